@@ -0,0 +1,72 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	// Получаем DATABASE_URL из переменных окружения
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		log.Fatal("DATABASE_URL не установлен в переменных окружения")
+	}
+
+	// Подключаемся к базе данных
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		log.Fatalf("Не удалось подключиться к базе данных: %v", err)
+	}
+	defer db.Close()
+
+	// Проверяем соединение
+	if err := db.Ping(); err != nil {
+		log.Fatalf("Не удалось выполнить ping базы данных: %v", err)
+	}
+
+	log.Println("✅ Подключение к PostgreSQL успешно")
+
+	// Читаем миграцию
+	migrationPath := "sql/migrations/016_add_assets.sql"
+	migrationSQL, err := ioutil.ReadFile(migrationPath)
+	if err != nil {
+		log.Fatalf("Не удалось прочитать файл миграции %s: %v", migrationPath, err)
+	}
+
+	log.Printf("📄 Применяем миграцию: %s", migrationPath)
+
+	// Выполняем миграцию
+	_, err = db.Exec(string(migrationSQL))
+	if err != nil {
+		log.Fatalf("❌ Ошибка выполнения миграции: %v", err)
+	}
+
+	log.Println("✅ Миграция успешно применена!")
+
+	// Проверяем что таблица реестра создана
+	var tableExists bool
+	checkSQL := `
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.tables
+			WHERE table_name = 'assets'
+		)`
+
+	err = db.QueryRow(checkSQL).Scan(&tableExists)
+	if err != nil {
+		log.Printf("⚠️ Не удалось проверить таблицу assets: %v", err)
+	} else if tableExists {
+		log.Println("✅ Таблица assets создана")
+	} else {
+		log.Println("❌ Таблица assets не найдена")
+	}
+
+	fmt.Println()
+	fmt.Println("🎯 Миграция завершена! Теперь можно:")
+	fmt.Println("   1. Ограничить разделы реестра через SUPPORTED_CRYPTOCURRENCIES, SUPPORTED_FIAT_CURRENCIES, SUPPORTED_PAYMENT_METHODS")
+	fmt.Println("   2. Переопределять названия, точность и лимиты заявок строками таблицы assets")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	// Получаем DATABASE_URL из переменных окружения
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		log.Fatal("DATABASE_URL не установлен в переменных окружения")
+	}
+
+	// Подключаемся к базе данных
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		log.Fatalf("Не удалось подключиться к базе данных: %v", err)
+	}
+	defer db.Close()
+
+	// Проверяем соединение
+	if err := db.Ping(); err != nil {
+		log.Fatalf("Не удалось выполнить ping базы данных: %v", err)
+	}
+
+	log.Println("✅ Подключение к PostgreSQL успешно")
+
+	// Читаем миграцию
+	migrationPath := "sql/migrations/012_add_auto_accept.sql"
+	migrationSQL, err := ioutil.ReadFile(migrationPath)
+	if err != nil {
+		log.Fatalf("Не удалось прочитать файл миграции %s: %v", migrationPath, err)
+	}
+
+	log.Printf("📄 Применяем миграцию: %s", migrationPath)
+
+	// Выполняем миграцию
+	_, err = db.Exec(string(migrationSQL))
+	if err != nil {
+		log.Fatalf("❌ Ошибка выполнения миграции: %v", err)
+	}
+
+	log.Println("✅ Миграция успешно применена!")
+
+	// Проверяем что колонка правил автопринятия создана
+	var columnExists bool
+	checkSQL := `
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_name = 'orders' AND column_name = 'auto_accept'
+		)`
+
+	err = db.QueryRow(checkSQL).Scan(&columnExists)
+	if err != nil {
+		log.Printf("⚠️ Не удалось проверить колонку orders.auto_accept: %v", err)
+	} else if columnExists {
+		log.Println("✅ Колонка orders.auto_accept создана")
+	} else {
+		log.Println("❌ Колонка orders.auto_accept не найдена")
+	}
+
+	fmt.Println()
+	fmt.Println("🎯 Миграция завершена! Теперь можно:")
+	fmt.Println("   1. Задавать в заявках правила автопринятия откликов")
+	fmt.Println("   2. Выбирать способ оплаты при отклике")
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	// Получаем DATABASE_URL из переменных окружения
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		log.Fatal("DATABASE_URL не установлен в переменных окружения")
+	}
+
+	// Подключаемся к базе данных
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		log.Fatalf("Не удалось подключиться к базе данных: %v", err)
+	}
+	defer db.Close()
+
+	// Проверяем соединение
+	if err := db.Ping(); err != nil {
+		log.Fatalf("Не удалось выполнить ping базы данных: %v", err)
+	}
+
+	log.Println("✅ Подключение к PostgreSQL успешно")
+
+	// Читаем миграцию
+	migrationPath := "sql/migrations/007_add_deal_disputes.sql"
+	migrationSQL, err := ioutil.ReadFile(migrationPath)
+	if err != nil {
+		log.Fatalf("Не удалось прочитать файл миграции %s: %v", migrationPath, err)
+	}
+
+	log.Printf("📄 Применяем миграцию: %s", migrationPath)
+
+	// Выполняем миграцию
+	_, err = db.Exec(string(migrationSQL))
+	if err != nil {
+		log.Fatalf("❌ Ошибка выполнения миграции: %v", err)
+	}
+
+	log.Println("✅ Миграция успешно применена!")
+
+	// Проверяем что таблица хронологии сделок создана
+	var eventsTableExists bool
+	checkSQL := `
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.tables 
+			WHERE table_name = 'deal_events'
+		)`
+
+	err = db.QueryRow(checkSQL).Scan(&eventsTableExists)
+	if err != nil {
+		log.Printf("⚠️ Не удалось проверить таблицу deal_events: %v", err)
+	} else if eventsTableExists {
+		log.Println("✅ Поля спора добавлены в таблицу deals, таблица deal_events создана")
+	} else {
+		log.Println("⚠️ Таблица deal_events может быть не создана корректно")
+	}
+
+	fmt.Println()
+	fmt.Println("🎯 Миграция завершена! Теперь можно:")
+	fmt.Println("   1. Открывать споры по сделкам с причиной и доказательствами")
+	fmt.Println("   2. Решать споры администраторами (TELEGRAM_ADMIN_IDS)")
+	fmt.Println("   3. Просматривать хронологию сделки")
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	// Получаем DATABASE_URL из переменных окружения
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		log.Fatal("DATABASE_URL не установлен в переменных окружения")
+	}
+
+	// Подключаемся к базе данных
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		log.Fatalf("Не удалось подключиться к базе данных: %v", err)
+	}
+	defer db.Close()
+
+	// Проверяем соединение
+	if err := db.Ping(); err != nil {
+		log.Fatalf("Не удалось выполнить ping базы данных: %v", err)
+	}
+
+	log.Println("✅ Подключение к PostgreSQL успешно")
+
+	// Читаем миграцию
+	migrationPath := "sql/migrations/006_add_deal_expiration.sql"
+	migrationSQL, err := ioutil.ReadFile(migrationPath)
+	if err != nil {
+		log.Fatalf("Не удалось прочитать файл миграции %s: %v", migrationPath, err)
+	}
+
+	log.Printf("📄 Применяем миграцию: %s", migrationPath)
+
+	// Выполняем миграцию
+	_, err = db.Exec(string(migrationSQL))
+	if err != nil {
+		log.Fatalf("❌ Ошибка выполнения миграции: %v", err)
+	}
+
+	log.Println("✅ Миграция успешно применена!")
+
+	// Проверяем что поле срока сделки добавлено
+	var expiresAtExists bool
+	checkSQL := `
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.columns 
+			WHERE table_name = 'deals' AND column_name = 'expires_at'
+		)`
+
+	err = db.QueryRow(checkSQL).Scan(&expiresAtExists)
+	if err != nil {
+		log.Printf("⚠️ Не удалось проверить поле expires_at: %v", err)
+	} else if expiresAtExists {
+		log.Println("✅ Поля expires_at и expiry_warning_sent добавлены в таблицу deals")
+	} else {
+		log.Println("⚠️ Поле expires_at может быть не добавлено корректно")
+	}
+
+	fmt.Println()
+	fmt.Println("🎯 Миграция завершена! Теперь можно:")
+	fmt.Println("   1. Задавать срок подтверждения сделок (DEAL_CONFIRMATION_TIMEOUT)")
+	fmt.Println("   2. Получать предупреждения о скором истечении сделок")
+	fmt.Println("   3. Автоматически закрывать неподтвержденные сделки")
+}
@@ -0,0 +1,260 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"p2pTG-crypto-exchange/internal/model"
+
+	"gopkg.in/yaml.v3"
+)
+
+// =====================================================
+// ЗАГРУЗКА КОНФИГУРАЦИИ ПРИЛОЖЕНИЯ
+// =====================================================
+
+// FileEnv переменная окружения с путем к файлу конфигурации (JSON или YAML, необязательно)
+const FileEnv = "CONFIG_FILE"
+
+// Load собирает конфигурацию приложения по приоритету:
+// значения по умолчанию, затем файл из CONFIG_FILE, затем переменные окружения (теги env).
+// Итоговая конфигурация проверяется целиком, при ошибке возвращаются все найденные проблемы.
+// В демо-режиме (demo) настройки Telegram бота необязательны
+func Load(demo bool) (*model.Config, error) {
+	cfg := Default()
+	cfg.Demo = demo
+
+	if path := os.Getenv(FileEnv); path != "" {
+		if err := LoadFile(path, &cfg); err != nil {
+			return nil, err
+		}
+	}
+	if err := ApplyEnv(&cfg); err != nil {
+		return nil, err
+	}
+
+	// URL веб-приложения по умолчанию зависит от порта
+	if cfg.Telegram.WebAppURL == "" {
+		cfg.Telegram.WebAppURL = "https://localhost:" + strconv.Itoa(cfg.Server.Port)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("неверная конфигурация:\n%w", err)
+	}
+	return &cfg, nil
+}
+
+// Default возвращает значения по умолчанию.
+// Нулевые бизнес-настройки означают значения по умолчанию сервиса
+func Default() model.Config {
+	return model.Config{
+		Database: model.DatabaseConfig{
+			DataDir:      "data",
+			MaxOpenConns: 25,
+			MaxIdleConns: 25,
+		},
+		Server: model.ServerConfig{
+			Host:            "0.0.0.0",
+			Port:            8080,
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 25 * time.Second, // Render ждет остановки 30 секунд после SIGTERM
+			MaxHeaderBytes:  1 << 20,
+		},
+		Security: model.SecurityConfig{
+			RateLimitRequests: 120,
+			RateLimitWrites:   20,
+			RateLimitDuration: time.Minute,
+		},
+		Business: model.BusinessConfig{
+			RateCacheTTL: 30 * time.Second,
+			RateMaxAge:   10 * time.Minute,
+		},
+		Logging: model.LoggingConfig{
+			Level:      "info",
+			Format:     "text",
+			OutputPath: "stdout",
+		},
+	}
+}
+
+// LoadFile применяет к cfg значения из файла конфигурации.
+// Формат определяется по расширению: .json, .yaml или .yml. Ключи совпадают с JSON-тегами model.Config,
+// длительности записываются строками ("30s"), списки - массивами или строками через запятую
+func LoadFile(path string, cfg *model.Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать файл конфигурации %s: %w", path, err)
+	}
+
+	var values map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber() // Большие ID чатов не должны терять точность
+		err = decoder.Decode(&values)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("неподдерживаемый формат файла конфигурации %s (ожидается .json, .yaml или .yml)", path)
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось разобрать файл конфигурации %s: %w", path, err)
+	}
+
+	return applyFileValues(reflect.ValueOf(cfg).Elem(), values, "")
+}
+
+// applyFileValues рекурсивно заполняет поля структуры по ключам JSON-тегов
+func applyFileValues(target reflect.Value, values map[string]interface{}, prefix string) error {
+	fields := make(map[string]reflect.Value)
+	for i := 0; i < target.NumField(); i++ {
+		name := strings.Split(target.Type().Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = target.Field(i)
+		}
+	}
+
+	for key, value := range values {
+		path := prefix + key
+		field, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("неизвестный параметр конфигурации %s", path)
+		}
+
+		if field.Kind() == reflect.Struct && field.Type() != durationType {
+			nested, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("параметр конфигурации %s должен быть разделом", path)
+			}
+			if err := applyFileValues(field, nested, path+"."); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := setField(field, fileValueString(value), path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileValueString приводит значение из файла к строке в формате переменных окружения
+func fileValueString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fileValueString(item))
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// ApplyEnv применяет к cfg заданные переменные окружения по тегам env.
+// Пустые переменные считаются незаданными
+func ApplyEnv(cfg *model.Config) error {
+	return applyEnv(reflect.ValueOf(cfg).Elem())
+}
+
+// applyEnv рекурсивно обходит поля структуры с тегами env
+func applyEnv(target reflect.Value) error {
+	for i := 0; i < target.NumField(); i++ {
+		field := target.Field(i)
+		if field.Kind() == reflect.Struct && field.Type() != durationType {
+			if err := applyEnv(field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := target.Type().Field(i).Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		if raw := strings.TrimSpace(os.Getenv(name)); raw != "" {
+			if err := setField(field, raw, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// durationType тип time.Duration: задается строкой вида "30s", а не числом
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setField разбирает строковое значение в поле конфигурации; name - переменная или ключ для сообщения об ошибке
+func setField(field reflect.Value, raw, name string) error {
+	if field.Type() == durationType {
+		value, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("неверное значение %s (ожидается длительность, например 30s или 24h): %s", name, raw)
+		}
+		field.SetInt(int64(value))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("неверное значение %s (ожидается целое число): %s", name, raw)
+		}
+		field.SetInt(value)
+	case reflect.Float32, reflect.Float64:
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("неверное значение %s (ожидается число): %s", name, raw)
+		}
+		field.SetFloat(value)
+	case reflect.Bool:
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("неверное значение %s (ожидается true или false): %s", name, raw)
+		}
+		field.SetBool(value)
+	case reflect.Slice:
+		items := splitList(raw)
+		list := reflect.MakeSlice(field.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setField(list.Index(i), item, name); err != nil {
+				return err
+			}
+		}
+		field.Set(list)
+	default:
+		return fmt.Errorf("параметр %s имеет неподдерживаемый тип %s", name, field.Type())
+	}
+	return nil
+}
+
+// splitList разбирает список значений через запятую (пустые элементы пропускаются)
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -2,12 +2,18 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"p2pTG-crypto-exchange/internal/logging"
 	"p2pTG-crypto-exchange/internal/model"
+	"p2pTG-crypto-exchange/internal/ratelimit"
 	"p2pTG-crypto-exchange/internal/service"
 
 	"github.com/gorilla/mux"
@@ -16,7 +22,10 @@ import (
 // Handler представляет слой обработки HTTP запросов
 // Принимает HTTP запросы, вызывает соответствующие сервисы и возвращает ответы
 type Handler struct {
-	service *service.Service // Сервис бизнес-логики
+	service           *service.Service   // Сервис бизнес-логики
+	limiter           *ratelimit.Limiter // Ограничение частоты запросов (nil - без ограничений)
+	trustProxyHeaders bool               // Брать IP клиента из X-Forwarded-For
+	ipWhitelist       []*net.IPNet       // Разрешенные IP клиентов (nil - доступ для всех)
 }
 
 // NewHandler создает новый экземпляр обработчика
@@ -27,38 +36,102 @@ func NewHandler(service *service.Service) *Handler {
 	}
 }
 
+// svc возвращает сервис для обработки запроса r: логи сервиса получают поля запроса
+// (request_id, user_id, deal_id, order_id)
+func (h *Handler) svc(r *http.Request) *service.Service {
+	return h.service.WithContext(r.Context())
+}
+
+// SetRateLimiter включает ограничение частоты запросов по IP и изменяющих запросов по пользователю.
+// trustProxyHeaders включается, когда сервер работает за прокси, добавляющим X-Forwarded-For
+func (h *Handler) SetRateLimiter(limiter *ratelimit.Limiter, trustProxyHeaders bool) {
+	h.limiter = limiter
+	h.trustProxyHeaders = trustProxyHeaders
+}
+
+// SetIPWhitelist пускает к серверу только клиентов из списка IP адресов и подсетей (CIDR).
+// IP клиента определяется так же, как для лимита запросов (с учетом trustProxyHeaders)
+func (h *Handler) SetIPWhitelist(entries []string) error {
+	whitelist := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			whitelist = append(whitelist, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, subnet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return fmt.Errorf("неверный IP адрес или подсеть в белом списке: %q", entry)
+		}
+		whitelist = append(whitelist, subnet)
+	}
+	h.ipWhitelist = whitelist
+	return nil
+}
+
 // RegisterRoutes регистрирует все HTTP маршруты приложения
 // Определяет какой обработчик вызывать для каждого URL
 func (h *Handler) RegisterRoutes(router *mux.Router) {
 	log.Println("[INFO] Регистрация HTTP маршрутов")
 
+	// Белый список IP действует для всех маршрутов, включая веб-интерфейс
+	router.Use(h.ipWhitelistMiddleware)
+
 	// Маршруты для API
 	api := router.PathPrefix("/api/v1").Subrouter()
 
+	// Все маршруты API получают поля логов запроса, лимит запросов по IP (до авторизации,
+	// чтобы ограничить перебор токенов), проверку сессионного токена и лимит записи по пользователю
+	api.Use(h.requestLogMiddleware, h.ipRateLimitMiddleware, h.authMiddleware, h.writeRateLimitMiddleware)
+
 	// Аутентификация и авторизация
 	api.HandleFunc("/auth/login", h.handleLogin).Methods("POST")
 	api.HandleFunc("/auth/me", h.handleGetCurrentUser).Methods("GET")
+	if h.service.DemoMode() {
+		api.HandleFunc("/auth/demo-login", h.handleDemoLogin).Methods("POST") // Вход без Telegram (только --demo)
+	}
 
 	// Управление заявками (ордерами)
-	api.HandleFunc("/orders", h.handleGetOrders).Methods("GET")           // Получить список заявок
-	api.HandleFunc("/orders", h.handleCreateOrder).Methods("POST")        // Создать новую заявку
-	api.HandleFunc("/orders/my", h.handleGetMyOrders).Methods("GET")      // Получить мои заявки (ВАЖНО: должно быть ДО {id})
-	api.HandleFunc("/orders/{id}", h.handleGetOrder).Methods("GET")       // Получить заявку по ID
-	api.HandleFunc("/orders/{id}", h.handleUpdateOrder).Methods("PUT")    // Обновить заявку
-	api.HandleFunc("/orders/{id}", h.handleCancelOrder).Methods("DELETE") // Отменить заявку
+	api.HandleFunc("/orders", h.handleGetOrders).Methods("GET")                // Получить список заявок
+	api.HandleFunc("/orders", h.handleCreateOrder).Methods("POST")             // Создать новую заявку
+	api.HandleFunc("/orders/my", h.handleGetMyOrders).Methods("GET")           // Получить мои заявки (ВАЖНО: должно быть ДО {id})
+	api.HandleFunc("/orders/{id}", h.handleGetOrder).Methods("GET")            // Получить заявку по ID
+	api.HandleFunc("/orders/{id}", h.handleUpdateOrder).Methods("PUT")         // Обновить заявку
+	api.HandleFunc("/orders/{id}", h.handleCancelOrder).Methods("DELETE")      // Отменить заявку
+	api.HandleFunc("/orders/{id}/extend", h.handleExtendOrder).Methods("POST") // Продлить срок заявки
+	api.HandleFunc("/orderbook", h.handleGetOrderBook).Methods("GET")          // Стакан заявок по торговой паре
+
+	// Рыночная статистика по завершенным сделкам
+	api.HandleFunc("/market/stats", h.handleGetMarketStats).Methods("GET")     // Статистика пар за 24 часа
+	api.HandleFunc("/market/candles", h.handleGetMarketCandles).Methods("GET") // Свечи OHLCV по паре
+
+	// Справочники
+	api.HandleFunc("/meta/assets", h.handleGetAssets).Methods("GET") // Реестр криптовалют, фиатных валют и способов оплаты
 
 	// Управление сделками
-	api.HandleFunc("/deals", h.handleGetDeals).Methods("GET")                  // Получить список сделок пользователя
-	api.HandleFunc("/deals", h.handleCreateDeal).Methods("POST")               // Создать новую сделку (отклик)
-	api.HandleFunc("/deals/{id}", h.handleGetDeal).Methods("GET")              // Получить сделку по ID
-	api.HandleFunc("/deals/{id}/confirm", h.handleConfirmDeal).Methods("POST") // Подтвердить сделку
+	api.HandleFunc("/deals", h.handleGetDeals).Methods("GET")                      // Получить список сделок пользователя
+	api.HandleFunc("/deals", h.handleCreateDeal).Methods("POST")                   // Создать новую сделку (отклик)
+	api.HandleFunc("/deals/{id}", h.handleGetDeal).Methods("GET")                  // Получить сделку по ID
+	api.HandleFunc("/deals/{id}/confirm", h.handleConfirmDeal).Methods("POST")     // Подтвердить сделку
+	api.HandleFunc("/deals/{id}/cancel", h.handleCancelDeal).Methods("POST")       // Отменить сделку (или запросить отмену)
+	api.HandleFunc("/deals/{id}/dispute", h.handleOpenDispute).Methods("POST")     // Открыть спор по сделке
+	api.HandleFunc("/deals/{id}/timeline", h.handleGetDealTimeline).Methods("GET") // Хронология сделки
+
+	// Арбитраж споров (только администраторы из TELEGRAM_ADMIN_IDS)
+	api.HandleFunc("/admin/disputes", h.handleGetDisputes).Methods("GET")                  // Открытые споры
+	api.HandleFunc("/admin/disputes/{id}/resolve", h.handleResolveDispute).Methods("POST") // Решить спор
 
 	// Система откликов
-	api.HandleFunc("/responses", h.handleCreateResponse).Methods("POST")              // Создать отклик на заявку
-	api.HandleFunc("/responses/my", h.handleGetMyResponses).Methods("GET")            // Получить мои отклики
-	api.HandleFunc("/responses/to-my", h.handleGetResponsesToMyOrders).Methods("GET") // Получить отклики на мои заявки
-	api.HandleFunc("/responses/{id}/accept", h.handleAcceptResponse).Methods("POST")  // Принять отклик
-	api.HandleFunc("/responses/{id}/reject", h.handleRejectResponse).Methods("POST")  // Отклонить отклик
+	api.HandleFunc("/responses", h.handleCreateResponse).Methods("POST")                 // Создать отклик на заявку
+	api.HandleFunc("/responses/my", h.handleGetMyResponses).Methods("GET")               // Получить мои отклики
+	api.HandleFunc("/responses/to-my", h.handleGetResponsesToMyOrders).Methods("GET")    // Получить отклики на мои заявки
+	api.HandleFunc("/responses/{id}/accept", h.handleAcceptResponse).Methods("POST")     // Принять отклик
+	api.HandleFunc("/responses/{id}/reject", h.handleRejectResponse).Methods("POST")     // Отклонить отклик
+	api.HandleFunc("/responses/{id}/counter", h.handleCounterResponse).Methods("POST")   // Встречное предложение по отклику
+	api.HandleFunc("/responses/{id}/withdraw", h.handleWithdrawResponse).Methods("POST") // Отозвать свой отклик
 
 	// Система отзывов и рейтингов
 	api.HandleFunc("/reviews", h.handleGetReviews).Methods("GET")    // Получить отзывы пользователя
@@ -86,64 +159,87 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 
 // handleLogin обрабатывает авторизацию пользователя через Telegram WebApp
 func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
-	log.Println("[INFO] Обработка запроса авторизации")
+	logging.Printf(r.Context(), "[INFO] Обработка запроса авторизации")
 
 	// Читаем данные авторизации из тела запроса
 	var authData model.TelegramAuthData
 	if err := json.NewDecoder(r.Body).Decode(&authData); err != nil {
-		log.Printf("[WARN] Неверный формат данных авторизации: %v", err)
+		logging.Printf(r.Context(), "[WARN] Неверный формат данных авторизации: %v", err)
 		h.sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
 		return
 	}
 
 	// Вызываем сервис авторизации
-	user, err := h.service.AuthenticateUser(&authData)
+	user, err := h.svc(r).AuthenticateUser(&authData)
 	if err != nil {
-		log.Printf("[WARN] Ошибка авторизации: %v", err)
+		logging.Printf(r.Context(), "[WARN] Ошибка авторизации: %v", err)
 		h.sendErrorResponse(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
-	// Возвращаем успешный ответ с данными пользователя
-	log.Printf("[INFO] Успешная авторизация пользователя: ID=%d", user.ID)
-	h.sendJSONResponse(w, map[string]interface{}{
-		"success": true,
-		"user":    user,
-		"message": "Авторизация успешна",
-	})
+	h.sendSession(w, r, user)
 }
 
-// handleGetCurrentUser возвращает информацию о текущем пользователе
-func (h *Handler) handleGetCurrentUser(w http.ResponseWriter, r *http.Request) {
-	log.Printf("[INFO] Обработка запроса получения текущего пользователя")
+// handleDemoLogin выполняет вход под демонстрационным пользователем без Telegram.
+// Маршрут регистрируется только в демо-режиме; telegram_id в теле выбирает пользователя (необязательно)
+func (h *Handler) handleDemoLogin(w http.ResponseWriter, r *http.Request) {
+	logging.Printf(r.Context(), "[INFO] Обработка запроса демо-входа")
 
-	// Получаем Telegram ID пользователя из заголовка
-	telegramIDStr := r.Header.Get("X-Telegram-User-ID")
-	if telegramIDStr == "" {
-		log.Printf("[WARN] Не передан Telegram ID пользователя в /auth/me")
-		h.sendErrorResponse(w, "Требуется авторизация", http.StatusUnauthorized)
+	var req struct {
+		TelegramID int64 `json:"telegram_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		logging.Printf(r.Context(), "[WARN] Неверный формат запроса демо-входа: %v", err)
+		h.sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
 		return
 	}
 
-	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
+	user, err := h.svc(r).DemoLogin(req.TelegramID)
 	if err != nil {
-		log.Printf("[WARN] Неверный формат Telegram ID в /auth/me: %v", err)
-		h.sendErrorResponse(w, "Неверный ID пользователя", http.StatusBadRequest)
+		logging.Printf(r.Context(), "[WARN] Ошибка демо-входа: %v", err)
+		h.sendErrorResponse(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
-	// Получаем пользователя по Telegram ID
-	user, err := h.service.GetUserByTelegramID(telegramID)
+	h.sendSession(w, r, user)
+}
+
+// sendSession выпускает подписанный сессионный токен для пользователя и возвращает его вместе с данными пользователя
+func (h *Handler) sendSession(w http.ResponseWriter, r *http.Request, user *model.User) {
+	token, expiresAt, err := h.svc(r).IssueSessionToken(user)
 	if err != nil {
-		log.Printf("[ERROR] Пользователь не найден в /auth/me: %v", err)
-		h.sendErrorResponse(w, "Пользователь не найден", http.StatusNotFound)
+		logging.Printf(r.Context(), "[ERROR] Не удалось выпустить сессионный токен: %v", err)
+		h.sendErrorResponse(w, "Не удалось создать сессию", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("[INFO] Данные текущего пользователя получены: ID=%d, TelegramID=%d", user.ID, user.TelegramID)
+	// Возвращаем успешный ответ с данными пользователя и токеном
+	logging.Printf(r.Context(), "[INFO] Успешная авторизация пользователя: ID=%d", user.ID)
 	h.sendJSONResponse(w, map[string]interface{}{
-		"success": true,
-		"user":    user,
+		"success":    true,
+		"user":       user,
+		"token":      token,
+		"expires_at": expiresAt,
+		"is_admin":   h.svc(r).IsAdmin(user),
+		"message":    "Авторизация успешна",
+	})
+}
+
+// handleGetCurrentUser возвращает информацию о текущем пользователе
+func (h *Handler) handleGetCurrentUser(w http.ResponseWriter, r *http.Request) {
+	logging.Printf(r.Context(), "[INFO] Обработка запроса получения текущего пользователя")
+
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	logging.Printf(r.Context(), "[INFO] Данные текущего пользователя получены: ID=%d, TelegramID=%d", user.ID, user.TelegramID)
+	h.sendJSONResponse(w, map[string]interface{}{
+		"success":  true,
+		"user":     user,
+		"is_admin": h.svc(r).IsAdmin(user),
 	})
 }
 
@@ -153,7 +249,7 @@ func (h *Handler) handleGetCurrentUser(w http.ResponseWriter, r *http.Request) {
 
 // handleGetOrders обрабатывает получение списка заявок с фильтрацией
 func (h *Handler) handleGetOrders(w http.ResponseWriter, r *http.Request) {
-	log.Println("[INFO] Обработка запроса получения заявок")
+	logging.Printf(r.Context(), "[INFO] Обработка запроса получения заявок")
 
 	// Парсим параметры фильтрации из query string
 	filter := &model.OrderFilter{}
@@ -202,18 +298,18 @@ func (h *Handler) handleGetOrders(w http.ResponseWriter, r *http.Request) {
 	// Включить неактивные заявки (для фильтрации откликов)
 	if includeInactive := r.URL.Query().Get("include_inactive"); includeInactive == "true" {
 		filter.IncludeInactive = true
-		log.Printf("[DEBUG] Включены неактивные заявки для фильтрации")
+		logging.Printf(r.Context(), "[DEBUG] Включены неактивные заявки для фильтрации")
 	}
 
 	// Получаем заявки через сервис
-	orders, err := h.service.GetOrders(filter)
+	orders, err := h.svc(r).GetOrders(filter)
 	if err != nil {
-		log.Printf("[ERROR] Ошибка при получении заявок: %v", err)
+		logging.Printf(r.Context(), "[ERROR] Ошибка при получении заявок: %v", err)
 		h.sendErrorResponse(w, "Не удалось получить заявки", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("[INFO] Возвращено заявок: %d", len(orders))
+	logging.Printf(r.Context(), "[INFO] Возвращено заявок: %d", len(orders))
 	h.sendJSONResponse(w, map[string]interface{}{
 		"success": true,
 		"orders":  orders,
@@ -221,42 +317,142 @@ func (h *Handler) handleGetOrders(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// handleCreateOrder обрабатывает создание новой заявки
-func (h *Handler) handleCreateOrder(w http.ResponseWriter, r *http.Request) {
-	log.Println("[INFO] Обработка запроса создания заявки")
+// handleGetOrderBook обрабатывает получение стакана заявок по торговой паре
+// Параметры: crypto, fiat (обязательно), payment_method, depth
+func (h *Handler) handleGetOrderBook(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := &model.OrderBookFilter{
+		Cryptocurrency: query.Get("crypto"),
+		FiatCurrency:   query.Get("fiat"),
+		PaymentMethod:  query.Get("payment_method"),
+	}
+	if depthStr := query.Get("depth"); depthStr != "" {
+		depth, err := strconv.Atoi(depthStr)
+		if err != nil || depth <= 0 {
+			h.sendErrorResponse(w, "Неверная глубина стакана", http.StatusBadRequest)
+			return
+		}
+		filter.Depth = depth
+	}
+	if strings.TrimSpace(filter.Cryptocurrency) == "" || strings.TrimSpace(filter.FiatCurrency) == "" {
+		h.sendErrorResponse(w, "Укажите криптовалюту и фиатную валюту", http.StatusBadRequest)
+		return
+	}
+
+	book, err := h.svc(r).GetOrderBook(filter)
+	if err != nil {
+		logging.Printf(r.Context(), "[ERROR] Ошибка получения стакана: %v", err)
+		h.sendErrorResponse(w, "Не удалось получить стакан заявок", http.StatusInternalServerError)
+		return
+	}
+
+	h.sendJSONResponse(w, map[string]interface{}{
+		"success":   true,
+		"orderbook": book,
+	})
+}
+
+// handleGetMarketStats обрабатывает получение статистики рынка за 24 часа
+// Параметры: pair (необязательно, например USDT-RUB)
+func (h *Handler) handleGetMarketStats(w http.ResponseWriter, r *http.Request) {
+	pair := r.URL.Query().Get("pair")
+	if strings.TrimSpace(pair) != "" {
+		if _, _, err := model.ParseTradingPair(pair); err != nil {
+			h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	stats, err := h.svc(r).GetMarketStats(pair)
+	if err != nil {
+		logging.Printf(r.Context(), "[ERROR] Ошибка получения статистики рынка: %v", err)
+		h.sendErrorResponse(w, "Не удалось получить статистику рынка", http.StatusInternalServerError)
+		return
+	}
+
+	h.sendJSONResponse(w, map[string]interface{}{
+		"success": true,
+		"stats":   stats,
+		"count":   len(stats),
+	})
+}
 
-	// Получаем Telegram ID пользователя из заголовка
-	telegramIDStr := r.Header.Get("X-Telegram-User-ID")
-	if telegramIDStr == "" {
-		log.Printf("[WARN] Не передан Telegram ID пользователя")
-		h.sendErrorResponse(w, "Требуется авторизация", http.StatusUnauthorized)
+// handleGetMarketCandles обрабатывает получение свечей OHLCV по торговой паре
+// Параметры: pair (обязательно, например USDT-RUB), interval (15m, 1h, 4h, 1d), limit
+func (h *Handler) handleGetMarketCandles(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := 0
+	if limitStr := query.Get("limit"); limitStr != "" {
+		value, err := strconv.Atoi(limitStr)
+		if err != nil || value <= 0 {
+			h.sendErrorResponse(w, "Неверное количество свечей", http.StatusBadRequest)
+			return
+		}
+		limit = value
+	}
+	if _, _, err := model.ParseTradingPair(query.Get("pair")); err != nil {
+		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if interval := query.Get("interval"); interval != "" {
+		if _, _, err := model.ParseCandleInterval(interval); err != nil {
+			h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
-	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
+	candles, err := h.svc(r).GetMarketCandles(query.Get("pair"), query.Get("interval"), limit)
 	if err != nil {
-		log.Printf("[WARN] Неверный формат Telegram ID: %v", err)
-		h.sendErrorResponse(w, "Неверный ID пользователя", http.StatusBadRequest)
+		logging.Printf(r.Context(), "[ERROR] Ошибка получения свечей: %v", err)
+		h.sendErrorResponse(w, "Не удалось получить историю цен", http.StatusInternalServerError)
+		return
+	}
+	if candles == nil {
+		candles = []*model.Candle{}
+	}
+
+	h.sendJSONResponse(w, map[string]interface{}{
+		"success": true,
+		"candles": candles,
+		"count":   len(candles),
+	})
+}
+
+// handleGetAssets обрабатывает получение реестра криптовалют, фиатных валют и способов оплаты
+func (h *Handler) handleGetAssets(w http.ResponseWriter, r *http.Request) {
+	h.sendJSONResponse(w, map[string]interface{}{
+		"success": true,
+		"assets":  h.svc(r).GetAssetRegistry(),
+	})
+}
+
+// handleCreateOrder обрабатывает создание новой заявки
+func (h *Handler) handleCreateOrder(w http.ResponseWriter, r *http.Request) {
+	logging.Printf(r.Context(), "[INFO] Обработка запроса создания заявки")
+
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 
 	// Читаем данные заявки из тела запроса
 	var orderData model.Order
 	if err := json.NewDecoder(r.Body).Decode(&orderData); err != nil {
-		log.Printf("[WARN] Неверный формат данных заявки: %v", err)
+		logging.Printf(r.Context(), "[WARN] Неверный формат данных заявки: %v", err)
 		h.sendErrorResponse(w, "Неверный формат данных заявки", http.StatusBadRequest)
 		return
 	}
 
 	// Создаем заявку через сервис (передаем Telegram ID)
-	order, err := h.service.CreateOrder(telegramID, &orderData)
+	order, err := h.svc(r).CreateOrder(user.TelegramID, &orderData)
 	if err != nil {
-		log.Printf("[WARN] Ошибка создания заявки: %v", err)
+		logging.Printf(r.Context(), "[WARN] Ошибка создания заявки: %v", err)
 		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("[INFO] Создана новая заявка: ID=%d", order.ID)
+	logging.Printf(r.Context(), "[INFO] Создана новая заявка: ID=%d", order.ID)
 	h.sendJSONResponse(w, map[string]interface{}{
 		"success": true,
 		"order":   order,
@@ -266,49 +462,40 @@ func (h *Handler) handleCreateOrder(w http.ResponseWriter, r *http.Request) {
 
 // handleUpdateOrder обрабатывает обновление существующей заявки
 func (h *Handler) handleUpdateOrder(w http.ResponseWriter, r *http.Request) {
-	log.Println("[INFO] Обработка запроса обновления заявки")
+	logging.Printf(r.Context(), "[INFO] Обработка запроса обновления заявки")
 
 	// Получаем ID заявки из URL
 	vars := mux.Vars(r)
 	orderID, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
-		log.Printf("[WARN] Неверный ID заявки: %v", err)
+		logging.Printf(r.Context(), "[WARN] Неверный ID заявки: %v", err)
 		h.sendErrorResponse(w, "Неверный ID заявки", http.StatusBadRequest)
 		return
 	}
 
-	// Получаем Telegram ID пользователя из заголовка
-	telegramIDStr := r.Header.Get("X-Telegram-User-ID")
-	if telegramIDStr == "" {
-		log.Printf("[WARN] Не передан Telegram ID пользователя")
-		h.sendErrorResponse(w, "Требуется авторизация", http.StatusUnauthorized)
-		return
-	}
-
-	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
-	if err != nil {
-		log.Printf("[WARN] Неверный формат Telegram ID: %v", err)
-		h.sendErrorResponse(w, "Неверный ID пользователя", http.StatusBadRequest)
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 
 	// Читаем данные заявки из тела запроса
 	var orderData model.Order
 	if err := json.NewDecoder(r.Body).Decode(&orderData); err != nil {
-		log.Printf("[WARN] Неверный формат данных заявки: %v", err)
+		logging.Printf(r.Context(), "[WARN] Неверный формат данных заявки: %v", err)
 		h.sendErrorResponse(w, "Неверный формат данных заявки", http.StatusBadRequest)
 		return
 	}
 
 	// Обновляем заявку через сервис
-	order, err := h.service.UpdateOrder(orderID, telegramID, &orderData)
+	order, err := h.svc(r).UpdateOrder(orderID, user.TelegramID, &orderData)
 	if err != nil {
-		log.Printf("[WARN] Ошибка обновления заявки: %v", err)
+		logging.Printf(r.Context(), "[WARN] Ошибка обновления заявки: %v", err)
 		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("[INFO] Заявка успешно обновлена: ID=%d", order.ID)
+	logging.Printf(r.Context(), "[INFO] Заявка успешно обновлена: ID=%d", order.ID)
 	h.sendJSONResponse(w, map[string]interface{}{
 		"success": true,
 		"order":   order,
@@ -325,17 +512,17 @@ func (h *Handler) handleGetOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("[INFO] Запрос заявки по ID: %d", orderID)
+	logging.Printf(r.Context(), "[INFO] Запрос заявки по ID: %d", orderID)
 
 	// Получаем заявку через сервис
-	order, err := h.service.GetOrder(orderID)
+	order, err := h.svc(r).GetOrder(orderID)
 	if err != nil {
-		log.Printf("[WARN] Заявка ID=%d не найдена: %v", orderID, err)
+		logging.Printf(r.Context(), "[WARN] Заявка ID=%d не найдена: %v", orderID, err)
 		h.sendErrorResponse(w, "Заявка не найдена", http.StatusNotFound)
 		return
 	}
 
-	log.Printf("[INFO] Заявка ID=%d успешно получена", orderID)
+	logging.Printf(r.Context(), "[INFO] Заявка ID=%d успешно получена", orderID)
 	h.sendJSONResponse(w, map[string]interface{}{
 		"success": true,
 		"order":   order,
@@ -351,47 +538,74 @@ func (h *Handler) handleCancelOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// TODO: Получить ID пользователя из JWT токена
-	userID := int64(1) // Заглушка
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
 
 	// Отменяем заявку через сервис
-	if err := h.service.CancelOrder(userID, orderID); err != nil {
-		log.Printf("[WARN] Ошибка отмены заявки ID=%d: %v", orderID, err)
+	if err := h.svc(r).CancelOrder(user.ID, orderID); err != nil {
+		logging.Printf(r.Context(), "[WARN] Ошибка отмены заявки ID=%d: %v", orderID, err)
 		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("[INFO] Заявка ID=%d отменена пользователем ID=%d", orderID, userID)
+	logging.Printf(r.Context(), "[INFO] Заявка ID=%d отменена пользователем ID=%d", orderID, user.ID)
 	h.sendJSONResponse(w, map[string]interface{}{
 		"success": true,
 		"message": "Заявка успешно отменена",
 	})
 }
 
-// handleGetMyOrders обрабатывает получение заявок пользователя (страница "Мои")
-func (h *Handler) handleGetMyOrders(w http.ResponseWriter, r *http.Request) {
-	log.Println("[INFO] Обработка запроса получения заявок пользователя")
+// handleExtendOrder обрабатывает продление срока действия заявки
+// Тело запроса необязательно: {"hours": N}, без него используется срок по умолчанию
+func (h *Handler) handleExtendOrder(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	orderID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.sendErrorResponse(w, "Неверный ID заявки", http.StatusBadRequest)
+		return
+	}
 
-	// Получаем Telegram ID пользователя из заголовка
-	telegramIDStr := r.Header.Get("X-Telegram-User-ID")
-	if telegramIDStr == "" {
-		log.Printf("[WARN] Не передан Telegram ID пользователя")
-		h.sendErrorResponse(w, "Требуется авторизация", http.StatusUnauthorized)
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 
-	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
+	var req struct {
+		Hours int `json:"hours"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			h.sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+			return
+		}
+	}
+
+	order, err := h.svc(r).ExtendOrder(user.ID, orderID, req.Hours)
 	if err != nil {
-		log.Printf("[WARN] Неверный формат Telegram ID: %v", err)
-		h.sendErrorResponse(w, "Неверный ID пользователя", http.StatusBadRequest)
+		logging.Printf(r.Context(), "[WARN] Ошибка продления заявки ID=%d: %v", orderID, err)
+		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Получаем пользователя по Telegram ID
-	user, err := h.service.GetUserByTelegramID(telegramID)
-	if err != nil {
-		log.Printf("[ERROR] Пользователь не найден: %v", err)
-		h.sendErrorResponse(w, "Пользователь не найден", http.StatusNotFound)
+	h.sendJSONResponse(w, map[string]interface{}{
+		"success":    true,
+		"order":      order,
+		"expires_at": order.ExpiresAt,
+		"message":    "Срок заявки продлен",
+	})
+}
+
+// handleGetMyOrders обрабатывает получение заявок пользователя (страница "Мои")
+func (h *Handler) handleGetMyOrders(w http.ResponseWriter, r *http.Request) {
+	logging.Printf(r.Context(), "[INFO] Обработка запроса получения заявок пользователя")
+
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -403,14 +617,14 @@ func (h *Handler) handleGetMyOrders(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Получаем заявки пользователя
-	orders, err := h.service.GetOrders(filter)
+	orders, err := h.svc(r).GetOrders(filter)
 	if err != nil {
-		log.Printf("[ERROR] Ошибка при получении заявок пользователя: %v", err)
+		logging.Printf(r.Context(), "[ERROR] Ошибка при получении заявок пользователя: %v", err)
 		h.sendErrorResponse(w, "Не удалось получить заявки", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("[INFO] Возвращено заявок пользователю ID=%d: %d", user.ID, len(orders))
+	logging.Printf(r.Context(), "[INFO] Возвращено заявок пользователю ID=%d: %d", user.ID, len(orders))
 	h.sendJSONResponse(w, map[string]interface{}{
 		"success": true,
 		"orders":  orders,
@@ -424,40 +638,23 @@ func (h *Handler) handleGetMyOrders(w http.ResponseWriter, r *http.Request) {
 
 // handleGetDeals обрабатывает получение списка сделок пользователя
 func (h *Handler) handleGetDeals(w http.ResponseWriter, r *http.Request) {
-	log.Println("[INFO] Обработка запроса получения сделок пользователя")
-
-	// Получаем Telegram ID пользователя из заголовка
-	telegramIDStr := r.Header.Get("X-Telegram-User-ID")
-	if telegramIDStr == "" {
-		log.Printf("[WARN] Не передан Telegram ID пользователя")
-		h.sendErrorResponse(w, "Требуется авторизация", http.StatusUnauthorized)
-		return
-	}
-
-	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
-	if err != nil {
-		log.Printf("[WARN] Неверный формат Telegram ID: %v", err)
-		h.sendErrorResponse(w, "Неверный ID пользователя", http.StatusBadRequest)
-		return
-	}
+	logging.Printf(r.Context(), "[INFO] Обработка запроса получения сделок пользователя")
 
-	// Получаем пользователя по Telegram ID для получения внутреннего ID
-	user, err := h.service.GetUserByTelegramID(telegramID)
-	if err != nil {
-		log.Printf("[ERROR] Пользователь не найден: %v", err)
-		h.sendErrorResponse(w, "Пользователь не найден", http.StatusNotFound)
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 
 	// Получаем сделки пользователя через сервис
-	deals, err := h.service.GetUserDeals(user.ID)
+	deals, err := h.svc(r).GetUserDeals(user.ID)
 	if err != nil {
-		log.Printf("[ERROR] Ошибка при получении сделок: %v", err)
+		logging.Printf(r.Context(), "[ERROR] Ошибка при получении сделок: %v", err)
 		h.sendErrorResponse(w, "Не удалось получить сделки", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("[INFO] Возвращено сделок пользователю ID=%d: %d", user.ID, len(deals))
+	logging.Printf(r.Context(), "[INFO] Возвращено сделок пользователю ID=%d: %d", user.ID, len(deals))
 	h.sendJSONResponse(w, map[string]interface{}{
 		"success": true,
 		"deals":   deals,
@@ -467,71 +664,54 @@ func (h *Handler) handleGetDeals(w http.ResponseWriter, r *http.Request) {
 
 // handleCreateDeal обрабатывает создание нового отклика на заявку (в новой логике)
 func (h *Handler) handleCreateDeal(w http.ResponseWriter, r *http.Request) {
-	log.Println("[INFO] Обработка запроса создания сделки")
-
-	// Получаем Telegram ID пользователя из заголовка
-	telegramIDStr := r.Header.Get("X-Telegram-User-ID")
-	if telegramIDStr == "" {
-		log.Printf("[WARN] Не передан Telegram ID пользователя")
-		h.sendErrorResponse(w, "Требуется авторизация", http.StatusUnauthorized)
-		return
-	}
+	logging.Printf(r.Context(), "[INFO] Обработка запроса создания сделки")
 
-	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
-	if err != nil {
-		log.Printf("[WARN] Неверный формат Telegram ID: %v", err)
-		h.sendErrorResponse(w, "Неверный ID пользователя", http.StatusBadRequest)
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 
-	// Читаем данные для создания сделки
-	var createDealRequest struct {
-		OrderID    int64  `json:"order_id"`
-		Message    string `json:"message"`
-		AutoAccept bool   `json:"auto_accept"`
-	}
+	// Читаем данные для создания сделки: отклик с флагом auto_accept сразу становится сделкой,
+	// если подходит под правила автопринятия заявки
+	var responseData model.CreateResponseRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&createDealRequest); err != nil {
-		log.Printf("[WARN] Неверный формат данных сделки: %v", err)
+	if err := json.NewDecoder(r.Body).Decode(&responseData); err != nil {
+		logging.Printf(r.Context(), "[WARN] Неверный формат данных сделки: %v", err)
 		h.sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
 		return
 	}
 
-	if createDealRequest.OrderID == 0 {
-		log.Printf("[WARN] Не указан ID заявки")
+	if responseData.OrderID == 0 {
+		logging.Printf(r.Context(), "[WARN] Не указан ID заявки")
 		h.sendErrorResponse(w, "Требуется ID заявки", http.StatusBadRequest)
 		return
 	}
 
-	// Получаем пользователя по Telegram ID
-	user, err := h.service.GetUserByTelegramID(telegramID)
-	if err != nil {
-		log.Printf("[ERROR] Пользователь не найден: %v", err)
-		h.sendErrorResponse(w, "Пользователь не найден", http.StatusNotFound)
-		return
-	}
-
 	// Создаем отклик через новую систему
-	responseData := &model.CreateResponseRequest{
-		OrderID: createDealRequest.OrderID,
-		Message: createDealRequest.Message,
-	}
-
-	response, err := h.service.CreateResponse(user.ID, responseData)
+	response, err := h.svc(r).CreateResponse(user.ID, &responseData)
 	if err != nil {
-		log.Printf("[WARN] Ошибка создания отклика: %v", err)
+		logging.Printf(r.Context(), "[WARN] Ошибка создания отклика: %v", err)
 		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("[INFO] Создан новый отклик: ID=%d", response.ID)
+	logging.Printf(r.Context(), "[INFO] Создан новый отклик: ID=%d (статус %s)", response.ID, response.Status)
 	h.sendJSONResponse(w, map[string]interface{}{
 		"success":  true,
 		"response": response,
-		"message":  "Отклик успешно создан",
+		"message":  createdResponseMessage(response),
 	})
 }
 
+// createdResponseMessage возвращает сообщение об успешном отклике с учетом автопринятия
+func createdResponseMessage(response *model.Response) string {
+	if response.Status == model.ResponseStatusAccepted {
+		return "Отклик принят автоматически, создана сделка"
+	}
+	return "Отклик успешно создан"
+}
+
 // handleGetDeal обрабатывает получение сделки по ID
 func (h *Handler) handleGetDeal(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -541,21 +721,24 @@ func (h *Handler) handleGetDeal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// TODO: Получить ID пользователя из JWT токена
-	userID := int64(1) // Заглушка
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
 
-	log.Printf("[INFO] Запрос сделки ID=%d пользователем ID=%d", dealID, userID)
+	logging.Printf(r.Context(), "[INFO] Запрос сделки ID=%d пользователем ID=%d", dealID, user.ID)
 
 	// Получаем сделку через сервис с проверкой прав доступа
-	deal, err := h.service.GetDeal(dealID, userID)
+	deal, err := h.svc(r).GetDeal(dealID, user.ID)
 	if err != nil {
-		log.Printf("[WARN] Ошибка получения сделки ID=%d: %v", dealID, err)
+		logging.Printf(r.Context(), "[WARN] Ошибка получения сделки ID=%d: %v", dealID, err)
 		// Возвращаем общую ошибку чтобы не раскрывать детали
 		h.sendErrorResponse(w, "Сделка не найдена или доступ запрещен", http.StatusNotFound)
 		return
 	}
 
-	log.Printf("[INFO] Сделка ID=%d успешно получена", dealID)
+	logging.Printf(r.Context(), "[INFO] Сделка ID=%d успешно получена", dealID)
 	h.sendJSONResponse(w, map[string]interface{}{
 		"success": true,
 		"deal":    deal,
@@ -571,30 +754,13 @@ func (h *Handler) handleConfirmDeal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Получаем данные пользователя из Telegram заголовка
-	telegramUserIDStr := r.Header.Get("X-Telegram-User-ID")
-	if telegramUserIDStr == "" {
-		log.Printf("[ERROR] Отсутствует заголовок X-Telegram-User-ID")
-		h.sendErrorResponse(w, "Не авторизован", http.StatusUnauthorized)
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 
-	telegramUserID, err := strconv.ParseInt(telegramUserIDStr, 10, 64)
-	if err != nil {
-		log.Printf("[ERROR] Неверный формат Telegram User ID: %s", telegramUserIDStr)
-		h.sendErrorResponse(w, "Неверный формат пользователя", http.StatusBadRequest)
-		return
-	}
-
-	// Получаем пользователя по Telegram ID
-	user, err := h.service.GetUserByTelegramID(telegramUserID)
-	if err != nil {
-		log.Printf("[ERROR] Ошибка получения пользователя: %v", err)
-		h.sendErrorResponse(w, "Не авторизован", http.StatusUnauthorized)
-		return
-	}
-
-	log.Printf("[INFO] Подтверждение сделки ID=%d пользователем ID=%d", dealID, user.ID)
+	logging.Printf(r.Context(), "[INFO] Подтверждение сделки ID=%d пользователем ID=%d", dealID, user.ID)
 
 	// Читаем данные запроса
 	var requestData struct {
@@ -604,7 +770,7 @@ func (h *Handler) handleConfirmDeal(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
-		log.Printf("[WARN] Неверный формат данных подтверждения: %v", err)
+		logging.Printf(r.Context(), "[WARN] Неверный формат данных подтверждения: %v", err)
 		// Игнорируем ошибку декодирования - подтверждение без доказательств
 	}
 
@@ -615,16 +781,16 @@ func (h *Handler) handleConfirmDeal(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Подтверждаем сделку через сервис с указанием роли пользователя
-	if err := h.service.ConfirmDealWithRole(dealID, user.ID, requestData.IsAuthor, paymentProof); err != nil {
-		log.Printf("[WARN] Ошибка подтверждения сделки ID=%d: %v", dealID, err)
+	if err := h.svc(r).ConfirmDealWithRole(dealID, user.ID, requestData.IsAuthor, paymentProof); err != nil {
+		logging.Printf(r.Context(), "[WARN] Ошибка подтверждения сделки ID=%d: %v", dealID, err)
 		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("[INFO] Сделка ID=%d подтверждена пользователем ID=%d", dealID, user.ID)
+	logging.Printf(r.Context(), "[INFO] Сделка ID=%d подтверждена пользователем ID=%d", dealID, user.ID)
 
 	// Проверяем завершена ли сделка (оба участника подтвердили)
-	deal, err := h.service.GetDeal(dealID, user.ID)
+	deal, err := h.svc(r).GetDeal(dealID, user.ID)
 	dealCompleted := false
 	if err == nil {
 		dealCompleted = deal.Status == "completed"
@@ -637,13 +803,187 @@ func (h *Handler) handleConfirmDeal(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleCancelDeal обрабатывает отмену сделки участником
+// До отметки об оплате покупатель отменяет сделку сразу, иначе нужна отмена обеими сторонами
+func (h *Handler) handleCancelDeal(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	dealID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.sendErrorResponse(w, "Неверный ID сделки", http.StatusBadRequest)
+		return
+	}
+
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req model.CancelDealRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	deal, err := h.svc(r).CancelDeal(dealID, user.ID, &req)
+	if err != nil {
+		logging.Printf(r.Context(), "[WARN] Ошибка отмены сделки ID=%d: %v", dealID, err)
+		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cancelled := deal.Status == model.DealStatusCancelled
+	message := "Запрос на отмену отправлен второй стороне"
+	if cancelled {
+		message = "Сделка отменена"
+	}
+
+	h.sendJSONResponse(w, map[string]interface{}{
+		"success":   true,
+		"deal":      deal,
+		"cancelled": cancelled,
+		"message":   message,
+	})
+}
+
+// =====================================================
+// ОБРАБОТЧИКИ СПОРОВ
+// =====================================================
+
+// handleOpenDispute обрабатывает открытие спора участником сделки
+func (h *Handler) handleOpenDispute(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	dealID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.sendErrorResponse(w, "Неверный ID сделки", http.StatusBadRequest)
+		return
+	}
+
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req model.OpenDisputeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	deal, err := h.svc(r).OpenDispute(dealID, user.ID, &req)
+	if err != nil {
+		logging.Printf(r.Context(), "[WARN] Ошибка открытия спора по сделке ID=%d: %v", dealID, err)
+		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.sendJSONResponse(w, map[string]interface{}{
+		"success": true,
+		"deal":    deal,
+		"message": "Спор открыт, администратор рассмотрит его в ближайшее время",
+	})
+}
+
+// handleGetDealTimeline обрабатывает получение хронологии сделки
+func (h *Handler) handleGetDealTimeline(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	dealID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.sendErrorResponse(w, "Неверный ID сделки", http.StatusBadRequest)
+		return
+	}
+
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := h.svc(r).GetDealTimeline(dealID, user)
+	if err != nil {
+		logging.Printf(r.Context(), "[WARN] Ошибка получения хронологии сделки ID=%d: %v", dealID, err)
+		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if events == nil {
+		events = []*model.DealEvent{}
+	}
+
+	h.sendJSONResponse(w, map[string]interface{}{
+		"success":  true,
+		"timeline": events,
+	})
+}
+
+// handleGetDisputes обрабатывает получение списка открытых споров для администратора
+func (h *Handler) handleGetDisputes(w http.ResponseWriter, r *http.Request) {
+	admin, ok := h.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	deals, err := h.svc(r).GetOpenDisputes(admin)
+	if err != nil {
+		logging.Printf(r.Context(), "[ERROR] Ошибка получения споров: %v", err)
+		h.sendErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if deals == nil {
+		deals = []*model.Deal{}
+	}
+
+	h.sendJSONResponse(w, map[string]interface{}{
+		"success": true,
+		"deals":   deals,
+		"count":   len(deals),
+	})
+}
+
+// handleResolveDispute обрабатывает решение спора администратором
+// Тело запроса: {"winner_id": ID участника, "comment": "..."}
+func (h *Handler) handleResolveDispute(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	dealID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		h.sendErrorResponse(w, "Неверный ID сделки", http.StatusBadRequest)
+		return
+	}
+
+	admin, ok := h.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var req model.ResolveDisputeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendErrorResponse(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	deal, err := h.svc(r).ResolveDispute(admin, dealID, &req)
+	if err != nil {
+		logging.Printf(r.Context(), "[WARN] Ошибка решения спора по сделке ID=%d: %v", dealID, err)
+		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.sendJSONResponse(w, map[string]interface{}{
+		"success": true,
+		"deal":    deal,
+		"message": "Спор решен",
+	})
+}
+
 // =====================================================
 // ОБРАБОТЧИКИ ОТЗЫВОВ
 // =====================================================
 
 // handleGetReviews обрабатывает получение отзывов о пользователе
 func (h *Handler) handleGetReviews(w http.ResponseWriter, r *http.Request) {
-	log.Println("[INFO] Обработка запроса получения отзывов")
+	logging.Printf(r.Context(), "[INFO] Обработка запроса получения отзывов")
 
 	// Получаем ID пользователя из параметров запроса
 	userIDStr := r.URL.Query().Get("user_id")
@@ -675,14 +1015,14 @@ func (h *Handler) handleGetReviews(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Получаем отзывы через сервис
-	reviews, err := h.service.GetUserReviews(userID, limit, offset)
+	reviews, err := h.svc(r).GetUserReviews(userID, limit, offset)
 	if err != nil {
-		log.Printf("[ERROR] Ошибка при получении отзывов: %v", err)
+		logging.Printf(r.Context(), "[ERROR] Ошибка при получении отзывов: %v", err)
 		h.sendErrorResponse(w, "Не удалось получить отзывы", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("[INFO] Возвращено отзывов: %d", len(reviews))
+	logging.Printf(r.Context(), "[INFO] Возвращено отзывов: %d", len(reviews))
 	h.sendJSONResponse(w, map[string]interface{}{
 		"success": true,
 		"reviews": reviews,
@@ -696,52 +1036,35 @@ func (h *Handler) handleGetReviews(w http.ResponseWriter, r *http.Request) {
 
 // handleCreateReview обрабатывает создание нового отзыва
 func (h *Handler) handleCreateReview(w http.ResponseWriter, r *http.Request) {
-	log.Println("[INFO] Обработка создания отзыва")
-
-	// Получаем Telegram ID пользователя из заголовка
-	telegramIDStr := r.Header.Get("X-Telegram-User-ID")
-	if telegramIDStr == "" {
-		log.Printf("[WARN] Не передан Telegram ID пользователя")
-		h.sendErrorResponse(w, "Требуется авторизация", http.StatusUnauthorized)
-		return
-	}
+	logging.Printf(r.Context(), "[INFO] Обработка создания отзыва")
 
-	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
-	if err != nil {
-		log.Printf("[WARN] Неверный формат Telegram ID: %v", err)
-		h.sendErrorResponse(w, "Неверный ID пользователя", http.StatusBadRequest)
-		return
-	}
-
-	// Получаем пользователя по Telegram ID для получения внутреннего ID
-	user, err := h.service.GetUserByTelegramID(telegramID)
-	if err != nil {
-		log.Printf("[ERROR] Пользователь не найден: %v", err)
-		h.sendErrorResponse(w, "Пользователь не найден", http.StatusNotFound)
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 
 	// Читаем данные отзыва из тела запроса
 	var reviewData model.CreateReviewRequest
 	if err := json.NewDecoder(r.Body).Decode(&reviewData); err != nil {
-		log.Printf("[WARN] Неверный формат данных отзыва: %v", err)
+		logging.Printf(r.Context(), "[WARN] Неверный формат данных отзыва: %v", err)
 		h.sendErrorResponse(w, "Неверный формат данных отзыва", http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("[INFO] Создание отзыва: DealID=%d, ToUserID=%d, Rating=%d",
+	logging.Printf(r.Context(), "[INFO] Создание отзыва: DealID=%d, ToUserID=%d, Rating=%d",
 		reviewData.DealID, reviewData.ToUserID, reviewData.Rating)
-	log.Printf("[DEBUG] Отправитель отзыва: TelegramID=%d, InternalID=%d", telegramID, user.ID)
+	logging.Printf(r.Context(), "[DEBUG] Отправитель отзыва: TelegramID=%d, InternalID=%d", user.TelegramID, user.ID)
 
 	// Создаем отзыв через сервис
-	review, err := h.service.CreateReview(user.ID, &reviewData)
+	review, err := h.svc(r).CreateReview(user.ID, &reviewData)
 	if err != nil {
-		log.Printf("[WARN] Ошибка создания отзыва: %v", err)
+		logging.Printf(r.Context(), "[WARN] Ошибка создания отзыва: %v", err)
 		h.sendErrorResponse(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("[INFO] Отзыв создан успешно: ID=%d, Rating=%d", review.ID, review.Rating)
+	logging.Printf(r.Context(), "[INFO] Отзыв создан успешно: ID=%d, Rating=%d", review.ID, review.Rating)
 	h.sendJSONResponse(w, map[string]interface{}{
 		"success": true,
 		"review":  review,
@@ -758,17 +1081,17 @@ func (h *Handler) handleGetUserProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("[INFO] Запрос профиля пользователя ID=%d", userID)
+	logging.Printf(r.Context(), "[INFO] Запрос профиля пользователя ID=%d", userID)
 
 	// Получаем данные пользователя и статистику профиля
-	userProfile, err := h.service.GetFullUserProfile(userID)
+	userProfile, err := h.svc(r).GetFullUserProfile(userID)
 	if err != nil {
-		log.Printf("[ERROR] Ошибка получения профиля пользователя ID=%d: %v", userID, err)
+		logging.Printf(r.Context(), "[ERROR] Ошибка получения профиля пользователя ID=%d: %v", userID, err)
 		h.sendErrorResponse(w, "Не удалось получить профиль пользователя", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("[INFO] Профиль пользователя ID=%d получен успешно", userID)
+	logging.Printf(r.Context(), "[INFO] Профиль пользователя ID=%d получен успешно", userID)
 	h.sendJSONResponse(w, map[string]interface{}{
 		"success": true,
 		"profile": userProfile,
@@ -777,28 +1100,11 @@ func (h *Handler) handleGetUserProfile(w http.ResponseWriter, r *http.Request) {
 
 // handleGetMyReviews обрабатывает получение отзывов текущего пользователя
 func (h *Handler) handleGetMyReviews(w http.ResponseWriter, r *http.Request) {
-	log.Printf("[INFO] Обработка запроса получения отзывов текущего пользователя")
+	logging.Printf(r.Context(), "[INFO] Обработка запроса получения отзывов текущего пользователя")
 
-	// Получаем Telegram ID пользователя из заголовка
-	telegramIDStr := r.Header.Get("X-Telegram-User-ID")
-	if telegramIDStr == "" {
-		log.Printf("[WARN] Не передан Telegram ID пользователя для отзывов")
-		h.sendErrorResponse(w, "Требуется авторизация", http.StatusUnauthorized)
-		return
-	}
-
-	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
-	if err != nil {
-		log.Printf("[WARN] Неверный формат Telegram ID: %v", err)
-		h.sendErrorResponse(w, "Неверный ID пользователя", http.StatusBadRequest)
-		return
-	}
-
-	// Получаем пользователя по Telegram ID
-	user, err := h.service.GetUserByTelegramID(telegramID)
-	if err != nil {
-		log.Printf("[ERROR] Пользователь не найден для отзывов: %v", err)
-		h.sendErrorResponse(w, "Пользователь не найден", http.StatusNotFound)
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -818,17 +1124,17 @@ func (h *Handler) handleGetMyReviews(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	log.Printf("[INFO] Получение отзывов для пользователя ID=%d (limit=%d, offset=%d)", user.ID, limit, offset)
+	logging.Printf(r.Context(), "[INFO] Получение отзывов для пользователя ID=%d (limit=%d, offset=%d)", user.ID, limit, offset)
 
 	// Получаем отзывы пользователя
-	reviews, err := h.service.GetUserReviews(user.ID, limit, offset)
+	reviews, err := h.svc(r).GetUserReviews(user.ID, limit, offset)
 	if err != nil {
-		log.Printf("[ERROR] Ошибка получения отзывов: %v", err)
+		logging.Printf(r.Context(), "[ERROR] Ошибка получения отзывов: %v", err)
 		h.sendErrorResponse(w, "Не удалось получить отзывы", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("[INFO] Получено отзывов: %d", len(reviews))
+	logging.Printf(r.Context(), "[INFO] Получено отзывов: %d", len(reviews))
 	h.sendJSONResponse(w, map[string]interface{}{
 		"success": true,
 		"reviews": reviews,
@@ -838,42 +1144,25 @@ func (h *Handler) handleGetMyReviews(w http.ResponseWriter, r *http.Request) {
 
 // handleGetMyStats обрабатывает получение статистики текущего пользователя
 func (h *Handler) handleGetMyStats(w http.ResponseWriter, r *http.Request) {
-	log.Printf("[INFO] Обработка запроса получения статистики пользователя")
+	logging.Printf(r.Context(), "[INFO] Обработка запроса получения статистики пользователя")
 
-	// Получаем Telegram ID пользователя из заголовка
-	telegramIDStr := r.Header.Get("X-Telegram-User-ID")
-	if telegramIDStr == "" {
-		log.Printf("[WARN] Не передан Telegram ID пользователя для статистики")
-		h.sendErrorResponse(w, "Требуется авторизация", http.StatusUnauthorized)
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 
-	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
-	if err != nil {
-		log.Printf("[WARN] Неверный формат Telegram ID: %v", err)
-		h.sendErrorResponse(w, "Неверный ID пользователя", http.StatusBadRequest)
-		return
-	}
-
-	// Получаем пользователя по Telegram ID
-	user, err := h.service.GetUserByTelegramID(telegramID)
-	if err != nil {
-		log.Printf("[ERROR] Пользователь не найден: %v", err)
-		h.sendErrorResponse(w, "Пользователь не найден", http.StatusNotFound)
-		return
-	}
-
-	log.Printf("[INFO] Запрос статистики пользователя ID=%d", user.ID)
+	logging.Printf(r.Context(), "[INFO] Запрос статистики пользователя ID=%d", user.ID)
 
 	// Получаем статистику пользователя
-	stats, err := h.service.GetUserStats(user.ID)
+	stats, err := h.svc(r).GetUserStats(user.ID)
 	if err != nil {
-		log.Printf("[ERROR] Ошибка получения статистики пользователя ID=%d: %v", user.ID, err)
+		logging.Printf(r.Context(), "[ERROR] Ошибка получения статистики пользователя ID=%d: %v", user.ID, err)
 		h.sendErrorResponse(w, "Не удалось получить статистику", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("[INFO] Статистика пользователя ID=%d получена успешно", user.ID)
+	logging.Printf(r.Context(), "[INFO] Статистика пользователя ID=%d получена успешно", user.ID)
 	h.sendJSONResponse(w, map[string]interface{}{
 		"success": true,
 		"stats":   stats,
@@ -887,8 +1176,8 @@ func (h *Handler) handleGetMyStats(w http.ResponseWriter, r *http.Request) {
 // handleHealthCheck обрабатывает проверку состояния сервиса
 func (h *Handler) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
 	// Проверяем состояние сервиса и его зависимостей
-	if err := h.service.HealthCheck(); err != nil {
-		log.Printf("[ERROR] Проблемы со здоровьем сервиса: %v", err)
+	if err := h.svc(r).HealthCheck(); err != nil {
+		logging.Printf(r.Context(), "[ERROR] Проблемы со здоровьем сервиса: %v", err)
 		h.sendErrorResponse(w, "Сервис недоступен", http.StatusServiceUnavailable)
 		return
 	}
@@ -896,7 +1185,7 @@ func (h *Handler) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
 	h.sendJSONResponse(w, map[string]interface{}{
 		"status":    "ok",
 		"message":   "Сервис работает нормально",
-		"timestamp": strings.Split(log.Prefix(), " ")[0], // Простая временная метка
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
 	})
 }
 
@@ -943,44 +1232,26 @@ func (h *Handler) sendErrorResponse(w http.ResponseWriter, message string, statu
 
 // handleCreateResponse создает новый отклик на заявку
 func (h *Handler) handleCreateResponse(w http.ResponseWriter, r *http.Request) {
-	log.Printf("[INFO] %s %s - Создание отклика", r.Method, r.URL.Path)
-
-	// Получаем данные пользователя
-	// Извлекаем пользователя из заголовка Telegram
-	telegramUserIDStr := r.Header.Get("X-Telegram-User-ID")
-	if telegramUserIDStr == "" {
-		log.Printf("[ERROR] Отсутствует заголовок X-Telegram-User-ID")
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
-		return
-	}
-
-	telegramUserID, err := strconv.ParseInt(telegramUserIDStr, 10, 64)
-	if err != nil {
-		log.Printf("[ERROR] Неверный формат Telegram User ID: %s", telegramUserIDStr)
-		http.Error(w, "Неверный формат пользователя", http.StatusBadRequest)
-		return
-	}
+	logging.Printf(r.Context(), "[INFO] %s %s - Создание отклика", r.Method, r.URL.Path)
 
-	// Получаем пользователя по Telegram ID
-	user, err := h.service.GetUserByTelegramID(telegramUserID)
-	if err != nil {
-		log.Printf("[ERROR] Ошибка получения пользователя: %v", err)
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 
 	// Декодируем запрос
 	var requestData model.CreateResponseRequest
 	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
-		log.Printf("[ERROR] Некорректный JSON в запросе создания отклика: %v", err)
+		logging.Printf(r.Context(), "[ERROR] Некорректный JSON в запросе создания отклика: %v", err)
 		http.Error(w, "Некорректный формат данных", http.StatusBadRequest)
 		return
 	}
 
 	// Создаем отклик через сервис
-	response, err := h.service.CreateResponse(user.ID, &requestData)
+	response, err := h.svc(r).CreateResponse(user.ID, &requestData)
 	if err != nil {
-		log.Printf("[ERROR] Ошибка создания отклика: %v", err)
+		logging.Printf(r.Context(), "[ERROR] Ошибка создания отклика: %v", err)
 		h.sendJSONResponse(w, map[string]interface{}{
 			"success": false,
 			"message": err.Error(),
@@ -991,46 +1262,28 @@ func (h *Handler) handleCreateResponse(w http.ResponseWriter, r *http.Request) {
 	// Успешный ответ
 	h.sendJSONResponse(w, map[string]interface{}{
 		"success":  true,
-		"message":  "Отклик успешно создан",
+		"message":  createdResponseMessage(response),
 		"response": response,
 	})
 
-	log.Printf("[INFO] Отклик создан: ID=%d, UserID=%d, OrderID=%d",
+	logging.Printf(r.Context(), "[INFO] Отклик создан: ID=%d, UserID=%d, OrderID=%d",
 		response.ID, user.ID, requestData.OrderID)
 }
 
 // handleGetMyResponses получает отклики пользователя
 func (h *Handler) handleGetMyResponses(w http.ResponseWriter, r *http.Request) {
-	log.Printf("[INFO] %s %s - Получение моих откликов", r.Method, r.URL.Path)
-
-	// Получаем данные пользователя
-	// Извлекаем пользователя из заголовка Telegram
-	telegramUserIDStr := r.Header.Get("X-Telegram-User-ID")
-	if telegramUserIDStr == "" {
-		log.Printf("[ERROR] Отсутствует заголовок X-Telegram-User-ID")
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
-		return
-	}
-
-	telegramUserID, err := strconv.ParseInt(telegramUserIDStr, 10, 64)
-	if err != nil {
-		log.Printf("[ERROR] Неверный формат Telegram User ID: %s", telegramUserIDStr)
-		http.Error(w, "Неверный формат пользователя", http.StatusBadRequest)
-		return
-	}
+	logging.Printf(r.Context(), "[INFO] %s %s - Получение моих откликов", r.Method, r.URL.Path)
 
-	// Получаем пользователя по Telegram ID
-	user, err := h.service.GetUserByTelegramID(telegramUserID)
-	if err != nil {
-		log.Printf("[ERROR] Ошибка получения пользователя: %v", err)
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 
 	// Получаем отклики через сервис
-	responses, err := h.service.GetMyResponses(user.ID)
+	responses, err := h.svc(r).GetMyResponses(user.ID)
 	if err != nil {
-		log.Printf("[ERROR] Ошибка получения откликов: %v", err)
+		logging.Printf(r.Context(), "[ERROR] Ошибка получения откликов: %v", err)
 		h.sendJSONResponse(w, map[string]interface{}{
 			"success": false,
 			"message": "Не удалось загрузить отклики",
@@ -1045,41 +1298,23 @@ func (h *Handler) handleGetMyResponses(w http.ResponseWriter, r *http.Request) {
 		"count":     len(responses),
 	})
 
-	log.Printf("[INFO] Возвращено откликов пользователя ID=%d: %d", user.ID, len(responses))
+	logging.Printf(r.Context(), "[INFO] Возвращено откликов пользователя ID=%d: %d", user.ID, len(responses))
 }
 
 // handleGetResponsesToMyOrders получает отклики на заявки пользователя
 func (h *Handler) handleGetResponsesToMyOrders(w http.ResponseWriter, r *http.Request) {
-	log.Printf("[INFO] %s %s - Получение откликов на мои заявки", r.Method, r.URL.Path)
-
-	// Получаем данные пользователя
-	// Извлекаем пользователя из заголовка Telegram
-	telegramUserIDStr := r.Header.Get("X-Telegram-User-ID")
-	if telegramUserIDStr == "" {
-		log.Printf("[ERROR] Отсутствует заголовок X-Telegram-User-ID")
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
-		return
-	}
-
-	telegramUserID, err := strconv.ParseInt(telegramUserIDStr, 10, 64)
-	if err != nil {
-		log.Printf("[ERROR] Неверный формат Telegram User ID: %s", telegramUserIDStr)
-		http.Error(w, "Неверный формат пользователя", http.StatusBadRequest)
-		return
-	}
+	logging.Printf(r.Context(), "[INFO] %s %s - Получение откликов на мои заявки", r.Method, r.URL.Path)
 
-	// Получаем пользователя по Telegram ID
-	user, err := h.service.GetUserByTelegramID(telegramUserID)
-	if err != nil {
-		log.Printf("[ERROR] Ошибка получения пользователя: %v", err)
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 
 	// Получаем отклики через сервис
-	responses, err := h.service.GetResponsesToMyOrders(user.ID)
+	responses, err := h.svc(r).GetResponsesToMyOrders(user.ID)
 	if err != nil {
-		log.Printf("[ERROR] Ошибка получения откликов на заявки: %v", err)
+		logging.Printf(r.Context(), "[ERROR] Ошибка получения откликов на заявки: %v", err)
 		h.sendJSONResponse(w, map[string]interface{}{
 			"success": false,
 			"message": "Не удалось загрузить отклики",
@@ -1094,34 +1329,57 @@ func (h *Handler) handleGetResponsesToMyOrders(w http.ResponseWriter, r *http.Re
 		"count":     len(responses),
 	})
 
-	log.Printf("[INFO] Возвращено откликов на заявки автора ID=%d: %d", user.ID, len(responses))
+	logging.Printf(r.Context(), "[INFO] Возвращено откликов на заявки автора ID=%d: %d", user.ID, len(responses))
 }
 
 // handleAcceptResponse принимает отклик
 func (h *Handler) handleAcceptResponse(w http.ResponseWriter, r *http.Request) {
-	log.Printf("[INFO] %s %s - Принятие отклика", r.Method, r.URL.Path)
+	logging.Printf(r.Context(), "[INFO] %s %s - Принятие отклика", r.Method, r.URL.Path)
 
-	// Получаем данные пользователя
-	// Извлекаем пользователя из заголовка Telegram
-	telegramUserIDStr := r.Header.Get("X-Telegram-User-ID")
-	if telegramUserIDStr == "" {
-		log.Printf("[ERROR] Отсутствует заголовок X-Telegram-User-ID")
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 
-	telegramUserID, err := strconv.ParseInt(telegramUserIDStr, 10, 64)
+	// Получаем ID отклика из URL
+	vars := mux.Vars(r)
+	responseIDStr := vars["id"]
+	responseID, err := strconv.ParseInt(responseIDStr, 10, 64)
 	if err != nil {
-		log.Printf("[ERROR] Неверный формат Telegram User ID: %s", telegramUserIDStr)
-		http.Error(w, "Неверный формат пользователя", http.StatusBadRequest)
+		logging.Printf(r.Context(), "[ERROR] Неверный ID отклика: %s", responseIDStr)
+		http.Error(w, "Неверный ID отклика", http.StatusBadRequest)
 		return
 	}
 
-	// Получаем пользователя по Telegram ID
-	user, err := h.service.GetUserByTelegramID(telegramUserID)
+	// Принимаем отклик через сервис
+	deal, err := h.svc(r).AcceptResponse(responseID, user.ID)
 	if err != nil {
-		log.Printf("[ERROR] Ошибка получения пользователя: %v", err)
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
+		logging.Printf(r.Context(), "[ERROR] Ошибка принятия отклика: %v", err)
+		h.sendJSONResponse(w, map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// Успешный ответ
+	h.sendJSONResponse(w, map[string]interface{}{
+		"success": true,
+		"message": "Отклик принят, создана сделка",
+		"deal":    deal,
+	})
+
+	logging.Printf(r.Context(), "[INFO] Отклик принят: ResponseID=%d, DealID=%d", responseID, deal.ID)
+}
+
+// handleRejectResponse отклоняет отклик
+func (h *Handler) handleRejectResponse(w http.ResponseWriter, r *http.Request) {
+	logging.Printf(r.Context(), "[INFO] %s %s - Отклонение отклика", r.Method, r.URL.Path)
+
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -1130,15 +1388,14 @@ func (h *Handler) handleAcceptResponse(w http.ResponseWriter, r *http.Request) {
 	responseIDStr := vars["id"]
 	responseID, err := strconv.ParseInt(responseIDStr, 10, 64)
 	if err != nil {
-		log.Printf("[ERROR] Неверный ID отклика: %s", responseIDStr)
+		logging.Printf(r.Context(), "[ERROR] Неверный ID отклика: %s", responseIDStr)
 		http.Error(w, "Неверный ID отклика", http.StatusBadRequest)
 		return
 	}
 
-	// Принимаем отклик через сервис
-	deal, err := h.service.AcceptResponse(responseID, user.ID)
-	if err != nil {
-		log.Printf("[ERROR] Ошибка принятия отклика: %v", err)
+	// Отклоняем отклик через сервис
+	if err := h.svc(r).RejectResponse(responseID, user.ID, "Отклонен автором"); err != nil {
+		logging.Printf(r.Context(), "[ERROR] Ошибка отклонения отклика: %v", err)
 		h.sendJSONResponse(w, map[string]interface{}{
 			"success": false,
 			"message": err.Error(),
@@ -1149,38 +1406,56 @@ func (h *Handler) handleAcceptResponse(w http.ResponseWriter, r *http.Request) {
 	// Успешный ответ
 	h.sendJSONResponse(w, map[string]interface{}{
 		"success": true,
-		"message": "Отклик принят, создана сделка",
-		"deal":    deal,
+		"message": "Отклик отклонен",
 	})
 
-	log.Printf("[INFO] Отклик принят: ResponseID=%d, DealID=%d", responseID, deal.ID)
+	logging.Printf(r.Context(), "[INFO] Отклик отклонен: ResponseID=%d", responseID)
 }
 
-// handleRejectResponse отклоняет отклик
-func (h *Handler) handleRejectResponse(w http.ResponseWriter, r *http.Request) {
-	log.Printf("[INFO] %s %s - Отклонение отклика", r.Method, r.URL.Path)
+// handleWithdrawResponse отзывает отклик текущего пользователя
+func (h *Handler) handleWithdrawResponse(w http.ResponseWriter, r *http.Request) {
+	logging.Printf(r.Context(), "[INFO] %s %s - Отзыв отклика", r.Method, r.URL.Path)
 
-	// Получаем данные пользователя
-	// Извлекаем пользователя из заголовка Telegram
-	telegramUserIDStr := r.Header.Get("X-Telegram-User-ID")
-	if telegramUserIDStr == "" {
-		log.Printf("[ERROR] Отсутствует заголовок X-Telegram-User-ID")
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 
-	telegramUserID, err := strconv.ParseInt(telegramUserIDStr, 10, 64)
+	// Получаем ID отклика из URL
+	vars := mux.Vars(r)
+	responseIDStr := vars["id"]
+	responseID, err := strconv.ParseInt(responseIDStr, 10, 64)
 	if err != nil {
-		log.Printf("[ERROR] Неверный формат Telegram User ID: %s", telegramUserIDStr)
-		http.Error(w, "Неверный формат пользователя", http.StatusBadRequest)
+		logging.Printf(r.Context(), "[ERROR] Неверный ID отклика: %s", responseIDStr)
+		http.Error(w, "Неверный ID отклика", http.StatusBadRequest)
 		return
 	}
 
-	// Получаем пользователя по Telegram ID
-	user, err := h.service.GetUserByTelegramID(telegramUserID)
-	if err != nil {
-		log.Printf("[ERROR] Ошибка получения пользователя: %v", err)
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
+	if err := h.svc(r).WithdrawResponse(responseID, user.ID); err != nil {
+		logging.Printf(r.Context(), "[ERROR] Ошибка отзыва отклика: %v", err)
+		h.sendJSONResponse(w, map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	h.sendJSONResponse(w, map[string]interface{}{
+		"success": true,
+		"message": "Отклик отозван",
+	})
+
+	logging.Printf(r.Context(), "[INFO] Отклик отозван: ResponseID=%d, UserID=%d", responseID, user.ID)
+}
+
+// handleCounterResponse отвечает на отклик встречным предложением цены и/или объема
+func (h *Handler) handleCounterResponse(w http.ResponseWriter, r *http.Request) {
+	logging.Printf(r.Context(), "[INFO] %s %s - Встречное предложение по отклику", r.Method, r.URL.Path)
+
+	// Получаем авторизованного пользователя из контекста запроса
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -1189,14 +1464,22 @@ func (h *Handler) handleRejectResponse(w http.ResponseWriter, r *http.Request) {
 	responseIDStr := vars["id"]
 	responseID, err := strconv.ParseInt(responseIDStr, 10, 64)
 	if err != nil {
-		log.Printf("[ERROR] Неверный ID отклика: %s", responseIDStr)
+		logging.Printf(r.Context(), "[ERROR] Неверный ID отклика: %s", responseIDStr)
 		http.Error(w, "Неверный ID отклика", http.StatusBadRequest)
 		return
 	}
 
-	// Отклоняем отклик через сервис
-	if err := h.service.RejectResponse(responseID, user.ID, "Отклонен автором"); err != nil {
-		log.Printf("[ERROR] Ошибка отклонения отклика: %v", err)
+	// Декодируем предложение
+	var requestData model.CounterResponseRequest
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		logging.Printf(r.Context(), "[ERROR] Некорректный JSON во встречном предложении: %v", err)
+		http.Error(w, "Некорректный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.svc(r).CounterResponse(responseID, user.ID, &requestData)
+	if err != nil {
+		logging.Printf(r.Context(), "[ERROR] Ошибка встречного предложения: %v", err)
 		h.sendJSONResponse(w, map[string]interface{}{
 			"success": false,
 			"message": err.Error(),
@@ -1204,11 +1487,11 @@ func (h *Handler) handleRejectResponse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Успешный ответ
 	h.sendJSONResponse(w, map[string]interface{}{
-		"success": true,
-		"message": "Отклик отклонен",
+		"success":  true,
+		"message":  "Встречное предложение отправлено",
+		"response": response,
 	})
 
-	log.Printf("[INFO] Отклик отклонен: ResponseID=%d", responseID)
+	logging.Printf(r.Context(), "[INFO] Встречное предложение отправлено: ResponseID=%d, UserID=%d", responseID, user.ID)
 }
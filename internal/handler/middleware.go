@@ -0,0 +1,313 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"p2pTG-crypto-exchange/internal/logging"
+	"p2pTG-crypto-exchange/internal/model"
+
+	"github.com/gorilla/mux"
+)
+
+// contextKey тип ключей контекста запроса, чтобы не пересекаться с другими пакетами
+type contextKey string
+
+// userContextKey ключ, под которым в контексте хранится авторизованный пользователь
+const userContextKey contextKey = "user"
+
+// publicEndpoints маршруты API, доступные без сессионного токена
+// Ключ: "МЕТОД шаблон_пути"
+var publicEndpoints = map[string]bool{
+	"POST /api/v1/auth/login":        true,
+	"POST /api/v1/auth/demo-login":   true, // Маршрут существует только в демо-режиме
+	"GET /api/v1/health":             true,
+	"GET /api/v1/orders":             true,
+	"GET /api/v1/orders/{id}":        true,
+	"GET /api/v1/orderbook":          true,
+	"GET /api/v1/market/stats":       true,
+	"GET /api/v1/market/candles":     true,
+	"GET /api/v1/meta/assets":        true,
+	"GET /api/v1/reviews":            true,
+	"GET /api/v1/users/{id}/profile": true,
+}
+
+// requestIDHeader заголовок с ID запроса: принимается от прокси или клиента и возвращается в ответе
+const requestIDHeader = "X-Request-ID"
+
+// routeEntityFields поле логов для параметра {id} по префиксу шаблона маршрута
+var routeEntityFields = []struct {
+	prefix string
+	field  string
+}{
+	{"/api/v1/orders/", "order_id"},
+	{"/api/v1/deals/", "deal_id"},
+	{"/api/v1/admin/disputes/", "deal_id"}, // Спор открывается по сделке
+	{"/api/v1/responses/", "response_id"},
+	{"/api/v1/users/", "profile_user_id"},
+}
+
+// requestLogMiddleware кладет в контекст запроса поля логов (ID запроса, метод, путь, ID сущности из маршрута)
+// и после обработки пишет строку журнала доступа со статусом и длительностью.
+// Должен стоять перед authMiddleware, чтобы тот мог дополнить поля ID пользователя
+func (h *Handler) requestLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+
+		requestID := r.Header.Get(requestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			requestID = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		ctx := logging.WithFields(r.Context(), "request_id", requestID, "method", r.Method, "path", r.URL.Path)
+		if field, id := routeEntity(r); field != "" {
+			logging.AddFields(ctx, field, id)
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		slog.InfoContext(ctx, "Запрос обработан", "status", recorder.status, "duration_ms", time.Since(started).Milliseconds())
+	})
+}
+
+// routeEntity возвращает поле логов и значение параметра {id} совпавшего маршрута
+func routeEntity(r *http.Request) (string, string) {
+	id, ok := mux.Vars(r)["id"]
+	route := mux.CurrentRoute(r)
+	if !ok || route == nil {
+		return "", ""
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return "", ""
+	}
+	for _, entity := range routeEntityFields {
+		if strings.HasPrefix(template, entity.prefix) {
+			return entity.field, id
+		}
+	}
+	return "", ""
+}
+
+// newRequestID генерирует случайный ID запроса
+func newRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// statusRecorder запоминает код ответа для журнала доступа
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader сохраняет код ответа и передает его дальше
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// authMiddleware проверяет сессионный токен из заголовка Authorization
+// и кладет авторизованного пользователя в контекст запроса.
+// Для публичных маршрутов токен необязателен, но если он валиден - пользователь также доступен
+func (h *Handler) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		public := isPublicEndpoint(r)
+
+		token := bearerToken(r)
+		if token == "" {
+			if public {
+				next.ServeHTTP(w, r)
+				return
+			}
+			logging.Printf(r.Context(), "[WARN] %s %s - запрос без сессионного токена", r.Method, r.URL.Path)
+			h.sendErrorResponse(w, "Требуется авторизация", http.StatusUnauthorized)
+			return
+		}
+
+		user, err := h.svc(r).AuthenticateSessionToken(token)
+		if err != nil {
+			if public {
+				next.ServeHTTP(w, r)
+				return
+			}
+			logging.Printf(r.Context(), "[WARN] %s %s - недействительный сессионный токен: %v", r.Method, r.URL.Path, err)
+			h.sendErrorResponse(w, "Сессия недействительна, авторизуйтесь заново", http.StatusUnauthorized)
+			return
+		}
+
+		logging.AddFields(r.Context(), "user_id", user.ID)
+		ctx := context.WithValue(r.Context(), userContextKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ipWhitelistMiddleware отвечает 403 клиентам, чей IP не входит в белый список.
+// Проверка здоровья доступна всем, чтобы хостинг мог следить за сервером
+func (h *Handler) ipWhitelistMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.ipWhitelist == nil || routeKey(r) == "GET /api/v1/health" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		clientIP := h.clientIP(r)
+		if ip := net.ParseIP(clientIP); ip != nil {
+			for _, subnet := range h.ipWhitelist {
+				if subnet.Contains(ip) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+		}
+		logging.Printf(r.Context(), "[WARN] %s %s - IP %s не входит в белый список", r.Method, r.URL.Path, clientIP)
+		h.sendErrorResponse(w, "Доступ с вашего IP запрещен", http.StatusForbidden)
+	})
+}
+
+// rateLimitExempt маршруты API без ограничения частоты запросов
+// Ключ: "МЕТОД шаблон_пути"
+var rateLimitExempt = map[string]bool{
+	"GET /api/v1/health": true, // Проверки хостинга не должны упираться в лимит
+}
+
+// ipRateLimitMiddleware списывает каждый запрос из бюджета чтения IP клиента.
+// Работает до авторизации, чтобы перебор сессионных токенов тоже упирался в лимит.
+// При превышении отвечает 429 с заголовком Retry-After
+func (h *Handler) ipRateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.limiter == nil || rateLimitExempt[routeKey(r)] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !h.allowRequest(w, r, "ip:"+h.clientIP(r), false) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeRateLimitMiddleware списывает изменяющие запросы (POST, PUT, DELETE) из отдельного, меньшего
+// бюджета записи: авторизованных пользователей - по ID, остальных - по IP.
+// Работает после авторизации, чтобы считать по пользователю
+func (h *Handler) writeRateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		write := r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions
+		if h.limiter == nil || !write || rateLimitExempt[routeKey(r)] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := "ip:" + h.clientIP(r)
+		if user, ok := userFromContext(r.Context()); ok {
+			key = "user:" + strconv.FormatInt(user.ID, 10)
+		}
+		if !h.allowRequest(w, r, key, true) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowRequest списывает запрос из бюджета клиента key; при превышении отвечает 429 и возвращает false
+func (h *Handler) allowRequest(w http.ResponseWriter, r *http.Request, key string, write bool) bool {
+	allowed, retryAfter := h.limiter.Allow(key, write)
+	if allowed {
+		return true
+	}
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	logging.Printf(r.Context(), "[WARN] Превышен лимит запросов для %s, повтор через %d с", key, seconds)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	h.sendErrorResponse(w, "Слишком много запросов, повторите позже", http.StatusTooManyRequests)
+	return false
+}
+
+// clientIP возвращает IP клиента: адрес соединения или, за доверенным прокси,
+// последний адрес из X-Forwarded-For (его добавляет сам прокси, подделать нельзя)
+func (h *Handler) clientIP(r *http.Request) string {
+	if h.trustProxyHeaders {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			parts := strings.Split(forwarded, ",")
+			if ip := strings.TrimSpace(parts[len(parts)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// routeKey возвращает ключ совпавшего маршрута "МЕТОД шаблон_пути"
+func routeKey(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+	return r.Method + " " + template
+}
+
+// isPublicEndpoint определяет, доступен ли совпавший маршрут без авторизации
+func isPublicEndpoint(r *http.Request) bool {
+	return publicEndpoints[routeKey(r)]
+}
+
+// bearerToken извлекает токен из заголовка "Authorization: Bearer <token>"
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+// userFromContext возвращает пользователя, положенного в контекст authMiddleware
+func userFromContext(ctx context.Context) (*model.User, bool) {
+	user, ok := ctx.Value(userContextKey).(*model.User)
+	return user, ok && user != nil
+}
+
+// currentUser возвращает авторизованного пользователя запроса
+// или отправляет 401, если пользователя в контексте нет
+func (h *Handler) currentUser(w http.ResponseWriter, r *http.Request) (*model.User, bool) {
+	user, ok := userFromContext(r.Context())
+	if !ok {
+		h.sendErrorResponse(w, "Требуется авторизация", http.StatusUnauthorized)
+		return nil, false
+	}
+	return user, true
+}
+
+// requireAdmin возвращает авторизованного администратора запроса
+// или отправляет 401/403, если пользователь не авторизован или не является администратором
+func (h *Handler) requireAdmin(w http.ResponseWriter, r *http.Request) (*model.User, bool) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return nil, false
+	}
+	if !h.svc(r).IsAdmin(user) {
+		logging.Printf(r.Context(), "[WARN] Пользователь ID=%d запросил админский маршрут %s", user.ID, r.URL.Path)
+		h.sendErrorResponse(w, "Доступ запрещен", http.StatusForbidden)
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// =====================================================
+// ПОЛЯ ЗАПРОСА В КОНТЕКСТЕ
+// =====================================================
+
+// fieldsContextKey ключ, под которым в контексте хранятся поля логов запроса
+type fieldsContextKey struct{}
+
+// fields набор полей логов одного запроса.
+// Дополняется по ходу обработки (например, ID пользователя после авторизации),
+// поэтому хранится по указателю и защищен мьютексом
+type fields struct {
+	mutex sync.Mutex
+	attrs []slog.Attr
+}
+
+// WithFields создает в контексте набор полей логов запроса с начальными значениями
+// Записи slog с этим контекстом (slog.InfoContext и т.п.) получают все поля набора
+func WithFields(ctx context.Context, args ...any) context.Context {
+	set := &fields{}
+	set.add(args...)
+	return context.WithValue(ctx, fieldsContextKey{}, set)
+}
+
+// AddFields дополняет набор полей запроса из контекста (без набора ничего не делает)
+func AddFields(ctx context.Context, args ...any) {
+	if set, ok := ctx.Value(fieldsContextKey{}).(*fields); ok {
+		set.add(args...)
+	}
+}
+
+// add добавляет поля в формате slog: пары ключ-значение или slog.Attr
+func (f *fields) add(args ...any) {
+	record := slog.Record{}
+	record.Add(args...)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	record.Attrs(func(attr slog.Attr) bool {
+		f.attrs = append(f.attrs, attr)
+		return true
+	})
+}
+
+// snapshot возвращает копию полей для записи в лог
+func (f *fields) snapshot() []slog.Attr {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]slog.Attr(nil), f.attrs...)
+}
+
+// contextHandler добавляет к записям поля запроса из контекста
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle дополняет запись полями запроса и передает ее дальше
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if set, ok := ctx.Value(fieldsContextKey{}).(*fields); ok {
+		record.AddAttrs(set.snapshot()...)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs сохраняет обертку при добавлении постоянных полей
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup сохраняет обертку при группировке полей
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,178 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"p2pTG-crypto-exchange/internal/model"
+)
+
+// =====================================================
+// СТРУКТУРИРОВАННОЕ ЛОГИРОВАНИЕ
+// =====================================================
+
+// Setup настраивает логгер slog по LoggingConfig и делает его логгером по умолчанию.
+// Вызовы стандартного log с префиксами "[DEBUG]", "[INFO]", "[WARN]", "[ERROR]" направляются
+// в тот же логгер с соответствующим уровнем. Возвращает Closer для файла логов (при выводе в stdout/stderr - пустой)
+func Setup(cfg model.LoggingConfig) (io.Closer, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	var output io.Writer
+	closer := io.Closer(nopCloser{})
+	switch cfg.OutputPath {
+	case "", "stdout":
+		output = os.Stdout
+	case "stderr":
+		output = os.Stderr
+	default:
+		file, err := OpenRotatingFile(cfg.OutputPath, RotateOptions{
+			MaxSizeMB:  cfg.MaxSize,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     time.Duration(cfg.MaxAge) * 24 * time.Hour,
+			Compress:   cfg.Compress,
+		})
+		if err != nil {
+			return nil, err
+		}
+		output, closer = file, file
+	}
+
+	options := &slog.HandlerOptions{Level: level, AddSource: cfg.EnableStack}
+	var handler slog.Handler
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(output, options)
+	} else {
+		handler = slog.NewTextHandler(output, options)
+	}
+
+	logger := slog.New(contextHandler{handler})
+	slog.SetDefault(logger)
+
+	// Стандартный log пишет через мост: время и уровень добавляет slog
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(legacyWriter{logger: logger})
+
+	return closer, nil
+}
+
+// ParseLevel переводит уровень из конфигурации (debug, info, warn, error) в уровень slog
+func ParseLevel(value string) (slog.Level, error) {
+	switch strings.ToLower(value) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("неизвестный уровень логирования: %s", value)
+	}
+}
+
+// Printf записывает сообщение в формате стандартного log ("[INFO] ...") через slog с контекстом ctx:
+// уровень берется из префикса, к записи добавляются поля запроса из контекста
+func Printf(ctx context.Context, format string, args ...any) {
+	// Источник записи - код, вызвавший Printf
+	printf(ctx, 2, format, args...)
+}
+
+// PrintfDepth как Printf, но источником записи считается код на depth кадров выше вызвавшего
+// (для оберток логирования: depth 1 - вызывающий обертку)
+func PrintfDepth(ctx context.Context, depth int, format string, args ...any) {
+	printf(ctx, depth+2, format, args...)
+}
+
+// PrintlnDepth как PrintfDepth, но сообщение собирается из args как в log.Println
+func PrintlnDepth(ctx context.Context, depth int, args ...any) {
+	printMessage(ctx, depth+2, strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+// printMessage записывает готовое сообщение с источником на skip кадров выше printMessage
+func printMessage(ctx context.Context, skip int, message string) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	level, message := parseLevel(message)
+	logger := slog.Default()
+	if logger.Enabled(ctx, level) {
+		write(ctx, logger, level, message, skip+1)
+	}
+}
+
+// printf записывает сообщение с источником на skip кадров выше printf
+func printf(ctx context.Context, skip int, format string, args ...any) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	level, format := parseLevel(format)
+	logger := slog.Default()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	write(ctx, logger, level, fmt.Sprintf(format, args...), skip+1)
+}
+
+// legacyPrefixes префиксы уровней в сообщениях стандартного log
+var legacyPrefixes = []struct {
+	prefix string
+	level  slog.Level
+}{
+	{"[DEBUG]", slog.LevelDebug},
+	{"[INFO]", slog.LevelInfo},
+	{"[WARN]", slog.LevelWarn},
+	{"[ERROR]", slog.LevelError},
+}
+
+// parseLevel определяет уровень по префиксу сообщения и убирает префикс
+// Сообщения без префикса получают уровень INFO
+func parseLevel(message string) (slog.Level, string) {
+	for _, legacy := range legacyPrefixes {
+		if strings.HasPrefix(message, legacy.prefix) {
+			return legacy.level, strings.TrimSpace(strings.TrimPrefix(message, legacy.prefix))
+		}
+	}
+	return slog.LevelInfo, message
+}
+
+// write создает запись с источником на skip кадров выше write (1 - функция, вызвавшая write) и передает ее обработчику логгера
+func write(ctx context.Context, logger *slog.Logger, level slog.Level, message string, skip int) {
+	var pcs [1]uintptr
+	runtime.Callers(skip+1, pcs[:])
+	record := slog.NewRecord(time.Now(), level, message, pcs[0])
+	_ = logger.Handler().Handle(ctx, record)
+}
+
+// legacyWriter принимает строки стандартного log и записывает их в slog с уровнем из префикса
+type legacyWriter struct {
+	logger *slog.Logger
+}
+
+// Write разбирает одну строку стандартного log
+func (w legacyWriter) Write(p []byte) (int, error) {
+	level, message := parseLevel(string(bytes.TrimRight(p, "\n")))
+	if w.logger.Enabled(context.Background(), level) {
+		// Источник записи - код, вызвавший log.Printf: выше Write идут log.(*Logger).output и log.Printf
+		write(context.Background(), w.logger, level, message, 4)
+	}
+	return len(p), nil
+}
+
+// nopCloser Closer, которому нечего закрывать (вывод в stdout/stderr)
+type nopCloser struct{}
+
+// Close ничего не делает
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"debug", slog.LevelDebug, false},
+		{"INFO", slog.LevelInfo, false},
+		{"", slog.LevelInfo, false},
+		{"warn", slog.LevelWarn, false},
+		{"Error", slog.LevelError, false},
+		{"trace", slog.LevelInfo, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, %v; ожидалось %v, ошибка %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseLevelPrefix(t *testing.T) {
+	tests := []struct {
+		message     string
+		wantLevel   slog.Level
+		wantMessage string
+	}{
+		{"[DEBUG] Проверяем права", slog.LevelDebug, "Проверяем права"},
+		{"[INFO] Сервер запущен", slog.LevelInfo, "Сервер запущен"},
+		{"[WARN]   лишние пробелы", slog.LevelWarn, "лишние пробелы"},
+		{"[ERROR] Ошибка: %v", slog.LevelError, "Ошибка: %v"},
+		{"без префикса", slog.LevelInfo, "без префикса"},
+		{"Текст [ERROR] в середине", slog.LevelInfo, "Текст [ERROR] в середине"},
+	}
+	for _, tt := range tests {
+		level, message := parseLevel(tt.message)
+		if level != tt.wantLevel || message != tt.wantMessage {
+			t.Errorf("parseLevel(%q) = %v, %q; ожидалось %v, %q", tt.message, level, message, tt.wantLevel, tt.wantMessage)
+		}
+	}
+}
+
+// captureLogs подменяет логгер по умолчанию на JSON-логгер в буфер
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(contextHandler{slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})}))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func TestPrintfAddsRequestFields(t *testing.T) {
+	buf := captureLogs(t)
+
+	ctx := WithFields(context.Background(), "request_id", "req-1")
+	AddFields(ctx, "user_id", int64(42))
+	PrintfDepth(ctx, 0, "[WARN] Сделка ID=%d не найдена", 7)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("запись не в JSON: %v (%s)", err, buf.String())
+	}
+	if record["level"] != "WARN" || record["msg"] != "Сделка ID=7 не найдена" {
+		t.Errorf("уровень %v, сообщение %v", record["level"], record["msg"])
+	}
+	if record["request_id"] != "req-1" || record["user_id"] != float64(42) {
+		t.Errorf("поля запроса не добавлены: %v", record)
+	}
+}
+
+func TestPrintfWithoutContext(t *testing.T) {
+	buf := captureLogs(t)
+	// Сервис вне запроса (фоновые задачи) передает пустой контекст
+	var ctx context.Context
+	PrintfDepth(ctx, 0, "[DEBUG] фоновая задача")
+	if !bytes.Contains(buf.Bytes(), []byte(`"msg":"фоновая задача"`)) {
+		t.Errorf("запись без контекста не создана: %s", buf.String())
+	}
+}
+
+func TestPrintlnDepthParsesLevel(t *testing.T) {
+	buf := captureLogs(t)
+	PrintlnDepth(context.Background(), 0, "[WARN] JWT_SECRET не задан,", "используется случайный ключ")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("запись не в JSON: %v (%s)", err, buf.String())
+	}
+	if record["level"] != "WARN" || record["msg"] != "JWT_SECRET не задан, используется случайный ключ" {
+		t.Errorf("уровень %v, сообщение %q", record["level"], record["msg"])
+	}
+}
@@ -0,0 +1,188 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =====================================================
+// ФАЙЛ ЛОГОВ С РОТАЦИЕЙ ПО РАЗМЕРУ
+// =====================================================
+
+// RotateOptions параметры ротации файла логов (нулевые значения - без ограничения)
+type RotateOptions struct {
+	MaxSizeMB  int           // Размер файла в MB, после которого он ротируется
+	MaxBackups int           // Количество хранимых старых файлов
+	MaxAge     time.Duration // Максимальный возраст старых файлов
+	Compress   bool          // Сжимать старые файлы gzip
+}
+
+// backupTimeFormat формат времени в имени старого файла: app.log.20260102-150405.000
+const backupTimeFormat = "20060102-150405.000"
+
+// RotatingFile файл логов, который при превышении размера переименовывается
+// в path.<время> (при Compress - path.<время>.gz) и заменяется новым
+type RotatingFile struct {
+	path    string
+	options RotateOptions
+	mutex   sync.Mutex
+	file    *os.File
+	size    int64
+}
+
+// OpenRotatingFile открывает (или создает) файл логов для дозаписи
+func OpenRotatingFile(path string, options RotateOptions) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("не удалось создать папку логов: %w", err)
+	}
+
+	f := &RotatingFile{path: path, options: options}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write дописывает данные, предварительно ротируя файл, если он превысит MaxSizeMB.
+// После Close записи идут в stderr, чтобы не потерять последние сообщения при остановке
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.file == nil {
+		return os.Stderr.Write(p)
+	}
+
+	maxSize := int64(f.options.MaxSizeMB) * 1024 * 1024
+	if maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > maxSize {
+		if err := f.rotate(); err != nil {
+			// Продолжаем писать в текущий файл - потерять логи хуже, чем превысить размер
+			fmt.Fprintf(os.Stderr, "не удалось ротировать файл логов %s: %v\n", f.path, err)
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close закрывает текущий файл логов
+func (f *RotatingFile) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// open открывает файл по пути path и запоминает его размер
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть файл логов: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("не удалось получить размер файла логов: %w", err)
+	}
+
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// rotate переименовывает текущий файл, открывает новый и удаляет лишние старые файлы (вызывается под mutex)
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	backup := f.path + "." + time.Now().Format(backupTimeFormat)
+	if err := os.Rename(f.path, backup); err != nil {
+		// Файл не переименован - продолжаем писать в него же
+		if openErr := f.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	// Сжатие и очистка не должны задерживать запись логов
+	go func() {
+		if f.options.Compress {
+			if err := compressFile(backup); err != nil {
+				slog.Warn("не удалось сжать старый файл логов", "file", backup, "error", err)
+			}
+		}
+		f.removeOldBackups()
+	}()
+	return nil
+}
+
+// removeOldBackups удаляет старые файлы сверх MaxBackups и старше MaxAge
+func (f *RotatingFile) removeOldBackups() {
+	backups, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return
+	}
+	// Время в имени файла сортируется как строка: новые файлы в конце
+	sort.Strings(backups)
+
+	now := time.Now()
+	for i, backup := range backups {
+		tooMany := f.options.MaxBackups > 0 && i < len(backups)-f.options.MaxBackups
+		tooOld := false
+		if f.options.MaxAge > 0 {
+			if info, err := os.Stat(backup); err == nil && now.Sub(info.ModTime()) > f.options.MaxAge {
+				tooOld = true
+			}
+		}
+		if tooMany || tooOld {
+			os.Remove(backup)
+		}
+	}
+}
+
+// compressFile сжимает файл в path.gz и удаляет исходный
+func compressFile(path string) error {
+	if strings.HasSuffix(path, ".gz") {
+		return nil
+	}
+
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	writer := gzip.NewWriter(target)
+	if _, err := io.Copy(writer, source); err != nil {
+		target.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		target.Close()
+		return err
+	}
+	if err := target.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
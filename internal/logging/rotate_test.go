@@ -0,0 +1,168 @@
+package logging
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// backupsOf возвращает старые файлы логов path по порядку имен
+func backupsOf(t *testing.T, path string) []string {
+	t.Helper()
+	backups, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(backups)
+	return backups
+}
+
+// waitFor ждет условия, которое выполняет фоновая горутина ротации
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("не дождались: %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRotatingFileRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+	f, err := OpenRotatingFile(path, RotateOptions{MaxSizeMB: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	chunk := bytes.Repeat([]byte("x"), 600*1024)
+	for i := 0; i < 2; i++ {
+		if _, err := f.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Вторая запись не поместилась в 1 MB: первая ушла в старый файл, вторая - в новый
+	backups := backupsOf(t, path)
+	if len(backups) != 1 {
+		t.Fatalf("старых файлов %d, ожидался 1", len(backups))
+	}
+	for _, file := range []string{path, backups[0]} {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != int64(len(chunk)) {
+			t.Errorf("%s: размер %d, ожидалось %d", file, info.Size(), len(chunk))
+		}
+	}
+	if _, err := time.Parse(backupTimeFormat, strings.TrimPrefix(backups[0], path+".")); err != nil {
+		t.Errorf("неверное имя старого файла %s: %v", backups[0], err)
+	}
+}
+
+func TestRotatingFileKeepsSizeAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, bytes.Repeat([]byte("x"), 900*1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Размер существующего файла учитывается: следующая запись сверх 1 MB ротирует его
+	f, err := OpenRotatingFile(path, RotateOptions{MaxSizeMB: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(bytes.Repeat([]byte("y"), 200*1024)); err != nil {
+		t.Fatal(err)
+	}
+	if backups := backupsOf(t, path); len(backups) != 1 {
+		t.Fatalf("старых файлов %d, ожидался 1", len(backups))
+	}
+}
+
+func TestRotatingFileCompressesBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := OpenRotatingFile(path, RotateOptions{MaxSizeMB: 1, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	first := bytes.Repeat([]byte("первая запись\n"), 20*1024)
+	f.Write(first)
+	f.Write(bytes.Repeat([]byte("x"), 600*1024))
+
+	waitFor(t, "сжатие старого файла", func() bool {
+		backups := backupsOf(t, path)
+		return len(backups) == 1 && strings.HasSuffix(backups[0], ".gz")
+	})
+
+	file, err := os.Open(backupsOf(t, path)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, first) {
+		t.Errorf("в сжатом файле %d байт, ожидалось %d", len(data), len(first))
+	}
+}
+
+func TestRemoveOldBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	now := time.Now()
+
+	// Пять старых файлов с шагом 12 часов; два самых старых старше 30 часов
+	var names []string
+	for i := 4; i >= 0; i-- {
+		created := now.Add(-time.Duration(i) * 12 * time.Hour)
+		name := path + "." + created.Format(backupTimeFormat)
+		if i%2 == 0 {
+			name += ".gz"
+		}
+		if err := os.WriteFile(name, []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(name, created, created); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+
+	tests := []struct {
+		name    string
+		options RotateOptions
+		keep    []string
+	}{
+		{"без ограничений", RotateOptions{}, names},
+		{"MaxBackups", RotateOptions{MaxBackups: 4}, names[1:]},
+		{"MaxAge", RotateOptions{MaxAge: 30 * time.Hour}, names[2:]},
+		{"MaxBackups и MaxAge", RotateOptions{MaxBackups: 1, MaxAge: 30 * time.Hour}, names[4:]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			(&RotatingFile{path: path, options: tt.options}).removeOldBackups()
+			got := backupsOf(t, path)
+			if strings.Join(got, "\n") != strings.Join(tt.keep, "\n") {
+				t.Errorf("осталось %v, ожидалось %v", got, tt.keep)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// =====================================================
+// РЕЕСТР АКТИВОВ, ВАЛЮТ И СПОСОБОВ ОПЛАТЫ
+// =====================================================
+
+// AssetKind определяет раздел реестра
+type AssetKind string
+
+const (
+	AssetKindCrypto        AssetKind = "crypto"         // Криптовалюта
+	AssetKindFiat          AssetKind = "fiat"           // Фиатная валюта
+	AssetKindPaymentMethod AssetKind = "payment_method" // Способ оплаты
+)
+
+// Asset запись реестра: криптовалюта, фиатная валюта или способ оплаты.
+// Для криптовалюты лимиты заданы в ее единицах (количество заявки), для фиата - в сумме заявки;
+// точность и лимиты способа оплаты не используются
+type Asset struct {
+	Code           string    `json:"code" db:"code"`                                   // Код (BTC, RUB, sberbank)
+	Kind           AssetKind `json:"kind" db:"kind"`                                   // Раздел реестра
+	DisplayName    string    `json:"display_name" db:"display_name"`                   // Название для интерфейса
+	Decimals       int       `json:"decimals" db:"decimals"`                           // Количество знаков после запятой
+	MinOrderAmount float64   `json:"min_order_amount,omitempty" db:"min_order_amount"` // Минимальный размер заявки (0 - без ограничения)
+	MaxOrderAmount float64   `json:"max_order_amount,omitempty" db:"max_order_amount"` // Максимальный размер заявки (0 - без ограничения)
+	SortOrder      int       `json:"sort_order" db:"sort_order"`                       // Порядок в списках интерфейса
+	IsActive       bool      `json:"is_active" db:"is_active"`                         // Доступен ли для новых заявок
+}
+
+// AssetRegistry реестр активных криптовалют, фиатных валют и способов оплаты
+type AssetRegistry struct {
+	Cryptocurrencies []*Asset `json:"cryptocurrencies"` // Криптовалюты
+	FiatCurrencies   []*Asset `json:"fiat_currencies"`  // Фиатные валюты
+	PaymentMethods   []*Asset `json:"payment_methods"`  // Способы оплаты
+}
+
+// AssetAllowlist коды, разрешенные конфигурацией (пустой список - разрешены все)
+type AssetAllowlist struct {
+	Cryptocurrencies []string
+	FiatCurrencies   []string
+	PaymentMethods   []string
+}
+
+// defaultAssetDecimals точность записей, которые указаны только кодом в конфигурации
+var defaultAssetDecimals = map[AssetKind]int{
+	AssetKindCrypto: 8,
+	AssetKindFiat:   2,
+}
+
+// DefaultAssets встроенный реестр: используется, пока записи не переопределены в базе данных
+func DefaultAssets() []*Asset {
+	return []*Asset{
+		{Code: "BTC", Kind: AssetKindCrypto, DisplayName: "Bitcoin", Decimals: 8, SortOrder: 10, IsActive: true},
+		{Code: "ETH", Kind: AssetKindCrypto, DisplayName: "Ethereum", Decimals: 8, SortOrder: 20, IsActive: true},
+		{Code: "USDT", Kind: AssetKindCrypto, DisplayName: "Tether", Decimals: 6, SortOrder: 30, IsActive: true},
+		{Code: "USDC", Kind: AssetKindCrypto, DisplayName: "USD Coin", Decimals: 6, SortOrder: 40, IsActive: true},
+		{Code: "LTC", Kind: AssetKindCrypto, DisplayName: "Litecoin", Decimals: 8, SortOrder: 50, IsActive: true},
+
+		{Code: "RUB", Kind: AssetKindFiat, DisplayName: "Рубли", Decimals: 2, SortOrder: 10, IsActive: true},
+		{Code: "USD", Kind: AssetKindFiat, DisplayName: "Доллары", Decimals: 2, SortOrder: 20, IsActive: true},
+		{Code: "EUR", Kind: AssetKindFiat, DisplayName: "Евро", Decimals: 2, SortOrder: 30, IsActive: true},
+		{Code: "UAH", Kind: AssetKindFiat, DisplayName: "Гривны", Decimals: 2, SortOrder: 40, IsActive: true},
+
+		{Code: string(PaymentMethodSberbank), Kind: AssetKindPaymentMethod, DisplayName: "СБЕР", SortOrder: 10, IsActive: true},
+		{Code: string(PaymentMethodTinkoff), Kind: AssetKindPaymentMethod, DisplayName: "Тинькофф", SortOrder: 20, IsActive: true},
+		{Code: string(PaymentMethodSPB), Kind: AssetKindPaymentMethod, DisplayName: "СПБ", SortOrder: 30, IsActive: true},
+		{Code: string(PaymentMethodYandexMoney), Kind: AssetKindPaymentMethod, DisplayName: "ЮMoney", SortOrder: 40, IsActive: true},
+		{Code: string(PaymentMethodBank), Kind: AssetKindPaymentMethod, DisplayName: "По № карты", SortOrder: 50, IsActive: true},
+		{Code: string(PaymentMethodCash), Kind: AssetKindPaymentMethod, DisplayName: "Наличные", SortOrder: 60, IsActive: true},
+		{Code: string(PaymentMethodOther), Kind: AssetKindPaymentMethod, DisplayName: "Другое", SortOrder: 70, IsActive: true},
+	}
+}
+
+// Validate проверяет запись реестра перед использованием
+func (a *Asset) Validate() error {
+	if strings.TrimSpace(a.Code) == "" {
+		return fmt.Errorf("код записи реестра не указан")
+	}
+	switch a.Kind {
+	case AssetKindCrypto, AssetKindFiat, AssetKindPaymentMethod:
+	default:
+		return fmt.Errorf("неверный раздел реестра у %s: %s", a.Code, a.Kind)
+	}
+	if a.Decimals < 0 || a.Decimals > 18 {
+		return fmt.Errorf("точность %s должна быть от 0 до 18 знаков", a.Code)
+	}
+	if a.MinOrderAmount < 0 || a.MaxOrderAmount < 0 {
+		return fmt.Errorf("лимиты заявки для %s не могут быть отрицательными", a.Code)
+	}
+	if a.MaxOrderAmount > 0 && a.MaxOrderAmount < a.MinOrderAmount {
+		return fmt.Errorf("максимальный размер заявки для %s меньше минимального", a.Code)
+	}
+	return nil
+}
+
+// CheckOrderSize проверяет размер заявки по лимитам записи.
+// what описывает проверяемую величину в тексте ошибки ("количество", "сумма")
+func (a *Asset) CheckOrderSize(value float64, what string) error {
+	if a.MinOrderAmount > 0 && value < a.MinOrderAmount {
+		return fmt.Errorf("%s не может быть меньше %g %s", what, a.MinOrderAmount, a.Code)
+	}
+	if a.MaxOrderAmount > 0 && value > a.MaxOrderAmount {
+		return fmt.Errorf("%s не может быть больше %g %s", what, a.MaxOrderAmount, a.Code)
+	}
+	return nil
+}
+
+// CheckPrecision проверяет, что у значения не больше знаков после запятой, чем допускает запись
+func (a *Asset) CheckPrecision(value float64, what string) error {
+	scaled := value * math.Pow10(a.Decimals)
+	if math.Abs(scaled-math.Round(scaled)) > 1e-9*math.Max(1, math.Abs(scaled)) {
+		return fmt.Errorf("%s в %s указывается с точностью до %d знаков после запятой", what, a.Code, a.Decimals)
+	}
+	return nil
+}
+
+// NewAssetRegistry собирает реестр: встроенные записи переопределяются записями из базы данных
+// (по разделу и коду), неактивные записи исключаются, затем применяются списки из конфигурации.
+// Код из конфигурации, которого нет ни во встроенном реестре, ни в базе, добавляется с точностью по умолчанию
+func NewAssetRegistry(defaults, overrides []*Asset, allow AssetAllowlist) (*AssetRegistry, error) {
+	byKey := make(map[string]*Asset)
+	var order []string
+	for _, source := range [][]*Asset{defaults, overrides} {
+		for _, asset := range source {
+			if err := asset.Validate(); err != nil {
+				return nil, err
+			}
+			key := string(asset.Kind) + "/" + asset.Code
+			if _, exists := byKey[key]; !exists {
+				order = append(order, key)
+			}
+			byKey[key] = asset
+		}
+	}
+
+	registry := &AssetRegistry{}
+	sections := []struct {
+		kind    AssetKind
+		allowed []string
+		target  *[]*Asset
+	}{
+		{AssetKindCrypto, allow.Cryptocurrencies, &registry.Cryptocurrencies},
+		{AssetKindFiat, allow.FiatCurrencies, &registry.FiatCurrencies},
+		{AssetKindPaymentMethod, allow.PaymentMethods, &registry.PaymentMethods},
+	}
+	for _, section := range sections {
+		if len(section.allowed) > 0 {
+			// Конфигурация задает состав раздела и порядок по умолчанию
+			for i, code := range section.allowed {
+				asset, ok := byKey[string(section.kind)+"/"+code]
+				if !ok {
+					asset = &Asset{Code: code, Kind: section.kind, DisplayName: code,
+						Decimals: defaultAssetDecimals[section.kind], SortOrder: (i + 1) * 10, IsActive: true}
+				}
+				if asset.IsActive {
+					*section.target = append(*section.target, asset)
+				}
+			}
+		} else {
+			for _, key := range order {
+				if asset := byKey[key]; asset.Kind == section.kind && asset.IsActive {
+					*section.target = append(*section.target, asset)
+				}
+			}
+		}
+
+		list := *section.target
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].SortOrder < list[j].SortOrder
+		})
+		if len(list) == 0 {
+			return nil, fmt.Errorf("в реестре нет ни одной записи раздела %s", section.kind)
+		}
+	}
+
+	return registry, nil
+}
+
+// Lookup возвращает активную запись реестра по разделу и коду (nil, если записи нет)
+func (r *AssetRegistry) Lookup(kind AssetKind, code string) *Asset {
+	var list []*Asset
+	switch kind {
+	case AssetKindCrypto:
+		list = r.Cryptocurrencies
+	case AssetKindFiat:
+		list = r.FiatCurrencies
+	case AssetKindPaymentMethod:
+		list = r.PaymentMethods
+	}
+	for _, asset := range list {
+		if asset.Code == code {
+			return asset
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// AutoAcceptRules правила автоматического принятия откликов на заявку.
+// Отклик от пользователя, подходящего под все правила, сразу становится сделкой
+// без участия автора заявки. Нулевые значения правил не проверяются
+type AutoAcceptRules struct {
+	Enabled           bool     `json:"enabled"`              // Включено ли автопринятие
+	MinRating         float32  `json:"min_rating"`           // Минимальный рейтинг откликнувшегося (0-5)
+	MinCompletedDeals int      `json:"min_completed_deals"`  // Минимальное количество успешных сделок
+	MinChatMemberDays int      `json:"min_chat_member_days"` // Минимальный стаж в закрытом чате, дней
+	PaymentMethods    []string `json:"payment_methods"`      // Способы оплаты для автопринятия (пусто - любой способ заявки)
+}
+
+// Validate проверяет корректность правил относительно заявки
+func (r *AutoAcceptRules) Validate(order *Order) error {
+	if r.MinRating < 0 || r.MinRating > 5 {
+		return fmt.Errorf("минимальный рейтинг для автопринятия должен быть от 0 до 5")
+	}
+	if r.MinCompletedDeals < 0 {
+		return fmt.Errorf("минимальное количество сделок для автопринятия не может быть отрицательным")
+	}
+	if r.MinChatMemberDays < 0 {
+		return fmt.Errorf("минимальный стаж в чате для автопринятия не может быть отрицательным")
+	}
+	for _, method := range r.PaymentMethods {
+		if !order.HasPaymentMethod(method) {
+			return fmt.Errorf("способ оплаты '%s' для автопринятия не указан в заявке", method)
+		}
+	}
+	return nil
+}
+
+// Check проверяет, подходит ли откликнувшийся пользователь под правила.
+// Возвращает ошибку с причиной, по которой отклик нельзя принять автоматически
+func (r *AutoAcceptRules) Check(user *User, paymentMethod string, now time.Time) error {
+	if !r.Enabled {
+		return fmt.Errorf("автопринятие откликов выключено")
+	}
+	if !user.ChatMember {
+		return fmt.Errorf("пользователь не состоит в закрытом чате")
+	}
+	if r.MinRating > 0 && user.Rating < r.MinRating {
+		return fmt.Errorf("рейтинг %.1f ниже требуемого %.1f", user.Rating, r.MinRating)
+	}
+	if r.MinCompletedDeals > 0 && user.SuccessfulDeals < r.MinCompletedDeals {
+		return fmt.Errorf("успешных сделок %d, требуется не менее %d", user.SuccessfulDeals, r.MinCompletedDeals)
+	}
+	if r.MinChatMemberDays > 0 {
+		required := time.Duration(r.MinChatMemberDays) * 24 * time.Hour
+		if user.ChatMembershipAge(now) < required {
+			return fmt.Errorf("стаж в чате меньше %d дн.", r.MinChatMemberDays)
+		}
+	}
+	if len(r.PaymentMethods) > 0 && !containsString(r.PaymentMethods, paymentMethod) {
+		if paymentMethod == "" {
+			return fmt.Errorf("не выбран способ оплаты")
+		}
+		return fmt.Errorf("способ оплаты '%s' не подходит для автопринятия", paymentMethod)
+	}
+	return nil
+}
+
+// containsString проверяет наличие строки в списке
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,12 @@
 package model
 
 import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strconv"
 	"time"
 )
 
@@ -24,11 +30,16 @@ type Config struct {
 
 	// Настройки логирования
 	Logging LoggingConfig `json:"logging"`
+
+	// Демо-режим (флаг --demo): хранилище в памяти, вход без Telegram, настройки бота необязательны
+	Demo bool `json:"-"`
 }
 
 // DatabaseConfig содержит настройки для подключения к базе данных
 type DatabaseConfig struct {
-	Driver          string        `json:"driver" env:"DB_DRIVER"`                   // Драйвер БД (postgres, mysql, sqlite)
+	URL             string        `json:"url" env:"DATABASE_URL"`                   // Строка подключения PostgreSQL (приоритетнее Host/Port)
+	DataDir         string        `json:"data_dir" env:"DATA_DIR"`                  // Папка JSON файлов (и файла SQLite), если база данных не настроена
+	Driver          string        `json:"driver" env:"DB_DRIVER"`                   // Драйвер БД (postgres, sqlite; пусто - postgres при заданном DSN, иначе файлы)
 	Host            string        `json:"host" env:"DB_HOST"`                       // Хост базы данных
 	Port            int           `json:"port" env:"DB_PORT"`                       // Порт базы данных
 	Database        string        `json:"database" env:"DB_NAME"`                   // Имя базы данных
@@ -42,50 +53,55 @@ type DatabaseConfig struct {
 
 // TelegramConfig содержит настройки для работы с Telegram Bot API
 type TelegramConfig struct {
-	BotToken     string  `json:"bot_token" env:"TELEGRAM_BOT_TOKEN"`      // Токен Telegram бота
-	WebhookURL   string  `json:"webhook_url" env:"TELEGRAM_WEBHOOK_URL"`  // URL для webhook
-	WebAppURL    string  `json:"webapp_url" env:"TELEGRAM_WEBAPP_URL"`    // URL веб-приложения
-	ChatID       int64   `json:"chat_id" env:"TELEGRAM_CHAT_ID"`          // ID закрытого чата
-	AdminUserIDs []int64 `json:"admin_user_ids" env:"TELEGRAM_ADMIN_IDS"` // ID администраторов
-	UseWebhook   bool    `json:"use_webhook" env:"TELEGRAM_USE_WEBHOOK"`  // Использовать webhook или polling
-	TimeoutSec   int     `json:"timeout_sec" env:"TELEGRAM_TIMEOUT"`      // Таймаут для запросов к API
+	BotToken     string  `json:"bot_token" env:"TELEGRAM_BOT_TOKEN"`           // Токен Telegram бота
+	WebhookURL   string  `json:"webhook_url" env:"TELEGRAM_WEBHOOK_URL"`       // Не поддерживается (бот не принимает обновления), должен быть пустым
+	WebAppURL    string  `json:"webapp_url" env:"TELEGRAM_WEBAPP_URL"`         // URL веб-приложения
+	ChatID       int64   `json:"chat_id" env:"TELEGRAM_CHAT_ID"`               // ID закрытого чата
+	GroupChatID  int64   `json:"group_chat_id" env:"TELEGRAM_GROUP_CHAT_ID"`   // ID группового чата для публикации заявок (необязательно)
+	GroupTopicID int64   `json:"group_topic_id" env:"TELEGRAM_GROUP_TOPIC_ID"` // ID темы в групповом чате (необязательно)
+	AdminUserIDs []int64 `json:"admin_user_ids" env:"TELEGRAM_ADMIN_IDS"`      // ID администраторов
+	UseWebhook   bool    `json:"use_webhook" env:"TELEGRAM_USE_WEBHOOK"`       // Не поддерживается (бот не принимает обновления), должен быть false
+	TimeoutSec   int     `json:"timeout_sec" env:"TELEGRAM_TIMEOUT"`           // Таймаут запросов к Telegram Bot API в секундах (0 - 10 секунд)
 }
 
 // ServerConfig содержит настройки веб-сервера
 type ServerConfig struct {
-	Host           string        `json:"host" env:"SERVER_HOST"`                   // Хост сервера (0.0.0.0)
-	Port           int           `json:"port" env:"SERVER_PORT"`                   // Порт сервера (8080)
-	ReadTimeout    time.Duration `json:"read_timeout" env:"SERVER_READ_TIMEOUT"`   // Таймаут чтения
-	WriteTimeout   time.Duration `json:"write_timeout" env:"SERVER_WRITE_TIMEOUT"` // Таймаут записи
-	IdleTimeout    time.Duration `json:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`   // Таймаут простоя
-	MaxHeaderBytes int           `json:"max_header_bytes" env:"SERVER_MAX_HEADER"` // Максимальный размер заголовков
-	EnableCORS     bool          `json:"enable_cors" env:"SERVER_ENABLE_CORS"`     // Включить CORS
-	EnableTLS      bool          `json:"enable_tls" env:"SERVER_ENABLE_TLS"`       // Включить TLS/HTTPS
-	CertFile       string        `json:"cert_file" env:"SERVER_CERT_FILE"`         // Путь к сертификату
-	KeyFile        string        `json:"key_file" env:"SERVER_KEY_FILE"`           // Путь к приватному ключу
+	Host            string        `json:"host" env:"SERVER_HOST"`                         // Хост сервера (0.0.0.0)
+	Port            int           `json:"port" env:"PORT"`                                // Порт сервера (8080)
+	ReadTimeout     time.Duration `json:"read_timeout" env:"SERVER_READ_TIMEOUT"`         // Таймаут чтения
+	WriteTimeout    time.Duration `json:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`       // Таймаут записи
+	IdleTimeout     time.Duration `json:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`         // Таймаут простоя
+	ShutdownTimeout time.Duration `json:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT"` // Время на завершение запросов и фоновых задач при остановке
+	MaxHeaderBytes  int           `json:"max_header_bytes" env:"SERVER_MAX_HEADER"`       // Максимальный размер заголовков
+	EnableCORS      bool          `json:"enable_cors" env:"SERVER_ENABLE_CORS"`           // Не поддерживается (API и веб-интерфейс на одном сервере), должен быть false
+	EnableTLS       bool          `json:"enable_tls" env:"SERVER_ENABLE_TLS"`             // Включить TLS/HTTPS
+	CertFile        string        `json:"cert_file" env:"SERVER_CERT_FILE"`               // Путь к сертификату
+	KeyFile         string        `json:"key_file" env:"SERVER_KEY_FILE"`                 // Путь к приватному ключу
 }
 
 // SecurityConfig содержит настройки безопасности
 type SecurityConfig struct {
 	JWTSecret          string        `json:"jwt_secret" env:"JWT_SECRET"`                     // Секретный ключ для JWT
 	JWTExpiration      time.Duration `json:"jwt_expiration" env:"JWT_EXPIRATION"`             // Время жизни JWT токена
-	RateLimitRequests  int           `json:"rate_limit_requests" env:"RATE_LIMIT_REQUESTS"`   // Лимит запросов в минуту
+	RateLimitRequests  int           `json:"rate_limit_requests" env:"RATE_LIMIT_REQUESTS"`   // Лимит всех запросов с одного IP за период (0 - без лимита)
+	RateLimitWrites    int           `json:"rate_limit_writes" env:"RATE_LIMIT_WRITES"`       // Лимит изменяющих запросов (POST, PUT, DELETE) пользователя за период (0 - без лимита)
 	RateLimitDuration  time.Duration `json:"rate_limit_duration" env:"RATE_LIMIT_DURATION"`   // Период для лимита запросов
+	TrustProxyHeaders  bool          `json:"trust_proxy_headers" env:"TRUST_PROXY_HEADERS"`   // Брать IP клиента из X-Forwarded-For (сервер за прокси)
 	EncryptionKey      string        `json:"encryption_key" env:"ENCRYPTION_KEY"`             // Ключ для шифрования данных
 	HashSalt           string        `json:"hash_salt" env:"HASH_SALT"`                       // Соль для хеширования
 	MaxLoginAttempts   int           `json:"max_login_attempts" env:"MAX_LOGIN_ATTEMPTS"`     // Максимальное количество попыток входа
 	LoginAttemptWindow time.Duration `json:"login_attempt_window" env:"LOGIN_ATTEMPT_WINDOW"` // Окно для подсчета попыток входа
-	EnableIPWhitelist  bool          `json:"enable_ip_whitelist" env:"ENABLE_IP_WHITELIST"`   // Включить белый список IP
-	WhitelistedIPs     []string      `json:"whitelisted_ips" env:"WHITELISTED_IPS"`           // Белый список IP адресов
+	EnableIPWhitelist  bool          `json:"enable_ip_whitelist" env:"ENABLE_IP_WHITELIST"`   // Пускать к серверу только IP из WHITELISTED_IPS
+	WhitelistedIPs     []string      `json:"whitelisted_ips" env:"WHITELISTED_IPS"`           // Белый список IP адресов и подсетей (CIDR)
 }
 
 // BusinessConfig содержит бизнес-настройки P2P биржи
 type BusinessConfig struct {
 	// Настройки заявок
 	OrderExpirationHours      int      `json:"order_expiration_hours" env:"ORDER_EXPIRATION_HOURS"`         // Срок действия заявки в часах
-	MaxActiveOrdersPerUser    int      `json:"max_active_orders_per_user" env:"MAX_ACTIVE_ORDERS_USER"`     // Максимум активных заявок на пользователя
-	MinOrderAmount            float64  `json:"min_order_amount" env:"MIN_ORDER_AMOUNT"`                     // Минимальная сумма заявки
-	MaxOrderAmount            float64  `json:"max_order_amount" env:"MAX_ORDER_AMOUNT"`                     // Максимальная сумма заявки
+	MaxActiveOrdersPerUser    int      `json:"max_active_orders_per_user" env:"MAX_ACTIVE_ORDERS_USER"`     // Максимум заявок пользователя на рынке и в сделках (0 - без ограничения)
+	MinOrderAmount            float64  `json:"min_order_amount" env:"MIN_ORDER_AMOUNT"`                     // Не поддерживается (лимиты в реестре активов), должен быть 0
+	MaxOrderAmount            float64  `json:"max_order_amount" env:"MAX_ORDER_AMOUNT"`                     // Не поддерживается (лимиты в реестре активов), должен быть 0
 	SupportedCryptocurrencies []string `json:"supported_cryptocurrencies" env:"SUPPORTED_CRYPTOCURRENCIES"` // Поддерживаемые криптовалюты
 	SupportedFiatCurrencies   []string `json:"supported_fiat_currencies" env:"SUPPORTED_FIAT_CURRENCIES"`   // Поддерживаемые фиатные валюты
 	SupportedPaymentMethods   []string `json:"supported_payment_methods" env:"SUPPORTED_PAYMENT_METHODS"`   // Поддерживаемые способы оплаты
@@ -95,6 +111,12 @@ type BusinessConfig struct {
 	RequireBothConfirmations       bool `json:"require_both_confirmations" env:"REQUIRE_BOTH_CONFIRMATIONS"`       // Требовать подтверждение от обеих сторон
 	EnableAutoMatch                bool `json:"enable_auto_match" env:"ENABLE_AUTO_MATCH"`                         // Включить автоматическое сопоставление заявок
 
+	// Настройки плавающих цен (источник курсов - URL или файл, не оба сразу)
+	RateProviderURL  string        `json:"rate_provider_url" env:"RATE_PROVIDER_URL"`   // URL JSON-таблицы курсов
+	RateProviderFile string        `json:"rate_provider_file" env:"RATE_PROVIDER_FILE"` // Путь к JSON-файлу курсов
+	RateCacheTTL     time.Duration `json:"rate_cache_ttl" env:"RATE_CACHE_TTL"`         // Время жизни загруженных курсов
+	RateMaxAge       time.Duration `json:"rate_max_age" env:"RATE_MAX_AGE"`             // Предельный возраст курсов при недоступном источнике
+
 	// Настройки рейтинга
 	MinRatingForNewUsers     float32 `json:"min_rating_for_new_users" env:"MIN_RATING_NEW_USERS"`         // Минимальный рейтинг для новых пользователей
 	RequireMinRatingForDeals bool    `json:"require_min_rating_for_deals" env:"REQUIRE_MIN_RATING_DEALS"` // Требовать минимальный рейтинг для сделок
@@ -130,3 +152,131 @@ type SystemSettings struct {
 	LastUpdatedAt       time.Time `json:"last_updated_at" db:"last_updated_at"`           // Дата последнего обновления настроек
 	UpdatedByAdminID    int64     `json:"updated_by_admin_id" db:"updated_by_admin_id"`   // ID админа, обновившего настройки
 }
+
+// DSN возвращает строку подключения PostgreSQL: URL, если он задан, иначе собирает ее из Host/Port/Database.
+// Пустая строка означает, что база данных не настроена и используется файловое хранилище
+func (c DatabaseConfig) DSN() string {
+	if c.URL != "" {
+		return c.URL
+	}
+	if c.Host == "" {
+		return ""
+	}
+
+	host := c.Host
+	if c.Port > 0 {
+		host = net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	}
+	dsn := url.URL{Scheme: "postgres", Host: host, Path: "/" + c.Database}
+	if c.Username != "" {
+		dsn.User = url.UserPassword(c.Username, c.Password)
+	}
+	if c.SSLMode != "" {
+		dsn.RawQuery = url.Values{"sslmode": {c.SSLMode}}.Encode()
+	}
+	return dsn.String()
+}
+
+// SQLitePath возвращает путь к файлу базы SQLite: URL, если он задан, иначе exchange.db в папке данных
+func (c DatabaseConfig) SQLitePath() string {
+	if c.URL != "" {
+		return c.URL
+	}
+	return filepath.Join(c.DataDir, "exchange.db")
+}
+
+// Validate проверяет конфигурацию целиком, включая правила между полями.
+// Возвращает все найденные ошибки сразу, чтобы их можно было исправить за один раз
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	// Telegram (в демо-режиме бот не используется)
+	check(c.Demo || c.Telegram.BotToken != "", "TELEGRAM_BOT_TOKEN (telegram.bot_token) обязателен")
+	check(c.Demo || c.Telegram.ChatID != 0, "TELEGRAM_CHAT_ID (telegram.chat_id) обязателен")
+	check(c.Telegram.GroupTopicID == 0 || c.Telegram.GroupChatID != 0,
+		"TELEGRAM_GROUP_TOPIC_ID задан без TELEGRAM_GROUP_CHAT_ID")
+	check(!c.Telegram.UseWebhook && c.Telegram.WebhookURL == "",
+		"TELEGRAM_USE_WEBHOOK и TELEGRAM_WEBHOOK_URL не поддерживаются: бот только отправляет уведомления и не принимает обновления")
+	check(c.Telegram.TimeoutSec >= 0, "TELEGRAM_TIMEOUT не может быть отрицательным")
+
+	// Веб-сервер
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "PORT должен быть от 1 до 65535, получено %d", c.Server.Port)
+	check(c.Server.ReadTimeout >= 0 && c.Server.WriteTimeout >= 0 && c.Server.IdleTimeout >= 0,
+		"таймауты сервера (SERVER_READ_TIMEOUT, SERVER_WRITE_TIMEOUT, SERVER_IDLE_TIMEOUT) не могут быть отрицательными")
+	check(c.Server.ShutdownTimeout > 0, "SERVER_SHUTDOWN_TIMEOUT должен быть больше нуля")
+	check(c.Server.MaxHeaderBytes >= 0, "SERVER_MAX_HEADER не может быть отрицательным")
+	check(!c.Server.EnableTLS || (c.Server.CertFile != "" && c.Server.KeyFile != ""),
+		"SERVER_ENABLE_TLS требует SERVER_CERT_FILE и SERVER_KEY_FILE")
+	check(!c.Server.EnableCORS,
+		"SERVER_ENABLE_CORS не поддерживается: веб-интерфейс обслуживается тем же сервером, что и API")
+
+	// База данных
+	check(c.Database.Driver == "" || c.Database.Driver == "postgres" || c.Database.Driver == "sqlite",
+		"DB_DRIVER: поддерживаются postgres и sqlite, получено %q", c.Database.Driver)
+	check(c.Database.Driver != "postgres" || c.Database.DSN() != "",
+		"DB_DRIVER=postgres требует DATABASE_URL или DB_HOST")
+	check(c.Database.URL != "" || c.Database.Host == "" || c.Database.Database != "",
+		"DB_HOST требует DB_NAME")
+	check(c.Database.DSN() != "" || c.Database.DataDir != "",
+		"не задана ни база данных (DATABASE_URL или DB_HOST), ни папка данных (DATA_DIR)")
+	check(c.Database.MaxOpenConns >= 0 && c.Database.MaxIdleConns >= 0,
+		"DB_MAX_OPEN и DB_MAX_IDLE не могут быть отрицательными")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"DB_MAX_IDLE (%d) не может быть больше DB_MAX_OPEN (%d)", c.Database.MaxIdleConns, c.Database.MaxOpenConns)
+	check(c.Database.ConnMaxLifetime >= 0, "DB_CONN_LIFETIME не может быть отрицательным")
+
+	// Безопасность
+	check(c.Security.JWTSecret == "" || len(c.Security.JWTSecret) >= 16,
+		"JWT_SECRET должен быть не короче 16 символов")
+	check(c.Security.JWTExpiration >= 0, "JWT_EXPIRATION не может быть отрицательным")
+	check(c.Security.RateLimitRequests >= 0, "RATE_LIMIT_REQUESTS не может быть отрицательным")
+	check(c.Security.RateLimitWrites >= 0, "RATE_LIMIT_WRITES не может быть отрицательным")
+	check((c.Security.RateLimitRequests == 0 && c.Security.RateLimitWrites == 0) || c.Security.RateLimitDuration > 0,
+		"RATE_LIMIT_REQUESTS и RATE_LIMIT_WRITES требуют положительный RATE_LIMIT_DURATION")
+	check(!c.Security.EnableIPWhitelist || len(c.Security.WhitelistedIPs) > 0,
+		"ENABLE_IP_WHITELIST требует WHITELISTED_IPS")
+	for _, ip := range c.Security.WhitelistedIPs {
+		_, _, cidrErr := net.ParseCIDR(ip)
+		check(net.ParseIP(ip) != nil || cidrErr == nil, "WHITELISTED_IPS: неверный IP адрес или подсеть %q", ip)
+	}
+
+	// Бизнес-настройки
+	b := c.Business
+	check(b.OrderExpirationHours >= 0, "ORDER_EXPIRATION_HOURS не может быть отрицательным")
+	check(b.MaxActiveOrdersPerUser >= 0, "MAX_ACTIVE_ORDERS_USER не может быть отрицательным")
+	check(b.MinOrderAmount == 0 && b.MaxOrderAmount == 0,
+		"MIN_ORDER_AMOUNT и MAX_ORDER_AMOUNT не поддерживаются: лимиты объема заявки задаются для каждой криптовалюты в реестре активов")
+	check(b.DealConfirmationTimeoutMinutes >= 0, "DEAL_CONFIRMATION_TIMEOUT не может быть отрицательным")
+	check(b.RateProviderURL == "" || b.RateProviderFile == "",
+		"RATE_PROVIDER_URL и RATE_PROVIDER_FILE заданы одновременно, укажите один источник курсов")
+	if b.RateProviderURL != "" {
+		parsed, err := url.Parse(b.RateProviderURL)
+		check(err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != "",
+			"RATE_PROVIDER_URL должен быть http(s) адресом, получено %q", b.RateProviderURL)
+	}
+	check(b.RateCacheTTL >= 0, "RATE_CACHE_TTL не может быть отрицательным")
+	check(b.RateMaxAge >= 0, "RATE_MAX_AGE не может быть отрицательным")
+	check(b.RateMaxAge == 0 || b.RateMaxAge >= b.RateCacheTTL,
+		"RATE_MAX_AGE (%s) не может быть меньше RATE_CACHE_TTL (%s)", b.RateMaxAge, b.RateCacheTTL)
+	check(b.MinRatingForNewUsers >= 0 && b.MinRatingForNewUsers <= 5, "MIN_RATING_NEW_USERS должен быть от 0 до 5")
+	check(b.CommissionPercent >= 0 && b.CommissionPercent < 100, "COMMISSION_PERCENT должен быть от 0 до 100")
+	check(!b.EnableCommission || b.CommissionPercent > 0, "ENABLE_COMMISSION требует положительный COMMISSION_PERCENT")
+	check(b.MinCommissionAmount >= 0, "MIN_COMMISSION_AMOUNT не может быть отрицательным")
+
+	// Логирование
+	check(c.Logging.Level == "" || c.Logging.Level == "debug" || c.Logging.Level == "info" ||
+		c.Logging.Level == "warn" || c.Logging.Level == "error",
+		"LOG_LEVEL должен быть debug, info, warn или error, получено %q", c.Logging.Level)
+	check(c.Logging.Format == "" || c.Logging.Format == "text" || c.Logging.Format == "json",
+		"LOG_FORMAT должен быть text или json, получено %q", c.Logging.Format)
+	check(c.Logging.MaxSize >= 0, "LOG_MAX_SIZE не может быть отрицательным")
+	check(c.Logging.MaxBackups >= 0, "LOG_MAX_BACKUPS не может быть отрицательным")
+	check(c.Logging.MaxAge >= 0, "LOG_MAX_AGE не может быть отрицательным")
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig минимальная конфигурация, проходящая проверку
+func validConfig() Config {
+	return Config{
+		Telegram: TelegramConfig{BotToken: "123:abc", ChatID: -100123},
+		Server:   ServerConfig{Port: 8080, ShutdownTimeout: 25 * time.Second},
+		Database: DatabaseConfig{DataDir: "data"},
+	}
+}
+
+func TestValidateRequiresTelegramOutsideDemo(t *testing.T) {
+	cfg := validConfig()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("минимальная конфигурация не прошла проверку: %v", err)
+	}
+
+	cfg.Telegram = TelegramConfig{}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "TELEGRAM_BOT_TOKEN") || !strings.Contains(err.Error(), "TELEGRAM_CHAT_ID") {
+		t.Fatalf("без настроек Telegram ожидались ошибки TELEGRAM_BOT_TOKEN и TELEGRAM_CHAT_ID, получено %v", err)
+	}
+
+	// В демо-режиме бот не используется
+	cfg.Demo = true
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("демо-режим без настроек Telegram: %v", err)
+	}
+}
+
+func TestValidateRejectsUnsupportedSettings(t *testing.T) {
+	cases := []struct {
+		name   string
+		mutate func(*Config)
+		want   string
+	}{
+		{"webhook", func(c *Config) { c.Telegram.UseWebhook = true }, "TELEGRAM_USE_WEBHOOK"},
+		{"webhook url", func(c *Config) { c.Telegram.WebhookURL = "https://example.com/hook" }, "TELEGRAM_WEBHOOK_URL"},
+		{"cors", func(c *Config) { c.Server.EnableCORS = true }, "SERVER_ENABLE_CORS"},
+		{"min order amount", func(c *Config) { c.Business.MinOrderAmount = 1000 }, "MIN_ORDER_AMOUNT"},
+		{"max order amount", func(c *Config) { c.Business.MaxOrderAmount = 1000000 }, "MAX_ORDER_AMOUNT"},
+		{"whitelist without ips", func(c *Config) { c.Security.EnableIPWhitelist = true }, "WHITELISTED_IPS"},
+		{"bad whitelist entry", func(c *Config) { c.Security.WhitelistedIPs = []string{"10.0.0.0/33"} }, "10.0.0.0/33"},
+	}
+	for _, tc := range cases {
+		cfg := validConfig()
+		tc.mutate(&cfg)
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: ожидалась ошибка %s, получено %v", tc.name, tc.want, err)
+		}
+	}
+
+	cfg := validConfig()
+	cfg.Security.EnableIPWhitelist = true
+	cfg.Security.WhitelistedIPs = []string{"192.168.1.10", "10.0.0.0/8"}
+	cfg.Business.MaxActiveOrdersPerUser = 5
+	cfg.Telegram.TimeoutSec = 20
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("поддерживаемые настройки не прошли проверку: %v", err)
+	}
+}
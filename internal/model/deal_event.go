@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+)
+
+// DealEventType определяет тип события в хронологии сделки
+type DealEventType string
+
+const (
+	DealEventDisputeOpened   DealEventType = "dispute_opened"   // Участник открыл спор
+	DealEventDisputeResolved DealEventType = "dispute_resolved" // Администратор решил спор
+	DealEventCancelRequested DealEventType = "cancel_requested" // Участник запросил отмену сделки
+	DealEventCancelled       DealEventType = "cancelled"        // Сделка отменена
+)
+
+// DealEvent представляет запись в хронологии сделки
+// Хронология показывается участникам и используется администраторами при разборе споров
+type DealEvent struct {
+	ID        int64         `json:"id" db:"id"`                 // Уникальный идентификатор записи
+	DealID    int64         `json:"deal_id" db:"deal_id"`       // ID сделки
+	UserID    int64         `json:"user_id" db:"user_id"`       // ID пользователя, совершившего действие (0 - система)
+	Type      DealEventType `json:"type" db:"type"`             // Тип события
+	Status    DealStatus    `json:"status" db:"status"`         // Статус сделки после события
+	Message   string        `json:"message" db:"message"`       // Описание события
+	CreatedAt time.Time     `json:"created_at" db:"created_at"` // Время события
+}
@@ -0,0 +1,235 @@
+package model
+
+import (
+	"fmt"
+)
+
+// =====================================================
+// КОНЕЧНЫЙ АВТОМАТ СТАТУСОВ СДЕЛКИ
+// =====================================================
+
+// DealAction определяет действие, переводящее сделку из одного статуса в другой
+type DealAction string
+
+const (
+	DealActionConfirm         DealAction = "confirm"          // Первая сторона подтвердила перевод
+	DealActionComplete        DealAction = "complete"         // Вторая сторона подтвердила перевод - сделка завершена
+	DealActionExpire          DealAction = "expire"           // Срок подтверждения истек без единого подтверждения
+	DealActionOpenDispute     DealAction = "open_dispute"     // Участник открыл спор
+	DealActionResolveComplete DealAction = "resolve_complete" // Администратор решил спор в пользу покупателя криптовалюты
+	DealActionResolveCancel   DealAction = "resolve_cancel"   // Администратор решил спор в пользу продавца криптовалюты
+	DealActionRequestCancel   DealAction = "request_cancel"   // Участник запросил отмену (статус не меняется)
+	DealActionCancel          DealAction = "cancel"           // Сделка отменена участником или по взаимному согласию
+)
+
+// DealActor определяет, кто может выполнить действие над сделкой
+type DealActor string
+
+const (
+	DealActorParticipant DealActor = "participant" // Автор заявки или контрагент
+	DealActorAdmin       DealActor = "admin"       // Администратор биржи
+	DealActorSystem      DealActor = "system"      // Фоновые задачи сервиса
+)
+
+// DealEffect определяет побочный эффект, который выполняется после перехода сделки
+type DealEffect string
+
+const (
+	DealEffectOrderCompleted DealEffect = "order_completed" // Заявка завершается, если исполнен весь ее объем
+	DealEffectOrderToMarket  DealEffect = "order_to_market" // Объем сделки возвращается в заявку на биржу
+	DealEffectUserStats      DealEffect = "user_stats"      // Обновляется статистика сделок участников
+)
+
+// DealTransition описывает допустимый переход сделки между статусами
+type DealTransition struct {
+	From         DealStatus       // Исходный статус сделки
+	Action       DealAction       // Действие, выполняющее переход
+	To           DealStatus       // Статус сделки после перехода
+	Actors       []DealActor      // Кто может выполнить переход
+	Effects      []DealEffect     // Побочные эффекты перехода
+	Notification NotificationType // Уведомление участникам (пустое - без уведомления)
+}
+
+// dealTransitions таблица всех допустимых переходов сделки.
+// Репозитории меняют статус сделки только по этой таблице, сервис выполняет указанные побочные эффекты
+var dealTransitions = []DealTransition{
+	// Подтверждения участников
+	{
+		From: DealStatusInProgress, Action: DealActionConfirm, To: DealStatusWaitingConfirmation,
+		Actors:       []DealActor{DealActorParticipant},
+		Notification: NotificationTypeDealConfirmed,
+	},
+	{
+		// Старые сделки могли остаться в статусе in_progress с одним подтверждением
+		From: DealStatusInProgress, Action: DealActionComplete, To: DealStatusCompleted,
+		Actors:       []DealActor{DealActorParticipant},
+		Effects:      []DealEffect{DealEffectOrderCompleted, DealEffectUserStats},
+		Notification: NotificationTypeDealCompleted,
+	},
+	{
+		From: DealStatusWaitingConfirmation, Action: DealActionComplete, To: DealStatusCompleted,
+		Actors:       []DealActor{DealActorParticipant},
+		Effects:      []DealEffect{DealEffectOrderCompleted, DealEffectUserStats},
+		Notification: NotificationTypeDealCompleted,
+	},
+
+	// Истечение срока подтверждения (только сделки без подтверждений)
+	{
+		From: DealStatusInProgress, Action: DealActionExpire, To: DealStatusExpired,
+		Actors:       []DealActor{DealActorSystem},
+		Effects:      []DealEffect{DealEffectOrderToMarket, DealEffectUserStats},
+		Notification: NotificationTypeDealExpired,
+	},
+
+	// Споры
+	{
+		From: DealStatusInProgress, Action: DealActionOpenDispute, To: DealStatusDispute,
+		Actors:       []DealActor{DealActorParticipant},
+		Notification: NotificationTypeDisputeOpened,
+	},
+	{
+		From: DealStatusWaitingConfirmation, Action: DealActionOpenDispute, To: DealStatusDispute,
+		Actors:       []DealActor{DealActorParticipant},
+		Notification: NotificationTypeDisputeOpened,
+	},
+	{
+		From: DealStatusDispute, Action: DealActionResolveComplete, To: DealStatusCompleted,
+		Actors:       []DealActor{DealActorAdmin},
+		Effects:      []DealEffect{DealEffectOrderCompleted, DealEffectUserStats},
+		Notification: NotificationTypeDisputeResolved,
+	},
+	{
+		From: DealStatusDispute, Action: DealActionResolveCancel, To: DealStatusCancelled,
+		Actors:       []DealActor{DealActorAdmin},
+		Effects:      []DealEffect{DealEffectOrderToMarket, DealEffectUserStats},
+		Notification: NotificationTypeDisputeResolved,
+	},
+
+	// Отмена
+	{
+		From: DealStatusInProgress, Action: DealActionRequestCancel, To: DealStatusInProgress,
+		Actors:       []DealActor{DealActorParticipant},
+		Notification: NotificationTypeDealCancelRequested,
+	},
+	{
+		From: DealStatusWaitingConfirmation, Action: DealActionRequestCancel, To: DealStatusWaitingConfirmation,
+		Actors:       []DealActor{DealActorParticipant},
+		Notification: NotificationTypeDealCancelRequested,
+	},
+	{
+		From: DealStatusInProgress, Action: DealActionCancel, To: DealStatusCancelled,
+		Actors:       []DealActor{DealActorParticipant},
+		Effects:      []DealEffect{DealEffectOrderToMarket, DealEffectUserStats},
+		Notification: NotificationTypeDealCancelled,
+	},
+	{
+		From: DealStatusWaitingConfirmation, Action: DealActionCancel, To: DealStatusCancelled,
+		Actors:       []DealActor{DealActorParticipant},
+		Effects:      []DealEffect{DealEffectOrderToMarket, DealEffectUserStats},
+		Notification: NotificationTypeDealCancelled,
+	},
+}
+
+// dealActionTitles описания действий для сообщений об ошибках ("сделку ... нельзя <действие>")
+var dealActionTitles = map[DealAction]string{
+	DealActionConfirm:         "подтвердить",
+	DealActionComplete:        "завершить",
+	DealActionExpire:          "закрыть по сроку",
+	DealActionOpenDispute:     "перевести в спор",
+	DealActionResolveComplete: "завершить решением спора",
+	DealActionResolveCancel:   "отменить решением спора",
+	DealActionRequestCancel:   "отменить",
+	DealActionCancel:          "отменить",
+}
+
+// DealTransitions возвращает копию таблицы переходов сделки
+func DealTransitions() []DealTransition {
+	transitions := make([]DealTransition, len(dealTransitions))
+	copy(transitions, dealTransitions)
+	return transitions
+}
+
+// FindDealTransition находит переход для действия над сделкой в указанном статусе
+// Возвращает ошибку, если действие в этом статусе не разрешено
+func FindDealTransition(from DealStatus, action DealAction) (DealTransition, error) {
+	for _, transition := range dealTransitions {
+		if transition.From == from && transition.Action == action {
+			return transition, nil
+		}
+	}
+
+	title, ok := dealActionTitles[action]
+	if !ok {
+		return DealTransition{}, fmt.Errorf("неизвестное действие над сделкой: %s", action)
+	}
+	return DealTransition{}, fmt.Errorf("сделку в статусе '%s' нельзя %s", from, title)
+}
+
+// AuthorizeDealTransition находит переход и проверяет, что actor может его выполнить
+func AuthorizeDealTransition(from DealStatus, action DealAction, actor DealActor) (DealTransition, error) {
+	transition, err := FindDealTransition(from, action)
+	if err != nil {
+		return DealTransition{}, err
+	}
+	if !transition.AllowedFor(actor) {
+		return DealTransition{}, fmt.Errorf("действие '%s' над сделкой недоступно для роли '%s'", action, actor)
+	}
+	return transition, nil
+}
+
+// CanTransitionDeal проверяет, есть ли в таблице переход из статуса from в статус to
+func CanTransitionDeal(from, to DealStatus) bool {
+	for _, transition := range dealTransitions {
+		if transition.From == from && transition.To == to {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedFor проверяет, может ли actor выполнить переход
+func (t DealTransition) AllowedFor(actor DealActor) bool {
+	for _, allowed := range t.Actors {
+		if allowed == actor {
+			return true
+		}
+	}
+	return false
+}
+
+// HasEffect проверяет, входит ли побочный эффект в переход
+func (t DealTransition) HasEffect(effect DealEffect) bool {
+	for _, e := range t.Effects {
+		if e == effect {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal проверяет, является ли статус сделки конечным (из него нет переходов)
+func (s DealStatus) IsFinal() bool {
+	for _, transition := range dealTransitions {
+		if transition.From == s {
+			return false
+		}
+	}
+	return true
+}
+
+// ConfirmAction возвращает действие для подтверждения сделки стороной:
+// если вторая сторона уже подтвердила - сделка завершается, иначе ожидает второго подтверждения
+func (d *Deal) ConfirmAction(isAuthor bool) DealAction {
+	if (isAuthor && d.CounterConfirmed) || (!isAuthor && d.AuthorConfirmed) {
+		return DealActionComplete
+	}
+	return DealActionConfirm
+}
+
+// Action возвращает действие над сделкой, соответствующее итогу спора
+func (r *DisputeResolution) Action() DealAction {
+	if r.Status == DealStatusCompleted {
+		return DealActionResolveComplete
+	}
+	return DealActionResolveCancel
+}
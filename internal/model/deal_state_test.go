@@ -0,0 +1,226 @@
+package model
+
+import (
+	"testing"
+)
+
+// allDealStatuses все статусы сделки
+var allDealStatuses = []DealStatus{
+	DealStatusInProgress,
+	DealStatusWaitingConfirmation,
+	DealStatusCompleted,
+	DealStatusExpired,
+	DealStatusDispute,
+	DealStatusCancelled,
+}
+
+// allDealActions все действия над сделкой
+var allDealActions = []DealAction{
+	DealActionConfirm,
+	DealActionComplete,
+	DealActionExpire,
+	DealActionOpenDispute,
+	DealActionResolveComplete,
+	DealActionResolveCancel,
+	DealActionRequestCancel,
+	DealActionCancel,
+}
+
+// allDealActors все роли, выполняющие действия над сделкой
+var allDealActors = []DealActor{DealActorParticipant, DealActorAdmin, DealActorSystem}
+
+// dealEdge ожидаемый переход сделки
+type dealEdge struct {
+	to           DealStatus
+	actor        DealActor
+	effects      []DealEffect
+	notification NotificationType
+}
+
+// expectedDealEdges все допустимые переходы; остальные пары (статус, действие) должны отклоняться
+var expectedDealEdges = map[DealStatus]map[DealAction]dealEdge{
+	DealStatusInProgress: {
+		DealActionConfirm:       {DealStatusWaitingConfirmation, DealActorParticipant, nil, NotificationTypeDealConfirmed},
+		DealActionComplete:      {DealStatusCompleted, DealActorParticipant, []DealEffect{DealEffectOrderCompleted, DealEffectUserStats}, NotificationTypeDealCompleted},
+		DealActionExpire:        {DealStatusExpired, DealActorSystem, []DealEffect{DealEffectOrderToMarket, DealEffectUserStats}, NotificationTypeDealExpired},
+		DealActionOpenDispute:   {DealStatusDispute, DealActorParticipant, nil, NotificationTypeDisputeOpened},
+		DealActionRequestCancel: {DealStatusInProgress, DealActorParticipant, nil, NotificationTypeDealCancelRequested},
+		DealActionCancel:        {DealStatusCancelled, DealActorParticipant, []DealEffect{DealEffectOrderToMarket, DealEffectUserStats}, NotificationTypeDealCancelled},
+	},
+	DealStatusWaitingConfirmation: {
+		DealActionComplete:      {DealStatusCompleted, DealActorParticipant, []DealEffect{DealEffectOrderCompleted, DealEffectUserStats}, NotificationTypeDealCompleted},
+		DealActionOpenDispute:   {DealStatusDispute, DealActorParticipant, nil, NotificationTypeDisputeOpened},
+		DealActionRequestCancel: {DealStatusWaitingConfirmation, DealActorParticipant, nil, NotificationTypeDealCancelRequested},
+		DealActionCancel:        {DealStatusCancelled, DealActorParticipant, []DealEffect{DealEffectOrderToMarket, DealEffectUserStats}, NotificationTypeDealCancelled},
+	},
+	DealStatusDispute: {
+		DealActionResolveComplete: {DealStatusCompleted, DealActorAdmin, []DealEffect{DealEffectOrderCompleted, DealEffectUserStats}, NotificationTypeDisputeResolved},
+		DealActionResolveCancel:   {DealStatusCancelled, DealActorAdmin, []DealEffect{DealEffectOrderToMarket, DealEffectUserStats}, NotificationTypeDisputeResolved},
+	},
+}
+
+func TestFindDealTransition(t *testing.T) {
+	for _, from := range allDealStatuses {
+		for _, action := range allDealActions {
+			from, action := from, action
+			t.Run(string(from)+"/"+string(action), func(t *testing.T) {
+				edge, allowed := expectedDealEdges[from][action]
+
+				transition, err := FindDealTransition(from, action)
+				if !allowed {
+					if err == nil {
+						t.Fatalf("переход %s --%s--> %s не должен быть разрешен", from, action, transition.To)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("ожидался переход, получена ошибка: %v", err)
+				}
+
+				if transition.From != from || transition.Action != action {
+					t.Errorf("найден чужой переход: %+v", transition)
+				}
+				if transition.To != edge.to {
+					t.Errorf("To = %s, ожидалось %s", transition.To, edge.to)
+				}
+				if transition.Notification != edge.notification {
+					t.Errorf("Notification = %s, ожидалось %s", transition.Notification, edge.notification)
+				}
+				if len(transition.Effects) != len(edge.effects) {
+					t.Fatalf("Effects = %v, ожидалось %v", transition.Effects, edge.effects)
+				}
+				for _, effect := range edge.effects {
+					if !transition.HasEffect(effect) {
+						t.Errorf("нет эффекта %s в %v", effect, transition.Effects)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestAuthorizeDealTransition(t *testing.T) {
+	for from, actions := range expectedDealEdges {
+		for action, edge := range actions {
+			for _, actor := range allDealActors {
+				from, action, edge, actor := from, action, edge, actor
+				t.Run(string(from)+"/"+string(action)+"/"+string(actor), func(t *testing.T) {
+					_, err := AuthorizeDealTransition(from, action, actor)
+					if actor == edge.actor && err != nil {
+						t.Errorf("роль %s должна выполнять переход: %v", actor, err)
+					}
+					if actor != edge.actor && err == nil {
+						t.Errorf("роль %s не должна выполнять переход", actor)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestDealTransitionTableIsComplete(t *testing.T) {
+	transitions := DealTransitions()
+
+	count := 0
+	for _, actions := range expectedDealEdges {
+		count += len(actions)
+	}
+	if len(transitions) != count {
+		t.Fatalf("в таблице %d переходов, ожидалось %d", len(transitions), count)
+	}
+
+	seen := make(map[string]bool)
+	for _, transition := range transitions {
+		key := string(transition.From) + "/" + string(transition.Action)
+		if seen[key] {
+			t.Errorf("переход %s объявлен дважды", key)
+		}
+		seen[key] = true
+
+		if _, ok := dealActionTitles[transition.Action]; !ok {
+			t.Errorf("нет описания действия %s для сообщений об ошибках", transition.Action)
+		}
+		if len(transition.Actors) == 0 {
+			t.Errorf("переход %s никто не может выполнить", key)
+		}
+	}
+}
+
+func TestDealStatusIsFinal(t *testing.T) {
+	tests := []struct {
+		status DealStatus
+		final  bool
+	}{
+		{DealStatusInProgress, false},
+		{DealStatusWaitingConfirmation, false},
+		{DealStatusDispute, false},
+		{DealStatusCompleted, true},
+		{DealStatusExpired, true},
+		{DealStatusCancelled, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.status.IsFinal(); got != tt.final {
+			t.Errorf("%s.IsFinal() = %v, ожидалось %v", tt.status, got, tt.final)
+		}
+	}
+}
+
+func TestCanTransitionDeal(t *testing.T) {
+	for _, from := range allDealStatuses {
+		for _, to := range allDealStatuses {
+			want := false
+			for _, edge := range expectedDealEdges[from] {
+				if edge.to == to {
+					want = true
+				}
+			}
+			if got := CanTransitionDeal(from, to); got != want {
+				t.Errorf("CanTransitionDeal(%s, %s) = %v, ожидалось %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestDealConfirmAction(t *testing.T) {
+	tests := []struct {
+		name             string
+		authorConfirmed  bool
+		counterConfirmed bool
+		isAuthor         bool
+		want             DealAction
+	}{
+		{"автор первым", false, false, true, DealActionConfirm},
+		{"контрагент первым", false, false, false, DealActionConfirm},
+		{"автор после контрагента", false, true, true, DealActionComplete},
+		{"контрагент после автора", true, false, false, DealActionComplete},
+		{"автор повторно", true, false, true, DealActionConfirm},
+		{"контрагент повторно", false, true, false, DealActionConfirm},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deal := &Deal{AuthorConfirmed: tt.authorConfirmed, CounterConfirmed: tt.counterConfirmed}
+			if got := deal.ConfirmAction(tt.isAuthor); got != tt.want {
+				t.Errorf("ConfirmAction(%v) = %s, ожидалось %s", tt.isAuthor, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisputeResolutionAction(t *testing.T) {
+	tests := []struct {
+		status DealStatus
+		want   DealAction
+	}{
+		{DealStatusCompleted, DealActionResolveComplete},
+		{DealStatusCancelled, DealActionResolveCancel},
+	}
+
+	for _, tt := range tests {
+		resolution := &DisputeResolution{Status: tt.status}
+		if got := resolution.Action(); got != tt.want {
+			t.Errorf("Action() для %s = %s, ожидалось %s", tt.status, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+)
+
+// OpenDisputeRequest содержит данные для открытия спора по сделке
+type OpenDisputeRequest struct {
+	Reason   string `json:"reason"`   // Причина спора (обязательно)
+	Evidence string `json:"evidence"` // Доказательства: ссылки на скриншоты, номера транзакций
+}
+
+// ResolveDisputeRequest содержит решение администратора по спору
+// Решение в пользу покупателя криптовалюты завершает сделку, в пользу продавца - отменяет
+type ResolveDisputeRequest struct {
+	WinnerID int64  `json:"winner_id"` // ID участника сделки, в пользу которого решен спор
+	Comment  string `json:"comment"`   // Комментарий администратора
+}
+
+// DisputeResolution содержит итог спора для сохранения в репозитории
+type DisputeResolution struct {
+	Status     DealStatus // Итоговый статус сделки (completed или cancelled)
+	WinnerID   int64      // ID участника, в пользу которого решен спор
+	ResolvedBy int64      // ID администратора
+	Comment    string     // Комментарий администратора
+	ResolvedAt time.Time  // Время решения
+}
@@ -0,0 +1,206 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// =====================================================
+// РЫНОЧНАЯ СТАТИСТИКА ПО ЗАВЕРШЕННЫМ СДЕЛКАМ
+// =====================================================
+
+// Trade завершенная сделка как рыночная сделка: пара, цена, объем и время исполнения
+type Trade struct {
+	DealID         int64     `json:"deal_id"`        // ID сделки
+	Cryptocurrency string    `json:"cryptocurrency"` // Криптовалюта
+	FiatCurrency   string    `json:"fiat_currency"`  // Фиатная валюта
+	Price          float64   `json:"price"`          // Цена за единицу
+	Amount         float64   `json:"amount"`         // Количество криптовалюты
+	CompletedAt    time.Time `json:"completed_at"`   // Время завершения сделки
+}
+
+// TradeFilter содержит параметры выборки завершенных сделок
+// Пустые Cryptocurrency/FiatCurrency означают все пары
+type TradeFilter struct {
+	Cryptocurrency string    // Криптовалюта
+	FiatCurrency   string    // Фиатная валюта
+	Since          time.Time // Завершены не раньше (включительно)
+	Until          time.Time // Завершены раньше (не включительно)
+}
+
+// MarketStats статистика торговой пары за период
+type MarketStats struct {
+	Cryptocurrency string     `json:"cryptocurrency"` // Криптовалюта
+	FiatCurrency   string     `json:"fiat_currency"`  // Фиатная валюта
+	TradeCount     int        `json:"trade_count"`    // Количество сделок
+	Volume         float64    `json:"volume"`         // Объем в криптовалюте
+	QuoteVolume    float64    `json:"quote_volume"`   // Объем в фиатной валюте
+	Last           float64    `json:"last"`           // Цена последней сделки
+	Median         float64    `json:"median"`         // Медианная цена сделок
+	VWAP           float64    `json:"vwap"`           // Средняя цена, взвешенная по объему
+	High           float64    `json:"high"`           // Максимальная цена
+	Low            float64    `json:"low"`            // Минимальная цена
+	LastTradeAt    *time.Time `json:"last_trade_at"`  // Время последней сделки
+}
+
+// Candle свеча OHLCV: цены открытия, максимума, минимума, закрытия и объем за интервал
+type Candle struct {
+	OpenTime    time.Time `json:"open_time"`    // Начало интервала
+	CloseTime   time.Time `json:"close_time"`   // Конец интервала (не включительно)
+	Open        float64   `json:"open"`         // Цена первой сделки
+	High        float64   `json:"high"`         // Максимальная цена
+	Low         float64   `json:"low"`          // Минимальная цена
+	Close       float64   `json:"close"`        // Цена последней сделки
+	Volume      float64   `json:"volume"`       // Объем в криптовалюте
+	QuoteVolume float64   `json:"quote_volume"` // Объем в фиатной валюте
+	TradeCount  int       `json:"trade_count"`  // Количество сделок
+}
+
+// CandleInterval интервал свечей
+type CandleInterval string
+
+const (
+	CandleInterval15m CandleInterval = "15m" // 15 минут
+	CandleInterval1h  CandleInterval = "1h"  // 1 час
+	CandleInterval4h  CandleInterval = "4h"  // 4 часа
+	CandleInterval1d  CandleInterval = "1d"  // 1 день
+)
+
+// candleIntervals длительность поддерживаемых интервалов свечей
+var candleIntervals = map[CandleInterval]time.Duration{
+	CandleInterval15m: 15 * time.Minute,
+	CandleInterval1h:  time.Hour,
+	CandleInterval4h:  4 * time.Hour,
+	CandleInterval1d:  24 * time.Hour,
+}
+
+// ParseCandleInterval проверяет интервал свечей и возвращает его длительность
+func ParseCandleInterval(value string) (CandleInterval, time.Duration, error) {
+	interval := CandleInterval(value)
+	duration, ok := candleIntervals[interval]
+	if !ok {
+		return "", 0, fmt.Errorf("неподдерживаемый интервал свечей: %s (доступны 15m, 1h, 4h, 1d)", value)
+	}
+	return interval, duration, nil
+}
+
+// ParseTradingPair разбирает торговую пару вида "USDT-RUB" или "USDT/RUB"
+func ParseTradingPair(pair string) (crypto, fiat string, err error) {
+	parts := strings.FieldsFunc(strings.ToUpper(strings.TrimSpace(pair)), func(r rune) bool {
+		return r == '-' || r == '/' || r == '_'
+	})
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("неверная торговая пара: %s (ожидается, например, USDT-RUB)", pair)
+	}
+	return parts[0], parts[1], nil
+}
+
+// CalculateMarketStats считает статистику по каждой торговой паре
+// Сделки могут идти в любом порядке; результат отсортирован по паре
+func CalculateMarketStats(trades []*Trade) []*MarketStats {
+	byPair := make(map[string][]*Trade)
+	for _, trade := range trades {
+		key := trade.Cryptocurrency + "/" + trade.FiatCurrency
+		byPair[key] = append(byPair[key], trade)
+	}
+
+	stats := make([]*MarketStats, 0, len(byPair))
+	for _, pairTrades := range byPair {
+		stats = append(stats, calculatePairStats(pairTrades))
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Cryptocurrency != stats[j].Cryptocurrency {
+			return stats[i].Cryptocurrency < stats[j].Cryptocurrency
+		}
+		return stats[i].FiatCurrency < stats[j].FiatCurrency
+	})
+	return stats
+}
+
+// calculatePairStats считает статистику по сделкам одной пары (trades не пустой)
+func calculatePairStats(trades []*Trade) *MarketStats {
+	stats := &MarketStats{
+		Cryptocurrency: trades[0].Cryptocurrency,
+		FiatCurrency:   trades[0].FiatCurrency,
+		TradeCount:     len(trades),
+		High:           trades[0].Price,
+		Low:            trades[0].Price,
+	}
+
+	prices := make([]float64, 0, len(trades))
+	last := trades[0]
+	for _, trade := range trades {
+		stats.Volume += trade.Amount
+		stats.QuoteVolume += trade.Amount * trade.Price
+		if trade.Price > stats.High {
+			stats.High = trade.Price
+		}
+		if trade.Price < stats.Low {
+			stats.Low = trade.Price
+		}
+		if trade.CompletedAt.After(last.CompletedAt) {
+			last = trade
+		}
+		prices = append(prices, trade.Price)
+	}
+
+	stats.Volume = RoundAmount(stats.Volume)
+	stats.Last = last.Price
+	lastAt := last.CompletedAt
+	stats.LastTradeAt = &lastAt
+	stats.Median = median(prices)
+	if stats.Volume > 0 {
+		stats.VWAP = stats.QuoteVolume / stats.Volume
+	}
+	return stats
+}
+
+// median возвращает медиану цен (prices не пустой, порядок меняется)
+func median(prices []float64) float64 {
+	sort.Float64s(prices)
+	middle := len(prices) / 2
+	if len(prices)%2 == 1 {
+		return prices[middle]
+	}
+	return (prices[middle-1] + prices[middle]) / 2
+}
+
+// BuildCandles группирует сделки одной пары в свечи длительностью interval
+// Интервалы отсчитываются от начала эпохи (UTC); интервалы без сделок не возвращаются
+func BuildCandles(trades []*Trade, interval time.Duration) []*Candle {
+	sorted := make([]*Trade, len(trades))
+	copy(sorted, trades)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CompletedAt.Before(sorted[j].CompletedAt)
+	})
+
+	var candles []*Candle
+	var current *Candle
+	for _, trade := range sorted {
+		openTime := trade.CompletedAt.UTC().Truncate(interval)
+		if current == nil || !current.OpenTime.Equal(openTime) {
+			current = &Candle{
+				OpenTime:  openTime,
+				CloseTime: openTime.Add(interval),
+				Open:      trade.Price,
+				High:      trade.Price,
+				Low:       trade.Price,
+			}
+			candles = append(candles, current)
+		}
+
+		if trade.Price > current.High {
+			current.High = trade.Price
+		}
+		if trade.Price < current.Low {
+			current.Low = trade.Price
+		}
+		current.Close = trade.Price
+		current.Volume = RoundAmount(current.Volume + trade.Amount)
+		current.QuoteVolume += trade.Amount * trade.Price
+		current.TradeCount++
+	}
+	return candles
+}
@@ -0,0 +1,170 @@
+package model
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// marketBase момент отсчета сделок в тестах (начало часа UTC)
+var marketBase = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+// marketTrade сделка пары crypto/RUB через offset после marketBase
+func marketTrade(crypto string, price, amount float64, offset time.Duration) *Trade {
+	return &Trade{Cryptocurrency: crypto, FiatCurrency: "RUB", Price: price, Amount: amount, CompletedAt: marketBase.Add(offset)}
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		name   string
+		prices []float64
+		want   float64
+	}{
+		{"одна цена", []float64{95}, 95},
+		{"нечетное количество", []float64{97, 93, 95}, 95},
+		{"четное количество", []float64{96, 92, 94, 98}, 95},
+		{"две цены", []float64{90, 100}, 95},
+		{"одинаковые цены", []float64{94, 94, 94, 94}, 94},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := median(tt.prices); got != tt.want {
+				t.Errorf("median = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateMarketStats(t *testing.T) {
+	trades := []*Trade{
+		marketTrade("USDT", 95, 100, 10*time.Minute),
+		marketTrade("BTC", 9000000, 0.1, time.Minute),
+		marketTrade("USDT", 93, 300, 30*time.Minute), // Последняя по времени, но не последняя в списке
+		marketTrade("USDT", 97, 100, 20*time.Minute),
+	}
+
+	stats := CalculateMarketStats(trades)
+	if len(stats) != 2 || stats[0].Cryptocurrency != "BTC" || stats[1].Cryptocurrency != "USDT" {
+		t.Fatalf("ожидались пары BTC/RUB и USDT/RUB по порядку, получено %d", len(stats))
+	}
+
+	btc := stats[0]
+	if btc.TradeCount != 1 || btc.Last != 9000000 || btc.Median != 9000000 || btc.VWAP != 9000000 {
+		t.Errorf("статистика BTC по одной сделке: %+v", btc)
+	}
+
+	usdt := stats[1]
+	tests := []struct {
+		name      string
+		got, want float64
+	}{
+		{"Volume", usdt.Volume, 500},
+		{"QuoteVolume", usdt.QuoteVolume, 95*100 + 93*300 + 97*100},
+		{"VWAP", usdt.VWAP, (95*100 + 93*300 + 97*100) / 500.0},
+		{"Median", usdt.Median, 95},
+		{"High", usdt.High, 97},
+		{"Low", usdt.Low, 93},
+		{"Last", usdt.Last, 93},
+	}
+	for _, tt := range tests {
+		if !almostEqual(tt.got, tt.want) {
+			t.Errorf("%s = %v, ожидалось %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if usdt.TradeCount != 3 {
+		t.Errorf("TradeCount = %d, ожидалось 3", usdt.TradeCount)
+	}
+	if usdt.LastTradeAt == nil || !usdt.LastTradeAt.Equal(marketBase.Add(30*time.Minute)) {
+		t.Errorf("LastTradeAt = %v, ожидалось %v", usdt.LastTradeAt, marketBase.Add(30*time.Minute))
+	}
+}
+
+func TestCalculateMarketStatsVWAPDiffersFromMean(t *testing.T) {
+	// Крупная сделка по низкой цене тянет VWAP вниз, медиана по ценам - нет
+	stats := CalculateMarketStats([]*Trade{
+		marketTrade("ETH", 300000, 10, 0),
+		marketTrade("ETH", 340000, 1, time.Minute),
+		marketTrade("ETH", 350000, 1, 2*time.Minute),
+	})
+	eth := stats[0]
+	if want := (300000*10 + 340000 + 350000) / 12.0; !almostEqual(eth.VWAP, want) {
+		t.Errorf("VWAP = %v, ожидалось %v", eth.VWAP, want)
+	}
+	if eth.Median != 340000 {
+		t.Errorf("Median = %v, ожидалось 340000", eth.Median)
+	}
+}
+
+func TestCalculateMarketStatsEmpty(t *testing.T) {
+	if stats := CalculateMarketStats(nil); len(stats) != 0 {
+		t.Fatalf("без сделок ожидалась пустая статистика, получено %d пар", len(stats))
+	}
+}
+
+func TestBuildCandles(t *testing.T) {
+	trades := []*Trade{
+		marketTrade("USDT", 96, 10, 14*time.Minute+59*time.Second), // Последняя секунда первого интервала
+		marketTrade("USDT", 95, 20, 0),                             // Ровно на границе - открывает первый интервал
+		marketTrade("USDT", 94, 5, 5*time.Minute),
+		marketTrade("USDT", 97, 30, 15*time.Minute), // Ровно на границе - уже второй интервал
+		marketTrade("USDT", 98, 10, 50*time.Minute), // Интервалы 30m-45m пропущены
+	}
+
+	candles := BuildCandles(trades, 15*time.Minute)
+	want := []Candle{
+		{OpenTime: marketBase, CloseTime: marketBase.Add(15 * time.Minute),
+			Open: 95, High: 96, Low: 94, Close: 96, Volume: 35, QuoteVolume: 95*20 + 94*5 + 96*10, TradeCount: 3},
+		{OpenTime: marketBase.Add(15 * time.Minute), CloseTime: marketBase.Add(30 * time.Minute),
+			Open: 97, High: 97, Low: 97, Close: 97, Volume: 30, QuoteVolume: 97 * 30, TradeCount: 1},
+		{OpenTime: marketBase.Add(45 * time.Minute), CloseTime: marketBase.Add(time.Hour),
+			Open: 98, High: 98, Low: 98, Close: 98, Volume: 10, QuoteVolume: 98 * 10, TradeCount: 1},
+	}
+	if len(candles) != len(want) {
+		t.Fatalf("получено %d свечей, ожидалось %d", len(candles), len(want))
+	}
+	for i, candle := range candles {
+		w := want[i]
+		if !candle.OpenTime.Equal(w.OpenTime) || !candle.CloseTime.Equal(w.CloseTime) {
+			t.Errorf("свеча %d: интервал %s-%s, ожидался %s-%s", i, candle.OpenTime, candle.CloseTime, w.OpenTime, w.CloseTime)
+		}
+		if candle.Open != w.Open || candle.High != w.High || candle.Low != w.Low || candle.Close != w.Close {
+			t.Errorf("свеча %d: OHLC %v/%v/%v/%v, ожидалось %v/%v/%v/%v",
+				i, candle.Open, candle.High, candle.Low, candle.Close, w.Open, w.High, w.Low, w.Close)
+		}
+		if candle.Volume != w.Volume || !almostEqual(candle.QuoteVolume, w.QuoteVolume) || candle.TradeCount != w.TradeCount {
+			t.Errorf("свеча %d: объем %v (%v), сделок %d, ожидалось %v (%v), %d",
+				i, candle.Volume, candle.QuoteVolume, candle.TradeCount, w.Volume, w.QuoteVolume, w.TradeCount)
+		}
+	}
+}
+
+func TestBuildCandlesDailyUTC(t *testing.T) {
+	// Дневные свечи режутся по полуночи UTC, а не по местному времени сделки
+	moscow := time.FixedZone("MSK", 3*60*60)
+	trades := []*Trade{
+		{Cryptocurrency: "BTC", FiatCurrency: "RUB", Price: 1, Amount: 1, CompletedAt: time.Date(2026, 3, 11, 2, 0, 0, 0, moscow)}, // 10 марта 23:00 UTC
+		{Cryptocurrency: "BTC", FiatCurrency: "RUB", Price: 2, Amount: 1, CompletedAt: time.Date(2026, 3, 11, 3, 0, 0, 0, moscow)}, // 11 марта 00:00 UTC
+	}
+
+	candles := BuildCandles(trades, 24*time.Hour)
+	if len(candles) != 2 {
+		t.Fatalf("получено %d свечей, ожидалось 2", len(candles))
+	}
+	if !candles[0].OpenTime.Equal(time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)) ||
+		!candles[1].OpenTime.Equal(time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("свечи открываются в %s и %s", candles[0].OpenTime, candles[1].OpenTime)
+	}
+}
+
+func TestParseCandleInterval(t *testing.T) {
+	if _, d, err := ParseCandleInterval("4h"); err != nil || d != 4*time.Hour {
+		t.Errorf("4h: %v, %v", d, err)
+	}
+	if _, _, err := ParseCandleInterval("5m"); err == nil {
+		t.Error("интервал 5m должен отклоняться")
+	}
+}
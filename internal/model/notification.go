@@ -10,18 +10,27 @@ type NotificationType string
 const (
 	// Уведомления по заявкам
 	NotificationTypeOrderCreated NotificationType = "order_created" // Создана новая заявка (групповое уведомление)
+	NotificationTypeOrderExpired NotificationType = "order_expired" // Срок действия заявки истек
 
 	// Уведомления по откликам
-	NotificationTypeNewResponse      NotificationType = "new_response"      // Новый отклик на заявку
-	NotificationTypeResponseAccepted NotificationType = "response_accepted" // Отклик принят
-	NotificationTypeResponseRejected NotificationType = "response_rejected" // Отклик отклонен
+	NotificationTypeNewResponse       NotificationType = "new_response"       // Новый отклик на заявку
+	NotificationTypeResponseAccepted  NotificationType = "response_accepted"  // Отклик принят
+	NotificationTypeResponseRejected  NotificationType = "response_rejected"  // Отклик отклонен
+	NotificationTypeResponseCountered NotificationType = "response_countered" // Встречное предложение по отклику
+	NotificationTypeResponseWithdrawn NotificationType = "response_withdrawn" // Откликнувшийся отозвал отклик
 
 	// Уведомления по сделкам
-	NotificationTypeDealCreated   NotificationType = "deal_created"   // Сделка создана
-	NotificationTypeDealConfirmed NotificationType = "deal_confirmed" // Сделка подтверждена одной стороной
-	NotificationTypeDealCompleted NotificationType = "deal_completed" // Сделка полностью завершена
-	NotificationTypeDealExpiring  NotificationType = "deal_expiring"  // Сделка скоро истекает
-	NotificationTypeDealCancelled NotificationType = "deal_cancelled" // Сделка отменена
+	NotificationTypeDealCreated         NotificationType = "deal_created"          // Сделка создана
+	NotificationTypeDealConfirmed       NotificationType = "deal_confirmed"        // Сделка подтверждена одной стороной
+	NotificationTypeDealCompleted       NotificationType = "deal_completed"        // Сделка полностью завершена
+	NotificationTypeDealExpiring        NotificationType = "deal_expiring"         // Сделка скоро истекает
+	NotificationTypeDealExpired         NotificationType = "deal_expired"          // Сделка истекла без подтверждения
+	NotificationTypeDealCancelled       NotificationType = "deal_cancelled"        // Сделка отменена
+	NotificationTypeDealCancelRequested NotificationType = "deal_cancel_requested" // Вторая сторона просит отменить сделку
+
+	// Уведомления по спорам
+	NotificationTypeDisputeOpened   NotificationType = "dispute_opened"   // По сделке открыт спор
+	NotificationTypeDisputeResolved NotificationType = "dispute_resolved" // Спор решен администратором
 
 	// Системные уведомления
 	NotificationTypeSystemMessage NotificationType = "system_message" // Системные сообщения
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"math"
 	"time"
 )
 
@@ -40,26 +41,36 @@ const (
 // Order представляет заявку на покупку или продажу криптовалюты
 // Это основная сущность для P2P торговли
 type Order struct {
-	ID                 int64       `json:"id" db:"id"`                                     // Уникальный идентификатор заявки
-	UserID             int64       `json:"user_id" db:"user_id"`                           // ID создателя заявки
-	Type               OrderType   `json:"type" db:"type"`                                 // Тип заявки (buy/sell)
-	Cryptocurrency     string      `json:"cryptocurrency" db:"cryptocurrency"`             // Название криптовалюты (BTC, ETH, USDT и т.д.)
-	FiatCurrency       string      `json:"fiat_currency" db:"fiat_currency"`               // Фиатная валюта (RUB, USD, EUR)
-	Amount             float64     `json:"amount" db:"amount"`                             // Количество криптовалюты
-	Price              float64     `json:"price" db:"price"`                               // Цена за единицу криптовалюты
-	TotalAmount        float64     `json:"total_amount" db:"total_amount"`                 // Общая сумма сделки (amount * price)
-	MinAmount          float64     `json:"min_amount" db:"min_amount"`                     // Минимальная сумма для сделки
-	MaxAmount          float64     `json:"max_amount" db:"max_amount"`                     // Максимальная сумма для сделки
-	PaymentMethods     []string    `json:"payment_methods" db:"payment_methods"`           // Способы оплаты (JSON array)
-	Description        string      `json:"description" db:"description"`                   // Дополнительное описание заявки
-	Status             OrderStatus `json:"status" db:"status"`                             // Статус заявки
-	CreatedAt          time.Time   `json:"created_at" db:"created_at"`                     // Дата создания заявки
-	UpdatedAt          time.Time   `json:"updated_at" db:"updated_at"`                     // Дата последнего обновления
-	ExpiresAt          time.Time   `json:"expires_at" db:"expires_at"`                     // Дата истечения заявки
-	CompletedAt        *time.Time  `json:"completed_at" db:"completed_at"`                 // Время завершения сделки
-	IsActive           bool        `json:"is_active" db:"is_active"`                       // Активна ли заявка
-	ResponseCount      int         `json:"response_count" db:"response_count"`             // Количество откликов на заявку
-	AcceptedResponseID *int64      `json:"accepted_response_id" db:"accepted_response_id"` // ID принятого отклика (если есть)
+	ID                 int64            `json:"id" db:"id"`                                     // Уникальный идентификатор заявки
+	UserID             int64            `json:"user_id" db:"user_id"`                           // ID создателя заявки
+	Type               OrderType        `json:"type" db:"type"`                                 // Тип заявки (buy/sell)
+	Cryptocurrency     string           `json:"cryptocurrency" db:"cryptocurrency"`             // Название криптовалюты (BTC, ETH, USDT и т.д.)
+	FiatCurrency       string           `json:"fiat_currency" db:"fiat_currency"`               // Фиатная валюта (RUB, USD, EUR)
+	Amount             float64          `json:"amount" db:"amount"`                             // Количество криптовалюты
+	Price              float64          `json:"price" db:"price"`                               // Цена за единицу криптовалюты
+	TotalAmount        float64          `json:"total_amount" db:"total_amount"`                 // Общая сумма сделки (amount * price)
+	MinAmount          float64          `json:"min_amount" db:"min_amount"`                     // Минимальная сумма для сделки
+	MaxAmount          float64          `json:"max_amount" db:"max_amount"`                     // Максимальная сумма для сделки
+	RemainingAmount    float64          `json:"remaining_amount" db:"remaining_amount"`         // Количество криптовалюты, еще не занятое сделками
+	PaymentMethods     []string         `json:"payment_methods" db:"payment_methods"`           // Способы оплаты (JSON array)
+	Description        string           `json:"description" db:"description"`                   // Дополнительное описание заявки
+	Status             OrderStatus      `json:"status" db:"status"`                             // Статус заявки
+	CreatedAt          time.Time        `json:"created_at" db:"created_at"`                     // Дата создания заявки
+	UpdatedAt          time.Time        `json:"updated_at" db:"updated_at"`                     // Дата последнего обновления
+	ExpiresAt          time.Time        `json:"expires_at" db:"expires_at"`                     // Дата истечения заявки
+	CompletedAt        *time.Time       `json:"completed_at" db:"completed_at"`                 // Время завершения сделки
+	IsActive           bool             `json:"is_active" db:"is_active"`                       // Активна ли заявка
+	ResponseCount      int              `json:"response_count" db:"response_count"`             // Количество откликов на заявку
+	AcceptedResponseID *int64           `json:"accepted_response_id" db:"accepted_response_id"` // ID принятого отклика (если есть)
+	AutoAccept         *AutoAcceptRules `json:"auto_accept,omitempty" db:"auto_accept"`         // Правила автоматического принятия откликов (JSON)
+
+	// Плавающая цена: курс источника ± наценка в процентах (PriceType = floating)
+	PriceType     PriceType `json:"price_type,omitempty" db:"price_type"`     // Тип цены: fixed (по умолчанию) или floating
+	PriceMargin   float64   `json:"price_margin,omitempty" db:"price_margin"` // Наценка к курсу в процентах (может быть отрицательной)
+	ReferenceRate float64   `json:"reference_rate,omitempty" db:"-"`          // Курс, по которому посчитана текущая цена
+
+	// Параметры запроса на создание (не сохраняются в БД)
+	TTLHours int `json:"ttl_hours,omitempty" db:"-"` // Срок действия заявки в часах (по умолчанию OrderExpirationHours)
 
 	// Дополнительные поля для фронтенда (не сохраняются в БД)
 	UserName  string `json:"user_name,omitempty"`  // Полное имя пользователя
@@ -68,6 +79,24 @@ type Order struct {
 	LastName  string `json:"last_name,omitempty"`  // Фамилия пользователя
 }
 
+// AmountEpsilon допустимая погрешность при сравнении количеств криптовалюты (8 знаков, как в БД)
+const AmountEpsilon = 1e-8
+
+// RoundAmount округляет количество криптовалюты до 8 знаков после запятой
+func RoundAmount(amount float64) float64 {
+	return math.Round(amount*1e8) / 1e8
+}
+
+// IsFilled проверяет, что весь объем заявки уже занят сделками
+func (o *Order) IsFilled() bool {
+	return o.RemainingAmount < AmountEpsilon
+}
+
+// HasPaymentMethod проверяет, указан ли способ оплаты в заявке
+func (o *Order) HasPaymentMethod(method string) bool {
+	return containsString(o.PaymentMethods, method)
+}
+
 // DealStatus определяет статус сделки в новой логике
 type DealStatus string
 
@@ -97,7 +126,7 @@ type Deal struct {
 	OrderType        OrderType  `json:"order_type" db:"order_type"`               // Тип исходной заявки (buy/sell)
 	Status           DealStatus `json:"status" db:"status"`                       // Статус сделки
 	CreatedAt        time.Time  `json:"created_at" db:"created_at"`               // Время создания сделки
-	ExpiresAt        time.Time  `json:"expires_at" db:"expires_at"`               // Время истечения сделки (таймер 1 час)
+	ExpiresAt        time.Time  `json:"expires_at" db:"expires_at"`               // Крайний срок первого подтверждения (DealConfirmationTimeoutMinutes)
 	CompletedAt      *time.Time `json:"completed_at" db:"completed_at"`           // Время завершения сделки
 	AuthorConfirmed  bool       `json:"author_confirmed" db:"author_confirmed"`   // Подтвердил ли автор заявки перевод
 	CounterConfirmed bool       `json:"counter_confirmed" db:"counter_confirmed"` // Подтвердил ли контрагент перевод
@@ -106,6 +135,27 @@ type Deal struct {
 	Notes            string     `json:"notes" db:"notes"`                         // Заметки по сделке
 	DisputeReason    string     `json:"dispute_reason" db:"dispute_reason"`       // Причина спора (если есть)
 
+	// Поля спора по сделке (заполняются при открытии и решении спора)
+	DisputeEvidence   string     `json:"dispute_evidence,omitempty" db:"dispute_evidence"`       // Доказательства, приложенные к спору
+	DisputeOpenedBy   int64      `json:"dispute_opened_by,omitempty" db:"dispute_opened_by"`     // ID участника, открывшего спор
+	DisputeOpenedAt   *time.Time `json:"dispute_opened_at,omitempty" db:"dispute_opened_at"`     // Время открытия спора
+	DisputeWinnerID   int64      `json:"dispute_winner_id,omitempty" db:"dispute_winner_id"`     // ID участника, в пользу которого решен спор
+	DisputeResolvedBy int64      `json:"dispute_resolved_by,omitempty" db:"dispute_resolved_by"` // ID администратора, решившего спор
+	DisputeResolvedAt *time.Time `json:"dispute_resolved_at,omitempty" db:"dispute_resolved_at"` // Время решения спора
+	DisputeComment    string     `json:"dispute_comment,omitempty" db:"dispute_comment"`         // Комментарий администратора к решению
+
+	// Поля отмены сделки
+	CancelReason      string     `json:"cancel_reason,omitempty" db:"cancel_reason"`             // Причина отмены
+	CancelRequestedBy int64      `json:"cancel_requested_by,omitempty" db:"cancel_requested_by"` // ID участника, запросившего отмену (ждет согласия второй стороны)
+	CancelRequestedAt *time.Time `json:"cancel_requested_at,omitempty" db:"cancel_requested_at"` // Время запроса отмены
+	CancelledBy       int64      `json:"cancelled_by,omitempty" db:"cancelled_by"`               // ID участника, отменившего сделку
+	CancelledAt       *time.Time `json:"cancelled_at,omitempty" db:"cancelled_at"`               // Время отмены сделки
+
+	ExpiryWarningSent bool `json:"expiry_warning_sent" db:"expiry_warning_sent"` // Отправлено ли предупреждение о скором истечении
+
+	// Поля автосопоставления заявок
+	MatchedOrderID int64 `json:"matched_order_id,omitempty" db:"matched_order_id"` // ID встречной заявки контрагента
+
 	// Дополнительные поля для фронтенда (не сохраняются в БД)
 	AuthorUsername          string `json:"author_username,omitempty"`       // Telegram username автора
 	AuthorName              string `json:"author_name,omitempty"`           // Полное имя автора
@@ -115,6 +165,19 @@ type Deal struct {
 	CounterpartyReviewGiven bool   `json:"counterparty_review_given"`       // Оставил ли контрагент отзыв об авторе
 }
 
+// OrderIDs возвращает заявки, объем которых занят сделкой: исходную и встречную (при автосопоставлении)
+func (d *Deal) OrderIDs() []int64 {
+	if d.MatchedOrderID != 0 {
+		return []int64{d.OrderID, d.MatchedOrderID}
+	}
+	return []int64{d.OrderID}
+}
+
+// CancelDealRequest содержит данные для отмены сделки
+type CancelDealRequest struct {
+	Reason string `json:"reason"` // Причина отмены (обязательно)
+}
+
 // OrderFilter содержит параметры для фильтрации заявок
 // Используется при поиске подходящих заявок
 type OrderFilter struct {
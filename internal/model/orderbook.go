@@ -0,0 +1,58 @@
+package model
+
+import (
+	"time"
+)
+
+// Лимиты глубины стакана (количество ценовых уровней с каждой стороны)
+const (
+	DefaultOrderBookDepth = 20
+	MaxOrderBookDepth     = 100
+)
+
+// OrderBookFilter содержит параметры построения стакана заявок по торговой паре
+type OrderBookFilter struct {
+	Cryptocurrency string `json:"cryptocurrency"` // Криптовалюта (обязательно)
+	FiatCurrency   string `json:"fiat_currency"`  // Фиатная валюта (обязательно)
+	PaymentMethod  string `json:"payment_method"` // Только заявки с этим способом оплаты (необязательно)
+	Depth          int    `json:"depth"`          // Количество ценовых уровней с каждой стороны
+}
+
+// OrderBookLevel ценовой уровень стакана: заявки одной стороны с одинаковой ценой
+type OrderBookLevel struct {
+	Price      float64 `json:"price"`       // Цена за единицу
+	Amount     float64 `json:"amount"`      // Суммарный свободный остаток заявок уровня
+	Total      float64 `json:"total"`       // Сумма уровня в фиатной валюте (amount * price)
+	OrderCount int     `json:"order_count"` // Количество заявок на уровне
+}
+
+// OrderBook стакан заявок по торговой паре.
+// Bids - заявки на покупку (лучшая - самая дорогая), Asks - заявки на продажу (лучшая - самая дешевая)
+type OrderBook struct {
+	Cryptocurrency string           `json:"cryptocurrency"`           // Криптовалюта
+	FiatCurrency   string           `json:"fiat_currency"`            // Фиатная валюта
+	PaymentMethod  string           `json:"payment_method,omitempty"` // Фильтр по способу оплаты
+	Bids           []OrderBookLevel `json:"bids"`                     // Уровни покупки по убыванию цены
+	Asks           []OrderBookLevel `json:"asks"`                     // Уровни продажи по возрастанию цены
+	BestBid        *float64         `json:"best_bid"`                 // Лучшая цена покупки (nil - покупок нет)
+	BestAsk        *float64         `json:"best_ask"`                 // Лучшая цена продажи (nil - продаж нет)
+	Spread         *float64         `json:"spread"`                   // Разница лучших цен продажи и покупки
+	UpdatedAt      time.Time        `json:"updated_at"`               // Время построения стакана
+}
+
+// CalculateSpread заполняет лучшие цены и спред по уровням стакана
+func (b *OrderBook) CalculateSpread() {
+	b.BestBid, b.BestAsk, b.Spread = nil, nil, nil
+	if len(b.Bids) > 0 {
+		bid := b.Bids[0].Price
+		b.BestBid = &bid
+	}
+	if len(b.Asks) > 0 {
+		ask := b.Asks[0].Price
+		b.BestAsk = &ask
+	}
+	if b.BestBid != nil && b.BestAsk != nil {
+		spread := *b.BestAsk - *b.BestBid
+		b.Spread = &spread
+	}
+}
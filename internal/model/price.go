@@ -0,0 +1,52 @@
+package model
+
+import (
+	"fmt"
+	"math"
+)
+
+// PriceType определяет способ формирования цены заявки
+type PriceType string
+
+const (
+	PriceTypeFixed    PriceType = "fixed"    // Фиксированная цена, указанная автором
+	PriceTypeFloating PriceType = "floating" // Курс источника ± наценка автора
+)
+
+// MaxPriceMargin максимальная наценка (или скидка) к курсу для плавающей цены, в процентах
+const MaxPriceMargin = 50.0
+
+// IsFloating проверяет, что цена заявки привязана к курсу
+func (o *Order) IsFloating() bool {
+	return o.PriceType == PriceTypeFloating
+}
+
+// ValidatePricing проверяет тип цены и наценку заявки
+func (o *Order) ValidatePricing() error {
+	switch o.PriceType {
+	case "", PriceTypeFixed:
+		if o.PriceMargin != 0 {
+			return fmt.Errorf("наценка к курсу указывается только для плавающей цены")
+		}
+	case PriceTypeFloating:
+		if math.Abs(o.PriceMargin) > MaxPriceMargin {
+			return fmt.Errorf("наценка к курсу должна быть от -%.0f%% до %.0f%%", MaxPriceMargin, MaxPriceMargin)
+		}
+	default:
+		return fmt.Errorf("неверный тип цены: %s", o.PriceType)
+	}
+	return nil
+}
+
+// FloatingPrice считает цену по курсу с наценкой заявки (округление до копеек)
+func (o *Order) FloatingPrice(rate float64) float64 {
+	return math.Round(rate*(1+o.PriceMargin/100)*100) / 100
+}
+
+// ApplyReferenceRate пересчитывает цену плавающей заявки по курсу.
+// Общая сумма пересчитывается по новой цене для объема, еще не занятого сделками; лимиты сделки не меняются
+func (o *Order) ApplyReferenceRate(rate float64) {
+	o.ReferenceRate = rate
+	o.Price = o.FloatingPrice(rate)
+	o.TotalAmount = o.RemainingAmount * o.Price
+}
@@ -0,0 +1,19 @@
+package model
+
+import "testing"
+
+func TestApplyReferenceRate(t *testing.T) {
+	order := &Order{PriceType: PriceTypeFloating, PriceMargin: 2, Amount: 1, RemainingAmount: 1, MinAmount: 1000}
+
+	order.ApplyReferenceRate(100000)
+	if order.ReferenceRate != 100000 || order.Price != 102000 || order.TotalAmount != 102000 {
+		t.Fatalf("курс %v, цена %v, сумма %v", order.ReferenceRate, order.Price, order.TotalAmount)
+	}
+
+	// После частичного исполнения сумма считается по остатку, лимиты сделки не меняются
+	order.RemainingAmount = 0.25
+	order.ApplyReferenceRate(80000)
+	if order.Price != 81600 || !almostEqual(order.TotalAmount, 20400) || order.MinAmount != 1000 {
+		t.Fatalf("цена %v, сумма %v, минимум %v", order.Price, order.TotalAmount, order.MinAmount)
+	}
+}
@@ -11,6 +11,8 @@ const (
 	ResponseStatusWaiting  ResponseStatus = "waiting"  // Ожидает рассмотрения автором заявки
 	ResponseStatusAccepted ResponseStatus = "accepted" // Принят автором заявки
 	ResponseStatusRejected ResponseStatus = "rejected" // Отклонен автором заявки
+	ResponseStatusCountered ResponseStatus = "countered" // Автор заявки предложил встречные условия, ждем ответа откликнувшегося
+	ResponseStatusWithdrawn ResponseStatus = "withdrawn" // Отозван откликнувшимся до решения автора
 )
 
 // Response представляет отклик пользователя на заявку
@@ -24,6 +26,10 @@ type Response struct {
 	CreatedAt  time.Time      `json:"created_at" db:"created_at"`   // Время создания отклика
 	UpdatedAt  time.Time      `json:"updated_at" db:"updated_at"`   // Время последнего обновления
 	ReviewedAt *time.Time     `json:"reviewed_at" db:"reviewed_at"` // Время рассмотрения автором (null если еще не рассмотрен)
+	RequestedAmount float64   `json:"requested_amount" db:"requested_amount"` // Запрошенное количество криптовалюты (часть заявки)
+	ProposedPrice   float64   `json:"proposed_price" db:"proposed_price"`     // Предложенная цена за единицу (0 - цена заявки)
+	PaymentMethod   string    `json:"payment_method" db:"payment_method"`     // Выбранный способ оплаты (пусто - любой из способов заявки)
+	Offers          []*ResponseOffer `json:"offers,omitempty"`                 // История предложений по отклику (заполняется сервисом)
 	
 	// Дополнительные поля для фронтенда (не сохраняются в БД)
 	UserName     string `json:"user_name,omitempty"`     // Полное имя откликнувшегося
@@ -36,12 +42,50 @@ type Response struct {
 	Amount         float64 `json:"amount,omitempty"`        // Объем
 	Price          float64 `json:"price,omitempty"`         // Цена
 	TotalAmount    float64 `json:"total_amount,omitempty"`  // Общая сумма
+	DealID         int64   `json:"deal_id,omitempty"`       // ID сделки, если отклик принят автоматически
 }
 
 // CreateResponseRequest содержит данные для создания нового отклика
 type CreateResponseRequest struct {
 	OrderID int64  `json:"order_id" validate:"required"` // ID заявки (обязательно)
 	Message string `json:"message" validate:"max=500"`   // Сообщение (макс 500 символов)
+	Amount  float64 `json:"amount"`                      // Запрошенное количество криптовалюты (0 - весь остаток заявки)
+	Price   float64 `json:"price"`                       // Предложенная цена за единицу (0 - цена заявки)
+	PaymentMethod string `json:"payment_method"`        // Способ оплаты из способов заявки (необязательно)
+	AutoAccept    bool   `json:"auto_accept"`           // Принять отклик сразу, если он подходит под правила автопринятия заявки
+}
+
+// CounterResponseRequest содержит встречное предложение по отклику
+// Нулевые поля означают, что условие не меняется
+type CounterResponseRequest struct {
+	Price   float64 `json:"price"`   // Предлагаемая цена за единицу
+	Amount  float64 `json:"amount"`  // Предлагаемое количество криптовалюты
+	Message string  `json:"message"` // Комментарий к предложению
+}
+
+// ResponseOffer представляет одно предложение в истории переговоров по отклику:
+// первоначальные условия откликнувшегося и встречные предложения сторон
+type ResponseOffer struct {
+	ID         int64     `json:"id" db:"id"`                   // Уникальный идентификатор предложения
+	ResponseID int64     `json:"response_id" db:"response_id"` // ID отклика
+	UserID     int64     `json:"user_id" db:"user_id"`         // Кто сделал предложение
+	Price      float64   `json:"price" db:"price"`             // Предложенная цена за единицу
+	Amount     float64   `json:"amount" db:"amount"`           // Предложенное количество криптовалюты
+	Message    string    `json:"message" db:"message"`         // Комментарий к предложению
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`   // Время предложения
+}
+
+// IsOpen проверяет, идут ли еще переговоры по отклику (его можно принять, отклонить или ответить встречным предложением)
+func (r *Response) IsOpen() bool {
+	return r.Status == ResponseStatusWaiting || r.Status == ResponseStatusCountered
+}
+
+// DealPrice возвращает цену сделки по текущим условиям отклика
+func (r *Response) DealPrice(order *Order) float64 {
+	if r.ProposedPrice > 0 {
+		return r.ProposedPrice
+	}
+	return order.Price
 }
 
 // ResponseWithDetails содержит отклик с дополнительной информацией о заявке и пользователе
@@ -7,22 +7,39 @@ import (
 // User представляет модель пользователя в системе P2P биржи
 // Каждый пользователь авторизуется через Telegram и имеет свой профиль
 type User struct {
-	ID              int64     `json:"id" db:"id"`                             // Уникальный идентификатор пользователя
-	TelegramID      int64     `json:"telegram_id" db:"telegram_id"`           // ID пользователя в Telegram
-	TelegramUserID  string    `json:"telegram_user_id" db:"telegram_user_id"` // Username в Telegram (@username)
-	FirstName       string    `json:"first_name" db:"first_name"`             // Имя из Telegram профиля
-	LastName        string    `json:"last_name" db:"last_name"`               // Фамилия из Telegram профиля
-	Username        string    `json:"username" db:"username"`                 // Username из Telegram профиля
-	PhotoURL        string    `json:"photo_url" db:"photo_url"`               // URL фото профиля из Telegram
-	IsBot           bool      `json:"is_bot" db:"is_bot"`                     // Флаг бота (должен быть false)
-	LanguageCode    string    `json:"language_code" db:"language_code"`       // Код языка пользователя
-	CreatedAt       time.Time `json:"created_at" db:"created_at"`             // Дата создания аккаунта
-	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`             // Дата последнего обновления
-	IsActive        bool      `json:"is_active" db:"is_active"`               // Активен ли пользователь
-	Rating          float32   `json:"rating" db:"rating"`                     // Средний рейтинг пользователя (0-5 звезд)
-	TotalDeals      int       `json:"total_deals" db:"total_deals"`           // Общее количество завершенных сделок
-	SuccessfulDeals int       `json:"successful_deals" db:"successful_deals"` // Количество успешных сделок
-	ChatMember      bool      `json:"chat_member" db:"chat_member"`           // Является ли членом закрытого чата
+	ID              int64      `json:"id" db:"id"`                               // Уникальный идентификатор пользователя
+	TelegramID      int64      `json:"telegram_id" db:"telegram_id"`             // ID пользователя в Telegram
+	TelegramUserID  string     `json:"telegram_user_id" db:"telegram_user_id"`   // Username в Telegram (@username)
+	FirstName       string     `json:"first_name" db:"first_name"`               // Имя из Telegram профиля
+	LastName        string     `json:"last_name" db:"last_name"`                 // Фамилия из Telegram профиля
+	Username        string     `json:"username" db:"username"`                   // Username из Telegram профиля
+	PhotoURL        string     `json:"photo_url" db:"photo_url"`                 // URL фото профиля из Telegram
+	IsBot           bool       `json:"is_bot" db:"is_bot"`                       // Флаг бота (должен быть false)
+	LanguageCode    string     `json:"language_code" db:"language_code"`         // Код языка пользователя
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`               // Дата создания аккаунта
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`               // Дата последнего обновления
+	IsActive        bool       `json:"is_active" db:"is_active"`                 // Активен ли пользователь
+	Rating          float32    `json:"rating" db:"rating"`                       // Средний рейтинг пользователя (0-5 звезд)
+	TotalDeals      int        `json:"total_deals" db:"total_deals"`             // Общее количество завершенных сделок
+	SuccessfulDeals int        `json:"successful_deals" db:"successful_deals"`   // Количество успешных сделок
+	ChatMember      bool       `json:"chat_member" db:"chat_member"`             // Является ли членом закрытого чата
+	ChatMemberSince *time.Time `json:"chat_member_since" db:"chat_member_since"` // С какого момента состоит в закрытом чате
+}
+
+// ChatMembershipAge возвращает стаж пользователя в закрытом чате на момент now.
+// Для участников без сохраненной даты вступления стаж считается от регистрации
+func (u *User) ChatMembershipAge(now time.Time) time.Duration {
+	if !u.ChatMember {
+		return 0
+	}
+	since := u.CreatedAt
+	if u.ChatMemberSince != nil {
+		since = *u.ChatMemberSince
+	}
+	if since.After(now) {
+		return 0
+	}
+	return now.Sub(since)
 }
 
 // UserProfile содержит расширенную информацию о пользователе
@@ -45,6 +62,10 @@ type TelegramAuthData struct {
 	PhotoURL  string `json:"photo_url"`  // URL фото профиля
 	AuthDate  int64  `json:"auth_date"`  // Unix timestamp авторизации
 	Hash      string `json:"hash"`       // Хеш для валидации данных
+
+	// InitData сырая строка initData из Telegram Mini App (window.Telegram.WebApp.initData)
+	// Если передана, авторизация проверяется по схеме WebAppData, а поля выше заполняются из нее
+	InitData string `json:"init_data,omitempty"`
 }
 
 // UserStats содержит подробную статистику пользователя для отображения в профиле
@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// =====================================================
+// ОГРАНИЧЕНИЕ ЧАСТОТЫ ЗАПРОСОВ (TOKEN BUCKET)
+// =====================================================
+
+// Limit бюджет запросов: не больше Requests за Period с накоплением до Requests подряд
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Enabled сообщает, задан ли лимит (нулевой лимит не ограничивает запросы)
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// rate скорость пополнения корзины в токенах в секунду
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Store хранилище корзин токенов.
+// Take списывает токен из корзины key и возвращает, разрешен ли запрос,
+// а если нет - через сколько появится следующий токен.
+// Реализация должна быть безопасной для конкурентного использования;
+// общее хранилище (например, Redis) позволит делить лимиты между несколькими экземплярами сервера
+type Store interface {
+	Take(key string, limit Limit, now time.Time) (allowed bool, retryAfter time.Duration)
+}
+
+// Limiter применяет раздельные бюджеты для чтения и записи
+type Limiter struct {
+	store Store
+	read  Limit
+	write Limit
+}
+
+// NewLimiter создает ограничитель с бюджетами на чтение и запись поверх хранилища store
+func NewLimiter(store Store, read, write Limit) *Limiter {
+	return &Limiter{store: store, read: read, write: write}
+}
+
+// Allow списывает запрос из бюджета клиента key (пользователь или IP).
+// write выбирает бюджет записи вместо бюджета чтения
+func (l *Limiter) Allow(key string, write bool) (bool, time.Duration) {
+	limit, class := l.read, "read"
+	if write {
+		limit, class = l.write, "write"
+	}
+	if !limit.Enabled() {
+		return true, 0
+	}
+	return l.store.Take(class+":"+key, limit, time.Now())
+}
+
+// =====================================================
+// ХРАНИЛИЩЕ В ПАМЯТИ
+// =====================================================
+
+// sweepInterval как часто MemoryStore удаляет полностью восстановившиеся корзины
+const sweepInterval = time.Minute
+
+// bucket корзина токенов одного клиента
+type bucket struct {
+	tokens  float64   // Доступные токены на момент updated
+	updated time.Time // Время последнего списания
+	fullAt  time.Time // Когда корзина снова заполнится (после этого ее можно удалить)
+}
+
+// MemoryStore хранит корзины в памяти процесса.
+// Лимиты действуют в пределах одного экземпляра сервера и сбрасываются при перезапуске
+type MemoryStore struct {
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore создает пустое хранилище корзин в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+// Take списывает токен из корзины key, пополняя ее пропорционально прошедшему времени
+func (s *MemoryStore) Take(key string, limit Limit, now time.Time) (bool, time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	capacity := float64(limit.Requests)
+	rate := limit.rate()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+		return false, wait
+	}
+
+	b.tokens--
+	b.fullAt = now.Add(time.Duration((capacity - b.tokens) / rate * float64(time.Second)))
+	return true, 0
+}
+
+// sweep удаляет корзины, которые уже заполнились: они не отличаются от новых (вызывается под mutex)
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if !now.Before(b.fullAt) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
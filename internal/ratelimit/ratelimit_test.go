@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// testLimit 10 запросов в минуту: один токен каждые 6 секунд
+var testLimit = Limit{Requests: 10, Period: time.Minute}
+
+var testNow = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+// takeN списывает n токенов и возвращает, сколько из них было разрешено
+func takeN(s *MemoryStore, key string, n int, now time.Time) int {
+	allowed := 0
+	for i := 0; i < n; i++ {
+		if ok, _ := s.Take(key, testLimit, now); ok {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestMemoryStoreBurstAndRetryAfter(t *testing.T) {
+	s := NewMemoryStore()
+
+	// Новая корзина полная: разрешено Requests запросов подряд
+	if allowed := takeN(s, "ip:1", 10, testNow); allowed != 10 {
+		t.Fatalf("разрешено %d запросов из полной корзины", allowed)
+	}
+
+	ok, retryAfter := s.Take("ip:1", testLimit, testNow)
+	if ok {
+		t.Fatal("запрос сверх бюджета разрешен")
+	}
+	if retryAfter != 6*time.Second {
+		t.Fatalf("Retry-After %s, ожидалось 6s", retryAfter)
+	}
+
+	// Через 2 секунды до следующего токена остается 4
+	if _, retryAfter := s.Take("ip:1", testLimit, testNow.Add(2*time.Second)); retryAfter != 4*time.Second {
+		t.Fatalf("Retry-After %s, ожидалось 4s", retryAfter)
+	}
+
+	// Корзины клиентов независимы
+	if ok, _ := s.Take("ip:2", testLimit, testNow); !ok {
+		t.Fatal("другой клиент упирается в чужой лимит")
+	}
+}
+
+func TestMemoryStoreRefill(t *testing.T) {
+	s := NewMemoryStore()
+	takeN(s, "user:1", 10, testNow)
+
+	// За 12 секунд восстанавливается 2 токена
+	if allowed := takeN(s, "user:1", 5, testNow.Add(12*time.Second)); allowed != 2 {
+		t.Fatalf("после 12 секунд разрешено %d запросов, ожидалось 2", allowed)
+	}
+
+	// Отклоненные запросы не отодвигают пополнение; за час корзина заполняется, но не сверх Requests
+	if allowed := takeN(s, "user:1", 15, testNow.Add(time.Hour)); allowed != 10 {
+		t.Fatalf("после часа разрешено %d запросов, ожидалось 10", allowed)
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	s := NewMemoryStore()
+	s.Take("ip:idle", testLimit, testNow) // Заполнится через 6 секунд
+	takeN(s, "ip:busy", 10, testNow)
+	s.Take("ip:busy", testLimit, testNow.Add(50*time.Second)) // Заполнится на 66-й секунде
+
+	// Очистка выполняется не чаще sweepInterval и удаляет только заполнившиеся корзины
+	s.Take("ip:other", testLimit, testNow.Add(30*time.Second))
+	if len(s.buckets) != 3 {
+		t.Fatalf("корзин %d до истечения sweepInterval, ожидалось 3", len(s.buckets))
+	}
+
+	s.Take("ip:other", testLimit, testNow.Add(sweepInterval+time.Second))
+	if _, ok := s.buckets["ip:idle"]; ok {
+		t.Error("заполнившаяся корзина не удалена")
+	}
+	if _, ok := s.buckets["ip:busy"]; !ok {
+		t.Error("удалена корзина, которая еще не заполнилась")
+	}
+
+	// Удаленная корзина создается заново полной
+	if allowed := takeN(s, "ip:idle", 10, testNow.Add(2*time.Minute)); allowed != 10 {
+		t.Fatalf("после очистки разрешено %d запросов, ожидалось 10", allowed)
+	}
+}
+
+func TestLimiterSeparatesReadAndWrite(t *testing.T) {
+	l := NewLimiter(NewMemoryStore(), Limit{Requests: 3, Period: time.Minute}, Limit{Requests: 1, Period: time.Minute})
+
+	if ok, _ := l.Allow("ip:1", true); !ok {
+		t.Fatal("первая запись отклонена")
+	}
+	if ok, retryAfter := l.Allow("ip:1", true); ok || retryAfter <= 0 {
+		t.Fatalf("вторая запись: разрешена=%v, Retry-After %s", ok, retryAfter)
+	}
+	// Бюджет чтения не расходуется записями
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("ip:1", false); !ok {
+			t.Fatalf("чтение %d отклонено", i+1)
+		}
+	}
+
+	// Нулевой лимит не ограничивает запросы
+	unlimited := NewLimiter(NewMemoryStore(), Limit{}, Limit{})
+	for i := 0; i < 100; i++ {
+		if ok, _ := unlimited.Allow("ip:1", true); !ok {
+			t.Fatal("нулевой лимит ограничил запрос")
+		}
+	}
+}
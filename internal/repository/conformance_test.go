@@ -0,0 +1,1359 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"p2pTG-crypto-exchange/internal/model"
+)
+
+// =====================================================
+// ОБЩИЙ НАБОР ПРОВЕРОК РЕАЛИЗАЦИЙ RepositoryInterface
+// =====================================================
+
+// conformanceBackend хранилище, на котором прогоняется набор проверок
+type conformanceBackend struct {
+	// open создает пустое хранилище для одной проверки
+	open func(t *testing.T) RepositoryInterface
+
+	// tracksResponseCount хранилище ведет счетчик откликов в заявке и само переключает
+	// заявку между active и has_responses (в PostgreSQL счетчик не хранится)
+	tracksResponseCount bool
+}
+
+// Все хранилища выполняют многошаговые операции сервиса атомарно
+var (
+	_ Transactional = (*Repository)(nil)
+	_ Transactional = (*FileRepository)(nil)
+	_ Transactional = (*MemoryRepository)(nil)
+	_ Transactional = (*SQLiteRepository)(nil)
+)
+
+// conformanceCase одна проверка набора
+type conformanceCase struct {
+	name string
+	run  func(c *conformance)
+}
+
+// conformanceCases все проверки набора
+var conformanceCases = []conformanceCase{
+	{"Users", testConformanceUsers},
+	{"OrdersCreate", testConformanceOrdersCreate},
+	{"OrdersFilter", testConformanceOrdersFilter},
+	{"OrdersUpdate", testConformanceOrdersUpdate},
+	{"OrdersExpiration", testConformanceOrdersExpiration},
+	{"OrdersReserve", testConformanceOrdersReserve},
+	{"OrderBook", testConformanceOrderBook},
+	{"MatchingOrders", testConformanceMatchingOrders},
+	{"Responses", testConformanceResponses},
+	{"ResponsesFilter", testConformanceResponsesFilter},
+	{"ResponseCounters", testConformanceResponseCounters},
+	{"ResponseReviewOnce", testConformanceResponseReviewOnce},
+	{"Deals", testConformanceDeals},
+	{"DealConfirmation", testConformanceDealConfirmation},
+	{"DealExpiration", testConformanceDealExpiration},
+	{"DealCancellationAndDisputes", testConformanceDealCancellationAndDisputes},
+	{"CompletedTrades", testConformanceCompletedTrades},
+	{"CheckCanReview", testConformanceCheckCanReview},
+	{"Reviews", testConformanceReviews},
+	{"Transactions", testConformanceTransactions},
+	{"Misc", testConformanceMisc},
+}
+
+// runConformance прогоняет все проверки набора на хранилище backend
+func runConformance(t *testing.T, backend conformanceBackend) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	for _, tc := range conformanceCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(&conformance{t: t, repo: backend.open(t), backend: backend})
+		})
+	}
+}
+
+func TestFileRepositoryConformance(t *testing.T) {
+	runConformance(t, conformanceBackend{
+		open: func(t *testing.T) RepositoryInterface {
+			repo, err := NewFileRepository(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { repo.Close() })
+			return repo
+		},
+		tracksResponseCount: true,
+	})
+}
+
+func TestMemoryRepositoryConformance(t *testing.T) {
+	runConformance(t, conformanceBackend{
+		open: func(t *testing.T) RepositoryInterface {
+			return NewMemoryRepository()
+		},
+		tracksResponseCount: true,
+	})
+}
+
+func TestSQLiteRepositoryConformance(t *testing.T) {
+	runConformance(t, conformanceBackend{
+		open: func(t *testing.T) RepositoryInterface {
+			repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "exchange.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { repo.Close() })
+			return repo
+		},
+		tracksResponseCount: true,
+	})
+}
+
+// TestPostgresRepositoryConformance прогоняет набор на PostgreSQL из TEST_DATABASE_URL.
+// База должна быть с примененными миграциями и предназначаться только для тестов: перед каждой проверкой таблицы очищаются
+func TestPostgresRepositoryConformance(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL не задан")
+	}
+
+	runConformance(t, conformanceBackend{
+		open: func(t *testing.T) RepositoryInterface {
+			repo, err := NewRepositoryWithConfig(model.DatabaseConfig{URL: dsn})
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { repo.Close() })
+
+			_, err = repo.db.Exec(`TRUNCATE users, orders, responses, response_offers, deals, deal_events,
+				reviews, ratings, review_reports, assets RESTART IDENTITY CASCADE`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return repo
+		},
+	})
+}
+
+// =====================================================
+// ВСПОМОГАТЕЛЬНЫЕ ФУНКЦИИ
+// =====================================================
+
+// conformance состояние одной проверки: хранилище и счетчик для уникальных Telegram ID
+type conformance struct {
+	t          *testing.T
+	repo       RepositoryInterface
+	backend    conformanceBackend
+	telegramID int64
+}
+
+// must останавливает проверку при ошибке
+func (c *conformance) must(err error) {
+	c.t.Helper()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// expectError проверяет, что операция вернула ошибку с указанным фрагментом текста
+func (c *conformance) expectError(err error, fragment string) {
+	c.t.Helper()
+	if err == nil {
+		c.t.Fatalf("ожидалась ошибка %q, операция выполнена", fragment)
+	}
+	if !strings.Contains(err.Error(), fragment) {
+		c.t.Fatalf("ожидалась ошибка %q, получено: %v", fragment, err)
+	}
+}
+
+// pause разводит временные метки последовательных записей
+func (c *conformance) pause() {
+	time.Sleep(5 * time.Millisecond)
+}
+
+// user создает пользователя с уникальным Telegram ID
+func (c *conformance) user(firstName string) *model.User {
+	c.t.Helper()
+	c.telegramID++
+	user := &model.User{
+		TelegramID: 1000 + c.telegramID,
+		FirstName:  firstName,
+		Username:   strings.ToLower(firstName),
+	}
+	c.must(c.repo.CreateUser(user))
+	return user
+}
+
+// order создает заявку пользователя на пару BTC/RUB; mutate меняет поля перед созданием
+func (c *conformance) order(user *model.User, orderType model.OrderType, price, amount float64, mutate ...func(order *model.Order)) *model.Order {
+	c.t.Helper()
+	order := &model.Order{
+		UserID:          user.ID,
+		Type:            orderType,
+		Cryptocurrency:  "BTC",
+		FiatCurrency:    "RUB",
+		Amount:          amount,
+		Price:           price,
+		TotalAmount:     amount * price,
+		MinAmount:       amount * price / 10,
+		MaxAmount:       amount * price,
+		RemainingAmount: amount,
+		PaymentMethods:  []string{"sberbank"},
+	}
+	for _, fn := range mutate {
+		fn(order)
+	}
+	c.must(c.repo.CreateOrder(order))
+	return order
+}
+
+// getOrder перечитывает заявку из хранилища
+func (c *conformance) getOrder(orderID int64) *model.Order {
+	c.t.Helper()
+	order, err := c.repo.GetOrderByID(orderID)
+	c.must(err)
+	return order
+}
+
+// response создает отклик пользователя на заявку
+func (c *conformance) response(order *model.Order, user *model.User) *model.Response {
+	c.t.Helper()
+	response := &model.Response{
+		OrderID:         order.ID,
+		UserID:          user.ID,
+		Message:         "готов к сделке",
+		RequestedAmount: order.RemainingAmount,
+		PaymentMethod:   "sberbank",
+	}
+	c.must(c.repo.CreateResponse(response))
+	return response
+}
+
+// deal создает сделку по заявке автора с контрагентом (через отклик контрагента)
+func (c *conformance) deal(author, counterparty *model.User, mutate ...func(deal *model.Deal)) *model.Deal {
+	c.t.Helper()
+	order := c.order(author, model.OrderTypeSell, 100, 1)
+	response := c.response(order, counterparty)
+	deal := &model.Deal{
+		ResponseID:     response.ID,
+		OrderID:        order.ID,
+		AuthorID:       author.ID,
+		CounterpartyID: counterparty.ID,
+		Cryptocurrency: order.Cryptocurrency,
+		FiatCurrency:   order.FiatCurrency,
+		Amount:         order.Amount,
+		Price:          order.Price,
+		TotalAmount:    order.TotalAmount,
+		PaymentMethods: order.PaymentMethods,
+		OrderType:      order.Type,
+	}
+	for _, fn := range mutate {
+		fn(deal)
+	}
+	c.must(c.repo.CreateDeal(deal))
+	return deal
+}
+
+// completedDeal создает сделку и завершает ее подтверждениями обеих сторон
+func (c *conformance) completedDeal(author, counterparty *model.User) *model.Deal {
+	c.t.Helper()
+	deal := c.deal(author, counterparty)
+	_, err := c.repo.ConfirmDealWithRole(deal.ID, author.ID, true, "")
+	c.must(err)
+	_, err = c.repo.ConfirmDealWithRole(deal.ID, counterparty.ID, false, "")
+	c.must(err)
+	return c.getDeal(deal.ID)
+}
+
+// getDeal перечитывает сделку из хранилища
+func (c *conformance) getDeal(dealID int64) *model.Deal {
+	c.t.Helper()
+	deal, err := c.repo.GetDealByID(dealID)
+	c.must(err)
+	return deal
+}
+
+// orders получает заявки по фильтру
+func (c *conformance) orders(filter *model.OrderFilter) []*model.Order {
+	c.t.Helper()
+	orders, err := c.repo.GetOrdersByFilter(filter)
+	c.must(err)
+	return orders
+}
+
+// responses получает отклики по фильтру
+func (c *conformance) responses(filter *model.ResponseFilter) []*model.Response {
+	c.t.Helper()
+	responses, err := c.repo.GetResponsesByFilter(filter)
+	c.must(err)
+	return responses
+}
+
+// expectOrderIDs проверяет состав и порядок заявок
+func (c *conformance) expectOrderIDs(orders []*model.Order, want ...*model.Order) {
+	c.t.Helper()
+	got := make([]int64, 0, len(orders))
+	for _, order := range orders {
+		got = append(got, order.ID)
+	}
+	wantIDs := make([]int64, 0, len(want))
+	for _, order := range want {
+		wantIDs = append(wantIDs, order.ID)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(wantIDs) {
+		c.t.Fatalf("заявки %v, ожидались %v", got, wantIDs)
+	}
+}
+
+// expectResponseIDs проверяет состав и порядок откликов
+func (c *conformance) expectResponseIDs(responses []*model.Response, want ...*model.Response) {
+	c.t.Helper()
+	got := make([]int64, 0, len(responses))
+	for _, response := range responses {
+		got = append(got, response.ID)
+	}
+	wantIDs := make([]int64, 0, len(want))
+	for _, response := range want {
+		wantIDs = append(wantIDs, response.ID)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(wantIDs) {
+		c.t.Fatalf("отклики %v, ожидались %v", got, wantIDs)
+	}
+}
+
+// expectDealIDs проверяет состав и порядок сделок
+func (c *conformance) expectDealIDs(deals []*model.Deal, want ...*model.Deal) {
+	c.t.Helper()
+	got := make([]int64, 0, len(deals))
+	for _, deal := range deals {
+		got = append(got, deal.ID)
+	}
+	wantIDs := make([]int64, 0, len(want))
+	for _, deal := range want {
+		wantIDs = append(wantIDs, deal.ID)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(wantIDs) {
+		c.t.Fatalf("сделки %v, ожидались %v", got, wantIDs)
+	}
+}
+
+// sameTime сравнивает время с точностью хранения (микросекунды в PostgreSQL)
+func sameTime(a, b time.Time) bool {
+	diff := a.Sub(b)
+	return diff > -time.Millisecond && diff < time.Millisecond
+}
+
+// closeTo сравнивает рейтинги и проценты с точностью float32 и округления в базе
+func closeTo(got, want float32) bool {
+	return got > want-0.01 && got < want+0.01
+}
+
+// =====================================================
+// ПОЛЬЗОВАТЕЛИ
+// =====================================================
+
+func testConformanceUsers(c *conformance) {
+	member := &model.User{TelegramID: 1, FirstName: "Иван", LastName: "Петров", Username: "ivan", ChatMember: true}
+	c.must(c.repo.CreateUser(member))
+	if member.ID == 0 || !member.IsActive || member.CreatedAt.IsZero() || member.ChatMemberSince == nil {
+		c.t.Fatalf("поля нового пользователя не заполнены: %+v", member)
+	}
+
+	c.expectError(c.repo.CreateUser(&model.User{TelegramID: 1, FirstName: "Дубль"}), "уже существует")
+
+	got, err := c.repo.GetUserByTelegramID(1)
+	c.must(err)
+	if got.ID != member.ID || got.FirstName != "Иван" || got.LastName != "Петров" || got.Username != "ivan" {
+		c.t.Fatalf("GetUserByTelegramID вернул %+v", got)
+	}
+	if !sameTime(got.CreatedAt, member.CreatedAt) || got.ChatMemberSince == nil {
+		c.t.Fatalf("время создания или вступления в чат не сохранено: %+v", got)
+	}
+
+	byID, err := c.repo.GetUserByID(member.ID)
+	c.must(err)
+	if byID.TelegramID != 1 {
+		c.t.Fatalf("GetUserByID вернул %+v", byID)
+	}
+
+	_, err = c.repo.GetUserByTelegramID(999)
+	c.expectError(err, "не найден")
+	_, err = c.repo.GetUserByID(999)
+	c.expectError(err, "не найден")
+
+	// Повторное подтверждение членства не сбрасывает дату вступления
+	since := *got.ChatMemberSince
+	c.pause()
+	c.must(c.repo.UpdateUserChatMembership(1, true))
+	got, _ = c.repo.GetUserByID(member.ID)
+	if got.ChatMemberSince == nil || !sameTime(*got.ChatMemberSince, since) {
+		c.t.Fatalf("дата вступления в чат изменилась: %v -> %v", since, got.ChatMemberSince)
+	}
+
+	c.must(c.repo.UpdateUserChatMembership(1, false))
+	got, _ = c.repo.GetUserByID(member.ID)
+	if got.ChatMember || got.ChatMemberSince != nil {
+		c.t.Fatalf("выход из чата не сохранен: %+v", got)
+	}
+
+	c.must(c.repo.UpdateUserChatMembership(1, true))
+	got, _ = c.repo.GetUserByID(member.ID)
+	if !got.ChatMember || got.ChatMemberSince == nil || !got.ChatMemberSince.After(since) {
+		c.t.Fatalf("повторное вступление в чат не сохранено: %+v", got)
+	}
+	c.expectError(c.repo.UpdateUserChatMembership(999, true), "не найден")
+
+	c.must(c.repo.UpdateUserDealStats(member.ID, true))
+	c.must(c.repo.UpdateUserDealStats(member.ID, false))
+	got, _ = c.repo.GetUserByID(member.ID)
+	if got.TotalDeals != 2 || got.SuccessfulDeals != 1 {
+		c.t.Fatalf("статистика сделок %d/%d, ожидалось 2/1", got.TotalDeals, got.SuccessfulDeals)
+	}
+	c.expectError(c.repo.UpdateUserDealStats(999, true), "не найден")
+}
+
+// =====================================================
+// ЗАЯВКИ
+// =====================================================
+
+func testConformanceOrdersCreate(c *conformance) {
+	author := c.user("Автор")
+	rules := &model.AutoAcceptRules{Enabled: true, MinRating: 4.5, MinCompletedDeals: 3, PaymentMethods: []string{"sberbank"}}
+	order := c.order(author, model.OrderTypeSell, 5_000_000, 0.5, func(order *model.Order) {
+		order.PaymentMethods = []string{"sberbank", "tinkoff"}
+		order.Description = "быстрая сделка"
+		order.AutoAccept = rules
+	})
+
+	if order.ID == 0 || order.Status != model.OrderStatusActive || !order.IsActive {
+		c.t.Fatalf("поля новой заявки не заполнены: %+v", order)
+	}
+	if days := time.Until(order.ExpiresAt).Hours() / 24; days < 364 || days > 366 {
+		c.t.Fatalf("срок действия по умолчанию %v, ожидался год", order.ExpiresAt)
+	}
+
+	got := c.getOrder(order.ID)
+	if got.UserID != author.ID || got.Type != model.OrderTypeSell || got.Cryptocurrency != "BTC" || got.FiatCurrency != "RUB" {
+		c.t.Fatalf("GetOrderByID вернул %+v", got)
+	}
+	if got.Amount != 0.5 || got.Price != 5_000_000 || got.TotalAmount != 2_500_000 || got.RemainingAmount != 0.5 {
+		c.t.Fatalf("суммы заявки не сохранены: %+v", got)
+	}
+	if fmt.Sprint(got.PaymentMethods) != "[sberbank tinkoff]" || got.Description != "быстрая сделка" {
+		c.t.Fatalf("способы оплаты или описание не сохранены: %+v", got)
+	}
+	if got.AutoAccept == nil || got.AutoAccept.MinCompletedDeals != 3 || got.AutoAccept.MinRating != 4.5 {
+		c.t.Fatalf("правила автопринятия не сохранены: %+v", got.AutoAccept)
+	}
+	if got.IsFloating() || !sameTime(got.ExpiresAt, order.ExpiresAt) || !sameTime(got.CreatedAt, order.CreatedAt) {
+		c.t.Fatalf("тип цены или даты заявки не сохранены: %+v", got)
+	}
+
+	// Явно заданный срок действия сохраняется
+	expiresAt := time.Now().Add(48 * time.Hour)
+	short := c.order(author, model.OrderTypeBuy, 100, 1, func(order *model.Order) { order.ExpiresAt = expiresAt })
+	if got := c.getOrder(short.ID); !sameTime(got.ExpiresAt, expiresAt) {
+		c.t.Fatalf("срок действия %v, ожидался %v", got.ExpiresAt, expiresAt)
+	}
+
+	_, err := c.repo.GetOrderByID(999)
+	c.expectError(err, "не найдена")
+}
+
+func testConformanceOrdersFilter(c *conformance) {
+	alice, bob := c.user("Алиса"), c.user("Боб")
+	sellCheap := c.order(alice, model.OrderTypeSell, 100, 3)
+	c.pause()
+	buy := c.order(bob, model.OrderTypeBuy, 300, 1)
+	c.pause()
+	sellSame := c.order(bob, model.OrderTypeSell, 100, 2)
+	c.pause()
+	usdt := c.order(alice, model.OrderTypeSell, 200, 5, func(order *model.Order) { order.Cryptocurrency = "USDT" })
+	c.pause()
+	cancelled := c.order(alice, model.OrderTypeSell, 150, 4)
+	c.must(c.repo.UpdateOrderStatus(cancelled.ID, model.OrderStatusCancelled))
+
+	// По умолчанию только активные заявки, новые сначала
+	c.expectOrderIDs(c.orders(&model.OrderFilter{}), usdt, sellSame, buy, sellCheap)
+	c.expectOrderIDs(c.orders(&model.OrderFilter{IncludeInactive: true}), cancelled, usdt, sellSame, buy, sellCheap)
+
+	sell, rub, btc := model.OrderTypeSell, "RUB", "BTC"
+	c.expectOrderIDs(c.orders(&model.OrderFilter{Type: &sell}), usdt, sellSame, sellCheap)
+	c.expectOrderIDs(c.orders(&model.OrderFilter{Cryptocurrency: &btc, FiatCurrency: &rub}), sellSame, buy, sellCheap)
+	c.expectOrderIDs(c.orders(&model.OrderFilter{UserID: &alice.ID}), usdt, sellCheap)
+
+	status := model.OrderStatusCancelled
+	c.expectOrderIDs(c.orders(&model.OrderFilter{Status: &status}))
+	c.expectOrderIDs(c.orders(&model.OrderFilter{Status: &status, IncludeInactive: true}), cancelled)
+
+	// Границы дат включительно
+	after, before := buy.CreatedAt, sellSame.CreatedAt
+	c.expectOrderIDs(c.orders(&model.OrderFilter{CreatedAfter: &after, CreatedBefore: &before}), sellSame, buy)
+
+	// Сортировка: при равной цене заявки идут в порядке создания
+	c.expectOrderIDs(c.orders(&model.OrderFilter{SortBy: "price", SortOrder: "asc"}), sellCheap, sellSame, usdt, buy)
+	c.expectOrderIDs(c.orders(&model.OrderFilter{SortBy: "price", SortOrder: "desc"}), buy, usdt, sellCheap, sellSame)
+	c.expectOrderIDs(c.orders(&model.OrderFilter{SortBy: "amount", SortOrder: "asc"}), buy, sellSame, sellCheap, usdt)
+	c.expectOrderIDs(c.orders(&model.OrderFilter{SortBy: "created_at", SortOrder: "asc"}), sellCheap, buy, sellSame, usdt)
+
+	// Пагинация
+	c.expectOrderIDs(c.orders(&model.OrderFilter{SortBy: "price", SortOrder: "asc", Limit: 2}), sellCheap, sellSame)
+	c.expectOrderIDs(c.orders(&model.OrderFilter{SortBy: "price", SortOrder: "asc", Limit: 2, Offset: 2}), usdt, buy)
+	c.expectOrderIDs(c.orders(&model.OrderFilter{SortBy: "price", SortOrder: "asc", Offset: 3}), buy)
+	c.expectOrderIDs(c.orders(&model.OrderFilter{Offset: 10}))
+}
+
+func testConformanceOrdersUpdate(c *conformance) {
+	author := c.user("Автор")
+	order := c.order(author, model.OrderTypeSell, 100, 2)
+
+	order.Price = 120
+	order.Amount = 3
+	order.TotalAmount = 360
+	order.RemainingAmount = 3
+	order.PaymentMethods = []string{"tinkoff"}
+	order.Description = "новое описание"
+	order.PriceType = model.PriceTypeFloating
+	order.PriceMargin = 1.5
+	c.pause()
+	c.must(c.repo.UpdateOrder(order))
+
+	got := c.getOrder(order.ID)
+	if got.Price != 120 || got.Amount != 3 || got.TotalAmount != 360 || got.RemainingAmount != 3 {
+		c.t.Fatalf("суммы заявки не обновлены: %+v", got)
+	}
+	if fmt.Sprint(got.PaymentMethods) != "[tinkoff]" || got.Description != "новое описание" {
+		c.t.Fatalf("способы оплаты или описание не обновлены: %+v", got)
+	}
+	if !got.IsFloating() || got.PriceMargin != 1.5 || !got.UpdatedAt.After(got.CreatedAt) {
+		c.t.Fatalf("плавающая цена или время обновления не сохранены: %+v", got)
+	}
+
+	c.must(c.repo.UpdateOrderPrice(order.ID, 150))
+	if got := c.getOrder(order.ID); got.Price != 150 || got.TotalAmount != 450 {
+		c.t.Fatalf("цена %v и сумма %v, ожидались 150 и 450", got.Price, got.TotalAmount)
+	}
+
+	// После частичного исполнения сумма считается по остатку
+	_, err := c.repo.ReserveOrderAmount(order.ID, 1)
+	c.must(err)
+	c.must(c.repo.UpdateOrderPrice(order.ID, 160))
+	if got := c.getOrder(order.ID); got.Price != 160 || got.TotalAmount != 320 {
+		c.t.Fatalf("цена %v и сумма %v, ожидались 160 и 320", got.Price, got.TotalAmount)
+	}
+
+	c.must(c.repo.UpdateOrderStatus(order.ID, model.OrderStatusInDeal))
+	if got := c.getOrder(order.ID); got.Status != model.OrderStatusInDeal || !got.IsActive {
+		c.t.Fatalf("заявка в сделке должна остаться активной: %+v", got)
+	}
+	c.must(c.repo.UpdateOrderStatus(order.ID, model.OrderStatusCompleted))
+	if got := c.getOrder(order.ID); got.Status != model.OrderStatusCompleted || got.IsActive {
+		c.t.Fatalf("завершенная заявка должна стать неактивной: %+v", got)
+	}
+
+	c.expectError(c.repo.UpdateOrderStatus(999, model.OrderStatusCancelled), "не найдена")
+	c.expectError(c.repo.UpdateOrder(&model.Order{ID: 999}), "не найдена")
+}
+
+func testConformanceOrdersExpiration(c *conformance) {
+	author := c.user("Автор")
+	now := time.Now()
+	expired := c.order(author, model.OrderTypeSell, 100, 1, func(order *model.Order) { order.ExpiresAt = now.Add(-time.Hour) })
+	fresh := c.order(author, model.OrderTypeSell, 100, 1, func(order *model.Order) { order.ExpiresAt = now.Add(time.Hour) })
+	inDeal := c.order(author, model.OrderTypeSell, 100, 1, func(order *model.Order) { order.ExpiresAt = now.Add(-time.Hour) })
+	c.must(c.repo.UpdateOrderStatus(inDeal.ID, model.OrderStatusInDeal))
+
+	orders, err := c.repo.GetExpiredOrders(now)
+	c.must(err)
+	c.expectOrderIDs(orders, expired)
+
+	c.expectError(c.repo.ExpireOrder(fresh.ID, now), "не может быть истекшей")
+	c.expectError(c.repo.ExpireOrder(inDeal.ID, now), "не может быть истекшей")
+	c.must(c.repo.ExpireOrder(expired.ID, now))
+	if got := c.getOrder(expired.ID); got.Status != model.OrderStatusExpired || got.IsActive {
+		c.t.Fatalf("истекшая заявка: %+v", got)
+	}
+	c.expectError(c.repo.ExpireOrder(expired.ID, now), "не может быть истекшей")
+
+	// Продление срока
+	extended := now.Add(72 * time.Hour)
+	c.must(c.repo.UpdateOrderExpiration(fresh.ID, extended))
+	if got := c.getOrder(fresh.ID); !sameTime(got.ExpiresAt, extended) {
+		c.t.Fatalf("срок %v, ожидался %v", got.ExpiresAt, extended)
+	}
+	orders, err = c.repo.GetExpiredOrders(now.Add(48 * time.Hour))
+	c.must(err)
+	c.expectOrderIDs(orders)
+}
+
+func testConformanceOrdersReserve(c *conformance) {
+	author := c.user("Автор")
+	order := c.order(author, model.OrderTypeSell, 100, 1)
+
+	reserved, err := c.repo.ReserveOrderAmount(order.ID, 0.3)
+	c.must(err)
+	if reserved.RemainingAmount != 0.7 || reserved.Status != model.OrderStatusActive {
+		c.t.Fatalf("после частичного резерва: остаток %v, статус %s", reserved.RemainingAmount, reserved.Status)
+	}
+
+	_, err = c.repo.ReserveOrderAmount(order.ID, 0.8)
+	c.expectError(err, "осталось только")
+
+	reserved, err = c.repo.ReserveOrderAmount(order.ID, 0.7)
+	c.must(err)
+	if reserved.RemainingAmount != 0 || reserved.Status != model.OrderStatusInDeal {
+		c.t.Fatalf("после полного резерва: остаток %v, статус %s", reserved.RemainingAmount, reserved.Status)
+	}
+	if got := c.getOrder(order.ID); got.RemainingAmount != 0 || got.Status != model.OrderStatusInDeal {
+		c.t.Fatalf("резерв не сохранен: %+v", got)
+	}
+
+	_, err = c.repo.ReserveOrderAmount(order.ID, 0.1)
+	c.expectError(err, "недоступна для сделок")
+
+	// Возврат объема возвращает заявку на рынок, но не больше исходного объема
+	c.must(c.repo.ReleaseOrderAmount(order.ID, 0.7))
+	if got := c.getOrder(order.ID); got.RemainingAmount != 0.7 || got.Status != model.OrderStatusActive {
+		c.t.Fatalf("после возврата: остаток %v, статус %s", got.RemainingAmount, got.Status)
+	}
+	c.must(c.repo.ReleaseOrderAmount(order.ID, 5))
+	if got := c.getOrder(order.ID); got.RemainingAmount != 1 {
+		c.t.Fatalf("остаток %v больше объема заявки", got.RemainingAmount)
+	}
+
+	_, err = c.repo.ReserveOrderAmount(999, 0.1)
+	c.expectError(err, "не найдена")
+}
+
+func testConformanceOrderBook(c *conformance) {
+	alice, bob := c.user("Алиса"), c.user("Боб")
+	c.order(alice, model.OrderTypeSell, 101, 1)
+	c.order(bob, model.OrderTypeSell, 101, 0.5, func(order *model.Order) { order.PaymentMethods = []string{"tinkoff"} })
+	c.order(alice, model.OrderTypeSell, 103, 2)
+	c.order(alice, model.OrderTypeSell, 102, 1)
+	c.order(bob, model.OrderTypeBuy, 99, 1)
+	c.order(bob, model.OrderTypeBuy, 98, 3)
+	c.order(bob, model.OrderTypeBuy, 100, 1, func(order *model.Order) { order.ExpiresAt = time.Now().Add(-time.Minute) })
+	c.order(bob, model.OrderTypeBuy, 97, 1, func(order *model.Order) { order.Cryptocurrency = "ETH" })
+	filled := c.order(alice, model.OrderTypeSell, 100, 1)
+	_, err := c.repo.ReserveOrderAmount(filled.ID, 1)
+	c.must(err)
+
+	book, err := c.repo.GetOrderBook(&model.OrderBookFilter{Cryptocurrency: "BTC", FiatCurrency: "RUB", Depth: 2})
+	c.must(err)
+	if len(book.Asks) != 2 || len(book.Bids) != 2 {
+		c.t.Fatalf("стакан %d/%d уровней, ожидалось 2/2", len(book.Asks), len(book.Bids))
+	}
+	if ask := book.Asks[0]; ask.Price != 101 || ask.Amount != 1.5 || ask.OrderCount != 2 || ask.Total != 151.5 {
+		c.t.Fatalf("лучший уровень продажи %+v", ask)
+	}
+	if book.Asks[1].Price != 102 || book.Bids[0].Price != 99 || book.Bids[1].Price != 98 {
+		c.t.Fatalf("порядок уровней: продажи %+v, покупки %+v", book.Asks, book.Bids)
+	}
+
+	book, err = c.repo.GetOrderBook(&model.OrderBookFilter{Cryptocurrency: "BTC", FiatCurrency: "RUB", PaymentMethod: "tinkoff", Depth: 10})
+	c.must(err)
+	if len(book.Asks) != 1 || book.Asks[0].Amount != 0.5 || len(book.Bids) != 0 {
+		c.t.Fatalf("стакан по способу оплаты: продажи %+v, покупки %+v", book.Asks, book.Bids)
+	}
+
+	book, err = c.repo.GetOrderBook(&model.OrderBookFilter{Cryptocurrency: "TON", FiatCurrency: "RUB", Depth: 10})
+	c.must(err)
+	if book.Asks == nil || book.Bids == nil || len(book.Asks)+len(book.Bids) != 0 {
+		c.t.Fatalf("пустой стакан должен содержать пустые уровни: %+v", book)
+	}
+}
+
+func testConformanceMatchingOrders(c *conformance) {
+	alice, bob, carol := c.user("Алиса"), c.user("Боб"), c.user("Кэрол")
+	buy := c.order(alice, model.OrderTypeBuy, 100, 1)
+	c.order(alice, model.OrderTypeSell, 90, 1) // Своя заявка
+	c.order(bob, model.OrderTypeSell, 110, 1)  // Дороже цены заявки
+	later := c.order(bob, model.OrderTypeSell, 95, 1)
+	c.pause()
+	best := c.order(carol, model.OrderTypeSell, 92, 1)
+	c.pause()
+	samePriceLater := c.order(carol, model.OrderTypeSell, 95, 1)
+	c.order(bob, model.OrderTypeSell, 93, 1, func(order *model.Order) { order.FiatCurrency = "USD" })
+	cancelled := c.order(bob, model.OrderTypeSell, 91, 1)
+	c.must(c.repo.UpdateOrderStatus(cancelled.ID, model.OrderStatusCancelled))
+
+	matches, err := c.repo.GetMatchingOrders(buy)
+	c.must(err)
+	c.expectOrderIDs(matches, best, later, samePriceLater)
+
+	// Для продажи подходят покупки не дешевле ее цены, лучшая - самая дорогая
+	sell := c.order(carol, model.OrderTypeSell, 99, 1)
+	higher := c.order(bob, model.OrderTypeBuy, 105, 1)
+	matches, err = c.repo.GetMatchingOrders(sell)
+	c.must(err)
+	c.expectOrderIDs(matches, higher, buy)
+
+	for i := 0; i < 12; i++ {
+		c.order(bob, model.OrderTypeBuy, 120, 1)
+	}
+	matches, err = c.repo.GetMatchingOrders(sell)
+	c.must(err)
+	if len(matches) != 10 {
+		c.t.Fatalf("найдено %d встречных заявок, ожидалось не больше 10", len(matches))
+	}
+}
+
+// =====================================================
+// ОТКЛИКИ
+// =====================================================
+
+func testConformanceResponses(c *conformance) {
+	author, trader := c.user("Автор"), c.user("Трейдер")
+	order := c.order(author, model.OrderTypeSell, 100, 1)
+
+	response := &model.Response{OrderID: order.ID, UserID: trader.ID, Message: "куплю", RequestedAmount: 0.5,
+		ProposedPrice: 95, PaymentMethod: "sberbank", Status: model.ResponseStatusAccepted}
+	c.must(c.repo.CreateResponse(response))
+	if response.ID == 0 || response.Status != model.ResponseStatusWaiting || response.CreatedAt.IsZero() {
+		c.t.Fatalf("поля нового отклика не заполнены: %+v", response)
+	}
+	c.expectError(c.repo.CreateResponse(&model.Response{OrderID: order.ID, UserID: trader.ID}), "уже откликались")
+
+	got := c.responses(&model.ResponseFilter{OrderID: &order.ID})
+	c.expectResponseIDs(got, response)
+	if r := got[0]; r.Message != "куплю" || r.RequestedAmount != 0.5 || r.ProposedPrice != 95 ||
+		r.PaymentMethod != "sberbank" || r.Status != model.ResponseStatusWaiting || r.ReviewedAt != nil {
+		c.t.Fatalf("отклик не сохранен: %+v", r)
+	}
+	byID, err := c.repo.GetResponseByID(response.ID)
+	c.must(err)
+	if byID.ID != response.ID || byID.OrderID != order.ID || byID.UserID != trader.ID || byID.Message != "куплю" ||
+		byID.RequestedAmount != 0.5 || byID.Status != model.ResponseStatusWaiting {
+		c.t.Fatalf("отклик по ID: %+v", byID)
+	}
+	_, err = c.repo.GetResponseByID(999)
+	c.expectError(err, "не найден")
+
+	// Рассмотрение отклика
+	c.must(c.repo.UpdateResponseStatus(response.ID, model.ResponseStatusRejected))
+	got = c.responses(&model.ResponseFilter{OrderID: &order.ID})
+	if got[0].Status != model.ResponseStatusRejected || got[0].ReviewedAt == nil {
+		c.t.Fatalf("рассмотренный отклик: %+v", got[0])
+	}
+	c.expectError(c.repo.UpdateResponseStatus(999, model.ResponseStatusRejected), "не найден")
+
+	// Повторный отклик на тех же условиях возвращает его на рассмотрение
+	response.Message = "передумал"
+	response.ProposedPrice = 98
+	response.RequestedAmount = 0.4
+	c.must(c.repo.ReopenResponse(response))
+	got = c.responses(&model.ResponseFilter{OrderID: &order.ID})
+	if r := got[0]; r.Status != model.ResponseStatusWaiting || r.ReviewedAt != nil || r.Message != "передумал" ||
+		r.ProposedPrice != 98 || r.RequestedAmount != 0.4 {
+		c.t.Fatalf("возобновленный отклик: %+v", r)
+	}
+
+	// Встречное предложение
+	c.must(c.repo.UpdateResponseTerms(response.ID, model.ResponseStatusCountered, 99, 0.3))
+	got = c.responses(&model.ResponseFilter{OrderID: &order.ID})
+	if r := got[0]; r.Status != model.ResponseStatusCountered || r.ProposedPrice != 99 || r.RequestedAmount != 0.3 {
+		c.t.Fatalf("условия отклика: %+v", r)
+	}
+	c.expectError(c.repo.UpdateResponseTerms(999, model.ResponseStatusCountered, 1, 1), "не найден")
+
+	first := &model.ResponseOffer{ResponseID: response.ID, UserID: trader.ID, Price: 95, Amount: 0.5, Message: "первое"}
+	second := &model.ResponseOffer{ResponseID: response.ID, UserID: author.ID, Price: 99, Amount: 0.3, Message: "встречное"}
+	c.must(c.repo.AddResponseOffer(first))
+	c.must(c.repo.AddResponseOffer(second))
+	if first.ID == 0 || first.CreatedAt.IsZero() {
+		c.t.Fatalf("поля предложения не заполнены: %+v", first)
+	}
+	offers, err := c.repo.GetResponseOffers(response.ID)
+	c.must(err)
+	if len(offers) != 2 || offers[0].ID != first.ID || offers[1].ID != second.ID || offers[1].Message != "встречное" {
+		c.t.Fatalf("история предложений: %+v", offers)
+	}
+
+	// Отзыв отклика
+	c.must(c.repo.WithdrawResponse(response.ID))
+	got = c.responses(&model.ResponseFilter{OrderID: &order.ID})
+	if got[0].Status != model.ResponseStatusWithdrawn {
+		c.t.Fatalf("отозванный отклик: %+v", got[0])
+	}
+	c.expectError(c.repo.WithdrawResponse(response.ID), "рассмотрен")
+}
+
+func testConformanceResponsesFilter(c *conformance) {
+	alice, bob, carol := c.user("Алиса"), c.user("Боб"), c.user("Кэрол")
+	aliceOrder := c.order(alice, model.OrderTypeSell, 100, 1)
+	bobOrder := c.order(bob, model.OrderTypeBuy, 100, 1)
+
+	first := c.response(aliceOrder, bob)
+	c.pause()
+	second := c.response(aliceOrder, carol)
+	c.pause()
+	third := c.response(bobOrder, carol)
+	c.pause()
+	c.must(c.repo.UpdateResponseStatus(first.ID, model.ResponseStatusRejected))
+
+	// Без сортировки - в порядке создания
+	c.expectResponseIDs(c.responses(&model.ResponseFilter{}), first, second, third)
+	c.expectResponseIDs(c.responses(&model.ResponseFilter{OrderID: &aliceOrder.ID}), first, second)
+	c.expectResponseIDs(c.responses(&model.ResponseFilter{UserID: &carol.ID}), second, third)
+	c.expectResponseIDs(c.responses(&model.ResponseFilter{AuthorID: &bob.ID}), third)
+
+	waiting := model.ResponseStatusWaiting
+	c.expectResponseIDs(c.responses(&model.ResponseFilter{Status: &waiting}), second, third)
+
+	after, before := second.CreatedAt, third.CreatedAt
+	c.expectResponseIDs(c.responses(&model.ResponseFilter{CreatedAfter: &after}), second, third)
+	c.expectResponseIDs(c.responses(&model.ResponseFilter{CreatedBefore: &before, CreatedAfter: &after}), second, third)
+	c.expectResponseIDs(c.responses(&model.ResponseFilter{CreatedBefore: &after}), first, second)
+
+	c.expectResponseIDs(c.responses(&model.ResponseFilter{SortBy: "created_at", SortOrder: "desc"}), third, second, first)
+	c.expectResponseIDs(c.responses(&model.ResponseFilter{SortBy: "created_at", SortOrder: "asc"}), first, second, third)
+	c.expectResponseIDs(c.responses(&model.ResponseFilter{SortBy: "updated_at", SortOrder: "desc"}), first, third, second)
+
+	// Пагинация
+	c.expectResponseIDs(c.responses(&model.ResponseFilter{Limit: 2}), first, second)
+	c.expectResponseIDs(c.responses(&model.ResponseFilter{Limit: 2, Offset: 2}), third)
+	c.expectResponseIDs(c.responses(&model.ResponseFilter{Offset: 1}), second, third)
+	c.expectResponseIDs(c.responses(&model.ResponseFilter{Offset: 3}))
+
+	// Готовые выборки - новые сначала
+	forOrder, err := c.repo.GetResponsesForOrder(aliceOrder.ID)
+	c.must(err)
+	c.expectResponseIDs(forOrder, second, first)
+	fromUser, err := c.repo.GetResponsesFromUser(carol.ID)
+	c.must(err)
+	c.expectResponseIDs(fromUser, third, second)
+	forAuthor, err := c.repo.GetResponsesForAuthor(alice.ID)
+	c.must(err)
+	c.expectResponseIDs(forAuthor, second, first)
+}
+
+func testConformanceResponseCounters(c *conformance) {
+	if !c.backend.tracksResponseCount {
+		c.t.Skip("хранилище не ведет счетчик откликов в заявке")
+	}
+
+	author, bob, carol := c.user("Автор"), c.user("Боб"), c.user("Кэрол")
+	order := c.order(author, model.OrderTypeSell, 100, 1)
+	expect := func(count int, status model.OrderStatus) {
+		c.t.Helper()
+		got := c.getOrder(order.ID)
+		if got.ResponseCount != count || got.Status != status {
+			c.t.Fatalf("откликов %d в статусе %s, ожидалось %d в %s", got.ResponseCount, got.Status, count, status)
+		}
+	}
+
+	bobResponse := c.response(order, bob)
+	expect(1, model.OrderStatusHasResponses)
+	carolResponse := c.response(order, carol)
+	expect(2, model.OrderStatusHasResponses)
+
+	c.must(c.repo.WithdrawResponse(bobResponse.ID))
+	expect(1, model.OrderStatusHasResponses)
+	c.must(c.repo.WithdrawResponse(carolResponse.ID))
+	expect(0, model.OrderStatusActive)
+
+	// Возобновление отозванного отклика снова учитывается в счетчике
+	c.must(c.repo.ReopenResponse(bobResponse))
+	expect(1, model.OrderStatusHasResponses)
+
+	// Отклонение не меняет счетчик; возобновление отклоненного - тоже
+	c.must(c.repo.UpdateResponseStatus(bobResponse.ID, model.ResponseStatusRejected))
+	expect(1, model.OrderStatusHasResponses)
+	c.must(c.repo.ReopenResponse(bobResponse))
+	expect(1, model.OrderStatusHasResponses)
+}
+
+func testConformanceResponseReviewOnce(c *conformance) {
+	author, bob, carol := c.user("Автор"), c.user("Боб"), c.user("Кэрол")
+	order := c.order(author, model.OrderTypeSell, 100, 1)
+
+	// Рассмотренный отклик нельзя принять, отклонить или отозвать повторно
+	response := c.response(order, bob)
+	c.must(c.repo.UpdateResponseStatus(response.ID, model.ResponseStatusAccepted))
+	c.expectError(c.repo.UpdateResponseStatus(response.ID, model.ResponseStatusAccepted), "рассмотрен")
+	c.expectError(c.repo.UpdateResponseStatus(response.ID, model.ResponseStatusRejected), "рассмотрен")
+	c.expectError(c.repo.WithdrawResponse(response.ID), "рассмотрен")
+	got := c.responses(&model.ResponseFilter{OrderID: &order.ID})
+	if got[0].Status != model.ResponseStatusAccepted {
+		c.t.Fatalf("статус принятого отклика изменился: %+v", got[0])
+	}
+
+	// Отклик со встречным предложением еще открыт
+	countered := c.response(order, carol)
+	c.must(c.repo.UpdateResponseTerms(countered.ID, model.ResponseStatusCountered, 99, 0.5))
+	c.must(c.repo.UpdateResponseStatus(countered.ID, model.ResponseStatusRejected))
+	c.expectError(c.repo.UpdateResponseStatus(countered.ID, model.ResponseStatusAccepted), "рассмотрен")
+
+	transactional, ok := c.repo.(Transactional)
+	if !ok {
+		return
+	}
+
+	// Принятие как в сервисе: резерв объема и принятие отклика в одной транзакции.
+	// Второе принятие того же отклика откатывает свой резерв
+	second := c.order(author, model.OrderTypeSell, 100, 1)
+	response = c.response(second, bob)
+	accept := func() error {
+		return transactional.InTransaction(func(tx RepositoryInterface) error {
+			if _, err := tx.ReserveOrderAmount(second.ID, 0.3); err != nil {
+				return err
+			}
+			return tx.UpdateResponseStatus(response.ID, model.ResponseStatusAccepted)
+		})
+	}
+	c.must(accept())
+	c.expectError(accept(), "рассмотрен")
+	if got := c.getOrder(second.ID); !closeTo(float32(got.RemainingAmount), 0.7) {
+		c.t.Fatalf("остаток после повторного принятия %v, ожидался 0.7", got.RemainingAmount)
+	}
+}
+
+// =====================================================
+// СДЕЛКИ
+// =====================================================
+
+func testConformanceDeals(c *conformance) {
+	alice, bob, carol := c.user("Алиса"), c.user("Боб"), c.user("Кэрол")
+
+	expiresAt := time.Now().Add(30 * time.Minute)
+	first := c.deal(alice, bob, func(deal *model.Deal) {
+		deal.ExpiresAt = expiresAt
+		deal.Notes = "заметка"
+	})
+	if first.ID == 0 || first.Status != model.DealStatusInProgress || first.CreatedAt.IsZero() {
+		c.t.Fatalf("поля новой сделки не заполнены: %+v", first)
+	}
+
+	got := c.getDeal(first.ID)
+	if got.AuthorID != alice.ID || got.CounterpartyID != bob.ID || got.OrderID != first.OrderID ||
+		got.ResponseID != first.ResponseID || got.Amount != 1 || got.Price != 100 || got.TotalAmount != 100 {
+		c.t.Fatalf("GetDealByID вернул %+v", got)
+	}
+	if fmt.Sprint(got.PaymentMethods) != "[sberbank]" || got.OrderType != model.OrderTypeSell || got.Notes != "заметка" {
+		c.t.Fatalf("способы оплаты, тип или заметки сделки не сохранены: %+v", got)
+	}
+	if !sameTime(got.ExpiresAt, expiresAt) || got.CompletedAt != nil || got.AuthorConfirmed || got.CounterConfirmed {
+		c.t.Fatalf("срок или подтверждения сделки: %+v", got)
+	}
+
+	c.pause()
+	second := c.deal(carol, alice)
+	c.pause()
+	third := c.deal(bob, carol)
+
+	deals, err := c.repo.GetDealsByUserID(alice.ID)
+	c.must(err)
+	c.expectDealIDs(deals, second, first)
+	deals, err = c.repo.GetDealsByUserID(carol.ID)
+	c.must(err)
+	c.expectDealIDs(deals, third, second)
+
+	_, err = c.repo.GetDealByID(999)
+	c.expectError(err, "не найдена")
+
+	// Хронология сделки
+	created := &model.DealEvent{DealID: first.ID, UserID: alice.ID, Type: model.DealEventCancelRequested,
+		Status: model.DealStatusInProgress, Message: "первое событие"}
+	c.must(c.repo.AddDealEvent(created))
+	if created.ID == 0 || created.CreatedAt.IsZero() {
+		c.t.Fatalf("поля события не заполнены: %+v", created)
+	}
+	cancelled := &model.DealEvent{DealID: first.ID, Type: model.DealEventCancelled, Status: model.DealStatusCancelled}
+	c.must(c.repo.AddDealEvent(cancelled))
+	c.must(c.repo.AddDealEvent(&model.DealEvent{DealID: second.ID, Type: model.DealEventCancelled, Status: model.DealStatusCancelled}))
+
+	events, err := c.repo.GetDealEvents(first.ID)
+	c.must(err)
+	if len(events) != 2 || events[0].ID != created.ID || events[1].ID != cancelled.ID ||
+		events[0].Message != "первое событие" || events[0].UserID != alice.ID {
+		c.t.Fatalf("хронология сделки: %+v", events)
+	}
+}
+
+func testConformanceDealConfirmation(c *conformance) {
+	alice, bob := c.user("Алиса"), c.user("Боб")
+	deal := c.deal(alice, bob)
+
+	transition, err := c.repo.ConfirmDealWithRole(deal.ID, bob.ID, false, "чек-1")
+	c.must(err)
+	if transition.To != model.DealStatusWaitingConfirmation {
+		c.t.Fatalf("первое подтверждение перевело сделку в %s", transition.To)
+	}
+	got := c.getDeal(deal.ID)
+	if got.Status != model.DealStatusWaitingConfirmation || !got.CounterConfirmed || got.CounterProof != "чек-1" || got.AuthorConfirmed {
+		c.t.Fatalf("после подтверждения контрагентом: %+v", got)
+	}
+
+	// Повторное подтверждение той же стороной не допускается
+	_, err = c.repo.ConfirmDealWithRole(deal.ID, bob.ID, false, "")
+	if err == nil {
+		c.t.Fatal("повторное подтверждение той же стороной выполнено")
+	}
+
+	transition, err = c.repo.ConfirmDealWithRole(deal.ID, alice.ID, true, "чек-2")
+	c.must(err)
+	if transition.To != model.DealStatusCompleted {
+		c.t.Fatalf("второе подтверждение перевело сделку в %s", transition.To)
+	}
+	got = c.getDeal(deal.ID)
+	if got.Status != model.DealStatusCompleted || !got.AuthorConfirmed || got.AuthorProof != "чек-2" || got.CompletedAt == nil {
+		c.t.Fatalf("после подтверждения обеими сторонами: %+v", got)
+	}
+
+	_, err = c.repo.ConfirmDealWithRole(deal.ID, alice.ID, true, "")
+	if err == nil {
+		c.t.Fatal("подтверждение завершенной сделки выполнено")
+	}
+	_, err = c.repo.ConfirmDealWithRole(999, alice.ID, true, "")
+	c.expectError(err, "не найдена")
+}
+
+func testConformanceDealExpiration(c *conformance) {
+	alice, bob := c.user("Алиса"), c.user("Боб")
+	now := time.Now()
+	overdue := c.deal(alice, bob, func(deal *model.Deal) { deal.ExpiresAt = now.Add(-time.Minute) })
+	soon := c.deal(alice, bob, func(deal *model.Deal) { deal.ExpiresAt = now.Add(5 * time.Minute) })
+	later := c.deal(alice, bob, func(deal *model.Deal) { deal.ExpiresAt = now.Add(time.Hour) })
+	confirmed := c.deal(alice, bob, func(deal *model.Deal) { deal.ExpiresAt = now.Add(-time.Minute) })
+	c.deal(alice, bob) // Без срока
+	_, err := c.repo.ConfirmDealWithRole(confirmed.ID, alice.ID, true, "")
+	c.must(err)
+
+	deals, err := c.repo.GetUnconfirmedDealsExpiringBefore(now.Add(10 * time.Minute))
+	c.must(err)
+	c.expectDealIDs(deals, overdue, soon)
+
+	c.must(c.repo.MarkDealExpiryWarningSent(soon.ID))
+	if got := c.getDeal(soon.ID); !got.ExpiryWarningSent {
+		c.t.Fatal("отметка о предупреждении не сохранена")
+	}
+
+	c.expectError(c.repo.ExpireDeal(later.ID, now), "еще не истек")
+	if c.repo.ExpireDeal(confirmed.ID, now) == nil {
+		c.t.Fatal("подтвержденная сделка истекла")
+	}
+	c.must(c.repo.ExpireDeal(overdue.ID, now))
+	if got := c.getDeal(overdue.ID); got.Status != model.DealStatusExpired {
+		c.t.Fatalf("просроченная сделка в статусе %s", got.Status)
+	}
+	if got := c.getDeal(confirmed.ID); got.Status != model.DealStatusWaitingConfirmation {
+		c.t.Fatalf("подтвержденная сделка истекла: %s", got.Status)
+	}
+	if c.repo.ExpireDeal(overdue.ID, now) == nil {
+		c.t.Fatal("повторное истечение сделки выполнено")
+	}
+
+	deals, err = c.repo.GetUnconfirmedDealsExpiringBefore(now.Add(10 * time.Minute))
+	c.must(err)
+	c.expectDealIDs(deals, soon)
+}
+
+func testConformanceDealCancellationAndDisputes(c *conformance) {
+	alice, bob, admin := c.user("Алиса"), c.user("Боб"), c.user("Админ")
+	now := time.Now()
+
+	cancelled := c.deal(alice, bob)
+	c.must(c.repo.RequestDealCancellation(cancelled.ID, bob.ID, "передумал", now))
+	got := c.getDeal(cancelled.ID)
+	if got.Status != model.DealStatusInProgress || got.CancelRequestedBy != bob.ID || got.CancelRequestedAt == nil || got.CancelReason != "передумал" {
+		c.t.Fatalf("запрос отмены: %+v", got)
+	}
+	c.must(c.repo.CancelDeal(cancelled.ID, alice.ID, "по согласию", now))
+	got = c.getDeal(cancelled.ID)
+	if got.Status != model.DealStatusCancelled || got.CancelledBy != alice.ID || got.CancelledAt == nil || got.CancelReason != "по согласию" {
+		c.t.Fatalf("отмена сделки: %+v", got)
+	}
+	if c.repo.CancelDeal(cancelled.ID, alice.ID, "", now) == nil {
+		c.t.Fatal("повторная отмена выполнена")
+	}
+
+	disputed := c.deal(alice, bob)
+	refunded := c.deal(alice, bob)
+	c.must(c.repo.OpenDealDispute(disputed.ID, bob.ID, "нет оплаты", "скриншот", now))
+	c.must(c.repo.OpenDealDispute(refunded.ID, alice.ID, "нет перевода", "", now))
+	got = c.getDeal(disputed.ID)
+	if got.Status != model.DealStatusDispute || got.DisputeOpenedBy != bob.ID || got.DisputeOpenedAt == nil ||
+		got.DisputeReason != "нет оплаты" || got.DisputeEvidence != "скриншот" {
+		c.t.Fatalf("открытый спор: %+v", got)
+	}
+	if c.repo.OpenDealDispute(disputed.ID, bob.ID, "", "", now) == nil {
+		c.t.Fatal("повторное открытие спора выполнено")
+	}
+
+	deals, err := c.repo.GetDisputedDeals()
+	c.must(err)
+	c.expectDealIDs(deals, disputed, refunded)
+
+	resolvedAt := now.Add(time.Minute)
+	c.must(c.repo.ResolveDealDispute(disputed.ID, &model.DisputeResolution{
+		Status: model.DealStatusCompleted, WinnerID: bob.ID, ResolvedBy: admin.ID, Comment: "оплата подтверждена", ResolvedAt: resolvedAt,
+	}))
+	got = c.getDeal(disputed.ID)
+	if got.Status != model.DealStatusCompleted || got.DisputeWinnerID != bob.ID || got.DisputeResolvedBy != admin.ID ||
+		got.DisputeComment != "оплата подтверждена" || got.CompletedAt == nil || !sameTime(*got.CompletedAt, resolvedAt) {
+		c.t.Fatalf("спор решен завершением: %+v", got)
+	}
+
+	c.must(c.repo.ResolveDealDispute(refunded.ID, &model.DisputeResolution{
+		Status: model.DealStatusCancelled, WinnerID: alice.ID, ResolvedBy: admin.ID, ResolvedAt: resolvedAt,
+	}))
+	got = c.getDeal(refunded.ID)
+	if got.Status != model.DealStatusCancelled || got.CompletedAt != nil || got.DisputeResolvedAt == nil {
+		c.t.Fatalf("спор решен отменой: %+v", got)
+	}
+
+	deals, err = c.repo.GetDisputedDeals()
+	c.must(err)
+	c.expectDealIDs(deals)
+}
+
+func testConformanceCompletedTrades(c *conformance) {
+	alice, bob := c.user("Алиса"), c.user("Боб")
+	since := time.Now().Add(-time.Second)
+	first := c.completedDeal(alice, bob)
+	c.pause()
+	second := c.completedDeal(bob, alice)
+	c.deal(alice, bob) // Незавершенная
+
+	// Сделка в другой валюте
+	usd := c.deal(alice, bob, func(deal *model.Deal) { deal.FiatCurrency = "USD" })
+	_, err := c.repo.ConfirmDealWithRole(usd.ID, alice.ID, true, "")
+	c.must(err)
+	_, err = c.repo.ConfirmDealWithRole(usd.ID, bob.ID, false, "")
+	c.must(err)
+	until := time.Now().Add(time.Second)
+
+	trades, err := c.repo.GetCompletedTrades(&model.TradeFilter{Cryptocurrency: "BTC", FiatCurrency: "RUB", Since: since, Until: until})
+	c.must(err)
+	if len(trades) != 2 || trades[0].DealID != first.ID || trades[1].DealID != second.ID {
+		c.t.Fatalf("завершенные сделки по паре: %+v", trades)
+	}
+	if trades[0].Price != 100 || trades[0].Amount != 1 || !sameTime(trades[0].CompletedAt, *first.CompletedAt) {
+		c.t.Fatalf("сделка рынка: %+v", trades[0])
+	}
+
+	trades, err = c.repo.GetCompletedTrades(&model.TradeFilter{Since: since, Until: until})
+	c.must(err)
+	if len(trades) != 3 {
+		c.t.Fatalf("завершенных сделок по всем парам %d, ожидалось 3", len(trades))
+	}
+
+	// Конец периода не включается
+	trades, err = c.repo.GetCompletedTrades(&model.TradeFilter{Since: since, Until: *first.CompletedAt})
+	c.must(err)
+	if len(trades) != 0 {
+		c.t.Fatalf("сделка на границе периода включена: %+v", trades)
+	}
+}
+
+// =====================================================
+// ОТЗЫВЫ И РЕЙТИНГИ
+// =====================================================
+
+func testConformanceCheckCanReview(c *conformance) {
+	alice, bob, carol := c.user("Алиса"), c.user("Боб"), c.user("Кэрол")
+	active := c.deal(alice, bob)
+	completed := c.completedDeal(alice, bob)
+
+	_, err := c.repo.CheckCanReview(999, alice.ID, bob.ID)
+	c.expectError(err, "сделка не найдена")
+	_, err = c.repo.CheckCanReview(completed.ID, carol.ID, bob.ID)
+	c.expectError(err, "вы не участвовали в данной сделке")
+	_, err = c.repo.CheckCanReview(completed.ID, alice.ID, carol.ID)
+	c.expectError(err, "неверный получатель отзыва")
+	_, err = c.repo.CheckCanReview(completed.ID, alice.ID, alice.ID)
+	c.expectError(err, "неверный получатель отзыва")
+	_, err = c.repo.CheckCanReview(active.ID, alice.ID, bob.ID)
+	c.expectError(err, "только по завершенным сделкам")
+
+	for _, pair := range [][2]*model.User{{alice, bob}, {bob, alice}} {
+		ok, err := c.repo.CheckCanReview(completed.ID, pair[0].ID, pair[1].ID)
+		if !ok || err != nil {
+			c.t.Fatalf("отзыв %s -> %s запрещен: %v", pair[0].FirstName, pair[1].FirstName, err)
+		}
+	}
+
+	c.must(c.repo.CreateReview(&model.Review{DealID: completed.ID, FromUserID: alice.ID, ToUserID: bob.ID, Rating: 5}))
+	_, err = c.repo.CheckCanReview(completed.ID, alice.ID, bob.ID)
+	c.expectError(err, "отзыв по данной сделке уже оставлен")
+
+	// Отзыв одной стороны не мешает второй
+	ok, err := c.repo.CheckCanReview(completed.ID, bob.ID, alice.ID)
+	if !ok || err != nil {
+		c.t.Fatalf("ответный отзыв запрещен: %v", err)
+	}
+}
+
+func testConformanceReviews(c *conformance) {
+	target := c.user("Получатель")
+	named := &model.User{TelegramID: 1, FirstName: "Иван", LastName: "Петров", Username: "ivan"}
+	c.must(c.repo.CreateUser(named))
+
+	rating, err := c.repo.GetUserRating(target.ID)
+	c.must(err)
+	if rating.UserID != target.ID || rating.TotalReviews != 0 || rating.AverageRating != 0 {
+		c.t.Fatalf("рейтинг без отзывов: %+v", rating)
+	}
+
+	reviews, err := c.repo.GetReviewsByUserID(target.ID, 10, 0)
+	c.must(err)
+	if len(reviews) != 0 {
+		c.t.Fatalf("отзывов без сделок: %d", len(reviews))
+	}
+
+	// Пять отзывов с оценками 5, 4, 3, 1, 5 от разных сделок
+	stars := []int{5, 4, 3, 1, 5}
+	created := make([]*model.Review, 0, len(stars))
+	for i, rating := range stars {
+		deal := c.completedDeal(named, target)
+		review := &model.Review{DealID: deal.ID, FromUserID: named.ID, ToUserID: target.ID, Rating: rating,
+			Comment: fmt.Sprintf("отзыв %d", i+1), IsAnonymous: i == 3}
+		c.must(c.repo.CreateReview(review))
+		created = append(created, review)
+		c.pause()
+	}
+
+	wantTypes := []model.ReviewType{model.ReviewTypePositive, model.ReviewTypePositive, model.ReviewTypeNeutral,
+		model.ReviewTypeNegative, model.ReviewTypePositive}
+	for i, review := range created {
+		if review.ID == 0 || review.Type != wantTypes[i] || !review.IsVisible || review.CreatedAt.IsZero() {
+			c.t.Fatalf("отзыв %d: %+v", i+1, review)
+		}
+	}
+
+	rating, err = c.repo.GetUserRating(target.ID)
+	c.must(err)
+	if rating.TotalReviews != 5 || !closeTo(rating.AverageRating, 3.6) || rating.PositiveReviews != 3 ||
+		rating.NeutralReviews != 1 || rating.NegativeReviews != 1 {
+		c.t.Fatalf("пересчитанный рейтинг: %+v", rating)
+	}
+	if rating.FiveStars != 2 || rating.FourStars != 1 || rating.ThreeStars != 1 || rating.TwoStars != 0 || rating.OneStar != 1 {
+		c.t.Fatalf("распределение оценок: %+v", rating)
+	}
+
+	// Новые отзывы сначала, с именем автора
+	reviews, err = c.repo.GetReviewsByUserID(target.ID, 2, 0)
+	c.must(err)
+	if len(reviews) != 2 || reviews[0].ID != created[4].ID || reviews[1].ID != created[3].ID {
+		c.t.Fatalf("первая страница отзывов: %+v", reviews)
+	}
+	if reviews[0].FromUserName != "Иван Петров" || reviews[0].FromUserUsername != "ivan" {
+		c.t.Fatalf("автор отзыва: %q (%q)", reviews[0].FromUserName, reviews[0].FromUserUsername)
+	}
+	if reviews[1].FromUserName != "Аноним" || reviews[1].FromUserUsername != "" {
+		c.t.Fatalf("автор анонимного отзыва: %q (%q)", reviews[1].FromUserName, reviews[1].FromUserUsername)
+	}
+
+	reviews, err = c.repo.GetReviewsByUserID(target.ID, 2, 4)
+	c.must(err)
+	if len(reviews) != 1 || reviews[0].ID != created[0].ID || reviews[0].Comment != "отзыв 1" {
+		c.t.Fatalf("последняя страница отзывов: %+v", reviews)
+	}
+	reviews, err = c.repo.GetReviewsByUserID(target.ID, 2, 10)
+	c.must(err)
+	if reviews == nil || len(reviews) != 0 {
+		c.t.Fatalf("страница за пределами списка: %+v", reviews)
+	}
+
+	stats, err := c.repo.GetUserReviewStats(target.ID)
+	c.must(err)
+	if stats.TotalReviews != 5 || !closeTo(stats.AverageRating, 3.6) || !closeTo(stats.PositivePercent, 60) || len(stats.RecentReviews) != 5 {
+		c.t.Fatalf("статистика отзывов: %+v", stats)
+	}
+	if stats.RatingDistribution[5] != 2 || stats.RatingDistribution[2] != 0 || stats.RatingDistribution[1] != 1 {
+		c.t.Fatalf("распределение в статистике: %+v", stats.RatingDistribution)
+	}
+
+	// Жалоба на отзыв
+	report := &model.ReviewReport{ReviewID: created[3].ID, UserID: target.ID, Reason: "оскорбление"}
+	c.must(c.repo.ReportReview(report))
+	if report.ID == 0 || report.Status != "pending" || report.CreatedAt.IsZero() {
+		c.t.Fatalf("поля жалобы не заполнены: %+v", report)
+	}
+	reviews, err = c.repo.GetReviewsByUserID(target.ID, 10, 0)
+	c.must(err)
+	for _, review := range reviews {
+		want := 0
+		if review.ID == created[3].ID {
+			want = 1
+		}
+		if review.ReportedCount != want {
+			c.t.Fatalf("жалоб на отзыв ID=%d: %d, ожидалось %d", review.ID, review.ReportedCount, want)
+		}
+	}
+}
+
+// =====================================================
+// ТРАНЗАКЦИИ И ПРОЧЕЕ
+// =====================================================
+
+func testConformanceTransactions(c *conformance) {
+	transactional, ok := c.repo.(Transactional)
+	if !ok {
+		c.t.Skip("хранилище не поддерживает транзакции")
+	}
+
+	author := c.user("Автор")
+	order := c.order(author, model.OrderTypeSell, 100, 1)
+	errAbort := errors.New("откат")
+
+	// Ошибка отменяет все изменения транзакции
+	err := transactional.InTransaction(func(tx RepositoryInterface) error {
+		if _, err := tx.ReserveOrderAmount(order.ID, 1); err != nil {
+			return err
+		}
+		if err := tx.CreateUser(&model.User{TelegramID: 777, FirstName: "Временный"}); err != nil {
+			return err
+		}
+		// Изменения видны внутри транзакции
+		if got, err := tx.GetOrderByID(order.ID); err != nil || got.Status != model.OrderStatusInDeal {
+			return fmt.Errorf("резерв не виден в транзакции: %v", err)
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		c.t.Fatalf("транзакция вернула %v", err)
+	}
+	if got := c.getOrder(order.ID); got.RemainingAmount != 1 || got.Status != model.OrderStatusActive {
+		c.t.Fatalf("резерв не откатился: %+v", got)
+	}
+	if _, err := c.repo.GetUserByTelegramID(777); err == nil {
+		c.t.Fatal("пользователь из откаченной транзакции сохранился")
+	}
+
+	// Ошибка одной операции внутри транзакции отменяет только ее
+	err = transactional.InTransaction(func(tx RepositoryInterface) error {
+		if _, err := tx.ReserveOrderAmount(order.ID, 0.4); err != nil {
+			return err
+		}
+		if _, err := tx.ReserveOrderAmount(order.ID, 5); err == nil {
+			return fmt.Errorf("резерв сверх остатка выполнен")
+		}
+		return tx.CreateUser(&model.User{TelegramID: 778, FirstName: "Постоянный"})
+	})
+	c.must(err)
+	if got := c.getOrder(order.ID); got.RemainingAmount != 0.6 {
+		c.t.Fatalf("остаток после транзакции %v, ожидался 0.6", got.RemainingAmount)
+	}
+	if _, err := c.repo.GetUserByTelegramID(778); err != nil {
+		c.t.Fatalf("пользователь из зафиксированной транзакции не найден: %v", err)
+	}
+}
+
+func testConformanceMisc(c *conformance) {
+	c.must(c.repo.HealthCheck())
+
+	assets, err := c.repo.GetAssets()
+	c.must(err)
+	if len(assets) != 0 {
+		c.t.Fatalf("в пустом хранилище записи реестра активов: %+v", assets)
+	}
+}
@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"p2pTG-crypto-exchange/internal/model"
+)
+
+// =====================================================
+// ДЕМОНСТРАЦИОННЫЕ ДАННЫЕ
+// =====================================================
+
+// demoUsers участники демо-биржи: все состоят в закрытом чате
+var demoUsers = []model.User{
+	{TelegramID: 100000001, FirstName: "Алексей", LastName: "Смирнов", Username: "alex_smirnov"},
+	{TelegramID: 100000002, FirstName: "Мария", LastName: "Иванова", Username: "maria_iv"},
+	{TelegramID: 100000003, FirstName: "Дмитрий", LastName: "Кузнецов", Username: "dkuznetsov"},
+	{TelegramID: 100000004, FirstName: "Елена", LastName: "Попова", Username: "elena_p2p"},
+	{TelegramID: 100000005, FirstName: "Сергей", LastName: "Волков", Username: "volkov_crypto"},
+	{TelegramID: 100000006, FirstName: "Анна", LastName: "Соколова", Username: "anna_sokol"},
+}
+
+// DemoTelegramIDs возвращает Telegram ID демонстрационных пользователей в порядке создания
+func DemoTelegramIDs() []int64 {
+	ids := make([]int64, len(demoUsers))
+	for i, user := range demoUsers {
+		ids[i] = user.TelegramID
+	}
+	return ids
+}
+
+// demoOrder описывает заявку демо-биржи; author - индекс в demoUsers
+type demoOrder struct {
+	author  int
+	side    model.OrderType
+	crypto  string
+	amount  float64
+	price   float64
+	methods []model.PaymentMethod
+	note    string
+}
+
+// demoOrders открытые заявки стакана по парам BTC/RUB, ETH/RUB и USDT/RUB
+var demoOrders = []demoOrder{
+	{0, model.OrderTypeSell, "BTC", 0.05, 9150000, []model.PaymentMethod{model.PaymentMethodSberbank, model.PaymentMethodTinkoff}, "Быстро, без третьих лиц"},
+	{1, model.OrderTypeBuy, "BTC", 0.1, 8980000, []model.PaymentMethod{model.PaymentMethodSPB}, "Куплю частями от 100 000 ₽"},
+	{2, model.OrderTypeSell, "BTC", 0.02, 9120000, []model.PaymentMethod{model.PaymentMethodTinkoff}, ""},
+	{3, model.OrderTypeBuy, "ETH", 1.5, 328000, []model.PaymentMethod{model.PaymentMethodSberbank, model.PaymentMethodSPB}, "Оплата в течение 15 минут"},
+	{4, model.OrderTypeSell, "ETH", 2, 334500, []model.PaymentMethod{model.PaymentMethodBank}, "Только перевод с личного счета"},
+	{5, model.OrderTypeSell, "USDT", 1500, 95.4, []model.PaymentMethod{model.PaymentMethodSberbank, model.PaymentMethodTinkoff, model.PaymentMethodSPB}, "Сеть TRC20"},
+	{0, model.OrderTypeBuy, "USDT", 3000, 94.1, []model.PaymentMethod{model.PaymentMethodYandexMoney, model.PaymentMethodSPB}, ""},
+	{2, model.OrderTypeBuy, "USDT", 800, 93.8, []model.PaymentMethod{model.PaymentMethodCash}, "Наличные, Москва, м. Курская"},
+}
+
+// demoDeal описывает сделку демо-биржи; author и counterparty - индексы в demoUsers
+type demoDeal struct {
+	author       int
+	counterparty int
+	side         model.OrderType
+	crypto       string
+	amount       float64
+	price        float64
+	method       model.PaymentMethod
+	completed    bool // Обе стороны подтвердили сделку и оставили отзывы
+	authorReview string
+	partyReview  string
+}
+
+// demoDeals завершенные сделки с отзывами и одна сделка в процессе
+var demoDeals = []demoDeal{
+	{1, 4, model.OrderTypeSell, "USDT", 500, 95.1, model.PaymentMethodSberbank, true, "Все четко, рекомендую", "Быстрый перевод, спасибо"},
+	{3, 0, model.OrderTypeBuy, "BTC", 0.01, 9050000, model.PaymentMethodTinkoff, true, "Отличный контрагент", "Оплата пришла сразу"},
+	{5, 2, model.OrderTypeSell, "ETH", 0.5, 331000, model.PaymentMethodSPB, true, "Надежный партнер", "Рекомендую"},
+	{4, 5, model.OrderTypeSell, "USDT", 1000, 95.3, model.PaymentMethodTinkoff, false, "", ""},
+}
+
+// demoResponses ожидающие отклики: индекс заявки в demoOrders и откликнувшийся
+var demoResponses = []struct {
+	order   int
+	user    int
+	message string
+}{
+	{0, 3, "Готов купить весь объем, оплата Тинькофф"},
+	{3, 4, "Могу продать 1 ETH прямо сейчас"},
+	{5, 1, "Заберу 500 USDT по СБП"},
+}
+
+// SeedDemoData заполняет пустое хранилище демонстрационными пользователями, заявками,
+// откликами и сделками. Данные создаются через методы интерфейса, поэтому подходят
+// для любого хранилища, но предназначены для MemoryRepository в демо-режиме
+func SeedDemoData(repo RepositoryInterface) error {
+	log.Println("[INFO] Заполнение хранилища демонстрационными данными")
+
+	users := make([]*model.User, len(demoUsers))
+	for i := range demoUsers {
+		user := demoUsers[i]
+		user.TelegramUserID = "@" + user.Username
+		user.LanguageCode = "ru"
+		user.ChatMember = true
+		if err := repo.CreateUser(&user); err != nil {
+			return fmt.Errorf("не удалось создать демо-пользователя %s: %w", user.Username, err)
+		}
+		users[i] = &user
+	}
+
+	// Сначала сделки: их статистика и отзывы формируют рейтинги участников
+	for _, d := range demoDeals {
+		if err := seedDemoDeal(repo, users, d); err != nil {
+			return err
+		}
+	}
+
+	orders := make([]*model.Order, len(demoOrders))
+	for i, o := range demoOrders {
+		order, err := createDemoOrder(repo, users[o.author], o)
+		if err != nil {
+			return err
+		}
+		orders[i] = order
+	}
+
+	for _, r := range demoResponses {
+		order := orders[r.order]
+		response := &model.Response{
+			OrderID:         order.ID,
+			UserID:          users[r.user].ID,
+			Message:         r.message,
+			RequestedAmount: order.RemainingAmount,
+			PaymentMethod:   order.PaymentMethods[0],
+		}
+		if err := repo.CreateResponse(response); err != nil {
+			return fmt.Errorf("не удалось создать демо-отклик на заявку ID=%d: %w", order.ID, err)
+		}
+	}
+
+	log.Printf("[INFO] Демонстрационные данные созданы: пользователей %d, заявок %d, сделок %d, откликов %d",
+		len(demoUsers), len(demoOrders)+len(demoDeals), len(demoDeals), len(demoResponses))
+	return nil
+}
+
+// createDemoOrder создает заявку автора по описанию демо-заявки
+func createDemoOrder(repo RepositoryInterface, author *model.User, o demoOrder) (*model.Order, error) {
+	methods := make([]string, len(o.methods))
+	for i, method := range o.methods {
+		methods[i] = string(method)
+	}
+	total := o.amount * o.price
+	order := &model.Order{
+		UserID:          author.ID,
+		Type:            o.side,
+		Cryptocurrency:  o.crypto,
+		FiatCurrency:    "RUB",
+		Amount:          o.amount,
+		Price:           o.price,
+		TotalAmount:     total,
+		MinAmount:       total / 10,
+		MaxAmount:       total,
+		RemainingAmount: o.amount,
+		PaymentMethods:  methods,
+		Description:     o.note,
+		ExpiresAt:       time.Now().Add(24 * time.Hour),
+	}
+	if err := repo.CreateOrder(order); err != nil {
+		return nil, fmt.Errorf("не удалось создать демо-заявку %s %s: %w", o.side, o.crypto, err)
+	}
+	return order, nil
+}
+
+// seedDemoDeal проводит сделку так же, как сервис при принятии отклика:
+// заявка, отклик, резерв объема, сделка; завершенные сделки подтверждаются обеими сторонами
+func seedDemoDeal(repo RepositoryInterface, users []*model.User, d demoDeal) error {
+	author, counterparty := users[d.author], users[d.counterparty]
+	order, err := createDemoOrder(repo, author, demoOrder{
+		side: d.side, crypto: d.crypto, amount: d.amount, price: d.price,
+		methods: []model.PaymentMethod{d.method},
+	})
+	if err != nil {
+		return err
+	}
+
+	response := &model.Response{
+		OrderID:         order.ID,
+		UserID:          counterparty.ID,
+		Message:         "Готов к сделке",
+		RequestedAmount: d.amount,
+		PaymentMethod:   string(d.method),
+	}
+	if err := repo.CreateResponse(response); err != nil {
+		return fmt.Errorf("не удалось создать демо-отклик: %w", err)
+	}
+	if _, err := repo.ReserveOrderAmount(order.ID, d.amount); err != nil {
+		return fmt.Errorf("не удалось занять объем демо-заявки ID=%d: %w", order.ID, err)
+	}
+	if err := repo.UpdateResponseStatus(response.ID, model.ResponseStatusAccepted); err != nil {
+		return fmt.Errorf("не удалось принять демо-отклик ID=%d: %w", response.ID, err)
+	}
+
+	deal := &model.Deal{
+		ResponseID:     response.ID,
+		OrderID:        order.ID,
+		AuthorID:       author.ID,
+		CounterpartyID: counterparty.ID,
+		Cryptocurrency: d.crypto,
+		FiatCurrency:   order.FiatCurrency,
+		Amount:         d.amount,
+		Price:          d.price,
+		TotalAmount:    d.amount * d.price,
+		PaymentMethods: order.PaymentMethods,
+		OrderType:      d.side,
+		Status:         model.DealStatusInProgress,
+		ExpiresAt:      time.Now().Add(30 * time.Minute),
+	}
+	if err := repo.CreateDeal(deal); err != nil {
+		return fmt.Errorf("не удалось создать демо-сделку по заявке ID=%d: %w", order.ID, err)
+	}
+	if !d.completed {
+		return nil
+	}
+
+	if _, err := repo.ConfirmDealWithRole(deal.ID, author.ID, true, ""); err != nil {
+		return fmt.Errorf("не удалось подтвердить демо-сделку ID=%d автором: %w", deal.ID, err)
+	}
+	if _, err := repo.ConfirmDealWithRole(deal.ID, counterparty.ID, false, ""); err != nil {
+		return fmt.Errorf("не удалось подтвердить демо-сделку ID=%d контрагентом: %w", deal.ID, err)
+	}
+	for _, user := range []*model.User{author, counterparty} {
+		if err := repo.UpdateUserDealStats(user.ID, true); err != nil {
+			return fmt.Errorf("не удалось обновить статистику демо-пользователя ID=%d: %w", user.ID, err)
+		}
+	}
+
+	reviews := []*model.Review{
+		{DealID: deal.ID, FromUserID: author.ID, ToUserID: counterparty.ID, Rating: 5, Comment: d.authorReview, IsVisible: true},
+		{DealID: deal.ID, FromUserID: counterparty.ID, ToUserID: author.ID, Rating: 5, Comment: d.partyReview, IsVisible: true},
+	}
+	for _, review := range reviews {
+		if err := repo.CreateReview(review); err != nil {
+			return fmt.Errorf("не удалось создать демо-отзыв по сделке ID=%d: %w", deal.ID, err)
+		}
+	}
+	return nil
+}
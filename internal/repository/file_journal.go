@@ -0,0 +1,449 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"p2pTG-crypto-exchange/internal/model"
+)
+
+// =====================================================
+// ЖУРНАЛ ЗАПИСИ И ТРАНЗАКЦИИ ФАЙЛОВОГО ХРАНИЛИЩА
+// =====================================================
+
+// journalFilename журнал упреждающей записи в папке данных
+const journalFilename = "journal.log"
+
+// countersFilename файл счетчиков ID
+const countersFilename = "counters.json"
+
+// journalCheckpointSize размер журнала, после которого измененные таблицы сохраняются в файлы данных,
+// а журнал очищается
+const journalCheckpointSize = 4 << 20
+
+// journalRecord одна транзакция в журнале: новые версии измененных записей, удаленные записи и счетчики ID
+type journalRecord struct {
+	Rows     map[string][]json.RawMessage `json:"rows,omitempty"`     // Новые версии записей по файлам данных
+	Deleted  map[string][]int64           `json:"deleted,omitempty"`  // Ключи удаленных записей по файлам данных
+	Counters map[string]int64             `json:"counters,omitempty"` // Счетчики ID после транзакции
+}
+
+// fileDB данные файлового хранилища в памяти: таблицы, счетчики ID и состояние текущей транзакции.
+// Общие для репозитория и хранилищ транзакций InTransaction
+type fileDB struct {
+	dataDir string // Папка данных (пусто - данные только в памяти, см. MemoryRepository)
+	tables  []persistentTable
+
+	users          *table[model.User]
+	orders         *table[model.Order]
+	responses      *table[model.Response]
+	deals          *table[model.Deal]
+	reviews        *table[model.Review]
+	ratings        *table[model.Rating]
+	reviewReports  *table[model.ReviewReport]
+	dealEvents     *table[model.DealEvent]
+	responseOffers *table[model.ResponseOffer]
+	assets         []model.Asset // Только для чтения: переопределения встроенного реестра активов
+
+	counters map[string]int64
+
+	tx          *fileTx         // Текущая транзакция записи (под mutex репозитория)
+	savepoints  []int           // Точки отката вложенных операций внутри InTransaction
+	unsaved     map[string]bool // Файлы данных, изменения которых пока только в журнале
+	journalSize int64           // Текущий размер журнала
+}
+
+// fileTx изменения текущей транзакции: действия отката и измененные записи для журнала
+type fileTx struct {
+	undo     []func()
+	touched  map[persistentTable]map[int64]bool
+	counters bool
+}
+
+// persistent сохраняет ли хранилище данные на диск
+func (db *fileDB) persistent() bool {
+	return db.dataDir != ""
+}
+
+// track учитывает изменение записи id таблицы в текущей транзакции.
+// Вне транзакции (загрузка и восстановление из журнала) таблица только помечается несохраненной
+func (db *fileDB) track(t persistentTable, id int64, undo func()) {
+	if db.tx == nil {
+		db.unsaved[t.file()] = true
+		return
+	}
+
+	db.tx.undo = append(db.tx.undo, undo)
+	if db.tx.touched[t] == nil {
+		db.tx.touched[t] = make(map[int64]bool)
+	}
+	db.tx.touched[t][id] = true
+}
+
+// nextID увеличивает счетчик ID сущности и возвращает новый ID
+func (db *fileDB) nextID(counter string) int64 {
+	previous, existed := db.counters[counter]
+	if db.tx != nil {
+		db.tx.undo = append(db.tx.undo, func() {
+			if existed {
+				db.counters[counter] = previous
+			} else {
+				delete(db.counters, counter)
+			}
+		})
+		db.tx.counters = true
+	} else {
+		db.unsaved[countersFilename] = true
+	}
+
+	db.counters[counter] = previous + 1
+	return previous + 1
+}
+
+// rollback отменяет изменения транзакции, сделанные после точки отката savepoint
+func (db *fileDB) rollback(savepoint int) {
+	for i := len(db.tx.undo) - 1; i >= savepoint; i-- {
+		db.tx.undo[i]()
+	}
+	db.tx.undo = db.tx.undo[:savepoint]
+}
+
+// lock захватывает хранилище на запись и начинает транзакцию.
+// Внутри InTransaction хранилище уже захвачено: запоминается точка отката вложенной операции
+func (r *FileRepository) lock() {
+	if r.held {
+		r.db.savepoints = append(r.db.savepoints, len(r.db.tx.undo))
+		return
+	}
+	r.mutex.Lock()
+	r.db.tx = &fileTx{touched: make(map[persistentTable]map[int64]bool)}
+}
+
+// unlock фиксирует транзакцию, если операция завершилась без ошибки (*err == nil), иначе отменяет ее.
+// Ошибка фиксации возвращается через err, изменения в памяти при этом тоже отменяются
+func (r *FileRepository) unlock(err *error) {
+	db := r.db
+	if r.held {
+		savepoint := db.savepoints[len(db.savepoints)-1]
+		db.savepoints = db.savepoints[:len(db.savepoints)-1]
+		if *err != nil {
+			db.rollback(savepoint)
+		}
+		return
+	}
+
+	if *err == nil {
+		*err = db.commit()
+	}
+	if *err != nil {
+		db.rollback(0)
+	}
+	db.tx = nil
+	r.mutex.Unlock()
+}
+
+// rlock захватывает хранилище на чтение (внутри InTransaction оно уже захвачено)
+func (r *FileRepository) rlock() {
+	if !r.held {
+		r.mutex.RLock()
+	}
+}
+
+// runlock освобождает хранилище после чтения
+func (r *FileRepository) runlock() {
+	if !r.held {
+		r.mutex.RUnlock()
+	}
+}
+
+// InTransaction выполняет fn атомарно: все изменения, сделанные через переданное хранилище,
+// фиксируются вместе или (при ошибке fn) не фиксируются вовсе.
+// На время транзакции остальные операции с хранилищем ждут ее завершения.
+// Хранилище транзакции действительно только внутри fn
+func (r *FileRepository) InTransaction(fn func(tx RepositoryInterface) error) (err error) {
+	r.lock()
+	defer r.unlock(&err)
+
+	// Хранилище транзакции работает с теми же данными, но без повторного захвата мьютекса
+	return fn(&FileRepository{dataDir: r.dataDir, db: r.db, held: true})
+}
+
+// commit записывает изменения транзакции в журнал и сбрасывает его на диск.
+// Файлы данных обновляются позже целиком (checkpoint), до этого изменения восстанавливаются из журнала
+func (db *fileDB) commit() error {
+	if !db.persistent() {
+		return nil
+	}
+
+	record := journalRecord{}
+	for t, ids := range db.tx.touched {
+		for id := range ids {
+			row, exists, err := t.rowJSON(id)
+			if err != nil {
+				return fmt.Errorf("не удалось сериализовать запись %s: %w", t.file(), err)
+			}
+			if exists {
+				if record.Rows == nil {
+					record.Rows = make(map[string][]json.RawMessage)
+				}
+				record.Rows[t.file()] = append(record.Rows[t.file()], row)
+			} else {
+				if record.Deleted == nil {
+					record.Deleted = make(map[string][]int64)
+				}
+				record.Deleted[t.file()] = append(record.Deleted[t.file()], id)
+			}
+		}
+	}
+	if db.tx.counters {
+		record.Counters = make(map[string]int64, len(db.counters))
+		for name, value := range db.counters {
+			record.Counters[name] = value
+		}
+	}
+	if record.Rows == nil && record.Deleted == nil && record.Counters == nil {
+		return nil
+	}
+
+	if err := db.appendJournal(record); err != nil {
+		return fmt.Errorf("не удалось записать журнал: %w", err)
+	}
+	for t := range db.tx.touched {
+		db.unsaved[t.file()] = true
+	}
+	if db.tx.counters {
+		db.unsaved[countersFilename] = true
+	}
+
+	if db.journalSize >= journalCheckpointSize {
+		if err := db.checkpoint(); err != nil {
+			// Изменения уже в журнале: сохраним файлы данных при следующей возможности
+			log.Printf("[WARN] Не удалось сохранить файлы данных, изменения остаются в журнале: %v", err)
+		}
+	}
+	return nil
+}
+
+// appendJournal дописывает запись транзакции в журнал и сбрасывает ее на диск
+func (db *fileDB) appendJournal(record journalRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	file, err := os.OpenFile(filepath.Join(db.dataDir, journalFilename), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Write(line); err != nil {
+		truncateJournal(file, info.Size())
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		truncateJournal(file, info.Size())
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	db.journalSize += int64(len(line))
+	return nil
+}
+
+// truncateJournal отрезает от журнала недописанную запись, оставляя size байт.
+// Иначе следующая запись продолжит оборванную строку и журнал не прочитается при запуске
+func truncateJournal(file *os.File, size int64) {
+	if err := file.Truncate(size); err != nil {
+		log.Printf("[WARN] Не удалось отрезать недописанную запись журнала: %v", err)
+		return
+	}
+	if err := file.Sync(); err != nil {
+		log.Printf("[WARN] Не удалось сбросить журнал на диск: %v", err)
+	}
+}
+
+// checkpoint сохраняет измененные таблицы и счетчики в файлы данных и очищает журнал.
+// Сбой посреди сохранения безопасен: журнал очищается последним и при запуске применяется повторно
+func (db *fileDB) checkpoint() error {
+	if !db.persistent() || len(db.unsaved) == 0 {
+		return nil
+	}
+
+	for _, t := range db.tables {
+		if !db.unsaved[t.file()] {
+			continue
+		}
+		data, err := t.snapshot()
+		if err != nil {
+			return fmt.Errorf("не удалось сериализовать %s: %w", t.file(), err)
+		}
+		if err := writeFileAtomic(filepath.Join(db.dataDir, t.file()), data); err != nil {
+			return err
+		}
+	}
+	if db.unsaved[countersFilename] {
+		data, err := json.MarshalIndent(db.counters, "", "  ")
+		if err != nil {
+			return fmt.Errorf("не удалось сериализовать счетчики: %w", err)
+		}
+		if err := writeFileAtomic(filepath.Join(db.dataDir, countersFilename), data); err != nil {
+			return err
+		}
+	}
+
+	path := filepath.Join(db.dataDir, journalFilename)
+	if err := os.Truncate(path, 0); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("не удалось очистить журнал: %w", err)
+	}
+	db.unsaved = make(map[string]bool)
+	db.journalSize = 0
+	return nil
+}
+
+// replayJournal применяет к загруженным таблицам транзакции из журнала, еще не сохраненные в файлы данных.
+// Недописанная последняя запись означает, что транзакция не была зафиксирована: она отбрасывается
+// и отрезается от журнала, чтобы новые записи начинались с новой строки
+func (db *fileDB) replayJournal() error {
+	file, err := os.OpenFile(filepath.Join(db.dataDir, journalFilename), os.O_RDWR, 0644)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось открыть журнал: %w", err)
+	}
+	defer file.Close()
+
+	tables := make(map[string]persistentTable, len(db.tables))
+	for _, t := range db.tables {
+		tables[t.file()] = t
+	}
+
+	reader := bufio.NewReader(file)
+	replayed := 0
+	var size int64 // Размер журнала до конца последней целой записи
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return fmt.Errorf("не удалось прочитать журнал: %w", readErr)
+		}
+		if readErr != nil {
+			// Строка без перевода строки - запись оборвалась при сбое
+			if len(line) > 0 {
+				if len(bytes.TrimSpace(line)) > 0 {
+					log.Printf("[WARN] Отброшена незавершенная запись журнала (%d байт)", len(line))
+				}
+				truncateJournal(file, size)
+			}
+			break
+		}
+		size += int64(len(line))
+
+		var record journalRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("поврежденная запись журнала %d: %w", replayed+1, err)
+		}
+		if err := db.applyRecord(record, tables); err != nil {
+			return fmt.Errorf("запись журнала %d: %w", replayed+1, err)
+		}
+		replayed++
+	}
+	db.journalSize = size
+
+	if replayed > 0 {
+		log.Printf("[INFO] Восстановлено транзакций из журнала: %d", replayed)
+		// Записанные таблицы сохранятся в файлы, журнал будет очищен
+		db.unsaved[journalFilename] = true
+	}
+	return nil
+}
+
+// applyRecord применяет одну транзакцию журнала (в порядке имен файлов для предсказуемости)
+func (db *fileDB) applyRecord(record journalRecord, tables map[string]persistentTable) error {
+	filenames := make([]string, 0, len(record.Rows)+len(record.Deleted))
+	for filename := range record.Rows {
+		filenames = append(filenames, filename)
+	}
+	for filename := range record.Deleted {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	for _, filename := range filenames {
+		t, ok := tables[filename]
+		if !ok {
+			return fmt.Errorf("неизвестный файл данных %s", filename)
+		}
+		for _, row := range record.Rows[filename] {
+			if err := t.applyJSON(row); err != nil {
+				return err
+			}
+		}
+		for _, id := range record.Deleted[filename] {
+			t.remove(id)
+		}
+	}
+
+	if record.Counters != nil {
+		db.counters = record.Counters
+		db.unsaved[countersFilename] = true
+	}
+	return nil
+}
+
+// writeFileAtomic записывает файл через временный файл, fsync и переименование:
+// при сбое на диске остается либо старое, либо новое содержимое целиком
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	temp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("не удалось создать временный файл: %w", err)
+	}
+	tempPath := temp.Name()
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("не удалось записать файл: %w", err)
+	}
+	if err := temp.Chmod(0644); err != nil {
+		temp.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("не удалось записать файл: %w", err)
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("не удалось сбросить файл на диск: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("не удалось записать файл: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("не удалось заменить файл: %w", err)
+	}
+
+	// Сбрасываем каталог, чтобы переименование пережило сбой питания
+	if dirFile, err := os.Open(dir); err == nil {
+		dirFile.Sync()
+		dirFile.Close()
+	}
+	return nil
+}
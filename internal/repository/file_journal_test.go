@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"p2pTG-crypto-exchange/internal/model"
+)
+
+// openJournalRepo открывает файловое хранилище в dir без вывода журнала в консоль
+func openJournalRepo(t *testing.T, dir string) *FileRepository {
+	t.Helper()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	repo, err := NewFileRepository(dir)
+	if err != nil {
+		t.Fatalf("не удалось открыть хранилище: %v", err)
+	}
+	return repo
+}
+
+// appendTornRecord дописывает в журнал оборванную при сбое запись без перевода строки
+func appendTornRecord(t *testing.T, dir string) {
+	t.Helper()
+	file, err := os.OpenFile(filepath.Join(dir, journalFilename), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(`{"rows":{"users.json":[{"id":99,"telegram_id":`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectJournalUser(t *testing.T, repo *FileRepository, telegramID int64) {
+	t.Helper()
+	if _, err := repo.GetUserByTelegramID(telegramID); err != nil {
+		t.Errorf("пользователь telegram_id=%d не найден: %v", telegramID, err)
+	}
+}
+
+func TestReplayJournalTruncatesTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	repo := openJournalRepo(t, dir)
+	if err := repo.CreateUser(&model.User{TelegramID: 1001, FirstName: "До сбоя"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Сбой посреди записи следующей транзакции: в журнале целая запись и оборванная
+	appendTornRecord(t, dir)
+	repo = openJournalRepo(t, dir)
+	expectJournalUser(t, repo, 1001)
+
+	// Новая транзакция после восстановления и снова сбой до сохранения файлов данных
+	if err := repo.CreateUser(&model.User{TelegramID: 1002, FirstName: "После сбоя"}); err != nil {
+		t.Fatal(err)
+	}
+	repo = openJournalRepo(t, dir)
+	expectJournalUser(t, repo, 1001)
+	expectJournalUser(t, repo, 1002)
+}
+
+func TestReplayJournalTruncatesTornOnlyJournal(t *testing.T) {
+	// Журнал состоит только из оборванной записи: восстанавливать нечего, но хвост все равно отрезается
+	dir := t.TempDir()
+	openJournalRepo(t, dir)
+	appendTornRecord(t, dir)
+
+	repo := openJournalRepo(t, dir)
+	info, err := os.Stat(filepath.Join(dir, journalFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 {
+		t.Fatalf("после восстановления в журнале осталось %d байт", info.Size())
+	}
+
+	if err := repo.CreateUser(&model.User{TelegramID: 1003, FirstName: "Новый"}); err != nil {
+		t.Fatal(err)
+	}
+	repo = openJournalRepo(t, dir)
+	expectJournalUser(t, repo, 1003)
+}
+
+func TestTruncateJournalAfterFailedAppend(t *testing.T) {
+	dir := t.TempDir()
+	repo := openJournalRepo(t, dir)
+	if err := repo.CreateUser(&model.User{TelegramID: 1004, FirstName: "Целая запись"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Запись оборвалась на середине, appendJournal отрезает журнал до размера перед ней
+	path := filepath.Join(dir, journalFilename)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendTornRecord(t, dir)
+	file, err := os.OpenFile(path, os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncateJournal(file, info.Size())
+	file.Close()
+
+	if err := repo.CreateUser(&model.User{TelegramID: 1005, FirstName: "Следующая запись"}); err != nil {
+		t.Fatal(err)
+	}
+	repo = openJournalRepo(t, dir)
+	expectJournalUser(t, repo, 1004)
+	expectJournalUser(t, repo, 1005)
+}
@@ -3,7 +3,6 @@ package repository
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
@@ -15,10 +14,15 @@ import (
 )
 
 // FileRepository представляет файловое хранилище данных в JSON формате
-// Реализует тот же интерфейс что и PostgreSQL репозиторий, но использует JSON файлы
+// Реализует тот же интерфейс что и PostgreSQL репозиторий, но использует JSON файлы.
+// Данные загружаются в память один раз при запуске и читаются через вторичные индексы;
+// изменения фиксируются в журнале и периодически сохраняются в файлы данных целиком
 type FileRepository struct {
 	dataDir string       // Путь к папке с данными
-	mutex   sync.RWMutex // Мютекс для потокобезопасности при работе с файлами
+	mutex   sync.RWMutex // Мютекс для потокобезопасности при работе с данными
+
+	db   *fileDB // Таблицы в памяти, журнал и текущая транзакция
+	held bool    // Хранилище транзакции InTransaction: mutex уже захвачен
 }
 
 // NewFileRepository создает новый файловый репозиторий
@@ -30,102 +34,169 @@ func NewFileRepository(dataDir string) (*FileRepository, error) {
 
 	repo := &FileRepository{
 		dataDir: dataDir,
+		db:      newFileDB(dataDir),
 	}
 
-	// Инициализируем файлы с пустыми данными если они не существуют
-	if err := repo.initializeFiles(); err != nil {
-		return nil, fmt.Errorf("не удалось инициализировать файлы: %w", err)
+	// Загружаем файлы данных в память (отсутствующие файлы создаются пустыми)
+	if err := repo.db.load(); err != nil {
+		return nil, fmt.Errorf("не удалось загрузить файлы данных: %w", err)
 	}
 
-	log.Printf("[INFO] Файловый репозиторий инициализирован в папке: %s", dataDir)
-	return repo, nil
-}
-
-// initializeFiles создает начальные JSON файлы если они не существуют
-func (r *FileRepository) initializeFiles() error {
-	// Список файлов для инициализации
-	files := map[string]interface{}{
-		"users.json":          []model.User{},
-		"orders.json":         []model.Order{},
-		"responses.json":      []model.Response{}, // Новый файл для откликов
-		"deals.json":          []model.Deal{},
-		"reviews.json":        []model.Review{},
-		"ratings.json":        []model.Rating{},
-		"review_reports.json": []model.ReviewReport{},
-		"counters.json":       map[string]int64{"users": 0, "orders": 0, "responses": 0, "deals": 0, "reviews": 0, "reports": 0},
-	}
-
-	// Создаем файлы если они не существуют
-	for filename, initialData := range files {
-		filePath := filepath.Join(r.dataDir, filename)
-		if _, err := os.Stat(filePath); os.IsNotExist(err) {
-			if err := r.saveToFile(filename, initialData); err != nil {
-				return fmt.Errorf("не удалось создать файл %s: %w", filename, err)
-			}
-			log.Printf("[INFO] Создан файл данных: %s", filename)
-		}
+	// Доводим до конца транзакции, прерванные сбоем
+	if err := repo.db.replayJournal(); err != nil {
+		return nil, fmt.Errorf("не удалось восстановить данные из журнала: %w", err)
 	}
 
-	return nil
-}
+	// Счетчики ID не должны отставать от уже выданных ID
+	repo.db.syncCounters()
 
-// saveToFile сохраняет данные в JSON файл
-func (r *FileRepository) saveToFile(filename string, data interface{}) error {
-	filePath := filepath.Join(r.dataDir, filename)
+	// Заполняем остаток для заявок, созданных до частичного исполнения
+	repo.backfillOrderRemainingAmounts()
 
-	// Сериализуем данные в JSON с отступами для читаемости
-	jsonData, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return fmt.Errorf("не удалось сериализовать данные: %w", err)
+	// Сохраняем созданные, восстановленные и обновленные файлы
+	if err := repo.db.checkpoint(); err != nil {
+		return nil, fmt.Errorf("не удалось инициализировать файлы: %w", err)
 	}
 
-	// Записываем в файл
-	if err := ioutil.WriteFile(filePath, jsonData, 0644); err != nil {
-		return fmt.Errorf("не удалось записать файл: %w", err)
-	}
+	log.Printf("[INFO] Файловый репозиторий инициализирован в папке: %s", dataDir)
+	return repo, nil
+}
 
-	return nil
+// newFileDB создает пустые таблицы файлового хранилища с их вторичными индексами
+func newFileDB(dataDir string) *fileDB {
+	db := &fileDB{
+		dataDir:  dataDir,
+		counters: make(map[string]int64),
+		unsaved:  make(map[string]bool),
+	}
+
+	db.users = newTable(db, "users.json", "users",
+		func(u *model.User) int64 { return u.ID }, cloneUser).
+		withIndex("telegram", func(u *model.User) []string { return []string{indexKey(u.TelegramID)} })
+	db.orders = newTable(db, "orders.json", "orders",
+		func(o *model.Order) int64 { return o.ID }, cloneOrder).
+		withIndex("user", func(o *model.Order) []string { return []string{indexKey(o.UserID)} }).
+		withIndex("status", func(o *model.Order) []string { return []string{string(o.Status)} }).
+		withIndex("pair", func(o *model.Order) []string { return []string{orderPair(o.Cryptocurrency, o.FiatCurrency)} })
+	db.responses = newTable(db, "responses.json", "responses",
+		func(r *model.Response) int64 { return r.ID }, cloneResponse).
+		withIndex("order", func(r *model.Response) []string { return []string{indexKey(r.OrderID)} }).
+		withIndex("user", func(r *model.Response) []string { return []string{indexKey(r.UserID)} })
+	db.deals = newTable(db, "deals.json", "deals",
+		func(d *model.Deal) int64 { return d.ID }, cloneDeal).
+		withIndex("user", func(d *model.Deal) []string { return []string{indexKey(d.AuthorID), indexKey(d.CounterpartyID)} }).
+		withIndex("status", func(d *model.Deal) []string { return []string{string(d.Status)} })
+	db.reviews = newTable(db, "reviews.json", "reviews",
+		func(r *model.Review) int64 { return r.ID }, cloneRow[model.Review]).
+		withIndex("to_user", func(r *model.Review) []string { return []string{indexKey(r.ToUserID)} }).
+		withIndex("deal", func(r *model.Review) []string { return []string{indexKey(r.DealID)} })
+	db.ratings = newTable(db, "ratings.json", "",
+		func(r *model.Rating) int64 { return r.UserID }, cloneRow[model.Rating])
+	db.reviewReports = newTable(db, "review_reports.json", "reports",
+		func(r *model.ReviewReport) int64 { return r.ID }, cloneRow[model.ReviewReport])
+	db.dealEvents = newTable(db, "deal_events.json", "deal_events",
+		func(e *model.DealEvent) int64 { return e.ID }, cloneRow[model.DealEvent]).
+		withIndex("deal", func(e *model.DealEvent) []string { return []string{indexKey(e.DealID)} })
+	db.responseOffers = newTable(db, "response_offers.json", "response_offers",
+		func(o *model.ResponseOffer) int64 { return o.ID }, cloneRow[model.ResponseOffer]).
+		withIndex("response", func(o *model.ResponseOffer) []string { return []string{indexKey(o.ResponseID)} })
+
+	return db
 }
 
-// loadFromFile загружает данные из JSON файла
-func (r *FileRepository) loadFromFile(filename string, dest interface{}) error {
-	filePath := filepath.Join(r.dataDir, filename)
+// load читает файлы данных в таблицы; отсутствующие файлы будут созданы при сохранении
+func (db *fileDB) load() error {
+	for _, t := range db.tables {
+		data, err := os.ReadFile(filepath.Join(db.dataDir, t.file()))
+		if os.IsNotExist(err) {
+			db.unsaved[t.file()] = true
+			log.Printf("[INFO] Создан файл данных: %s", t.file())
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("не удалось прочитать файл %s: %w", t.file(), err)
+		}
+		if err := t.load(data); err != nil {
+			return err
+		}
+	}
 
-	// Читаем файл
-	jsonData, err := ioutil.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("не удалось прочитать файл: %w", err)
+	data, err := os.ReadFile(filepath.Join(db.dataDir, countersFilename))
+	switch {
+	case os.IsNotExist(err):
+		db.counters = map[string]int64{"users": 0, "orders": 0, "responses": 0, "deals": 0, "reviews": 0, "reports": 0, "deal_events": 0, "response_offers": 0}
+		db.unsaved[countersFilename] = true
+		log.Printf("[INFO] Создан файл данных: %s", countersFilename)
+	case err != nil:
+		return fmt.Errorf("не удалось прочитать файл %s: %w", countersFilename, err)
+	default:
+		if err := json.Unmarshal(data, &db.counters); err != nil {
+			return fmt.Errorf("не удалось десериализовать %s: %w", countersFilename, err)
+		}
+		if db.counters == nil {
+			db.counters = make(map[string]int64)
+		}
 	}
 
-	// Десериализуем JSON
-	if err := json.Unmarshal(jsonData, dest); err != nil {
-		return fmt.Errorf("не удалось десериализовать данные: %w", err)
+	// Реестр активов только читается: изменения assets.json применяются после перезапуска
+	assetsPath := filepath.Join(db.dataDir, "assets.json")
+	data, err = os.ReadFile(assetsPath)
+	switch {
+	case os.IsNotExist(err):
+		if err := writeFileAtomic(assetsPath, []byte("[]")); err != nil {
+			return fmt.Errorf("не удалось создать файл assets.json: %w", err)
+		}
+		log.Printf("[INFO] Создан файл данных: %s", "assets.json")
+	case err != nil:
+		return fmt.Errorf("не удалось прочитать файл assets.json: %w", err)
+	default:
+		if err := json.Unmarshal(data, &db.assets); err != nil {
+			return fmt.Errorf("не удалось десериализовать assets.json: %w", err)
+		}
 	}
 
 	return nil
 }
 
-// generateID генерирует уникальный ID для новой записи
-func (r *FileRepository) generateID(entityType string) (int64, error) {
-	var counters map[string]int64
-	if err := r.loadFromFile("counters.json", &counters); err != nil {
-		return 0, fmt.Errorf("не удалось загрузить счетчики: %w", err)
+// syncCounters поднимает счетчики ID до наибольшего ID в таблицах
+func (db *fileDB) syncCounters() {
+	for _, t := range db.tables {
+		if t.counterName() == "" {
+			continue
+		}
+		if maxID := t.maxID(); db.counters[t.counterName()] < maxID {
+			db.counters[t.counterName()] = maxID
+			db.unsaved[countersFilename] = true
+		}
 	}
+}
 
-	// Увеличиваем счетчик для данного типа сущности
-	counters[entityType]++
-	newID := counters[entityType]
-
-	// Сохраняем обновленные счетчики
-	if err := r.saveToFile("counters.json", counters); err != nil {
-		return 0, fmt.Errorf("не удалось сохранить счетчики: %w", err)
+// backfillOrderRemainingAmounts заполняет RemainingAmount у заявок из старых файлов данных:
+// заявки на рынке доступны целиком, остальные уже заняты сделкой или закрыты
+func (r *FileRepository) backfillOrderRemainingAmounts() {
+	updated := 0
+	for _, stored := range r.db.orders.find("status", string(model.OrderStatusActive), string(model.OrderStatusHasResponses)) {
+		if stored.RemainingAmount == 0 {
+			order := cloneOrder(stored)
+			order.RemainingAmount = order.Amount
+			r.db.orders.put(order)
+			updated++
+		}
+	}
+	if updated > 0 {
+		log.Printf("[INFO] Заполнен остаток для %d старых заявок", updated)
 	}
-
-	return newID, nil
 }
 
-// Close закрывает файловый репозиторий (заглушка для совместимости)
+// Close сохраняет изменения из журнала в файлы данных и закрывает файловый репозиторий
 func (r *FileRepository) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.db.checkpoint(); err != nil {
+		return fmt.Errorf("не удалось сохранить файлы данных: %w", err)
+	}
+
 	log.Println("[INFO] Файловый репозиторий закрыт")
 	return nil
 }
@@ -135,128 +206,105 @@ func (r *FileRepository) Close() error {
 // =====================================================
 
 // CreateUser создает нового пользователя в JSON файле
-func (r *FileRepository) CreateUser(user *model.User) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
-
-	// Загружаем существующих пользователей
-	var users []model.User
-	if err := r.loadFromFile("users.json", &users); err != nil {
-		return fmt.Errorf("не удалось загрузить пользователей: %w", err)
-	}
+func (r *FileRepository) CreateUser(user *model.User) (err error) {
+	r.lock()
+	defer r.unlock(&err)
 
 	// Проверяем что пользователь с таким Telegram ID не существует
-	for _, existingUser := range users {
-		if existingUser.TelegramID == user.TelegramID {
-			return fmt.Errorf("пользователь с Telegram ID %d уже существует", user.TelegramID)
-		}
-	}
-
-	// Генерируем новый ID
-	newID, err := r.generateID("users")
-	if err != nil {
-		return err
+	if r.db.users.first("telegram", indexKey(user.TelegramID)) != nil {
+		return fmt.Errorf("пользователь с Telegram ID %d уже существует", user.TelegramID)
 	}
 
 	// Заполняем системные поля
-	user.ID = newID
+	user.ID = r.db.nextID("users")
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
 	user.IsActive = true
 	user.Rating = 0.0
 	user.TotalDeals = 0
 	user.SuccessfulDeals = 0
-
-	// Добавляем пользователя к списку
-	users = append(users, *user)
-
-	// Сохраняем обновленный список
-	if err := r.saveToFile("users.json", users); err != nil {
-		return fmt.Errorf("не удалось сохранить пользователей: %w", err)
+	if user.ChatMember {
+		since := user.CreatedAt
+		user.ChatMemberSince = &since
 	}
 
-	log.Printf("[INFO] Создан пользователь в JSON: ID=%d, TelegramID=%d", user.ID, user.TelegramID)
-
-	// Принудительно синхронизируем файловую систему
-	time.Sleep(10 * time.Millisecond)
+	r.db.users.put(cloneUser(user))
 
+	log.Printf("[INFO] Создан пользователь в JSON: ID=%d, TelegramID=%d", user.ID, user.TelegramID)
 	return nil
 }
 
 // GetUserByTelegramID находит пользователя по Telegram ID
 func (r *FileRepository) GetUserByTelegramID(telegramID int64) (*model.User, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+	r.rlock()
+	defer r.runlock()
 
-	// Загружаем пользователей
-	var users []model.User
-	if err := r.loadFromFile("users.json", &users); err != nil {
-		return nil, fmt.Errorf("не удалось загрузить пользователей: %w", err)
+	user := r.db.users.first("telegram", indexKey(telegramID))
+	if user == nil {
+		return nil, fmt.Errorf("пользователь с Telegram ID %d не найден", telegramID)
 	}
-
-	// Ищем пользователя по Telegram ID
-	for _, user := range users {
-		if user.TelegramID == telegramID {
-			return &user, nil
-		}
-	}
-
-	return nil, fmt.Errorf("пользователь с Telegram ID %d не найден", telegramID)
+	return cloneUser(user), nil
 }
 
 // GetUserByID находит пользователя по внутреннему ID
 func (r *FileRepository) GetUserByID(userID int64) (*model.User, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+	r.rlock()
+	defer r.runlock()
 
-	// Загружаем пользователей
-	var users []model.User
-	if err := r.loadFromFile("users.json", &users); err != nil {
-		return nil, fmt.Errorf("не удалось загрузить пользователей: %w", err)
+	user := r.db.users.get(userID)
+	if user == nil {
+		return nil, fmt.Errorf("пользователь с ID %d не найден", userID)
 	}
-
-	// Ищем пользователя по ID
-	for _, user := range users {
-		if user.ID == userID {
-			return &user, nil
-		}
-	}
-
-	return nil, fmt.Errorf("пользователь с ID %d не найден", userID)
+	return cloneUser(user), nil
 }
 
 // UpdateUserChatMembership обновляет статус членства в чате
-func (r *FileRepository) UpdateUserChatMembership(telegramID int64, isMember bool) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+func (r *FileRepository) UpdateUserChatMembership(telegramID int64, isMember bool) (err error) {
+	r.lock()
+	defer r.unlock(&err)
 
-	// Загружаем пользователей
-	var users []model.User
-	if err := r.loadFromFile("users.json", &users); err != nil {
-		return fmt.Errorf("не удалось загрузить пользователей: %w", err)
+	stored := r.db.users.first("telegram", indexKey(telegramID))
+	if stored == nil {
+		return fmt.Errorf("пользователь с Telegram ID %d не найден", telegramID)
 	}
 
-	// Находим и обновляем пользователя
-	found := false
-	for i, user := range users {
-		if user.TelegramID == telegramID {
-			users[i].ChatMember = isMember
-			users[i].UpdatedAt = time.Now()
-			found = true
-			break
-		}
+	user := cloneUser(stored)
+	// Дата вступления сохраняется, пока пользователь остается в чате
+	if !isMember {
+		user.ChatMemberSince = nil
+	} else if !user.ChatMember || user.ChatMemberSince == nil {
+		now := time.Now()
+		user.ChatMemberSince = &now
 	}
+	user.ChatMember = isMember
+	user.UpdatedAt = time.Now()
+	r.db.users.put(user)
 
-	if !found {
-		return fmt.Errorf("пользователь с Telegram ID %d не найден", telegramID)
+	log.Printf("[INFO] Обновлен статус членства пользователя TelegramID=%d: %t", telegramID, isMember)
+	return nil
+}
+
+// UpdateUserDealStats учитывает закрытую сделку в статистике пользователя
+// successful - сделка завершена успешно (иначе отменена или истекла)
+func (r *FileRepository) UpdateUserDealStats(userID int64, successful bool) (err error) {
+	r.lock()
+	defer r.unlock(&err)
+
+	stored := r.db.users.get(userID)
+	if stored == nil {
+		return fmt.Errorf("пользователь с ID %d не найден", userID)
 	}
 
-	// Сохраняем изменения
-	if err := r.saveToFile("users.json", users); err != nil {
-		return fmt.Errorf("не удалось сохранить пользователей: %w", err)
+	user := cloneUser(stored)
+	user.TotalDeals++
+	if successful {
+		user.SuccessfulDeals++
 	}
+	user.UpdatedAt = time.Now()
+	r.db.users.put(user)
 
-	log.Printf("[INFO] Обновлен статус членства пользователя TelegramID=%d: %t", telegramID, isMember)
+	log.Printf("[INFO] Обновлена статистика сделок пользователя ID=%d: всего=%d, успешных=%d",
+		userID, user.TotalDeals, user.SuccessfulDeals)
 	return nil
 }
 
@@ -265,39 +313,23 @@ func (r *FileRepository) UpdateUserChatMembership(telegramID int64, isMember boo
 // =====================================================
 
 // CreateOrder создает новую заявку в JSON файле
-func (r *FileRepository) CreateOrder(order *model.Order) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
-
-	// Загружаем существующие заявки
-	var orders []model.Order
-	if err := r.loadFromFile("orders.json", &orders); err != nil {
-		return fmt.Errorf("не удалось загрузить заявки: %w", err)
-	}
-
-	// Генерируем новый ID
-	newID, err := r.generateID("orders")
-	if err != nil {
-		return err
-	}
+func (r *FileRepository) CreateOrder(order *model.Order) (err error) {
+	r.lock()
+	defer r.unlock(&err)
 
 	// Заполняем системные поля
-	order.ID = newID
+	order.ID = r.db.nextID("orders")
 	order.CreatedAt = time.Now()
 	order.UpdatedAt = time.Now()
 	order.Status = model.OrderStatusActive
 	order.IsActive = true
-	// Устанавливаем ExpiresAt в далекое будущее - таймеры больше не используются
-	order.ExpiresAt = time.Now().Add(365 * 24 * time.Hour) // 1 год
-
-	// Добавляем заявку к списку
-	orders = append(orders, *order)
-
-	// Сохраняем обновленный список
-	if err := r.saveToFile("orders.json", orders); err != nil {
-		return fmt.Errorf("не удалось сохранить заявки: %w", err)
+	// Срок действия задается сервисом, по умолчанию заявка живет 1 год
+	if order.ExpiresAt.IsZero() {
+		order.ExpiresAt = time.Now().Add(365 * 24 * time.Hour)
 	}
 
+	r.db.orders.put(cloneOrder(order))
+
 	log.Printf("[INFO] Создана заявка в JSON: ID=%d, Type=%s, Amount=%.8f",
 		order.ID, order.Type, order.Amount)
 	return nil
@@ -305,21 +337,14 @@ func (r *FileRepository) CreateOrder(order *model.Order) error {
 
 // GetOrdersByFilter получает заявки по фильтрам с пагинацией
 func (r *FileRepository) GetOrdersByFilter(filter *model.OrderFilter) ([]*model.Order, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+	r.rlock()
+	defer r.runlock()
 
-	// Загружаем все заявки
-	var orders []model.Order
-	if err := r.loadFromFile("orders.json", &orders); err != nil {
-		return nil, fmt.Errorf("не удалось загрузить заявки: %w", err)
-	}
-
-	// Фильтруем заявки
+	// Фильтруем заявки из самого узкого подходящего индекса
 	var filtered []*model.Order
-	for _, order := range orders {
-		if r.matchesFilter(&order, filter) {
-			orderCopy := order // Создаем копию для избежания проблем с указателями
-			filtered = append(filtered, &orderCopy)
+	for _, order := range r.orderCandidates(filter) {
+		if r.matchesFilter(order, filter) {
+			filtered = append(filtered, order)
 		}
 	}
 
@@ -337,12 +362,39 @@ func (r *FileRepository) GetOrdersByFilter(filter *model.OrderFilter) ([]*model.
 		end = len(filtered)
 	}
 
-	result := filtered[start:end]
+	result := r.db.orders.clones(filtered[start:end])
 	log.Printf("[INFO] Найдено заявок по фильтру: %d (показано %d)", len(filtered), len(result))
 
 	return result, nil
 }
 
+// orderCandidates выбирает заявки для проверки фильтром: по пользователю, статусу или паре -
+// в зависимости от того, какой из заданных в фильтре индексов дает меньше записей
+func (r *FileRepository) orderCandidates(filter *model.OrderFilter) []*model.Order {
+	orders := r.db.orders
+	index, key, best := "", "", -1
+	consider := func(name, value string) {
+		if count := orders.count(name, value); best < 0 || count < best {
+			index, key, best = name, value, count
+		}
+	}
+
+	if filter.UserID != nil {
+		consider("user", indexKey(*filter.UserID))
+	}
+	if filter.Status != nil {
+		consider("status", string(*filter.Status))
+	}
+	if filter.Cryptocurrency != nil && filter.FiatCurrency != nil {
+		consider("pair", orderPair(*filter.Cryptocurrency, *filter.FiatCurrency))
+	}
+
+	if best < 0 {
+		return orders.all()
+	}
+	return orders.find(index, key)
+}
+
 // matchesFilter проверяет соответствует ли заявка фильтру
 func (r *FileRepository) matchesFilter(order *model.Order, filter *model.OrderFilter) bool {
 	// Проверяем активность (только активные заявки по умолчанию, кроме случая включения неактивных)
@@ -388,6 +440,7 @@ func (r *FileRepository) matchesFilter(order *model.Order, filter *model.OrderFi
 }
 
 // sortOrders сортирует массив заявок согласно параметрам
+// Сортировка устойчивая: при равных значениях заявки остаются в порядке создания (по ID)
 func (r *FileRepository) sortOrders(orders []*model.Order, filter *model.OrderFilter) {
 	sortBy := filter.SortBy
 	if sortBy == "" {
@@ -396,116 +449,104 @@ func (r *FileRepository) sortOrders(orders []*model.Order, filter *model.OrderFi
 
 	ascending := filter.SortOrder == "asc"
 
-	sort.Slice(orders, func(i, j int) bool {
-		var less bool
+	less := func(a, b *model.Order) bool {
 		switch sortBy {
 		case "price":
-			less = orders[i].Price < orders[j].Price
+			return a.Price < b.Price
 		case "amount":
-			less = orders[i].Amount < orders[j].Amount
-		case "created_at":
-			less = orders[i].CreatedAt.Before(orders[j].CreatedAt)
+			return a.Amount < b.Amount
 		default:
-			less = orders[i].CreatedAt.Before(orders[j].CreatedAt)
+			return a.CreatedAt.Before(b.CreatedAt)
 		}
+	}
 
+	sort.SliceStable(orders, func(i, j int) bool {
 		if ascending {
-			return less
+			return less(orders[i], orders[j])
 		}
-		return !less
+		return less(orders[j], orders[i])
 	})
 }
 
 // UpdateOrderStatus обновляет статус заявки
-func (r *FileRepository) UpdateOrderStatus(orderID int64, status model.OrderStatus) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+func (r *FileRepository) UpdateOrderStatus(orderID int64, status model.OrderStatus) (err error) {
+	r.lock()
+	defer r.unlock(&err)
 
-	// Загружаем заявки
-	var orders []model.Order
-	if err := r.loadFromFile("orders.json", &orders); err != nil {
-		return fmt.Errorf("не удалось загрузить заявки: %w", err)
+	stored := r.db.orders.get(orderID)
+	if stored == nil {
+		return fmt.Errorf("заявка с ID %d не найдена", orderID)
 	}
 
-	// Находим и обновляем заявку
-	found := false
-	for i, order := range orders {
-		if order.ID == orderID {
-			orders[i].Status = status
-			orders[i].UpdatedAt = time.Now()
-
-			// Если заявка отменена или завершена, делаем ее неактивной
-			if status == model.OrderStatusCancelled || status == model.OrderStatusCompleted {
-				orders[i].IsActive = false
-			}
+	order := cloneOrder(stored)
+	order.Status = status
+	order.UpdatedAt = time.Now()
 
-			found = true
-			break
-		}
+	// Если заявка отменена или завершена, делаем ее неактивной
+	if status == model.OrderStatusCancelled || status == model.OrderStatusCompleted {
+		order.IsActive = false
 	}
+	r.db.orders.put(order)
 
-	if !found {
-		return fmt.Errorf("заявка с ID %d не найдена", orderID)
-	}
+	log.Printf("[INFO] Обновлен статус заявки ID=%d: %s", orderID, status)
+	return nil
+}
 
-	// Сохраняем изменения
-	if err := r.saveToFile("orders.json", orders); err != nil {
-		return fmt.Errorf("не удалось сохранить заявки: %w", err)
+// UpdateOrderPrice сохраняет пересчитанную цену заявки с плавающей ценой и общую сумму по ней
+// для объема, еще не занятого сделками
+func (r *FileRepository) UpdateOrderPrice(orderID int64, price float64) (err error) {
+	r.lock()
+	defer r.unlock(&err)
+
+	stored := r.db.orders.get(orderID)
+	if stored == nil {
+		return fmt.Errorf("заявка с ID %d не найдена", orderID)
 	}
 
-	log.Printf("[INFO] Обновлен статус заявки ID=%d: %s", orderID, status)
+	order := cloneOrder(stored)
+	order.Price = price
+	order.TotalAmount = order.RemainingAmount * price
+	r.db.orders.put(order)
 	return nil
 }
 
 // GetOrderByID получает заявку по ID
 func (r *FileRepository) GetOrderByID(orderID int64) (*model.Order, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+	r.rlock()
+	defer r.runlock()
 
 	return r.getOrderByID(orderID)
 }
 
 // UpdateOrder обновляет существующую заявку
-func (r *FileRepository) UpdateOrder(order *model.Order) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
-
-	// Загружаем заявки
-	var orders []model.Order
-	if err := r.loadFromFile("orders.json", &orders); err != nil {
-		return fmt.Errorf("не удалось загрузить заявки: %w", err)
-	}
-
-	// Находим и обновляем заявку
-	found := false
-	for i, existingOrder := range orders {
-		if existingOrder.ID == order.ID {
-			// Обновляем все поля кроме системных
-			orders[i].Type = order.Type
-			orders[i].Cryptocurrency = order.Cryptocurrency
-			orders[i].FiatCurrency = order.FiatCurrency
-			orders[i].Amount = order.Amount
-			orders[i].Price = order.Price
-			orders[i].TotalAmount = order.TotalAmount
-			orders[i].MinAmount = order.MinAmount
-			orders[i].MaxAmount = order.MaxAmount
-			orders[i].PaymentMethods = order.PaymentMethods
-			orders[i].Description = order.Description
-			orders[i].UpdatedAt = time.Now()
-
-			found = true
-			break
-		}
-	}
+func (r *FileRepository) UpdateOrder(order *model.Order) (err error) {
+	r.lock()
+	defer r.unlock(&err)
 
-	if !found {
+	stored := r.db.orders.get(order.ID)
+	if stored == nil {
 		return fmt.Errorf("заявка с ID %d не найдена", order.ID)
 	}
 
-	// Сохраняем изменения
-	if err := r.saveToFile("orders.json", orders); err != nil {
-		return fmt.Errorf("не удалось сохранить заявки: %w", err)
-	}
+	// Обновляем все поля кроме системных
+	updated := cloneOrder(stored)
+	source := cloneOrder(order)
+	updated.Type = source.Type
+	updated.Cryptocurrency = source.Cryptocurrency
+	updated.FiatCurrency = source.FiatCurrency
+	updated.Amount = source.Amount
+	updated.Price = source.Price
+	updated.TotalAmount = source.TotalAmount
+	updated.MinAmount = source.MinAmount
+	updated.MaxAmount = source.MaxAmount
+	updated.RemainingAmount = source.RemainingAmount
+	updated.PaymentMethods = source.PaymentMethods
+	updated.Description = source.Description
+	updated.AutoAccept = source.AutoAccept
+	updated.PriceType = source.PriceType
+	updated.PriceMargin = source.PriceMargin
+	updated.UpdatedAt = time.Now()
+	r.db.orders.put(updated)
 
 	log.Printf("[INFO] Обновлена заявка ID=%d: Type=%s, Amount=%.8f", order.ID, order.Type, order.Amount)
 	return nil
@@ -513,6 +554,11 @@ func (r *FileRepository) UpdateOrder(order *model.Order) error {
 
 // HealthCheck проверяет доступность файлового хранилища
 func (r *FileRepository) HealthCheck() error {
+	// Хранилищу в памяти папка данных не нужна
+	if !r.db.persistent() {
+		return nil
+	}
+
 	// Проверяем доступность папки данных
 	if _, err := os.Stat(r.dataDir); os.IsNotExist(err) {
 		return fmt.Errorf("папка данных недоступна: %s", r.dataDir)
@@ -520,7 +566,7 @@ func (r *FileRepository) HealthCheck() error {
 
 	// Проверяем возможность записи в папку
 	testFile := filepath.Join(r.dataDir, ".health_check")
-	if err := ioutil.WriteFile(testFile, []byte("test"), 0644); err != nil {
+	if err := os.WriteFile(testFile, []byte("test"), 0644); err != nil {
 		return fmt.Errorf("невозможно записать в папку данных: %w", err)
 	}
 
@@ -530,23 +576,215 @@ func (r *FileRepository) HealthCheck() error {
 	return nil
 }
 
+// GetExpiredOrders получает заявки на рынке (active/has_responses), срок действия которых истек к моменту now
+func (r *FileRepository) GetExpiredOrders(now time.Time) ([]*model.Order, error) {
+	r.rlock()
+	defer r.runlock()
+
+	var expired []*model.Order
+	for _, order := range r.marketOrders() {
+		if !order.ExpiresAt.IsZero() && !order.ExpiresAt.After(now) {
+			expired = append(expired, order)
+		}
+	}
+
+	return r.db.orders.clones(expired), nil
+}
+
+// ExpireOrder переводит заявку в статус "expired", если она еще на рынке и ее срок истек
+func (r *FileRepository) ExpireOrder(orderID int64, now time.Time) (err error) {
+	r.lock()
+	defer r.unlock(&err)
+
+	stored := r.db.orders.get(orderID)
+	if stored == nil {
+		return fmt.Errorf("заявка с ID %d не найдена", orderID)
+	}
+
+	if !isOrderOnMarket(stored) || stored.ExpiresAt.After(now) {
+		return fmt.Errorf("заявка ID=%d не может быть истекшей (статус %s)", orderID, stored.Status)
+	}
+
+	order := cloneOrder(stored)
+	order.Status = model.OrderStatusExpired
+	order.IsActive = false
+	order.UpdatedAt = now
+	r.db.orders.put(order)
+
+	log.Printf("[INFO] Заявка ID=%d истекла", orderID)
+	return nil
+}
+
+// UpdateOrderExpiration устанавливает новый срок действия заявки
+func (r *FileRepository) UpdateOrderExpiration(orderID int64, expiresAt time.Time) (err error) {
+	r.lock()
+	defer r.unlock(&err)
+
+	stored := r.db.orders.get(orderID)
+	if stored == nil {
+		return fmt.Errorf("заявка с ID %d не найдена", orderID)
+	}
+
+	order := cloneOrder(stored)
+	order.ExpiresAt = expiresAt
+	order.UpdatedAt = time.Now()
+	r.db.orders.put(order)
+
+	log.Printf("[INFO] Срок заявки ID=%d продлен до %s", orderID, expiresAt.Format(time.RFC3339))
+	return nil
+}
+
+// ReserveOrderAmount занимает часть заявки под новую сделку
+// Заявка должна быть на рынке и иметь достаточный остаток; исчерпанная заявка снимается с рынка (in_deal)
+func (r *FileRepository) ReserveOrderAmount(orderID int64, amount float64) (_ *model.Order, err error) {
+	r.lock()
+	defer r.unlock(&err)
+
+	stored := r.db.orders.get(orderID)
+	if stored == nil {
+		return nil, fmt.Errorf("заявка с ID %d не найдена", orderID)
+	}
+
+	if !isOrderOnMarket(stored) {
+		return nil, fmt.Errorf("заявка ID=%d в статусе '%s' недоступна для сделок", orderID, stored.Status)
+	}
+	if amount > stored.RemainingAmount+model.AmountEpsilon {
+		return nil, fmt.Errorf("в заявке ID=%d осталось только %.8f", orderID, stored.RemainingAmount)
+	}
+
+	order := cloneOrder(stored)
+	order.RemainingAmount = model.RoundAmount(order.RemainingAmount - amount)
+	if order.IsFilled() {
+		order.RemainingAmount = 0
+		order.Status = model.OrderStatusInDeal
+	}
+	order.UpdatedAt = time.Now()
+	r.db.orders.put(order)
+
+	log.Printf("[INFO] Из заявки ID=%d занято %.8f, остаток %.8f (%s)",
+		orderID, amount, order.RemainingAmount, order.Status)
+	return cloneOrder(order), nil
+}
+
+// ReleaseOrderAmount возвращает в заявку объем закрытой без исполнения сделки
+// Снятая с рынка из-за исчерпания заявка возвращается на рынок
+func (r *FileRepository) ReleaseOrderAmount(orderID int64, amount float64) (err error) {
+	r.lock()
+	defer r.unlock(&err)
+
+	stored := r.db.orders.get(orderID)
+	if stored == nil {
+		return fmt.Errorf("заявка с ID %d не найдена", orderID)
+	}
+
+	order := cloneOrder(stored)
+	order.RemainingAmount = model.RoundAmount(order.RemainingAmount + amount)
+	if order.RemainingAmount > order.Amount {
+		order.RemainingAmount = order.Amount
+	}
+	if order.Status == model.OrderStatusInDeal {
+		order.Status = model.OrderStatusActive
+	}
+	order.UpdatedAt = time.Now()
+	r.db.orders.put(order)
+
+	log.Printf("[INFO] В заявку ID=%d возвращено %.8f, остаток %.8f (%s)",
+		orderID, amount, order.RemainingAmount, order.Status)
+	return nil
+}
+
+// isOrderOnMarket проверяет, что заявка выставлена на рынке и доступна для откликов
+func isOrderOnMarket(order *model.Order) bool {
+	return order.Status == model.OrderStatusActive || order.Status == model.OrderStatusHasResponses
+}
+
+// marketOrders возвращает заявки на рынке (active/has_responses) из индекса статусов
+func (r *FileRepository) marketOrders() []*model.Order {
+	return r.db.orders.find("status", string(model.OrderStatusActive), string(model.OrderStatusHasResponses))
+}
+
+// orderPair ключ индекса заявок по торговой паре
+func orderPair(cryptocurrency, fiatCurrency string) string {
+	return cryptocurrency + "/" + fiatCurrency
+}
+
+// GetOrderBook строит стакан заявок по торговой паре за один проход по заявкам пары:
+// свободные остатки заявок на рынке суммируются по ценовым уровням каждой стороны
+func (r *FileRepository) GetOrderBook(filter *model.OrderBookFilter) (*model.OrderBook, error) {
+	r.rlock()
+	defer r.runlock()
+
+	now := time.Now()
+	bids := make(map[float64]*model.OrderBookLevel)
+	asks := make(map[float64]*model.OrderBookLevel)
+	for _, order := range r.db.orders.find("pair", orderPair(filter.Cryptocurrency, filter.FiatCurrency)) {
+		if !isOrderOnMarket(order) || !order.IsActive || order.IsFilled() {
+			continue
+		}
+		if !order.ExpiresAt.IsZero() && !order.ExpiresAt.After(now) {
+			continue
+		}
+		if filter.PaymentMethod != "" && !order.HasPaymentMethod(filter.PaymentMethod) {
+			continue
+		}
+
+		levels := asks
+		if order.Type == model.OrderTypeBuy {
+			levels = bids
+		}
+		level, ok := levels[order.Price]
+		if !ok {
+			level = &model.OrderBookLevel{Price: order.Price}
+			levels[order.Price] = level
+		}
+		level.Amount = model.RoundAmount(level.Amount + order.RemainingAmount)
+		level.OrderCount++
+	}
+
+	book := &model.OrderBook{
+		Cryptocurrency: filter.Cryptocurrency,
+		FiatCurrency:   filter.FiatCurrency,
+		PaymentMethod:  filter.PaymentMethod,
+		Bids:           sortOrderBookLevels(bids, true, filter.Depth),
+		Asks:           sortOrderBookLevels(asks, false, filter.Depth),
+		UpdatedAt:      now,
+	}
+	return book, nil
+}
+
+// sortOrderBookLevels сортирует уровни стакана от лучшей цены и оставляет depth уровней
+// Для покупок лучшая цена - наибольшая (descending), для продаж - наименьшая
+func sortOrderBookLevels(levels map[float64]*model.OrderBookLevel, descending bool, depth int) []model.OrderBookLevel {
+	result := make([]model.OrderBookLevel, 0, len(levels))
+	for _, level := range levels {
+		level.Total = level.Amount * level.Price
+		result = append(result, *level)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if descending {
+			return result[i].Price > result[j].Price
+		}
+		return result[i].Price < result[j].Price
+	})
+
+	if depth > 0 && len(result) > depth {
+		result = result[:depth]
+	}
+	return result
+}
+
 // =====================================================
 // АВТОМАТИЧЕСКОЕ СОПОСТАВЛЕНИЕ ЗАЯВОК
 // =====================================================
 
 // GetMatchingOrders ищет заявки противоположного типа для автосопоставления
 func (r *FileRepository) GetMatchingOrders(order *model.Order) ([]*model.Order, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+	r.rlock()
+	defer r.runlock()
 
 	log.Printf("[INFO] Поиск подходящих заявок для Order ID=%d, Type=%s", order.ID, order.Type)
 
-	// Загружаем все заявки
-	var orders []model.Order
-	if err := r.loadFromFile("orders.json", &orders); err != nil {
-		return nil, fmt.Errorf("не удалось загрузить заявки для сопоставления: %w", err)
-	}
-
 	// Определяем противоположный тип заявки
 	var oppositeType model.OrderType
 	if order.Type == model.OrderTypeBuy {
@@ -557,21 +795,18 @@ func (r *FileRepository) GetMatchingOrders(order *model.Order) ([]*model.Order,
 
 	var matchingOrders []*model.Order
 
-	// Фильтруем подходящие заявки
-	for _, candidateOrder := range orders {
+	// Фильтруем подходящие заявки той же торговой пары
+	for _, candidateOrder := range r.db.orders.find("pair", orderPair(order.Cryptocurrency, order.FiatCurrency)) {
 		// Проверяем все критерии для сопоставления
 		if candidateOrder.Type == oppositeType && // Противоположный тип
-			candidateOrder.Cryptocurrency == order.Cryptocurrency && // Та же криптовалюта
-			candidateOrder.FiatCurrency == order.FiatCurrency && // Та же фиатная валюта
-			candidateOrder.Status == model.OrderStatusActive && // Активная заявка
+			isOrderOnMarket(candidateOrder) && // Заявка на рынке
+			!candidateOrder.IsFilled() && // Есть свободный остаток
 			candidateOrder.IsActive && // Не отключена
 			candidateOrder.UserID != order.UserID { // Не наша заявка
-			// Убираем проверку ExpiresAt - таймеры больше не используются
 
 			// Проверяем совместимость цен
-			if r.isPriceCompatible(order, &candidateOrder) {
-				orderCopy := candidateOrder
-				matchingOrders = append(matchingOrders, &orderCopy)
+			if r.isPriceCompatible(order, candidateOrder) {
+				matchingOrders = append(matchingOrders, candidateOrder)
 			}
 		}
 	}
@@ -585,7 +820,7 @@ func (r *FileRepository) GetMatchingOrders(order *model.Order) ([]*model.Order,
 	}
 
 	log.Printf("[INFO] Найдено подходящих заявок для Order ID=%d: %d", order.ID, len(matchingOrders))
-	return matchingOrders, nil
+	return r.db.orders.clones(matchingOrders), nil
 }
 
 // isPriceCompatible проверяет совместимость цен для автосопоставления
@@ -601,7 +836,7 @@ func (r *FileRepository) isPriceCompatible(order1, order2 *model.Order) bool {
 
 // sortMatchingOrders сортирует подходящие заявки по оптимальным ценам
 func (r *FileRepository) sortMatchingOrders(orders []*model.Order, orderType model.OrderType) {
-	sort.Slice(orders, func(i, j int) bool {
+	sort.SliceStable(orders, func(i, j int) bool {
 		// Для заявки покупки: сначала самые дешевые продажи
 		if orderType == model.OrderTypeBuy {
 			if orders[i].Price != orders[j].Price {
@@ -619,232 +854,332 @@ func (r *FileRepository) sortMatchingOrders(orders []*model.Order, orderType mod
 	})
 }
 
-// MatchOrders создает сделку между двумя сопоставленными заявками
-func (r *FileRepository) MatchOrders(orderID1, orderID2 int64) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+// =====================================================
+// УПРАВЛЕНИЕ СДЕЛКАМИ
+// =====================================================
 
-	log.Printf("[INFO] Сопоставление заявок: Order1 ID=%d, Order2 ID=%d", orderID1, orderID2)
+// CreateDeal создает новую сделку между пользователями
+func (r *FileRepository) CreateDeal(deal *model.Deal) (err error) {
+	r.lock()
+	defer r.unlock(&err)
 
-	// Загружаем все заявки
-	var orders []model.Order
-	if err := r.loadFromFile("orders.json", &orders); err != nil {
-		return fmt.Errorf("не удалось загрузить заявки для сопоставления: %w", err)
-	}
+	log.Printf("[INFO] Создание сделки: Author ID=%d, Counterparty ID=%d, Amount=%.8f %s",
+		deal.AuthorID, deal.CounterpartyID, deal.Amount, deal.Cryptocurrency)
 
-	// Находим обе заявки
-	var order1, order2 *model.Order
-	for i := range orders {
-		if orders[i].ID == orderID1 {
-			order1 = &orders[i]
-		} else if orders[i].ID == orderID2 {
-			order2 = &orders[i]
-		}
+	// Устанавливаем ID и временные метки
+	deal.ID = r.db.nextID("deals")
+	// Сделка создается уже в процессе, не требует дополнительного подтверждения создания
+	if deal.Status == "" {
+		deal.Status = model.DealStatusInProgress // Статус "в процессе" - можно подтверждать платежи
 	}
+	deal.CreatedAt = time.Now()
 
-	if order1 == nil || order2 == nil {
-		return fmt.Errorf("не удалось найти одну или обе заявки для сопоставления")
-	}
+	r.db.deals.put(cloneDeal(deal))
 
-	// Проверяем что заявки можно сопоставить
-	if order1.Type == order2.Type {
-		return fmt.Errorf("нельзя сопоставить заявки одинакового типа")
-	}
+	log.Printf("[INFO] Сделка успешно создана: ID=%d", deal.ID)
+	return nil
+}
 
-	if order1.Cryptocurrency != order2.Cryptocurrency || order1.FiatCurrency != order2.FiatCurrency {
-		return fmt.Errorf("заявки имеют разные валюты")
-	}
+// GetDealsByUserID получает все сделки пользователя (как покупателя и продавца)
+func (r *FileRepository) GetDealsByUserID(userID int64) ([]*model.Deal, error) {
+	r.rlock()
+	defer r.runlock()
 
-	// Обновляем статусы заявок на "matched" (сопоставлено)
-	now := time.Now()
+	log.Printf("[INFO] Получение сделок для пользователя ID=%d", userID)
 
-	// Обновляем первую заявку
-	for i := range orders {
-		if orders[i].ID == orderID1 {
-			orders[i].Status = model.OrderStatusInDeal
-			orders[i].UpdatedAt = now
-			break
-		}
+	// Пользователь участвует в сделке если он автор или контрагент
+	userDeals := r.db.deals.clones(r.db.deals.find("user", indexKey(userID)))
+
+	// Сортируем по дате создания (новые сначала)
+	sort.SliceStable(userDeals, func(i, j int) bool {
+		return userDeals[i].CreatedAt.After(userDeals[j].CreatedAt)
+	})
+
+	log.Printf("[INFO] Найдено сделок для пользователя ID=%d: %d", userID, len(userDeals))
+	return userDeals, nil
+}
+
+// GetDealByID получает сделку по её ID
+func (r *FileRepository) GetDealByID(dealID int64) (*model.Deal, error) {
+	r.rlock()
+	defer r.runlock()
+
+	log.Printf("[INFO] Получение сделки по ID=%d", dealID)
+
+	deal := r.db.deals.get(dealID)
+	if deal == nil {
+		log.Printf("[WARN] Сделка с ID=%d не найдена", dealID)
+		return nil, fmt.Errorf("сделка с ID=%d не найдена", dealID)
 	}
 
-	// Обновляем вторую заявку
-	for i := range orders {
-		if orders[i].ID == orderID2 {
-			orders[i].Status = model.OrderStatusInDeal
-			orders[i].UpdatedAt = now
-			break
-		}
+	log.Printf("[INFO] Сделка найдена: ID=%d, Status=%s", deal.ID, deal.Status)
+	return cloneDeal(deal), nil
+}
+
+// transitionDeal применяет к сделке переход из таблицы model.DealTransitions:
+// находит сделку, проверяет допустимость действия для ее текущего статуса,
+// вызывает apply для заполнения сопутствующих полей и сохраняет новый статус.
+// Ошибка apply отменяет переход
+func (r *FileRepository) transitionDeal(dealID int64, action func(deal *model.Deal) model.DealAction, apply func(deal *model.Deal, transition model.DealTransition) error) (err error) {
+	r.lock()
+	defer r.unlock(&err)
+
+	stored := r.db.deals.get(dealID)
+	if stored == nil {
+		return fmt.Errorf("сделка ID=%d не найдена", dealID)
 	}
 
-	// Сохраняем обновленные заявки
-	if err := r.saveToFile("orders.json", orders); err != nil {
-		return fmt.Errorf("не удалось сохранить сопоставленные заявки: %w", err)
+	deal := cloneDeal(stored)
+	transition, err := model.FindDealTransition(deal.Status, action(deal))
+	if err != nil {
+		return fmt.Errorf("сделка ID=%d: %w", dealID, err)
+	}
+	if err := apply(deal, transition); err != nil {
+		return err
 	}
+	deal.Status = transition.To
+	r.db.deals.put(deal)
 
-	log.Printf("[INFO] Заявки успешно сопоставлены: Order1 ID=%d, Order2 ID=%d", orderID1, orderID2)
+	log.Printf("[DEBUG] Сделка ID=%d: %s -> %s (%s)", dealID, transition.From, transition.To, transition.Action)
 	return nil
 }
 
-// =====================================================
-// УПРАВЛЕНИЕ СДЕЛКАМИ
-// =====================================================
-
-// CreateDeal создает новую сделку между пользователями
-func (r *FileRepository) CreateDeal(deal *model.Deal) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+// ConfirmDealWithRole подтверждает сделку с указанием роли пользователя
+// Первое подтверждение переводит сделку в ожидание второй стороны, второе - завершает ее.
+// Возвращает примененный переход, чтобы сервис выполнил его побочные эффекты
+func (r *FileRepository) ConfirmDealWithRole(dealID int64, userID int64, isAuthor bool, paymentProof string) (model.DealTransition, error) {
+	log.Printf("[INFO] Подтверждение сделки ID=%d пользователем ID=%d (isAuthor=%v)", dealID, userID, isAuthor)
 
-	log.Printf("[INFO] Создание сделки: Author ID=%d, Counterparty ID=%d, Amount=%.8f %s",
-		deal.AuthorID, deal.CounterpartyID, deal.Amount, deal.Cryptocurrency)
+	var applied model.DealTransition
+	confirmAction := func(deal *model.Deal) model.DealAction { return deal.ConfirmAction(isAuthor) }
 
-	// Загружаем существующие сделки
-	var deals []model.Deal
-	if err := r.loadFromFile("deals.json", &deals); err != nil {
-		return fmt.Errorf("не удалось загрузить сделки: %w", err)
-	}
+	err := r.transitionDeal(dealID, confirmAction, func(deal *model.Deal, transition model.DealTransition) error {
+		applied = transition
+		if isAuthor {
+			deal.AuthorConfirmed = true
+			deal.AuthorProof = paymentProof
+		} else {
+			deal.CounterConfirmed = true
+			deal.CounterProof = paymentProof
+		}
 
-	// Генерируем новый ID для сделки
-	dealID, err := r.generateID("deals")
+		if transition.To == model.DealStatusCompleted {
+			now := time.Now()
+			deal.CompletedAt = &now
+			log.Printf("[INFO] Сделка ID=%d завершена - оба участника подтвердили", dealID)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("не удалось сгенерировать ID для сделки: %w", err)
+		return model.DealTransition{}, err
 	}
 
-	// Устанавливаем ID и временные метки
-	deal.ID = dealID
-	// Сделка создается уже в процессе, не требует дополнительного подтверждения создания
-	if deal.Status == "" {
-		deal.Status = model.DealStatusInProgress // Статус "в процессе" - можно подтверждать платежи
-	}
-	deal.CreatedAt = time.Now()
-
-	// Добавляем сделку в список
-	deals = append(deals, *deal)
+	log.Printf("[INFO] Сделка ID=%d успешно подтверждена пользователем ID=%d", dealID, userID)
+	return applied, nil
+}
 
-	// Сохраняем обновленный список сделок
-	if err := r.saveToFile("deals.json", deals); err != nil {
-		return fmt.Errorf("не удалось сохранить сделки: %w", err)
+// GetUnconfirmedDealsExpiringBefore получает активные сделки без единого подтверждения,
+// срок которых истекает не позже указанного момента
+func (r *FileRepository) GetUnconfirmedDealsExpiringBefore(deadline time.Time) ([]*model.Deal, error) {
+	r.rlock()
+	defer r.runlock()
+
+	var expiring []*model.Deal
+	active := r.db.deals.find("status", string(model.DealStatusInProgress), string(model.DealStatusWaitingConfirmation))
+	for _, deal := range active {
+		if isDealUnconfirmed(deal) && !deal.ExpiresAt.IsZero() && !deal.ExpiresAt.After(deadline) {
+			expiring = append(expiring, deal)
+		}
 	}
 
-	log.Printf("[INFO] Сделка успешно создана: ID=%d", deal.ID)
-	return nil
-}
+	// Сначала обрабатываем сделки с самым ранним сроком
+	sort.SliceStable(expiring, func(i, j int) bool {
+		return expiring[i].ExpiresAt.Before(expiring[j].ExpiresAt)
+	})
 
-// GetDealsByUserID получает все сделки пользователя (как покупателя и продавца)
-func (r *FileRepository) GetDealsByUserID(userID int64) ([]*model.Deal, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+	return r.db.deals.clones(expiring), nil
+}
 
-	log.Printf("[INFO] Получение сделок для пользователя ID=%d", userID)
+// MarkDealExpiryWarningSent отмечает, что предупреждение об истечении сделки отправлено
+func (r *FileRepository) MarkDealExpiryWarningSent(dealID int64) (err error) {
+	r.lock()
+	defer r.unlock(&err)
 
-	// Загружаем все сделки
-	var deals []model.Deal
-	if err := r.loadFromFile("deals.json", &deals); err != nil {
-		return nil, fmt.Errorf("не удалось загрузить сделки: %w", err)
+	stored := r.db.deals.get(dealID)
+	if stored == nil {
+		return fmt.Errorf("сделка ID=%d не найдена", dealID)
 	}
 
-	// Фильтруем сделки пользователя
-	var userDeals []*model.Deal
-	for _, deal := range deals {
-		// Пользователь участвует в сделке если он покупатель или продавец
-		if deal.AuthorID == userID || deal.CounterpartyID == userID {
-			dealCopy := deal
-			userDeals = append(userDeals, &dealCopy)
+	deal := cloneDeal(stored)
+	deal.ExpiryWarningSent = true
+	r.db.deals.put(deal)
+	return nil
+}
+
+// ExpireDeal переводит просроченную сделку в статус "expired"
+// Сделка истекает только если никто из участников ее не подтвердил и срок наступил к моменту now
+func (r *FileRepository) ExpireDeal(dealID int64, now time.Time) error {
+	err := r.transitionDeal(dealID, dealAction(model.DealActionExpire), func(deal *model.Deal, _ model.DealTransition) error {
+		if !isDealUnconfirmed(deal) {
+			return fmt.Errorf("сделка ID=%d уже подтверждена или завершена", dealID)
+		}
+		if deal.ExpiresAt.IsZero() || deal.ExpiresAt.After(now) {
+			return fmt.Errorf("срок сделки ID=%d еще не истек", dealID)
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	// Сортируем по дате создания (новые сначала)
-	sort.Slice(userDeals, func(i, j int) bool {
-		return userDeals[i].CreatedAt.After(userDeals[j].CreatedAt)
-	})
+	log.Printf("[INFO] Сделка ID=%d истекла без подтверждения", dealID)
+	return nil
+}
 
-	log.Printf("[INFO] Найдено сделок для пользователя ID=%d: %d", userID, len(userDeals))
-	return userDeals, nil
+// isDealUnconfirmed проверяет, что сделка активна и ни одна из сторон ее еще не подтвердила
+func isDealUnconfirmed(deal *model.Deal) bool {
+	active := deal.Status == model.DealStatusInProgress || deal.Status == model.DealStatusWaitingConfirmation
+	return active && !deal.AuthorConfirmed && !deal.CounterConfirmed
 }
 
-// GetDealByID получает сделку по её ID
-func (r *FileRepository) GetDealByID(dealID int64) (*model.Deal, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+// RequestDealCancellation сохраняет запрос участника на отмену активной сделки
+func (r *FileRepository) RequestDealCancellation(dealID, userID int64, reason string, requestedAt time.Time) error {
+	err := r.transitionDeal(dealID, dealAction(model.DealActionRequestCancel), func(deal *model.Deal, _ model.DealTransition) error {
+		deal.CancelRequestedBy = userID
+		deal.CancelRequestedAt = &requestedAt
+		deal.CancelReason = reason
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-	log.Printf("[INFO] Получение сделки по ID=%d", dealID)
+	log.Printf("[INFO] Пользователь ID=%d запросил отмену сделки ID=%d", userID, dealID)
+	return nil
+}
 
-	// Загружаем все сделки
-	var deals []model.Deal
-	if err := r.loadFromFile("deals.json", &deals); err != nil {
-		return nil, fmt.Errorf("не удалось загрузить сделки: %w", err)
+// CancelDeal переводит активную сделку в статус "cancelled" и сохраняет причину отмены
+func (r *FileRepository) CancelDeal(dealID, userID int64, reason string, cancelledAt time.Time) error {
+	err := r.transitionDeal(dealID, dealAction(model.DealActionCancel), func(deal *model.Deal, _ model.DealTransition) error {
+		deal.CancelReason = reason
+		deal.CancelledBy = userID
+		deal.CancelledAt = &cancelledAt
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	// Ищем сделку с нужным ID
-	for _, deal := range deals {
-		if deal.ID == dealID {
-			log.Printf("[INFO] Сделка найдена: ID=%d, Status=%s", deal.ID, deal.Status)
-			return &deal, nil
-		}
+	log.Printf("[INFO] Сделка ID=%d отменена пользователем ID=%d", dealID, userID)
+	return nil
+}
+
+// OpenDealDispute переводит активную сделку в статус "dispute" и сохраняет причину и доказательства
+func (r *FileRepository) OpenDealDispute(dealID, userID int64, reason, evidence string, openedAt time.Time) error {
+	err := r.transitionDeal(dealID, dealAction(model.DealActionOpenDispute), func(deal *model.Deal, _ model.DealTransition) error {
+		deal.DisputeReason = reason
+		deal.DisputeEvidence = evidence
+		deal.DisputeOpenedBy = userID
+		deal.DisputeOpenedAt = &openedAt
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	log.Printf("[WARN] Сделка с ID=%d не найдена", dealID)
-	return nil, fmt.Errorf("сделка с ID=%d не найдена", dealID)
+	log.Printf("[INFO] По сделке ID=%d открыт спор пользователем ID=%d", dealID, userID)
+	return nil
 }
 
-// ConfirmDeal - заглушка для совместимости
-func (r *FileRepository) ConfirmDeal(dealID int64, userID int64, isPaymentProof bool, paymentProof string) error {
-	log.Printf("[WARN] ConfirmDeal не реализован для файлового хранилища")
+// ResolveDealDispute закрывает спор по сделке с указанным итоговым статусом
+func (r *FileRepository) ResolveDealDispute(dealID int64, resolution *model.DisputeResolution) error {
+	err := r.transitionDeal(dealID, dealAction(resolution.Action()), func(deal *model.Deal, transition model.DealTransition) error {
+		resolvedAt := resolution.ResolvedAt
+		deal.DisputeWinnerID = resolution.WinnerID
+		deal.DisputeResolvedBy = resolution.ResolvedBy
+		deal.DisputeResolvedAt = &resolvedAt
+		deal.DisputeComment = resolution.Comment
+		if transition.To == model.DealStatusCompleted {
+			deal.CompletedAt = &resolvedAt
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Спор по сделке ID=%d решен: status=%s, winner=%d", dealID, resolution.Status, resolution.WinnerID)
 	return nil
 }
 
-// ConfirmDealWithRole подтверждает сделку с указанием роли пользователя
-func (r *FileRepository) ConfirmDealWithRole(dealID int64, userID int64, isAuthor bool, paymentProof string) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+// GetDisputedDeals получает все сделки с открытым спором (старые споры первыми)
+func (r *FileRepository) GetDisputedDeals() ([]*model.Deal, error) {
+	r.rlock()
+	defer r.runlock()
 
-	log.Printf("[INFO] Подтверждение сделки ID=%d пользователем ID=%d (isAuthor=%v)", dealID, userID, isAuthor)
+	return r.db.deals.clones(r.db.deals.find("status", string(model.DealStatusDispute))), nil
+}
 
-	// Загружаем сделки
-	var deals []model.Deal
-	if err := r.loadFromFile("deals.json", &deals); err != nil {
-		return fmt.Errorf("не удалось загрузить сделки: %w", err)
-	}
+// GetCompletedTrades возвращает завершенные сделки за период как рыночные сделки
+// в порядке завершения; пустая криптовалюта или фиат означают все пары
+func (r *FileRepository) GetCompletedTrades(filter *model.TradeFilter) ([]*model.Trade, error) {
+	r.rlock()
+	defer r.runlock()
 
-	// Ищем сделку и обновляем соответствующее поле подтверждения
-	var dealFound = false
-	for i := range deals {
-		if deals[i].ID == dealID {
-			dealFound = true
+	var trades []*model.Trade
+	for _, deal := range r.db.deals.find("status", string(model.DealStatusCompleted)) {
+		if deal.CompletedAt == nil {
+			continue
+		}
+		if deal.CompletedAt.Before(filter.Since) || !deal.CompletedAt.Before(filter.Until) {
+			continue
+		}
+		if filter.Cryptocurrency != "" && deal.Cryptocurrency != filter.Cryptocurrency {
+			continue
+		}
+		if filter.FiatCurrency != "" && deal.FiatCurrency != filter.FiatCurrency {
+			continue
+		}
+		trades = append(trades, &model.Trade{
+			DealID:         deal.ID,
+			Cryptocurrency: deal.Cryptocurrency,
+			FiatCurrency:   deal.FiatCurrency,
+			Price:          deal.Price,
+			Amount:         deal.Amount,
+			CompletedAt:    *deal.CompletedAt,
+		})
+	}
+
+	sort.Slice(trades, func(i, j int) bool {
+		if !trades[i].CompletedAt.Equal(trades[j].CompletedAt) {
+			return trades[i].CompletedAt.Before(trades[j].CompletedAt)
+		}
+		return trades[i].DealID < trades[j].DealID
+	})
 
-			if isAuthor {
-				deals[i].AuthorConfirmed = true
-				deals[i].AuthorProof = paymentProof
-				log.Printf("[DEBUG] Автор сделки ID=%d подтвердил (AuthorConfirmed=true)", dealID)
-			} else {
-				deals[i].CounterConfirmed = true
-				deals[i].CounterProof = paymentProof
-				log.Printf("[DEBUG] Контрагент сделки ID=%d подтвердил (CounterConfirmed=true)", dealID)
-			}
+	return trades, nil
+}
 
-			// Если оба подтвердили, завершаем сделку
-			if deals[i].AuthorConfirmed && deals[i].CounterConfirmed {
-				deals[i].Status = model.DealStatusCompleted
-				now := time.Now()
-				deals[i].CompletedAt = &now
-				log.Printf("[INFO] Сделка ID=%d завершена - оба участника подтвердили", dealID)
-			}
+// AddDealEvent добавляет запись в хронологию сделки
+func (r *FileRepository) AddDealEvent(event *model.DealEvent) (err error) {
+	r.lock()
+	defer r.unlock(&err)
 
-			// UpdatedAt поле отсутствует в модели Deal
-			break
-		}
+	event.ID = r.db.nextID("deal_events")
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
 	}
+	r.db.dealEvents.put(cloneRow(event))
 
-	if !dealFound {
-		return fmt.Errorf("сделка ID=%d не найдена", dealID)
-	}
+	return nil
+}
 
-	// Сохраняем обновленные сделки
-	if err := r.saveToFile("deals.json", deals); err != nil {
-		return fmt.Errorf("не удалось сохранить сделки: %w", err)
-	}
+// GetDealEvents получает хронологию сделки в порядке возникновения событий
+func (r *FileRepository) GetDealEvents(dealID int64) ([]*model.DealEvent, error) {
+	r.rlock()
+	defer r.runlock()
 
-	log.Printf("[INFO] Сделка ID=%d успешно подтверждена пользователем ID=%d", dealID, userID)
-	return nil
+	return r.db.dealEvents.clones(r.db.dealEvents.find("deal", indexKey(dealID))), nil
 }
 
 // =====================================================
@@ -852,27 +1187,15 @@ func (r *FileRepository) ConfirmDealWithRole(dealID int64, userID int64, isAutho
 // =====================================================
 
 // CreateReview создает новый отзыв и обновляет рейтинг пользователя
-func (r *FileRepository) CreateReview(review *model.Review) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+func (r *FileRepository) CreateReview(review *model.Review) (err error) {
+	r.lock()
+	defer r.unlock(&err)
 
 	log.Printf("[INFO] Создание отзыва от пользователя ID=%d к пользователю ID=%d",
 		review.FromUserID, review.ToUserID)
 
-	// Загружаем существующие отзывы
-	var reviews []model.Review
-	if err := r.loadFromFile("reviews.json", &reviews); err != nil {
-		return fmt.Errorf("не удалось загрузить отзывы: %w", err)
-	}
-
-	// Генерируем новый ID для отзыва
-	reviewID, err := r.generateID("reviews")
-	if err != nil {
-		return fmt.Errorf("не удалось сгенерировать ID для отзыва: %w", err)
-	}
-
 	// Устанавливаем поля отзыва
-	review.ID = reviewID
+	review.ID = r.db.nextID("reviews")
 	review.CreatedAt = time.Now()
 	review.UpdatedAt = time.Now()
 	review.IsVisible = true
@@ -887,18 +1210,10 @@ func (r *FileRepository) CreateReview(review *model.Review) error {
 		review.Type = model.ReviewTypeNegative
 	}
 
-	// Добавляем отзыв в список
-	reviews = append(reviews, *review)
-
-	// Сохраняем отзывы
-	if err := r.saveToFile("reviews.json", reviews); err != nil {
-		return fmt.Errorf("не удалось сохранить отзывы: %w", err)
-	}
+	r.db.reviews.put(cloneRow(review))
 
 	// Обновляем рейтинг пользователя
-	if err := r.updateUserRating(review.ToUserID); err != nil {
-		log.Printf("[WARN] Не удалось обновить рейтинг пользователя ID=%d: %v", review.ToUserID, err)
-	}
+	r.updateUserRating(review.ToUserID)
 
 	log.Printf("[INFO] Отзыв успешно создан: ID=%d, рейтинг=%d", review.ID, review.Rating)
 	return nil
@@ -906,66 +1221,33 @@ func (r *FileRepository) CreateReview(review *model.Review) error {
 
 // GetReviewsByUserID получает отзывы о пользователе с пагинацией
 func (r *FileRepository) GetReviewsByUserID(userID int64, limit, offset int) ([]*model.Review, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
-
-	log.Printf("[INFO] Получение отзывов для пользователя ID=%d (limit=%d, offset=%d)", userID, limit, offset)
-
-	// Загружаем все отзывы
-	var allReviews []model.Review
-	if err := r.loadFromFile("reviews.json", &allReviews); err != nil {
-		return nil, fmt.Errorf("не удалось загрузить отзывы: %w", err)
-	}
+	r.rlock()
+	defer r.runlock()
 
-	// Загружаем всех пользователей для получения имен авторов отзывов
-	var allUsers []model.User
-	if err := r.loadFromFile("users.json", &allUsers); err != nil {
-		log.Printf("[WARN] Не удалось загрузить пользователей для отзывов: %v", err)
-	}
+	return r.getReviewsByUserID(userID, limit, offset), nil
+}
 
-	// Создаем карту пользователей для быстрого поиска
-	userMap := make(map[int64]model.User)
-	for _, user := range allUsers {
-		userMap[user.ID] = user
-	}
+// getReviewsByUserID внутренний метод получения отзывов о пользователе (без блокировки мьютекса)
+func (r *FileRepository) getReviewsByUserID(userID int64, limit, offset int) []*model.Review {
+	log.Printf("[INFO] Получение отзывов для пользователя ID=%d (limit=%d, offset=%d)", userID, limit, offset)
 
 	// Фильтруем отзывы для данного пользователя (видимые отзывы)
 	var userReviews []*model.Review
-	for _, review := range allReviews {
-		if review.ToUserID == userID && review.IsVisible {
-			reviewCopy := review
-
-			// Заполняем информацию об авторе отзыва (если не анонимный)
-			if !reviewCopy.IsAnonymous {
-				if author, exists := userMap[reviewCopy.FromUserID]; exists {
-					reviewCopy.FromUserName = author.FirstName
-					if author.LastName != "" {
-						reviewCopy.FromUserName += " " + author.LastName
-					}
-					reviewCopy.FromUserUsername = author.Username
-				} else {
-					// Если пользователь не найден, показываем ID
-					reviewCopy.FromUserName = fmt.Sprintf("Пользователь #%d", reviewCopy.FromUserID)
-				}
-			} else {
-				// Для анонимных отзывов скрываем автора
-				reviewCopy.FromUserName = "Аноним"
-				reviewCopy.FromUserUsername = ""
-			}
-
-			userReviews = append(userReviews, &reviewCopy)
+	for _, review := range r.db.reviews.find("to_user", indexKey(userID)) {
+		if review.IsVisible {
+			userReviews = append(userReviews, review)
 		}
 	}
 
 	// Сортируем по дате создания (новые сначала)
-	sort.Slice(userReviews, func(i, j int) bool {
+	sort.SliceStable(userReviews, func(i, j int) bool {
 		return userReviews[i].CreatedAt.After(userReviews[j].CreatedAt)
 	})
 
 	// Применяем пагинацию
 	totalReviews := len(userReviews)
 	if offset >= totalReviews {
-		return []*model.Review{}, nil
+		return []*model.Review{}
 	}
 
 	end := offset + limit
@@ -973,32 +1255,51 @@ func (r *FileRepository) GetReviewsByUserID(userID int64, limit, offset int) ([]
 		end = totalReviews
 	}
 
-	paginatedReviews := userReviews[offset:end]
+	paginatedReviews := make([]*model.Review, 0, end-offset)
+	for _, review := range userReviews[offset:end] {
+		reviewCopy := cloneRow(review)
+
+		// Заполняем информацию об авторе отзыва (если не анонимный)
+		if !reviewCopy.IsAnonymous {
+			if author := r.db.users.get(reviewCopy.FromUserID); author != nil {
+				reviewCopy.FromUserName = author.FirstName
+				if author.LastName != "" {
+					reviewCopy.FromUserName += " " + author.LastName
+				}
+				reviewCopy.FromUserUsername = author.Username
+			} else {
+				// Если пользователь не найден, показываем ID
+				reviewCopy.FromUserName = fmt.Sprintf("Пользователь #%d", reviewCopy.FromUserID)
+			}
+		} else {
+			// Для анонимных отзывов скрываем автора
+			reviewCopy.FromUserName = "Аноним"
+			reviewCopy.FromUserUsername = ""
+		}
+
+		paginatedReviews = append(paginatedReviews, reviewCopy)
+	}
 
 	log.Printf("[INFO] Найдено отзывов для пользователя ID=%d: %d (показано %d)", userID, totalReviews, len(paginatedReviews))
-	return paginatedReviews, nil
+	return paginatedReviews
 }
 
 // GetUserRating получает агрегированный рейтинг пользователя
 func (r *FileRepository) GetUserRating(userID int64) (*model.Rating, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+	r.rlock()
+	defer r.runlock()
 
-	log.Printf("[INFO] Получение рейтинга пользователя ID=%d", userID)
+	return r.getUserRating(userID), nil
+}
 
-	// Загружаем рейтинги
-	var ratings []model.Rating
-	if err := r.loadFromFile("ratings.json", &ratings); err != nil {
-		return nil, fmt.Errorf("не удалось загрузить рейтинги: %w", err)
-	}
+// getUserRating внутренний метод получения рейтинга пользователя (без блокировки мьютекса)
+func (r *FileRepository) getUserRating(userID int64) *model.Rating {
+	log.Printf("[INFO] Получение рейтинга пользователя ID=%d", userID)
 
-	// Ищем рейтинг пользователя
-	for _, rating := range ratings {
-		if rating.UserID == userID {
-			log.Printf("[INFO] Найден рейтинг пользователя ID=%d: %.2f (%d отзывов)",
-				userID, rating.AverageRating, rating.TotalReviews)
-			return &rating, nil
-		}
+	if rating := r.db.ratings.get(userID); rating != nil {
+		log.Printf("[INFO] Найден рейтинг пользователя ID=%d: %.2f (%d отзывов)",
+			userID, rating.AverageRating, rating.TotalReviews)
+		return cloneRow(rating)
 	}
 
 	// Если рейтинг не найден, создаем пустой
@@ -1018,21 +1319,21 @@ func (r *FileRepository) GetUserRating(userID int64) (*model.Rating, error) {
 	}
 
 	log.Printf("[INFO] Рейтинг пользователя ID=%d не найден, возвращен пустой", userID)
-	return emptyRating, nil
+	return emptyRating
 }
 
 // CheckCanReview проверяет можно ли пользователю оставить отзыв о другом пользователе по сделке
 func (r *FileRepository) CheckCanReview(dealID, fromUserID, toUserID int64) (bool, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+	r.rlock()
+	defer r.runlock()
 
 	log.Printf("[INFO] Проверка возможности оставить отзыв: Deal ID=%d, From=%d, To=%d",
 		dealID, fromUserID, toUserID)
 
 	// 1. Проверяем что сделка существует и завершена
-	deal, err := r.getDealByIDInternal(dealID)
-	if err != nil {
-		log.Printf("[WARN] Сделка не найдена: %v", err)
+	deal := r.db.deals.get(dealID)
+	if deal == nil {
+		log.Printf("[WARN] Сделка не найдена: сделка с ID=%d не найдена", dealID)
 		return false, fmt.Errorf("сделка не найдена")
 	}
 
@@ -1057,13 +1358,8 @@ func (r *FileRepository) CheckCanReview(dealID, fromUserID, toUserID int64) (boo
 	}
 
 	// 5. Проверяем что отзыв еще не был оставлен
-	var reviews []model.Review
-	if err := r.loadFromFile("reviews.json", &reviews); err != nil {
-		return false, fmt.Errorf("не удалось загрузить отзывы: %w", err)
-	}
-
-	for _, review := range reviews {
-		if review.DealID == dealID && review.FromUserID == fromUserID && review.ToUserID == toUserID {
+	for _, review := range r.db.reviews.find("deal", indexKey(dealID)) {
+		if review.FromUserID == fromUserID && review.ToUserID == toUserID {
 			log.Printf("[WARN] Отзыв уже оставлен по сделке ID=%d от пользователя ID=%d к пользователю ID=%d",
 				dealID, fromUserID, toUserID)
 			return false, fmt.Errorf("отзыв по данной сделке уже оставлен")
@@ -1075,35 +1371,24 @@ func (r *FileRepository) CheckCanReview(dealID, fromUserID, toUserID int64) (boo
 }
 
 // ReportReview создает жалобу на отзыв
-func (r *FileRepository) ReportReview(report *model.ReviewReport) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+func (r *FileRepository) ReportReview(report *model.ReviewReport) (err error) {
+	r.lock()
+	defer r.unlock(&err)
 
 	log.Printf("[INFO] Создание жалобы на отзыв ID=%d от пользователя ID=%d", report.ReviewID, report.UserID)
 
-	// Загружаем жалобы
-	var reports []model.ReviewReport
-	if err := r.loadFromFile("review_reports.json", &reports); err != nil {
-		return fmt.Errorf("не удалось загрузить жалобы: %w", err)
-	}
-
-	// Генерируем ID для жалобы
-	reportID, err := r.generateID("reports")
-	if err != nil {
-		return fmt.Errorf("не удалось сгенерировать ID для жалобы: %w", err)
-	}
-
 	// Устанавливаем поля жалобы
-	report.ID = reportID
+	report.ID = r.db.nextID("reports")
 	report.Status = "pending"
 	report.CreatedAt = time.Now()
 
-	// Добавляем жалобу
-	reports = append(reports, *report)
+	r.db.reviewReports.put(cloneRow(report))
 
-	// Сохраняем жалобы
-	if err := r.saveToFile("review_reports.json", reports); err != nil {
-		return fmt.Errorf("не удалось сохранить жалобы: %w", err)
+	// Увеличиваем счетчик жалоб отзыва
+	if stored := r.db.reviews.get(report.ReviewID); stored != nil {
+		review := cloneRow(stored)
+		review.ReportedCount++
+		r.db.reviews.put(review)
 	}
 
 	log.Printf("[INFO] Жалоба успешно создана: ID=%d", report.ID)
@@ -1112,22 +1397,16 @@ func (r *FileRepository) ReportReview(report *model.ReviewReport) error {
 
 // GetUserReviewStats получает детальную статистику отзывов пользователя
 func (r *FileRepository) GetUserReviewStats(userID int64) (*model.ReviewStats, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+	r.rlock()
+	defer r.runlock()
 
 	log.Printf("[INFO] Получение статистики отзывов пользователя ID=%d", userID)
 
 	// Получаем рейтинг пользователя
-	rating, err := r.GetUserRating(userID)
-	if err != nil {
-		return nil, fmt.Errorf("не удалось получить рейтинг: %w", err)
-	}
+	rating := r.getUserRating(userID)
 
 	// Получаем последние отзывы (максимум 5)
-	recentReviews, err := r.GetReviewsByUserID(userID, 5, 0)
-	if err != nil {
-		return nil, fmt.Errorf("не удалось получить отзывы: %w", err)
-	}
+	recentReviews := r.getReviewsByUserID(userID, 5, 0)
 
 	// Конвертируем указатели в значения для ReviewStats
 	var recentReviewsValues []model.Review
@@ -1164,35 +1443,40 @@ func (r *FileRepository) GetUserReviewStats(userID int64) (*model.ReviewStats, e
 	return stats, nil
 }
 
+// GetAssets получает записи реестра активов, переопределяющие встроенный реестр
+func (r *FileRepository) GetAssets() ([]*model.Asset, error) {
+	r.rlock()
+	defer r.runlock()
+
+	result := make([]*model.Asset, 0, len(r.db.assets))
+	for i := range r.db.assets {
+		asset := r.db.assets[i]
+		result = append(result, &asset)
+	}
+	return result, nil
+}
+
 // =====================================================
 // МЕТОДЫ ДЛЯ РАБОТЫ С ОТКЛИКАМИ
 // =====================================================
 
 // CreateResponse создает новый отклик на заявку
-func (r *FileRepository) CreateResponse(response *model.Response) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+func (r *FileRepository) CreateResponse(response *model.Response) (err error) {
+	r.lock()
+	defer r.unlock(&err)
 
 	log.Printf("[INFO] Создание нового отклика на заявку ID=%d от пользователя ID=%d",
 		response.OrderID, response.UserID)
 
-	// Читаем текущие отклики
-	var responses []model.Response
-	if err := r.loadFromFile("responses.json", &responses); err != nil {
-		return fmt.Errorf("не удалось загрузить отклики: %w", err)
-	}
-
 	// Проверяем что пользователь не откликался уже на эту заявку
-	for _, existingResponse := range responses {
-		if existingResponse.OrderID == response.OrderID && existingResponse.UserID == response.UserID {
+	for _, existingResponse := range r.db.responses.find("order", indexKey(response.OrderID)) {
+		if existingResponse.UserID == response.UserID {
 			return fmt.Errorf("вы уже откликались на эту заявку")
 		}
 	}
 
 	// Генерируем ID для отклика
-	counters := r.getCounters()
-	counters["responses"]++
-	response.ID = counters["responses"]
+	response.ID = r.db.nextID("responses")
 
 	// Устанавливаем временные метки
 	now := time.Now()
@@ -1200,18 +1484,7 @@ func (r *FileRepository) CreateResponse(response *model.Response) error {
 	response.UpdatedAt = now
 	response.Status = model.ResponseStatusWaiting
 
-	// Добавляем отклик
-	responses = append(responses, *response)
-
-	// Сохраняем отклики
-	if err := r.saveToFile("responses.json", responses); err != nil {
-		return fmt.Errorf("не удалось сохранить отклики: %w", err)
-	}
-
-	// Обновляем счетчики
-	if err := r.saveCounters(counters); err != nil {
-		log.Printf("[WARN] Не удалось обновить счетчики: %v", err)
-	}
+	r.db.responses.put(cloneResponse(response))
 
 	// Обновляем счетчик откликов в заявке
 	if err := r.updateOrderResponseCount(response.OrderID, 1); err != nil {
@@ -1222,25 +1495,29 @@ func (r *FileRepository) CreateResponse(response *model.Response) error {
 	return nil
 }
 
+// GetResponseByID получает отклик по ID
+func (r *FileRepository) GetResponseByID(responseID int64) (*model.Response, error) {
+	r.rlock()
+	defer r.runlock()
+
+	response := r.db.responses.get(responseID)
+	if response == nil {
+		return nil, fmt.Errorf("отклик с ID=%d не найден", responseID)
+	}
+	return cloneResponse(response), nil
+}
+
 // GetResponsesByFilter получает отклики с фильтрацией
 func (r *FileRepository) GetResponsesByFilter(filter *model.ResponseFilter) ([]*model.Response, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+	r.rlock()
+	defer r.runlock()
 
 	log.Printf("[INFO] Получение откликов с фильтром: %+v", filter)
 
-	// Читаем все отклики
-	var allResponses []model.Response
-	if err := r.loadFromFile("responses.json", &allResponses); err != nil {
-		return nil, fmt.Errorf("не удалось загрузить отклики: %w", err)
-	}
-
 	var responses []*model.Response
 
-	// Применяем фильтры
-	for i := range allResponses {
-		response := &allResponses[i]
-
+	// Применяем фильтры к откликам из самого узкого индекса
+	for _, response := range r.responseCandidates(filter) {
 		// Фильтр по заявке
 		if filter.OrderID != nil && response.OrderID != *filter.OrderID {
 			continue
@@ -1253,9 +1530,8 @@ func (r *FileRepository) GetResponsesByFilter(filter *model.ResponseFilter) ([]*
 
 		// Фильтр по автору заявки (для кого отклики)
 		if filter.AuthorID != nil {
-			// Нужно получить заявку чтобы проверить автора
-			order, err := r.getOrderByID(response.OrderID)
-			if err != nil || order.UserID != *filter.AuthorID {
+			order := r.db.orders.get(response.OrderID)
+			if order == nil || order.UserID != *filter.AuthorID {
 				continue
 			}
 		}
@@ -1284,7 +1560,9 @@ func (r *FileRepository) GetResponsesByFilter(filter *model.ResponseFilter) ([]*
 
 	// Применяем лимит и офсет
 	total := len(responses)
-	if filter.Offset > 0 && filter.Offset < total {
+	if filter.Offset >= total {
+		responses = nil
+	} else if filter.Offset > 0 {
 		responses = responses[filter.Offset:]
 	}
 
@@ -1293,51 +1571,194 @@ func (r *FileRepository) GetResponsesByFilter(filter *model.ResponseFilter) ([]*
 	}
 
 	log.Printf("[INFO] Возвращено откликов: %d из %d", len(responses), total)
-	return responses, nil
+	return r.db.responses.clones(responses), nil
 }
 
-// UpdateResponseStatus обновляет статус отклика
-func (r *FileRepository) UpdateResponseStatus(responseID int64, status model.ResponseStatus) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+// responseCandidates выбирает отклики для проверки фильтром: по заявке, по пользователю,
+// по заявкам автора или все отклики, если фильтр не задает ни одного из них
+func (r *FileRepository) responseCandidates(filter *model.ResponseFilter) []*model.Response {
+	switch {
+	case filter.OrderID != nil:
+		return r.db.responses.find("order", indexKey(*filter.OrderID))
+	case filter.UserID != nil:
+		return r.db.responses.find("user", indexKey(*filter.UserID))
+	case filter.AuthorID != nil:
+		authorOrders := r.db.orders.find("user", indexKey(*filter.AuthorID))
+		orderKeys := make([]string, 0, len(authorOrders))
+		for _, order := range authorOrders {
+			orderKeys = append(orderKeys, indexKey(order.ID))
+		}
+		return r.db.responses.find("order", orderKeys...)
+	default:
+		return r.db.responses.all()
+	}
+}
+
+// UpdateResponseStatus обновляет статус открытого отклика (waiting или countered).
+// Статус проверяется под блокировкой хранилища, поэтому один отклик не рассматривается дважды
+func (r *FileRepository) UpdateResponseStatus(responseID int64, status model.ResponseStatus) (err error) {
+	r.lock()
+	defer r.unlock(&err)
 
 	log.Printf("[INFO] Обновление статуса отклика ID=%d на %s", responseID, status)
 
-	// Читаем отклики
-	var responses []model.Response
-	if err := r.loadFromFile("responses.json", &responses); err != nil {
-		return fmt.Errorf("не удалось загрузить отклики: %w", err)
+	stored := r.db.responses.get(responseID)
+	if stored == nil {
+		return fmt.Errorf("отклик с ID=%d не найден", responseID)
+	}
+	if !stored.IsOpen() {
+		return fmt.Errorf("отклик ID=%d уже был рассмотрен (статус %s)", responseID, stored.Status)
 	}
 
-	// Находим и обновляем отклик
-	found := false
 	now := time.Now()
+	response := cloneResponse(stored)
+	response.Status = status
+	response.UpdatedAt = now
+	if status != model.ResponseStatusWaiting {
+		response.ReviewedAt = &now
+	}
+	r.db.responses.put(response)
 
-	for i := range responses {
-		if responses[i].ID == responseID {
-			responses[i].Status = status
-			responses[i].UpdatedAt = now
-			if status != model.ResponseStatusWaiting {
-				responses[i].ReviewedAt = &now
-			}
-			found = true
-			break
+	log.Printf("[INFO] Статус отклика ID=%d успешно обновлен", responseID)
+	return nil
+}
+
+// ReopenResponse возвращает отклоненный или отозванный отклик на рассмотрение с новым сообщением и условиями
+func (r *FileRepository) ReopenResponse(response *model.Response) (err error) {
+	r.lock()
+	defer r.unlock(&err)
+
+	stored := r.db.responses.get(response.ID)
+	if stored == nil {
+		return fmt.Errorf("отклик с ID=%d не найден", response.ID)
+	}
+
+	// Отозванный отклик снова учитывается в счетчике откликов заявки
+	wasWithdrawn := stored.Status == model.ResponseStatusWithdrawn
+
+	updated := cloneResponse(stored)
+	updated.Message = response.Message
+	updated.ProposedPrice = response.ProposedPrice
+	updated.RequestedAmount = response.RequestedAmount
+	updated.PaymentMethod = response.PaymentMethod
+	updated.Status = model.ResponseStatusWaiting
+	updated.ReviewedAt = nil
+	updated.UpdatedAt = time.Now()
+	r.db.responses.put(updated)
+
+	if wasWithdrawn {
+		if err := r.updateOrderResponseCount(updated.OrderID, 1); err != nil {
+			log.Printf("[WARN] Не удалось обновить счетчик откликов в заявке: %v", err)
 		}
 	}
 
-	if !found {
+	log.Printf("[INFO] Отклик ID=%d снова ожидает рассмотрения (объем %.8f)", response.ID, response.RequestedAmount)
+	return nil
+}
+
+// UpdateResponseTerms сохраняет новые условия отклика после встречного предложения
+func (r *FileRepository) UpdateResponseTerms(responseID int64, status model.ResponseStatus, proposedPrice, requestedAmount float64) (err error) {
+	r.lock()
+	defer r.unlock(&err)
+
+	stored := r.db.responses.get(responseID)
+	if stored == nil {
+		return fmt.Errorf("отклик с ID=%d не найден", responseID)
+	}
+
+	response := cloneResponse(stored)
+	response.Status = status
+	response.ProposedPrice = proposedPrice
+	response.RequestedAmount = requestedAmount
+	response.UpdatedAt = time.Now()
+	r.db.responses.put(response)
+
+	log.Printf("[INFO] Условия отклика ID=%d обновлены: %.8f по %.2f, статус %s",
+		responseID, requestedAmount, proposedPrice, status)
+	return nil
+}
+
+// WithdrawResponse отзывает отклик, по которому еще идут переговоры.
+// Уменьшает счетчик откликов заявки и возвращает ее из has_responses в active,
+// если открытых откликов на нее не осталось
+func (r *FileRepository) WithdrawResponse(responseID int64) (err error) {
+	r.lock()
+	defer r.unlock(&err)
+
+	stored := r.db.responses.get(responseID)
+	if stored == nil {
 		return fmt.Errorf("отклик с ID=%d не найден", responseID)
 	}
+	if !stored.IsOpen() {
+		return fmt.Errorf("отклик уже был рассмотрен")
+	}
+
+	response := cloneResponse(stored)
+	response.Status = model.ResponseStatusWithdrawn
+	response.UpdatedAt = time.Now()
+	r.db.responses.put(response)
+
+	orderID := response.OrderID
+	if err := r.updateOrderResponseCount(orderID, -1); err != nil {
+		return fmt.Errorf("не удалось обновить счетчик откликов в заявке: %w", err)
+	}
 
-	// Сохраняем обновленные отклики
-	if err := r.saveToFile("responses.json", responses); err != nil {
-		return fmt.Errorf("не удалось сохранить отклики: %w", err)
+	hasOpen := false
+	for _, other := range r.db.responses.find("order", indexKey(orderID)) {
+		if other.IsOpen() {
+			hasOpen = true
+			break
+		}
+	}
+	if !hasOpen {
+		if err := r.returnOrderToActive(orderID); err != nil {
+			return err
+		}
 	}
 
-	log.Printf("[INFO] Статус отклика ID=%d успешно обновлен", responseID)
+	log.Printf("[INFO] Отклик ID=%d отозван", responseID)
+	return nil
+}
+
+// returnOrderToActive возвращает заявку из has_responses в active
+func (r *FileRepository) returnOrderToActive(orderID int64) error {
+	stored := r.db.orders.get(orderID)
+	if stored == nil {
+		return fmt.Errorf("заявка с ID=%d не найдена", orderID)
+	}
+	if stored.Status != model.OrderStatusHasResponses {
+		return nil
+	}
+
+	order := cloneOrder(stored)
+	order.Status = model.OrderStatusActive
+	order.UpdatedAt = time.Now()
+	r.db.orders.put(order)
 	return nil
 }
 
+// AddResponseOffer добавляет предложение в историю переговоров по отклику
+func (r *FileRepository) AddResponseOffer(offer *model.ResponseOffer) (err error) {
+	r.lock()
+	defer r.unlock(&err)
+
+	offer.ID = r.db.nextID("response_offers")
+	if offer.CreatedAt.IsZero() {
+		offer.CreatedAt = time.Now()
+	}
+	r.db.responseOffers.put(cloneRow(offer))
+
+	return nil
+}
+
+// GetResponseOffers получает историю предложений по отклику в порядке их поступления
+func (r *FileRepository) GetResponseOffers(responseID int64) ([]*model.ResponseOffer, error) {
+	r.rlock()
+	defer r.runlock()
+
+	return r.db.responseOffers.clones(r.db.responseOffers.find("response", indexKey(responseID))), nil
+}
+
 // GetResponsesForOrder получает все отклики для заявки
 func (r *FileRepository) GetResponsesForOrder(orderID int64) ([]*model.Response, error) {
 	filter := &model.ResponseFilter{
@@ -1370,56 +1791,40 @@ func (r *FileRepository) GetResponsesForAuthor(authorID int64) ([]*model.Respons
 
 // getOrderByID внутренний метод получения заявки по ID (без блокировки мьютекса)
 func (r *FileRepository) getOrderByID(orderID int64) (*model.Order, error) {
-	var orders []model.Order
-	if err := r.loadFromFile("orders.json", &orders); err != nil {
-		return nil, fmt.Errorf("не удалось загрузить заявки: %w", err)
-	}
-
-	for i := range orders {
-		if orders[i].ID == orderID {
-			return &orders[i], nil
-		}
+	order := r.db.orders.get(orderID)
+	if order == nil {
+		return nil, fmt.Errorf("заявка с ID=%d не найдена", orderID)
 	}
-
-	return nil, fmt.Errorf("заявка с ID=%d не найдена", orderID)
+	return cloneOrder(order), nil
 }
 
 // updateOrderResponseCount обновляет счетчик откликов в заявке
 func (r *FileRepository) updateOrderResponseCount(orderID int64, delta int) error {
-	var orders []model.Order
-	if err := r.loadFromFile("orders.json", &orders); err != nil {
-		return fmt.Errorf("не удалось загрузить заявки: %w", err)
+	stored := r.db.orders.get(orderID)
+	if stored == nil {
+		return fmt.Errorf("заявка с ID=%d не найдена", orderID)
 	}
 
-	found := false
-	for i := range orders {
-		if orders[i].ID == orderID {
-			orders[i].ResponseCount += delta
-			if orders[i].ResponseCount < 0 {
-				orders[i].ResponseCount = 0
-			}
-
-			// Обновляем статус заявки в зависимости от количества откликов
-			if orders[i].ResponseCount > 0 && orders[i].Status == model.OrderStatusActive {
-				orders[i].Status = model.OrderStatusHasResponses
-			} else if orders[i].ResponseCount == 0 && orders[i].Status == model.OrderStatusHasResponses {
-				orders[i].Status = model.OrderStatusActive
-			}
-
-			orders[i].UpdatedAt = time.Now()
-			found = true
-			break
-		}
+	order := cloneOrder(stored)
+	order.ResponseCount += delta
+	if order.ResponseCount < 0 {
+		order.ResponseCount = 0
 	}
 
-	if !found {
-		return fmt.Errorf("заявка с ID=%d не найдена", orderID)
+	// Обновляем статус заявки в зависимости от количества откликов
+	if order.ResponseCount > 0 && order.Status == model.OrderStatusActive {
+		order.Status = model.OrderStatusHasResponses
+	} else if order.ResponseCount == 0 && order.Status == model.OrderStatusHasResponses {
+		order.Status = model.OrderStatusActive
 	}
 
-	return r.saveToFile("orders.json", orders)
+	order.UpdatedAt = time.Now()
+	r.db.orders.put(order)
+	return nil
 }
 
 // sortResponses сортирует отклики по указанному полю
+// Сортировка устойчивая: при равных значениях отклики остаются в порядке создания (по ID)
 func (r *FileRepository) sortResponses(responses []*model.Response, sortBy, sortOrder string) {
 	less := func(i, j int) bool {
 		switch sortBy {
@@ -1442,81 +1847,30 @@ func (r *FileRepository) sortResponses(responses []*model.Response, sortBy, sort
 		}
 	}
 
-	// Простая сортировка пузырьком (для небольших массивов это нормально)
-	n := len(responses)
-	for i := 0; i < n-1; i++ {
-		for j := 0; j < n-i-1; j++ {
-			if less(j+1, j) {
-				responses[j], responses[j+1] = responses[j+1], responses[j]
-			}
-		}
-	}
-}
-
-// getCounters читает счетчики из файла
-func (r *FileRepository) getCounters() map[string]int64 {
-	var counters map[string]int64
-	if err := r.loadFromFile("counters.json", &counters); err != nil {
-		// Если файл не существует, создаем с нулевыми значениями
-		counters = map[string]int64{
-			"users":     0,
-			"orders":    0,
-			"responses": 0,
-			"deals":     0,
-			"reviews":   0,
-			"reports":   0,
-		}
-	}
-	return counters
-}
-
-// saveCounters сохраняет счетчики в файл
-func (r *FileRepository) saveCounters(counters map[string]int64) error {
-	return r.saveToFile("counters.json", counters)
-}
-
-// getDealByIDInternal внутренний метод получения сделки (без блокировки мьютекса)
-func (r *FileRepository) getDealByIDInternal(dealID int64) (*model.Deal, error) {
-	var deals []model.Deal
-	if err := r.loadFromFile("deals.json", &deals); err != nil {
-		return nil, fmt.Errorf("не удалось загрузить сделки: %w", err)
-	}
-
-	for _, deal := range deals {
-		if deal.ID == dealID {
-			return &deal, nil
-		}
-	}
-
-	return nil, fmt.Errorf("сделка с ID=%d не найдена", dealID)
+	sort.SliceStable(responses, less)
 }
 
 // updateUserRating пересчитывает рейтинг пользователя на основе всех его отзывов
-func (r *FileRepository) updateUserRating(userID int64) error {
+func (r *FileRepository) updateUserRating(userID int64) {
 	log.Printf("[INFO] Обновление рейтинга пользователя ID=%d", userID)
 
-	// Загружаем все отзывы пользователя
-	var allReviews []model.Review
-	if err := r.loadFromFile("reviews.json", &allReviews); err != nil {
-		return fmt.Errorf("не удалось загрузить отзывы: %w", err)
-	}
-
-	// Фильтруем отзывы для данного пользователя
-	var userReviews []model.Review
-	for _, review := range allReviews {
-		if review.ToUserID == userID && review.IsVisible {
+	// Фильтруем видимые отзывы о пользователе
+	var userReviews []*model.Review
+	for _, review := range r.db.reviews.find("to_user", indexKey(userID)) {
+		if review.IsVisible {
 			userReviews = append(userReviews, review)
 		}
 	}
 
-	// Если отзывов нет, удаляем рейтинг из файла
+	// Если отзывов нет, удаляем рейтинг
 	if len(userReviews) == 0 {
-		return r.removeUserRating(userID)
+		r.db.ratings.delete(userID)
+		return
 	}
 
 	// Вычисляем статистику
 	var totalRating int
-	rating := model.Rating{
+	rating := &model.Rating{
 		UserID:    userID,
 		UpdatedAt: time.Now(),
 	}
@@ -1553,48 +1907,60 @@ func (r *FileRepository) updateUserRating(userID int64) error {
 	rating.AverageRating = float32(totalRating) / float32(len(userReviews))
 
 	// Сохраняем рейтинг
-	return r.saveUserRating(&rating)
+	r.db.ratings.put(rating)
 }
 
-// removeUserRating удаляет рейтинг пользователя
-func (r *FileRepository) removeUserRating(userID int64) error {
-	var ratings []model.Rating
-	if err := r.loadFromFile("ratings.json", &ratings); err != nil {
-		return fmt.Errorf("не удалось загрузить рейтинги: %w", err)
-	}
+// =====================================================
+// КОПИИ ЗАПИСЕЙ
+// =====================================================
 
-	// Фильтруем рейтинги, удаляя целевого пользователя
-	var filteredRatings []model.Rating
-	for _, rating := range ratings {
-		if rating.UserID != userID {
-			filteredRatings = append(filteredRatings, rating)
-		}
+// cloneRow копирует запись без срезов и вложенных структур
+func cloneRow[T any](row *T) *T {
+	clone := *row
+	return &clone
+}
+
+// cloneStrings копирует срез строк (nil остается nil)
+func cloneStrings(values []string) []string {
+	if values == nil {
+		return nil
 	}
+	return append(make([]string, 0, len(values)), values...)
+}
 
-	return r.saveToFile("ratings.json", filteredRatings)
+// cloneUser копирует пользователя
+func cloneUser(user *model.User) *model.User {
+	return cloneRow(user)
 }
 
-// saveUserRating сохраняет или обновляет рейтинг пользователя
-func (r *FileRepository) saveUserRating(newRating *model.Rating) error {
-	var ratings []model.Rating
-	if err := r.loadFromFile("ratings.json", &ratings); err != nil {
-		return fmt.Errorf("не удалось загрузить рейтинги: %w", err)
+// cloneOrder копирует заявку вместе со способами оплаты и правилами автопринятия
+func cloneOrder(order *model.Order) *model.Order {
+	clone := *order
+	clone.PaymentMethods = cloneStrings(order.PaymentMethods)
+	if order.AutoAccept != nil {
+		rules := *order.AutoAccept
+		rules.PaymentMethods = cloneStrings(order.AutoAccept.PaymentMethods)
+		clone.AutoAccept = &rules
 	}
-
-	// Ищем существующий рейтинг
-	found := false
-	for i, rating := range ratings {
-		if rating.UserID == newRating.UserID {
-			ratings[i] = *newRating
-			found = true
-			break
-		}
+	if order.AcceptedResponseID != nil {
+		responseID := *order.AcceptedResponseID
+		clone.AcceptedResponseID = &responseID
 	}
+	return &clone
+}
 
-	// Если не найден, добавляем новый
-	if !found {
-		ratings = append(ratings, *newRating)
-	}
+// cloneDeal копирует сделку вместе со способами оплаты
+func cloneDeal(deal *model.Deal) *model.Deal {
+	clone := *deal
+	clone.PaymentMethods = cloneStrings(deal.PaymentMethods)
+	return &clone
+}
 
-	return r.saveToFile("ratings.json", ratings)
+// cloneResponse копирует отклик вместе с историей предложений
+func cloneResponse(response *model.Response) *model.Response {
+	clone := *response
+	if response.Offers != nil {
+		clone.Offers = append(make([]*model.ResponseOffer, 0, len(response.Offers)), response.Offers...)
+	}
+	return &clone
 }
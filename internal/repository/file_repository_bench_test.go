@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"p2pTG-crypto-exchange/internal/model"
+)
+
+// =====================================================
+// БЕНЧМАРКИ ФАЙЛОВОГО ХРАНИЛИЩА НА 100 000 ЗАЯВОК
+// =====================================================
+
+const (
+	benchOrders = 100_000 // Заявок в файле данных
+	benchUsers  = 1_000   // Авторов заявок
+)
+
+var (
+	benchCryptos  = []string{"BTC", "ETH", "USDT", "TON"}
+	benchFiats    = []string{"RUB", "USD", "EUR"}
+	benchStatuses = []model.OrderStatus{
+		model.OrderStatusActive, model.OrderStatusActive, model.OrderStatusHasResponses,
+		model.OrderStatusInDeal, model.OrderStatusCompleted, model.OrderStatusCancelled,
+	}
+)
+
+// seedBenchOrders записывает orders.json со 100 000 заявок и открывает на нем хранилище
+func seedBenchOrders(b *testing.B) (*FileRepository, string) {
+	b.Helper()
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	dir := b.TempDir()
+	created := time.Now().Add(-benchOrders * time.Second)
+	orders := make([]model.Order, benchOrders)
+	for i := range orders {
+		status := benchStatuses[i%len(benchStatuses)]
+		orders[i] = model.Order{
+			ID:              int64(i + 1),
+			UserID:          int64(i%benchUsers + 1),
+			Type:            []model.OrderType{model.OrderTypeBuy, model.OrderTypeSell}[i%2],
+			Cryptocurrency:  benchCryptos[i%len(benchCryptos)],
+			FiatCurrency:    benchFiats[i%len(benchFiats)],
+			Amount:          1,
+			RemainingAmount: 1,
+			Price:           float64(1000 + i%500),
+			PaymentMethods:  []string{"sbp"},
+			Status:          status,
+			IsActive:        status != model.OrderStatusCompleted && status != model.OrderStatusCancelled,
+			CreatedAt:       created.Add(time.Duration(i) * time.Second),
+			ExpiresAt:       created.Add(365 * 24 * time.Hour),
+		}
+	}
+
+	data, err := json.MarshalIndent(orders, "", "  ")
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "orders.json"), data, 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	repo, err := NewFileRepository(dir)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { repo.Close() })
+	return repo, dir
+}
+
+// BenchmarkFileRepositoryScanOrdersFile прежняя схема чтения: каждый запрос читает
+// и десериализует orders.json целиком и перебирает все заявки (точка отсчета для сравнения)
+func BenchmarkFileRepositoryScanOrdersFile(b *testing.B) {
+	_, dir := seedBenchOrders(b)
+	userID := int64(42)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data, err := os.ReadFile(filepath.Join(dir, "orders.json"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		var orders []model.Order
+		if err := json.Unmarshal(data, &orders); err != nil {
+			b.Fatal(err)
+		}
+		found := 0
+		for j := range orders {
+			if orders[j].UserID == userID {
+				found++
+			}
+		}
+		if found == 0 {
+			b.Fatal("заявки пользователя не найдены")
+		}
+	}
+}
+
+func BenchmarkFileRepositoryGetOrderByID(b *testing.B) {
+	repo, _ := seedBenchOrders(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.GetOrderByID(int64(i%benchOrders + 1)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFileRepositoryOrdersByUser(b *testing.B) {
+	repo, _ := seedBenchOrders(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		userID := int64(i%benchUsers + 1)
+		orders, err := repo.GetOrdersByFilter(&model.OrderFilter{UserID: &userID, IncludeInactive: true})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(orders) != benchOrders/benchUsers {
+			b.Fatalf("получено %d заявок пользователя", len(orders))
+		}
+	}
+}
+
+func BenchmarkFileRepositoryOrdersByPairAndStatus(b *testing.B) {
+	repo, _ := seedBenchOrders(b)
+	crypto, fiat, status := "BTC", "RUB", model.OrderStatusActive
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		orders, err := repo.GetOrdersByFilter(&model.OrderFilter{
+			Cryptocurrency: &crypto,
+			FiatCurrency:   &fiat,
+			Status:         &status,
+			SortBy:         "price",
+			SortOrder:      "asc",
+			Limit:          20,
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(orders) != 20 {
+			b.Fatalf("получено %d заявок", len(orders))
+		}
+	}
+}
+
+func BenchmarkFileRepositoryOrderBook(b *testing.B) {
+	repo, _ := seedBenchOrders(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		book, err := repo.GetOrderBook(&model.OrderBookFilter{Cryptocurrency: "ETH", FiatCurrency: "USD", Depth: 20})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(book.Bids)+len(book.Asks) == 0 {
+			b.Fatal("пустой стакан")
+		}
+	}
+}
+
+func BenchmarkFileRepositoryUpdateOrderStatus(b *testing.B) {
+	repo, _ := seedBenchOrders(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		orderID := int64(i%benchOrders + 1)
+		if err := repo.UpdateOrderStatus(orderID, model.OrderStatusActive); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
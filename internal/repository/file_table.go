@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// =====================================================
+// ТАБЛИЦЫ ФАЙЛОВОГО ХРАНИЛИЩА В ПАМЯТИ
+// =====================================================
+
+// persistentTable таблица, которую можно сохранить в файл данных и восстановить из журнала
+type persistentTable interface {
+	file() string
+	counterName() string
+	maxID() int64
+	load(data []byte) error
+	snapshot() ([]byte, error)
+	rowJSON(id int64) (json.RawMessage, bool, error)
+	applyJSON(raw json.RawMessage) error
+	remove(id int64)
+}
+
+// table коллекция записей одного файла данных, загруженная в память, с вторичными индексами.
+// Записи таблицы не изменяются на месте: изменение - это новая копия записи, сохраненная через put.
+// Поэтому наружу отдаются только копии (clone), а индексы всегда соответствуют записям
+type table[T any] struct {
+	filename string                    // Файл данных таблицы
+	counter  string                    // Счетчик ID в counters.json (пусто - ID задает сама запись)
+	id       func(row *T) int64        // Первичный ключ записи
+	clone    func(row *T) *T           // Копия записи для выдачи наружу и изменения
+	rows     map[int64]*T              // Записи по первичному ключу
+	indexes  map[string]*tableIndex[T] // Вторичные индексы по имени
+	db       *fileDB                   // Хранилище: учет изменений транзакции
+}
+
+// tableIndex вторичный индекс: ключ -> записи с этим ключом
+type tableIndex[T any] struct {
+	keys    func(row *T) []string
+	entries map[string]map[int64]*T
+}
+
+// newTable создает пустую таблицу файла filename
+func newTable[T any](db *fileDB, filename, counter string, id func(row *T) int64, clone func(row *T) *T) *table[T] {
+	t := &table[T]{
+		filename: filename,
+		counter:  counter,
+		id:       id,
+		clone:    clone,
+		rows:     make(map[int64]*T),
+		indexes:  make(map[string]*tableIndex[T]),
+		db:       db,
+	}
+	db.tables = append(db.tables, t)
+	return t
+}
+
+// withIndex добавляет вторичный индекс; keys возвращает ключи записи (запись может попасть под несколько ключей)
+func (t *table[T]) withIndex(name string, keys func(row *T) []string) *table[T] {
+	t.indexes[name] = &tableIndex[T]{keys: keys, entries: make(map[string]map[int64]*T)}
+	return t
+}
+
+// indexKey ключ индекса для числового значения
+func indexKey(value int64) string {
+	return strconv.FormatInt(value, 10)
+}
+
+// get возвращает запись по первичному ключу (nil - нет записи). Запись нельзя изменять
+func (t *table[T]) get(id int64) *T {
+	return t.rows[id]
+}
+
+// find возвращает записи с любым из ключей keys в индексе name, упорядоченные по первичному ключу
+func (t *table[T]) find(name string, keys ...string) []*T {
+	index := t.indexes[name]
+	size := 0
+	for _, key := range keys {
+		size += len(index.entries[key])
+	}
+
+	// Запись может попасть под несколько ключей (например, сделка под обоих участников)
+	var seen map[int64]bool
+	if len(keys) > 1 {
+		seen = make(map[int64]bool, size)
+	}
+
+	rows := make([]*T, 0, size)
+	for _, key := range keys {
+		for id, row := range index.entries[key] {
+			if seen != nil {
+				if seen[id] {
+					continue
+				}
+				seen[id] = true
+			}
+			rows = append(rows, row)
+		}
+	}
+	t.sortByID(rows)
+	return rows
+}
+
+// first возвращает запись с наименьшим первичным ключом среди записей с ключом key (nil - нет записей)
+func (t *table[T]) first(name, key string) *T {
+	var result *T
+	for id, row := range t.indexes[name].entries[key] {
+		if result == nil || id < t.id(result) {
+			result = row
+		}
+	}
+	return result
+}
+
+// count возвращает количество записей с ключом key в индексе name
+func (t *table[T]) count(name, key string) int {
+	return len(t.indexes[name].entries[key])
+}
+
+// all возвращает все записи, упорядоченные по первичному ключу
+func (t *table[T]) all() []*T {
+	rows := make([]*T, 0, len(t.rows))
+	for _, row := range t.rows {
+		rows = append(rows, row)
+	}
+	t.sortByID(rows)
+	return rows
+}
+
+// sortByID упорядочивает записи по первичному ключу (порядок добавления)
+func (t *table[T]) sortByID(rows []*T) {
+	sort.Slice(rows, func(i, j int) bool { return t.id(rows[i]) < t.id(rows[j]) })
+}
+
+// clones возвращает копии записей для выдачи наружу (nil - записей нет)
+func (t *table[T]) clones(rows []*T) []*T {
+	var result []*T
+	for _, row := range rows {
+		result = append(result, t.clone(row))
+	}
+	return result
+}
+
+// put добавляет или заменяет запись; изменение учитывается в текущей транзакции
+func (t *table[T]) put(row *T) {
+	id := t.id(row)
+	previous := t.rows[id]
+	t.db.track(t, id, func() { t.restore(id, previous) })
+	t.restore(id, row)
+}
+
+// delete удаляет запись; изменение учитывается в текущей транзакции
+func (t *table[T]) delete(id int64) {
+	previous := t.rows[id]
+	if previous == nil {
+		return
+	}
+	t.db.track(t, id, func() { t.restore(id, previous) })
+	t.restore(id, nil)
+}
+
+// restore ставит запись id на место (nil - удаляет) и обновляет индексы без учета в транзакции
+func (t *table[T]) restore(id int64, row *T) {
+	if previous := t.rows[id]; previous != nil {
+		for _, index := range t.indexes {
+			for _, key := range index.keys(previous) {
+				delete(index.entries[key], id)
+				if len(index.entries[key]) == 0 {
+					delete(index.entries, key)
+				}
+			}
+		}
+		delete(t.rows, id)
+	}
+	if row == nil {
+		return
+	}
+
+	t.rows[id] = row
+	for _, index := range t.indexes {
+		for _, key := range index.keys(row) {
+			if index.entries[key] == nil {
+				index.entries[key] = make(map[int64]*T)
+			}
+			index.entries[key][id] = row
+		}
+	}
+}
+
+// file возвращает имя файла данных таблицы
+func (t *table[T]) file() string {
+	return t.filename
+}
+
+// counterName возвращает имя счетчика ID таблицы
+func (t *table[T]) counterName() string {
+	return t.counter
+}
+
+// maxID возвращает наибольший первичный ключ (0 - таблица пуста)
+func (t *table[T]) maxID() int64 {
+	var max int64
+	for id := range t.rows {
+		if id > max {
+			max = id
+		}
+	}
+	return max
+}
+
+// load заменяет содержимое таблицы записями из файла данных (JSON массив)
+func (t *table[T]) load(data []byte) error {
+	var rows []T
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return fmt.Errorf("не удалось десериализовать %s: %w", t.filename, err)
+	}
+
+	t.rows = make(map[int64]*T, len(rows))
+	for _, index := range t.indexes {
+		index.entries = make(map[string]map[int64]*T)
+	}
+	for i := range rows {
+		t.restore(t.id(&rows[i]), &rows[i])
+	}
+	return nil
+}
+
+// snapshot сериализует таблицу для файла данных: JSON массив по возрастанию ключа с отступами
+func (t *table[T]) snapshot() ([]byte, error) {
+	return json.MarshalIndent(t.all(), "", "  ")
+}
+
+// rowJSON сериализует запись id для журнала (false - записи нет, она удалена)
+func (t *table[T]) rowJSON(id int64) (json.RawMessage, bool, error) {
+	row := t.rows[id]
+	if row == nil {
+		return nil, false, nil
+	}
+	data, err := json.Marshal(row)
+	return data, true, err
+}
+
+// applyJSON добавляет или заменяет запись из журнала
+func (t *table[T]) applyJSON(raw json.RawMessage) error {
+	row := new(T)
+	if err := json.Unmarshal(raw, row); err != nil {
+		return fmt.Errorf("не удалось десериализовать запись %s: %w", t.filename, err)
+	}
+	t.put(row)
+	return nil
+}
+
+// remove удаляет запись по команде журнала
+func (t *table[T]) remove(id int64) {
+	t.delete(id)
+}
@@ -1,6 +1,12 @@
 package repository
 
-import "p2pTG-crypto-exchange/internal/model"
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"p2pTG-crypto-exchange/internal/model"
+)
 
 // RepositoryInterface определяет методы для работы с хранилищем данных
 // Этот интерфейс может быть реализован для PostgreSQL, файлового хранилища или других БД
@@ -14,6 +20,7 @@ type RepositoryInterface interface {
 	GetUserByID(userID int64) (*model.User, error)
 	GetUserByTelegramID(telegramID int64) (*model.User, error)
 	UpdateUserChatMembership(telegramID int64, isMember bool) error
+	UpdateUserDealStats(userID int64, successful bool) error
 
 	// Методы для работы с заявками
 	CreateOrder(order *model.Order) error
@@ -21,20 +28,44 @@ type RepositoryInterface interface {
 	GetOrderByID(orderID int64) (*model.Order, error)
 	UpdateOrder(order *model.Order) error
 	UpdateOrderStatus(orderID int64, status model.OrderStatus) error
+	UpdateOrderPrice(orderID int64, price float64) error
 	GetMatchingOrders(order *model.Order) ([]*model.Order, error)
-	MatchOrders(orderID1, orderID2 int64) error
+	GetOrderBook(filter *model.OrderBookFilter) (*model.OrderBook, error)
+	GetExpiredOrders(now time.Time) ([]*model.Order, error)
+	ExpireOrder(orderID int64, now time.Time) error
+	UpdateOrderExpiration(orderID int64, expiresAt time.Time) error
+	ReserveOrderAmount(orderID int64, amount float64) (*model.Order, error)
+	ReleaseOrderAmount(orderID int64, amount float64) error
 
 	// Методы для работы со сделками
 	CreateDeal(deal *model.Deal) error
 	GetDealsByUserID(userID int64) ([]*model.Deal, error)
 	GetDealByID(dealID int64) (*model.Deal, error)
-	ConfirmDeal(dealID int64, userID int64, isPaymentProof bool, paymentProof string) error
-	ConfirmDealWithRole(dealID int64, userID int64, isAuthor bool, paymentProof string) error
+	ConfirmDealWithRole(dealID int64, userID int64, isAuthor bool, paymentProof string) (model.DealTransition, error)
+	GetUnconfirmedDealsExpiringBefore(deadline time.Time) ([]*model.Deal, error)
+	MarkDealExpiryWarningSent(dealID int64) error
+	ExpireDeal(dealID int64, now time.Time) error
+	RequestDealCancellation(dealID, userID int64, reason string, requestedAt time.Time) error
+	CancelDeal(dealID, userID int64, reason string, cancelledAt time.Time) error
+
+	// Методы для работы со спорами и хронологией сделок
+	OpenDealDispute(dealID, userID int64, reason, evidence string, openedAt time.Time) error
+	ResolveDealDispute(dealID int64, resolution *model.DisputeResolution) error
+	GetDisputedDeals() ([]*model.Deal, error)
+	GetCompletedTrades(filter *model.TradeFilter) ([]*model.Trade, error)
+	AddDealEvent(event *model.DealEvent) error
+	GetDealEvents(dealID int64) ([]*model.DealEvent, error)
 
 	// Методы для работы с откликами
 	CreateResponse(response *model.Response) error
 	GetResponsesByFilter(filter *model.ResponseFilter) ([]*model.Response, error)
-	UpdateResponseStatus(responseID int64, status model.ResponseStatus) error
+	GetResponseByID(responseID int64) (*model.Response, error)
+	UpdateResponseStatus(responseID int64, status model.ResponseStatus) error // Только открытый отклик (waiting, countered)
+	ReopenResponse(response *model.Response) error
+	UpdateResponseTerms(responseID int64, status model.ResponseStatus, proposedPrice, requestedAmount float64) error
+	WithdrawResponse(responseID int64) error
+	AddResponseOffer(offer *model.ResponseOffer) error
+	GetResponseOffers(responseID int64) ([]*model.ResponseOffer, error)
 	GetResponsesForOrder(orderID int64) ([]*model.Response, error)
 	GetResponsesFromUser(userID int64) ([]*model.Response, error)
 	GetResponsesForAuthor(authorID int64) ([]*model.Response, error)
@@ -46,4 +77,46 @@ type RepositoryInterface interface {
 	CheckCanReview(dealID, fromUserID, toUserID int64) (bool, error)
 	ReportReview(report *model.ReviewReport) error
 	GetUserReviewStats(userID int64) (*model.ReviewStats, error)
+
+	// Методы для работы с реестром активов
+	GetAssets() ([]*model.Asset, error)
+}
+
+// Transactional реализуют хранилища, которые умеют выполнять несколько операций атомарно.
+// fn получает хранилище транзакции: изменения через него фиксируются вместе, а при ошибке fn отменяются
+type Transactional interface {
+	InTransaction(fn func(tx RepositoryInterface) error) error
+}
+
+// Open создает хранилище по настройкам базы данных: SQLite при DB_DRIVER=sqlite,
+// PostgreSQL, если задана строка подключения, иначе файловое хранилище JSON в папке DataDir
+func Open(cfg model.DatabaseConfig) (RepositoryInterface, error) {
+	if cfg.Driver == "sqlite" {
+		log.Printf("[INFO] Открытие базы SQLite: %s", cfg.SQLitePath())
+		repo, err := NewSQLiteRepositoryWithConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось открыть базу SQLite: %w", err)
+		}
+		log.Println("[INFO] SQLite репозиторий инициализирован")
+		return repo, nil
+	}
+
+	if cfg.DSN() != "" {
+		log.Printf("[INFO] 🐘 Подключение к PostgreSQL базе данных...")
+		repo, err := NewRepositoryWithConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось подключиться к PostgreSQL: %w", err)
+		}
+		log.Println("[INFO] ✅ PostgreSQL репозиторий инициализирован")
+		return repo, nil
+	}
+
+	log.Printf("[WARN] DATABASE_URL не задан, используем файловое хранилище: %s", cfg.DataDir)
+	log.Printf("[WARN] ⚠️  При деплоях данные БУДУТ пропадать!")
+	repo, err := NewFileRepository(cfg.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось инициализировать файловый репозиторий: %w", err)
+	}
+	log.Println("[INFO] Файловое хранилище JSON данных готово")
+	return repo, nil
 }
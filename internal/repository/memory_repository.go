@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"log"
+)
+
+// =====================================================
+// ХРАНИЛИЩЕ В ПАМЯТИ
+// =====================================================
+
+// MemoryRepository хранилище целиком в памяти процесса: те же таблицы, вторичные индексы,
+// блокировки и транзакции, что у FileRepository, но без журнала и файлов данных.
+// Данные пропадают при остановке процесса - хранилище для тестов и демо-режима
+type MemoryRepository struct {
+	*FileRepository
+}
+
+// NewMemoryRepository создает пустое хранилище в памяти
+func NewMemoryRepository() *MemoryRepository {
+	repo := &MemoryRepository{
+		FileRepository: &FileRepository{db: newFileDB("")},
+	}
+
+	log.Println("[INFO] Хранилище в памяти инициализировано")
+	return repo
+}
+
+// Close закрывает хранилище; данные в памяти не сохраняются
+func (r *MemoryRepository) Close() error {
+	log.Println("[INFO] Хранилище в памяти закрыто, данные не сохраняются")
+	return nil
+}
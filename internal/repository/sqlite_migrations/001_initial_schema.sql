@@ -0,0 +1,235 @@
+-- Миграция для создания схемы SQLite хранилища P2P криптобиржи
+-- Версия: 001
+-- Описание: Все таблицы сразу в итоговом виде (схема PostgreSQL после миграций 001-016)
+-- Время хранится текстом в UTC ("2006-01-02 15:04:05.000000000"), поэтому сравнивается как строка;
+-- колонки времени объявлены как TIMESTAMP, чтобы драйвер возвращал их как time.Time
+
+-- =====================================================
+-- ТАБЛИЦА ПОЛЬЗОВАТЕЛЕЙ
+-- =====================================================
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY,                            -- Уникальный идентификатор пользователя
+    telegram_id INTEGER NOT NULL UNIQUE,               -- ID пользователя в Telegram (уникальный)
+    telegram_user_id TEXT NOT NULL DEFAULT '',         -- Username в Telegram (@username)
+    first_name TEXT NOT NULL,                          -- Имя из Telegram профиля
+    last_name TEXT NOT NULL DEFAULT '',                -- Фамилия из Telegram профиля
+    username TEXT NOT NULL DEFAULT '',                 -- Username из Telegram профиля
+    photo_url TEXT NOT NULL DEFAULT '',                -- URL фото профиля из Telegram
+    is_bot BOOLEAN NOT NULL DEFAULT 0,                 -- Флаг бота (должен быть false для пользователей)
+    language_code TEXT NOT NULL DEFAULT '',            -- Код языка пользователя
+    created_at TIMESTAMP NOT NULL,                     -- Дата и время создания аккаунта
+    updated_at TIMESTAMP NOT NULL,                     -- Дата и время последнего обновления
+    is_active BOOLEAN NOT NULL DEFAULT 1,              -- Активен ли пользователь (для блокировки)
+    rating REAL NOT NULL DEFAULT 0,                    -- Средний рейтинг пользователя (0-5)
+    total_deals INTEGER NOT NULL DEFAULT 0,            -- Общее количество закрытых сделок
+    successful_deals INTEGER NOT NULL DEFAULT 0,       -- Количество успешных сделок
+    chat_member BOOLEAN NOT NULL DEFAULT 0,            -- Является ли членом закрытого чата
+    chat_member_since TIMESTAMP                        -- С какого момента состоит в закрытом чате
+);
+
+-- =====================================================
+-- ТАБЛИЦА ЗАЯВОК
+-- =====================================================
+CREATE TABLE orders (
+    id INTEGER PRIMARY KEY,                            -- Уникальный идентификатор заявки
+    user_id INTEGER NOT NULL REFERENCES users(id),     -- Создатель заявки
+    type TEXT NOT NULL CHECK (type IN ('buy', 'sell')), -- Тип заявки (покупка/продажа)
+    cryptocurrency TEXT NOT NULL,                      -- Криптовалюта (BTC, ETH, USDT)
+    fiat_currency TEXT NOT NULL,                       -- Фиатная валюта (RUB, USD, EUR)
+    amount REAL NOT NULL,                              -- Количество криптовалюты
+    price REAL NOT NULL,                               -- Цена за единицу криптовалюты
+    total_amount REAL NOT NULL,                        -- Общая сумма (amount * price)
+    min_amount REAL NOT NULL DEFAULT 0,                -- Минимальная сумма для сделки
+    max_amount REAL NOT NULL DEFAULT 0,                -- Максимальная сумма для сделки
+    remaining_amount REAL NOT NULL DEFAULT 0,          -- Количество, еще не занятое сделками
+    payment_methods TEXT NOT NULL DEFAULT '[]',        -- Способы оплаты (JSON массив строк)
+    description TEXT NOT NULL DEFAULT '',              -- Дополнительное описание заявки
+    status TEXT NOT NULL DEFAULT 'active'              -- Статус заявки
+        CHECK (status IN ('active', 'has_responses', 'in_deal', 'completed', 'cancelled', 'expired')),
+    created_at TIMESTAMP NOT NULL,                     -- Дата и время создания заявки
+    updated_at TIMESTAMP NOT NULL,                     -- Дата и время последнего обновления
+    expires_at TIMESTAMP NOT NULL,                     -- Дата истечения заявки
+    completed_at TIMESTAMP,                            -- Время завершения
+    is_active BOOLEAN NOT NULL DEFAULT 1,              -- Активна ли заявка (для быстрой фильтрации)
+    response_count INTEGER NOT NULL DEFAULT 0,         -- Количество открытых откликов
+    accepted_response_id INTEGER,                      -- ID принятого отклика
+    auto_accept TEXT,                                  -- Правила автопринятия откликов (JSON, NULL - нет)
+    price_type TEXT NOT NULL DEFAULT 'fixed'           -- Тип цены
+        CHECK (price_type IN ('fixed', 'floating')),
+    price_margin REAL NOT NULL DEFAULT 0               -- Наценка к курсу в процентах
+);
+
+CREATE INDEX idx_orders_user_id ON orders(user_id);                           -- Заявки пользователя
+CREATE INDEX idx_orders_status ON orders(status, expires_at);                 -- Заявки на рынке и истекающие
+CREATE INDEX idx_orders_pair ON orders(cryptocurrency, fiat_currency, type, price); -- Стакан и сопоставление
+CREATE INDEX idx_orders_created_at ON orders(created_at);                     -- Сортировка по дате создания
+
+-- =====================================================
+-- ТАБЛИЦА ОТКЛИКОВ
+-- =====================================================
+CREATE TABLE responses (
+    id INTEGER PRIMARY KEY,                            -- Уникальный идентификатор отклика
+    order_id INTEGER NOT NULL REFERENCES orders(id),   -- Заявка, на которую откликнулись
+    user_id INTEGER NOT NULL REFERENCES users(id),     -- Откликнувшийся пользователь
+    message TEXT NOT NULL DEFAULT '',                  -- Сообщение от откликающегося
+    status TEXT NOT NULL DEFAULT 'waiting'             -- Статус отклика
+        CHECK (status IN ('waiting', 'accepted', 'rejected', 'countered', 'withdrawn')),
+    requested_amount REAL NOT NULL DEFAULT 0,          -- Запрошенное количество криптовалюты
+    proposed_price REAL NOT NULL DEFAULT 0,            -- Предложенная цена (0 - цена заявки)
+    payment_method TEXT NOT NULL DEFAULT '',           -- Выбранный способ оплаты
+    created_at TIMESTAMP NOT NULL,                     -- Время создания отклика
+    updated_at TIMESTAMP NOT NULL,                     -- Время последнего обновления
+    reviewed_at TIMESTAMP,                             -- Время рассмотрения автором
+
+    -- Ограничение: один отклик на заявку от каждого пользователя
+    UNIQUE(order_id, user_id)
+);
+
+CREATE INDEX idx_responses_user_id ON responses(user_id); -- Отклики пользователя
+
+-- =====================================================
+-- ИСТОРИЯ ПРЕДЛОЖЕНИЙ ПО ОТКЛИКАМ
+-- =====================================================
+CREATE TABLE response_offers (
+    id INTEGER PRIMARY KEY,                            -- Уникальный идентификатор предложения
+    response_id INTEGER NOT NULL REFERENCES responses(id), -- Отклик
+    user_id INTEGER NOT NULL,                          -- Кто сделал предложение
+    price REAL NOT NULL,                               -- Предложенная цена за единицу
+    amount REAL NOT NULL,                              -- Предложенное количество
+    message TEXT NOT NULL DEFAULT '',                  -- Комментарий к предложению
+    created_at TIMESTAMP NOT NULL                      -- Время предложения
+);
+
+CREATE INDEX idx_response_offers_response_id ON response_offers(response_id);
+
+-- =====================================================
+-- ТАБЛИЦА СДЕЛОК
+-- =====================================================
+CREATE TABLE deals (
+    id INTEGER PRIMARY KEY,                            -- Уникальный идентификатор сделки
+    response_id INTEGER NOT NULL,                      -- Отклик, на основе которого создана сделка
+    order_id INTEGER NOT NULL,                         -- Исходная заявка
+    author_id INTEGER NOT NULL,                        -- Автор заявки
+    counterparty_id INTEGER NOT NULL,                  -- Контрагент (откликнувшийся)
+    cryptocurrency TEXT NOT NULL,                      -- Торгуемая криптовалюта
+    fiat_currency TEXT NOT NULL,                       -- Фиатная валюта
+    amount REAL NOT NULL,                              -- Количество криптовалюты
+    price REAL NOT NULL,                               -- Цена за единицу
+    total_amount REAL NOT NULL,                        -- Общая сумма сделки
+    payment_methods TEXT NOT NULL DEFAULT '[]',        -- Способы оплаты (JSON массив строк)
+    order_type TEXT NOT NULL DEFAULT '',               -- Тип исходной заявки (buy/sell)
+    status TEXT NOT NULL                               -- Статус сделки
+        CHECK (status IN ('in_progress', 'waiting_confirmation', 'completed', 'expired', 'dispute', 'cancelled')),
+    created_at TIMESTAMP NOT NULL,                     -- Время создания сделки
+    expires_at TIMESTAMP,                              -- Крайний срок первого подтверждения
+    completed_at TIMESTAMP,                            -- Время завершения сделки
+    author_confirmed BOOLEAN NOT NULL DEFAULT 0,       -- Подтвердил ли автор заявки
+    counter_confirmed BOOLEAN NOT NULL DEFAULT 0,      -- Подтвердил ли контрагент
+    author_proof TEXT NOT NULL DEFAULT '',             -- Доказательство перевода от автора
+    counter_proof TEXT NOT NULL DEFAULT '',            -- Доказательство перевода от контрагента
+    notes TEXT NOT NULL DEFAULT '',                    -- Заметки по сделке
+    expiry_warning_sent BOOLEAN NOT NULL DEFAULT 0,    -- Отправлено ли предупреждение об истечении
+
+    -- Спор по сделке
+    dispute_reason TEXT NOT NULL DEFAULT '',
+    dispute_evidence TEXT NOT NULL DEFAULT '',
+    dispute_opened_by INTEGER NOT NULL DEFAULT 0,
+    dispute_opened_at TIMESTAMP,
+    dispute_winner_id INTEGER NOT NULL DEFAULT 0,
+    dispute_resolved_by INTEGER NOT NULL DEFAULT 0,
+    dispute_resolved_at TIMESTAMP,
+    dispute_comment TEXT NOT NULL DEFAULT '',
+
+    -- Отмена сделки
+    cancel_reason TEXT NOT NULL DEFAULT '',
+    cancel_requested_by INTEGER NOT NULL DEFAULT 0,
+    cancel_requested_at TIMESTAMP,
+    cancelled_by INTEGER NOT NULL DEFAULT 0,
+    cancelled_at TIMESTAMP,
+
+    matched_order_id INTEGER NOT NULL DEFAULT 0        -- Встречная заявка при автосопоставлении (0 - нет)
+);
+
+CREATE INDEX idx_deals_author_id ON deals(author_id);             -- Сделки автора
+CREATE INDEX idx_deals_counterparty_id ON deals(counterparty_id); -- Сделки контрагента
+CREATE INDEX idx_deals_status ON deals(status, expires_at);       -- Фильтр по статусу и сроку
+CREATE INDEX idx_deals_completed_at ON deals(completed_at);       -- Рыночные сделки за период
+
+-- =====================================================
+-- ХРОНОЛОГИЯ СДЕЛОК
+-- =====================================================
+CREATE TABLE deal_events (
+    id INTEGER PRIMARY KEY,                            -- Уникальный идентификатор записи
+    deal_id INTEGER NOT NULL REFERENCES deals(id),     -- Сделка
+    user_id INTEGER NOT NULL DEFAULT 0,                -- Кто совершил действие (0 - система)
+    type TEXT NOT NULL,                                -- Тип события
+    status TEXT NOT NULL,                              -- Статус сделки после события
+    message TEXT NOT NULL DEFAULT '',                  -- Описание события
+    created_at TIMESTAMP NOT NULL                      -- Время события
+);
+
+CREATE INDEX idx_deal_events_deal_id ON deal_events(deal_id);
+
+-- =====================================================
+-- ТАБЛИЦЫ ОТЗЫВОВ И РЕЙТИНГОВ
+-- =====================================================
+CREATE TABLE reviews (
+    id INTEGER PRIMARY KEY,                            -- Уникальный идентификатор отзыва
+    deal_id INTEGER NOT NULL,                          -- Сделка, по которой оставлен отзыв
+    from_user_id INTEGER NOT NULL,                     -- Автор отзыва
+    to_user_id INTEGER NOT NULL,                       -- Получатель отзыва
+    rating INTEGER NOT NULL CHECK (rating BETWEEN 1 AND 5), -- Оценка от 1 до 5
+    type TEXT NOT NULL CHECK (type IN ('positive', 'neutral', 'negative')), -- Тип отзыва
+    comment TEXT NOT NULL DEFAULT '',                  -- Текст отзыва
+    is_anonymous BOOLEAN NOT NULL DEFAULT 0,           -- Анонимный ли отзыв
+    created_at TIMESTAMP NOT NULL,                     -- Дата создания отзыва
+    updated_at TIMESTAMP NOT NULL,                     -- Дата последнего обновления
+    is_visible BOOLEAN NOT NULL DEFAULT 1,             -- Видимый ли отзыв
+    reported_count INTEGER NOT NULL DEFAULT 0          -- Количество жалоб на отзыв
+);
+
+CREATE INDEX idx_reviews_to_user_id ON reviews(to_user_id, is_visible); -- Отзывы о пользователе
+CREATE INDEX idx_reviews_deal_id ON reviews(deal_id);                   -- Проверка повторного отзыва
+
+-- Агрегированный рейтинг пересчитывается при каждом новом отзыве; нет строки - нет отзывов
+CREATE TABLE ratings (
+    user_id INTEGER PRIMARY KEY,                       -- ID пользователя
+    average_rating REAL NOT NULL DEFAULT 0,            -- Средний рейтинг (0-5)
+    total_reviews INTEGER NOT NULL DEFAULT 0,          -- Общее количество отзывов
+    positive_reviews INTEGER NOT NULL DEFAULT 0,       -- Положительных отзывов
+    neutral_reviews INTEGER NOT NULL DEFAULT 0,        -- Нейтральных отзывов
+    negative_reviews INTEGER NOT NULL DEFAULT 0,       -- Отрицательных отзывов
+    five_stars INTEGER NOT NULL DEFAULT 0,             -- 5-звездочных оценок
+    four_stars INTEGER NOT NULL DEFAULT 0,             -- 4-звездочных оценок
+    three_stars INTEGER NOT NULL DEFAULT 0,            -- 3-звездочных оценок
+    two_stars INTEGER NOT NULL DEFAULT 0,              -- 2-звездочных оценок
+    one_star INTEGER NOT NULL DEFAULT 0,               -- 1-звездочных оценок
+    updated_at TIMESTAMP NOT NULL                      -- Дата последнего пересчета
+);
+
+CREATE TABLE review_reports (
+    id INTEGER PRIMARY KEY,                            -- Уникальный идентификатор жалобы
+    review_id INTEGER NOT NULL,                        -- Отзыв, на который жалуются
+    user_id INTEGER NOT NULL,                          -- Пользователь, подавший жалобу
+    reason TEXT NOT NULL,                              -- Причина жалобы
+    comment TEXT NOT NULL DEFAULT '',                  -- Дополнительный комментарий
+    status TEXT NOT NULL DEFAULT 'pending',            -- Статус рассмотрения жалобы
+    created_at TIMESTAMP NOT NULL,                     -- Дата подачи жалобы
+    resolved_at TIMESTAMP                              -- Дата рассмотрения жалобы
+);
+
+-- =====================================================
+-- РЕЕСТР АКТИВОВ
+-- =====================================================
+-- Строка с тем же kind и code переопределяет встроенную запись реестра (см. миграцию PostgreSQL 016)
+CREATE TABLE assets (
+    code TEXT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('crypto', 'fiat', 'payment_method')),
+    display_name TEXT NOT NULL,
+    decimals INTEGER NOT NULL DEFAULT 2 CHECK (decimals BETWEEN 0 AND 18),
+    min_order_amount REAL NOT NULL DEFAULT 0 CHECK (min_order_amount >= 0),
+    max_order_amount REAL NOT NULL DEFAULT 0 CHECK (max_order_amount >= 0),
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    is_active BOOLEAN NOT NULL DEFAULT 1,
+    PRIMARY KEY (kind, code)
+);
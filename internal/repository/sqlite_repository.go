@@ -0,0 +1,1926 @@
+package repository
+
+import (
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"p2pTG-crypto-exchange/internal/model"
+
+	_ "modernc.org/sqlite"
+)
+
+// =====================================================
+// SQLITE РЕПОЗИТОРИЙ
+// =====================================================
+
+// SQLiteRepository хранилище данных в одном файле SQLite (драйвер modernc.org/sqlite на чистом Go, без cgo).
+// Поведение совпадает с FileRepository: те же правила статусов, счетчики откликов и пересчет рейтингов,
+// но данные не загружаются в память целиком, а каждая операция атомарна на уровне базы
+type SQLiteRepository struct {
+	db   *sql.DB // Пул соединений с файлом базы
+	tx   *sql.Tx // Транзакция InTransaction (nil - операции выполняются в собственных транзакциях)
+	path string  // Путь к файлу базы
+}
+
+// sqliteMigrations миграции схемы SQLite; применяются при открытии базы по порядку имен файлов
+//
+//go:embed sqlite_migrations/*.sql
+var sqliteMigrations embed.FS
+
+// sqliteTimeLayout формат хранения времени: UTC с фиксированной длиной дробной части,
+// чтобы строки сравнивались в SQL в том же порядке, что и моменты времени
+const sqliteTimeLayout = "2006-01-02 15:04:05.000000000"
+
+// sqliteQuerier общий интерфейс для *sql.DB и *sql.Tx
+type sqliteQuerier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// NewSQLiteRepository открывает (или создает) базу SQLite в файле path и применяет недостающие миграции
+func NewSQLiteRepository(path string) (*SQLiteRepository, error) {
+	return NewSQLiteRepositoryWithConfig(model.DatabaseConfig{Driver: "sqlite", URL: path})
+}
+
+// NewSQLiteRepositoryWithConfig открывает базу SQLite по настройкам базы данных:
+// путь к файлу берется из SQLitePath, параметры пула - как для PostgreSQL
+func NewSQLiteRepositoryWithConfig(cfg model.DatabaseConfig) (*SQLiteRepository, error) {
+	path := cfg.SQLitePath()
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("не удалось создать папку базы данных: %w", err)
+		}
+	}
+
+	// WAL позволяет читать параллельно с записью; immediate-транзакции сразу берут блокировку записи,
+	// а busy_timeout заставляет конкурирующих писателей ждать, а не получать SQLITE_BUSY
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть базу SQLite: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("не удалось подключиться к базе SQLite: %w", err)
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	repo := &SQLiteRepository{db: db, path: path}
+	if err := repo.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	log.Printf("[INFO] SQLite база данных открыта: %s", path)
+	return repo, nil
+}
+
+// migrate применяет миграции из sqlite_migrations, которых еще нет в таблице schema_migrations
+func (r *SQLiteRepository) migrate() error {
+	if _, err := r.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version TEXT PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("не удалось создать таблицу миграций: %w", err)
+	}
+
+	entries, err := fs.ReadDir(sqliteMigrations, "sqlite_migrations")
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать список миграций: %w", err)
+	}
+
+	for _, entry := range entries {
+		version := entry.Name()
+
+		var applied int
+		if err := r.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, version).Scan(&applied); err != nil {
+			return fmt.Errorf("не удалось проверить миграцию %s: %w", version, err)
+		}
+		if applied > 0 {
+			continue
+		}
+
+		migrationSQL, err := sqliteMigrations.ReadFile("sqlite_migrations/" + version)
+		if err != nil {
+			return fmt.Errorf("не удалось прочитать миграцию %s: %w", version, err)
+		}
+
+		err = r.inTx(func(q sqliteQuerier) error {
+			if _, err := q.Exec(string(migrationSQL)); err != nil {
+				return err
+			}
+			_, err := q.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, version, sqliteTime(time.Now()))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("не удалось применить миграцию %s: %w", version, err)
+		}
+		log.Printf("[INFO] Применена миграция SQLite: %s", version)
+	}
+
+	return nil
+}
+
+// Close закрывает базу данных
+func (r *SQLiteRepository) Close() error {
+	if r.tx != nil {
+		return fmt.Errorf("нельзя закрыть хранилище внутри транзакции")
+	}
+
+	log.Println("[INFO] Закрытие базы SQLite")
+	return r.db.Close()
+}
+
+// HealthCheck проверяет доступность базы данных
+func (r *SQLiteRepository) HealthCheck() error {
+	var result int
+	if err := r.conn().QueryRow("SELECT 1").Scan(&result); err != nil {
+		return fmt.Errorf("база SQLite недоступна: %w", err)
+	}
+	return nil
+}
+
+// InTransaction выполняет fn в одной транзакции SQLite: все изменения через tx фиксируются вместе,
+// а ошибка fn откатывает их. Внутри транзакции вложенный вызов использует точку сохранения
+func (r *SQLiteRepository) InTransaction(fn func(tx RepositoryInterface) error) error {
+	return r.inTx(func(q sqliteQuerier) error {
+		return fn(&SQLiteRepository{db: r.db, tx: q.(*sql.Tx), path: r.path})
+	})
+}
+
+// conn возвращает транзакцию InTransaction или пул соединений
+func (r *SQLiteRepository) conn() sqliteQuerier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db
+}
+
+// inTx выполняет fn атомарно: в новой транзакции или, если хранилище уже в транзакции,
+// под точкой сохранения, чтобы ошибка fn отменяла только изменения самой fn
+func (r *SQLiteRepository) inTx(fn func(q sqliteQuerier) error) (err error) {
+	if r.tx != nil {
+		if _, err := r.tx.Exec(`SAVEPOINT repository_op`); err != nil {
+			return fmt.Errorf("не удалось создать точку сохранения: %w", err)
+		}
+		if err := fn(r.tx); err != nil {
+			if _, rollbackErr := r.tx.Exec(`ROLLBACK TO repository_op`); rollbackErr != nil {
+				log.Printf("[ERROR] Не удалось откатить точку сохранения: %v", rollbackErr)
+			}
+			r.tx.Exec(`RELEASE repository_op`)
+			return err
+		}
+		if _, err := r.tx.Exec(`RELEASE repository_op`); err != nil {
+			return fmt.Errorf("не удалось освободить точку сохранения: %w", err)
+		}
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+	defer tx.Rollback() // Откатываем транзакцию если что-то пойдет не так
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
+	}
+	return nil
+}
+
+// sqliteTime переводит время в формат хранения sqliteTimeLayout
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+// sqliteNullTime переводит необязательное время в формат хранения (nil - NULL)
+func sqliteNullTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return sqliteTime(*t)
+}
+
+// sqliteZeroTime переводит время, у которого нулевое значение означает "не задано", в формат хранения (NULL)
+func sqliteZeroTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return sqliteTime(t)
+}
+
+// marshalStrings сериализует список строк в JSON массив (nil - пустой массив)
+func marshalStrings(values []string) (string, error) {
+	if values == nil {
+		values = []string{}
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("не удалось сериализовать способы оплаты: %w", err)
+	}
+	return string(data), nil
+}
+
+// execAffected выполняет запрос изменения и возвращает ошибку notFound, если ни одна строка не изменена
+func execAffected(q sqliteQuerier, notFound error, query string, args ...interface{}) error {
+	result, err := q.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("не удалось проверить результат обновления: %w", err)
+	}
+	if rowsAffected == 0 {
+		return notFound
+	}
+	return nil
+}
+
+// =====================================================
+// МЕТОДЫ ДЛЯ РАБОТЫ С ПОЛЬЗОВАТЕЛЯМИ
+// =====================================================
+
+// sqliteUserColumns список колонок пользователя в порядке, который ожидает scanSQLiteUser
+const sqliteUserColumns = `id, telegram_id, telegram_user_id, first_name, last_name, username, photo_url,
+		       is_bot, language_code, created_at, updated_at, is_active, rating, total_deals,
+		       successful_deals, chat_member, chat_member_since`
+
+// scanSQLiteUser сканирует строку с колонками sqliteUserColumns в модель пользователя
+func scanSQLiteUser(row rowScanner) (*model.User, error) {
+	user := &model.User{}
+	err := row.Scan(
+		&user.ID, &user.TelegramID, &user.TelegramUserID, &user.FirstName, &user.LastName, &user.Username,
+		&user.PhotoURL, &user.IsBot, &user.LanguageCode, &user.CreatedAt, &user.UpdatedAt, &user.IsActive,
+		&user.Rating, &user.TotalDeals, &user.SuccessfulDeals, &user.ChatMember, &user.ChatMemberSince,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// CreateUser создает нового пользователя
+func (r *SQLiteRepository) CreateUser(user *model.User) error {
+	return r.inTx(func(q sqliteQuerier) error {
+		var exists int
+		if err := q.QueryRow(`SELECT COUNT(*) FROM users WHERE telegram_id = ?`, user.TelegramID).Scan(&exists); err != nil {
+			return fmt.Errorf("не удалось проверить пользователя: %w", err)
+		}
+		if exists > 0 {
+			return fmt.Errorf("пользователь с Telegram ID %d уже существует", user.TelegramID)
+		}
+
+		now := time.Now()
+		var chatMemberSince *time.Time
+		if user.ChatMember {
+			chatMemberSince = &now
+		}
+
+		result, err := q.Exec(`
+			INSERT INTO users (
+				telegram_id, telegram_user_id, first_name, last_name, username, photo_url,
+				is_bot, language_code, created_at, updated_at, is_active, chat_member, chat_member_since
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?)`,
+			user.TelegramID, user.TelegramUserID, user.FirstName, user.LastName, user.Username, user.PhotoURL,
+			user.IsBot, user.LanguageCode, sqliteTime(now), sqliteTime(now), user.ChatMember, sqliteNullTime(chatMemberSince),
+		)
+		if err != nil {
+			return fmt.Errorf("не удалось создать пользователя: %w", err)
+		}
+		if user.ID, err = result.LastInsertId(); err != nil {
+			return fmt.Errorf("не удалось получить ID пользователя: %w", err)
+		}
+
+		user.CreatedAt = now
+		user.UpdatedAt = now
+		user.IsActive = true
+		user.Rating = 0.0
+		user.TotalDeals = 0
+		user.SuccessfulDeals = 0
+		user.ChatMemberSince = chatMemberSince
+
+		log.Printf("[INFO] Создан пользователь в SQLite: ID=%d, TelegramID=%d", user.ID, user.TelegramID)
+		return nil
+	})
+}
+
+// GetUserByTelegramID находит пользователя по Telegram ID
+func (r *SQLiteRepository) GetUserByTelegramID(telegramID int64) (*model.User, error) {
+	user, err := scanSQLiteUser(r.conn().QueryRow(`SELECT `+sqliteUserColumns+` FROM users WHERE telegram_id = ?`, telegramID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("пользователь с Telegram ID %d не найден", telegramID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось найти пользователя: %w", err)
+	}
+	return user, nil
+}
+
+// GetUserByID находит пользователя по внутреннему ID
+func (r *SQLiteRepository) GetUserByID(userID int64) (*model.User, error) {
+	user, err := scanSQLiteUser(r.conn().QueryRow(`SELECT `+sqliteUserColumns+` FROM users WHERE id = ?`, userID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("пользователь с ID %d не найден", userID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось найти пользователя: %w", err)
+	}
+	return user, nil
+}
+
+// UpdateUserChatMembership обновляет статус членства в чате
+// Дата вступления сохраняется, пока пользователь остается в чате
+func (r *SQLiteRepository) UpdateUserChatMembership(telegramID int64, isMember bool) error {
+	now := sqliteTime(time.Now())
+	err := execAffected(r.conn(), fmt.Errorf("пользователь с Telegram ID %d не найден", telegramID), `
+		UPDATE users SET
+			chat_member_since = CASE
+				WHEN NOT ? THEN NULL
+				WHEN chat_member = 0 OR chat_member_since IS NULL THEN ?
+				ELSE chat_member_since
+			END,
+			chat_member = ?,
+			updated_at = ?
+		WHERE telegram_id = ?`,
+		isMember, now, isMember, now, telegramID)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Обновлен статус членства пользователя TelegramID=%d: %t", telegramID, isMember)
+	return nil
+}
+
+// UpdateUserDealStats учитывает закрытую сделку в статистике пользователя
+// successful - сделка завершена успешно (иначе отменена или истекла)
+func (r *SQLiteRepository) UpdateUserDealStats(userID int64, successful bool) error {
+	err := execAffected(r.conn(), fmt.Errorf("пользователь с ID %d не найден", userID), `
+		UPDATE users SET
+			total_deals = total_deals + 1,
+			successful_deals = successful_deals + CASE WHEN ? THEN 1 ELSE 0 END,
+			updated_at = ?
+		WHERE id = ?`,
+		successful, sqliteTime(time.Now()), userID)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Обновлена статистика сделок пользователя ID=%d (успешная: %t)", userID, successful)
+	return nil
+}
+
+// =====================================================
+// МЕТОДЫ ДЛЯ РАБОТЫ С ЗАЯВКАМИ
+// =====================================================
+
+// sqliteOrderColumns список колонок заявки в порядке, который ожидает scanSQLiteOrder
+const sqliteOrderColumns = `id, user_id, type, cryptocurrency, fiat_currency, amount, price, total_amount,
+		       min_amount, max_amount, remaining_amount, payment_methods, description, status,
+		       created_at, updated_at, expires_at, completed_at, is_active, response_count,
+		       accepted_response_id, auto_accept, price_type, price_margin`
+
+// scanSQLiteOrder сканирует строку с колонками sqliteOrderColumns в модель заявки
+func scanSQLiteOrder(row rowScanner) (*model.Order, error) {
+	order := &model.Order{}
+	var paymentMethodsJSON, autoAcceptJSON []byte
+	err := row.Scan(
+		&order.ID, &order.UserID, &order.Type, &order.Cryptocurrency, &order.FiatCurrency,
+		&order.Amount, &order.Price, &order.TotalAmount, &order.MinAmount, &order.MaxAmount, &order.RemainingAmount,
+		&paymentMethodsJSON, &order.Description, &order.Status, &order.CreatedAt, &order.UpdatedAt,
+		&order.ExpiresAt, &order.CompletedAt, &order.IsActive, &order.ResponseCount,
+		&order.AcceptedResponseID, &autoAcceptJSON, &order.PriceType, &order.PriceMargin,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("не удалось сканировать заявку: %w", err)
+	}
+
+	if err := json.Unmarshal(paymentMethodsJSON, &order.PaymentMethods); err != nil {
+		return nil, fmt.Errorf("не удалось парсить способы оплаты: %w", err)
+	}
+	if order.AutoAccept, err = unmarshalAutoAcceptRules(autoAcceptJSON); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// queryOrders выполняет запрос с колонками sqliteOrderColumns и сканирует все заявки
+func queryOrders(q sqliteQuerier, query string, args ...interface{}) ([]*model.Order, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось выполнить запрос заявок: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []*model.Order
+	for rows.Next() {
+		order, err := scanSQLiteOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по заявкам: %w", err)
+	}
+	return orders, nil
+}
+
+// getOrder получает заявку по ID через q
+func getOrder(q sqliteQuerier, orderID int64) (*model.Order, error) {
+	order, err := scanSQLiteOrder(q.QueryRow(`SELECT `+sqliteOrderColumns+` FROM orders WHERE id = ?`, orderID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("заявка с ID=%d не найдена", orderID)
+	}
+	return order, err
+}
+
+// CreateOrder создает новую заявку
+func (r *SQLiteRepository) CreateOrder(order *model.Order) error {
+	now := time.Now()
+	order.CreatedAt = now
+	order.UpdatedAt = now
+	order.Status = model.OrderStatusActive
+	order.IsActive = true
+	// Срок действия задается сервисом, по умолчанию заявка живет 1 год
+	if order.ExpiresAt.IsZero() {
+		order.ExpiresAt = now.Add(365 * 24 * time.Hour)
+	}
+
+	paymentMethodsJSON, err := marshalStrings(order.PaymentMethods)
+	if err != nil {
+		return err
+	}
+	autoAcceptJSON, err := marshalAutoAcceptRules(order.AutoAccept)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.conn().Exec(`
+		INSERT INTO orders (
+			user_id, type, cryptocurrency, fiat_currency, amount, price, total_amount,
+			min_amount, max_amount, remaining_amount, payment_methods, description, status,
+			created_at, updated_at, expires_at, completed_at, is_active, response_count,
+			accepted_response_id, auto_accept, price_type, price_margin
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		order.UserID, order.Type, order.Cryptocurrency, order.FiatCurrency, order.Amount, order.Price, order.TotalAmount,
+		order.MinAmount, order.MaxAmount, order.RemainingAmount, paymentMethodsJSON, order.Description, order.Status,
+		sqliteTime(order.CreatedAt), sqliteTime(order.UpdatedAt), sqliteTime(order.ExpiresAt), sqliteNullTime(order.CompletedAt),
+		order.IsActive, order.ResponseCount, order.AcceptedResponseID, autoAcceptJSON, orderPriceType(order), order.PriceMargin,
+	)
+	if err != nil {
+		return fmt.Errorf("не удалось создать заявку: %w", err)
+	}
+	if order.ID, err = result.LastInsertId(); err != nil {
+		return fmt.Errorf("не удалось получить ID заявки: %w", err)
+	}
+
+	log.Printf("[INFO] Создана заявка в SQLite: ID=%d, Type=%s, Amount=%.8f", order.ID, order.Type, order.Amount)
+	return nil
+}
+
+// GetOrdersByFilter получает заявки по фильтрам с сортировкой и пагинацией
+// При равных значениях сортировки заявки идут в порядке создания (по ID)
+func (r *SQLiteRepository) GetOrdersByFilter(filter *model.OrderFilter) ([]*model.Order, error) {
+	var where []string
+	var args []interface{}
+	add := func(condition string, value interface{}) {
+		where = append(where, condition)
+		args = append(args, value)
+	}
+
+	// По умолчанию только активные заявки, кроме случая включения неактивных
+	if !filter.IncludeInactive {
+		where = append(where, "is_active = 1")
+	}
+	if filter.Type != nil {
+		add("type = ?", *filter.Type)
+	}
+	if filter.Cryptocurrency != nil {
+		add("cryptocurrency = ?", *filter.Cryptocurrency)
+	}
+	if filter.FiatCurrency != nil {
+		add("fiat_currency = ?", *filter.FiatCurrency)
+	}
+	if filter.Status != nil {
+		add("status = ?", *filter.Status)
+	}
+	if filter.UserID != nil {
+		add("user_id = ?", *filter.UserID)
+	}
+	if filter.CreatedAfter != nil {
+		add("created_at >= ?", sqliteTime(*filter.CreatedAfter))
+	}
+	if filter.CreatedBefore != nil {
+		add("created_at <= ?", sqliteTime(*filter.CreatedBefore))
+	}
+
+	query := `SELECT ` + sqliteOrderColumns + ` FROM orders`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	// Колонка сортировки только из списка, чтобы не подставлять ввод пользователя в запрос
+	sortBy := "created_at"
+	switch filter.SortBy {
+	case "price", "amount":
+		sortBy = filter.SortBy
+	}
+	sortOrder := "DESC"
+	if filter.SortOrder == "asc" {
+		sortOrder = "ASC"
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s, id ASC", sortBy, sortOrder)
+
+	// LIMIT -1 в SQLite означает "без ограничения"
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	query += " LIMIT ? OFFSET ?"
+	args = append(args, limit, max(filter.Offset, 0))
+
+	orders, err := queryOrders(r.conn(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[INFO] Найдено заявок по фильтру: %d", len(orders))
+	return orders, nil
+}
+
+// GetOrderByID получает заявку по ID
+func (r *SQLiteRepository) GetOrderByID(orderID int64) (*model.Order, error) {
+	return getOrder(r.conn(), orderID)
+}
+
+// UpdateOrder обновляет существующую заявку (кроме системных полей)
+func (r *SQLiteRepository) UpdateOrder(order *model.Order) error {
+	paymentMethodsJSON, err := marshalStrings(order.PaymentMethods)
+	if err != nil {
+		return err
+	}
+	autoAcceptJSON, err := marshalAutoAcceptRules(order.AutoAccept)
+	if err != nil {
+		return err
+	}
+
+	err = execAffected(r.conn(), fmt.Errorf("заявка с ID %d не найдена", order.ID), `
+		UPDATE orders SET
+			type = ?, cryptocurrency = ?, fiat_currency = ?, amount = ?, price = ?, total_amount = ?,
+			min_amount = ?, max_amount = ?, remaining_amount = ?, payment_methods = ?, description = ?,
+			auto_accept = ?, price_type = ?, price_margin = ?, updated_at = ?
+		WHERE id = ?`,
+		order.Type, order.Cryptocurrency, order.FiatCurrency, order.Amount, order.Price, order.TotalAmount,
+		order.MinAmount, order.MaxAmount, order.RemainingAmount, paymentMethodsJSON, order.Description,
+		autoAcceptJSON, orderPriceType(order), order.PriceMargin, sqliteTime(time.Now()), order.ID)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Обновлена заявка ID=%d: Type=%s, Amount=%.8f", order.ID, order.Type, order.Amount)
+	return nil
+}
+
+// UpdateOrderStatus обновляет статус заявки; отмененная или завершенная заявка становится неактивной
+func (r *SQLiteRepository) UpdateOrderStatus(orderID int64, status model.OrderStatus) error {
+	closed := status == model.OrderStatusCancelled || status == model.OrderStatusCompleted
+	err := execAffected(r.conn(), fmt.Errorf("заявка с ID %d не найдена", orderID), `
+		UPDATE orders SET
+			status = ?,
+			is_active = CASE WHEN ? THEN 0 ELSE is_active END,
+			updated_at = ?
+		WHERE id = ?`,
+		status, closed, sqliteTime(time.Now()), orderID)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Обновлен статус заявки ID=%d: %s", orderID, status)
+	return nil
+}
+
+// UpdateOrderPrice сохраняет пересчитанную цену заявки с плавающей ценой и общую сумму по ней
+// для объема, еще не занятого сделками
+func (r *SQLiteRepository) UpdateOrderPrice(orderID int64, price float64) error {
+	return execAffected(r.conn(), fmt.Errorf("заявка с ID %d не найдена", orderID),
+		`UPDATE orders SET price = ?, total_amount = remaining_amount * ? WHERE id = ?`, price, price, orderID)
+}
+
+// GetExpiredOrders получает заявки на рынке (active/has_responses), срок действия которых истек к моменту now
+func (r *SQLiteRepository) GetExpiredOrders(now time.Time) ([]*model.Order, error) {
+	return queryOrders(r.conn(), `
+		SELECT `+sqliteOrderColumns+`
+		FROM orders
+		WHERE status IN ('active', 'has_responses') AND expires_at <= ?
+		ORDER BY id`,
+		sqliteTime(now))
+}
+
+// ExpireOrder переводит заявку в статус "expired", если она еще на рынке и ее срок истек
+func (r *SQLiteRepository) ExpireOrder(orderID int64, now time.Time) error {
+	err := r.inTx(func(q sqliteQuerier) error {
+		order, err := getOrder(q, orderID)
+		if err != nil {
+			return err
+		}
+		if !isOrderOnMarket(order) || order.ExpiresAt.After(now) {
+			return fmt.Errorf("заявка ID=%d не может быть истекшей (статус %s)", orderID, order.Status)
+		}
+
+		_, err = q.Exec(`UPDATE orders SET status = ?, is_active = 0, updated_at = ? WHERE id = ?`,
+			model.OrderStatusExpired, sqliteTime(now), orderID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Заявка ID=%d истекла", orderID)
+	return nil
+}
+
+// UpdateOrderExpiration устанавливает новый срок действия заявки
+func (r *SQLiteRepository) UpdateOrderExpiration(orderID int64, expiresAt time.Time) error {
+	err := execAffected(r.conn(), fmt.Errorf("заявка с ID %d не найдена", orderID),
+		`UPDATE orders SET expires_at = ?, updated_at = ? WHERE id = ?`,
+		sqliteTime(expiresAt), sqliteTime(time.Now()), orderID)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Срок заявки ID=%d продлен до %s", orderID, expiresAt.Format(time.RFC3339))
+	return nil
+}
+
+// ReserveOrderAmount занимает часть заявки под новую сделку
+// Заявка должна быть на рынке и иметь достаточный остаток; исчерпанная заявка снимается с рынка (in_deal)
+func (r *SQLiteRepository) ReserveOrderAmount(orderID int64, amount float64) (*model.Order, error) {
+	var reserved *model.Order
+	err := r.inTx(func(q sqliteQuerier) error {
+		order, err := getOrder(q, orderID)
+		if err != nil {
+			return err
+		}
+		if !isOrderOnMarket(order) {
+			return fmt.Errorf("заявка ID=%d в статусе '%s' недоступна для сделок", orderID, order.Status)
+		}
+		if amount > order.RemainingAmount+model.AmountEpsilon {
+			return fmt.Errorf("в заявке ID=%d осталось только %.8f", orderID, order.RemainingAmount)
+		}
+
+		order.RemainingAmount = model.RoundAmount(order.RemainingAmount - amount)
+		if order.IsFilled() {
+			order.RemainingAmount = 0
+			order.Status = model.OrderStatusInDeal
+		}
+		order.UpdatedAt = time.Now()
+
+		_, err = q.Exec(`UPDATE orders SET remaining_amount = ?, status = ?, updated_at = ? WHERE id = ?`,
+			order.RemainingAmount, order.Status, sqliteTime(order.UpdatedAt), orderID)
+		reserved = order
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[INFO] Из заявки ID=%d занято %.8f, остаток %.8f (%s)",
+		orderID, amount, reserved.RemainingAmount, reserved.Status)
+	return reserved, nil
+}
+
+// ReleaseOrderAmount возвращает в заявку объем закрытой без исполнения сделки
+// Снятая с рынка из-за исчерпания заявка возвращается на рынок
+func (r *SQLiteRepository) ReleaseOrderAmount(orderID int64, amount float64) error {
+	var released *model.Order
+	err := r.inTx(func(q sqliteQuerier) error {
+		order, err := getOrder(q, orderID)
+		if err != nil {
+			return err
+		}
+
+		order.RemainingAmount = model.RoundAmount(order.RemainingAmount + amount)
+		if order.RemainingAmount > order.Amount {
+			order.RemainingAmount = order.Amount
+		}
+		if order.Status == model.OrderStatusInDeal {
+			order.Status = model.OrderStatusActive
+		}
+
+		_, err = q.Exec(`UPDATE orders SET remaining_amount = ?, status = ?, updated_at = ? WHERE id = ?`,
+			order.RemainingAmount, order.Status, sqliteTime(time.Now()), orderID)
+		released = order
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] В заявку ID=%d возвращено %.8f, остаток %.8f (%s)",
+		orderID, amount, released.RemainingAmount, released.Status)
+	return nil
+}
+
+// GetOrderBook строит стакан заявок по торговой паре агрегацией в SQL:
+// свободные остатки заявок на рынке суммируются по ценовым уровням каждой стороны
+func (r *SQLiteRepository) GetOrderBook(filter *model.OrderBookFilter) (*model.OrderBook, error) {
+	now := time.Now()
+	rows, err := r.conn().Query(`
+		SELECT type, price, SUM(remaining_amount), COUNT(*)
+		FROM orders
+		WHERE cryptocurrency = ?
+		  AND fiat_currency = ?
+		  AND status IN ('active', 'has_responses')
+		  AND is_active = 1
+		  AND remaining_amount >= ?
+		  AND expires_at > ?
+		  AND (? = '' OR EXISTS (SELECT 1 FROM json_each(payment_methods) WHERE value = ?))
+		GROUP BY type, price
+		ORDER BY type, CASE WHEN type = 'buy' THEN -price ELSE price END`,
+		filter.Cryptocurrency, filter.FiatCurrency, model.AmountEpsilon, sqliteTime(now),
+		filter.PaymentMethod, filter.PaymentMethod)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось построить стакан заявок: %w", err)
+	}
+	defer rows.Close()
+
+	book := &model.OrderBook{
+		Cryptocurrency: filter.Cryptocurrency,
+		FiatCurrency:   filter.FiatCurrency,
+		PaymentMethod:  filter.PaymentMethod,
+		Bids:           []model.OrderBookLevel{},
+		Asks:           []model.OrderBookLevel{},
+		UpdatedAt:      now,
+	}
+	for rows.Next() {
+		var orderType model.OrderType
+		var level model.OrderBookLevel
+		if err := rows.Scan(&orderType, &level.Price, &level.Amount, &level.OrderCount); err != nil {
+			return nil, fmt.Errorf("не удалось сканировать уровень стакана: %w", err)
+		}
+		level.Amount = model.RoundAmount(level.Amount)
+		level.Total = level.Amount * level.Price
+
+		// Уровни уже упорядочены от лучшей цены: лишние по глубине отбрасываем
+		side := &book.Asks
+		if orderType == model.OrderTypeBuy {
+			side = &book.Bids
+		}
+		if filter.Depth <= 0 || len(*side) < filter.Depth {
+			*side = append(*side, level)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по уровням стакана: %w", err)
+	}
+
+	return book, nil
+}
+
+// GetMatchingOrders находит встречные заявки на рынке, цена которых пересекается с ценой заявки
+// Результат отсортирован по приоритету цена-время: лучшая цена, при равной цене - более ранняя заявка
+func (r *SQLiteRepository) GetMatchingOrders(order *model.Order) ([]*model.Order, error) {
+	// Для покупки подходят продажи не дороже цены заявки, для продажи - покупки не дешевле
+	oppositeType := model.OrderTypeSell
+	priceCondition := "price <= ?"
+	priceOrder := "price ASC"
+	if order.Type == model.OrderTypeSell {
+		oppositeType = model.OrderTypeBuy
+		priceCondition = "price >= ?"
+		priceOrder = "price DESC"
+	}
+
+	matchingOrders, err := queryOrders(r.conn(), `
+		SELECT `+sqliteOrderColumns+`
+		FROM orders
+		WHERE type = ?
+		  AND cryptocurrency = ?
+		  AND fiat_currency = ?
+		  AND user_id != ?
+		  AND `+priceCondition+`
+		  AND status IN ('active', 'has_responses')
+		  AND is_active = 1
+		  AND remaining_amount >= ?
+		ORDER BY `+priceOrder+`, created_at ASC, id ASC
+		LIMIT 10`,
+		oppositeType, order.Cryptocurrency, order.FiatCurrency, order.UserID, order.Price, model.AmountEpsilon)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[INFO] Найдено подходящих заявок для Order ID=%d: %d", order.ID, len(matchingOrders))
+	return matchingOrders, nil
+}
+
+// =====================================================
+// УПРАВЛЕНИЕ СДЕЛКАМИ
+// =====================================================
+
+// sqliteDealColumns список колонок сделки в порядке, который ожидает scanSQLiteDeal
+const sqliteDealColumns = `id, response_id, order_id, author_id, counterparty_id,
+		       cryptocurrency, fiat_currency, amount, price, total_amount, payment_methods, order_type,
+		       status, created_at, expires_at, completed_at,
+		       author_confirmed, counter_confirmed, author_proof, counter_proof, notes, expiry_warning_sent,
+		       dispute_reason, dispute_evidence, dispute_opened_by, dispute_opened_at,
+		       dispute_winner_id, dispute_resolved_by, dispute_resolved_at, dispute_comment,
+		       cancel_reason, cancel_requested_by, cancel_requested_at, cancelled_by, cancelled_at,
+		       matched_order_id`
+
+// scanSQLiteDeal сканирует строку с колонками sqliteDealColumns в модель сделки
+func scanSQLiteDeal(row rowScanner) (*model.Deal, error) {
+	deal := &model.Deal{}
+	var paymentMethodsJSON []byte
+	var expiresAt *time.Time // Срок сделки (NULL для сделок без таймера)
+	err := row.Scan(
+		&deal.ID, &deal.ResponseID, &deal.OrderID, &deal.AuthorID, &deal.CounterpartyID,
+		&deal.Cryptocurrency, &deal.FiatCurrency, &deal.Amount, &deal.Price, &deal.TotalAmount,
+		&paymentMethodsJSON, &deal.OrderType, &deal.Status, &deal.CreatedAt, &expiresAt, &deal.CompletedAt,
+		&deal.AuthorConfirmed, &deal.CounterConfirmed, &deal.AuthorProof, &deal.CounterProof, &deal.Notes,
+		&deal.ExpiryWarningSent,
+		&deal.DisputeReason, &deal.DisputeEvidence, &deal.DisputeOpenedBy, &deal.DisputeOpenedAt,
+		&deal.DisputeWinnerID, &deal.DisputeResolvedBy, &deal.DisputeResolvedAt, &deal.DisputeComment,
+		&deal.CancelReason, &deal.CancelRequestedBy, &deal.CancelRequestedAt, &deal.CancelledBy, &deal.CancelledAt,
+		&deal.MatchedOrderID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(paymentMethodsJSON, &deal.PaymentMethods); err != nil {
+		return nil, fmt.Errorf("не удалось парсить способы оплаты сделки: %w", err)
+	}
+	if expiresAt != nil {
+		deal.ExpiresAt = *expiresAt
+	}
+	return deal, nil
+}
+
+// queryDeals выполняет запрос с колонками sqliteDealColumns и сканирует все сделки
+func queryDeals(q sqliteQuerier, query string, args ...interface{}) ([]*model.Deal, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось выполнить запрос сделок: %w", err)
+	}
+	defer rows.Close()
+
+	var deals []*model.Deal
+	for rows.Next() {
+		deal, err := scanSQLiteDeal(rows)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось сканировать сделку: %w", err)
+		}
+		deals = append(deals, deal)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по сделкам: %w", err)
+	}
+	return deals, nil
+}
+
+// CreateDeal создает новую сделку между пользователями
+func (r *SQLiteRepository) CreateDeal(deal *model.Deal) error {
+	log.Printf("[INFO] Создание сделки: Author ID=%d, Counterparty ID=%d, Amount=%.8f %s",
+		deal.AuthorID, deal.CounterpartyID, deal.Amount, deal.Cryptocurrency)
+
+	// Сделка создается уже в процессе, не требует дополнительного подтверждения создания
+	if deal.Status == "" {
+		deal.Status = model.DealStatusInProgress
+	}
+	deal.CreatedAt = time.Now()
+
+	paymentMethodsJSON, err := marshalStrings(deal.PaymentMethods)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.conn().Exec(`
+		INSERT INTO deals (
+			response_id, order_id, author_id, counterparty_id, cryptocurrency, fiat_currency,
+			amount, price, total_amount, payment_methods, order_type, status, created_at, expires_at,
+			completed_at, author_confirmed, counter_confirmed, author_proof, counter_proof, notes,
+			expiry_warning_sent, matched_order_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		deal.ResponseID, deal.OrderID, deal.AuthorID, deal.CounterpartyID, deal.Cryptocurrency, deal.FiatCurrency,
+		deal.Amount, deal.Price, deal.TotalAmount, paymentMethodsJSON, deal.OrderType, deal.Status,
+		sqliteTime(deal.CreatedAt), sqliteZeroTime(deal.ExpiresAt), sqliteNullTime(deal.CompletedAt),
+		deal.AuthorConfirmed, deal.CounterConfirmed, deal.AuthorProof, deal.CounterProof, deal.Notes,
+		deal.ExpiryWarningSent, deal.MatchedOrderID,
+	)
+	if err != nil {
+		return fmt.Errorf("не удалось создать сделку: %w", err)
+	}
+	if deal.ID, err = result.LastInsertId(); err != nil {
+		return fmt.Errorf("не удалось получить ID сделки: %w", err)
+	}
+
+	log.Printf("[INFO] Сделка успешно создана: ID=%d", deal.ID)
+	return nil
+}
+
+// GetDealsByUserID получает все сделки пользователя (как автора и контрагента), новые сначала
+func (r *SQLiteRepository) GetDealsByUserID(userID int64) ([]*model.Deal, error) {
+	deals, err := queryDeals(r.conn(), `
+		SELECT `+sqliteDealColumns+`
+		FROM deals
+		WHERE author_id = ? OR counterparty_id = ?
+		ORDER BY created_at DESC, id ASC`,
+		userID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[INFO] Найдено сделок для пользователя ID=%d: %d", userID, len(deals))
+	return deals, nil
+}
+
+// GetDealByID получает сделку по её ID
+func (r *SQLiteRepository) GetDealByID(dealID int64) (*model.Deal, error) {
+	deal, err := scanSQLiteDeal(r.conn().QueryRow(`SELECT `+sqliteDealColumns+` FROM deals WHERE id = ?`, dealID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("сделка с ID=%d не найдена", dealID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось найти сделку: %w", err)
+	}
+	return deal, nil
+}
+
+// transitionDeal применяет к сделке переход из таблицы model.DealTransitions в одной транзакции:
+// находит сделку, проверяет допустимость действия для ее текущего статуса,
+// вызывает apply для заполнения сопутствующих полей и сохраняет сделку с новым статусом.
+// Ошибка apply откатывает транзакцию
+func (r *SQLiteRepository) transitionDeal(dealID int64, action func(deal *model.Deal) model.DealAction, apply func(deal *model.Deal, transition model.DealTransition) error) error {
+	return r.inTx(func(q sqliteQuerier) error {
+		deal, err := scanSQLiteDeal(q.QueryRow(`SELECT `+sqliteDealColumns+` FROM deals WHERE id = ?`, dealID))
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("сделка ID=%d не найдена", dealID)
+		}
+		if err != nil {
+			return fmt.Errorf("не удалось получить сделку: %w", err)
+		}
+
+		transition, err := model.FindDealTransition(deal.Status, action(deal))
+		if err != nil {
+			return fmt.Errorf("сделка ID=%d: %w", dealID, err)
+		}
+		if err := apply(deal, transition); err != nil {
+			return err
+		}
+		deal.Status = transition.To
+
+		_, err = q.Exec(`
+			UPDATE deals SET
+				status = ?, completed_at = ?, author_confirmed = ?, counter_confirmed = ?,
+				author_proof = ?, counter_proof = ?,
+				dispute_reason = ?, dispute_evidence = ?, dispute_opened_by = ?, dispute_opened_at = ?,
+				dispute_winner_id = ?, dispute_resolved_by = ?, dispute_resolved_at = ?, dispute_comment = ?,
+				cancel_reason = ?, cancel_requested_by = ?, cancel_requested_at = ?, cancelled_by = ?, cancelled_at = ?
+			WHERE id = ?`,
+			deal.Status, sqliteNullTime(deal.CompletedAt), deal.AuthorConfirmed, deal.CounterConfirmed,
+			deal.AuthorProof, deal.CounterProof,
+			deal.DisputeReason, deal.DisputeEvidence, deal.DisputeOpenedBy, sqliteNullTime(deal.DisputeOpenedAt),
+			deal.DisputeWinnerID, deal.DisputeResolvedBy, sqliteNullTime(deal.DisputeResolvedAt), deal.DisputeComment,
+			deal.CancelReason, deal.CancelRequestedBy, sqliteNullTime(deal.CancelRequestedAt), deal.CancelledBy,
+			sqliteNullTime(deal.CancelledAt), dealID)
+		if err != nil {
+			return fmt.Errorf("не удалось сохранить сделку: %w", err)
+		}
+
+		log.Printf("[DEBUG] Сделка ID=%d: %s -> %s (%s)", dealID, transition.From, transition.To, transition.Action)
+		return nil
+	})
+}
+
+// ConfirmDealWithRole подтверждает сделку с указанием роли пользователя
+// Первое подтверждение переводит сделку в ожидание второй стороны, второе - завершает ее.
+// Возвращает примененный переход, чтобы сервис выполнил его побочные эффекты
+func (r *SQLiteRepository) ConfirmDealWithRole(dealID int64, userID int64, isAuthor bool, paymentProof string) (model.DealTransition, error) {
+	log.Printf("[INFO] Подтверждение сделки ID=%d пользователем ID=%d (isAuthor=%v)", dealID, userID, isAuthor)
+
+	var applied model.DealTransition
+	confirmAction := func(deal *model.Deal) model.DealAction { return deal.ConfirmAction(isAuthor) }
+
+	err := r.transitionDeal(dealID, confirmAction, func(deal *model.Deal, transition model.DealTransition) error {
+		applied = transition
+		if isAuthor {
+			deal.AuthorConfirmed = true
+			deal.AuthorProof = paymentProof
+		} else {
+			deal.CounterConfirmed = true
+			deal.CounterProof = paymentProof
+		}
+
+		if transition.To == model.DealStatusCompleted {
+			now := time.Now()
+			deal.CompletedAt = &now
+			log.Printf("[INFO] Сделка ID=%d завершена - оба участника подтвердили", dealID)
+		}
+		return nil
+	})
+	if err != nil {
+		return model.DealTransition{}, err
+	}
+
+	log.Printf("[INFO] Сделка ID=%d успешно подтверждена пользователем ID=%d", dealID, userID)
+	return applied, nil
+}
+
+// GetUnconfirmedDealsExpiringBefore получает активные сделки без единого подтверждения,
+// срок которых истекает не позже указанного момента
+func (r *SQLiteRepository) GetUnconfirmedDealsExpiringBefore(deadline time.Time) ([]*model.Deal, error) {
+	return queryDeals(r.conn(), `
+		SELECT `+sqliteDealColumns+`
+		FROM deals
+		WHERE status IN ('in_progress', 'waiting_confirmation')
+		  AND author_confirmed = 0 AND counter_confirmed = 0
+		  AND expires_at IS NOT NULL AND expires_at <= ?
+		ORDER BY expires_at ASC, id ASC`,
+		sqliteTime(deadline))
+}
+
+// MarkDealExpiryWarningSent отмечает, что предупреждение об истечении сделки отправлено
+func (r *SQLiteRepository) MarkDealExpiryWarningSent(dealID int64) error {
+	return execAffected(r.conn(), fmt.Errorf("сделка ID=%d не найдена", dealID),
+		`UPDATE deals SET expiry_warning_sent = 1 WHERE id = ?`, dealID)
+}
+
+// ExpireDeal переводит просроченную сделку в статус "expired"
+// Сделка истекает только если никто из участников ее не подтвердил и срок наступил к моменту now
+func (r *SQLiteRepository) ExpireDeal(dealID int64, now time.Time) error {
+	err := r.transitionDeal(dealID, dealAction(model.DealActionExpire), func(deal *model.Deal, _ model.DealTransition) error {
+		if !isDealUnconfirmed(deal) {
+			return fmt.Errorf("сделка ID=%d уже подтверждена или завершена", dealID)
+		}
+		if deal.ExpiresAt.IsZero() || deal.ExpiresAt.After(now) {
+			return fmt.Errorf("срок сделки ID=%d еще не истек", dealID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Сделка ID=%d истекла без подтверждения", dealID)
+	return nil
+}
+
+// RequestDealCancellation сохраняет запрос участника на отмену активной сделки
+func (r *SQLiteRepository) RequestDealCancellation(dealID, userID int64, reason string, requestedAt time.Time) error {
+	err := r.transitionDeal(dealID, dealAction(model.DealActionRequestCancel), func(deal *model.Deal, _ model.DealTransition) error {
+		deal.CancelRequestedBy = userID
+		deal.CancelRequestedAt = &requestedAt
+		deal.CancelReason = reason
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Пользователь ID=%d запросил отмену сделки ID=%d", userID, dealID)
+	return nil
+}
+
+// CancelDeal переводит активную сделку в статус "cancelled" и сохраняет причину отмены
+func (r *SQLiteRepository) CancelDeal(dealID, userID int64, reason string, cancelledAt time.Time) error {
+	err := r.transitionDeal(dealID, dealAction(model.DealActionCancel), func(deal *model.Deal, _ model.DealTransition) error {
+		deal.CancelReason = reason
+		deal.CancelledBy = userID
+		deal.CancelledAt = &cancelledAt
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Сделка ID=%d отменена пользователем ID=%d", dealID, userID)
+	return nil
+}
+
+// OpenDealDispute переводит активную сделку в статус "dispute" и сохраняет причину и доказательства
+func (r *SQLiteRepository) OpenDealDispute(dealID, userID int64, reason, evidence string, openedAt time.Time) error {
+	err := r.transitionDeal(dealID, dealAction(model.DealActionOpenDispute), func(deal *model.Deal, _ model.DealTransition) error {
+		deal.DisputeReason = reason
+		deal.DisputeEvidence = evidence
+		deal.DisputeOpenedBy = userID
+		deal.DisputeOpenedAt = &openedAt
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] По сделке ID=%d открыт спор пользователем ID=%d", dealID, userID)
+	return nil
+}
+
+// ResolveDealDispute закрывает спор по сделке с указанным итоговым статусом
+func (r *SQLiteRepository) ResolveDealDispute(dealID int64, resolution *model.DisputeResolution) error {
+	err := r.transitionDeal(dealID, dealAction(resolution.Action()), func(deal *model.Deal, transition model.DealTransition) error {
+		resolvedAt := resolution.ResolvedAt
+		deal.DisputeWinnerID = resolution.WinnerID
+		deal.DisputeResolvedBy = resolution.ResolvedBy
+		deal.DisputeResolvedAt = &resolvedAt
+		deal.DisputeComment = resolution.Comment
+		if transition.To == model.DealStatusCompleted {
+			deal.CompletedAt = &resolvedAt
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Спор по сделке ID=%d решен: status=%s, winner=%d", dealID, resolution.Status, resolution.WinnerID)
+	return nil
+}
+
+// GetDisputedDeals получает все сделки с открытым спором (старые споры первыми)
+func (r *SQLiteRepository) GetDisputedDeals() ([]*model.Deal, error) {
+	return queryDeals(r.conn(), `SELECT `+sqliteDealColumns+` FROM deals WHERE status = ? ORDER BY id`, model.DealStatusDispute)
+}
+
+// GetCompletedTrades возвращает завершенные сделки за период как рыночные сделки
+// в порядке завершения; пустая криптовалюта или фиат означают все пары
+func (r *SQLiteRepository) GetCompletedTrades(filter *model.TradeFilter) ([]*model.Trade, error) {
+	rows, err := r.conn().Query(`
+		SELECT id, cryptocurrency, fiat_currency, price, amount, completed_at
+		FROM deals
+		WHERE status = ?
+		  AND completed_at IS NOT NULL AND completed_at >= ? AND completed_at < ?
+		  AND (? = '' OR cryptocurrency = ?)
+		  AND (? = '' OR fiat_currency = ?)
+		ORDER BY completed_at ASC, id ASC`,
+		model.DealStatusCompleted, sqliteTime(filter.Since), sqliteTime(filter.Until),
+		filter.Cryptocurrency, filter.Cryptocurrency, filter.FiatCurrency, filter.FiatCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить завершенные сделки: %w", err)
+	}
+	defer rows.Close()
+
+	var trades []*model.Trade
+	for rows.Next() {
+		trade := &model.Trade{}
+		if err := rows.Scan(&trade.DealID, &trade.Cryptocurrency, &trade.FiatCurrency,
+			&trade.Price, &trade.Amount, &trade.CompletedAt); err != nil {
+			return nil, fmt.Errorf("не удалось сканировать сделку: %w", err)
+		}
+		trades = append(trades, trade)
+	}
+
+	return trades, rows.Err()
+}
+
+// AddDealEvent добавляет запись в хронологию сделки
+func (r *SQLiteRepository) AddDealEvent(event *model.DealEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	result, err := r.conn().Exec(`
+		INSERT INTO deal_events (deal_id, user_id, type, status, message, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		event.DealID, event.UserID, event.Type, event.Status, event.Message, sqliteTime(event.CreatedAt))
+	if err != nil {
+		return fmt.Errorf("не удалось добавить событие сделки: %w", err)
+	}
+	if event.ID, err = result.LastInsertId(); err != nil {
+		return fmt.Errorf("не удалось получить ID события сделки: %w", err)
+	}
+	return nil
+}
+
+// GetDealEvents получает хронологию сделки в порядке возникновения событий
+func (r *SQLiteRepository) GetDealEvents(dealID int64) ([]*model.DealEvent, error) {
+	rows, err := r.conn().Query(`
+		SELECT id, deal_id, user_id, type, status, message, created_at
+		FROM deal_events
+		WHERE deal_id = ?
+		ORDER BY id`, dealID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить хронологию сделки: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*model.DealEvent
+	for rows.Next() {
+		event := &model.DealEvent{}
+		if err := rows.Scan(&event.ID, &event.DealID, &event.UserID, &event.Type,
+			&event.Status, &event.Message, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("не удалось сканировать событие сделки: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// =====================================================
+// УПРАВЛЕНИЕ ОТЗЫВАМИ И РЕЙТИНГАМИ
+// =====================================================
+
+// CreateReview создает новый отзыв и пересчитывает рейтинг получателя в одной транзакции
+func (r *SQLiteRepository) CreateReview(review *model.Review) error {
+	log.Printf("[INFO] Создание отзыва от пользователя ID=%d к пользователю ID=%d",
+		review.FromUserID, review.ToUserID)
+
+	return r.inTx(func(q sqliteQuerier) error {
+		now := time.Now()
+
+		// Определяем тип отзыва по рейтингу
+		reviewType := model.ReviewTypeNegative
+		if review.Rating >= 4 {
+			reviewType = model.ReviewTypePositive
+		} else if review.Rating == 3 {
+			reviewType = model.ReviewTypeNeutral
+		}
+
+		result, err := q.Exec(`
+			INSERT INTO reviews (
+				deal_id, from_user_id, to_user_id, rating, type, comment, is_anonymous,
+				created_at, updated_at, is_visible, reported_count
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1, 0)`,
+			review.DealID, review.FromUserID, review.ToUserID, review.Rating, reviewType, review.Comment,
+			review.IsAnonymous, sqliteTime(now), sqliteTime(now))
+		if err != nil {
+			return fmt.Errorf("не удалось создать отзыв: %w", err)
+		}
+		if review.ID, err = result.LastInsertId(); err != nil {
+			return fmt.Errorf("не удалось получить ID отзыва: %w", err)
+		}
+
+		review.Type = reviewType
+		review.CreatedAt = now
+		review.UpdatedAt = now
+		review.IsVisible = true
+		review.ReportedCount = 0
+
+		if err := updateSQLiteUserRating(q, review.ToUserID); err != nil {
+			return err
+		}
+
+		log.Printf("[INFO] Отзыв успешно создан: ID=%d, рейтинг=%d", review.ID, review.Rating)
+		return nil
+	})
+}
+
+// updateSQLiteUserRating пересчитывает рейтинг пользователя по всем его видимым отзывам
+// Нет отзывов - нет строки рейтинга
+func updateSQLiteUserRating(q sqliteQuerier, userID int64) error {
+	rows, err := q.Query(`SELECT rating, type FROM reviews WHERE to_user_id = ? AND is_visible = 1`, userID)
+	if err != nil {
+		return fmt.Errorf("не удалось получить отзывы для рейтинга: %w", err)
+	}
+	defer rows.Close()
+
+	var totalRating int
+	rating := &model.Rating{UserID: userID, UpdatedAt: time.Now()}
+	for rows.Next() {
+		var stars int
+		var reviewType model.ReviewType
+		if err := rows.Scan(&stars, &reviewType); err != nil {
+			return fmt.Errorf("не удалось сканировать отзыв: %w", err)
+		}
+
+		totalRating += stars
+		rating.TotalReviews++
+
+		// Подсчитываем по типам
+		switch reviewType {
+		case model.ReviewTypePositive:
+			rating.PositiveReviews++
+		case model.ReviewTypeNeutral:
+			rating.NeutralReviews++
+		case model.ReviewTypeNegative:
+			rating.NegativeReviews++
+		}
+
+		// Подсчитываем по звездам
+		switch stars {
+		case 1:
+			rating.OneStar++
+		case 2:
+			rating.TwoStars++
+		case 3:
+			rating.ThreeStars++
+		case 4:
+			rating.FourStars++
+		case 5:
+			rating.FiveStars++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("ошибка при чтении отзывов: %w", err)
+	}
+	rows.Close()
+
+	if rating.TotalReviews == 0 {
+		_, err := q.Exec(`DELETE FROM ratings WHERE user_id = ?`, userID)
+		return err
+	}
+	rating.AverageRating = float32(totalRating) / float32(rating.TotalReviews)
+
+	_, err = q.Exec(`
+		INSERT OR REPLACE INTO ratings (
+			user_id, average_rating, total_reviews, positive_reviews, neutral_reviews, negative_reviews,
+			five_stars, four_stars, three_stars, two_stars, one_star, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		userID, rating.AverageRating, rating.TotalReviews, rating.PositiveReviews, rating.NeutralReviews,
+		rating.NegativeReviews, rating.FiveStars, rating.FourStars, rating.ThreeStars, rating.TwoStars,
+		rating.OneStar, sqliteTime(rating.UpdatedAt))
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить рейтинг пользователя: %w", err)
+	}
+	return nil
+}
+
+// GetReviewsByUserID получает видимые отзывы о пользователе (новые сначала) с пагинацией
+func (r *SQLiteRepository) GetReviewsByUserID(userID int64, limit, offset int) ([]*model.Review, error) {
+	log.Printf("[INFO] Получение отзывов для пользователя ID=%d (limit=%d, offset=%d)", userID, limit, offset)
+
+	rows, err := r.conn().Query(`
+		SELECT r.id, r.deal_id, r.from_user_id, r.to_user_id, r.rating, r.type, r.comment, r.is_anonymous,
+		       r.created_at, r.updated_at, r.is_visible, r.reported_count,
+		       u.first_name, u.last_name, u.username
+		FROM reviews r
+		LEFT JOIN users u ON u.id = r.from_user_id
+		WHERE r.to_user_id = ? AND r.is_visible = 1
+		ORDER BY r.created_at DESC, r.id ASC
+		LIMIT ? OFFSET ?`,
+		userID, max(limit, 0), max(offset, 0))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить отзывы: %w", err)
+	}
+	defer rows.Close()
+
+	reviews := []*model.Review{}
+	for rows.Next() {
+		review := &model.Review{}
+		var firstName, lastName, username sql.NullString
+		if err := rows.Scan(&review.ID, &review.DealID, &review.FromUserID, &review.ToUserID, &review.Rating,
+			&review.Type, &review.Comment, &review.IsAnonymous, &review.CreatedAt, &review.UpdatedAt,
+			&review.IsVisible, &review.ReportedCount, &firstName, &lastName, &username); err != nil {
+			return nil, fmt.Errorf("не удалось сканировать отзыв: %w", err)
+		}
+
+		// Заполняем информацию об авторе отзыва (если не анонимный)
+		switch {
+		case review.IsAnonymous:
+			review.FromUserName = "Аноним"
+		case !firstName.Valid:
+			review.FromUserName = fmt.Sprintf("Пользователь #%d", review.FromUserID)
+		default:
+			review.FromUserName = firstName.String
+			if lastName.String != "" {
+				review.FromUserName += " " + lastName.String
+			}
+			review.FromUserUsername = username.String
+		}
+
+		reviews = append(reviews, review)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при чтении отзывов: %w", err)
+	}
+
+	return reviews, nil
+}
+
+// GetUserRating получает агрегированный рейтинг пользователя (пустой, если отзывов нет)
+func (r *SQLiteRepository) GetUserRating(userID int64) (*model.Rating, error) {
+	rating := &model.Rating{}
+	err := r.conn().QueryRow(`
+		SELECT user_id, average_rating, total_reviews, positive_reviews, neutral_reviews, negative_reviews,
+		       five_stars, four_stars, three_stars, two_stars, one_star, updated_at
+		FROM ratings
+		WHERE user_id = ?`, userID).Scan(
+		&rating.UserID, &rating.AverageRating, &rating.TotalReviews, &rating.PositiveReviews,
+		&rating.NeutralReviews, &rating.NegativeReviews, &rating.FiveStars, &rating.FourStars,
+		&rating.ThreeStars, &rating.TwoStars, &rating.OneStar, &rating.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return &model.Rating{UserID: userID, UpdatedAt: time.Now()}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить рейтинг пользователя: %w", err)
+	}
+	return rating, nil
+}
+
+// CheckCanReview проверяет можно ли пользователю оставить отзыв о другом пользователе по сделке
+func (r *SQLiteRepository) CheckCanReview(dealID, fromUserID, toUserID int64) (bool, error) {
+	log.Printf("[INFO] Проверка возможности оставить отзыв: Deal ID=%d, From=%d, To=%d",
+		dealID, fromUserID, toUserID)
+
+	var status model.DealStatus
+	var authorID, counterpartyID int64
+	err := r.conn().QueryRow(`SELECT status, author_id, counterparty_id FROM deals WHERE id = ?`, dealID).
+		Scan(&status, &authorID, &counterpartyID)
+	if err == sql.ErrNoRows {
+		return false, fmt.Errorf("сделка не найдена")
+	}
+	if err != nil {
+		return false, fmt.Errorf("не удалось проверить сделку: %w", err)
+	}
+
+	// Пользователь участвовал в сделке и оставляет отзыв второму участнику
+	if authorID != fromUserID && counterpartyID != fromUserID {
+		return false, fmt.Errorf("вы не участвовали в данной сделке")
+	}
+	if authorID == fromUserID && counterpartyID != toUserID {
+		return false, fmt.Errorf("неверный получатель отзыва")
+	}
+	if counterpartyID == fromUserID && authorID != toUserID {
+		return false, fmt.Errorf("неверный получатель отзыва")
+	}
+
+	if status != model.DealStatusCompleted {
+		return false, fmt.Errorf("отзыв можно оставить только по завершенным сделкам")
+	}
+
+	var reviewed int
+	err = r.conn().QueryRow(`SELECT COUNT(*) FROM reviews WHERE deal_id = ? AND from_user_id = ? AND to_user_id = ?`,
+		dealID, fromUserID, toUserID).Scan(&reviewed)
+	if err != nil {
+		return false, fmt.Errorf("не удалось проверить существующие отзывы: %w", err)
+	}
+	if reviewed > 0 {
+		return false, fmt.Errorf("отзыв по данной сделке уже оставлен")
+	}
+
+	return true, nil
+}
+
+// ReportReview создает жалобу на отзыв и увеличивает счетчик жалоб отзыва
+func (r *SQLiteRepository) ReportReview(report *model.ReviewReport) error {
+	log.Printf("[INFO] Создание жалобы на отзыв ID=%d от пользователя ID=%d", report.ReviewID, report.UserID)
+
+	return r.inTx(func(q sqliteQuerier) error {
+		report.Status = "pending"
+		report.CreatedAt = time.Now()
+
+		result, err := q.Exec(`
+			INSERT INTO review_reports (review_id, user_id, reason, comment, status, created_at, resolved_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			report.ReviewID, report.UserID, report.Reason, report.Comment, report.Status,
+			sqliteTime(report.CreatedAt), sqliteNullTime(report.ResolvedAt))
+		if err != nil {
+			return fmt.Errorf("не удалось создать жалобу на отзыв: %w", err)
+		}
+		if report.ID, err = result.LastInsertId(); err != nil {
+			return fmt.Errorf("не удалось получить ID жалобы: %w", err)
+		}
+
+		if _, err := q.Exec(`UPDATE reviews SET reported_count = reported_count + 1 WHERE id = ?`, report.ReviewID); err != nil {
+			return fmt.Errorf("не удалось обновить счетчик жалоб: %w", err)
+		}
+
+		log.Printf("[INFO] Жалоба успешно создана: ID=%d", report.ID)
+		return nil
+	})
+}
+
+// GetUserReviewStats получает детальную статистику отзывов пользователя
+func (r *SQLiteRepository) GetUserReviewStats(userID int64) (*model.ReviewStats, error) {
+	rating, err := r.GetUserRating(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	recentReviews, err := r.GetReviewsByUserID(userID, 5, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var recentReviewsValues []model.Review
+	for _, review := range recentReviews {
+		recentReviewsValues = append(recentReviewsValues, *review)
+	}
+
+	positivePercent := float32(0.0)
+	if rating.TotalReviews > 0 {
+		positivePercent = float32(rating.PositiveReviews) / float32(rating.TotalReviews) * 100
+	}
+
+	return &model.ReviewStats{
+		UserID:          userID,
+		AverageRating:   rating.AverageRating,
+		TotalReviews:    rating.TotalReviews,
+		PositivePercent: positivePercent,
+		RecentReviews:   recentReviewsValues,
+		RatingDistribution: map[int]int{
+			1: rating.OneStar,
+			2: rating.TwoStars,
+			3: rating.ThreeStars,
+			4: rating.FourStars,
+			5: rating.FiveStars,
+		},
+	}, nil
+}
+
+// GetAssets получает записи реестра активов, переопределяющие встроенный реестр
+func (r *SQLiteRepository) GetAssets() ([]*model.Asset, error) {
+	rows, err := r.conn().Query(`
+		SELECT code, kind, display_name, decimals, min_order_amount, max_order_amount, sort_order, is_active
+		FROM assets
+		ORDER BY kind, sort_order, code`)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить реестр активов: %w", err)
+	}
+	defer rows.Close()
+
+	assets := []*model.Asset{}
+	for rows.Next() {
+		asset := &model.Asset{}
+		if err := rows.Scan(&asset.Code, &asset.Kind, &asset.DisplayName, &asset.Decimals,
+			&asset.MinOrderAmount, &asset.MaxOrderAmount, &asset.SortOrder, &asset.IsActive); err != nil {
+			return nil, fmt.Errorf("не удалось сканировать запись реестра активов: %w", err)
+		}
+		assets = append(assets, asset)
+	}
+
+	return assets, rows.Err()
+}
+
+// =====================================================
+// МЕТОДЫ ДЛЯ РАБОТЫ С ОТКЛИКАМИ
+// =====================================================
+
+// sqliteResponseColumns список колонок отклика в порядке, который ожидает scanSQLiteResponse
+const sqliteResponseColumns = `r.id, r.order_id, r.user_id, r.message, r.status, r.requested_amount,
+		       r.proposed_price, r.payment_method, r.created_at, r.updated_at, r.reviewed_at`
+
+// scanSQLiteResponse сканирует строку с колонками sqliteResponseColumns и дополнительными колонками extra
+func scanSQLiteResponse(row rowScanner, extra ...interface{}) (*model.Response, error) {
+	response := &model.Response{}
+	dest := []interface{}{
+		&response.ID, &response.OrderID, &response.UserID, &response.Message, &response.Status,
+		&response.RequestedAmount, &response.ProposedPrice, &response.PaymentMethod,
+		&response.CreatedAt, &response.UpdatedAt, &response.ReviewedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// getResponse получает отклик по ID через q
+func getResponse(q sqliteQuerier, responseID int64) (*model.Response, error) {
+	response, err := scanSQLiteResponse(q.QueryRow(`SELECT `+sqliteResponseColumns+` FROM responses r WHERE r.id = ?`, responseID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("отклик с ID=%d не найден", responseID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить отклик: %w", err)
+	}
+	return response, nil
+}
+
+// updateSQLiteOrderResponseCount изменяет счетчик откликов заявки на delta и переключает
+// статус заявки между active и has_responses в зависимости от того, остались ли отклики
+func updateSQLiteOrderResponseCount(q sqliteQuerier, orderID int64, delta int) error {
+	return execAffected(q, fmt.Errorf("заявка с ID=%d не найдена", orderID), `
+		UPDATE orders SET
+			response_count = MAX(response_count + ?, 0),
+			status = CASE
+				WHEN MAX(response_count + ?, 0) > 0 AND status = 'active' THEN 'has_responses'
+				WHEN MAX(response_count + ?, 0) = 0 AND status = 'has_responses' THEN 'active'
+				ELSE status
+			END,
+			updated_at = ?
+		WHERE id = ?`,
+		delta, delta, delta, sqliteTime(time.Now()), orderID)
+}
+
+// CreateResponse создает новый отклик на заявку и увеличивает счетчик откликов заявки
+func (r *SQLiteRepository) CreateResponse(response *model.Response) error {
+	log.Printf("[INFO] Создание нового отклика на заявку ID=%d от пользователя ID=%d",
+		response.OrderID, response.UserID)
+
+	return r.inTx(func(q sqliteQuerier) error {
+		var exists int
+		err := q.QueryRow(`SELECT COUNT(*) FROM responses WHERE order_id = ? AND user_id = ?`,
+			response.OrderID, response.UserID).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("не удалось проверить отклики: %w", err)
+		}
+		if exists > 0 {
+			return fmt.Errorf("вы уже откликались на эту заявку")
+		}
+
+		now := time.Now()
+		result, err := q.Exec(`
+			INSERT INTO responses (
+				order_id, user_id, message, status, requested_amount, proposed_price, payment_method,
+				created_at, updated_at, reviewed_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NULL)`,
+			response.OrderID, response.UserID, response.Message, model.ResponseStatusWaiting,
+			response.RequestedAmount, response.ProposedPrice, response.PaymentMethod, sqliteTime(now), sqliteTime(now))
+		if err != nil {
+			return fmt.Errorf("не удалось создать отклик: %w", err)
+		}
+		if response.ID, err = result.LastInsertId(); err != nil {
+			return fmt.Errorf("не удалось получить ID отклика: %w", err)
+		}
+
+		response.Status = model.ResponseStatusWaiting
+		response.CreatedAt = now
+		response.UpdatedAt = now
+		response.ReviewedAt = nil
+
+		if err := updateSQLiteOrderResponseCount(q, response.OrderID, 1); err != nil {
+			log.Printf("[WARN] Не удалось обновить счетчик откликов в заявке: %v", err)
+		}
+
+		log.Printf("[INFO] Отклик успешно создан: ID=%d", response.ID)
+		return nil
+	})
+}
+
+// GetResponseByID получает отклик по ID
+func (r *SQLiteRepository) GetResponseByID(responseID int64) (*model.Response, error) {
+	return getResponse(r.conn(), responseID)
+}
+
+// GetResponsesByFilter получает отклики с фильтрацией, сортировкой и пагинацией
+// Без SortBy отклики идут в порядке создания (по ID); при равных значениях сортировки - тоже по ID
+func (r *SQLiteRepository) GetResponsesByFilter(filter *model.ResponseFilter) ([]*model.Response, error) {
+	log.Printf("[INFO] Получение откликов с фильтром: %+v", filter)
+
+	var where []string
+	var args []interface{}
+	add := func(condition string, value interface{}) {
+		where = append(where, condition)
+		args = append(args, value)
+	}
+
+	if filter.OrderID != nil {
+		add("r.order_id = ?", *filter.OrderID)
+	}
+	if filter.UserID != nil {
+		add("r.user_id = ?", *filter.UserID)
+	}
+	if filter.AuthorID != nil {
+		add("o.user_id = ?", *filter.AuthorID)
+	}
+	if filter.Status != nil {
+		add("r.status = ?", *filter.Status)
+	}
+	if filter.CreatedAfter != nil {
+		add("r.created_at >= ?", sqliteTime(*filter.CreatedAfter))
+	}
+	if filter.CreatedBefore != nil {
+		add("r.created_at <= ?", sqliteTime(*filter.CreatedBefore))
+	}
+
+	// Данные откликнувшегося, автора и заявки для фронтенда
+	query := `
+		SELECT ` + sqliteResponseColumns + `,
+		       COALESCE(u.first_name || CASE WHEN u.last_name != '' THEN ' ' || u.last_name ELSE '' END, ''),
+		       COALESCE(u.username, ''),
+		       COALESCE(o.type, ''), COALESCE(o.cryptocurrency, ''), COALESCE(o.fiat_currency, ''),
+		       COALESCE(o.amount, 0), COALESCE(o.price, 0), COALESCE(o.total_amount, 0),
+		       COALESCE(author.first_name || CASE WHEN author.last_name != '' THEN ' ' || author.last_name ELSE '' END, ''),
+		       COALESCE(author.username, '')
+		FROM responses r
+		LEFT JOIN users u ON u.id = r.user_id
+		LEFT JOIN orders o ON o.id = r.order_id
+		LEFT JOIN users author ON author.id = o.user_id`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	sortOrder := "ASC"
+	if filter.SortOrder == "desc" {
+		sortOrder = "DESC"
+	}
+	switch filter.SortBy {
+	case "":
+		query += " ORDER BY r.id ASC"
+	case "created_at", "updated_at":
+		query += fmt.Sprintf(" ORDER BY r.%s %s, r.id ASC", filter.SortBy, sortOrder)
+	default:
+		query += " ORDER BY r.id " + sortOrder
+	}
+
+	// LIMIT -1 в SQLite означает "без ограничения"
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	query += " LIMIT ? OFFSET ?"
+	args = append(args, limit, max(filter.Offset, 0))
+
+	rows, err := r.conn().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось выполнить запрос откликов: %w", err)
+	}
+	defer rows.Close()
+
+	var responses []*model.Response
+	for rows.Next() {
+		var details model.Response
+		response, err := scanSQLiteResponse(rows,
+			&details.UserName, &details.Username,
+			&details.OrderType, &details.Cryptocurrency, &details.FiatCurrency,
+			&details.Amount, &details.Price, &details.TotalAmount,
+			&details.AuthorName, &details.AuthorUsername)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать отклик: %w", err)
+		}
+
+		response.UserName, response.Username = details.UserName, details.Username
+		response.OrderType, response.Cryptocurrency, response.FiatCurrency = details.OrderType, details.Cryptocurrency, details.FiatCurrency
+		response.Amount, response.Price, response.TotalAmount = details.Amount, details.Price, details.TotalAmount
+		response.AuthorName, response.AuthorUsername = details.AuthorName, details.AuthorUsername
+		responses = append(responses, response)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при чтении откликов: %w", err)
+	}
+
+	log.Printf("[INFO] Возвращено откликов: %d", len(responses))
+	return responses, nil
+}
+
+// UpdateResponseStatus обновляет статус открытого отклика (waiting или countered);
+// любой статус кроме waiting отмечает время рассмотрения. Условие на текущий статус в UPDATE
+// не дает параллельным запросам рассмотреть один отклик дважды
+func (r *SQLiteRepository) UpdateResponseStatus(responseID int64, status model.ResponseStatus) error {
+	log.Printf("[INFO] Обновление статуса отклика ID=%d на %s", responseID, status)
+
+	now := sqliteTime(time.Now())
+	return execAffected(r.conn(), fmt.Errorf("отклик с ID=%d не найден или уже был рассмотрен", responseID), `
+		UPDATE responses SET
+			status = ?,
+			updated_at = ?,
+			reviewed_at = CASE WHEN ? != 'waiting' THEN ? ELSE reviewed_at END
+		WHERE id = ? AND status IN (?, ?)`,
+		status, now, status, now, responseID, model.ResponseStatusWaiting, model.ResponseStatusCountered)
+}
+
+// ReopenResponse возвращает отклоненный или отозванный отклик на рассмотрение с новым сообщением и условиями
+func (r *SQLiteRepository) ReopenResponse(response *model.Response) error {
+	return r.inTx(func(q sqliteQuerier) error {
+		stored, err := getResponse(q, response.ID)
+		if err != nil {
+			return err
+		}
+
+		_, err = q.Exec(`
+			UPDATE responses SET
+				message = ?, proposed_price = ?, requested_amount = ?, payment_method = ?,
+				status = ?, reviewed_at = NULL, updated_at = ?
+			WHERE id = ?`,
+			response.Message, response.ProposedPrice, response.RequestedAmount, response.PaymentMethod,
+			model.ResponseStatusWaiting, sqliteTime(time.Now()), response.ID)
+		if err != nil {
+			return fmt.Errorf("не удалось обновить отклик: %w", err)
+		}
+
+		// Отозванный отклик снова учитывается в счетчике откликов заявки
+		if stored.Status == model.ResponseStatusWithdrawn {
+			if err := updateSQLiteOrderResponseCount(q, stored.OrderID, 1); err != nil {
+				log.Printf("[WARN] Не удалось обновить счетчик откликов в заявке: %v", err)
+			}
+		}
+
+		log.Printf("[INFO] Отклик ID=%d снова ожидает рассмотрения (объем %.8f)", response.ID, response.RequestedAmount)
+		return nil
+	})
+}
+
+// UpdateResponseTerms сохраняет новые условия отклика после встречного предложения
+func (r *SQLiteRepository) UpdateResponseTerms(responseID int64, status model.ResponseStatus, proposedPrice, requestedAmount float64) error {
+	err := execAffected(r.conn(), fmt.Errorf("отклик с ID=%d не найден", responseID), `
+		UPDATE responses SET status = ?, proposed_price = ?, requested_amount = ?, updated_at = ?
+		WHERE id = ?`,
+		status, proposedPrice, requestedAmount, sqliteTime(time.Now()), responseID)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Условия отклика ID=%d обновлены: %.8f по %.2f, статус %s",
+		responseID, requestedAmount, proposedPrice, status)
+	return nil
+}
+
+// WithdrawResponse отзывает отклик, по которому еще идут переговоры.
+// Уменьшает счетчик откликов заявки и возвращает ее из has_responses в active,
+// если открытых откликов на нее не осталось
+func (r *SQLiteRepository) WithdrawResponse(responseID int64) error {
+	err := r.inTx(func(q sqliteQuerier) error {
+		response, err := getResponse(q, responseID)
+		if err != nil {
+			return err
+		}
+		if !response.IsOpen() {
+			return fmt.Errorf("отклик уже был рассмотрен")
+		}
+
+		now := sqliteTime(time.Now())
+		if _, err := q.Exec(`UPDATE responses SET status = ?, updated_at = ? WHERE id = ?`,
+			model.ResponseStatusWithdrawn, now, responseID); err != nil {
+			return fmt.Errorf("не удалось отозвать отклик: %w", err)
+		}
+
+		if err := updateSQLiteOrderResponseCount(q, response.OrderID, -1); err != nil {
+			return fmt.Errorf("не удалось обновить счетчик откликов в заявке: %w", err)
+		}
+
+		_, err = q.Exec(`
+			UPDATE orders SET status = 'active', updated_at = ?
+			WHERE id = ? AND status = 'has_responses'
+			  AND NOT EXISTS (
+			      SELECT 1 FROM responses WHERE order_id = ? AND status IN ('waiting', 'countered')
+			  )`,
+			now, response.OrderID, response.OrderID)
+		if err != nil {
+			return fmt.Errorf("не удалось вернуть заявку в активные: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Отклик ID=%d отозван", responseID)
+	return nil
+}
+
+// AddResponseOffer добавляет предложение в историю переговоров по отклику
+func (r *SQLiteRepository) AddResponseOffer(offer *model.ResponseOffer) error {
+	if offer.CreatedAt.IsZero() {
+		offer.CreatedAt = time.Now()
+	}
+
+	result, err := r.conn().Exec(`
+		INSERT INTO response_offers (response_id, user_id, price, amount, message, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		offer.ResponseID, offer.UserID, offer.Price, offer.Amount, offer.Message, sqliteTime(offer.CreatedAt))
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить предложение по отклику: %w", err)
+	}
+	if offer.ID, err = result.LastInsertId(); err != nil {
+		return fmt.Errorf("не удалось получить ID предложения: %w", err)
+	}
+	return nil
+}
+
+// GetResponseOffers получает историю предложений по отклику в порядке их поступления
+func (r *SQLiteRepository) GetResponseOffers(responseID int64) ([]*model.ResponseOffer, error) {
+	rows, err := r.conn().Query(`
+		SELECT id, response_id, user_id, price, amount, message, created_at
+		FROM response_offers
+		WHERE response_id = ?
+		ORDER BY id`, responseID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить историю предложений: %w", err)
+	}
+	defer rows.Close()
+
+	var offers []*model.ResponseOffer
+	for rows.Next() {
+		offer := &model.ResponseOffer{}
+		if err := rows.Scan(&offer.ID, &offer.ResponseID, &offer.UserID, &offer.Price,
+			&offer.Amount, &offer.Message, &offer.CreatedAt); err != nil {
+			return nil, fmt.Errorf("не удалось сканировать предложение: %w", err)
+		}
+		offers = append(offers, offer)
+	}
+
+	return offers, rows.Err()
+}
+
+// GetResponsesForOrder получает все отклики для заявки (новые сначала)
+func (r *SQLiteRepository) GetResponsesForOrder(orderID int64) ([]*model.Response, error) {
+	return r.GetResponsesByFilter(&model.ResponseFilter{OrderID: &orderID, SortBy: "created_at", SortOrder: "desc"})
+}
+
+// GetResponsesFromUser получает все отклики от пользователя (новые сначала)
+func (r *SQLiteRepository) GetResponsesFromUser(userID int64) ([]*model.Response, error) {
+	return r.GetResponsesByFilter(&model.ResponseFilter{UserID: &userID, SortBy: "created_at", SortOrder: "desc"})
+}
+
+// GetResponsesForAuthor получает все отклики на заявки автора (новые сначала)
+func (r *SQLiteRepository) GetResponsesForAuthor(authorID int64) ([]*model.Response, error) {
+	return r.GetResponsesByFilter(&model.ResponseFilter{AuthorID: &authorID, SortBy: "created_at", SortOrder: "desc"})
+}
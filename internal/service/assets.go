@@ -0,0 +1,89 @@
+package service
+
+import (
+	"fmt"
+	"log"
+
+	"p2pTG-crypto-exchange/internal/model"
+)
+
+// =====================================================
+// РЕЕСТР АКТИВОВ, ВАЛЮТ И СПОСОБОВ ОПЛАТЫ
+// =====================================================
+
+// LoadAssetRegistry собирает реестр из встроенных записей, записей базы данных
+// и списков SupportedCryptocurrencies/SupportedFiatCurrencies/SupportedPaymentMethods бизнес-настроек.
+// Вызывается после SetBusinessConfig
+func (s *Service) LoadAssetRegistry() error {
+	overrides, err := s.repo.GetAssets()
+	if err != nil {
+		return fmt.Errorf("не удалось загрузить реестр активов: %w", err)
+	}
+
+	registry, err := model.NewAssetRegistry(model.DefaultAssets(), overrides, model.AssetAllowlist{
+		Cryptocurrencies: s.business.SupportedCryptocurrencies,
+		FiatCurrencies:   s.business.SupportedFiatCurrencies,
+		PaymentMethods:   s.business.SupportedPaymentMethods,
+	})
+	if err != nil {
+		return fmt.Errorf("неверный реестр активов: %w", err)
+	}
+
+	s.assets = registry
+	s.logf("[INFO] Реестр активов загружен: криптовалют %d, фиатных валют %d, способов оплаты %d (из базы %d)",
+		len(registry.Cryptocurrencies), len(registry.FiatCurrencies), len(registry.PaymentMethods), len(overrides))
+	return nil
+}
+
+// GetAssetRegistry возвращает реестр активов; до LoadAssetRegistry используется встроенный реестр
+func (s *Service) GetAssetRegistry() *model.AssetRegistry {
+	return s.assets
+}
+
+// defaultAssetRegistry собирает реестр только из встроенных записей
+func defaultAssetRegistry() *model.AssetRegistry {
+	registry, err := model.NewAssetRegistry(model.DefaultAssets(), nil, model.AssetAllowlist{})
+	if err != nil {
+		log.Printf("[ERROR] Неверный встроенный реестр активов: %v", err)
+		return &model.AssetRegistry{}
+	}
+	return registry
+}
+
+// validateOrderAssets проверяет криптовалюту, фиатную валюту и способы оплаты заявки по реестру,
+// а также лимиты и точность количества, суммы и фиксированной цены
+func (s *Service) validateOrderAssets(order *model.Order) error {
+	registry := s.GetAssetRegistry()
+
+	crypto := registry.Lookup(model.AssetKindCrypto, order.Cryptocurrency)
+	if crypto == nil {
+		return fmt.Errorf("неподдерживаемая криптовалюта: %s", order.Cryptocurrency)
+	}
+	fiat := registry.Lookup(model.AssetKindFiat, order.FiatCurrency)
+	if fiat == nil {
+		return fmt.Errorf("неподдерживаемая фиатная валюта: %s", order.FiatCurrency)
+	}
+
+	if err := crypto.CheckOrderSize(order.Amount, "количество"); err != nil {
+		return err
+	}
+	if err := crypto.CheckPrecision(order.Amount, "количество"); err != nil {
+		return err
+	}
+	// Плавающая цена считается сервером, точность проверяется только у цены автора
+	if !order.IsFloating() {
+		if err := fiat.CheckPrecision(order.Price, "цена"); err != nil {
+			return err
+		}
+	}
+	if err := fiat.CheckOrderSize(order.Amount*order.Price, "сумма"); err != nil {
+		return err
+	}
+
+	for _, method := range order.PaymentMethods {
+		if registry.Lookup(model.AssetKindPaymentMethod, method) == nil {
+			return fmt.Errorf("неподдерживаемый способ оплаты: %s", method)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"time"
+
+	"p2pTG-crypto-exchange/internal/model"
+)
+
+// tryAutoAcceptResponse принимает новый отклик без участия автора, если он подходит под правила автопринятия заявки.
+// Автоматически принимается только отклик по цене заявки. Если отклик не подходит или сделку создать не удалось,
+// возвращает nil - отклик остается ожидать решения автора
+func (s *Service) tryAutoAcceptResponse(order *model.Order, response *model.Response) *model.Deal {
+	rules := order.AutoAccept
+	if rules == nil || !rules.Enabled {
+		return nil
+	}
+
+	if response.ProposedPrice > 0 && response.ProposedPrice != order.Price {
+		s.logf("[INFO] Отклик ID=%d не принят автоматически: предложена своя цена", response.ID)
+		return nil
+	}
+
+	user, err := s.repo.GetUserByID(response.UserID)
+	if err != nil {
+		s.logf("[WARN] Не удалось получить откликнувшегося ID=%d для автопринятия: %v", response.UserID, err)
+		return nil
+	}
+	if err := rules.Check(user, response.PaymentMethod, time.Now()); err != nil {
+		s.logf("[INFO] Отклик ID=%d не принят автоматически: %v", response.ID, err)
+		return nil
+	}
+
+	deal, err := s.createDealFromResponse(order, response, nil)
+	if err != nil {
+		s.logf("[WARN] Не удалось автоматически принять отклик ID=%d: %v", response.ID, err)
+		return nil
+	}
+
+	s.goNotify(func() { s.sendResponseAcceptedNotification(order, response, deal) })
+	s.goNotify(func() { s.sendDealCreatedNotifications(deal) })
+
+	s.logf("[INFO] Отклик ID=%d принят автоматически, создана сделка ID=%d", response.ID, deal.ID)
+	return deal
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"p2pTG-crypto-exchange/internal/model"
+)
+
+// =====================================================
+// АВТОМАТИЧЕСКОЕ СОПОСТАВЛЕНИЕ ЗАЯВОК
+// =====================================================
+
+// tryAutoMatchOrder сопоставляет новую заявку со встречными заявками на рынке (BusinessConfig.EnableAutoMatch).
+// Встречные заявки перебираются по приоритету цена-время: сначала лучшая цена, при равной цене - более ранняя.
+// Каждая пересекающаяся пара становится сделкой тем же путем, что и принятый отклик: автор встречной заявки -
+// автор сделки, автор новой заявки - контрагент, цена - цена встречной заявки, которая раньше стояла на рынке.
+// Возвращает новую заявку с учетом занятого сделками объема
+func (s *Service) tryAutoMatchOrder(order *model.Order) *model.Order {
+	s.logf("[INFO] Попытка автоматического сопоставления заявки ID=%d", order.ID)
+
+	candidates, err := s.repo.GetMatchingOrders(order)
+	if err != nil {
+		s.logf("[ERROR] Не удалось найти подходящие заявки для Order ID=%d: %v", order.ID, err)
+		return order
+	}
+	s.sortCandidatesByFloatingPrice(order, candidates)
+
+	matched := 0
+	for _, candidate := range candidates {
+		if order.IsFilled() {
+			break
+		}
+
+		// Защита от сделки пользователя с самим собой
+		if candidate.UserID == order.UserID {
+			continue
+		}
+		if !s.isPriceCompatible(order, candidate) {
+			continue
+		}
+		if !s.hasCommonPaymentMethods(order, candidate) {
+			s.logf("[DEBUG] У заявок ID=%d и ID=%d нет общих способов оплаты", order.ID, candidate.ID)
+			continue
+		}
+
+		amount, err := matchAmount(order, candidate)
+		if err != nil {
+			s.logf("[DEBUG] Заявки ID=%d и ID=%d не сопоставлены: %v", order.ID, candidate.ID, err)
+			continue
+		}
+
+		deal, err := s.matchOrders(order, candidate, amount)
+		if err != nil {
+			s.logf("[WARN] Не удалось сопоставить заявки ID=%d и ID=%d: %v", order.ID, candidate.ID, err)
+			continue
+		}
+		matched++
+
+		// Обновляем остаток новой заявки после сделки
+		if updated, err := s.repo.GetOrderByID(order.ID); err == nil {
+			order = updated
+		} else {
+			order.RemainingAmount = model.RoundAmount(order.RemainingAmount - deal.Amount)
+		}
+	}
+
+	if matched == 0 {
+		s.logf("[INFO] Подходящие заявки для Order ID=%d не найдены", order.ID)
+	}
+	return order
+}
+
+// sortCandidatesByFloatingPrice пересчитывает цены плавающих встречных заявок по текущему курсу
+// и восстанавливает приоритет цена-время: при равной цене порядок хранилища (по времени) сохраняется
+func (s *Service) sortCandidatesByFloatingPrice(order *model.Order, candidates []*model.Order) {
+	s.applyFloatingPrices(candidates)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if order.Type == model.OrderTypeBuy {
+			return candidates[i].Price < candidates[j].Price // Для покупки лучшая продажа - самая дешевая
+		}
+		return candidates[i].Price > candidates[j].Price // Для продажи лучшая покупка - самая дорогая
+	})
+}
+
+// matchOrders создает сделку между новой заявкой и встречной заявкой с рынка.
+// Сделка оформляется как отклик автора новой заявки на встречную заявку, принятый автоматически
+func (s *Service) matchOrders(order, candidate *model.Order, amount float64) (*model.Deal, error) {
+	response := &model.Response{
+		OrderID:         candidate.ID,
+		UserID:          order.UserID,
+		Message:         fmt.Sprintf("Автосопоставление с заявкой #%d", order.ID),
+		RequestedAmount: amount,
+		Status:          model.ResponseStatusWaiting,
+	}
+	if err := s.repo.CreateResponse(response); err != nil {
+		return nil, fmt.Errorf("не удалось создать отклик: %w", err)
+	}
+
+	deal, err := s.createDealFromResponse(candidate, response, order)
+	if err != nil {
+		// Отклик без сделки не должен ждать решения автора
+		if withdrawErr := s.repo.WithdrawResponse(response.ID); withdrawErr != nil {
+			s.logf("[WARN] Не удалось отозвать отклик автосопоставления ID=%d: %v", response.ID, withdrawErr)
+		}
+		return nil, err
+	}
+
+	s.goNotify(func() { s.sendDealCreatedNotifications(deal) })
+
+	s.logf("[INFO] Заявки ID=%d и ID=%d сопоставлены: сделка ID=%d на %.8f %s по %.2f %s",
+		order.ID, candidate.ID, deal.ID, deal.Amount, deal.Cryptocurrency, deal.Price, deal.FiatCurrency)
+	return deal, nil
+}
+
+// matchAmount определяет объем сделки между заявками по цене встречной заявки:
+// наибольший объем, который помещается в остатки и лимиты обеих заявок
+func matchAmount(order, candidate *model.Order) (float64, error) {
+	price := candidate.Price
+	amount := math.Min(order.RemainingAmount, candidate.RemainingAmount)
+
+	// Сумма сделки не должна превышать максимальную сумму ни одной из заявок
+	for _, o := range []*model.Order{order, candidate} {
+		if o.MaxAmount > 0 && amount*price > o.MaxAmount+model.AmountEpsilon {
+			amount = math.Floor(o.MaxAmount/price*1e8) / 1e8
+		}
+	}
+
+	for _, o := range []*model.Order{order, candidate} {
+		if _, err := resolveFillAmount(o, amount, price); err != nil {
+			return 0, err
+		}
+	}
+	return model.RoundAmount(amount), nil
+}
+
+// isPriceCompatible проверяет совместимость цен двух заявок
+func (s *Service) isPriceCompatible(order1, order2 *model.Order) bool {
+	// Для покупки: цена покупателя должна быть >= цены продавца
+	// Для продажи: цена продавца должна быть <= цены покупателя
+
+	if order1.Type == model.OrderTypeBuy && order2.Type == model.OrderTypeSell {
+		return order1.Price >= order2.Price // Покупатель готов платить >= чем просит продавец
+	}
+
+	if order1.Type == model.OrderTypeSell && order2.Type == model.OrderTypeBuy {
+		return order1.Price <= order2.Price // Продавец готов продать <= чем готов платить покупатель
+	}
+
+	return false
+}
+
+// hasCommonPaymentMethods проверяет есть ли общие способы оплаты у двух заявок
+func (s *Service) hasCommonPaymentMethods(order1, order2 *model.Order) bool {
+	return len(commonPaymentMethods(order1, order2)) > 0
+}
+
+// commonPaymentMethods возвращает общие способы оплаты двух заявок в порядке первой заявки
+func commonPaymentMethods(order1, order2 *model.Order) []string {
+	var common []string
+	for _, method := range order1.PaymentMethods {
+		if order2.HasPaymentMethod(method) {
+			common = append(common, method)
+		}
+	}
+	return common
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"p2pTG-crypto-exchange/internal/model"
+	"p2pTG-crypto-exchange/internal/repository"
+)
+
+// maxCancelReasonLength максимальная длина причины отмены сделки
+const maxCancelReasonLength = 500
+
+// CancelDeal отменяет сделку или запрашивает ее отмену у второй стороны.
+// Покупатель криптовалюты (платит первым) может отменить сделку сам, пока не отметил оплату.
+// В остальных случаях нужна отмена по взаимному согласию: первый вызов сохраняет запрос,
+// вызов второй стороной отменяет сделку.
+func (s *Service) CancelDeal(dealID, userID int64, req *model.CancelDealRequest) (*model.Deal, error) {
+	s.logf("[INFO] Отмена сделки ID=%d пользователем ID=%d", dealID, userID)
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, fmt.Errorf("укажите причину отмены")
+	}
+	if len([]rune(reason)) > maxCancelReasonLength {
+		return nil, fmt.Errorf("причина отмены не должна превышать %d символов", maxCancelReasonLength)
+	}
+
+	// Проверяем доступ к сделке
+	deal, err := s.GetDeal(dealID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	requestTransition, err := model.AuthorizeDealTransition(deal.Status, model.DealActionRequestCancel, model.DealActorParticipant)
+	if err != nil {
+		return nil, err
+	}
+
+	otherID := otherDealParticipant(deal, userID)
+
+	now := time.Now()
+
+	// Вторая сторона уже просила отменить сделку - это взаимное согласие
+	if deal.CancelRequestedBy == otherID {
+		return s.cancelDeal(deal, userID, deal.CancelReason, now)
+	}
+
+	// Покупатель еще не отметил оплату - может отменить сделку без согласия продавца
+	if userID == dealCryptoBuyerID(deal) && !dealPaymentSent(deal) {
+		return s.cancelDeal(deal, userID, reason, now)
+	}
+
+	if deal.CancelRequestedBy == userID {
+		return nil, fmt.Errorf("запрос на отмену уже отправлен, ожидается согласие второй стороны")
+	}
+
+	deal.CancelRequestedBy = userID
+	deal.CancelRequestedAt = &now
+	deal.CancelReason = reason
+
+	err = s.transitionDeal(deal, requestTransition, userID, func(repo repository.RepositoryInterface) error {
+		return repo.RequestDealCancellation(deal.ID, userID, reason, now)
+	})
+	if err != nil {
+		s.logf("[ERROR] Не удалось сохранить запрос отмены сделки ID=%d: %v", deal.ID, err)
+		return nil, fmt.Errorf("не удалось запросить отмену сделки: %w", err)
+	}
+
+	s.addDealEvent(deal.ID, userID, model.DealEventCancelRequested, requestTransition.To, "Запрошена отмена: "+reason)
+
+	s.logf("[INFO] По сделке ID=%d запрошена отмена, ожидается согласие пользователя ID=%d", deal.ID, otherID)
+	return deal, nil
+}
+
+// cancelDeal отменяет сделку и выполняет побочные эффекты перехода (заявка, статистика, уведомления)
+func (s *Service) cancelDeal(deal *model.Deal, userID int64, reason string, now time.Time) (*model.Deal, error) {
+	transition, err := model.AuthorizeDealTransition(deal.Status, model.DealActionCancel, model.DealActorParticipant)
+	if err != nil {
+		return nil, err
+	}
+
+	mutual := deal.CancelRequestedBy != 0 && deal.CancelRequestedBy != userID
+
+	deal.CancelReason = reason
+	deal.CancelledBy = userID
+	deal.CancelledAt = &now
+
+	// Возврат объема в заявку и статистика сохраняются вместе с отменой
+	err = s.transitionDeal(deal, transition, userID, func(repo repository.RepositoryInterface) error {
+		return repo.CancelDeal(deal.ID, userID, reason, now)
+	})
+	if err != nil {
+		s.logf("[ERROR] Не удалось отменить сделку ID=%d: %v", deal.ID, err)
+		return nil, fmt.Errorf("не удалось отменить сделку: %w", err)
+	}
+
+	message := "Сделка отменена: " + reason
+	if mutual {
+		message = "Сделка отменена по взаимному согласию: " + reason
+	}
+	s.addDealEvent(deal.ID, userID, model.DealEventCancelled, transition.To, message)
+
+	s.logf("[INFO] Сделка ID=%d отменена пользователем ID=%d (взаимно: %v)", deal.ID, userID, mutual)
+	return deal, nil
+}
+
+// dealPaymentSent проверяет, отметил ли покупатель криптовалюты оплату
+func dealPaymentSent(deal *model.Deal) bool {
+	if dealCryptoBuyerID(deal) == deal.AuthorID {
+		return deal.AuthorConfirmed
+	}
+	return deal.CounterConfirmed
+}
+
+// sendDealCancelRequestedNotification уведомляет вторую сторону о запросе отмены сделки
+func (s *Service) sendDealCancelRequestedNotification(deal *model.Deal, recipientID int64) {
+	requestedBy, err := s.repo.GetUserByID(deal.CancelRequestedBy)
+	if err != nil {
+		s.logf("[ERROR] Не удалось найти пользователя ID=%d, запросившего отмену: %v", deal.CancelRequestedBy, err)
+		return
+	}
+
+	recipient, err := s.repo.GetUserByID(recipientID)
+	if err != nil {
+		s.logf("[ERROR] Не удалось найти участника сделки ID=%d: %v", recipientID, err)
+		return
+	}
+
+	requestedByName := requestedBy.FirstName
+	if requestedBy.LastName != "" {
+		requestedByName += " " + requestedBy.LastName
+	}
+
+	title, message := s.notificationService.FormatDealCancelRequestedNotification(deal, requestedByName)
+	s.sendDealCancelNotification(deal, model.NotificationTypeDealCancelRequested, recipient, title, message)
+}
+
+// sendDealCancelledNotifications уведомляет обоих участников об отмене сделки
+func (s *Service) sendDealCancelledNotifications(deal *model.Deal, mutual bool) {
+	cancelledBy := "по взаимному согласию"
+	if !mutual {
+		user, err := s.repo.GetUserByID(deal.CancelledBy)
+		if err != nil {
+			s.logf("[ERROR] Не удалось найти пользователя ID=%d, отменившего сделку: %v", deal.CancelledBy, err)
+			return
+		}
+		cancelledBy = "инициатор " + user.FirstName
+		if user.LastName != "" {
+			cancelledBy += " " + user.LastName
+		}
+	}
+
+	title, message := s.notificationService.FormatDealCancelledNotification(deal, cancelledBy)
+
+	for _, userID := range []int64{deal.AuthorID, deal.CounterpartyID} {
+		recipient, err := s.repo.GetUserByID(userID)
+		if err != nil {
+			s.logf("[ERROR] Не удалось найти участника сделки ID=%d: %v", userID, err)
+			continue
+		}
+		s.sendDealCancelNotification(deal, model.NotificationTypeDealCancelled, recipient, title, message)
+	}
+}
+
+// sendDealCancelNotification создает и отправляет уведомление об отмене сделки одному участнику
+func (s *Service) sendDealCancelNotification(deal *model.Deal, notificationType model.NotificationType, recipient *model.User, title, message string) {
+	notification, err := s.notificationService.CreateNotification(&model.CreateNotificationRequest{
+		UserID:  recipient.ID,
+		Type:    notificationType,
+		Title:   title,
+		Message: message,
+		DealID:  &deal.ID,
+		Data: map[string]interface{}{
+			"deal_id":       deal.ID,
+			"order_id":      deal.OrderID,
+			"status":        string(deal.Status),
+			"cancel_reason": deal.CancelReason,
+		},
+	})
+	if err != nil {
+		s.logf("[ERROR] Не удалось создать уведомление %s: %v", notificationType, err)
+		return
+	}
+
+	if err := s.notificationService.SendNotification(notification, recipient.TelegramID); err != nil {
+		s.logf("[ERROR] Не удалось отправить уведомление %s: %v", notificationType, err)
+		return
+	}
+
+	s.logf("[INFO] Уведомление %s по сделке ID=%d отправлено пользователю TelegramID=%d",
+		notificationType, deal.ID, recipient.TelegramID)
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"p2pTG-crypto-exchange/internal/model"
+	"p2pTG-crypto-exchange/internal/repository"
+)
+
+const (
+	// defaultDealConfirmationTimeout срок первого подтверждения сделки, если DEAL_CONFIRMATION_TIMEOUT не задан
+	defaultDealConfirmationTimeout = 60 * time.Minute
+
+	// maxDealExpiryWarningLead за сколько до истечения сделки отправляется предупреждение
+	maxDealExpiryWarningLead = 10 * time.Minute
+)
+
+// dealConfirmationTimeout возвращает срок, за который одна из сторон должна подтвердить сделку
+func (s *Service) dealConfirmationTimeout() time.Duration {
+	if s.business.DealConfirmationTimeoutMinutes > 0 {
+		return time.Duration(s.business.DealConfirmationTimeoutMinutes) * time.Minute
+	}
+	return defaultDealConfirmationTimeout
+}
+
+// dealExpiryWarningLead возвращает интервал предупреждения до истечения сделки
+// Для коротких таймаутов предупреждение отправляется на середине срока
+func (s *Service) dealExpiryWarningLead() time.Duration {
+	lead := maxDealExpiryWarningLead
+	if half := s.dealConfirmationTimeout() / 2; half < lead {
+		lead = half
+	}
+	return lead
+}
+
+// StartDealExpiryWorker запускает фоновую проверку сроков сделок
+// Работает до отмены контекста, проверка выполняется раз в interval
+func (s *Service) StartDealExpiryWorker(ctx context.Context, interval time.Duration) {
+	s.logf("[INFO] Запуск проверки сроков сделок (интервал %s, предупреждение за %s)",
+		interval, s.dealExpiryWarningLead())
+
+	s.goWorker(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				s.logln("[INFO] Проверка сроков сделок остановлена")
+				return
+			case now := <-ticker.C:
+				s.ProcessExpiringDeals(now)
+			}
+		}
+	})
+}
+
+// ProcessExpiringDeals выполняет один проход проверки сроков сделок:
+// предупреждает участников о скором истечении и закрывает просроченные неподтвержденные сделки
+func (s *Service) ProcessExpiringDeals(now time.Time) {
+	deals, err := s.repo.GetUnconfirmedDealsExpiringBefore(now.Add(s.dealExpiryWarningLead()))
+	if err != nil {
+		s.logf("[ERROR] Не удалось получить истекающие сделки: %v", err)
+		return
+	}
+
+	for _, deal := range deals {
+		if deal.ExpiresAt.After(now) {
+			// Срок еще не наступил - предупреждаем участников один раз
+			if deal.ExpiryWarningSent {
+				continue
+			}
+			if err := s.repo.MarkDealExpiryWarningSent(deal.ID); err != nil {
+				s.logf("[WARN] Не удалось отметить предупреждение по сделке ID=%d: %v", deal.ID, err)
+				continue
+			}
+			s.logf("[INFO] Сделка ID=%d истекает в %s, отправляем предупреждение",
+				deal.ID, deal.ExpiresAt.Format(time.RFC3339))
+			s.goNotify(func() { s.sendDealExpiryNotifications(deal, model.NotificationTypeDealExpiring) })
+			continue
+		}
+
+		s.expireDeal(deal, now)
+	}
+}
+
+// expireDeal закрывает просроченную сделку и выполняет побочные эффекты перехода
+func (s *Service) expireDeal(deal *model.Deal, now time.Time) {
+	transition, err := model.AuthorizeDealTransition(deal.Status, model.DealActionExpire, model.DealActorSystem)
+	if err != nil {
+		s.logf("[WARN] Сделка ID=%d не закрыта по сроку: %v", deal.ID, err)
+		return
+	}
+
+	// Репозиторий повторно проверяет условия, поэтому подтверждение в последний момент не потеряется.
+	// При ошибке возврата объема сделка остается незакрытой и закрывается следующим проходом
+	err = s.transitionDeal(deal, transition, 0, func(repo repository.RepositoryInterface) error {
+		return repo.ExpireDeal(deal.ID, now)
+	})
+	if err != nil {
+		s.logf("[WARN] Сделка ID=%d не закрыта по сроку: %v", deal.ID, err)
+	}
+}
+
+// sendDealExpiryNotifications отправляет обоим участникам сделки уведомление о сроке сделки
+// Поддерживаются типы deal_expiring (предупреждение) и deal_expired (сделка закрыта)
+func (s *Service) sendDealExpiryNotifications(deal *model.Deal, notificationType model.NotificationType) {
+	var title, message string
+	if notificationType == model.NotificationTypeDealExpiring {
+		title, message = s.notificationService.FormatDealExpiringNotification(deal)
+	} else {
+		title, message = s.notificationService.FormatDealExpiredNotification(deal)
+	}
+
+	for _, userID := range []int64{deal.AuthorID, deal.CounterpartyID} {
+		recipient, err := s.repo.GetUserByID(userID)
+		if err != nil {
+			s.logf("[ERROR] Не удалось найти участника сделки ID=%d: %v", userID, err)
+			continue
+		}
+
+		notification, err := s.notificationService.CreateNotification(&model.CreateNotificationRequest{
+			UserID:  recipient.ID,
+			Type:    notificationType,
+			Title:   title,
+			Message: message,
+			DealID:  &deal.ID,
+			Data: map[string]interface{}{
+				"deal_id":    deal.ID,
+				"order_id":   deal.OrderID,
+				"expires_at": deal.ExpiresAt,
+				"status":     string(deal.Status),
+			},
+		})
+		if err != nil {
+			s.logf("[ERROR] Не удалось создать уведомление %s: %v", notificationType, err)
+			continue
+		}
+
+		if err := s.notificationService.SendNotification(notification, recipient.TelegramID); err != nil {
+			s.logf("[ERROR] Не удалось отправить уведомление %s: %v", notificationType, err)
+			continue
+		}
+
+		s.logf("[INFO] Уведомление %s по сделке ID=%d отправлено пользователю TelegramID=%d",
+			notificationType, deal.ID, recipient.TelegramID)
+	}
+}
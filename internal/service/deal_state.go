@@ -0,0 +1,103 @@
+package service
+
+import (
+	"fmt"
+
+	"p2pTG-crypto-exchange/internal/model"
+	"p2pTG-crypto-exchange/internal/repository"
+)
+
+// transitionDeal сохраняет переход сделки (save) и его изменения данных в одной транзакции хранилища:
+// если заявку или статистику обновить не удалось, новый статус сделки тоже не сохраняется.
+// После фиксации транзакции отправляется уведомление перехода
+func (s *Service) transitionDeal(deal *model.Deal, transition model.DealTransition, actorID int64,
+	save func(repo repository.RepositoryInterface) error) error {
+	err := s.inTransaction(func(repo repository.RepositoryInterface) error {
+		if err := save(repo); err != nil {
+			return err
+		}
+		return s.applyDealEffects(repo, deal, transition)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.announceDealTransition(deal, transition, actorID)
+	return nil
+}
+
+// applyDealEffects выполняет изменения данных перехода сделки из таблицы model.DealTransitions:
+// обновляет заявки и статистику участников. Вызывается в транзакции вместе с сохранением нового статуса
+func (s *Service) applyDealEffects(repo repository.RepositoryInterface, deal *model.Deal, transition model.DealTransition) error {
+	deal.Status = transition.To
+
+	if transition.HasEffect(model.DealEffectOrderCompleted) {
+		if err := s.completeFilledOrder(repo, deal); err != nil {
+			return err
+		}
+	}
+	if transition.HasEffect(model.DealEffectOrderToMarket) {
+		if err := releaseDealAmount(repo, deal); err != nil {
+			return err
+		}
+	}
+	if transition.HasEffect(model.DealEffectUserStats) {
+		if err := updateDealUserStats(repo, deal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// announceDealTransition отправляет уведомление, указанное в переходе сделки, и записывает переход в лог.
+// Вызывается после фиксации перехода
+func (s *Service) announceDealTransition(deal *model.Deal, transition model.DealTransition, actorID int64) {
+	if transition.Notification != "" {
+		s.goNotify(func() { s.sendDealTransitionNotification(deal, transition, actorID) })
+	}
+
+	s.logf("[INFO] Сделка ID=%d: %s -> %s (%s, пользователь ID=%d)",
+		deal.ID, transition.From, transition.To, transition.Action, actorID)
+}
+
+// updateDealUserStats учитывает закрытую сделку в статистике обоих участников
+func updateDealUserStats(repo repository.RepositoryInterface, deal *model.Deal) error {
+	successful := deal.Status == model.DealStatusCompleted
+	for _, userID := range []int64{deal.AuthorID, deal.CounterpartyID} {
+		if err := repo.UpdateUserDealStats(userID, successful); err != nil {
+			return fmt.Errorf("не удалось обновить статистику сделок пользователя ID=%d: %w", userID, err)
+		}
+	}
+	return nil
+}
+
+// sendDealTransitionNotification отправляет уведомление, указанное в переходе сделки
+func (s *Service) sendDealTransitionNotification(deal *model.Deal, transition model.DealTransition, actorID int64) {
+	switch transition.Notification {
+	case model.NotificationTypeDealConfirmed:
+		s.sendDealConfirmedNotification(deal, actorID, otherDealParticipant(deal, actorID))
+	case model.NotificationTypeDealCompleted:
+		s.sendDealCompletedNotifications(deal)
+	case model.NotificationTypeDealExpired:
+		s.sendDealExpiryNotifications(deal, model.NotificationTypeDealExpired)
+	case model.NotificationTypeDisputeOpened:
+		s.sendDisputeOpenedNotifications(deal)
+	case model.NotificationTypeDisputeResolved:
+		s.sendDisputeResolvedNotifications(deal)
+	case model.NotificationTypeDealCancelRequested:
+		s.sendDealCancelRequestedNotification(deal, otherDealParticipant(deal, deal.CancelRequestedBy))
+	case model.NotificationTypeDealCancelled:
+		mutual := deal.CancelRequestedBy != 0 && deal.CancelRequestedBy != deal.CancelledBy
+		s.sendDealCancelledNotifications(deal, mutual)
+	default:
+		s.logf("[WARN] Нет отправителя для уведомления %s по сделке ID=%d", transition.Notification, deal.ID)
+	}
+}
+
+// otherDealParticipant возвращает ID второго участника сделки
+func otherDealParticipant(deal *model.Deal, userID int64) int64 {
+	if userID == deal.AuthorID {
+		return deal.CounterpartyID
+	}
+	return deal.AuthorID
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"p2pTG-crypto-exchange/internal/model"
+	"p2pTG-crypto-exchange/internal/repository"
+)
+
+// failingReleaseRepository хранилище, в котором не удается вернуть объем в заявку.
+// Транзакции выполняются хранилищем-основой, сбой сохраняется и внутри них
+type failingReleaseRepository struct {
+	repository.RepositoryInterface
+}
+
+func (r failingReleaseRepository) ReleaseOrderAmount(orderID int64, amount float64) error {
+	return errors.New("хранилище недоступно")
+}
+
+func (r failingReleaseRepository) InTransaction(fn func(tx repository.RepositoryInterface) error) error {
+	return r.RepositoryInterface.(repository.Transactional).InTransaction(func(tx repository.RepositoryInterface) error {
+		return fn(failingReleaseRepository{tx})
+	})
+}
+
+func TestCancelDealRollsBackWhenEffectFails(t *testing.T) {
+	s, repo, seller, buyer := newTestService(t)
+	deal := openDeal(t, s, seller, buyer)
+
+	// Объем не вернулся в заявку - отмена не сохраняется вместе с ним
+	s.repo = failingReleaseRepository{repo}
+	_, err := s.CancelDeal(deal.ID, buyer.ID, &model.CancelDealRequest{Reason: "передумал"})
+	expectError(t, err, "не удалось вернуть объем")
+
+	stored, err := repo.GetDealByID(deal.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != model.DealStatusInProgress {
+		t.Fatalf("после сбоя сделка в статусе %s, ожидался %s", stored.Status, model.DealStatusInProgress)
+	}
+
+	// После восстановления хранилища отмена проходит целиком
+	s.repo = repo
+	if _, err := s.CancelDeal(deal.ID, buyer.ID, &model.CancelDealRequest{Reason: "передумал"}); err != nil {
+		t.Fatal(err)
+	}
+	order, err := repo.GetOrderByID(deal.OrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.RemainingAmount != order.Amount {
+		t.Fatalf("остаток заявки %v после отмены, ожидался %v", order.RemainingAmount, order.Amount)
+	}
+}
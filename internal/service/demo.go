@@ -0,0 +1,53 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+
+	"p2pTG-crypto-exchange/internal/model"
+	"p2pTG-crypto-exchange/internal/repository"
+)
+
+// SetDemoMode включает демо-режим: вход без Telegram под демонстрационными пользователями (DemoLogin)
+func (s *Service) SetDemoMode(demo bool) {
+	s.demo = demo
+	if demo {
+		s.logln("[WARN] Демо-режим: вход без подписи Telegram под демонстрационными пользователями")
+	}
+}
+
+// DemoMode сообщает, включен ли демо-режим
+func (s *Service) DemoMode() bool {
+	return s.demo
+}
+
+// DemoLogin выполняет вход под демонстрационным пользователем без данных Telegram (только в демо-режиме).
+// Членство в закрытом чате не проверяется: демо-пользователи созданы его участниками.
+// telegramID выбирает одного из демо-пользователей, 0 - первого из них
+func (s *Service) DemoLogin(telegramID int64) (*model.User, error) {
+	if !s.demo {
+		return nil, fmt.Errorf("демо-вход доступен только в демо-режиме")
+	}
+
+	demoIDs := repository.DemoTelegramIDs()
+	if telegramID == 0 {
+		telegramID = demoIDs[0]
+	}
+	if !slices.Contains(demoIDs, telegramID) {
+		s.logf("[WARN] Демо-вход под пользователем не из демо-данных: TelegramID=%d", telegramID)
+		return nil, fmt.Errorf("пользователь TelegramID=%d не является демонстрационным", telegramID)
+	}
+
+	user, err := s.repo.GetUserByTelegramID(telegramID)
+	if err != nil {
+		s.logf("[ERROR] Демо-пользователь TelegramID=%d не найден: %v", telegramID, err)
+		return nil, fmt.Errorf("демо-пользователь не найден")
+	}
+	if !user.IsActive {
+		s.logf("[WARN] Демо-вход заблокированного пользователя TelegramID=%d", telegramID)
+		return nil, fmt.Errorf("ваш аккаунт заблокирован")
+	}
+
+	s.logf("[INFO] Демо-вход пользователя: ID=%d, TelegramID=%d", user.ID, user.TelegramID)
+	return user, nil
+}
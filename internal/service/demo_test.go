@@ -0,0 +1,69 @@
+package service
+
+import (
+	"testing"
+
+	"p2pTG-crypto-exchange/internal/model"
+	"p2pTG-crypto-exchange/internal/repository"
+)
+
+func TestDemoLoginDisabledOutsideDemoMode(t *testing.T) {
+	s, repo, _, _ := newTestService(t)
+	if err := repository.SeedDemoData(repo); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := s.DemoLogin(repository.DemoTelegramIDs()[0])
+	expectError(t, err, "только в демо-режиме")
+}
+
+func TestDemoLogin(t *testing.T) {
+	s, repo, seller, _ := newTestService(t)
+	if err := repository.SeedDemoData(repo); err != nil {
+		t.Fatal(err)
+	}
+	s.SetDemoMode(true)
+	s.SetSecurityConfig(model.SecurityConfig{JWTSecret: "test-secret"})
+	demoIDs := repository.DemoTelegramIDs()
+
+	// Без telegram_id - первый демо-пользователь, сессия которого принимается как обычная
+	user, err := s.DemoLogin(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.TelegramID != demoIDs[0] {
+		t.Fatalf("демо-вход под TelegramID=%d, ожидался %d", user.TelegramID, demoIDs[0])
+	}
+	token, _, err := s.IssueSessionToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session, err := s.AuthenticateSessionToken(token); err != nil || session.ID != user.ID {
+		t.Fatalf("сессия демо-пользователя: %v", err)
+	}
+
+	user, err = s.DemoLogin(demoIDs[2])
+	if err != nil || user.TelegramID != demoIDs[2] {
+		t.Fatalf("демо-вход под выбранным пользователем: %v", err)
+	}
+
+	// Пользователи не из демо-данных недоступны даже в демо-режиме
+	_, err = s.DemoLogin(seller.TelegramID)
+	expectError(t, err, "не является демонстрационным")
+}
+
+func TestNewServiceFromConfigDemoMode(t *testing.T) {
+	cfg := &model.Config{Demo: true}
+	cfg.Telegram.BotToken = "123:abc"
+	s, err := NewServiceFromConfig(repository.NewMemoryRepository(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.DemoMode() {
+		t.Fatal("демо-режим из конфигурации не включен")
+	}
+	// Вход через Telegram и уведомления в демо-режиме отключены
+	if s.telegramToken != "" || s.notificationService.telegramToken != "" {
+		t.Fatal("в демо-режиме сервис не должен использовать токен бота")
+	}
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"p2pTG-crypto-exchange/internal/model"
+	"p2pTG-crypto-exchange/internal/repository"
+)
+
+const (
+	// maxDisputeReasonLength максимальная длина причины спора
+	maxDisputeReasonLength = 1000
+
+	// maxDisputeEvidenceLength максимальная длина описания доказательств
+	maxDisputeEvidenceLength = 2000
+)
+
+// SetAdminUserIDs задает Telegram ID администраторов, которые решают споры по сделкам
+func (s *Service) SetAdminUserIDs(telegramIDs []int64) {
+	s.adminTelegramIDs = make(map[int64]bool, len(telegramIDs))
+	for _, id := range telegramIDs {
+		s.adminTelegramIDs[id] = true
+	}
+	s.logf("[INFO] Администраторов для арбитража споров: %d", len(s.adminTelegramIDs))
+}
+
+// IsAdmin проверяет, является ли пользователь администратором биржи
+func (s *Service) IsAdmin(user *model.User) bool {
+	return user != nil && s.adminTelegramIDs[user.TelegramID]
+}
+
+// OpenDispute открывает спор по сделке от имени одного из участников
+// Пока спор открыт, подтверждения сделки заморожены
+func (s *Service) OpenDispute(dealID, userID int64, req *model.OpenDisputeRequest) (*model.Deal, error) {
+	s.logf("[INFO] Открытие спора по сделке ID=%d пользователем ID=%d", dealID, userID)
+
+	reason := strings.TrimSpace(req.Reason)
+	evidence := strings.TrimSpace(req.Evidence)
+	if reason == "" {
+		return nil, fmt.Errorf("укажите причину спора")
+	}
+	if len([]rune(reason)) > maxDisputeReasonLength {
+		return nil, fmt.Errorf("причина спора не должна превышать %d символов", maxDisputeReasonLength)
+	}
+	if len([]rune(evidence)) > maxDisputeEvidenceLength {
+		return nil, fmt.Errorf("описание доказательств не должно превышать %d символов", maxDisputeEvidenceLength)
+	}
+
+	// Проверяем доступ к сделке
+	deal, err := s.GetDeal(dealID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	transition, err := model.AuthorizeDealTransition(deal.Status, model.DealActionOpenDispute, model.DealActorParticipant)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	deal.DisputeReason = reason
+	deal.DisputeEvidence = evidence
+	deal.DisputeOpenedBy = userID
+	deal.DisputeOpenedAt = &now
+
+	err = s.transitionDeal(deal, transition, userID, func(repo repository.RepositoryInterface) error {
+		return repo.OpenDealDispute(dealID, userID, reason, evidence, now)
+	})
+	if err != nil {
+		s.logf("[ERROR] Не удалось открыть спор по сделке ID=%d: %v", dealID, err)
+		return nil, fmt.Errorf("не удалось открыть спор: %w", err)
+	}
+
+	s.addDealEvent(deal.ID, userID, model.DealEventDisputeOpened, transition.To, "Открыт спор: "+reason)
+
+	s.logf("[INFO] Спор по сделке ID=%d открыт пользователем ID=%d", dealID, userID)
+	return deal, nil
+}
+
+// GetOpenDisputes возвращает сделки с открытым спором для администратора
+func (s *Service) GetOpenDisputes(admin *model.User) ([]*model.Deal, error) {
+	if !s.IsAdmin(admin) {
+		return nil, fmt.Errorf("доступ запрещен: требуется роль администратора")
+	}
+
+	deals, err := s.repo.GetDisputedDeals()
+	if err != nil {
+		s.logf("[ERROR] Не удалось получить открытые споры: %v", err)
+		return nil, fmt.Errorf("не удалось получить споры: %w", err)
+	}
+
+	return deals, nil
+}
+
+// ResolveDispute закрывает спор решением администратора
+// Решение в пользу покупателя криптовалюты завершает сделку, в пользу продавца - отменяет ее
+func (s *Service) ResolveDispute(admin *model.User, dealID int64, req *model.ResolveDisputeRequest) (*model.Deal, error) {
+	if !s.IsAdmin(admin) {
+		return nil, fmt.Errorf("доступ запрещен: требуется роль администратора")
+	}
+
+	s.logf("[INFO] Решение спора по сделке ID=%d администратором ID=%d в пользу ID=%d",
+		dealID, admin.ID, req.WinnerID)
+
+	deal, err := s.repo.GetDealByID(dealID)
+	if err != nil {
+		s.logf("[ERROR] Сделка ID=%d не найдена: %v", dealID, err)
+		return nil, fmt.Errorf("сделка не найдена")
+	}
+
+	// Администратор не может быть арбитром в собственной сделке
+	if admin.ID == deal.AuthorID || admin.ID == deal.CounterpartyID {
+		s.logf("[WARN] Администратор ID=%d пытается решить спор по своей сделке ID=%d", admin.ID, dealID)
+		return nil, fmt.Errorf("доступ запрещен: нельзя решать спор по сделке, в которой вы участвуете")
+	}
+
+	if req.WinnerID != deal.AuthorID && req.WinnerID != deal.CounterpartyID {
+		return nil, fmt.Errorf("решение должно быть в пользу одного из участников сделки")
+	}
+
+	status := model.DealStatusCancelled
+	if req.WinnerID == dealCryptoBuyerID(deal) {
+		status = model.DealStatusCompleted
+	}
+
+	resolution := &model.DisputeResolution{
+		Status:     status,
+		WinnerID:   req.WinnerID,
+		ResolvedBy: admin.ID,
+		Comment:    strings.TrimSpace(req.Comment),
+		ResolvedAt: time.Now(),
+	}
+
+	transition, err := model.AuthorizeDealTransition(deal.Status, resolution.Action(), model.DealActorAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	deal.DisputeWinnerID = resolution.WinnerID
+	deal.DisputeResolvedBy = resolution.ResolvedBy
+	deal.DisputeResolvedAt = &resolution.ResolvedAt
+	deal.DisputeComment = resolution.Comment
+	if status == model.DealStatusCompleted {
+		deal.CompletedAt = &resolution.ResolvedAt
+	}
+
+	// Заявка и статистика участников обновляются в одной транзакции с решением спора
+	err = s.transitionDeal(deal, transition, admin.ID, func(repo repository.RepositoryInterface) error {
+		return repo.ResolveDealDispute(dealID, resolution)
+	})
+	if err != nil {
+		s.logf("[ERROR] Не удалось решить спор по сделке ID=%d: %v", dealID, err)
+		return nil, fmt.Errorf("не удалось решить спор: %w", err)
+	}
+
+	message := fmt.Sprintf("Спор решен в пользу пользователя ID=%d, сделка %s", req.WinnerID,
+		map[bool]string{true: "завершена", false: "отменена"}[status == model.DealStatusCompleted])
+	if resolution.Comment != "" {
+		message += ": " + resolution.Comment
+	}
+	s.addDealEvent(deal.ID, admin.ID, model.DealEventDisputeResolved, transition.To, message)
+
+	s.logf("[INFO] Спор по сделке ID=%d решен: status=%s", dealID, deal.Status)
+	return deal, nil
+}
+
+// GetDealTimeline возвращает хронологию сделки участнику или администратору
+func (s *Service) GetDealTimeline(dealID int64, user *model.User) ([]*model.DealEvent, error) {
+	if !s.IsAdmin(user) {
+		// Обычным пользователям хронология доступна только по своим сделкам
+		if _, err := s.GetDeal(dealID, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	events, err := s.repo.GetDealEvents(dealID)
+	if err != nil {
+		s.logf("[ERROR] Не удалось получить хронологию сделки ID=%d: %v", dealID, err)
+		return nil, fmt.Errorf("не удалось получить хронологию сделки: %w", err)
+	}
+
+	return events, nil
+}
+
+// dealCryptoBuyerID возвращает ID участника, который покупает криптовалюту в сделке
+func dealCryptoBuyerID(deal *model.Deal) int64 {
+	if deal.OrderType == model.OrderTypeBuy {
+		return deal.AuthorID
+	}
+	return deal.CounterpartyID
+}
+
+// addDealEvent записывает событие в хронологию сделки
+// Ошибка записи не прерывает основную операцию
+func (s *Service) addDealEvent(dealID, userID int64, eventType model.DealEventType, status model.DealStatus, message string) {
+	event := &model.DealEvent{
+		DealID:    dealID,
+		UserID:    userID,
+		Type:      eventType,
+		Status:    status,
+		Message:   message,
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.AddDealEvent(event); err != nil {
+		s.logf("[WARN] Не удалось записать событие %s в хронологию сделки ID=%d: %v", eventType, dealID, err)
+	}
+}
+
+// sendDisputeOpenedNotifications уведомляет второго участника и администраторов об открытом споре
+func (s *Service) sendDisputeOpenedNotifications(deal *model.Deal) {
+	openedBy, err := s.repo.GetUserByID(deal.DisputeOpenedBy)
+	if err != nil {
+		s.logf("[ERROR] Не удалось найти пользователя ID=%d, открывшего спор: %v", deal.DisputeOpenedBy, err)
+		return
+	}
+
+	openedByName := openedBy.FirstName
+	if openedBy.LastName != "" {
+		openedByName += " " + openedBy.LastName
+	}
+
+	title, message := s.notificationService.FormatDisputeOpenedNotification(deal, openedByName)
+
+	// Второй участник сделки
+	otherID := deal.CounterpartyID
+	if deal.DisputeOpenedBy == deal.CounterpartyID {
+		otherID = deal.AuthorID
+	}
+	recipients := make(map[int64]int64) // TelegramID -> внутренний ID (0 для администраторов без аккаунта)
+	if other, err := s.repo.GetUserByID(otherID); err != nil {
+		s.logf("[ERROR] Не удалось найти участника сделки ID=%d: %v", otherID, err)
+	} else {
+		recipients[other.TelegramID] = other.ID
+	}
+
+	// Администраторы получают уведомление напрямую в Telegram
+	for telegramID := range s.adminTelegramIDs {
+		if _, exists := recipients[telegramID]; !exists {
+			recipients[telegramID] = 0
+		}
+	}
+
+	for telegramID, userID := range recipients {
+		s.sendDisputeNotification(deal, model.NotificationTypeDisputeOpened, userID, telegramID, title, message)
+	}
+}
+
+// sendDisputeResolvedNotifications уведомляет обоих участников о решении спора
+func (s *Service) sendDisputeResolvedNotifications(deal *model.Deal) {
+	winner, err := s.repo.GetUserByID(deal.DisputeWinnerID)
+	if err != nil {
+		s.logf("[ERROR] Не удалось найти участника ID=%d, выигравшего спор: %v", deal.DisputeWinnerID, err)
+		return
+	}
+
+	winnerName := winner.FirstName
+	if winner.LastName != "" {
+		winnerName += " " + winner.LastName
+	}
+
+	title, message := s.notificationService.FormatDisputeResolvedNotification(deal, winnerName)
+
+	for _, userID := range []int64{deal.AuthorID, deal.CounterpartyID} {
+		recipient, err := s.repo.GetUserByID(userID)
+		if err != nil {
+			s.logf("[ERROR] Не удалось найти участника сделки ID=%d: %v", userID, err)
+			continue
+		}
+		s.sendDisputeNotification(deal, model.NotificationTypeDisputeResolved, recipient.ID, recipient.TelegramID, title, message)
+	}
+}
+
+// sendDisputeNotification создает и отправляет уведомление по спору одному получателю
+func (s *Service) sendDisputeNotification(deal *model.Deal, notificationType model.NotificationType, userID, telegramID int64, title, message string) {
+	notification, err := s.notificationService.CreateNotification(&model.CreateNotificationRequest{
+		UserID:  userID,
+		Type:    notificationType,
+		Title:   title,
+		Message: message,
+		DealID:  &deal.ID,
+		Data: map[string]interface{}{
+			"deal_id":        deal.ID,
+			"status":         string(deal.Status),
+			"dispute_reason": deal.DisputeReason,
+			"winner_id":      deal.DisputeWinnerID,
+		},
+	})
+	if err != nil {
+		s.logf("[ERROR] Не удалось создать уведомление %s: %v", notificationType, err)
+		return
+	}
+
+	if err := s.notificationService.SendNotification(notification, telegramID); err != nil {
+		s.logf("[ERROR] Не удалось отправить уведомление %s: %v", notificationType, err)
+		return
+	}
+
+	s.logf("[INFO] Уведомление %s по сделке ID=%d отправлено TelegramID=%d", notificationType, deal.ID, telegramID)
+}
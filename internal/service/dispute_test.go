@@ -0,0 +1,34 @@
+package service
+
+import (
+	"testing"
+
+	"p2pTG-crypto-exchange/internal/model"
+)
+
+func TestResolveDisputeRejectsParticipantAdmin(t *testing.T) {
+	s, repo, seller, buyer := newTestService(t)
+	deal := openDeal(t, s, seller, buyer)
+	if _, err := s.OpenDispute(deal.ID, buyer.ID, &model.OpenDisputeRequest{Reason: "оплата не пришла"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Продавец - администратор, но участвует в сделке
+	arbiter := &model.User{TelegramID: 1003, FirstName: "Арбитр", ChatMember: true}
+	if err := repo.CreateUser(arbiter); err != nil {
+		t.Fatal(err)
+	}
+	s.SetAdminUserIDs([]int64{seller.TelegramID, arbiter.TelegramID})
+
+	_, err := s.ResolveDispute(seller, deal.ID, &model.ResolveDisputeRequest{WinnerID: seller.ID})
+	expectError(t, err, "в которой вы участвуете")
+
+	// Сторонний администратор решает спор
+	resolved, err := s.ResolveDispute(arbiter, deal.ID, &model.ResolveDisputeRequest{WinnerID: seller.ID, Comment: "перевода нет"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.DisputeResolvedBy != arbiter.ID || resolved.DisputeWinnerID != seller.ID {
+		t.Fatalf("спор решен неверно: %+v", resolved)
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"p2pTG-crypto-exchange/internal/model"
+)
+
+// =====================================================
+// ПЛАВАЮЩИЕ ЦЕНЫ ЗАЯВОК
+// =====================================================
+
+// SetRateProvider подключает источник курсов для заявок с плавающей ценой
+// Без источника создать заявку с плавающей ценой нельзя, а существующие показываются по последней цене
+func (s *Service) SetRateProvider(provider RateProvider) {
+	s.rateProvider = provider
+	if provider != nil {
+		s.logf("[INFO] Источник курсов для плавающих цен подключен")
+	}
+}
+
+// priceFloatingOrder считает цену заявки с плавающей ценой перед сохранением.
+// Для заявок с фиксированной ценой ничего не делает
+func (s *Service) priceFloatingOrder(order *model.Order) error {
+	if !order.IsFloating() {
+		return nil
+	}
+	if s.rateProvider == nil {
+		return fmt.Errorf("плавающая цена недоступна: источник курсов не настроен")
+	}
+
+	rate, err := s.rateProvider.GetRate(order.Cryptocurrency, order.FiatCurrency)
+	if err != nil {
+		s.logf("[WARN] Нет курса %s/%s для плавающей цены: %v", order.Cryptocurrency, order.FiatCurrency, err)
+		return fmt.Errorf("не удалось получить курс %s/%s: %w", order.Cryptocurrency, order.FiatCurrency, err)
+	}
+	order.ApplyReferenceRate(rate)
+	return nil
+}
+
+// applyFloatingPrices пересчитывает цены плавающих заявок по текущему курсу при чтении.
+// Если курс недоступен, у заявки остается последняя сохраненная цена
+func (s *Service) applyFloatingPrices(orders []*model.Order) {
+	if s.rateProvider == nil {
+		return
+	}
+	for _, order := range orders {
+		if !order.IsFloating() {
+			continue
+		}
+		rate, err := s.rateProvider.GetRate(order.Cryptocurrency, order.FiatCurrency)
+		if err != nil {
+			s.logf("[WARN] Цена заявки ID=%d не пересчитана: %v", order.ID, err)
+			continue
+		}
+		order.ApplyReferenceRate(rate)
+	}
+}
+
+// StartFloatingPriceWorker запускает фоновое обновление сохраненных цен плавающих заявок.
+// Сохраненная цена нужна запросам, которые считают по ценам в хранилище: стакану и автосопоставлению
+func (s *Service) StartFloatingPriceWorker(ctx context.Context, interval time.Duration) {
+	if s.rateProvider == nil {
+		return
+	}
+	s.logf("[INFO] Запуск обновления плавающих цен (интервал %s)", interval)
+
+	s.goWorker(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				s.logln("[INFO] Обновление плавающих цен остановлено")
+				return
+			case <-ticker.C:
+				s.RefreshFloatingPrices()
+			}
+		}
+	})
+}
+
+// RefreshFloatingPrices выполняет один проход обновления: пересчитывает цены плавающих заявок
+// на рынке и сохраняет те, что изменились
+func (s *Service) RefreshFloatingPrices() {
+	orders, err := s.repo.GetOrdersByFilter(&model.OrderFilter{})
+	if err != nil {
+		s.logf("[ERROR] Не удалось получить заявки для обновления плавающих цен: %v", err)
+		return
+	}
+
+	updated := 0
+	for _, order := range orders {
+		if !order.IsFloating() {
+			continue
+		}
+		if order.Status != model.OrderStatusActive && order.Status != model.OrderStatusHasResponses {
+			continue
+		}
+		storedPrice := order.Price
+		s.applyFloatingPrices([]*model.Order{order})
+		if math.Abs(order.Price-storedPrice) < 0.005 {
+			continue
+		}
+
+		if err := s.repo.UpdateOrderPrice(order.ID, order.Price); err != nil {
+			s.logf("[WARN] Не удалось сохранить цену заявки ID=%d: %v", order.ID, err)
+			continue
+		}
+		updated++
+	}
+
+	if updated > 0 {
+		s.logf("[INFO] Обновлены цены плавающих заявок: %d", updated)
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"fmt"
+)
+
+// =====================================================
+// ФОНОВЫЕ ЗАДАЧИ И ОСТАНОВКА СЕРВИСА
+// =====================================================
+
+// goWorker запускает фоновый обработчик так, чтобы Shutdown дождался его завершения.
+// Обработчик должен завершаться по отмене своего контекста
+func (s *Service) goWorker(run func()) {
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		run()
+	}()
+}
+
+// goNotify отправляет уведомление в фоне так, чтобы Shutdown дождался окончания отправки
+func (s *Service) goNotify(send func()) {
+	s.notifications.Add(1)
+	go func() {
+		defer s.notifications.Done()
+		send()
+	}()
+}
+
+// Shutdown ожидает остановки фоновых обработчиков и отправки начатых уведомлений.
+// Вызывается после остановки HTTP сервера и отмены контекста, переданного в Start*Worker;
+// ожидание прерывается по ctx
+func (s *Service) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		// Обработчики могут успеть отправить уведомления, поэтому сначала ждем их
+		s.workers.Wait()
+		s.notifications.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.logln("[INFO] Фоновые задачи и уведомления завершены")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("не дождались завершения фоновых задач: %w", ctx.Err())
+	}
+}
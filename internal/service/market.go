@@ -0,0 +1,92 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"p2pTG-crypto-exchange/internal/model"
+)
+
+// =====================================================
+// РЫНОЧНАЯ СТАТИСТИКА И ИСТОРИЯ ЦЕН
+// =====================================================
+
+// Параметры рыночной статистики
+const (
+	marketStatsPeriod     = 24 * time.Hour // Период статистики по парам
+	defaultCandlesLimit   = 100            // Количество свечей по умолчанию
+	maxCandlesLimit       = 500            // Максимальное количество свечей в ответе
+	defaultCandleInterval = model.CandleInterval1h
+)
+
+// GetMarketStats возвращает статистику завершенных сделок за последние 24 часа по каждой торговой паре.
+// Если указана пара (например, "USDT-RUB"), возвращается статистика только по ней
+func (s *Service) GetMarketStats(pair string) ([]*model.MarketStats, error) {
+	now := time.Now()
+	filter := &model.TradeFilter{
+		Since: now.Add(-marketStatsPeriod),
+		Until: now,
+	}
+	if strings.TrimSpace(pair) != "" {
+		crypto, fiat, err := model.ParseTradingPair(pair)
+		if err != nil {
+			return nil, err
+		}
+		filter.Cryptocurrency, filter.FiatCurrency = crypto, fiat
+	}
+
+	trades, err := s.repo.GetCompletedTrades(filter)
+	if err != nil {
+		s.logf("[ERROR] Не удалось получить завершенные сделки для статистики: %v", err)
+		return nil, fmt.Errorf("не удалось получить статистику рынка: %w", err)
+	}
+
+	stats := model.CalculateMarketStats(trades)
+	s.logf("[INFO] Статистика рынка за 24 часа: сделок %d, пар %d", len(trades), len(stats))
+	return stats, nil
+}
+
+// GetMarketCandles возвращает свечи OHLCV торговой пары по завершенным сделкам.
+// Возвращаются последние limit интервалов, интервалы без сделок пропускаются
+func (s *Service) GetMarketCandles(pair, interval string, limit int) ([]*model.Candle, error) {
+	crypto, fiat, err := model.ParseTradingPair(pair)
+	if err != nil {
+		return nil, err
+	}
+
+	if interval == "" {
+		interval = string(defaultCandleInterval)
+	}
+	_, duration, err := model.ParseCandleInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = defaultCandlesLimit
+	}
+	if limit > maxCandlesLimit {
+		limit = maxCandlesLimit
+	}
+
+	// Окно выборки: limit интервалов, заканчивая текущим (незакрытым) интервалом
+	now := time.Now()
+	until := now.UTC().Truncate(duration).Add(duration)
+	filter := &model.TradeFilter{
+		Cryptocurrency: crypto,
+		FiatCurrency:   fiat,
+		Since:          until.Add(-time.Duration(limit) * duration),
+		Until:          until,
+	}
+
+	trades, err := s.repo.GetCompletedTrades(filter)
+	if err != nil {
+		s.logf("[ERROR] Не удалось получить завершенные сделки %s/%s для свечей: %v", crypto, fiat, err)
+		return nil, fmt.Errorf("не удалось получить историю цен: %w", err)
+	}
+
+	candles := model.BuildCandles(trades, duration)
+	s.logf("[INFO] Свечи %s/%s (%s): сделок %d, свечей %d", crypto, fiat, interval, len(trades), len(candles))
+	return candles, nil
+}
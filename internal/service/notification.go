@@ -90,6 +90,22 @@ func (ns *NotificationService) initTemplates() {
 		Description: "Уведомление участнику об отклонении его отклика",
 	}
 
+	// Шаблон для отозванного отклика
+	ns.templates[model.NotificationTypeResponseWithdrawn] = &model.NotificationTemplate{
+		Type:        model.NotificationTypeResponseWithdrawn,
+		Title:       "↩️ Отклик отозван",
+		Message:     "Пользователь %s отозвал свой отклик на вашу заявку #%d (%s %s %s).\n\n📋 Заявка остается на бирже, остальные отклики можно рассмотреть в приложении.",
+		Description: "Уведомление автору заявки об отзыве отклика",
+	}
+
+	// Шаблон для встречного предложения по отклику
+	ns.templates[model.NotificationTypeResponseCountered] = &model.NotificationTemplate{
+		Type:        model.NotificationTypeResponseCountered,
+		Title:       "💬 Встречное предложение",
+		Message:     "%s предлагает другие условия по заявке #%d (%s %s %s):\n\n💎 Объем: %.8g %s\n💵 Курс: %.2f %s\n💸 Сумма: %.2f %s\n💬 Комментарий: \"%s\"\n\n🤝 Примите предложение или ответьте своим в приложении.",
+		Description: "Уведомление второй стороне о встречном предложении по отклику",
+	}
+
 	// Шаблон для созданной сделки
 	ns.templates[model.NotificationTypeDealCreated] = &model.NotificationTemplate{
 		Type:        model.NotificationTypeDealCreated,
@@ -114,6 +130,62 @@ func (ns *NotificationService) initTemplates() {
 		Description: "Уведомление об успешном завершении сделки",
 	}
 
+	// Шаблон для предупреждения о скором истечении сделки
+	ns.templates[model.NotificationTypeDealExpiring] = &model.NotificationTemplate{
+		Type:        model.NotificationTypeDealExpiring,
+		Title:       "⏰ Сделка скоро истечет",
+		Message:     "Сделка #%d (%.2f %s на сумму %.2f %s) истекает в %s.\n\n⚠️ Если ни одна из сторон не подтвердит сделку до этого времени, она будет закрыта автоматически, а заявка вернется на биржу.",
+		Description: "Предупреждение участникам о скором истечении неподтвержденной сделки",
+	}
+
+	// Шаблон для истекшей сделки
+	ns.templates[model.NotificationTypeDealExpired] = &model.NotificationTemplate{
+		Type:        model.NotificationTypeDealExpired,
+		Title:       "⌛ Сделка истекла",
+		Message:     "Сделка #%d (%.2f %s на сумму %.2f %s) закрыта: никто из участников не подтвердил ее в отведенное время.\n\n📋 Заявка снова доступна на бирже.",
+		Description: "Уведомление участникам об автоматическом закрытии неподтвержденной сделки",
+	}
+
+	// Шаблон для истекшей заявки
+	ns.templates[model.NotificationTypeOrderExpired] = &model.NotificationTemplate{
+		Type:        model.NotificationTypeOrderExpired,
+		Title:       "⌛ Срок заявки истек",
+		Message:     "Ваша заявка #%d на %s %.2f %s по цене %.2f %s снята с биржи: истек срок ее действия.\n\n📋 Ожидающие отклики отклонены. Чтобы продолжить торговлю, создайте новую заявку.",
+		Description: "Уведомление автору об автоматическом снятии заявки по сроку",
+	}
+
+	// Шаблон для отмененной сделки
+	ns.templates[model.NotificationTypeDealCancelled] = &model.NotificationTemplate{
+		Type:        model.NotificationTypeDealCancelled,
+		Title:       "❌ Сделка отменена",
+		Message:     "Сделка #%d (%.2f %s на сумму %.2f %s) отменена: %s.\n\n📝 Причина: %s\n\n📋 Заявка снова доступна на бирже.",
+		Description: "Уведомление участникам об отмене сделки",
+	}
+
+	// Шаблон для запроса отмены сделки
+	ns.templates[model.NotificationTypeDealCancelRequested] = &model.NotificationTemplate{
+		Type:        model.NotificationTypeDealCancelRequested,
+		Title:       "🤚 Запрос на отмену сделки",
+		Message:     "%s просит отменить сделку #%d (%.2f %s на сумму %.2f %s).\n\n📝 Причина: %s\n\n💡 Если вы согласны, отмените сделку в приложении. Если нет - продолжайте сделку или откройте спор.",
+		Description: "Уведомление второй стороне о запросе взаимной отмены сделки",
+	}
+
+	// Шаблон для открытого спора
+	ns.templates[model.NotificationTypeDisputeOpened] = &model.NotificationTemplate{
+		Type:        model.NotificationTypeDisputeOpened,
+		Title:       "⚠️ Открыт спор по сделке",
+		Message:     "%s открыл спор по сделке #%d (%.2f %s на сумму %.2f %s).\n\n📝 Причина: %s\n\n⏸ Подтверждения по сделке заморожены до решения администратора.",
+		Description: "Уведомление второй стороне и администраторам об открытии спора",
+	}
+
+	// Шаблон для решенного спора
+	ns.templates[model.NotificationTypeDisputeResolved] = &model.NotificationTemplate{
+		Type:        model.NotificationTypeDisputeResolved,
+		Title:       "⚖️ Спор по сделке решен",
+		Message:     "Администратор рассмотрел спор по сделке #%d.\n\n🏆 Решение в пользу: %s\n📋 Итог: %s\n💬 Комментарий: %s",
+		Description: "Уведомление участникам о решении администратора по спору",
+	}
+
 	// Шаблон для системных сообщений
 	ns.templates[model.NotificationTypeSystemMessage] = &model.NotificationTemplate{
 		Type:        model.NotificationTypeSystemMessage,
@@ -260,8 +332,8 @@ func (ns *NotificationService) createInlineKeyboard(notification *model.Notifica
 	var buttons [][]model.TelegramInlineKeyboardButton
 
 	switch notification.Type {
-	case model.NotificationTypeNewResponse:
-		// Кнопки для автора заявки: "Посмотреть отклики", "Перейти в приложение"
+	case model.NotificationTypeNewResponse, model.NotificationTypeResponseCountered, model.NotificationTypeResponseWithdrawn:
+		// Кнопки для участника переговоров: "Посмотреть отклики", "Перейти в приложение"
 		buttons = [][]model.TelegramInlineKeyboardButton{
 			{
 				{Text: "📋 Посмотреть отклики", WebApp: &model.TelegramWebAppInfo{URL: fmt.Sprintf("%s/#responses", ns.webAppURL)}},
@@ -293,7 +365,9 @@ func (ns *NotificationService) createInlineKeyboard(notification *model.Notifica
 			},
 		}
 
-	case model.NotificationTypeDealConfirmed, model.NotificationTypeDealCompleted:
+	case model.NotificationTypeDealConfirmed, model.NotificationTypeDealCompleted, model.NotificationTypeDealExpiring,
+		model.NotificationTypeDisputeOpened, model.NotificationTypeDisputeResolved,
+		model.NotificationTypeDealCancelled, model.NotificationTypeDealCancelRequested:
 		// Кнопки для сделки: "Перейти к сделке", "Оставить отзыв"
 		buttons = [][]model.TelegramInlineKeyboardButton{
 			{
@@ -324,6 +398,12 @@ func (ns *NotificationService) createInlineKeyboard(notification *model.Notifica
 
 // sendTelegramMessage отправляет сообщение через Telegram Bot API
 func (ns *NotificationService) sendTelegramMessage(message *model.TelegramMessage) error {
+	// Без токена бота (демо-режим) уведомления не отправляются
+	if ns.telegramToken == "" {
+		log.Printf("[DEBUG] Токен бота не задан, уведомление в чат %v не отправлено", message.ChatID)
+		return nil
+	}
+
 	// URL для отправки сообщения через Telegram Bot API
 	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", ns.telegramToken)
 
@@ -455,6 +535,46 @@ func (ns *NotificationService) FormatRejectedResponseNotification(order *model.O
 	return title, message
 }
 
+// FormatWithdrawnResponseNotification форматирует уведомление об отозванном отклике
+func (ns *NotificationService) FormatWithdrawnResponseNotification(order *model.Order, responderName string) (string, string) {
+	template := ns.templates[model.NotificationTypeResponseWithdrawn]
+
+	title := template.Title
+	message := fmt.Sprintf(template.Message,
+		responderName,                       // Кто отозвал отклик
+		order.ID,                            // Номер заявки
+		strings.ToUpper(string(order.Type)), // BUY/SELL
+		order.Cryptocurrency,                // BTC
+		order.FiatCurrency,                  // RUB
+	)
+
+	return title, message
+}
+
+// FormatResponseCounteredNotification форматирует уведомление о встречном предложении по отклику
+func (ns *NotificationService) FormatResponseCounteredNotification(order *model.Order, response *model.Response, fromName, comment string) (string, string) {
+	template := ns.templates[model.NotificationTypeResponseCountered]
+
+	price := response.DealPrice(order)
+	title := template.Title
+	message := fmt.Sprintf(template.Message,
+		fromName,                            // Кто предложил
+		order.ID,                            // Номер заявки
+		strings.ToUpper(string(order.Type)), // BUY/SELL
+		order.Cryptocurrency,                // BTC
+		order.FiatCurrency,                  // RUB
+		response.RequestedAmount,            // 0.005
+		order.Cryptocurrency,                // BTC
+		price,                               // 2850000.00
+		order.FiatCurrency,                  // RUB
+		response.RequestedAmount*price,      // 14250.00
+		order.FiatCurrency,                  // RUB
+		comment,                             // Комментарий к предложению
+	)
+
+	return title, message
+}
+
 // FormatDealCreatedNotification форматирует уведомление о созданной сделке
 func (ns *NotificationService) FormatDealCreatedNotification(deal *model.Deal, counterpartyName string) (string, string) {
 	template := ns.templates[model.NotificationTypeDealCreated]
@@ -509,3 +629,139 @@ func (ns *NotificationService) FormatDealCompletedNotification(deal *model.Deal,
 
 	return title, message
 }
+
+// FormatDealExpiringNotification форматирует предупреждение о скором истечении сделки
+func (ns *NotificationService) FormatDealExpiringNotification(deal *model.Deal) (string, string) {
+	template := ns.templates[model.NotificationTypeDealExpiring]
+
+	title := template.Title
+	message := fmt.Sprintf(template.Message,
+		deal.ID,             // Номер сделки
+		deal.Amount,         // 0.01000000
+		deal.Cryptocurrency, // BTC
+		deal.TotalAmount,    // 28500.00
+		deal.FiatCurrency,   // RUB
+		deal.ExpiresAt.Format("02.01.2006 15:04"), // Крайний срок
+	)
+
+	return title, message
+}
+
+// FormatDealExpiredNotification форматирует уведомление об истекшей сделке
+func (ns *NotificationService) FormatDealExpiredNotification(deal *model.Deal) (string, string) {
+	template := ns.templates[model.NotificationTypeDealExpired]
+
+	title := template.Title
+	message := fmt.Sprintf(template.Message,
+		deal.ID,             // Номер сделки
+		deal.Amount,         // 0.01000000
+		deal.Cryptocurrency, // BTC
+		deal.TotalAmount,    // 28500.00
+		deal.FiatCurrency,   // RUB
+	)
+
+	return title, message
+}
+
+// FormatOrderExpiredNotification форматирует уведомление автору об истекшей заявке
+func (ns *NotificationService) FormatOrderExpiredNotification(order *model.Order) (string, string) {
+	template := ns.templates[model.NotificationTypeOrderExpired]
+
+	// Определяем действие по типу заявки
+	action := "покупку"
+	if order.Type == model.OrderTypeSell {
+		action = "продажу"
+	}
+
+	title := template.Title
+	message := fmt.Sprintf(template.Message,
+		order.ID,             // Номер заявки
+		action,               // покупку/продажу
+		order.Amount,         // 0.01
+		order.Cryptocurrency, // BTC
+		order.Price,          // 2850000.00
+		order.FiatCurrency,   // RUB
+	)
+
+	return title, message
+}
+
+// FormatDisputeOpenedNotification форматирует уведомление об открытии спора
+func (ns *NotificationService) FormatDisputeOpenedNotification(deal *model.Deal, openedByName string) (string, string) {
+	template := ns.templates[model.NotificationTypeDisputeOpened]
+
+	title := template.Title
+	message := fmt.Sprintf(template.Message,
+		openedByName,        // Кто открыл спор
+		deal.ID,             // Номер сделки
+		deal.Amount,         // 0.01000000
+		deal.Cryptocurrency, // BTC
+		deal.TotalAmount,    // 28500.00
+		deal.FiatCurrency,   // RUB
+		deal.DisputeReason,  // Причина спора
+	)
+
+	return title, message
+}
+
+// FormatDisputeResolvedNotification форматирует уведомление о решении спора
+func (ns *NotificationService) FormatDisputeResolvedNotification(deal *model.Deal, winnerName string) (string, string) {
+	template := ns.templates[model.NotificationTypeDisputeResolved]
+
+	outcome := "сделка отменена"
+	if deal.Status == model.DealStatusCompleted {
+		outcome = "сделка завершена"
+	}
+
+	comment := deal.DisputeComment
+	if comment == "" {
+		comment = "—"
+	}
+
+	title := template.Title
+	message := fmt.Sprintf(template.Message,
+		deal.ID,    // Номер сделки
+		winnerName, // В чью пользу решен спор
+		outcome,    // Итог сделки
+		comment,    // Комментарий администратора
+	)
+
+	return title, message
+}
+
+// FormatDealCancelledNotification форматирует уведомление об отмене сделки
+// cancelledBy - описание того, кто отменил сделку ("Иван" или "по взаимному согласию")
+func (ns *NotificationService) FormatDealCancelledNotification(deal *model.Deal, cancelledBy string) (string, string) {
+	template := ns.templates[model.NotificationTypeDealCancelled]
+
+	title := template.Title
+	message := fmt.Sprintf(template.Message,
+		deal.ID,             // Номер сделки
+		deal.Amount,         // 0.01000000
+		deal.Cryptocurrency, // BTC
+		deal.TotalAmount,    // 28500.00
+		deal.FiatCurrency,   // RUB
+		cancelledBy,         // Кто отменил
+		deal.CancelReason,   // Причина отмены
+	)
+
+	return title, message
+}
+
+// FormatDealCancelRequestedNotification форматирует уведомление о запросе отмены сделки
+func (ns *NotificationService) FormatDealCancelRequestedNotification(deal *model.Deal, requestedByName string) (string, string) {
+	template := ns.templates[model.NotificationTypeDealCancelRequested]
+
+	title := template.Title
+	message := fmt.Sprintf(template.Message,
+		requestedByName,     // Кто просит отменить
+		deal.ID,             // Номер сделки
+		deal.Amount,         // 0.01000000
+		deal.Cryptocurrency, // BTC
+		deal.TotalAmount,    // 28500.00
+		deal.FiatCurrency,   // RUB
+		deal.CancelReason,   // Причина отмены
+	)
+
+	return title, message
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"p2pTG-crypto-exchange/internal/model"
+)
+
+const (
+	// defaultOrderExpirationHours срок действия заявки, если ORDER_EXPIRATION_HOURS не задан (7 дней)
+	defaultOrderExpirationHours = 168
+
+	// maxOrderTTLHours максимальный срок действия заявки, который может указать пользователь (30 дней)
+	maxOrderTTLHours = 720
+)
+
+// orderTTL возвращает срок действия заявки
+// ttlHours - срок, указанный пользователем; 0 означает срок по умолчанию из OrderExpirationHours
+func (s *Service) orderTTL(ttlHours int) time.Duration {
+	if ttlHours > 0 {
+		if ttlHours > maxOrderTTLHours {
+			ttlHours = maxOrderTTLHours
+		}
+		return time.Duration(ttlHours) * time.Hour
+	}
+	if s.business.OrderExpirationHours > 0 {
+		return time.Duration(s.business.OrderExpirationHours) * time.Hour
+	}
+	return defaultOrderExpirationHours * time.Hour
+}
+
+// validateOrderTTL проверяет срок действия заявки, указанный пользователем
+func validateOrderTTL(ttlHours int) error {
+	if ttlHours < 0 || ttlHours > maxOrderTTLHours {
+		return fmt.Errorf("срок действия заявки должен быть от 1 до %d часов", maxOrderTTLHours)
+	}
+	return nil
+}
+
+// StartOrderExpiryWorker запускает фоновую проверку сроков заявок
+// Работает до отмены контекста, проверка выполняется раз в interval
+func (s *Service) StartOrderExpiryWorker(ctx context.Context, interval time.Duration) {
+	s.logf("[INFO] Запуск проверки сроков заявок (интервал %s, срок по умолчанию %s)",
+		interval, s.orderTTL(0))
+
+	s.goWorker(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				s.logln("[INFO] Проверка сроков заявок остановлена")
+				return
+			case now := <-ticker.C:
+				s.ProcessExpiredOrders(now)
+			}
+		}
+	})
+}
+
+// ProcessExpiredOrders выполняет один проход проверки сроков заявок:
+// снимает с биржи просроченные заявки, отклоняет ожидающие отклики и уведомляет авторов
+func (s *Service) ProcessExpiredOrders(now time.Time) {
+	orders, err := s.repo.GetExpiredOrders(now)
+	if err != nil {
+		s.logf("[ERROR] Не удалось получить истекшие заявки: %v", err)
+		return
+	}
+
+	for _, order := range orders {
+		s.expireOrder(order, now)
+	}
+}
+
+// expireOrder снимает просроченную заявку с биржи
+func (s *Service) expireOrder(order *model.Order, now time.Time) {
+	// Репозиторий повторно проверяет статус и срок, поэтому продление в последний момент не потеряется
+	if err := s.repo.ExpireOrder(order.ID, now); err != nil {
+		s.logf("[WARN] Заявка ID=%d не снята по сроку: %v", order.ID, err)
+		return
+	}
+	order.Status = model.OrderStatusExpired
+	order.IsActive = false
+
+	// Отклоняем все ожидающие отклики - заявка больше не доступна
+	responses, err := s.repo.GetResponsesForOrder(order.ID)
+	if err != nil {
+		s.logf("[WARN] Не удалось получить отклики истекшей заявки ID=%d: %v", order.ID, err)
+	}
+	for _, response := range responses {
+		if !response.IsOpen() {
+			continue
+		}
+		if err := s.repo.UpdateResponseStatus(response.ID, model.ResponseStatusRejected); err != nil {
+			s.logf("[WARN] Не удалось отклонить отклик ID=%d: %v", response.ID, err)
+			continue
+		}
+		s.goNotify(func() { s.sendResponseRejectedNotification(order, response) })
+	}
+
+	s.goNotify(func() { s.sendOrderExpiredNotification(order) })
+}
+
+// ExtendOrder продлевает срок действия заявки, пока она еще находится на бирже
+// hours - новый срок от текущего момента; 0 означает срок по умолчанию
+func (s *Service) ExtendOrder(userID, orderID int64, hours int) (*model.Order, error) {
+	s.logf("[INFO] Продление заявки ID=%d пользователем ID=%d на %d ч", orderID, userID, hours)
+
+	if err := validateOrderTTL(hours); err != nil {
+		return nil, err
+	}
+
+	order, err := s.repo.GetOrderByID(orderID)
+	if err != nil {
+		s.logf("[ERROR] Заявка ID=%d не найдена: %v", orderID, err)
+		return nil, fmt.Errorf("заявка не найдена")
+	}
+
+	if order.UserID != userID {
+		s.logf("[WARN] Пользователь ID=%d пытается продлить чужую заявку ID=%d", userID, orderID)
+		return nil, fmt.Errorf("можно продлевать только свои заявки")
+	}
+
+	if order.Status != model.OrderStatusActive && order.Status != model.OrderStatusHasResponses {
+		return nil, fmt.Errorf("можно продлить только заявку на бирже (текущий статус: %s)", order.Status)
+	}
+
+	now := time.Now()
+	if !order.ExpiresAt.After(now) {
+		return nil, fmt.Errorf("срок действия заявки уже истек")
+	}
+
+	expiresAt := now.Add(s.orderTTL(hours))
+	if err := s.repo.UpdateOrderExpiration(orderID, expiresAt); err != nil {
+		s.logf("[ERROR] Не удалось продлить заявку ID=%d: %v", orderID, err)
+		return nil, fmt.Errorf("не удалось продлить заявку: %w", err)
+	}
+
+	order.ExpiresAt = expiresAt
+	order.UpdatedAt = now
+
+	s.logf("[INFO] Заявка ID=%d продлена до %s", orderID, expiresAt.Format(time.RFC3339))
+	return order, nil
+}
+
+// sendOrderExpiredNotification уведомляет автора о снятии заявки по сроку
+func (s *Service) sendOrderExpiredNotification(order *model.Order) {
+	author, err := s.repo.GetUserByID(order.UserID)
+	if err != nil {
+		s.logf("[ERROR] Не удалось найти автора заявки ID=%d: %v", order.UserID, err)
+		return
+	}
+
+	title, message := s.notificationService.FormatOrderExpiredNotification(order)
+
+	notification, err := s.notificationService.CreateNotification(&model.CreateNotificationRequest{
+		UserID:  author.ID,
+		Type:    model.NotificationTypeOrderExpired,
+		Title:   title,
+		Message: message,
+		OrderID: &order.ID,
+		Data: map[string]interface{}{
+			"order_id":       order.ID,
+			"order_type":     string(order.Type),
+			"cryptocurrency": order.Cryptocurrency,
+			"fiat_currency":  order.FiatCurrency,
+			"amount":         order.Amount,
+			"expires_at":     order.ExpiresAt,
+		},
+	})
+	if err != nil {
+		s.logf("[ERROR] Не удалось создать уведомление об истекшей заявке: %v", err)
+		return
+	}
+
+	if err := s.notificationService.SendNotification(notification, author.TelegramID); err != nil {
+		s.logf("[ERROR] Не удалось отправить уведомление об истекшей заявке: %v", err)
+		return
+	}
+
+	s.logf("[INFO] Уведомление об истекшей заявке ID=%d отправлено пользователю TelegramID=%d",
+		order.ID, author.TelegramID)
+}
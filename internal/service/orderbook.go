@@ -0,0 +1,39 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"p2pTG-crypto-exchange/internal/model"
+)
+
+// GetOrderBook возвращает стакан заявок по торговой паре: уровни покупки и продажи,
+// сгруппированные по цене, лучшие цены и спред
+func (s *Service) GetOrderBook(filter *model.OrderBookFilter) (*model.OrderBook, error) {
+	filter.Cryptocurrency = strings.ToUpper(strings.TrimSpace(filter.Cryptocurrency))
+	filter.FiatCurrency = strings.ToUpper(strings.TrimSpace(filter.FiatCurrency))
+	filter.PaymentMethod = strings.TrimSpace(filter.PaymentMethod)
+
+	if filter.Cryptocurrency == "" || filter.FiatCurrency == "" {
+		return nil, fmt.Errorf("укажите криптовалюту и фиатную валюту")
+	}
+
+	// Глубина стакана: по умолчанию DefaultOrderBookDepth уровней, не больше MaxOrderBookDepth
+	if filter.Depth <= 0 {
+		filter.Depth = model.DefaultOrderBookDepth
+	}
+	if filter.Depth > model.MaxOrderBookDepth {
+		filter.Depth = model.MaxOrderBookDepth
+	}
+
+	book, err := s.repo.GetOrderBook(filter)
+	if err != nil {
+		s.logf("[ERROR] Не удалось построить стакан %s/%s: %v", filter.Cryptocurrency, filter.FiatCurrency, err)
+		return nil, fmt.Errorf("не удалось получить стакан заявок: %w", err)
+	}
+	book.CalculateSpread()
+
+	s.logf("[INFO] Стакан %s/%s: уровней покупки %d, продажи %d",
+		book.Cryptocurrency, book.FiatCurrency, len(book.Bids), len(book.Asks))
+	return book, nil
+}
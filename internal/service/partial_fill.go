@@ -0,0 +1,91 @@
+package service
+
+import (
+	"fmt"
+
+	"p2pTG-crypto-exchange/internal/model"
+	"p2pTG-crypto-exchange/internal/repository"
+)
+
+// resolveFillAmount определяет объем сделки по заявке и проверяет его по лимитам заявки.
+// requested = 0 означает весь остаток заявки. Сумма сделки (amount * price) по цене сделки
+// должна быть в пределах MinAmount/MaxAmount; остаток меньше минимальной суммы можно взять целиком
+func resolveFillAmount(order *model.Order, requested, price float64) (float64, error) {
+	if requested < 0 {
+		return 0, fmt.Errorf("количество не может быть отрицательным")
+	}
+	if order.IsFilled() {
+		return 0, fmt.Errorf("весь объем заявки уже занят сделками")
+	}
+
+	amount := model.RoundAmount(requested)
+	if amount == 0 {
+		amount = order.RemainingAmount
+	}
+	if amount > order.RemainingAmount+model.AmountEpsilon {
+		return 0, fmt.Errorf("в заявке доступно только %.8g %s", order.RemainingAmount, order.Cryptocurrency)
+	}
+
+	total := amount * price
+	wholeRemainder := amount >= order.RemainingAmount-model.AmountEpsilon
+	if !wholeRemainder && order.MinAmount > 0 && total < order.MinAmount-model.AmountEpsilon {
+		return 0, fmt.Errorf("сумма сделки %.2f %s меньше минимальной %.2f %s",
+			total, order.FiatCurrency, order.MinAmount, order.FiatCurrency)
+	}
+	if order.MaxAmount > 0 && total > order.MaxAmount+model.AmountEpsilon {
+		return 0, fmt.Errorf("сумма сделки %.2f %s больше максимальной %.2f %s",
+			total, order.FiatCurrency, order.MaxAmount, order.FiatCurrency)
+	}
+
+	return amount, nil
+}
+
+// completeFilledOrder завершает заявки сделки, когда весь их объем исполнен:
+// остатка нет и по заявке не осталось активных сделок
+func (s *Service) completeFilledOrder(repo repository.RepositoryInterface, deal *model.Deal) error {
+	for _, orderID := range deal.OrderIDs() {
+		if err := s.completeOrderIfFilled(repo, orderID, deal.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// completeOrderIfFilled завершает заявку, если ее объем исполнен и активных сделок по ней нет
+func (s *Service) completeOrderIfFilled(repo repository.RepositoryInterface, orderID, dealID int64) error {
+	order, err := repo.GetOrderByID(orderID)
+	if err != nil {
+		return fmt.Errorf("не удалось получить заявку ID=%d сделки ID=%d: %w", orderID, dealID, err)
+	}
+	if order.Status != model.OrderStatusInDeal || !order.IsFilled() {
+		return nil
+	}
+
+	// Автор заявки участвует во всех ее сделках: как автор сделки или как контрагент при автосопоставлении
+	deals, err := repo.GetDealsByUserID(order.UserID)
+	if err != nil {
+		return fmt.Errorf("не удалось получить сделки по заявке ID=%d: %w", order.ID, err)
+	}
+	for _, other := range deals {
+		if (other.OrderID == order.ID || other.MatchedOrderID == order.ID) && !other.Status.IsFinal() {
+			s.logf("[DEBUG] Заявка ID=%d ждет завершения сделки ID=%d", order.ID, other.ID)
+			return nil
+		}
+	}
+
+	if err := repo.UpdateOrderStatus(order.ID, model.OrderStatusCompleted); err != nil {
+		return fmt.Errorf("не удалось завершить заявку ID=%d: %w", order.ID, err)
+	}
+	s.logf("[INFO] Заявка ID=%d полностью исполнена", order.ID)
+	return nil
+}
+
+// releaseDealAmount возвращает объем закрытой без исполнения сделки в ее заявки
+func releaseDealAmount(repo repository.RepositoryInterface, deal *model.Deal) error {
+	for _, orderID := range deal.OrderIDs() {
+		if err := repo.ReleaseOrderAmount(orderID, deal.Amount); err != nil {
+			return fmt.Errorf("не удалось вернуть объем сделки ID=%d в заявку ID=%d: %w", deal.ID, orderID, err)
+		}
+	}
+	return nil
+}
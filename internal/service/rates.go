@@ -0,0 +1,197 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"p2pTG-crypto-exchange/internal/model"
+)
+
+// =====================================================
+// ИСТОЧНИКИ КУРСОВ ДЛЯ ПЛАВАЮЩИХ ЦЕН
+// =====================================================
+
+// DefaultRateCacheTTL время, в течение которого загруженные курсы считаются актуальными
+const DefaultRateCacheTTL = 30 * time.Second
+
+// DefaultRateMaxAge максимальный возраст курсов, которые еще можно использовать, если источник недоступен
+const DefaultRateMaxAge = 10 * time.Minute
+
+// maxRateRetryDelay предельная пауза между попытками обновления при недоступном источнике
+const maxRateRetryDelay = 5 * time.Minute
+
+// RateProvider источник опорного курса торговой пары для заявок с плавающей ценой
+type RateProvider interface {
+	// GetRate возвращает курс криптовалюты в фиатной валюте (цена за 1 единицу)
+	GetRate(crypto, fiat string) (float64, error)
+}
+
+// CachedRateProvider источник курсов, загружающий таблицу курсов целиком и кэширующий ее на ttl.
+// Формат таблицы - JSON-объект пар и курсов: {"USDT/RUB": 92.5, "BTC-RUB": 6100000}.
+// Таблицу обновляет один вызов и вне блокировки; остальные тем временем получают загруженные ранее курсы.
+// Если обновить таблицу не удалось, попытки повторяются с нарастающей паузой (от ttl до maxRateRetryDelay),
+// а прежние курсы используются, пока им не больше maxAge
+type CachedRateProvider struct {
+	source      string                 // Описание источника для логов
+	load        func() ([]byte, error) // Загрузка таблицы курсов
+	ttl         time.Duration          // Время жизни кэша
+	maxAge      time.Duration          // Предельный возраст курсов при недоступном источнике
+	mutex       sync.Mutex             // Защита полей ниже
+	rates       map[string]float64     // Курсы по ключу "CRYPTO/FIAT"
+	loaded      time.Time              // Время последней успешной загрузки
+	lastAttempt time.Time              // Время последней попытки загрузки
+	lastErr     error                  // Ошибка последней попытки (nil - успешна)
+	failures    int                    // Неудачных попыток подряд
+	refreshing  bool                   // Загрузка выполняется другим вызовом
+}
+
+// NewFileRateProvider создает источник курсов из JSON-файла
+// Файл перечитывается не чаще раза в ttl, поэтому его можно менять без перезапуска
+func NewFileRateProvider(path string, ttl time.Duration) *CachedRateProvider {
+	return newCachedRateProvider("файл "+path, ttl, func() ([]byte, error) {
+		return os.ReadFile(path)
+	})
+}
+
+// NewHTTPRateProvider создает источник курсов, получающий JSON-таблицу курсов GET-запросом по url
+func NewHTTPRateProvider(url string, ttl time.Duration) *CachedRateProvider {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return newCachedRateProvider("URL "+url, ttl, func() ([]byte, error) {
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("источник курсов ответил статусом %d", resp.StatusCode)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	})
+}
+
+// newCachedRateProvider создает кэширующий источник курсов с заданной функцией загрузки
+func newCachedRateProvider(source string, ttl time.Duration, load func() ([]byte, error)) *CachedRateProvider {
+	if ttl <= 0 {
+		ttl = DefaultRateCacheTTL
+	}
+	return &CachedRateProvider{source: source, load: load, ttl: ttl, maxAge: DefaultRateMaxAge}
+}
+
+// SetMaxAge задает предельный возраст курсов; курсы старше не используются, даже если новых нет.
+// Значение меньше ttl заменяется на ttl
+func (p *CachedRateProvider) SetMaxAge(maxAge time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if maxAge < p.ttl {
+		maxAge = p.ttl
+	}
+	p.maxAge = maxAge
+}
+
+// GetRate возвращает курс пары, при необходимости обновляя таблицу курсов
+func (p *CachedRateProvider) GetRate(crypto, fiat string) (float64, error) {
+	p.mutex.Lock()
+	if p.needsRefresh(time.Now()) {
+		p.refreshing = true
+		p.lastAttempt = time.Now()
+		p.mutex.Unlock()
+
+		// Загружаем без блокировки: медленный источник не задерживает остальные вызовы
+		rates, err := p.fetch()
+
+		p.mutex.Lock()
+		p.storeRefresh(rates, err)
+	}
+	defer p.mutex.Unlock()
+
+	if p.rates == nil {
+		if p.lastErr != nil {
+			return 0, fmt.Errorf("не удалось загрузить курсы: %w", p.lastErr)
+		}
+		return 0, fmt.Errorf("курсы еще загружаются")
+	}
+	if age := time.Since(p.loaded); age > p.maxAge {
+		return 0, fmt.Errorf("курсы устарели: последнее обновление %s назад (%s)", age.Round(time.Second), p.source)
+	}
+
+	rate, ok := p.rates[crypto+"/"+fiat]
+	if !ok {
+		return 0, fmt.Errorf("нет курса для пары %s/%s", crypto, fiat)
+	}
+	return rate, nil
+}
+
+// needsRefresh проверяет, пора ли загружать курсы (вызывается под mutex):
+// кэш устарел, никто другой его не обновляет и после неудачной попытки выдержана пауза
+func (p *CachedRateProvider) needsRefresh(now time.Time) bool {
+	if p.refreshing {
+		return false
+	}
+	if p.rates != nil && now.Sub(p.loaded) <= p.ttl {
+		return false
+	}
+	return p.failures == 0 || now.Sub(p.lastAttempt) >= p.retryDelay()
+}
+
+// retryDelay пауза перед следующей попыткой после failures неудач подряд: ttl, 2*ttl, 4*ttl... до maxRateRetryDelay
+func (p *CachedRateProvider) retryDelay() time.Duration {
+	delay := p.ttl
+	for i := 1; i < p.failures && delay < maxRateRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRateRetryDelay)
+}
+
+// fetch загружает и разбирает таблицу курсов
+func (p *CachedRateProvider) fetch() (map[string]float64, error) {
+	data, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+	return parseRates(data)
+}
+
+// storeRefresh сохраняет результат загрузки (вызывается под mutex)
+func (p *CachedRateProvider) storeRefresh(rates map[string]float64, err error) {
+	p.refreshing = false
+	p.lastErr = err
+	if err != nil {
+		p.failures++
+		log.Printf("[WARN] Не удалось обновить курсы (%s), следующая попытка через %s: %v",
+			p.source, p.retryDelay(), err)
+		return
+	}
+
+	p.rates = rates
+	p.loaded = time.Now()
+	p.failures = 0
+	log.Printf("[DEBUG] Загружены курсы (%s): пар %d", p.source, len(rates))
+}
+
+// parseRates разбирает JSON-таблицу курсов и приводит пары к виду "CRYPTO/FIAT"
+func parseRates(data []byte) (map[string]float64, error) {
+	var raw map[string]float64
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("неверный формат таблицы курсов: %w", err)
+	}
+
+	rates := make(map[string]float64, len(raw))
+	for pair, rate := range raw {
+		crypto, fiat, err := model.ParseTradingPair(pair)
+		if err != nil {
+			return nil, err
+		}
+		if rate <= 0 {
+			return nil, fmt.Errorf("курс пары %s должен быть больше нуля", pair)
+		}
+		rates[crypto+"/"+fiat] = rate
+	}
+	return rates, nil
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testRateSource источник курсов для тестов: считает загрузки и может отказывать или зависать
+type testRateSource struct {
+	calls   atomic.Int32
+	fail    atomic.Bool
+	release chan struct{} // Если задан, загрузка ждет закрытия канала
+}
+
+func (src *testRateSource) load() ([]byte, error) {
+	src.calls.Add(1)
+	if src.release != nil {
+		<-src.release
+	}
+	if src.fail.Load() {
+		return nil, errors.New("источник недоступен")
+	}
+	return []byte(`{"USDT/RUB": 95.5}`), nil
+}
+
+func newTestRateProvider(t *testing.T, src *testRateSource, ttl time.Duration) *CachedRateProvider {
+	t.Helper()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return newCachedRateProvider("тест", ttl, src.load)
+}
+
+// age сдвигает время последней загрузки и попытки в прошлое
+func (p *CachedRateProvider) age(d time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.loaded = p.loaded.Add(-d)
+	p.lastAttempt = p.lastAttempt.Add(-d)
+}
+
+func TestCachedRateProviderCachesForTTL(t *testing.T) {
+	src := &testRateSource{}
+	p := newTestRateProvider(t, src, time.Minute)
+
+	for i := 0; i < 5; i++ {
+		rate, err := p.GetRate("USDT", "RUB")
+		if err != nil || rate != 95.5 {
+			t.Fatalf("курс %v, ошибка %v", rate, err)
+		}
+	}
+	if calls := src.calls.Load(); calls != 1 {
+		t.Fatalf("в пределах ttl таблица загружена %d раз", calls)
+	}
+	if _, err := p.GetRate("BTC", "RUB"); err == nil {
+		t.Fatal("курс неизвестной пары должен возвращать ошибку")
+	}
+}
+
+func TestCachedRateProviderBacksOffOnFailure(t *testing.T) {
+	src := &testRateSource{}
+	src.fail.Store(true)
+	p := newTestRateProvider(t, src, time.Minute)
+
+	// Недоступный источник опрашивается один раз, а не при каждом вызове
+	for i := 0; i < 10; i++ {
+		if _, err := p.GetRate("USDT", "RUB"); err == nil {
+			t.Fatal("без загруженных курсов ожидалась ошибка")
+		}
+	}
+	if calls := src.calls.Load(); calls != 1 {
+		t.Fatalf("после отказа источника загрузок %d, ожидалась 1", calls)
+	}
+
+	// После паузы ttl - повторная попытка, следующая пауза удваивается
+	p.age(time.Minute)
+	p.GetRate("USDT", "RUB")
+	if calls := src.calls.Load(); calls != 2 {
+		t.Fatalf("после паузы загрузок %d, ожидалось 2", calls)
+	}
+	p.age(time.Minute)
+	p.GetRate("USDT", "RUB")
+	if calls := src.calls.Load(); calls != 2 {
+		t.Fatalf("вторая пауза должна быть 2*ttl, загрузок %d", calls)
+	}
+	p.age(time.Minute)
+	src.fail.Store(false)
+	if rate, err := p.GetRate("USDT", "RUB"); err != nil || rate != 95.5 {
+		t.Fatalf("после восстановления источника курс %v, ошибка %v", rate, err)
+	}
+
+	// Успешная загрузка сбрасывает паузу
+	if delay := p.retryDelay(); delay != time.Minute {
+		t.Fatalf("пауза после успешной загрузки %s", delay)
+	}
+}
+
+func TestCachedRateProviderRetryDelayCapped(t *testing.T) {
+	p := newTestRateProvider(t, &testRateSource{}, time.Minute)
+	p.failures = 20
+	if delay := p.retryDelay(); delay != maxRateRetryDelay {
+		t.Fatalf("пауза %s, ожидалось не больше %s", delay, maxRateRetryDelay)
+	}
+}
+
+func TestCachedRateProviderServesStaleWhileRefreshing(t *testing.T) {
+	src := &testRateSource{}
+	p := newTestRateProvider(t, src, time.Minute)
+	if _, err := p.GetRate("USDT", "RUB"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Следующая загрузка зависает; пока она идет, остальные вызовы получают прежний курс без ожидания
+	src.release = make(chan struct{})
+	p.age(2 * time.Minute)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.GetRate("USDT", "RUB")
+	}()
+	for src.calls.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.GetRate("USDT", "RUB")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("вызов ждет загрузку курсов, которую выполняет другой вызов")
+	}
+
+	close(src.release)
+	wg.Wait()
+	if calls := src.calls.Load(); calls != 2 {
+		t.Fatalf("загрузок %d, ожидалось 2", calls)
+	}
+}
+
+func TestCachedRateProviderMaxAge(t *testing.T) {
+	src := &testRateSource{}
+	p := newTestRateProvider(t, src, time.Minute)
+	p.SetMaxAge(10 * time.Minute)
+	if _, err := p.GetRate("USDT", "RUB"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Источник недоступен: в пределах maxAge используется прежний курс
+	src.fail.Store(true)
+	p.age(5 * time.Minute)
+	if rate, err := p.GetRate("USDT", "RUB"); err != nil || rate != 95.5 {
+		t.Fatalf("устаревший в пределах maxAge курс %v, ошибка %v", rate, err)
+	}
+
+	// Курсы старше maxAge не используются
+	p.age(6 * time.Minute)
+	_, err := p.GetRate("USDT", "RUB")
+	expectError(t, err, "курсы устарели")
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"context"
+
+	"p2pTG-crypto-exchange/internal/logging"
+)
+
+// =====================================================
+// ЛОГИ СЕРВИСА С ПОЛЯМИ ЗАПРОСА
+// =====================================================
+
+// WithContext возвращает сервис для обработки одного запроса: записи его логов получают поля
+// запроса из ctx (request_id, user_id, deal_id, order_id). Настройки, репозиторий и фоновые задачи общие
+func (s *Service) WithContext(ctx context.Context) *Service {
+	scoped := *s
+	scoped.ctx = ctx
+	return &scoped
+}
+
+// logf записывает сообщение в формате стандартного log ("[INFO] ...") с полями запроса сервиса
+func (s *Service) logf(format string, args ...any) {
+	logging.PrintfDepth(s.ctx, 1, format, args...)
+}
+
+// logln записывает сообщение как log.Println с полями запроса сервиса
+func (s *Service) logln(args ...any) {
+	logging.PrintlnDepth(s.ctx, 1, args...)
+}
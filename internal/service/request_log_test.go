@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"p2pTG-crypto-exchange/internal/logging"
+	"p2pTG-crypto-exchange/internal/model"
+)
+
+func TestWithContextAddsRequestFieldsToServiceLogs(t *testing.T) {
+	s, _, seller, _ := newTestService(t)
+
+	// Логи приложения пишутся в файл так же, как при запуске сервера
+	path := filepath.Join(t.TempDir(), "app.log")
+	previous := slog.Default()
+	logs, err := logging.Setup(model.LoggingConfig{Level: "debug", Format: "text", OutputPath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		logs.Close()
+		slog.SetDefault(previous)
+		log.SetFlags(log.LstdFlags)
+		log.SetOutput(io.Discard)
+	})
+
+	ctx := logging.WithFields(context.Background(), "request_id", "req-7")
+	logging.AddFields(ctx, "user_id", seller.ID)
+	if _, err := s.WithContext(ctx).GetUserByTelegramID(seller.TelegramID); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("сервис записал %d строк логов", len(lines))
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, "request_id=req-7") || !strings.Contains(line, "user_id=1") {
+			t.Errorf("запись без полей запроса: %s", line)
+		}
+	}
+
+	// Исходный сервис контекст запроса не получает
+	if s.ctx != nil {
+		t.Error("WithContext изменил общий сервис")
+	}
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"p2pTG-crypto-exchange/internal/model"
+)
+
+// maxOfferMessageLength максимальная длина комментария к встречному предложению
+const maxOfferMessageLength = 500
+
+// CounterResponse отвечает на отклик встречным предложением цены и/или объема.
+// Стороны ходят по очереди: на отклик в статусе waiting отвечает автор заявки (отклик переходит в countered),
+// на встречное предложение автора - откликнувшийся (отклик снова ожидает автора).
+// Принятые условия становятся условиями сделки
+func (s *Service) CounterResponse(responseID, userID int64, req *model.CounterResponseRequest) (*model.Response, error) {
+	s.logf("[INFO] Встречное предложение по отклику ID=%d от пользователя ID=%d", responseID, userID)
+
+	if req.Price < 0 || req.Amount < 0 {
+		return nil, fmt.Errorf("цена и количество не могут быть отрицательными")
+	}
+	message := strings.TrimSpace(req.Message)
+	if len([]rune(message)) > maxOfferMessageLength {
+		return nil, fmt.Errorf("комментарий не должен превышать %d символов", maxOfferMessageLength)
+	}
+
+	response, err := s.getResponseByID(responseID)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := s.GetOrder(response.OrderID)
+	if err != nil {
+		return nil, fmt.Errorf("заявка не найдена: %w", err)
+	}
+	if order.Status != model.OrderStatusActive && order.Status != model.OrderStatusHasResponses {
+		return nil, fmt.Errorf("заявка недоступна для сделок")
+	}
+
+	if err := checkResponseTurn(order, response, userID); err != nil {
+		return nil, err
+	}
+
+	// Нулевые поля оставляют текущие условия
+	currentPrice := response.DealPrice(order)
+	price := currentPrice
+	if req.Price > 0 {
+		price = req.Price
+	}
+	amount := response.RequestedAmount
+	if req.Amount > 0 {
+		amount = model.RoundAmount(req.Amount)
+	}
+	if price == currentPrice && amount == response.RequestedAmount {
+		return nil, fmt.Errorf("встречное предложение должно менять цену или количество")
+	}
+
+	amount, err = resolveFillAmount(order, amount, price)
+	if err != nil {
+		return nil, err
+	}
+
+	// После хода автора очередь переходит к откликнувшемуся и наоборот
+	status := model.ResponseStatusWaiting
+	recipientID := order.UserID
+	if userID == order.UserID {
+		status = model.ResponseStatusCountered
+		recipientID = response.UserID
+	}
+
+	if err := s.repo.UpdateResponseTerms(response.ID, status, price, amount); err != nil {
+		s.logf("[ERROR] Не удалось сохранить встречное предложение по отклику ID=%d: %v", response.ID, err)
+		return nil, fmt.Errorf("не удалось сохранить встречное предложение: %w", err)
+	}
+	response.Status = status
+	response.ProposedPrice = price
+	response.RequestedAmount = amount
+
+	s.recordResponseOffer(response, userID, price, amount, message)
+	s.attachResponseOffers([]*model.Response{response})
+
+	s.goNotify(func() { s.sendResponseCounteredNotification(order, response, userID, recipientID, message) })
+
+	s.logf("[INFO] Отклик ID=%d: предложено %.8f %s по %.2f %s, статус %s",
+		response.ID, amount, order.Cryptocurrency, price, order.FiatCurrency, status)
+	return response, nil
+}
+
+// checkResponseTurn проверяет, что пользователь может ответить на отклик сейчас:
+// отклик в статусе waiting ждет автора заявки, в статусе countered - откликнувшегося
+func checkResponseTurn(order *model.Order, response *model.Response, userID int64) error {
+	if !response.IsOpen() {
+		return fmt.Errorf("отклик уже был рассмотрен")
+	}
+	if userID != order.UserID && userID != response.UserID {
+		return fmt.Errorf("вы не участвуете в этом отклике")
+	}
+	if response.Status == model.ResponseStatusWaiting && userID != order.UserID {
+		return fmt.Errorf("отклик ожидает ответа автора заявки")
+	}
+	if response.Status == model.ResponseStatusCountered && userID != response.UserID {
+		return fmt.Errorf("встречное предложение ожидает ответа откликнувшегося")
+	}
+	return nil
+}
+
+// getResponseByID находит отклик по ID
+func (s *Service) getResponseByID(responseID int64) (*model.Response, error) {
+	response, err := s.repo.GetResponseByID(responseID)
+	if err != nil {
+		return nil, fmt.Errorf("отклик не найден: %w", err)
+	}
+	return response, nil
+}
+
+// recordResponseOffer сохраняет предложение в истории переговоров по отклику
+// Ошибка не прерывает операцию - история носит справочный характер
+func (s *Service) recordResponseOffer(response *model.Response, userID int64, price, amount float64, message string) {
+	offer := &model.ResponseOffer{
+		ResponseID: response.ID,
+		UserID:     userID,
+		Price:      price,
+		Amount:     amount,
+		Message:    message,
+	}
+	if err := s.repo.AddResponseOffer(offer); err != nil {
+		s.logf("[WARN] Не удалось сохранить предложение по отклику ID=%d: %v", response.ID, err)
+	}
+}
+
+// attachResponseOffers добавляет к откликам историю переговоров
+func (s *Service) attachResponseOffers(responses []*model.Response) {
+	for _, response := range responses {
+		offers, err := s.repo.GetResponseOffers(response.ID)
+		if err != nil {
+			s.logf("[WARN] Не удалось получить историю предложений по отклику ID=%d: %v", response.ID, err)
+			continue
+		}
+		response.Offers = offers
+	}
+}
+
+// sendResponseCounteredNotification уведомляет вторую сторону о встречном предложении
+func (s *Service) sendResponseCounteredNotification(order *model.Order, response *model.Response, fromUserID, recipientID int64, comment string) {
+	from, err := s.repo.GetUserByID(fromUserID)
+	if err != nil {
+		s.logf("[ERROR] Не удалось найти автора предложения ID=%d: %v", fromUserID, err)
+		return
+	}
+
+	recipient, err := s.repo.GetUserByID(recipientID)
+	if err != nil {
+		s.logf("[ERROR] Не удалось найти получателя предложения ID=%d: %v", recipientID, err)
+		return
+	}
+
+	fromName := from.FirstName
+	if from.LastName != "" {
+		fromName += " " + from.LastName
+	}
+
+	title, message := s.notificationService.FormatResponseCounteredNotification(order, response, fromName, comment)
+
+	notification, err := s.notificationService.CreateNotification(&model.CreateNotificationRequest{
+		UserID:     recipient.ID,
+		Type:       model.NotificationTypeResponseCountered,
+		Title:      title,
+		Message:    message,
+		OrderID:    &order.ID,
+		ResponseID: &response.ID,
+		Data: map[string]interface{}{
+			"order_id":       order.ID,
+			"response_id":    response.ID,
+			"cryptocurrency": order.Cryptocurrency,
+			"fiat_currency":  order.FiatCurrency,
+			"amount":         response.RequestedAmount,
+			"price":          response.ProposedPrice,
+			"status":         string(response.Status),
+		},
+	})
+	if err != nil {
+		s.logf("[ERROR] Не удалось создать уведомление о встречном предложении: %v", err)
+		return
+	}
+
+	if err := s.notificationService.SendNotification(notification, recipient.TelegramID); err != nil {
+		s.logf("[ERROR] Не удалось отправить уведомление о встречном предложении: %v", err)
+		return
+	}
+
+	s.logf("[INFO] Уведомление о встречном предложении по отклику ID=%d отправлено пользователю TelegramID=%d",
+		response.ID, recipient.TelegramID)
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -8,7 +9,11 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"p2pTG-crypto-exchange/internal/model"
@@ -37,6 +42,16 @@ type Service struct {
 	groupTopicID        string                         // ID темы в групповом чате (необязательно)
 	httpClient          *http.Client                   // HTTP клиент для запросов к Telegram Bot API
 	notificationService *NotificationService           // Сервис уведомлений для отправки сообщений в Telegram
+	sessionSecret       []byte                         // Ключ подписи сессионных токенов
+	sessionExpiration   time.Duration                  // Время жизни сессионного токена
+	business            model.BusinessConfig           // Бизнес-настройки (сроки сделок и заявок)
+	adminTelegramIDs    map[int64]bool                 // Telegram ID администраторов (арбитраж споров)
+	rateProvider        RateProvider                   // Источник курсов для плавающих цен (необязательно)
+	assets              *model.AssetRegistry           // Реестр криптовалют, фиатных валют и способов оплаты
+	workers             *sync.WaitGroup                // Запущенные фоновые обработчики
+	notifications       *sync.WaitGroup                // Отправляемые в фоне уведомления
+	ctx                 context.Context                // Контекст запроса для полей логов (nil - вне запроса, см. WithContext)
+	demo                bool                           // Демо-режим: вход под демонстрационными пользователями без Telegram
 }
 
 // NewService создает новый экземпляр сервиса (для обратной совместимости)
@@ -69,9 +84,81 @@ func NewServiceWithGroup(repo repository.RepositoryInterface, telegramToken, cha
 		groupTopicID:        groupTopicID,
 		httpClient:          &http.Client{Timeout: 10 * time.Second}, // HTTP клиент с таймаутом 10 сек
 		notificationService: notificationService,
+		sessionSecret:       randomSessionSecret(), // Переопределяется через SetSecurityConfig
+		sessionExpiration:   defaultSessionExpiration,
+		assets:              defaultAssetRegistry(), // Переопределяется через LoadAssetRegistry
+		workers:             &sync.WaitGroup{},
+		notifications:       &sync.WaitGroup{},
 	}
 }
 
+// NewServiceFromConfig создает сервис по загруженной конфигурации приложения:
+// Telegram, сессионные токены, бизнес-настройки, реестр активов, администраторы и источник курсов
+func NewServiceFromConfig(repo repository.RepositoryInterface, cfg *model.Config) (*Service, error) {
+	tg := cfg.Telegram
+	if cfg.Demo {
+		// Демо-режим не обращается к Telegram: без токена вход только через DemoLogin, уведомления не отправляются
+		tg.BotToken = ""
+	}
+	s := NewServiceWithGroup(repo, tg.BotToken, strconv.FormatInt(tg.ChatID, 10), tg.WebAppURL,
+		formatOptionalID(tg.GroupChatID), formatOptionalID(tg.GroupTopicID))
+	s.SetDemoMode(cfg.Demo)
+	if tg.TimeoutSec > 0 {
+		s.SetTelegramTimeout(time.Duration(tg.TimeoutSec) * time.Second)
+	}
+	s.SetSecurityConfig(cfg.Security)
+	s.SetBusinessConfig(cfg.Business)
+	if err := s.LoadAssetRegistry(); err != nil {
+		return nil, err
+	}
+	s.SetAdminUserIDs(tg.AdminUserIDs)
+
+	// Источник курсов для заявок с плавающей ценой (необязательно)
+	var rates *CachedRateProvider
+	switch {
+	case cfg.Business.RateProviderURL != "":
+		rates = NewHTTPRateProvider(cfg.Business.RateProviderURL, cfg.Business.RateCacheTTL)
+		log.Printf("[INFO] Курсы для плавающих цен загружаются с %s", cfg.Business.RateProviderURL)
+	case cfg.Business.RateProviderFile != "":
+		rates = NewFileRateProvider(cfg.Business.RateProviderFile, cfg.Business.RateCacheTTL)
+		log.Printf("[INFO] Курсы для плавающих цен загружаются из файла %s", cfg.Business.RateProviderFile)
+	default:
+		log.Println("[INFO] RATE_PROVIDER_URL и RATE_PROVIDER_FILE не заданы, плавающие цены отключены")
+	}
+	if rates != nil {
+		if cfg.Business.RateMaxAge > 0 {
+			rates.SetMaxAge(cfg.Business.RateMaxAge)
+		}
+		s.SetRateProvider(rates)
+	}
+
+	return s, nil
+}
+
+// formatOptionalID переводит необязательный Telegram ID в строку (0 - не задан)
+func formatOptionalID(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+// SetBusinessConfig применяет бизнес-настройки биржи к сервису
+func (s *Service) SetBusinessConfig(cfg model.BusinessConfig) {
+	s.business = cfg
+	s.logf("[INFO] Таймаут подтверждения сделки: %s", s.dealConfirmationTimeout())
+	if cfg.EnableAutoMatch {
+		s.logf("[INFO] Автоматическое сопоставление заявок включено")
+	}
+}
+
+// SetTelegramTimeout задает таймаут запросов к Telegram Bot API: проверки членства в чате и уведомлений
+func (s *Service) SetTelegramTimeout(timeout time.Duration) {
+	s.httpClient.Timeout = timeout
+	s.notificationService.httpClient.Timeout = timeout
+	s.logf("[INFO] Таймаут запросов к Telegram Bot API: %s", timeout)
+}
+
 // =====================================================
 // АВТОРИЗАЦИЯ И АУТЕНТИФИКАЦИЯ
 // =====================================================
@@ -79,20 +166,28 @@ func NewServiceWithGroup(repo repository.RepositoryInterface, telegramToken, cha
 // AuthenticateUser выполняет авторизацию пользователя через Telegram WebApp
 // Проверяет подлинность данных от Telegram и создает/обновляет пользователя
 func (s *Service) AuthenticateUser(authData *model.TelegramAuthData) (*model.User, error) {
-	log.Printf("[INFO] Попытка авторизации пользователя: TelegramID=%d, Username=%s",
+	s.logf("[INFO] Попытка авторизации пользователя: TelegramID=%d, Username=%s",
 		authData.ID, authData.Username)
 
-	// Проверяем подлинность данных от Telegram WebApp
-	// ВРЕМЕННО: отключаем проверку подписи для тестирования
-	if authData.Hash != "dummy_hash" && !s.validateTelegramAuth(authData) {
-		log.Printf("[WARN] Неверная подпись авторизации для пользователя TelegramID=%d", authData.ID)
+	if authData.InitData != "" {
+		// Mini App: проверяем подпись initData и берем данные пользователя из нее
+		webAppData, err := s.validateWebAppInitData(authData.InitData, webAppInitDataMaxAge)
+		if err != nil {
+			s.logf("[WARN] Неверные данные initData Mini App: %v", err)
+			return nil, fmt.Errorf("неверная подпись авторизации: %w", err)
+		}
+		authData = webAppData
+		s.logf("[INFO] Данные Mini App проверены для пользователя TelegramID=%d", authData.ID)
+	} else if !s.validateTelegramAuth(authData) {
+		// Login Widget в браузере: данные без действительной подписи бота не принимаются
+		s.logf("[WARN] Неверная подпись авторизации для пользователя TelegramID=%d", authData.ID)
 		return nil, fmt.Errorf("неверная подпись авторизации")
 	}
 
 	// Проверяем срок действия авторизации (не более 24 часов)
 	authTime := time.Unix(authData.AuthDate, 0)
 	if time.Since(authTime) > 24*time.Hour {
-		log.Printf("[WARN] Истекший токен авторизации для пользователя TelegramID=%d", authData.ID)
+		s.logf("[WARN] Истекший токен авторизации для пользователя TelegramID=%d", authData.ID)
 		return nil, fmt.Errorf("срок действия авторизации истек")
 	}
 
@@ -101,7 +196,7 @@ func (s *Service) AuthenticateUser(authData *model.TelegramAuthData) (*model.Use
 	if err != nil {
 		// Если пользователь не найден, создаем нового
 		if strings.Contains(err.Error(), "не найден") {
-			log.Printf("[INFO] Создание нового пользователя: TelegramID=%d", authData.ID)
+			s.logf("[INFO] Создание нового пользователя: TelegramID=%d", authData.ID)
 			user = &model.User{
 				TelegramID:      authData.ID,
 				TelegramUserID:  authData.Username,
@@ -120,22 +215,22 @@ func (s *Service) AuthenticateUser(authData *model.TelegramAuthData) (*model.Use
 
 			// Создаем пользователя в базе данных
 			if err := s.repo.CreateUser(user); err != nil {
-				log.Printf("[ERROR] Не удалось создать пользователя: %v", err)
+				s.logf("[ERROR] Не удалось создать пользователя: %v", err)
 				return nil, fmt.Errorf("не удалось создать пользователя: %w", err)
 			}
 		} else {
-			log.Printf("[ERROR] Ошибка при поиске пользователя: %v", err)
+			s.logf("[ERROR] Ошибка при поиске пользователя: %v", err)
 			return nil, fmt.Errorf("ошибка при поиске пользователя: %w", err)
 		}
 	} else {
-		log.Printf("[INFO] Найден существующий пользователь: ID=%d, TelegramID=%d",
+		s.logf("[INFO] Найден существующий пользователь: ID=%d, TelegramID=%d",
 			user.ID, user.TelegramID)
 	}
 
 	// Проверяем членство пользователя в закрытом чате через Telegram Bot API
 	isChatMember, err := s.checkChatMembership(authData.ID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось проверить членство в чате для пользователя TelegramID=%d: %v",
+		s.logf("[ERROR] Не удалось проверить членство в чате для пользователя TelegramID=%d: %v",
 			authData.ID, err)
 		// При ошибке API считаем что пользователь не является членом чата для безопасности
 		isChatMember = false
@@ -144,7 +239,7 @@ func (s *Service) AuthenticateUser(authData *model.TelegramAuthData) (*model.Use
 	// Обновляем статус членства если изменился
 	if user.ChatMember != isChatMember {
 		if err := s.repo.UpdateUserChatMembership(user.TelegramID, isChatMember); err != nil {
-			log.Printf("[WARN] Не удалось обновить статус членства: %v", err)
+			s.logf("[WARN] Не удалось обновить статус членства: %v", err)
 		} else {
 			user.ChatMember = isChatMember
 		}
@@ -152,17 +247,17 @@ func (s *Service) AuthenticateUser(authData *model.TelegramAuthData) (*model.Use
 
 	// Проверяем, является ли пользователь членом чата
 	if !user.ChatMember {
-		log.Printf("[WARN] Пользователь TelegramID=%d не является членом закрытого чата", user.TelegramID)
+		s.logf("[WARN] Пользователь TelegramID=%d не является членом закрытого чата", user.TelegramID)
 		return nil, fmt.Errorf("доступ запрещен: вы не являетесь членом закрытого чата")
 	}
 
 	// Проверяем, активен ли пользователь (не заблокирован)
 	if !user.IsActive {
-		log.Printf("[WARN] Попытка входа заблокированного пользователя TelegramID=%d", user.TelegramID)
+		s.logf("[WARN] Попытка входа заблокированного пользователя TelegramID=%d", user.TelegramID)
 		return nil, fmt.Errorf("ваш аккаунт заблокирован")
 	}
 
-	log.Printf("[INFO] Успешная авторизация пользователя: ID=%d, TelegramID=%d",
+	s.logf("[INFO] Успешная авторизация пользователя: ID=%d, TelegramID=%d",
 		user.ID, user.TelegramID)
 
 	return user, nil
@@ -170,21 +265,26 @@ func (s *Service) AuthenticateUser(authData *model.TelegramAuthData) (*model.Use
 
 // GetUserByTelegramID получает пользователя по его Telegram ID
 func (s *Service) GetUserByTelegramID(telegramID int64) (*model.User, error) {
-	log.Printf("[INFO] Получение пользователя по Telegram ID=%d", telegramID)
+	s.logf("[INFO] Получение пользователя по Telegram ID=%d", telegramID)
 
 	user, err := s.repo.GetUserByTelegramID(telegramID)
 	if err != nil {
-		log.Printf("[ERROR] Пользователь с Telegram ID=%d не найден: %v", telegramID, err)
+		s.logf("[ERROR] Пользователь с Telegram ID=%d не найден: %v", telegramID, err)
 		return nil, fmt.Errorf("пользователь не найден")
 	}
 
-	log.Printf("[INFO] Пользователь найден: ID=%d, Telegram ID=%d", user.ID, user.TelegramID)
+	s.logf("[INFO] Пользователь найден: ID=%d, Telegram ID=%d", user.ID, user.TelegramID)
 	return user, nil
 }
 
 // validateTelegramAuth проверяет подлинность данных авторизации от Telegram WebApp
 // Использует HMAC-SHA256 для валидации подписи
 func (s *Service) validateTelegramAuth(authData *model.TelegramAuthData) bool {
+	// Без токена бота ключ подписи общеизвестен - такие данные не принимаем
+	if s.telegramToken == "" || authData.Hash == "" {
+		return false
+	}
+
 	// Создаем строку для подписи из данных авторизации
 	// Порядок полей важен и должен соответствовать документации Telegram
 	dataCheckString := fmt.Sprintf("auth_date=%d\nfirst_name=%s\nid=%d\nlast_name=%s\nphoto_url=%s\nusername=%s",
@@ -208,10 +308,89 @@ func (s *Service) validateTelegramAuth(authData *model.TelegramAuthData) bool {
 	return hmac.Equal([]byte(authData.Hash), []byte(expectedHash))
 }
 
+// webAppInitDataMaxAge максимальный возраст initData Mini App (по полю auth_date)
+const webAppInitDataMaxAge = 24 * time.Hour
+
+// validateWebAppInitData проверяет строку initData от Telegram Mini App
+// Схема WebAppData: все поля кроме hash сортируются по ключу и склеиваются через "\n",
+// секретный ключ - HMAC-SHA256 токена бота с ключом "WebAppData".
+// Возвращает данные пользователя из вложенного JSON поля user
+func (s *Service) validateWebAppInitData(initData string, maxAge time.Duration) (*model.TelegramAuthData, error) {
+	values, err := url.ParseQuery(initData)
+	if err != nil {
+		return nil, fmt.Errorf("неверный формат initData: %w", err)
+	}
+
+	receivedHash := values.Get("hash")
+	if receivedHash == "" {
+		return nil, fmt.Errorf("в initData отсутствует hash")
+	}
+	if s.telegramToken == "" {
+		return nil, fmt.Errorf("токен бота не задан, initData проверить нельзя")
+	}
+
+	// Собираем строку проверки из всех полей кроме hash в алфавитном порядке
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if key != "hash" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+values.Get(key))
+	}
+	dataCheckString := strings.Join(pairs, "\n")
+
+	// secret_key = HMAC_SHA256(key="WebAppData", message=bot_token)
+	secretMac := hmac.New(sha256.New, []byte("WebAppData"))
+	secretMac.Write([]byte(s.telegramToken))
+	secretKey := secretMac.Sum(nil)
+
+	h := hmac.New(sha256.New, secretKey)
+	h.Write([]byte(dataCheckString))
+	expectedHash := hex.EncodeToString(h.Sum(nil))
+
+	if !hmac.Equal([]byte(receivedHash), []byte(expectedHash)) {
+		return nil, fmt.Errorf("подпись initData не совпадает")
+	}
+
+	// Проверяем свежесть данных
+	authDate, err := strconv.ParseInt(values.Get("auth_date"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("неверное поле auth_date: %w", err)
+	}
+	if time.Since(time.Unix(authDate, 0)) > maxAge {
+		return nil, fmt.Errorf("срок действия initData истек")
+	}
+
+	// Поле user содержит JSON с данными пользователя Telegram
+	userJSON := values.Get("user")
+	if userJSON == "" {
+		return nil, fmt.Errorf("в initData отсутствуют данные пользователя")
+	}
+
+	authData := &model.TelegramAuthData{}
+	if err := json.Unmarshal([]byte(userJSON), authData); err != nil {
+		return nil, fmt.Errorf("неверный формат поля user: %w", err)
+	}
+	if authData.ID == 0 {
+		return nil, fmt.Errorf("в initData отсутствует ID пользователя")
+	}
+
+	authData.AuthDate = authDate
+	authData.Hash = receivedHash
+	authData.InitData = initData
+
+	return authData, nil
+}
+
 // checkChatMembership проверяет является ли пользователь членом закрытого чата
 // Использует Telegram Bot API метод getChatMember
 func (s *Service) checkChatMembership(userTelegramID int64) (bool, error) {
-	log.Printf("[INFO] Проверка членства пользователя TelegramID=%d в чате %s", userTelegramID, s.chatID)
+	s.logf("[INFO] Проверка членства пользователя TelegramID=%d в чате %s", userTelegramID, s.chatID)
 
 	// URL для запроса к Telegram Bot API
 	url := fmt.Sprintf("https://api.telegram.org/bot%s/getChatMember?chat_id=%s&user_id=%d",
@@ -220,7 +399,7 @@ func (s *Service) checkChatMembership(userTelegramID int64) (bool, error) {
 	// Выполняем GET запрос
 	resp, err := s.httpClient.Get(url)
 	if err != nil {
-		log.Printf("[ERROR] Ошибка при запросе к Telegram Bot API: %v", err)
+		s.logf("[ERROR] Ошибка при запросе к Telegram Bot API: %v", err)
 		return false, fmt.Errorf("не удалось проверить членство в чате: %w", err)
 	}
 	defer resp.Body.Close()
@@ -228,13 +407,13 @@ func (s *Service) checkChatMembership(userTelegramID int64) (bool, error) {
 	// Парсим ответ от API
 	var response TelegramChatMemberResponse
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		log.Printf("[ERROR] Ошибка декодирования ответа от Telegram Bot API: %v", err)
+		s.logf("[ERROR] Ошибка декодирования ответа от Telegram Bot API: %v", err)
 		return false, fmt.Errorf("ошибка обработки ответа Telegram API: %w", err)
 	}
 
 	// Проверяем успешность запроса
 	if !response.OK {
-		log.Printf("[WARN] Telegram Bot API вернул ошибку для пользователя TelegramID=%d", userTelegramID)
+		s.logf("[WARN] Telegram Bot API вернул ошибку для пользователя TelegramID=%d", userTelegramID)
 		// Если пользователь не найден в чате, считаем что он не является членом
 		return false, nil
 	}
@@ -246,7 +425,7 @@ func (s *Service) checkChatMembership(userTelegramID int64) (bool, error) {
 		response.Result.Status == "administrator" ||
 		response.Result.Status == "member"
 
-	log.Printf("[INFO] Статус пользователя TelegramID=%d в чате: %s, член чата: %v",
+	s.logf("[INFO] Статус пользователя TelegramID=%d в чате: %s, член чата: %v",
 		userTelegramID, response.Result.Status, isMember)
 
 	return isMember, nil
@@ -259,24 +438,37 @@ func (s *Service) checkChatMembership(userTelegramID int64) (bool, error) {
 // CreateOrder создает новую заявку на покупку или продажу криптовалюты
 // Проверяет валидность данных и сохраняет заявку в базе данных
 func (s *Service) CreateOrder(userID int64, orderData *model.Order) (*model.Order, error) {
-	log.Printf("[INFO] Создание заявки пользователем ID=%d: Type=%s, Crypto=%s, Amount=%.2f",
+	s.logf("[INFO] Создание заявки пользователем ID=%d: Type=%s, Crypto=%s, Amount=%.2f",
 		userID, orderData.Type, orderData.Cryptocurrency, orderData.Amount)
 
 	// Проверяем права пользователя на создание заявки
 	user, err := s.repo.GetUserByTelegramID(userID)
 	if err != nil {
-		log.Printf("[ERROR] Пользователь ID=%d не найден при создании заявки", userID)
+		s.logf("[ERROR] Пользователь ID=%d не найден при создании заявки", userID)
 		return nil, fmt.Errorf("пользователь не найден")
 	}
 
 	if !user.IsActive || !user.ChatMember {
-		log.Printf("[WARN] Попытка создания заявки неактивным пользователем ID=%d", userID)
+		s.logf("[WARN] Попытка создания заявки неактивным пользователем ID=%d", userID)
 		return nil, fmt.Errorf("недостаточно прав для создания заявки")
 	}
 
+	// Плавающая цена считается по текущему курсу до проверки данных заявки
+	if err := s.priceFloatingOrder(orderData); err != nil {
+		return nil, err
+	}
+
 	// Валидируем данные заявки
 	if err := s.validateOrderData(orderData); err != nil {
-		log.Printf("[WARN] Невалидные данные заявки от пользователя ID=%d: %v", userID, err)
+		s.logf("[WARN] Невалидные данные заявки от пользователя ID=%d: %v", userID, err)
+		return nil, err
+	}
+	if err := validateOrderTTL(orderData.TTLHours); err != nil {
+		s.logf("[WARN] Невалидный срок заявки от пользователя ID=%d: %v", userID, err)
+		return nil, err
+	}
+	if err := s.checkActiveOrdersLimit(user.ID); err != nil {
+		s.logf("[WARN] Пользователь ID=%d превысил лимит активных заявок: %v", userID, err)
 		return nil, err
 	}
 
@@ -285,8 +477,9 @@ func (s *Service) CreateOrder(userID int64, orderData *model.Order) (*model.Orde
 	orderData.Status = model.OrderStatusActive
 	orderData.IsActive = true
 	orderData.TotalAmount = orderData.Amount * orderData.Price
-	// Устанавливаем ExpiresAt в далекое будущее - таймеры больше не используются
-	orderData.ExpiresAt = time.Now().Add(365 * 24 * time.Hour) // 1 год
+	orderData.RemainingAmount = orderData.Amount // Весь объем доступен для сделок
+	// Срок действия: указанный пользователем или OrderExpirationHours по умолчанию
+	orderData.ExpiresAt = time.Now().Add(s.orderTTL(orderData.TTLHours))
 
 	// Если не указан минимальный и максимальный лимит, устанавливаем их равными общей сумме
 	if orderData.MinAmount == 0 {
@@ -298,61 +491,93 @@ func (s *Service) CreateOrder(userID int64, orderData *model.Order) (*model.Orde
 
 	// Сохраняем заявку в базе данных
 	if err := s.repo.CreateOrder(orderData); err != nil {
-		log.Printf("[ERROR] Не удалось сохранить заявку пользователя ID=%d: %v", userID, err)
+		s.logf("[ERROR] Не удалось сохранить заявку пользователя ID=%d: %v", userID, err)
 		return nil, fmt.Errorf("не удалось создать заявку: %w", err)
 	}
 
-	// Автоматическое сопоставление больше не используется
-	// В новой логике пользователи создают отклики, а авторы их принимают
-
-	log.Printf("[INFO] Успешно создана заявка: ID=%d, UserID=%d, Type=%s",
+	s.logf("[INFO] Успешно создана заявка: ID=%d, UserID=%d, Type=%s",
 		orderData.ID, userID, orderData.Type)
 
-	// Отправляем групповое уведомление о новой заявке
-	go s.sendOrderCreatedGroupNotification(orderData, user)
+	// Пересекающиеся встречные заявки сразу становятся сделками
+	if s.business.EnableAutoMatch {
+		orderData = s.tryAutoMatchOrder(orderData)
+	}
+
+	// Отправляем групповое уведомление о новой заявке, если на рынке остался ее объем
+	if !orderData.IsFilled() {
+		s.goNotify(func() { s.sendOrderCreatedGroupNotification(orderData, user) })
+	}
 
 	return orderData, nil
 }
 
+// checkActiveOrdersLimit проверяет, что у пользователя меньше MaxActiveOrdersPerUser активных заявок
+// (на рынке и в сделках). Нулевой лимит не ограничивает количество заявок
+func (s *Service) checkActiveOrdersLimit(userID int64) error {
+	limit := s.business.MaxActiveOrdersPerUser
+	if limit <= 0 {
+		return nil
+	}
+	orders, err := s.repo.GetOrdersByFilter(&model.OrderFilter{UserID: &userID})
+	if err != nil {
+		return fmt.Errorf("не удалось проверить количество активных заявок: %w", err)
+	}
+	if len(orders) >= limit {
+		return fmt.Errorf("у вас уже %d активных заявок, максимум %d", len(orders), limit)
+	}
+	return nil
+}
+
 // UpdateOrder обновляет существующую заявку
 func (s *Service) UpdateOrder(orderID, userID int64, orderData *model.Order) (*model.Order, error) {
-	log.Printf("[INFO] Обновление заявки ID=%d пользователем ID=%d: Type=%s, Crypto=%s, Amount=%.2f",
+	s.logf("[INFO] Обновление заявки ID=%d пользователем ID=%d: Type=%s, Crypto=%s, Amount=%.2f",
 		orderID, userID, orderData.Type, orderData.Cryptocurrency, orderData.Amount)
 
 	// Проверяем права пользователя
 	user, err := s.repo.GetUserByTelegramID(userID)
 	if err != nil {
-		log.Printf("[ERROR] Пользователь ID=%d не найден при обновлении заявки", userID)
+		s.logf("[ERROR] Пользователь ID=%d не найден при обновлении заявки", userID)
 		return nil, fmt.Errorf("пользователь не найден")
 	}
 
 	if !user.IsActive || !user.ChatMember {
-		log.Printf("[WARN] Попытка обновления заявки неактивным пользователем ID=%d", userID)
+		s.logf("[WARN] Попытка обновления заявки неактивным пользователем ID=%d", userID)
 		return nil, fmt.Errorf("недостаточно прав для обновления заявки")
 	}
 
 	// Получаем существующую заявку
 	existingOrder, err := s.repo.GetOrderByID(orderID)
 	if err != nil {
-		log.Printf("[ERROR] Заявка ID=%d не найдена: %v", orderID, err)
+		s.logf("[ERROR] Заявка ID=%d не найдена: %v", orderID, err)
 		return nil, fmt.Errorf("заявка не найдена")
 	}
 
 	// Проверяем что заявка принадлежит пользователю
 	if existingOrder.UserID != user.ID {
-		log.Printf("[WARN] Попытка редактирования чужой заявки ID=%d пользователем ID=%d", orderID, userID)
+		s.logf("[WARN] Попытка редактирования чужой заявки ID=%d пользователем ID=%d", orderID, userID)
 		return nil, fmt.Errorf("нет прав на редактирование этой заявки")
 	}
 
 	// Проверяем что заявка может быть отредактирована (только активные заявки)
 	if existingOrder.Status != model.OrderStatusActive && existingOrder.Status != model.OrderStatusHasResponses {
-		log.Printf("[WARN] Попытка редактирования заявки ID=%d в статусе %s", orderID, existingOrder.Status)
+		s.logf("[WARN] Попытка редактирования заявки ID=%d в статусе %s", orderID, existingOrder.Status)
 		return nil, fmt.Errorf("заявку в статусе '%s' нельзя редактировать", existingOrder.Status)
 	}
 
+	// Заявку, часть которой уже взята в сделки, менять нельзя: объем сделок зависит от ее условий
+	if existingOrder.RemainingAmount < existingOrder.Amount-model.AmountEpsilon {
+		s.logf("[WARN] Попытка редактирования частично исполненной заявки ID=%d", orderID)
+		return nil, fmt.Errorf("по заявке уже открыты сделки, ее нельзя редактировать")
+	}
+
+	// Плавающая цена считается по текущему курсу до проверки данных заявки
+	if err := s.priceFloatingOrder(orderData); err != nil {
+		return nil, err
+	}
+
 	// Валидируем новые данные заявки
 	if err := s.validateOrderData(orderData); err != nil {
-		log.Printf("[WARN] Невалидные данные при обновлении заявки ID=%d: %v", orderID, err)
+		s.logf("[WARN] Невалидные данные при обновлении заявки ID=%d: %v", orderID, err)
 		return nil, err
 	}
 
@@ -362,6 +587,7 @@ func (s *Service) UpdateOrder(orderID, userID int64, orderData *model.Order) (*m
 	orderData.Status = existingOrder.Status // Сохраняем текущий статус
 	orderData.IsActive = existingOrder.IsActive
 	orderData.TotalAmount = orderData.Amount * orderData.Price
+	orderData.RemainingAmount = orderData.Amount
 	orderData.CreatedAt = existingOrder.CreatedAt // Сохраняем дату создания
 	orderData.ExpiresAt = existingOrder.ExpiresAt // Сохраняем срок истечения
 
@@ -375,11 +601,11 @@ func (s *Service) UpdateOrder(orderID, userID int64, orderData *model.Order) (*m
 
 	// Обновляем заявку в базе данных
 	if err := s.repo.UpdateOrder(orderData); err != nil {
-		log.Printf("[ERROR] Не удалось обновить заявку ID=%d: %v", orderID, err)
+		s.logf("[ERROR] Не удалось обновить заявку ID=%d: %v", orderID, err)
 		return nil, fmt.Errorf("не удалось обновить заявку: %w", err)
 	}
 
-	log.Printf("[INFO] Успешно обновлена заявка: ID=%d, UserID=%d, Type=%s",
+	s.logf("[INFO] Успешно обновлена заявка: ID=%d, UserID=%d, Type=%s",
 		orderData.ID, userID, orderData.Type)
 
 	return orderData, nil
@@ -388,7 +614,7 @@ func (s *Service) UpdateOrder(orderID, userID int64, orderData *model.Order) (*m
 // GetOrders получает список заявок с фильтрацией и пагинацией
 // Позволяет найти подходящие заявки для пользователя
 func (s *Service) GetOrders(filter *model.OrderFilter) ([]*model.Order, error) {
-	log.Printf("[INFO] Поиск заявок с фильтром: Type=%v, Crypto=%v, Status=%v",
+	s.logf("[INFO] Поиск заявок с фильтром: Type=%v, Crypto=%v, Status=%v",
 		filter.Type, filter.Cryptocurrency, filter.Status)
 
 	// Устанавливаем значения по умолчанию для пагинации
@@ -402,10 +628,13 @@ func (s *Service) GetOrders(filter *model.OrderFilter) ([]*model.Order, error) {
 	// Получаем заявки из репозитория
 	orders, err := s.repo.GetOrdersByFilter(filter)
 	if err != nil {
-		log.Printf("[ERROR] Ошибка при поиске заявок: %v", err)
+		s.logf("[ERROR] Ошибка при поиске заявок: %v", err)
 		return nil, fmt.Errorf("не удалось получить заявки: %w", err)
 	}
 
+	// Цены плавающих заявок показываем по текущему курсу
+	s.applyFloatingPrices(orders)
+
 	// Обогащаем заявки данными пользователей для отображения на фронтенде
 	for _, order := range orders {
 		if user, err := s.repo.GetUserByID(order.UserID); err == nil {
@@ -419,21 +648,21 @@ func (s *Service) GetOrders(filter *model.OrderFilter) ([]*model.Order, error) {
 			order.FirstName = user.FirstName
 			order.LastName = user.LastName
 
-			log.Printf("[DEBUG] Обогащена заявка ID=%d данными пользователя: Name=%s, Username=%s",
+			s.logf("[DEBUG] Обогащена заявка ID=%d данными пользователя: Name=%s, Username=%s",
 				order.ID, order.UserName, order.Username)
 		} else {
-			log.Printf("[WARN] Не удалось получить данные пользователя ID=%d для заявки ID=%d: %v",
+			s.logf("[WARN] Не удалось получить данные пользователя ID=%d для заявки ID=%d: %v",
 				order.UserID, order.ID, err)
 		}
 	}
 
-	log.Printf("[INFO] Найдено заявок: %d", len(orders))
+	s.logf("[INFO] Найдено заявок: %d", len(orders))
 	return orders, nil
 }
 
 // GetOrder получает заявку по ID
 func (s *Service) GetOrder(orderID int64) (*model.Order, error) {
-	log.Printf("[INFO] Получение заявки по ID=%d", orderID)
+	s.logf("[INFO] Получение заявки по ID=%d", orderID)
 
 	// Получаем все заявки и ищем нужную (пока нет отдельного метода GetOrderByID)
 	filter := &model.OrderFilter{
@@ -443,38 +672,47 @@ func (s *Service) GetOrder(orderID int64) (*model.Order, error) {
 
 	orders, err := s.repo.GetOrdersByFilter(filter)
 	if err != nil {
-		log.Printf("[ERROR] Ошибка при поиске заявки ID=%d: %v", orderID, err)
+		s.logf("[ERROR] Ошибка при поиске заявки ID=%d: %v", orderID, err)
 		return nil, fmt.Errorf("ошибка поиска заявки")
 	}
 
 	// Ищем заявку с нужным ID
 	for _, order := range orders {
 		if order.ID == orderID {
-			log.Printf("[INFO] Заявка найдена: ID=%d, Type=%s", order.ID, order.Type)
+			s.logf("[INFO] Заявка найдена: ID=%d, Type=%s", order.ID, order.Type)
+			s.applyFloatingPrices([]*model.Order{order})
 			return order, nil
 		}
 	}
 
-	log.Printf("[WARN] Заявка ID=%d не найдена", orderID)
+	s.logf("[WARN] Заявка ID=%d не найдена", orderID)
 	return nil, fmt.Errorf("заявка с ID=%d не найдена", orderID)
 }
 
 // CancelOrder отменяет активную заявку пользователя
 // Только создатель заявки может ее отменить
 func (s *Service) CancelOrder(userID, orderID int64) error {
-	log.Printf("[INFO] Отмена заявки ID=%d пользователем ID=%d", orderID, userID)
+	s.logf("[INFO] Отмена заявки ID=%d пользователем ID=%d", orderID, userID)
 
-	// TODO: Добавить проверку прав пользователя на отмену заявки
-	// Нужно проверить, что заявка принадлежит пользователю и имеет статус "active"
+	// Проверяем, что заявка принадлежит пользователю
+	order, err := s.repo.GetOrderByID(orderID)
+	if err != nil {
+		s.logf("[WARN] Заявка ID=%d не найдена: %v", orderID, err)
+		return fmt.Errorf("заявка не найдена")
+	}
+	if order.UserID != userID {
+		s.logf("[WARN] Пользователь ID=%d пытается отменить чужую заявку ID=%d", userID, orderID)
+		return fmt.Errorf("вы можете отменить только свою заявку")
+	}
 
 	// Обновляем статус заявки на "cancelled"
-	err := s.repo.UpdateOrderStatus(orderID, model.OrderStatusCancelled)
+	err = s.repo.UpdateOrderStatus(orderID, model.OrderStatusCancelled)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось отменить заявку ID=%d: %v", orderID, err)
+		s.logf("[ERROR] Не удалось отменить заявку ID=%d: %v", orderID, err)
 		return fmt.Errorf("не удалось отменить заявку: %w", err)
 	}
 
-	log.Printf("[INFO] Заявка ID=%d успешно отменена", orderID)
+	s.logf("[INFO] Заявка ID=%d успешно отменена", orderID)
 	return nil
 }
 
@@ -489,36 +727,13 @@ func (s *Service) validateOrderData(order *model.Order) error {
 		return fmt.Errorf("неверный тип заявки: %s", order.Type)
 	}
 
-	// Проверяем криптовалюту
-	supportedCryptos := []string{"BTC", "ETH", "USDT", "USDC", "LTC"}
-	isValidCrypto := false
-	for _, crypto := range supportedCryptos {
-		if order.Cryptocurrency == crypto {
-			isValidCrypto = true
-			break
-		}
-	}
-	if !isValidCrypto {
-		return fmt.Errorf("неподдерживаемая криптовалюта: %s", order.Cryptocurrency)
-	}
-
-	// Проверяем фиатную валюту
-	supportedFiats := []string{"RUB", "USD", "EUR", "UAH"}
-	isValidFiat := false
-	for _, fiat := range supportedFiats {
-		if order.FiatCurrency == fiat {
-			isValidFiat = true
-			break
-		}
-	}
-	if !isValidFiat {
-		return fmt.Errorf("неподдерживаемая фиатная валюта: %s", order.FiatCurrency)
-	}
-
 	// Проверяем количество и цену
 	if order.Amount <= 0 {
 		return fmt.Errorf("количество должно быть больше нуля")
 	}
+	if err := order.ValidatePricing(); err != nil {
+		return err
+	}
 	if order.Price <= 0 {
 		return fmt.Errorf("цена должна быть больше нуля")
 	}
@@ -536,17 +751,15 @@ func (s *Service) validateOrderData(order *model.Order) error {
 		return fmt.Errorf("необходимо указать хотя бы один способ оплаты")
 	}
 
-	supportedPayments := []string{"bank_transfer", "sberbank", "tinkoff", "SPB", "yandex_money", "cash"}
-	for _, method := range order.PaymentMethods {
-		isValidPayment := false
-		for _, supported := range supportedPayments {
-			if method == supported {
-				isValidPayment = true
-				break
-			}
-		}
-		if !isValidPayment {
-			return fmt.Errorf("неподдерживаемый способ оплаты: %s", method)
+	// Проверяем криптовалюту, фиатную валюту и способы оплаты по реестру
+	if err := s.validateOrderAssets(order); err != nil {
+		return err
+	}
+
+	// Проверяем правила автопринятия откликов
+	if order.AutoAccept != nil {
+		if err := order.AutoAccept.Validate(order); err != nil {
+			return err
 		}
 	}
 
@@ -563,140 +776,17 @@ func (s *Service) HealthCheck() error {
 	return nil
 }
 
-// =====================================================
-// ЛОГИКА P2P ТОРГОВЛИ
-// =====================================================
-
-// tryAutoMatchOrder пытается автоматически сопоставить заявку с подходящими
-// Выполняется в отдельной горутине для неблокирующей работы
-func (s *Service) tryAutoMatchOrder(order *model.Order) {
-	log.Printf("[INFO] Попытка автоматического сопоставления заявки ID=%d", order.ID)
-
-	// Ищем подходящие заявки
-	matchingOrders, err := s.repo.GetMatchingOrders(order)
-	if err != nil {
-		log.Printf("[ERROR] Не удалось найти подходящие заявки для Order ID=%d: %v", order.ID, err)
-		return
-	}
-
-	if len(matchingOrders) == 0 {
-		log.Printf("[INFO] Подходящие заявки для Order ID=%d не найдены", order.ID)
-		return
-	}
-
-	// Пытаемся сопоставить с первой подходящей заявкой
-	matchingOrder := matchingOrders[0]
-
-	// Проверяем совместимость цен
-	if !s.isPriceCompatible(order, matchingOrder) {
-		log.Printf("[INFO] Цены заявок ID=%d и ID=%d несовместимы", order.ID, matchingOrder.ID)
-		return
-	}
-
-	// Проверяем совместимость лимитов
-	if !s.isAmountCompatible(order, matchingOrder) {
-		log.Printf("[INFO] Лимиты заявок ID=%d и ID=%d несовместимы", order.ID, matchingOrder.ID)
-		return
-	}
-
-	// Проверяем совместимость способов оплаты
-	if !s.hasCommonPaymentMethods(order, matchingOrder) {
-		log.Printf("[INFO] У заявок ID=%d и ID=%d нет общих способов оплаты", order.ID, matchingOrder.ID)
-		return
-	}
-
-	// Сопоставляем заявки
-	if err := s.repo.MatchOrders(order.ID, matchingOrder.ID); err != nil {
-		log.Printf("[ERROR] Не удалось сопоставить заявки ID=%d и ID=%d: %v", order.ID, matchingOrder.ID, err)
-		return
-	}
-
-	// Автосопоставление отключено в новой логике откликов
-	log.Printf("[INFO] Автосопоставление отключено, используйте систему откликов")
-
-	// TODO: Отправить уведомления участникам через Telegram бота
-	// s.notifyUsersAboutDeal(deal)
-}
-
-// isPriceCompatible проверяет совместимость цен двух заявок
-func (s *Service) isPriceCompatible(order1, order2 *model.Order) bool {
-	// Для покупки: цена покупателя должна быть >= цены продавца
-	// Для продажи: цена продавца должна быть <= цены покупателя
-
-	if order1.Type == model.OrderTypeBuy && order2.Type == model.OrderTypeSell {
-		return order1.Price >= order2.Price // Покупатель готов платить >= чем просит продавец
-	}
-
-	if order1.Type == model.OrderTypeSell && order2.Type == model.OrderTypeBuy {
-		return order1.Price <= order2.Price // Продавец готов продать <= чем готов платить покупатель
-	}
-
-	return false
-}
-
-// isAmountCompatible проверяет совместимость сумм двух заявок
-func (s *Service) isAmountCompatible(order1, order2 *model.Order) bool {
-	// Проверяем что суммы заявок пересекаются в допустимых диапазонах
-
-	// Общее количество не должно превышать минимум из двух заявок
-	minAmount := order1.Amount
-	if order2.Amount < minAmount {
-		minAmount = order2.Amount
-	}
-
-	// Проверяем минимальные лимиты
-	if order1.MinAmount > 0 && minAmount*order1.Price < order1.MinAmount {
-		return false
-	}
-
-	if order2.MinAmount > 0 && minAmount*order2.Price < order2.MinAmount {
-		return false
-	}
-
-	// Проверяем максимальные лимиты
-	if order1.MaxAmount > 0 && minAmount*order1.Price > order1.MaxAmount {
-		return false
-	}
-
-	if order2.MaxAmount > 0 && minAmount*order2.Price > order2.MaxAmount {
-		return false
-	}
-
-	return true
-}
-
-// hasCommonPaymentMethods проверяет есть ли общие способы оплаты у двух заявок
-func (s *Service) hasCommonPaymentMethods(order1, order2 *model.Order) bool {
-	// Создаем карту способов оплаты первой заявки для быстрого поиска
-	methods1 := make(map[string]bool)
-	for _, method := range order1.PaymentMethods {
-		methods1[method] = true
-	}
-
-	// Проверяем есть ли пересечения с методами второй заявки
-	for _, method := range order2.PaymentMethods {
-		if methods1[method] {
-			return true // Найден общий способ оплаты
-		}
-	}
-
-	return false
-}
-
-// УДАЛЕНО: createDealFromOrders - устаревший метод автосопоставления
-// В новой логике откликов автоматическое сопоставление не используется
-
 // =====================================================
 // УПРАВЛЕНИЕ СДЕЛКАМИ
 // =====================================================
 
 // GetUserDeals получает все сделки пользователя
 func (s *Service) GetUserDeals(userID int64) ([]*model.Deal, error) {
-	log.Printf("[INFO] Получение сделок для пользователя ID=%d", userID)
+	s.logf("[INFO] Получение сделок для пользователя ID=%d", userID)
 
 	deals, err := s.repo.GetDealsByUserID(userID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось получить сделки пользователя ID=%d: %v", userID, err)
+		s.logf("[ERROR] Не удалось получить сделки пользователя ID=%d: %v", userID, err)
 		return nil, fmt.Errorf("не удалось получить сделки: %w", err)
 	}
 
@@ -710,10 +800,10 @@ func (s *Service) GetUserDeals(userID int64) ([]*model.Deal, error) {
 			}
 			deal.AuthorUsername = author.Username
 
-			log.Printf("[DEBUG] Обогащена сделка ID=%d данными автора: Name=%s, Username=%s",
+			s.logf("[DEBUG] Обогащена сделка ID=%d данными автора: Name=%s, Username=%s",
 				deal.ID, deal.AuthorName, deal.AuthorUsername)
 		} else {
-			log.Printf("[WARN] Не удалось получить данные автора ID=%d для сделки ID=%d: %v",
+			s.logf("[WARN] Не удалось получить данные автора ID=%d для сделки ID=%d: %v",
 				deal.AuthorID, deal.ID, err)
 		}
 
@@ -725,30 +815,30 @@ func (s *Service) GetUserDeals(userID int64) ([]*model.Deal, error) {
 			}
 			deal.CounterpartyUsername = counterparty.Username
 
-			log.Printf("[DEBUG] Обогащена сделка ID=%d данными контрагента: Name=%s, Username=%s",
+			s.logf("[DEBUG] Обогащена сделка ID=%d данными контрагента: Name=%s, Username=%s",
 				deal.ID, deal.CounterpartyName, deal.CounterpartyUsername)
 		} else {
-			log.Printf("[WARN] Не удалось получить данные контрагента ID=%d для сделки ID=%d: %v",
+			s.logf("[WARN] Не удалось получить данные контрагента ID=%d для сделки ID=%d: %v",
 				deal.CounterpartyID, deal.ID, err)
 		}
 
 		// Проверяем статус отзывов для завершенных сделок
 		if deal.Status == model.DealStatusCompleted {
-			log.Printf("[DEBUG] Проверяем статус отзывов для завершенной сделки ID=%d", deal.ID)
+			s.logf("[DEBUG] Проверяем статус отзывов для завершенной сделки ID=%d", deal.ID)
 
 			// Проверяем, оставил ли автор отзыв о контрагенте
 			if canReview, err := s.repo.CheckCanReview(deal.ID, deal.AuthorID, deal.CounterpartyID); err == nil {
 				// Если НЕ может оставить отзыв, значит уже оставил
 				deal.AuthorReviewGiven = !canReview
-				log.Printf("[DEBUG] Автор сделки ID=%d: canReview=%t, reviewGiven=%t", deal.ID, canReview, deal.AuthorReviewGiven)
+				s.logf("[DEBUG] Автор сделки ID=%d: canReview=%t, reviewGiven=%t", deal.ID, canReview, deal.AuthorReviewGiven)
 			} else {
 				// Если ошибка содержит "уже оставлен" или "уже оставили", значит отзыв оставлен
 				if strings.Contains(err.Error(), "уже оставлен") || strings.Contains(err.Error(), "уже оставили") {
 					deal.AuthorReviewGiven = true
-					log.Printf("[DEBUG] Автор сделки ID=%d: отзыв УЖЕ оставлен (из ошибки), reviewGiven=%t", deal.ID, deal.AuthorReviewGiven)
+					s.logf("[DEBUG] Автор сделки ID=%d: отзыв УЖЕ оставлен (из ошибки), reviewGiven=%t", deal.ID, deal.AuthorReviewGiven)
 				} else {
 					deal.AuthorReviewGiven = false
-					log.Printf("[WARN] Не удалось проверить отзыв автора для сделки ID=%d: %v", deal.ID, err)
+					s.logf("[WARN] Не удалось проверить отзыв автора для сделки ID=%d: %v", deal.ID, err)
 				}
 			}
 
@@ -756,85 +846,54 @@ func (s *Service) GetUserDeals(userID int64) ([]*model.Deal, error) {
 			if canReview, err := s.repo.CheckCanReview(deal.ID, deal.CounterpartyID, deal.AuthorID); err == nil {
 				// Если НЕ может оставить отзыв, значит уже оставил
 				deal.CounterpartyReviewGiven = !canReview
-				log.Printf("[DEBUG] Контрагент сделки ID=%d: canReview=%t, reviewGiven=%t", deal.ID, canReview, deal.CounterpartyReviewGiven)
+				s.logf("[DEBUG] Контрагент сделки ID=%d: canReview=%t, reviewGiven=%t", deal.ID, canReview, deal.CounterpartyReviewGiven)
 			} else {
 				// Если ошибка содержит "уже оставлен" или "уже оставили", значит отзыв оставлен
 				if strings.Contains(err.Error(), "уже оставлен") || strings.Contains(err.Error(), "уже оставили") {
 					deal.CounterpartyReviewGiven = true
-					log.Printf("[DEBUG] Контрагент сделки ID=%d: отзыв УЖЕ оставлен (из ошибки), reviewGiven=%t", deal.ID, deal.CounterpartyReviewGiven)
+					s.logf("[DEBUG] Контрагент сделки ID=%d: отзыв УЖЕ оставлен (из ошибки), reviewGiven=%t", deal.ID, deal.CounterpartyReviewGiven)
 				} else {
 					deal.CounterpartyReviewGiven = false
-					log.Printf("[WARN] Не удалось проверить отзыв контрагента для сделки ID=%d: %v", deal.ID, err)
+					s.logf("[WARN] Не удалось проверить отзыв контрагента для сделки ID=%d: %v", deal.ID, err)
 				}
 			}
 
-			log.Printf("[INFO] Сделка ID=%d - статус отзывов: автор=%t, контрагент=%t",
+			s.logf("[INFO] Сделка ID=%d - статус отзывов: автор=%t, контрагент=%t",
 				deal.ID, deal.AuthorReviewGiven, deal.CounterpartyReviewGiven)
 		} else {
-			log.Printf("[DEBUG] Сделка ID=%d не завершена (статус: %s), отзывы не проверяем", deal.ID, deal.Status)
+			s.logf("[DEBUG] Сделка ID=%d не завершена (статус: %s), отзывы не проверяем", deal.ID, deal.Status)
 			// Для незавершенных сделок явно устанавливаем false, чтобы поля были в JSON
 			deal.AuthorReviewGiven = false
 			deal.CounterpartyReviewGiven = false
 		}
 	}
 
-	log.Printf("[INFO] Найдено сделок для пользователя ID=%d: %d", userID, len(deals))
+	s.logf("[INFO] Найдено сделок для пользователя ID=%d: %d", userID, len(deals))
 	return deals, nil
 }
 
 // GetDeal получает сделку по ID с проверкой прав доступа
 func (s *Service) GetDeal(dealID, userID int64) (*model.Deal, error) {
-	log.Printf("[INFO] Получение сделки ID=%d пользователем ID=%d", dealID, userID)
+	s.logf("[INFO] Получение сделки ID=%d пользователем ID=%d", dealID, userID)
 
 	deal, err := s.repo.GetDealByID(dealID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось получить сделку ID=%d: %v", dealID, err)
+		s.logf("[ERROR] Не удалось получить сделку ID=%d: %v", dealID, err)
 		return nil, fmt.Errorf("сделка не найдена")
 	}
 
 	// Проверяем что пользователь участвует в сделке
 	if deal.AuthorID != userID && deal.CounterpartyID != userID {
-		log.Printf("[WARN] Пользователь ID=%d пытается получить доступ к чужой сделке ID=%d", userID, dealID)
+		s.logf("[WARN] Пользователь ID=%d пытается получить доступ к чужой сделке ID=%d", userID, dealID)
 		return nil, fmt.Errorf("доступ запрещен: вы не участвуете в данной сделке")
 	}
 
 	return deal, nil
 }
 
-// ConfirmDeal подтверждает сделку со стороны пользователя
-func (s *Service) ConfirmDeal(dealID, userID int64, paymentProof string) error {
-	log.Printf("[INFO] Подтверждение сделки ID=%d пользователем ID=%d", dealID, userID)
-
-	// Проверяем доступ к сделке
-	deal, err := s.GetDeal(dealID, userID)
-	if err != nil {
-		return err
-	}
-
-	// Проверяем что сделка в подходящем статусе
-	if deal.Status != model.DealStatusInProgress && deal.Status != model.DealStatusWaitingConfirmation {
-		return fmt.Errorf("сделка в статусе '%s' не может быть подтверждена", deal.Status)
-	}
-
-	// В новой логике доказательства могут предоставлять обе стороны
-	var isPaymentProof bool
-	if paymentProof != "" {
-		isPaymentProof = true
-	}
-
-	// Подтверждаем сделку
-	if err := s.repo.ConfirmDeal(dealID, userID, isPaymentProof, paymentProof); err != nil {
-		log.Printf("[ERROR] Не удалось подтвердить сделку ID=%d: %v", dealID, err)
-		return fmt.Errorf("не удалось подтвердить сделку: %w", err)
-	}
-
-	log.Printf("[INFO] Сделка ID=%d подтверждена пользователем ID=%d", dealID, userID)
-	return nil
-}
-
 // ConfirmDealWithRole подтверждает сделку со стороны пользователя с указанием роли
 func (s *Service) ConfirmDealWithRole(dealID, userID int64, isAuthor bool, paymentProof string) error {
-	log.Printf("[INFO] Подтверждение сделки ID=%d пользователем ID=%d (isAuthor=%v)", dealID, userID, isAuthor)
+	s.logf("[INFO] Подтверждение сделки ID=%d пользователем ID=%d (isAuthor=%v)", dealID, userID, isAuthor)
 
 	// Проверяем доступ к сделке
 	deal, err := s.GetDeal(dealID, userID)
@@ -842,11 +901,6 @@ func (s *Service) ConfirmDealWithRole(dealID, userID int64, isAuthor bool, payme
 		return err
 	}
 
-	// Проверяем что сделка в подходящем статусе
-	if deal.Status != model.DealStatusInProgress && deal.Status != model.DealStatusWaitingConfirmation {
-		return fmt.Errorf("сделка в статусе '%s' не может быть подтверждена", deal.Status)
-	}
-
 	// Проверяем соответствие роли
 	if isAuthor && deal.AuthorID != userID {
 		return fmt.Errorf("пользователь не является автором сделки")
@@ -855,39 +909,37 @@ func (s *Service) ConfirmDealWithRole(dealID, userID int64, isAuthor bool, payme
 		return fmt.Errorf("пользователь не является контрагентом сделки")
 	}
 
-	// Подтверждаем сделку с указанием роли
-	if err := s.repo.ConfirmDealWithRole(dealID, userID, isAuthor, paymentProof); err != nil {
-		log.Printf("[ERROR] Не удалось подтвердить сделку ID=%d: %v", dealID, err)
-		return fmt.Errorf("не удалось подтвердить сделку: %w", err)
+	// Проверяем что подтверждение допустимо в текущем статусе сделки
+	if _, err := model.AuthorizeDealTransition(deal.Status, deal.ConfirmAction(isAuthor), model.DealActorParticipant); err != nil {
+		return err
 	}
 
-	// Получаем обновленную сделку для проверки статуса
-	updatedDeal, err := s.repo.GetDealByID(dealID)
-	if err != nil {
-		log.Printf("[WARN] Не удалось получить обновленную сделку ID=%d: %v", dealID, err)
-	} else {
-		// Определяем кто подтвердил и кто ждет подтверждения
-		var confirmedByUserID, waitingForUserID int64
-
-		if isAuthor {
-			confirmedByUserID = deal.AuthorID
-			waitingForUserID = deal.CounterpartyID
-		} else {
-			confirmedByUserID = deal.CounterpartyID
-			waitingForUserID = deal.AuthorID
+	// Подтверждаем сделку с указанием роли. Репозиторий возвращает фактически примененный переход:
+	// если вторая сторона подтвердила параллельно, сделка уже будет завершена.
+	// Завершение исполненной заявки и статистика сохраняются в той же транзакции
+	var transition model.DealTransition
+	err = s.inTransaction(func(repo repository.RepositoryInterface) error {
+		var err error
+		transition, err = repo.ConfirmDealWithRole(dealID, userID, isAuthor, paymentProof)
+		if err != nil {
+			return err
 		}
 
-		// Проверяем завершена ли сделка полностью
-		if updatedDeal.Status == model.DealStatusCompleted {
-			// Сделка завершена - отправляем уведомления о завершении обеим сторонам
-			go s.sendDealCompletedNotifications(updatedDeal)
-		} else if updatedDeal.Status == model.DealStatusWaitingConfirmation {
-			// Одна сторона подтвердила, вторая еще нет - отправляем уведомление ожидающему
-			go s.sendDealConfirmedNotification(updatedDeal, confirmedByUserID, waitingForUserID)
+		// Получаем обновленную сделку для уведомлений
+		if updatedDeal, err := repo.GetDealByID(dealID); err != nil {
+			s.logf("[WARN] Не удалось получить обновленную сделку ID=%d: %v", dealID, err)
+		} else {
+			deal = updatedDeal
 		}
+		return s.applyDealEffects(repo, deal, transition)
+	})
+	if err != nil {
+		s.logf("[ERROR] Не удалось подтвердить сделку ID=%d: %v", dealID, err)
+		return fmt.Errorf("не удалось подтвердить сделку: %w", err)
 	}
+	s.announceDealTransition(deal, transition, userID)
 
-	log.Printf("[INFO] Сделка ID=%d подтверждена пользователем ID=%d как %s", dealID, userID,
+	s.logf("[INFO] Сделка ID=%d подтверждена пользователем ID=%d как %s", dealID, userID,
 		map[bool]string{true: "автор", false: "контрагент"}[isAuthor])
 	return nil
 }
@@ -898,28 +950,28 @@ func (s *Service) ConfirmDealWithRole(dealID, userID int64, isAuthor bool, payme
 
 // CreateReview создает новый отзыв после завершения сделки
 func (s *Service) CreateReview(userID int64, reviewData *model.CreateReviewRequest) (*model.Review, error) {
-	log.Printf("[INFO] Создание отзыва от пользователя ID=%d для сделки ID=%d", userID, reviewData.DealID)
+	s.logf("[INFO] Создание отзыва от пользователя ID=%d для сделки ID=%d", userID, reviewData.DealID)
 
 	// Проверяем права на создание отзыва
-	log.Printf("[DEBUG] Проверяем права на отзыв: DealID=%d, FromUserID=%d, ToUserID=%d",
+	s.logf("[DEBUG] Проверяем права на отзыв: DealID=%d, FromUserID=%d, ToUserID=%d",
 		reviewData.DealID, userID, reviewData.ToUserID)
 
 	canReview, err := s.repo.CheckCanReview(reviewData.DealID, userID, reviewData.ToUserID)
 	if err != nil {
-		log.Printf("[WARN] Ошибка проверки прав на отзыв пользователя ID=%d: %v", userID, err)
+		s.logf("[WARN] Ошибка проверки прав на отзыв пользователя ID=%d: %v", userID, err)
 		return nil, fmt.Errorf("ошибка проверки прав на отзыв: %w", err)
 	}
 
-	log.Printf("[DEBUG] Результат проверки прав: canReview=%t", canReview)
+	s.logf("[DEBUG] Результат проверки прав: canReview=%t", canReview)
 
 	if !canReview {
-		log.Printf("[WARN] Пользователь ID=%d не может оставить отзыв для сделки ID=%d", userID, reviewData.DealID)
+		s.logf("[WARN] Пользователь ID=%d не может оставить отзыв для сделки ID=%d", userID, reviewData.DealID)
 		return nil, fmt.Errorf("отзыв уже оставлен или сделка не завершена")
 	}
 
 	// Валидируем данные отзыва
 	if err := s.validateReviewData(reviewData); err != nil {
-		log.Printf("[WARN] Невалидные данные отзыва от пользователя ID=%d: %v", userID, err)
+		s.logf("[WARN] Невалидные данные отзыва от пользователя ID=%d: %v", userID, err)
 		return nil, err
 	}
 
@@ -936,17 +988,17 @@ func (s *Service) CreateReview(userID int64, reviewData *model.CreateReviewReque
 
 	// Сохраняем отзыв в базе данных
 	if err := s.repo.CreateReview(review); err != nil {
-		log.Printf("[ERROR] Не удалось создать отзыв: %v", err)
+		s.logf("[ERROR] Не удалось создать отзыв: %v", err)
 		return nil, fmt.Errorf("не удалось создать отзыв: %w", err)
 	}
 
-	log.Printf("[INFO] Отзыв создан успешно: ID=%d, Rating=%d", review.ID, review.Rating)
+	s.logf("[INFO] Отзыв создан успешно: ID=%d, Rating=%d", review.ID, review.Rating)
 	return review, nil
 }
 
 // GetUserReviews получает отзывы о пользователе с пагинацией
 func (s *Service) GetUserReviews(userID int64, limit, offset int) ([]*model.Review, error) {
-	log.Printf("[INFO] Получение отзывов для пользователя ID=%d (limit=%d, offset=%d)", userID, limit, offset)
+	s.logf("[INFO] Получение отзывов для пользователя ID=%d (limit=%d, offset=%d)", userID, limit, offset)
 
 	// Устанавливаем разумные лимиты
 	if limit <= 0 || limit > 50 {
@@ -959,21 +1011,21 @@ func (s *Service) GetUserReviews(userID int64, limit, offset int) ([]*model.Revi
 	// Получаем отзывы из репозитория
 	reviews, err := s.repo.GetReviewsByUserID(userID, limit, offset)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось получить отзывы для пользователя ID=%d: %v", userID, err)
+		s.logf("[ERROR] Не удалось получить отзывы для пользователя ID=%d: %v", userID, err)
 		return nil, fmt.Errorf("не удалось получить отзывы: %w", err)
 	}
 
-	log.Printf("[INFO] Получено отзывов: %d", len(reviews))
+	s.logf("[INFO] Получено отзывов: %d", len(reviews))
 	return reviews, nil
 }
 
 // GetUserRating получает рейтинг пользователя
 func (s *Service) GetUserRating(userID int64) (*model.Rating, error) {
-	log.Printf("[INFO] Получение рейтинга пользователя ID=%d", userID)
+	s.logf("[INFO] Получение рейтинга пользователя ID=%d", userID)
 
 	rating, err := s.repo.GetUserRating(userID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось получить рейтинг пользователя ID=%d: %v", userID, err)
+		s.logf("[ERROR] Не удалось получить рейтинг пользователя ID=%d: %v", userID, err)
 		return nil, fmt.Errorf("не удалось получить рейтинг: %w", err)
 	}
 
@@ -982,11 +1034,11 @@ func (s *Service) GetUserRating(userID int64) (*model.Rating, error) {
 
 // GetUserProfile получает полный профиль пользователя включая рейтинг и статистику
 func (s *Service) GetUserProfile(userID int64) (*model.ReviewStats, error) {
-	log.Printf("[INFO] Получение профиля пользователя ID=%d", userID)
+	s.logf("[INFO] Получение профиля пользователя ID=%d", userID)
 
 	stats, err := s.repo.GetUserReviewStats(userID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось получить статистику пользователя ID=%d: %v", userID, err)
+		s.logf("[ERROR] Не удалось получить статистику пользователя ID=%d: %v", userID, err)
 		return nil, fmt.Errorf("не удалось получить профиль пользователя: %w", err)
 	}
 
@@ -995,19 +1047,19 @@ func (s *Service) GetUserProfile(userID int64) (*model.ReviewStats, error) {
 
 // GetFullUserProfile получает полную информацию о пользователе включая данные профиля и статистику отзывов
 func (s *Service) GetFullUserProfile(userID int64) (*model.FullUserProfile, error) {
-	log.Printf("[INFO] Получение полного профиля пользователя ID=%d", userID)
+	s.logf("[INFO] Получение полного профиля пользователя ID=%d", userID)
 
 	// Получаем данные пользователя
 	user, err := s.repo.GetUserByID(userID)
 	if err != nil {
-		log.Printf("[ERROR] Пользователь ID=%d не найден: %v", userID, err)
+		s.logf("[ERROR] Пользователь ID=%d не найден: %v", userID, err)
 		return nil, fmt.Errorf("пользователь не найден")
 	}
 
 	// Получаем статистику отзывов
 	stats, err := s.repo.GetUserReviewStats(userID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось получить статистику пользователя ID=%d: %v", userID, err)
+		s.logf("[ERROR] Не удалось получить статистику пользователя ID=%d: %v", userID, err)
 		return nil, fmt.Errorf("не удалось получить статистику пользователя: %w", err)
 	}
 
@@ -1017,18 +1069,18 @@ func (s *Service) GetFullUserProfile(userID int64) (*model.FullUserProfile, erro
 		Stats: stats,
 	}
 
-	log.Printf("[INFO] Полный профиль пользователя ID=%d получен успешно", userID)
+	s.logf("[INFO] Полный профиль пользователя ID=%d получен успешно", userID)
 	return userProfile, nil
 }
 
 // GetUserStats получает подробную статистику пользователя
 func (s *Service) GetUserStats(userID int64) (*model.UserStats, error) {
-	log.Printf("[INFO] Получение статистики пользователя ID=%d", userID)
+	s.logf("[INFO] Получение статистики пользователя ID=%d", userID)
 
 	// Получаем статистику отзывов
 	reviewStats, err := s.repo.GetUserReviewStats(userID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось получить статистику отзывов пользователя ID=%d: %v", userID, err)
+		s.logf("[ERROR] Не удалось получить статистику отзывов пользователя ID=%d: %v", userID, err)
 		return nil, fmt.Errorf("не удалось получить статистику отзывов: %w", err)
 	}
 
@@ -1039,18 +1091,18 @@ func (s *Service) GetUserStats(userID int64) (*model.UserStats, error) {
 		Offset: 0,
 	}
 
-	log.Printf("[DEBUG] Поиск заявок пользователя ID=%d с фильтром: %+v", userID, orderFilter)
+	s.logf("[DEBUG] Поиск заявок пользователя ID=%d с фильтром: %+v", userID, orderFilter)
 	orders, err := s.repo.GetOrdersByFilter(orderFilter)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось получить заявки пользователя ID=%d: %v", userID, err)
+		s.logf("[ERROR] Не удалось получить заявки пользователя ID=%d: %v", userID, err)
 		return nil, fmt.Errorf("не удалось получить заявки: %w", err)
 	}
-	log.Printf("[DEBUG] Найдено заявок для пользователя ID=%d: %d", userID, len(orders))
+	s.logf("[DEBUG] Найдено заявок для пользователя ID=%d: %d", userID, len(orders))
 
 	// Получаем сделки пользователя
 	deals, err := s.repo.GetDealsByUserID(userID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось получить сделки пользователя ID=%d: %v", userID, err)
+		s.logf("[ERROR] Не удалось получить сделки пользователя ID=%d: %v", userID, err)
 		return nil, fmt.Errorf("не удалось получить сделки: %w", err)
 	}
 
@@ -1125,13 +1177,13 @@ func (s *Service) GetUserStats(userID int64) (*model.UserStats, error) {
 		TotalReviews:     reviewStats.TotalReviews,
 	}
 
-	log.Printf("[INFO] Статистика пользователя ID=%d собрана: %d заявок, %d сделок", userID, totalOrders, totalDeals)
+	s.logf("[INFO] Статистика пользователя ID=%d собрана: %d заявок, %d сделок", userID, totalOrders, totalDeals)
 	return stats, nil
 }
 
 // ReportReview создает жалобу на неподходящий отзыв
 func (s *Service) ReportReview(userID, reviewID int64, reason, comment string) error {
-	log.Printf("[INFO] Жалоба на отзыв ID=%d от пользователя ID=%d", reviewID, userID)
+	s.logf("[INFO] Жалоба на отзыв ID=%d от пользователя ID=%d", reviewID, userID)
 
 	// Валидируем данные жалобы
 	if reason == "" {
@@ -1170,11 +1222,11 @@ func (s *Service) ReportReview(userID, reviewID int64, reason, comment string) e
 
 	// Сохраняем жалобу в базе данных
 	if err := s.repo.ReportReview(report); err != nil {
-		log.Printf("[ERROR] Не удалось создать жалобу: %v", err)
+		s.logf("[ERROR] Не удалось создать жалобу: %v", err)
 		return fmt.Errorf("не удалось создать жалобу: %w", err)
 	}
 
-	log.Printf("[INFO] Жалоба создана успешно: ID=%d", report.ID)
+	s.logf("[INFO] Жалоба создана успешно: ID=%d", report.ID)
 	return nil
 }
 
@@ -1207,27 +1259,44 @@ func (s *Service) validateReviewData(review *model.CreateReviewRequest) error {
 
 // CreateResponse создает новый отклик на заявку
 func (s *Service) CreateResponse(userID int64, responseData *model.CreateResponseRequest) (*model.Response, error) {
-	log.Printf("[INFO] Создание отклика пользователем ID=%d на заявку ID=%d", userID, responseData.OrderID)
+	s.logf("[INFO] Создание отклика пользователем ID=%d на заявку ID=%d", userID, responseData.OrderID)
 
 	// Проверяем что заявка существует и активна
 	order, err := s.GetOrder(responseData.OrderID)
 	if err != nil {
-		log.Printf("[ERROR] Заявка не найдена: %v", err)
+		s.logf("[ERROR] Заявка не найдена: %v", err)
 		return nil, fmt.Errorf("заявка не найдена")
 	}
 
 	// Проверяем что это не своя заявка
 	if order.UserID == userID {
-		log.Printf("[WARN] Пользователь ID=%d пытается откликнуться на свою заявку", userID)
+		s.logf("[WARN] Пользователь ID=%d пытается откликнуться на свою заявку", userID)
 		return nil, fmt.Errorf("нельзя откликаться на собственную заявку")
 	}
 
-	// Проверяем статус заявки
-	if order.Status != model.OrderStatusActive {
-		log.Printf("[WARN] Заявка ID=%d имеет статус %s, нельзя откликаться", responseData.OrderID, order.Status)
+	// Проверяем статус заявки: на заявку с откликами можно откликаться, пока в ней есть остаток
+	if order.Status != model.OrderStatusActive && order.Status != model.OrderStatusHasResponses {
+		s.logf("[WARN] Заявка ID=%d имеет статус %s, нельзя откликаться", responseData.OrderID, order.Status)
 		return nil, fmt.Errorf("заявка недоступна для откликов")
 	}
 
+	// Проверяем предложенные условия: цену и объем по остатку и лимитам заявки
+	if responseData.Price < 0 {
+		return nil, fmt.Errorf("цена не может быть отрицательной")
+	}
+	dealPrice := order.Price
+	if responseData.Price > 0 {
+		dealPrice = responseData.Price
+	}
+	requestedAmount, err := resolveFillAmount(order, responseData.Amount, dealPrice)
+	if err != nil {
+		s.logf("[WARN] Недопустимый объем отклика на заявку ID=%d: %v", responseData.OrderID, err)
+		return nil, err
+	}
+	if responseData.PaymentMethod != "" && !order.HasPaymentMethod(responseData.PaymentMethod) {
+		return nil, fmt.Errorf("способ оплаты '%s' не указан в заявке", responseData.PaymentMethod)
+	}
+
 	// Проверяем, есть ли уже отклик от этого пользователя на эту заявку
 	existingResponses, err := s.repo.GetResponsesByFilter(&model.ResponseFilter{
 		OrderID: &responseData.OrderID,
@@ -1235,7 +1304,7 @@ func (s *Service) CreateResponse(userID int64, responseData *model.CreateRespons
 		Limit:   1,
 	})
 	if err != nil {
-		log.Printf("[ERROR] Не удалось проверить существующие отклики: %v", err)
+		s.logf("[ERROR] Не удалось проверить существующие отклики: %v", err)
 		return nil, fmt.Errorf("ошибка при проверке существующих откликов")
 	}
 
@@ -1243,62 +1312,77 @@ func (s *Service) CreateResponse(userID int64, responseData *model.CreateRespons
 
 	if len(existingResponses) > 0 {
 		existingResponse := existingResponses[0]
-		log.Printf("[INFO] Найден существующий отклик ID=%d со статусом %s", existingResponse.ID, existingResponse.Status)
+		s.logf("[INFO] Найден существующий отклик ID=%d со статусом %s", existingResponse.ID, existingResponse.Status)
 
-		if existingResponse.Status == model.ResponseStatusWaiting {
-			log.Printf("[WARN] Пользователь ID=%d уже откликнулся на заявку ID=%d", userID, responseData.OrderID)
+		if existingResponse.IsOpen() {
+			s.logf("[WARN] Пользователь ID=%d уже откликнулся на заявку ID=%d", userID, responseData.OrderID)
 			return nil, fmt.Errorf("вы уже откликнулись на эту заявку")
-		} else if existingResponse.Status == model.ResponseStatusRejected {
-			// Обновляем отклонённый отклик на новое сообщение и статус waiting
-			log.Printf("[INFO] Обновляем отклонённый отклик ID=%d на новое сообщение", existingResponse.ID)
+		} else if existingResponse.Status == model.ResponseStatusRejected || existingResponse.Status == model.ResponseStatusWithdrawn {
+			// Обновляем отклонённый или отозванный отклик на новое сообщение и статус waiting
+			s.logf("[INFO] Обновляем отклик ID=%d (%s) на новое сообщение", existingResponse.ID, existingResponse.Status)
 
 			existingResponse.Message = responseData.Message
+			existingResponse.ProposedPrice = responseData.Price
+			existingResponse.RequestedAmount = requestedAmount
+			existingResponse.PaymentMethod = responseData.PaymentMethod
 			existingResponse.Status = model.ResponseStatusWaiting
 
-			if err := s.repo.UpdateResponseStatus(existingResponse.ID, model.ResponseStatusWaiting); err != nil {
-				log.Printf("[ERROR] Не удалось обновить статус отклика: %v", err)
+			if err := s.repo.ReopenResponse(existingResponse); err != nil {
+				s.logf("[ERROR] Не удалось обновить отклик: %v", err)
 				return nil, fmt.Errorf("не удалось обновить отклик: %w", err)
 			}
 
-			// TODO: Также обновить сообщение отклика в БД (нужен метод UpdateResponseMessage)
-			log.Printf("[INFO] Отклик ID=%d обновлён со статусом waiting", existingResponse.ID)
+			s.logf("[INFO] Отклик ID=%d обновлён со статусом waiting", existingResponse.ID)
 			response = existingResponse
 		} else {
 			// Отклик принят (accepted) - нельзя повторно откликаться
-			log.Printf("[WARN] Отклик пользователя ID=%d уже принят для заявки ID=%d", userID, responseData.OrderID)
+			s.logf("[WARN] Отклик пользователя ID=%d уже принят для заявки ID=%d", userID, responseData.OrderID)
 			return nil, fmt.Errorf("ваш отклик уже принят, повторно откликаться нельзя")
 		}
 	} else {
 		// Создаем новый отклик
-		log.Printf("[INFO] Создаём новый отклик для пользователя ID=%d на заявку ID=%d", userID, responseData.OrderID)
+		s.logf("[INFO] Создаём новый отклик для пользователя ID=%d на заявку ID=%d", userID, responseData.OrderID)
 		response = &model.Response{
-			OrderID: responseData.OrderID,
-			UserID:  userID,
-			Message: responseData.Message,
-			Status:  model.ResponseStatusWaiting,
+			OrderID:         responseData.OrderID,
+			UserID:          userID,
+			Message:         responseData.Message,
+			RequestedAmount: requestedAmount,
+			ProposedPrice:   responseData.Price,
+			PaymentMethod:   responseData.PaymentMethod,
+			Status:          model.ResponseStatusWaiting,
 		}
 
 		// Сохраняем отклик в репозитории
 		if err := s.repo.CreateResponse(response); err != nil {
-			log.Printf("[ERROR] Не удалось создать отклик: %v", err)
+			s.logf("[ERROR] Не удалось создать отклик: %v", err)
 			return nil, fmt.Errorf("не удалось создать отклик: %w", err)
 		}
 	}
 
+	// Первоначальные условия открывают историю переговоров по отклику
+	s.recordResponseOffer(response, userID, dealPrice, requestedAmount, responseData.Message)
+
+	// Отклик, подходящий под правила автора, сразу становится сделкой
+	if responseData.AutoAccept {
+		if deal := s.tryAutoAcceptResponse(order, response); deal != nil {
+			return response, nil
+		}
+	}
+
 	// Отправляем уведомление автору заявки о новом отклике
-	go s.sendNewResponseNotification(order, response, userID)
+	s.goNotify(func() { s.sendNewResponseNotification(order, response, userID) })
 
-	log.Printf("[INFO] Отклик создан успешно: ID=%d", response.ID)
+	s.logf("[INFO] Отклик создан успешно: ID=%d", response.ID)
 	return response, nil
 }
 
 // GetMyResponses получает отклики пользователя (которые он оставлял)
 func (s *Service) GetMyResponses(userID int64) ([]*model.Response, error) {
-	log.Printf("[INFO] Получение откликов пользователя ID=%d", userID)
+	s.logf("[INFO] Получение откликов пользователя ID=%d", userID)
 
 	responses, err := s.repo.GetResponsesFromUser(userID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось получить отклики пользователя: %v", err)
+		s.logf("[ERROR] Не удалось получить отклики пользователя: %v", err)
 		return nil, fmt.Errorf("не удалось получить отклики: %w", err)
 	}
 
@@ -1330,7 +1414,7 @@ func (s *Service) GetMyResponses(userID int64) ([]*model.Response, error) {
 				}
 				response.AuthorUsername = author.Username
 
-				log.Printf("[DEBUG] Обогащен отклик ID=%d: User=%s(@%s) -> Author=%s(@%s), Order=%s %s",
+				s.logf("[DEBUG] Обогащен отклик ID=%d: User=%s(@%s) -> Author=%s(@%s), Order=%s %s",
 					response.ID, response.UserName, response.Username,
 					response.AuthorName, response.AuthorUsername,
 					response.OrderType, response.Cryptocurrency)
@@ -1338,17 +1422,20 @@ func (s *Service) GetMyResponses(userID int64) ([]*model.Response, error) {
 		}
 	}
 
-	log.Printf("[INFO] Найдено откликов пользователя ID=%d: %d", userID, len(responses))
+	// История переговоров по каждому отклику
+	s.attachResponseOffers(responses)
+
+	s.logf("[INFO] Найдено откликов пользователя ID=%d: %d", userID, len(responses))
 	return responses, nil
 }
 
 // GetResponsesToMyOrders получает отклики на заявки пользователя (которые ему оставляли)
 func (s *Service) GetResponsesToMyOrders(authorID int64) ([]*model.Response, error) {
-	log.Printf("[INFO] Получение откликов на заявки автора ID=%d", authorID)
+	s.logf("[INFO] Получение откликов на заявки автора ID=%d", authorID)
 
 	responses, err := s.repo.GetResponsesForAuthor(authorID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось получить отклики на заявки: %v", err)
+		s.logf("[ERROR] Не удалось получить отклики на заявки: %v", err)
 		return nil, fmt.Errorf("не удалось получить отклики: %w", err)
 	}
 
@@ -1380,7 +1467,7 @@ func (s *Service) GetResponsesToMyOrders(authorID int64) ([]*model.Response, err
 				}
 				response.AuthorUsername = author.Username
 
-				log.Printf("[DEBUG] Обогащен отклик на заявку ID=%d: %s(@%s) -> Author=%s(@%s), Order=%s %s",
+				s.logf("[DEBUG] Обогащен отклик на заявку ID=%d: %s(@%s) -> Author=%s(@%s), Order=%s %s",
 					response.ID, response.UserName, response.Username,
 					response.AuthorName, response.AuthorUsername,
 					response.OrderType, response.Cryptocurrency)
@@ -1388,31 +1475,20 @@ func (s *Service) GetResponsesToMyOrders(authorID int64) ([]*model.Response, err
 		}
 	}
 
-	log.Printf("[INFO] Найдено откликов на заявки автора ID=%d: %d", authorID, len(responses))
+	// История переговоров по каждому отклику
+	s.attachResponseOffers(responses)
+
+	s.logf("[INFO] Найдено откликов на заявки автора ID=%d: %d", authorID, len(responses))
 	return responses, nil
 }
 
-// AcceptResponse принимает отклик и создает сделку
-func (s *Service) AcceptResponse(responseID, authorID int64) (*model.Deal, error) {
-	log.Printf("[INFO] Принятие отклика ID=%d автором ID=%d", responseID, authorID)
+// AcceptResponse принимает текущие условия отклика и создает сделку по ним
+func (s *Service) AcceptResponse(responseID, userID int64) (*model.Deal, error) {
+	s.logf("[INFO] Принятие отклика ID=%d пользователем ID=%d", responseID, userID)
 
-	// Получаем отклик через фильтр
-	filter := &model.ResponseFilter{Limit: 100}
-	responses, err := s.repo.GetResponsesByFilter(filter)
+	response, err := s.getResponseByID(responseID)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось получить отклик: %w", err)
-	}
-
-	var response *model.Response
-	for _, r := range responses {
-		if r.ID == responseID {
-			response = r
-			break
-		}
-	}
-
-	if response == nil {
-		return nil, fmt.Errorf("отклик не найден")
+		return nil, err
 	}
 
 	// Получаем заявку
@@ -1421,79 +1497,126 @@ func (s *Service) AcceptResponse(responseID, authorID int64) (*model.Deal, error
 		return nil, fmt.Errorf("заявка не найдена: %w", err)
 	}
 
-	// Проверяем что автор заявки принимает отклик
-	if order.UserID != authorID {
-		return nil, fmt.Errorf("только автор заявки может принимать отклики")
+	// Принимает та сторона, чья очередь отвечать: автор заявки - отклик,
+	// откликнувшийся - встречное предложение автора
+	if err := checkResponseTurn(order, response, userID); err != nil {
+		return nil, err
 	}
 
-	// Проверяем статус отклика
-	if response.Status != model.ResponseStatusWaiting {
-		return nil, fmt.Errorf("отклик уже был рассмотрен")
+	deal, err := s.createDealFromResponse(order, response, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	// Принимаем отклик
-	if err := s.repo.UpdateResponseStatus(responseID, model.ResponseStatusAccepted); err != nil {
-		return nil, fmt.Errorf("не удалось принять отклик: %w", err)
-	}
+	// Отправляем уведомления участникам
+	s.goNotify(func() { s.sendResponseAcceptedNotification(order, response, deal) })
+	s.goNotify(func() { s.sendDealCreatedNotifications(deal) })
 
-	// Создаем сделку
-	deal := &model.Deal{
-		ResponseID:     responseID,
-		OrderID:        order.ID,
-		AuthorID:       authorID,
-		CounterpartyID: response.UserID,
-		Cryptocurrency: order.Cryptocurrency,
-		FiatCurrency:   order.FiatCurrency,
-		Amount:         order.Amount,
-		Price:          order.Price,
-		TotalAmount:    order.TotalAmount,
-		PaymentMethods: order.PaymentMethods,
-		OrderType:      order.Type,
-		Status:         model.DealStatusInProgress,
-		// Убираем ExpiresAt - таймеры больше не используются
-	}
+	s.logf("[INFO] Отклик принят, создана сделка ID=%d", deal.ID)
+	return deal, nil
+}
 
-	if err := s.repo.CreateDeal(deal); err != nil {
-		return nil, fmt.Errorf("не удалось создать сделку: %w", err)
-	}
+// createDealFromResponse принимает текущие условия отклика и создает по ним сделку:
+// занимает объем в заявке, переводит отклик в accepted и отклоняет отклики, которые больше не помещаются.
+// В хранилищах с транзакциями все изменения фиксируются вместе; в остальных при ошибке объем возвращается в заявки.
+// match - встречная заявка откликнувшегося при автосопоставлении (объем занимается и в ней), иначе nil.
+// Права пользователя проверяет вызывающий код, уведомления он же и отправляет
+// (кроме уведомлений об автоматически отклоненных откликах)
+func (s *Service) createDealFromResponse(order *model.Order, response *model.Response, match *model.Order) (*model.Deal, error) {
+	// Сделка по плавающей заявке заключается только по актуальному курсу, а не по последней сохраненной цене
+	if err := s.priceFloatingOrder(order); err != nil {
+		return nil, fmt.Errorf("отклик нельзя принять: %w", err)
+	}
+
+	// Остаток заявки мог уменьшиться после отклика - проверяем объем повторно
+	price := response.DealPrice(order)
+	amount, err := resolveFillAmount(order, response.RequestedAmount, price)
+	if err != nil {
+		return nil, fmt.Errorf("отклик нельзя принять: %w", err)
+	}
+
+	var deal *model.Deal
+	var reserved *model.Order
+	var rejected []*model.Response
+	err = s.inTransaction(func(repo repository.RepositoryInterface) error {
+		// Занимаем объем сделки в заявке; исчерпанная заявка снимается с рынка
+		var err error
+		reserved, err = repo.ReserveOrderAmount(order.ID, amount)
+		if err != nil {
+			return fmt.Errorf("не удалось занять объем заявки: %w", err)
+		}
+		paymentMethods := order.PaymentMethods
+		if match != nil {
+			if _, err := repo.ReserveOrderAmount(match.ID, amount); err != nil {
+				releaseOrderAmountOnError(repo, order.ID, amount)
+				return fmt.Errorf("не удалось занять объем встречной заявки: %w", err)
+			}
+			paymentMethods = commonPaymentMethods(order, match)
+		}
 
-	// Обновляем статус заявки на "в сделке"
-	if err := s.repo.UpdateOrderStatus(order.ID, model.OrderStatusInDeal); err != nil {
-		log.Printf("[WARN] Не удалось обновить статус заявки: %v", err)
-	}
+		// Принимаем отклик
+		if err := repo.UpdateResponseStatus(response.ID, model.ResponseStatusAccepted); err != nil {
+			releaseDealOrdersOnError(repo, order, match, amount)
+			return fmt.Errorf("не удалось принять отклик: %w", err)
+		}
 
-	// Отклоняем все остальные отклики на эту заявку
-	s.rejectOtherResponses(order.ID, responseID)
+		// Если откликнувшийся выбрал способ оплаты, сделка проводится только им
+		if response.PaymentMethod != "" {
+			paymentMethods = []string{response.PaymentMethod}
+		}
 
-	// Отправляем уведомления участникам
-	go s.sendResponseAcceptedNotification(order, response, deal)
-	go s.sendDealCreatedNotifications(deal)
+		// Создаем сделку на выбранную часть заявки
+		deal = &model.Deal{
+			ResponseID:     response.ID,
+			OrderID:        order.ID,
+			AuthorID:       order.UserID,
+			CounterpartyID: response.UserID,
+			Cryptocurrency: order.Cryptocurrency,
+			FiatCurrency:   order.FiatCurrency,
+			Amount:         amount,
+			Price:          price,
+			TotalAmount:    amount * price,
+			PaymentMethods: paymentMethods,
+			OrderType:      order.Type,
+			Status:         model.DealStatusInProgress,
+			ExpiresAt:      time.Now().Add(s.dealConfirmationTimeout()), // Срок первого подтверждения
+		}
+		if match != nil {
+			deal.MatchedOrderID = match.ID
+		}
+
+		if err := repo.CreateDeal(deal); err != nil {
+			releaseDealOrdersOnError(repo, order, match, amount)
+			return fmt.Errorf("не удалось создать сделку: %w", err)
+		}
+
+		// Отклоняем отклики, которые больше не помещаются в остаток заявки
+		rejected = rejectOtherResponses(repo, reserved, response.ID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	log.Printf("[INFO] Отклик принят, создана сделка ID=%d", deal.ID)
+	// Уведомляем об автоматическом отклонении только после фиксации изменений
+	for _, other := range rejected {
+		s.goNotify(func() { s.sendResponseRejectedNotification(reserved, other) })
+		s.logf("[INFO] Отправлено уведомление об автоматическом отклонении отклика ID=%d", other.ID)
+	}
+
+	response.Status = model.ResponseStatusAccepted
+	response.DealID = deal.ID
 	return deal, nil
 }
 
 // RejectResponse отклоняет отклик
 func (s *Service) RejectResponse(responseID, authorID int64, reason string) error {
-	log.Printf("[INFO] Отклонение отклика ID=%d автором ID=%d", responseID, authorID)
+	s.logf("[INFO] Отклонение отклика ID=%d автором ID=%d", responseID, authorID)
 
 	// Получаем отклик для отправки уведомления
-	filter := &model.ResponseFilter{Limit: 100}
-	responses, err := s.repo.GetResponsesByFilter(filter)
+	response, err := s.getResponseByID(responseID)
 	if err != nil {
-		return fmt.Errorf("не удалось получить отклик: %w", err)
-	}
-
-	var response *model.Response
-	for _, r := range responses {
-		if r.ID == responseID {
-			response = r
-			break
-		}
-	}
-
-	if response == nil {
-		return fmt.Errorf("отклик не найден")
+		return err
 	}
 
 	// Получаем заявку
@@ -1508,7 +1631,7 @@ func (s *Service) RejectResponse(responseID, authorID int64, reason string) erro
 	}
 
 	// Проверяем статус отклика
-	if response.Status != model.ResponseStatusWaiting {
+	if !response.IsOpen() {
 		return fmt.Errorf("отклик уже был рассмотрен")
 	}
 
@@ -1518,40 +1641,96 @@ func (s *Service) RejectResponse(responseID, authorID int64, reason string) erro
 	}
 
 	// Отправляем уведомление пользователю об отклонении его отклика
-	go s.sendResponseRejectedNotification(order, response)
+	s.goNotify(func() { s.sendResponseRejectedNotification(order, response) })
 
-	log.Printf("[INFO] Отклик ID=%d отклонен", responseID)
+	s.logf("[INFO] Отклик ID=%d отклонен", responseID)
 	return nil
 }
 
-// rejectOtherResponses отклоняет все остальные отклики на заявку кроме принятого
-func (s *Service) rejectOtherResponses(orderID, acceptedResponseID int64) {
-	responses, err := s.repo.GetResponsesForOrder(orderID)
+// WithdrawResponse отзывает отклик откликнувшимся, пока автор заявки не принял решение
+func (s *Service) WithdrawResponse(responseID, userID int64) error {
+	s.logf("[INFO] Отзыв отклика ID=%d пользователем ID=%d", responseID, userID)
+
+	response, err := s.getResponseByID(responseID)
 	if err != nil {
-		log.Printf("[WARN] Не удалось получить отклики для отклонения: %v", err)
-		return
+		return err
+	}
+
+	// Отозвать отклик может только тот, кто его оставил
+	if response.UserID != userID {
+		return fmt.Errorf("отозвать отклик может только его автор")
+	}
+	if !response.IsOpen() {
+		return fmt.Errorf("отклик уже был рассмотрен")
+	}
+
+	if err := s.repo.WithdrawResponse(responseID); err != nil {
+		s.logf("[ERROR] Не удалось отозвать отклик ID=%d: %v", responseID, err)
+		return fmt.Errorf("не удалось отозвать отклик: %w", err)
+	}
+
+	// Уведомляем автора заявки
+	if order, err := s.GetOrder(response.OrderID); err == nil {
+		s.goNotify(func() { s.sendResponseWithdrawnNotification(order, response) })
+	} else {
+		s.logf("[WARN] Не удалось получить заявку ID=%d для уведомления об отзыве: %v", response.OrderID, err)
+	}
+
+	s.logf("[INFO] Отклик ID=%d отозван", responseID)
+	return nil
+}
+
+// inTransaction выполняет fn в транзакции хранилища (все хранилища приложения реализуют repository.Transactional);
+// хранилище без транзакций (тестовые обертки) получает fn напрямую
+func (s *Service) inTransaction(fn func(repo repository.RepositoryInterface) error) error {
+	if transactional, ok := s.repo.(repository.Transactional); ok {
+		return transactional.InTransaction(fn)
 	}
+	return fn(s.repo)
+}
+
+// releaseOrderAmountOnError возвращает занятый объем в заявку, если сделку создать не удалось
+func releaseOrderAmountOnError(repo repository.RepositoryInterface, orderID int64, amount float64) {
+	if err := repo.ReleaseOrderAmount(orderID, amount); err != nil {
+		log.Printf("[ERROR] Не удалось вернуть объем %.8f в заявку ID=%d: %v", amount, orderID, err)
+	}
+}
+
+// releaseDealOrdersOnError возвращает объем несостоявшейся сделки в заявку и во встречную заявку (если есть)
+func releaseDealOrdersOnError(repo repository.RepositoryInterface, order, match *model.Order, amount float64) {
+	releaseOrderAmountOnError(repo, order.ID, amount)
+	if match != nil {
+		releaseOrderAmountOnError(repo, match.ID, amount)
+	}
+}
 
-	// Получаем заявку для отправки уведомлений
-	order, err := s.GetOrder(orderID)
+// rejectOtherResponses отклоняет ожидающие отклики на заявку, которые больше не помещаются в ее остаток,
+// и возвращает отклоненные отклики для уведомлений.
+// Если заявка исполнена целиком, отклоняются все отклики кроме принятого
+func rejectOtherResponses(repo repository.RepositoryInterface, order *model.Order, acceptedResponseID int64) []*model.Response {
+	responses, err := repo.GetResponsesForOrder(order.ID)
 	if err != nil {
-		log.Printf("[WARN] Не удалось получить заявку ID=%d для уведомлений об отклонении: %v", orderID, err)
+		log.Printf("[WARN] Не удалось получить отклики для отклонения: %v", err)
+		return nil
 	}
 
+	var rejected []*model.Response
 	for _, response := range responses {
-		if response.ID != acceptedResponseID && response.Status == model.ResponseStatusWaiting {
-			// Отклоняем отклик
-			if err := s.repo.UpdateResponseStatus(response.ID, model.ResponseStatusRejected); err != nil {
-				log.Printf("[WARN] Не удалось отклонить отклик ID=%d: %v", response.ID, err)
-			} else {
-				// Отправляем уведомление пользователю об отклонении его отклика (автоматически)
-				if order != nil {
-					go s.sendResponseRejectedNotification(order, response)
-					log.Printf("[INFO] Отправлено уведомление об автоматическом отклонении отклика ID=%d", response.ID)
-				}
-			}
+		if response.ID == acceptedResponseID || !response.IsOpen() {
+			continue
+		}
+		if _, err := resolveFillAmount(order, response.RequestedAmount, response.DealPrice(order)); err == nil {
+			continue // Отклик все еще можно принять из остатка
 		}
+
+		// Отклоняем отклик
+		if err := repo.UpdateResponseStatus(response.ID, model.ResponseStatusRejected); err != nil {
+			log.Printf("[WARN] Не удалось отклонить отклик ID=%d: %v", response.ID, err)
+			continue
+		}
+		rejected = append(rejected, response)
 	}
+	return rejected
 }
 
 // =====================================================
@@ -1560,19 +1739,19 @@ func (s *Service) rejectOtherResponses(orderID, acceptedResponseID int64) {
 
 // sendNewResponseNotification отправляет уведомление автору заявки о новом отклике
 func (s *Service) sendNewResponseNotification(order *model.Order, response *model.Response, responderUserID int64) {
-	log.Printf("[INFO] Отправка уведомления о новом отклике автору заявки ID=%d", order.UserID)
+	s.logf("[INFO] Отправка уведомления о новом отклике автору заявки ID=%d", order.UserID)
 
 	// Получаем данные автора заявки по внутреннему ID
 	author, err := s.repo.GetUserByID(order.UserID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось найти автора заявки ID=%d: %v", order.UserID, err)
+		s.logf("[ERROR] Не удалось найти автора заявки ID=%d: %v", order.UserID, err)
 		return
 	}
 
 	// Получаем данные пользователя, который откликнулся
 	responder, err := s.repo.GetUserByID(responderUserID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось найти откликнувшегося пользователя ID=%d: %v", responderUserID, err)
+		s.logf("[ERROR] Не удалось найти откликнувшегося пользователя ID=%d: %v", responderUserID, err)
 		return
 	}
 
@@ -1611,34 +1790,34 @@ func (s *Service) sendNewResponseNotification(order *model.Order, response *mode
 
 	notification, err := s.notificationService.CreateNotification(notificationReq)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось создать уведомление: %v", err)
+		s.logf("[ERROR] Не удалось создать уведомление: %v", err)
 		return
 	}
 
 	// Отправляем уведомление в Telegram
 	if err := s.notificationService.SendNotification(notification, author.TelegramID); err != nil {
-		log.Printf("[ERROR] Не удалось отправить уведомление: %v", err)
+		s.logf("[ERROR] Не удалось отправить уведомление: %v", err)
 		return
 	}
 
-	log.Printf("[INFO] Уведомление о новом отклике отправлено автору TelegramID=%d", author.TelegramID)
+	s.logf("[INFO] Уведомление о новом отклике отправлено автору TelegramID=%d", author.TelegramID)
 }
 
 // sendResponseAcceptedNotification отправляет уведомление участнику о принятом отклике
 func (s *Service) sendResponseAcceptedNotification(order *model.Order, response *model.Response, deal *model.Deal) {
-	log.Printf("[INFO] Отправка уведомления о принятии отклика пользователю ID=%d", response.UserID)
+	s.logf("[INFO] Отправка уведомления о принятии отклика пользователю ID=%d", response.UserID)
 
 	// Получаем данные пользователя, которому отправляем уведомление
 	responder, err := s.repo.GetUserByID(response.UserID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось найти пользователя ID=%d: %v", response.UserID, err)
+		s.logf("[ERROR] Не удалось найти пользователя ID=%d: %v", response.UserID, err)
 		return
 	}
 
 	// Получаем данные автора заявки
 	author, err := s.repo.GetUserByID(order.UserID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось найти автора заявки ID=%d: %v", order.UserID, err)
+		s.logf("[ERROR] Не удалось найти автора заявки ID=%d: %v", order.UserID, err)
 		return
 	}
 
@@ -1677,34 +1856,34 @@ func (s *Service) sendResponseAcceptedNotification(order *model.Order, response
 
 	notification, err := s.notificationService.CreateNotification(notificationReq)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось создать уведомление: %v", err)
+		s.logf("[ERROR] Не удалось создать уведомление: %v", err)
 		return
 	}
 
 	// Отправляем уведомление в Telegram
 	if err := s.notificationService.SendNotification(notification, responder.TelegramID); err != nil {
-		log.Printf("[ERROR] Не удалось отправить уведомление: %v", err)
+		s.logf("[ERROR] Не удалось отправить уведомление: %v", err)
 		return
 	}
 
-	log.Printf("[INFO] Уведомление о принятии отклика отправлено пользователю TelegramID=%d", responder.TelegramID)
+	s.logf("[INFO] Уведомление о принятии отклика отправлено пользователю TelegramID=%d", responder.TelegramID)
 }
 
 // sendResponseRejectedNotification отправляет уведомление участнику об отклоненном отклике
 func (s *Service) sendResponseRejectedNotification(order *model.Order, response *model.Response) {
-	log.Printf("[INFO] Отправка уведомления об отклонении отклика пользователю ID=%d", response.UserID)
+	s.logf("[INFO] Отправка уведомления об отклонении отклика пользователю ID=%d", response.UserID)
 
 	// Получаем данные пользователя, которому отправляем уведомление
 	responder, err := s.repo.GetUserByID(response.UserID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось найти пользователя ID=%d: %v", response.UserID, err)
+		s.logf("[ERROR] Не удалось найти пользователя ID=%d: %v", response.UserID, err)
 		return
 	}
 
 	// Получаем данные автора заявки
 	author, err := s.repo.GetUserByID(order.UserID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось найти автора заявки ID=%d: %v", order.UserID, err)
+		s.logf("[ERROR] Не удалось найти автора заявки ID=%d: %v", order.UserID, err)
 		return
 	}
 
@@ -1742,42 +1921,99 @@ func (s *Service) sendResponseRejectedNotification(order *model.Order, response
 
 	notification, err := s.notificationService.CreateNotification(notificationReq)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось создать уведомление об отклонении: %v", err)
+		s.logf("[ERROR] Не удалось создать уведомление об отклонении: %v", err)
 		return
 	}
 
 	// Отправляем уведомление в Telegram
 	if err := s.notificationService.SendNotification(notification, responder.TelegramID); err != nil {
-		log.Printf("[ERROR] Не удалось отправить уведомление об отклонении: %v", err)
+		s.logf("[ERROR] Не удалось отправить уведомление об отклонении: %v", err)
+		return
+	}
+
+	s.logf("[INFO] Уведомление об отклонении отклика отправлено пользователю TelegramID=%d", responder.TelegramID)
+}
+
+// sendResponseWithdrawnNotification отправляет уведомление автору заявки об отозванном отклике
+func (s *Service) sendResponseWithdrawnNotification(order *model.Order, response *model.Response) {
+	s.logf("[INFO] Отправка уведомления об отзыве отклика автору заявки ID=%d", order.UserID)
+
+	author, err := s.repo.GetUserByID(order.UserID)
+	if err != nil {
+		s.logf("[ERROR] Не удалось найти автора заявки ID=%d: %v", order.UserID, err)
+		return
+	}
+
+	responder, err := s.repo.GetUserByID(response.UserID)
+	if err != nil {
+		s.logf("[ERROR] Не удалось найти пользователя ID=%d: %v", response.UserID, err)
+		return
+	}
+
+	// Формируем имя откликнувшегося пользователя
+	responderName := responder.FirstName
+	if responder.LastName != "" {
+		responderName += " " + responder.LastName
+	}
+	if responder.Username != "" {
+		responderName += " (@" + responder.Username + ")"
+	}
+
+	title, message := s.notificationService.FormatWithdrawnResponseNotification(order, responderName)
+
+	notificationReq := &model.CreateNotificationRequest{
+		UserID:     author.ID,
+		Type:       model.NotificationTypeResponseWithdrawn,
+		Title:      title,
+		Message:    message,
+		OrderID:    &order.ID,
+		ResponseID: &response.ID,
+		Data: map[string]interface{}{
+			"order_type":     string(order.Type),
+			"cryptocurrency": order.Cryptocurrency,
+			"fiat_currency":  order.FiatCurrency,
+			"responder_name": responderName,
+			"responder_id":   response.UserID,
+		},
+	}
+
+	notification, err := s.notificationService.CreateNotification(notificationReq)
+	if err != nil {
+		s.logf("[ERROR] Не удалось создать уведомление об отзыве отклика: %v", err)
+		return
+	}
+
+	if err := s.notificationService.SendNotification(notification, author.TelegramID); err != nil {
+		s.logf("[ERROR] Не удалось отправить уведомление об отзыве отклика: %v", err)
 		return
 	}
 
-	log.Printf("[INFO] Уведомление об отклонении отклика отправлено пользователю TelegramID=%d", responder.TelegramID)
+	s.logf("[INFO] Уведомление об отзыве отклика отправлено автору TelegramID=%d", author.TelegramID)
 }
 
 // sendDealCreatedNotifications отправляет уведомления обеим сторонам о создании сделки
 func (s *Service) sendDealCreatedNotifications(deal *model.Deal) {
-	log.Printf("[INFO] Отправка уведомлений о создании сделки ID=%d", deal.ID)
+	s.logf("[INFO] Отправка уведомлений о создании сделки ID=%d", deal.ID)
 
 	// Получаем данные автора заявки
 	author, err := s.repo.GetUserByID(deal.AuthorID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось найти автора сделки ID=%d: %v", deal.AuthorID, err)
+		s.logf("[ERROR] Не удалось найти автора сделки ID=%d: %v", deal.AuthorID, err)
 		return
 	}
 
 	// Получаем данные контрагента
 	counterparty, err := s.repo.GetUserByID(deal.CounterpartyID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось найти контрагента сделки ID=%d: %v", deal.CounterpartyID, err)
+		s.logf("[ERROR] Не удалось найти контрагента сделки ID=%d: %v", deal.CounterpartyID, err)
 		return
 	}
 
 	// Отправляем уведомление автору заявки
-	go s.sendDealCreatedNotification(deal, author, counterparty, true)
+	s.goNotify(func() { s.sendDealCreatedNotification(deal, author, counterparty, true) })
 
 	// Отправляем уведомление контрагенту
-	go s.sendDealCreatedNotification(deal, counterparty, author, false)
+	s.goNotify(func() { s.sendDealCreatedNotification(deal, counterparty, author, false) })
 }
 
 // sendDealCreatedNotification отправляет уведомление о создании сделки конкретному пользователю
@@ -1818,34 +2054,34 @@ func (s *Service) sendDealCreatedNotification(deal *model.Deal, recipient *model
 
 	notification, err := s.notificationService.CreateNotification(notificationReq)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось создать уведомление о сделке: %v", err)
+		s.logf("[ERROR] Не удалось создать уведомление о сделке: %v", err)
 		return
 	}
 
 	// Отправляем уведомление в Telegram
 	if err := s.notificationService.SendNotification(notification, recipient.TelegramID); err != nil {
-		log.Printf("[ERROR] Не удалось отправить уведомление о сделке: %v", err)
+		s.logf("[ERROR] Не удалось отправить уведомление о сделке: %v", err)
 		return
 	}
 
-	log.Printf("[INFO] Уведомление о создании сделки отправлено пользователю TelegramID=%d", recipient.TelegramID)
+	s.logf("[INFO] Уведомление о создании сделки отправлено пользователю TelegramID=%d", recipient.TelegramID)
 }
 
 // sendDealConfirmedNotification отправляет уведомление о подтверждении сделки
 func (s *Service) sendDealConfirmedNotification(deal *model.Deal, confirmedByUserID int64, waitingForUserID int64) {
-	log.Printf("[INFO] Отправка уведомления о подтверждении сделки ID=%d", deal.ID)
+	s.logf("[INFO] Отправка уведомления о подтверждении сделки ID=%d", deal.ID)
 
 	// Получаем данные пользователя, который подтвердил
 	confirmedBy, err := s.repo.GetUserByID(confirmedByUserID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось найти пользователя который подтвердил ID=%d: %v", confirmedByUserID, err)
+		s.logf("[ERROR] Не удалось найти пользователя который подтвердил ID=%d: %v", confirmedByUserID, err)
 		return
 	}
 
 	// Получаем данные пользователя, который ждет подтверждения
 	waitingFor, err := s.repo.GetUserByID(waitingForUserID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось найти пользователя который ждет подтверждения ID=%d: %v", waitingForUserID, err)
+		s.logf("[ERROR] Не удалось найти пользователя который ждет подтверждения ID=%d: %v", waitingForUserID, err)
 		return
 	}
 
@@ -1887,34 +2123,34 @@ func (s *Service) sendDealConfirmedNotification(deal *model.Deal, confirmedByUse
 
 	notification, err := s.notificationService.CreateNotification(notificationReq)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось создать уведомление о подтверждении сделки: %v", err)
+		s.logf("[ERROR] Не удалось создать уведомление о подтверждении сделки: %v", err)
 		return
 	}
 
 	// Отправляем уведомление в Telegram
 	if err := s.notificationService.SendNotification(notification, waitingFor.TelegramID); err != nil {
-		log.Printf("[ERROR] Не удалось отправить уведомление о подтверждении сделки: %v", err)
+		s.logf("[ERROR] Не удалось отправить уведомление о подтверждении сделки: %v", err)
 		return
 	}
 
-	log.Printf("[INFO] Уведомление о подтверждении сделки отправлено пользователю TelegramID=%d", waitingFor.TelegramID)
+	s.logf("[INFO] Уведомление о подтверждении сделки отправлено пользователю TelegramID=%d", waitingFor.TelegramID)
 }
 
 // sendDealCompletedNotifications отправляет уведомления о завершении сделки обеим участникам
 func (s *Service) sendDealCompletedNotifications(deal *model.Deal) {
-	log.Printf("[INFO] Отправка уведомлений о завершении сделки ID=%d", deal.ID)
+	s.logf("[INFO] Отправка уведомлений о завершении сделки ID=%d", deal.ID)
 
 	// Получаем данные автора заявки
 	author, err := s.repo.GetUserByID(deal.AuthorID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось найти автора сделки ID=%d: %v", deal.AuthorID, err)
+		s.logf("[ERROR] Не удалось найти автора сделки ID=%d: %v", deal.AuthorID, err)
 		return
 	}
 
 	// Получаем данные контрагента
 	counterparty, err := s.repo.GetUserByID(deal.CounterpartyID)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось найти контрагента сделки ID=%d: %v", deal.CounterpartyID, err)
+		s.logf("[ERROR] Не удалось найти контрагента сделки ID=%d: %v", deal.CounterpartyID, err)
 		return
 	}
 
@@ -1933,10 +2169,10 @@ func (s *Service) sendDealCompletedNotifications(deal *model.Deal) {
 	title, message := s.notificationService.FormatDealCompletedNotification(deal, authorName, counterpartyName)
 
 	// Отправляем уведомление автору заявки
-	go s.sendDealCompletedNotification(deal, author, title, message)
+	s.goNotify(func() { s.sendDealCompletedNotification(deal, author, title, message) })
 
 	// Отправляем уведомление контрагенту
-	go s.sendDealCompletedNotification(deal, counterparty, title, message)
+	s.goNotify(func() { s.sendDealCompletedNotification(deal, counterparty, title, message) })
 }
 
 // sendDealCompletedNotification отправляет уведомление о завершении сделки конкретному пользователю
@@ -1964,22 +2200,22 @@ func (s *Service) sendDealCompletedNotification(deal *model.Deal, recipient *mod
 
 	notification, err := s.notificationService.CreateNotification(notificationReq)
 	if err != nil {
-		log.Printf("[ERROR] Не удалось создать уведомление о завершении сделки: %v", err)
+		s.logf("[ERROR] Не удалось создать уведомление о завершении сделки: %v", err)
 		return
 	}
 
 	// Отправляем уведомление в Telegram
 	if err := s.notificationService.SendNotification(notification, recipient.TelegramID); err != nil {
-		log.Printf("[ERROR] Не удалось отправить уведомление о завершении сделки: %v", err)
+		s.logf("[ERROR] Не удалось отправить уведомление о завершении сделки: %v", err)
 		return
 	}
 
-	log.Printf("[INFO] Уведомление о завершении сделки отправлено пользователю TelegramID=%d", recipient.TelegramID)
+	s.logf("[INFO] Уведомление о завершении сделки отправлено пользователю TelegramID=%d", recipient.TelegramID)
 }
 
 // sendOrderCreatedGroupNotification отправляет групповое уведомление о создании новой заявки
 func (s *Service) sendOrderCreatedGroupNotification(order *model.Order, user *model.User) {
-	log.Printf("[INFO] Отправка группового уведомления о создании заявки ID=%d", order.ID)
+	s.logf("[INFO] Отправка группового уведомления о создании заявки ID=%d", order.ID)
 
 	// Формируем имя пользователя
 	userName := user.FirstName
@@ -2026,9 +2262,9 @@ func (s *Service) sendOrderCreatedGroupNotification(order *model.Order, user *mo
 		message,
 		order,
 	); err != nil {
-		log.Printf("[ERROR] Не удалось отправить групповое уведомление о создании заявки: %v", err)
+		s.logf("[ERROR] Не удалось отправить групповое уведомление о создании заявки: %v", err)
 		return
 	}
 
-	log.Printf("[INFO] Групповое уведомление о создании заявки отправлено")
+	s.logf("[INFO] Групповое уведомление о создании заявки отправлено")
 }
@@ -0,0 +1,334 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"p2pTG-crypto-exchange/internal/model"
+	"p2pTG-crypto-exchange/internal/repository"
+)
+
+// =====================================================
+// ТЕСТЫ СЕРВИСА НА ХРАНИЛИЩЕ В ПАМЯТИ
+// =====================================================
+
+// offlineTransport отклоняет запросы к Telegram Bot API: уведомления в тестах не отправляются
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("сеть отключена в тестах")
+}
+
+// newTestService создает сервис на пустом MemoryRepository с продавцом и покупателем в закрытом чате
+func newTestService(t *testing.T) (*Service, repository.RepositoryInterface, *model.User, *model.User) {
+	t.Helper()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	repo := repository.NewMemoryRepository()
+	s := NewService(repo, "", "")
+	s.httpClient = &http.Client{Transport: offlineTransport{}}
+	s.notificationService.httpClient = s.httpClient
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := s.Shutdown(ctx); err != nil {
+			t.Error(err)
+		}
+	})
+
+	seller := &model.User{TelegramID: 1001, FirstName: "Продавец", ChatMember: true}
+	buyer := &model.User{TelegramID: 1002, FirstName: "Покупатель", ChatMember: true}
+	for _, user := range []*model.User{seller, buyer} {
+		if err := repo.CreateUser(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s, repo, seller, buyer
+}
+
+// openDeal проводит заявку продавца и отклик покупателя до принятия отклика
+func openDeal(t *testing.T, s *Service, seller, buyer *model.User) *model.Deal {
+	t.Helper()
+	order, err := s.CreateOrder(seller.TelegramID, &model.Order{
+		Type:           model.OrderTypeSell,
+		Cryptocurrency: "BTC",
+		FiatCurrency:   "RUB",
+		Amount:         0.1,
+		Price:          9000000,
+		PaymentMethods: []string{string(model.PaymentMethodSberbank)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := s.CreateResponse(buyer.ID, &model.CreateResponseRequest{OrderID: order.ID, Message: "готов к сделке"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deal, err := s.AcceptResponse(response.ID, seller.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return deal
+}
+
+// completeDeal подтверждает сделку обеими сторонами
+func completeDeal(t *testing.T, s *Service, deal *model.Deal) {
+	t.Helper()
+	if err := s.ConfirmDealWithRole(deal.ID, deal.AuthorID, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ConfirmDealWithRole(deal.ID, deal.CounterpartyID, false, "перевод отправлен"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectError(t *testing.T, err error, fragment string) {
+	t.Helper()
+	if err == nil || !strings.Contains(err.Error(), fragment) {
+		t.Fatalf("ожидалась ошибка с %q, получено %v", fragment, err)
+	}
+}
+
+func TestCreateOrderActiveOrdersLimit(t *testing.T) {
+	s, _, seller, _ := newTestService(t)
+	s.SetBusinessConfig(model.BusinessConfig{MaxActiveOrdersPerUser: 2})
+
+	create := func() (*model.Order, error) {
+		return s.CreateOrder(seller.TelegramID, &model.Order{
+			Type:           model.OrderTypeSell,
+			Cryptocurrency: "BTC",
+			FiatCurrency:   "RUB",
+			Amount:         0.1,
+			Price:          9000000,
+			PaymentMethods: []string{string(model.PaymentMethodSberbank)},
+		})
+	}
+
+	first, err := create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := create(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = create()
+	expectError(t, err, "максимум 2")
+
+	// Отмененная заявка освобождает место
+	if err := s.CancelOrder(seller.ID, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := create(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcceptResponse(t *testing.T) {
+	s, repo, seller, buyer := newTestService(t)
+
+	order, err := s.CreateOrder(seller.TelegramID, &model.Order{
+		Type:           model.OrderTypeSell,
+		Cryptocurrency: "BTC",
+		FiatCurrency:   "RUB",
+		Amount:         0.1,
+		Price:          9000000,
+		MinAmount:      90000,
+		PaymentMethods: []string{string(model.PaymentMethodSberbank), string(model.PaymentMethodTinkoff)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := s.CreateResponse(buyer.ID, &model.CreateResponseRequest{
+		OrderID:       order.ID,
+		Amount:        0.04,
+		PaymentMethod: string(model.PaymentMethodTinkoff),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Отклик в статусе waiting принимает только автор заявки
+	_, err = s.AcceptResponse(response.ID, buyer.ID)
+	expectError(t, err, "ожидает ответа автора")
+
+	deal, err := s.AcceptResponse(response.ID, seller.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deal.AuthorID != seller.ID || deal.CounterpartyID != buyer.ID || deal.Status != model.DealStatusInProgress {
+		t.Fatalf("неверная сделка: %+v", deal)
+	}
+	if deal.Amount != 0.04 || deal.TotalAmount != 0.04*9000000 {
+		t.Fatalf("объем сделки %.2f на сумму %.2f", deal.Amount, deal.TotalAmount)
+	}
+	if len(deal.PaymentMethods) != 1 || deal.PaymentMethods[0] != string(model.PaymentMethodTinkoff) {
+		t.Fatalf("способы оплаты сделки %v", deal.PaymentMethods)
+	}
+	if deal.ExpiresAt.Before(time.Now()) {
+		t.Fatalf("срок подтверждения сделки уже истек: %s", deal.ExpiresAt)
+	}
+
+	// Объем сделки занят в заявке, отклик принят
+	stored, err := repo.GetOrderByID(order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.RemainingAmount < 0.0599 || stored.RemainingAmount > 0.0601 {
+		t.Fatalf("остаток заявки %.4f", stored.RemainingAmount)
+	}
+	_, err = s.AcceptResponse(response.ID, seller.ID)
+	expectError(t, err, "уже был рассмотрен")
+}
+
+func TestAcceptResponseConcurrently(t *testing.T) {
+	s, repo, seller, buyer := newTestService(t)
+
+	order, err := s.CreateOrder(seller.TelegramID, &model.Order{
+		Type:           model.OrderTypeSell,
+		Cryptocurrency: "BTC",
+		FiatCurrency:   "RUB",
+		Amount:         0.5,
+		Price:          9000000,
+		MinAmount:      90000,
+		PaymentMethods: []string{string(model.PaymentMethodSberbank)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := s.CreateResponse(buyer.ID, &model.CreateResponseRequest{OrderID: order.ID, Amount: 0.2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Параллельные принятия одного отклика создают ровно одну сделку
+	const attempts = 3
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.AcceptResponse(response.ID, seller.ID)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	accepted := 0
+	for err := range errs {
+		if err == nil {
+			accepted++
+		}
+	}
+	if accepted != 1 {
+		t.Fatalf("отклик принят %d раз", accepted)
+	}
+	deals, err := repo.GetDealsByUserID(seller.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deals) != 1 {
+		t.Fatalf("создано сделок: %d", len(deals))
+	}
+	stored, err := repo.GetOrderByID(order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.RemainingAmount < 0.2999 || stored.RemainingAmount > 0.3001 {
+		t.Fatalf("остаток заявки %.4f", stored.RemainingAmount)
+	}
+}
+
+func TestConfirmDealWithRole(t *testing.T) {
+	s, repo, seller, buyer := newTestService(t)
+	deal := openDeal(t, s, seller, buyer)
+
+	// Роль должна совпадать с участием в сделке
+	expectError(t, s.ConfirmDealWithRole(deal.ID, seller.ID, false, ""), "не является контрагентом")
+	expectError(t, s.ConfirmDealWithRole(deal.ID, buyer.ID, true, ""), "не является автором")
+
+	if err := s.ConfirmDealWithRole(deal.ID, seller.ID, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := repo.GetDealByID(deal.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.AuthorConfirmed || stored.Status == model.DealStatusCompleted {
+		t.Fatalf("после подтверждения автора сделка в статусе %s", stored.Status)
+	}
+
+	if err := s.ConfirmDealWithRole(deal.ID, buyer.ID, false, "перевод отправлен"); err != nil {
+		t.Fatal(err)
+	}
+	stored, err = repo.GetDealByID(deal.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != model.DealStatusCompleted {
+		t.Fatalf("после подтверждения обеих сторон сделка в статусе %s", stored.Status)
+	}
+
+	// Завершенная сделка учтена в статистике обоих участников
+	for _, user := range []*model.User{seller, buyer} {
+		stats, err := repo.GetUserByID(user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.TotalDeals != 1 || stats.SuccessfulDeals != 1 {
+			t.Fatalf("статистика пользователя ID=%d: всего %d, успешных %d", user.ID, stats.TotalDeals, stats.SuccessfulDeals)
+		}
+	}
+
+	// Повторное подтверждение завершенной сделки недопустимо
+	if err := s.ConfirmDealWithRole(deal.ID, buyer.ID, false, ""); err == nil {
+		t.Fatal("повторное подтверждение завершенной сделки прошло без ошибки")
+	}
+}
+
+func TestCreateReview(t *testing.T) {
+	s, repo, seller, buyer := newTestService(t)
+	deal := openDeal(t, s, seller, buyer)
+
+	// До завершения сделки отзыв оставить нельзя
+	_, err := s.CreateReview(buyer.ID, &model.CreateReviewRequest{DealID: deal.ID, ToUserID: seller.ID, Rating: 5})
+	expectError(t, err, "только по завершенным сделкам")
+
+	completeDeal(t, s, deal)
+
+	// Низкая оценка требует комментария
+	_, err = s.CreateReview(seller.ID, &model.CreateReviewRequest{DealID: deal.ID, ToUserID: buyer.ID, Rating: 2})
+	expectError(t, err, "необходимо указать комментарий")
+
+	review, err := s.CreateReview(buyer.ID, &model.CreateReviewRequest{
+		DealID: deal.ID, ToUserID: seller.ID, Rating: 5, Comment: "все четко",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if review.ID == 0 || review.FromUserID != buyer.ID || !review.IsVisible {
+		t.Fatalf("неверный отзыв: %+v", review)
+	}
+
+	// Второй отзыв по той же сделке тому же пользователю не принимается
+	_, err = s.CreateReview(buyer.ID, &model.CreateReviewRequest{DealID: deal.ID, ToUserID: seller.ID, Rating: 4})
+	expectError(t, err, "уже оставлен")
+
+	rating, err := repo.GetUserRating(seller.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rating.TotalReviews != 1 || rating.AverageRating != 5 {
+		t.Fatalf("рейтинг продавца %.2f по %d отзывам", rating.AverageRating, rating.TotalReviews)
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"p2pTG-crypto-exchange/internal/model"
+)
+
+// defaultSessionExpiration время жизни сессионного токена, если JWT_EXPIRATION не задан
+const defaultSessionExpiration = 24 * time.Hour
+
+// sessionTokenHeader заголовок токена (алгоритм HS256), закодированный один раз
+var sessionTokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// SessionClaims содержит данные, зашитые в подписанный сессионный токен
+type SessionClaims struct {
+	UserID     int64 `json:"sub"` // Внутренний ID пользователя
+	TelegramID int64 `json:"tg"`  // Telegram ID пользователя
+	IssuedAt   int64 `json:"iat"` // Время выпуска токена (unix)
+	ExpiresAt  int64 `json:"exp"` // Время истечения токена (unix)
+}
+
+// SetSecurityConfig применяет настройки безопасности к сервису
+// Если секрет не задан, генерируется случайный ключ (токены не переживут перезапуск)
+func (s *Service) SetSecurityConfig(cfg model.SecurityConfig) {
+	if cfg.JWTSecret != "" {
+		s.sessionSecret = []byte(cfg.JWTSecret)
+	} else {
+		s.logln("[WARN] JWT_SECRET не задан, используется случайный ключ подписи сессий")
+		s.sessionSecret = randomSessionSecret()
+	}
+
+	s.sessionExpiration = cfg.JWTExpiration
+	if s.sessionExpiration <= 0 {
+		s.sessionExpiration = defaultSessionExpiration
+	}
+
+	s.logf("[INFO] Сессионные токены: время жизни %s", s.sessionExpiration)
+}
+
+// IssueSessionToken выпускает подписанный сессионный токен для пользователя
+// Возвращает токен и момент его истечения
+func (s *Service) IssueSessionToken(user *model.User) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(s.sessionExpiration)
+
+	claims := SessionClaims{
+		UserID:     user.ID,
+		TelegramID: user.TelegramID,
+		IssuedAt:   now.Unix(),
+		ExpiresAt:  expiresAt.Unix(),
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("не удалось сформировать токен: %w", err)
+	}
+
+	signingInput := sessionTokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	token := signingInput + "." + s.signSessionToken(signingInput)
+
+	s.logf("[INFO] Выпущен сессионный токен для пользователя ID=%d до %s",
+		user.ID, expiresAt.Format(time.RFC3339))
+	return token, expiresAt, nil
+}
+
+// AuthenticateSessionToken проверяет подпись и срок действия токена
+// и возвращает актуальные данные пользователя из репозитория
+func (s *Service) AuthenticateSessionToken(token string) (*model.User, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != sessionTokenHeader {
+		return nil, fmt.Errorf("неверный формат токена")
+	}
+
+	// Сравниваем подпись за постоянное время
+	expected := s.signSessionToken(parts[0] + "." + parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, fmt.Errorf("неверная подпись токена")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("неверный формат токена: %w", err)
+	}
+
+	var claims SessionClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("неверный формат токена: %w", err)
+	}
+
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, fmt.Errorf("срок действия токена истек")
+	}
+
+	user, err := s.repo.GetUserByID(claims.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("пользователь токена не найден: %w", err)
+	}
+
+	// Токен, выпущенный для другого Telegram аккаунта, не принимаем
+	if user.TelegramID != claims.TelegramID {
+		return nil, fmt.Errorf("токен не соответствует пользователю")
+	}
+
+	if !user.IsActive {
+		return nil, fmt.Errorf("пользователь заблокирован")
+	}
+
+	return user, nil
+}
+
+// signSessionToken вычисляет HMAC-SHA256 подпись для заголовка и полезной нагрузки токена
+func (s *Service) signSessionToken(signingInput string) string {
+	mac := hmac.New(sha256.New, s.sessionSecret)
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// randomSessionSecret генерирует случайный ключ подписи сессий
+func randomSessionSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatalf("[ERROR] Не удалось сгенерировать ключ подписи сессий: %v", err)
+	}
+	return secret
+}
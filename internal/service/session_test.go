@@ -0,0 +1,129 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"p2pTG-crypto-exchange/internal/model"
+	"p2pTG-crypto-exchange/internal/repository"
+)
+
+// blockedUserRepository отдает пользователя blockedID заблокированным
+type blockedUserRepository struct {
+	repository.RepositoryInterface
+	blockedID int64
+}
+
+func (r blockedUserRepository) GetUserByID(userID int64) (*model.User, error) {
+	user, err := r.RepositoryInterface.GetUserByID(userID)
+	if err == nil && user.ID == r.blockedID {
+		user.IsActive = false
+	}
+	return user, err
+}
+
+func TestSessionTokenRoundTrip(t *testing.T) {
+	s, _, seller, _ := newTestService(t)
+	s.SetSecurityConfig(model.SecurityConfig{JWTSecret: "test-secret", JWTExpiration: time.Hour})
+
+	token, expiresAt, err := s.IssueSessionToken(seller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(expiresAt); d < 59*time.Minute || d > time.Hour {
+		t.Fatalf("токен истекает через %s", d)
+	}
+
+	user, err := s.AuthenticateSessionToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != seller.ID || user.TelegramID != seller.TelegramID {
+		t.Fatalf("токен продавца вернул пользователя ID=%d", user.ID)
+	}
+}
+
+func TestSessionTokenBadSignature(t *testing.T) {
+	s, _, seller, buyer := newTestService(t)
+	s.SetSecurityConfig(model.SecurityConfig{JWTSecret: "test-secret"})
+
+	token, _, err := s.IssueSessionToken(seller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+
+	// Подмена полезной нагрузки на токен другого пользователя при старой подписи
+	other, _, err := s.IssueSessionToken(buyer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := parts[0] + "." + strings.Split(other, ".")[1] + "." + parts[2]
+	_, err = s.AuthenticateSessionToken(forged)
+	expectError(t, err, "неверная подпись")
+
+	// Токен, подписанный другим секретом
+	s.SetSecurityConfig(model.SecurityConfig{JWTSecret: "rotated-secret"})
+	_, err = s.AuthenticateSessionToken(token)
+	expectError(t, err, "неверная подпись")
+
+	_, err = s.AuthenticateSessionToken("not-a-token")
+	expectError(t, err, "неверный формат")
+}
+
+func TestSessionTokenExpired(t *testing.T) {
+	s, _, seller, _ := newTestService(t)
+	s.SetSecurityConfig(model.SecurityConfig{JWTSecret: "test-secret"})
+	s.sessionExpiration = -time.Second
+
+	token, _, err := s.IssueSessionToken(seller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.AuthenticateSessionToken(token)
+	expectError(t, err, "срок действия токена истек")
+}
+
+func TestSessionTokenTelegramIDMismatch(t *testing.T) {
+	s, _, seller, _ := newTestService(t)
+	s.SetSecurityConfig(model.SecurityConfig{JWTSecret: "test-secret"})
+
+	// Токен подписан верно, но выпущен для другого Telegram аккаунта с тем же внутренним ID
+	stranger := *seller
+	stranger.TelegramID = seller.TelegramID + 1
+	token, _, err := s.IssueSessionToken(&stranger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.AuthenticateSessionToken(token)
+	expectError(t, err, "не соответствует пользователю")
+}
+
+func TestSessionTokenInactiveUser(t *testing.T) {
+	s, repo, seller, _ := newTestService(t)
+	s.SetSecurityConfig(model.SecurityConfig{JWTSecret: "test-secret"})
+
+	token, _, err := s.IssueSessionToken(seller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.repo = blockedUserRepository{RepositoryInterface: repo, blockedID: seller.ID}
+	_, err = s.AuthenticateSessionToken(token)
+	expectError(t, err, "заблокирован")
+}
+
+func TestAuthenticateUserRejectsUnsignedLogin(t *testing.T) {
+	s, _, seller, _ := newTestService(t)
+	s.telegramToken = "123:abc"
+
+	// Login Widget без действительной подписи, в том числе с прежним "dummy_hash"
+	for _, hash := range []string{"", "dummy_hash", strings.Repeat("0", 64)} {
+		_, err := s.AuthenticateUser(&model.TelegramAuthData{
+			ID:       seller.TelegramID,
+			AuthDate: time.Now().Unix(),
+			Hash:     hash,
+		})
+		expectError(t, err, "неверная подпись")
+	}
+}
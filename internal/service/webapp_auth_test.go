@@ -0,0 +1,105 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testBotToken = "123456:TEST-bot-token"
+
+// signInitData подписывает поля initData по схеме WebAppData токеном testBotToken
+func signInitData(fields map[string]string) string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+fields[key])
+	}
+
+	secret := hmac.New(sha256.New, []byte("WebAppData"))
+	secret.Write([]byte(testBotToken))
+	h := hmac.New(sha256.New, secret.Sum(nil))
+	h.Write([]byte(strings.Join(pairs, "\n")))
+
+	values := url.Values{}
+	for key, value := range fields {
+		values.Set(key, value)
+	}
+	values.Set("hash", hex.EncodeToString(h.Sum(nil)))
+	return values.Encode()
+}
+
+// initDataFields поля initData Mini App с датой авторизации authDate
+func initDataFields(authDate time.Time) map[string]string {
+	return map[string]string{
+		"query_id":  "AAHdF6IQAAAAAN0XohDhrOrc",
+		"user":      `{"id":279058397,"first_name":"Иван","last_name":"Петров","username":"ivan_p","language_code":"ru"}`,
+		"auth_date": strconv.FormatInt(authDate.Unix(), 10),
+	}
+}
+
+func TestValidateWebAppInitData(t *testing.T) {
+	s := &Service{telegramToken: testBotToken}
+	now := time.Now()
+
+	t.Run("подписанные данные принимаются", func(t *testing.T) {
+		authData, err := s.validateWebAppInitData(signInitData(initDataFields(now)), time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if authData.ID != 279058397 || authData.Username != "ivan_p" || authData.FirstName != "Иван" {
+			t.Fatalf("неверные данные пользователя: %+v", authData)
+		}
+		if authData.AuthDate != now.Unix() {
+			t.Fatalf("auth_date %d вместо %d", authData.AuthDate, now.Unix())
+		}
+	})
+
+	t.Run("измененное поле", func(t *testing.T) {
+		values, err := url.ParseQuery(signInitData(initDataFields(now)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		values.Set("user", `{"id":1,"first_name":"Иван"}`)
+		_, err = s.validateWebAppInitData(values.Encode(), time.Hour)
+		expectError(t, err, "подпись initData не совпадает")
+	})
+
+	t.Run("чужой токен бота", func(t *testing.T) {
+		other := &Service{telegramToken: "654321:OTHER-bot-token"}
+		_, err := other.validateWebAppInitData(signInitData(initDataFields(now)), time.Hour)
+		expectError(t, err, "подпись initData не совпадает")
+	})
+
+	t.Run("устаревший auth_date", func(t *testing.T) {
+		_, err := s.validateWebAppInitData(signInitData(initDataFields(now.Add(-2*time.Hour))), time.Hour)
+		expectError(t, err, "срок действия initData истек")
+	})
+
+	t.Run("нет поля user", func(t *testing.T) {
+		fields := initDataFields(now)
+		delete(fields, "user")
+		_, err := s.validateWebAppInitData(signInitData(fields), time.Hour)
+		expectError(t, err, "отсутствуют данные пользователя")
+	})
+
+	t.Run("нет hash", func(t *testing.T) {
+		_, err := s.validateWebAppInitData("auth_date=1&user=%7B%7D", time.Hour)
+		expectError(t, err, "отсутствует hash")
+	})
+
+	t.Run("токен бота не задан", func(t *testing.T) {
+		_, err := (&Service{}).validateWebAppInitData(signInitData(initDataFields(now)), time.Hour)
+		expectError(t, err, "токен бота не задан")
+	})
+}
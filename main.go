@@ -1,11 +1,25 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"p2pTG-crypto-exchange/internal/config"
 	"p2pTG-crypto-exchange/internal/handler"
+	"p2pTG-crypto-exchange/internal/logging"
+	"p2pTG-crypto-exchange/internal/model"
+	"p2pTG-crypto-exchange/internal/ratelimit"
 	"p2pTG-crypto-exchange/internal/repository"
 	"p2pTG-crypto-exchange/internal/service"
 
@@ -23,97 +37,92 @@ func main() {
 		log.Println("[WARN] Файл .env не найден, используются переменные окружения системы")
 	}
 
-	// Получаем порт для запуска сервера из переменной окружения
-	// По умолчанию используется порт 8080
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-		log.Println("[INFO] Порт не задан, используется порт по умолчанию: 8080")
-	}
-
-	// Получаем URL базы данных PostgreSQL
-	databaseURL := os.Getenv("DATABASE_URL")
-
-	// Получаем путь к папке данных для JSON файлов (резервный вариант)
-	dataDir := os.Getenv("DATA_DIR")
-	if dataDir == "" {
-		// Папка данных по умолчанию
-		dataDir = "data"
-		log.Println("[INFO] DATA_DIR не задан, используется папка по умолчанию: data")
-	}
+	demo := flag.Bool("demo", false, "демо-режим: хранилище в памяти с демонстрационными данными, data/ не используется")
+	flag.Parse()
 
-	// Получаем токен Telegram бота из переменных окружения
-	telegramToken := os.Getenv("TELEGRAM_BOT_TOKEN")
-	if telegramToken == "" {
-		log.Fatal("[ERROR] TELEGRAM_BOT_TOKEN обязательная переменная окружения не задана")
+	if err := run(*demo); err != nil {
+		log.Fatalf("[ERROR] %v", err)
 	}
+	log.Println("[INFO] Сервер остановлен")
+}
 
-	// Получаем ID закрытого чата из переменных окружения
-	chatID := os.Getenv("TELEGRAM_CHAT_ID")
-	if chatID == "" {
-		log.Fatal("[ERROR] TELEGRAM_CHAT_ID обязательная переменная окружения не задана")
+// run инициализирует компоненты, обслуживает HTTP запросы до SIGTERM/SIGINT и корректно останавливает приложение:
+// дожидается завершения запросов, фоновых обработчиков и уведомлений, затем закрывает репозиторий.
+// В демо-режиме вместо настроенного хранилища используется MemoryRepository с демонстрационными данными
+func run(demo bool) error {
+	// Загружаем конфигурацию: значения по умолчанию, файл CONFIG_FILE (необязательно) и переменные окружения
+	cfg, err := config.Load(demo)
+	if err != nil {
+		return err
 	}
 
-	// Получаем URL веб-приложения из переменных окружения (необязательно)
-	webAppURL := os.Getenv("TELEGRAM_WEBAPP_URL")
-	if webAppURL == "" {
-		webAppURL = "https://localhost:" + port // Устанавливаем значение по умолчанию
-		log.Printf("[INFO] TELEGRAM_WEBAPP_URL не задан, используется по умолчанию: %s", webAppURL)
+	// Настраиваем структурированное логирование: уровень, формат и вывод из LoggingConfig
+	logs, err := logging.Setup(cfg.Logging)
+	if err != nil {
+		return fmt.Errorf("не удалось настроить логирование: %w", err)
 	}
+	defer logs.Close()
 
-	// Получаем ID группового чата для публикации новых заявок (необязательно)
-	groupChatID := os.Getenv("TELEGRAM_GROUP_CHAT_ID")
-	if groupChatID != "" {
-		log.Printf("[INFO] Групповые уведомления будут отправляться в чат ID: %s", groupChatID)
+	if cfg.Telegram.GroupChatID != 0 {
+		log.Printf("[INFO] Групповые уведомления будут отправляться в чат ID: %d", cfg.Telegram.GroupChatID)
+		if cfg.Telegram.GroupTopicID != 0 {
+			log.Printf("[INFO] Групповые уведомления будут отправляться в тему ID: %d", cfg.Telegram.GroupTopicID)
+		}
 	} else {
 		log.Println("[INFO] TELEGRAM_GROUP_CHAT_ID не задан, групповые уведомления отключены")
 	}
 
-	// Получаем ID темы в групповом чате (необязательно)
-	groupTopicID := os.Getenv("TELEGRAM_GROUP_TOPIC_ID")
-	if groupTopicID != "" && groupChatID != "" {
-		log.Printf("[INFO] Групповые уведомления будут отправляться в тему ID: %s", groupTopicID)
+	log.Println("[INFO] Запуск P2P криптобиржи...")
+	log.Printf("[INFO] Порт сервера: %d", cfg.Server.Port)
+	log.Printf("[INFO] URL веб-приложения: %s", cfg.Telegram.WebAppURL)
+
+	if demo {
+		log.Println("[INFO] Демо-режим: вход без Telegram через /api/v1/auth/demo-login, уведомления не отправляются")
 	}
 
-	log.Println("[INFO] Запуск P2P криптобиржи...")
-	log.Printf("[INFO] Порт сервера: %s", port)
-	log.Printf("[INFO] URL веб-приложения: %s", webAppURL)
-
-	// Инициализируем репозиторий для работы с данными
-	var repo repository.RepositoryInterface
-	var err error
-
-	if databaseURL != "" {
-		// Используем PostgreSQL базу данных
-		log.Printf("[INFO] 🐘 Подключение к PostgreSQL базе данных...")
-		repo, err = repository.NewRepository(databaseURL)
-		if err != nil {
-			log.Fatalf("[ERROR] Не удалось подключиться к PostgreSQL: %v", err)
-		}
-		log.Println("[INFO] ✅ PostgreSQL репозиторий инициализирован")
-		log.Println("[INFO] 🎯 Все данные теперь сохраняются в базе данных!")
-		log.Println("[INFO] 🔄 При деплоях данные НЕ БУДУТ пропадать!")
-	} else {
-		// Резервный вариант - файловое хранилище
-		log.Printf("[WARN] DATABASE_URL не задан, используем файловое хранилище: %s", dataDir)
-		log.Printf("[WARN] ⚠️  При деплоях данные БУДУТ пропадать!")
-		repo, err = repository.NewFileRepository(dataDir)
-		if err != nil {
-			log.Fatalf("[ERROR] Не удалось инициализировать файловый репозиторий: %v", err)
-		}
-		log.Println("[INFO] Файловое хранилище JSON данных готово")
+	// Инициализируем репозиторий для работы с данными:
+	// PostgreSQL, если задана база данных, иначе файловое хранилище JSON
+	repo, err := openRepository(cfg.Database, demo)
+	if err != nil {
+		return err
 	}
-	defer repo.Close() // Закрываем репозиторий при завершении работы
+	defer repo.Close() // Закрываем репозиторий последним, после остановки сервера и фоновых задач
 
 	// Инициализируем слой сервисов для бизнес-логики
 	// Сервисы содержат всю логику работы с заявками, пользователями и отзывами
-	svc := service.NewServiceWithGroup(repo, telegramToken, chatID, webAppURL, groupChatID, groupTopicID)
+	svc, err := service.NewServiceFromConfig(repo, cfg)
+	if err != nil {
+		return err
+	}
 	log.Println("[INFO] Сервисы инициализированы")
 	log.Println("[INFO] Система уведомлений готова к отправке сообщений участникам сделок")
 
+	// Контекст отменяется по SIGTERM (остановка на Render) или Ctrl+C
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// Запускаем фоновую проверку сроков сделок и заявок и пересчет плавающих цен
+	// Обработчики останавливаются после HTTP сервера, чтобы не прерывать их посреди запросов
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	svc.StartDealExpiryWorker(workersCtx, time.Minute)
+	svc.StartOrderExpiryWorker(workersCtx, time.Minute)
+	svc.StartFloatingPriceWorker(workersCtx, cfg.Business.RateCacheTTL)
+
 	// Инициализируем слой обработчиков HTTP запросов
 	// Обработчики принимают HTTP запросы и вызывают соответствующие сервисы
 	handlers := handler.NewHandler(svc)
+	// Лимиты запросов хранятся в памяти процесса: при нескольких экземплярах нужен общий ratelimit.Store
+	handlers.SetRateLimiter(ratelimit.NewLimiter(ratelimit.NewMemoryStore(),
+		ratelimit.Limit{Requests: cfg.Security.RateLimitRequests, Period: cfg.Security.RateLimitDuration},
+		ratelimit.Limit{Requests: cfg.Security.RateLimitWrites, Period: cfg.Security.RateLimitDuration},
+	), cfg.Security.TrustProxyHeaders)
+	if cfg.Security.EnableIPWhitelist {
+		if err := handlers.SetIPWhitelist(cfg.Security.WhitelistedIPs); err != nil {
+			return err
+		}
+		log.Printf("[INFO] Доступ к серверу разрешен только с IP: %s", strings.Join(cfg.Security.WhitelistedIPs, ", "))
+	}
 	log.Println("[INFO] Обработчики HTTP запросов инициализированы")
 
 	// Создаем HTTP маршрутизатор с использованием gorilla/mux
@@ -124,13 +133,70 @@ func main() {
 	handlers.RegisterRoutes(router)
 	log.Println("[INFO] HTTP маршруты зарегистрированы")
 
-	// Запускаем HTTP сервер на указанном порту
-	// Сервер будет обрабатывать все входящие HTTP запросы
-	log.Printf("[INFO] Запуск HTTP сервера на порту %s", port)
-	log.Printf("[INFO] Веб-интерфейс доступен по адресу: http://localhost:%s", port)
+	// Настраиваем HTTP сервер: таймауты защищают от медленных клиентов
+	server := &http.Server{
+		Addr:           net.JoinHostPort(cfg.Server.Host, strconv.Itoa(cfg.Server.Port)),
+		Handler:        router,
+		ReadTimeout:    cfg.Server.ReadTimeout,
+		WriteTimeout:   cfg.Server.WriteTimeout,
+		IdleTimeout:    cfg.Server.IdleTimeout,
+		MaxHeaderBytes: cfg.Server.MaxHeaderBytes,
+	}
+
+	// Запускаем HTTP сервер в отдельной горутине, чтобы дождаться сигнала остановки
+	serverErr := make(chan error, 1)
+	go func() {
+		var err error
+		if cfg.Server.EnableTLS {
+			log.Printf("[INFO] Запуск HTTPS сервера на %s", server.Addr)
+			err = server.ListenAndServeTLS(cfg.Server.CertFile, cfg.Server.KeyFile)
+		} else {
+			log.Printf("[INFO] Запуск HTTP сервера на %s", server.Addr)
+			log.Printf("[INFO] Веб-интерфейс доступен по адресу: http://localhost:%d", cfg.Server.Port)
+			err = server.ListenAndServe()
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		log.Println("[INFO] Получен сигнал остановки, завершаем работу...")
+	case err := <-serverErr:
+		runErr = fmt.Errorf("не удалось запустить HTTP сервер: %w", err)
+	}
+	stop() // Повторный сигнал завершит процесс сразу
+
+	// Останавливаем прием запросов и дожидаемся текущих, затем фоновых задач и уведомлений
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("[WARN] HTTP сервер остановлен принудительно: %v", err)
+	}
+	stopWorkers()
+	if err := svc.Shutdown(shutdownCtx); err != nil {
+		log.Printf("[WARN] %v", err)
+	}
+
+	return runErr
+}
+
+// openRepository открывает хранилище из конфигурации, а в демо-режиме - хранилище в памяти
+// с демонстрационными пользователями, заявками и сделками (данные пропадают при остановке)
+func openRepository(cfg model.DatabaseConfig, demo bool) (repository.RepositoryInterface, error) {
+	if !demo {
+		return repository.Open(cfg)
+	}
 
-	// ListenAndServe блокирует выполнение и обрабатывает HTTP запросы
-	if err := http.ListenAndServe(":"+port, router); err != nil {
-		log.Fatalf("[ERROR] Не удалось запустить HTTP сервер: %v", err)
+	log.Println("[INFO] Демо-режим: данные хранятся в памяти, настройки базы данных и каталог data/ не используются")
+	repo := repository.NewMemoryRepository()
+	if err := repository.SeedDemoData(repo); err != nil {
+		repo.Close()
+		return nil, fmt.Errorf("не удалось заполнить демо-данные: %w", err)
 	}
+	return repo, nil
 }
@@ -0,0 +1,20 @@
+-- Миграция для добавления сроков подтверждения сделок
+-- Версия: 006
+-- Описание: Крайний срок первого подтверждения сделки и отметка об отправленном предупреждении
+
+-- =====================================================
+-- СРОКИ ПОДТВЕРЖДЕНИЯ СДЕЛОК
+-- =====================================================
+
+-- Крайний срок, до которого одна из сторон должна подтвердить сделку (NULL для старых сделок)
+ALTER TABLE deals ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+
+-- Отправлено ли участникам предупреждение о скором истечении сделки
+ALTER TABLE deals ADD COLUMN IF NOT EXISTS expiry_warning_sent BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Индекс для фонового поиска истекающих сделок
+CREATE INDEX IF NOT EXISTS idx_deals_expires_at ON deals(expires_at) WHERE expires_at IS NOT NULL;
+
+-- Комментарии к изменениям
+COMMENT ON COLUMN deals.expires_at IS 'Крайний срок первого подтверждения сделки (DEAL_CONFIRMATION_TIMEOUT)';
+COMMENT ON COLUMN deals.expiry_warning_sent IS 'Отправлено ли предупреждение о скором истечении сделки';
@@ -0,0 +1,45 @@
+-- Миграция для добавления споров по сделкам и хронологии сделок
+-- Версия: 007
+-- Описание: Поля спора в таблице deals и таблица deal_events для хронологии сделки
+
+-- =====================================================
+-- ПОЛЯ СПОРА ПО СДЕЛКЕ
+-- =====================================================
+
+-- Причина спора и доказательства, приложенные участником
+ALTER TABLE deals ADD COLUMN IF NOT EXISTS dispute_reason TEXT;
+ALTER TABLE deals ADD COLUMN IF NOT EXISTS dispute_evidence TEXT;
+
+-- Кто и когда открыл спор
+ALTER TABLE deals ADD COLUMN IF NOT EXISTS dispute_opened_by BIGINT REFERENCES users(id);
+ALTER TABLE deals ADD COLUMN IF NOT EXISTS dispute_opened_at TIMESTAMP;
+
+-- Решение администратора
+ALTER TABLE deals ADD COLUMN IF NOT EXISTS dispute_winner_id BIGINT REFERENCES users(id);
+ALTER TABLE deals ADD COLUMN IF NOT EXISTS dispute_resolved_by BIGINT REFERENCES users(id);
+ALTER TABLE deals ADD COLUMN IF NOT EXISTS dispute_resolved_at TIMESTAMP;
+ALTER TABLE deals ADD COLUMN IF NOT EXISTS dispute_comment TEXT;
+
+-- Индекс для списка открытых споров в админке
+CREATE INDEX IF NOT EXISTS idx_deals_dispute ON deals(dispute_opened_at) WHERE status = 'dispute';
+
+-- =====================================================
+-- ХРОНОЛОГИЯ СДЕЛКИ
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS deal_events (
+    id BIGSERIAL PRIMARY KEY,                                      -- Уникальный идентификатор записи
+    deal_id BIGINT NOT NULL REFERENCES deals(id) ON DELETE CASCADE, -- ID сделки
+    user_id BIGINT REFERENCES users(id),                           -- Кто совершил действие (NULL - система)
+    type VARCHAR(30) NOT NULL,                                     -- Тип события
+    status VARCHAR(20) NOT NULL,                                   -- Статус сделки после события
+    message TEXT NOT NULL DEFAULT '',                              -- Описание события
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()                    -- Время события
+);
+
+CREATE INDEX IF NOT EXISTS idx_deal_events_deal_id ON deal_events(deal_id, created_at);
+
+-- Комментарии к изменениям
+COMMENT ON COLUMN deals.dispute_evidence IS 'Доказательства, приложенные к спору';
+COMMENT ON COLUMN deals.dispute_winner_id IS 'Участник, в пользу которого администратор решил спор';
+COMMENT ON TABLE deal_events IS 'Хронология событий сделки (споры, решения администраторов)';
@@ -0,0 +1,22 @@
+-- Миграция для добавления отмены сделок
+-- Версия: 008
+-- Описание: Причина отмены, запрос отмены (для взаимного согласия) и автор отмены сделки
+
+-- =====================================================
+-- ОТМЕНА СДЕЛОК
+-- =====================================================
+
+-- Причина отмены сделки (показывается в истории обоих участников)
+ALTER TABLE deals ADD COLUMN IF NOT EXISTS cancel_reason TEXT;
+
+-- Запрос отмены, ожидающий согласия второй стороны
+ALTER TABLE deals ADD COLUMN IF NOT EXISTS cancel_requested_by BIGINT REFERENCES users(id);
+ALTER TABLE deals ADD COLUMN IF NOT EXISTS cancel_requested_at TIMESTAMP;
+
+-- Кто и когда отменил сделку
+ALTER TABLE deals ADD COLUMN IF NOT EXISTS cancelled_by BIGINT REFERENCES users(id);
+ALTER TABLE deals ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP;
+
+-- Комментарии к изменениям
+COMMENT ON COLUMN deals.cancel_reason IS 'Причина отмены сделки';
+COMMENT ON COLUMN deals.cancel_requested_by IS 'Участник, запросивший отмену после отметки об оплате (нужно согласие второй стороны)';
@@ -0,0 +1,20 @@
+-- Миграция для частичного исполнения заявок
+-- Версия: 009
+-- Описание: Остаток заявки, не занятый сделками, и запрошенный объем в откликах
+
+-- =====================================================
+-- ЧАСТИЧНОЕ ИСПОЛНЕНИЕ ЗАЯВОК
+-- =====================================================
+
+-- Остаток криптовалюты в заявке, который еще можно взять в сделку
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS remaining_amount DECIMAL(20,8) NOT NULL DEFAULT 0;
+
+-- Заявки на рынке доступны целиком, остальные уже заняты сделкой или закрыты
+UPDATE orders SET remaining_amount = amount WHERE status IN ('active', 'has_responses');
+
+-- Объем, который откликнувшийся хочет взять из заявки (0 - весь остаток)
+ALTER TABLE responses ADD COLUMN IF NOT EXISTS requested_amount DECIMAL(20,8) NOT NULL DEFAULT 0;
+
+-- Комментарии к изменениям
+COMMENT ON COLUMN orders.remaining_amount IS 'Количество криптовалюты, еще не занятое сделками';
+COMMENT ON COLUMN responses.requested_amount IS 'Запрошенное количество криптовалюты (0 - весь остаток заявки)';
@@ -0,0 +1,35 @@
+-- Миграция для встречных предложений по откликам
+-- Версия: 010
+-- Описание: Предложенная цена в откликах, статус countered и история переговоров response_offers
+
+-- =====================================================
+-- УСЛОВИЯ ОТКЛИКА
+-- =====================================================
+
+-- Цена за единицу, о которой договариваются стороны (0 - цена заявки)
+ALTER TABLE responses ADD COLUMN IF NOT EXISTS proposed_price DECIMAL(20,8) NOT NULL DEFAULT 0;
+
+-- Статус countered: автор заявки предложил встречные условия
+ALTER TABLE responses DROP CONSTRAINT IF EXISTS responses_status_check;
+ALTER TABLE responses ADD CONSTRAINT responses_status_check
+    CHECK (status IN ('waiting', 'accepted', 'rejected', 'countered'));
+
+-- =====================================================
+-- ИСТОРИЯ ПЕРЕГОВОРОВ ПО ОТКЛИКУ
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS response_offers (
+    id BIGSERIAL PRIMARY KEY,                                              -- Уникальный идентификатор предложения
+    response_id BIGINT NOT NULL REFERENCES responses(id) ON DELETE CASCADE, -- ID отклика
+    user_id BIGINT NOT NULL REFERENCES users(id),                          -- Кто сделал предложение
+    price DECIMAL(20,8) NOT NULL,                                          -- Предложенная цена за единицу
+    amount DECIMAL(20,8) NOT NULL,                                         -- Предложенное количество криптовалюты
+    message TEXT NOT NULL DEFAULT '',                                      -- Комментарий к предложению
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()                            -- Время предложения
+);
+
+CREATE INDEX IF NOT EXISTS idx_response_offers_response_id ON response_offers(response_id, created_at);
+
+-- Комментарии к изменениям
+COMMENT ON COLUMN responses.proposed_price IS 'Согласуемая цена за единицу (0 - цена заявки)';
+COMMENT ON TABLE response_offers IS 'История предложений и встречных предложений по откликам';
@@ -0,0 +1,17 @@
+-- Миграция для отзыва откликов
+-- Версия: 011
+-- Описание: Статус withdrawn - откликнувшийся отозвал отклик до решения автора заявки
+
+-- =====================================================
+-- ОБНОВЛЕНИЕ CHECK CONSTRAINT ДЛЯ СТАТУСОВ ОТКЛИКОВ
+-- =====================================================
+
+ALTER TABLE responses DROP CONSTRAINT IF EXISTS responses_status_check;
+ALTER TABLE responses ADD CONSTRAINT responses_status_check
+    CHECK (status IN ('waiting', 'accepted', 'rejected', 'countered', 'withdrawn'));
+
+-- Индекс для проверки открытых откликов заявки при отзыве
+CREATE INDEX IF NOT EXISTS idx_responses_order_open ON responses(order_id) WHERE status IN ('waiting', 'countered');
+
+-- Комментарий к изменению
+COMMENT ON CONSTRAINT responses_status_check ON responses IS 'Статусы откликов должны соответствовать Go модели ResponseStatus';
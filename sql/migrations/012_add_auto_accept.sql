@@ -0,0 +1,32 @@
+-- Миграция для автопринятия откликов
+-- Версия: 012
+-- Описание: Правила автопринятия откликов в заявке, дата вступления пользователя в закрытый чат
+-- и способ оплаты, выбранный откликнувшимся
+
+-- =====================================================
+-- ПРАВИЛА АВТОПРИНЯТИЯ В ЗАЯВКАХ
+-- =====================================================
+
+-- JSON с правилами: enabled, min_rating, min_completed_deals, min_chat_member_days, payment_methods
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS auto_accept JSONB;
+
+COMMENT ON COLUMN orders.auto_accept IS 'Правила автоматического принятия откликов (NULL - автопринятие выключено)';
+
+-- =====================================================
+-- СТАЖ В ЗАКРЫТОМ ЧАТЕ
+-- =====================================================
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS chat_member_since TIMESTAMP;
+
+-- Для текущих участников чата стаж считается от регистрации
+UPDATE users SET chat_member_since = created_at WHERE chat_member = true AND chat_member_since IS NULL;
+
+COMMENT ON COLUMN users.chat_member_since IS 'С какого момента пользователь состоит в закрытом чате (NULL - не состоит)';
+
+-- =====================================================
+-- СПОСОБ ОПЛАТЫ В ОТКЛИКАХ
+-- =====================================================
+
+ALTER TABLE responses ADD COLUMN IF NOT EXISTS payment_method VARCHAR(50) NOT NULL DEFAULT '';
+
+COMMENT ON COLUMN responses.payment_method IS 'Способ оплаты, выбранный откликнувшимся (пусто - любой из способов заявки)';
@@ -0,0 +1,18 @@
+-- Миграция для автоматического сопоставления заявок
+-- Версия: 013
+-- Описание: Встречная заявка в сделках, созданных автосопоставлением (ENABLE_AUTO_MATCH)
+
+-- =====================================================
+-- ВСТРЕЧНАЯ ЗАЯВКА СДЕЛКИ
+-- =====================================================
+
+-- Объем сделки автосопоставления занят в обеих заявках и возвращается в обе при отмене
+ALTER TABLE deals ADD COLUMN IF NOT EXISTS matched_order_id BIGINT REFERENCES orders(id);
+
+CREATE INDEX IF NOT EXISTS idx_deals_matched_order_id ON deals(matched_order_id) WHERE matched_order_id IS NOT NULL;
+
+-- Индекс для поиска встречных заявок по приоритету цена-время
+CREATE INDEX IF NOT EXISTS idx_orders_matching ON orders(cryptocurrency, fiat_currency, type, price, created_at)
+    WHERE status IN ('active', 'has_responses');
+
+COMMENT ON COLUMN deals.matched_order_id IS 'Встречная заявка контрагента (NULL - сделка создана из отклика)';
@@ -0,0 +1,15 @@
+-- Миграция для рыночной статистики по завершенным сделкам
+-- Версия: 014
+-- Описание: Индекс завершенных сделок по паре и времени завершения для статистики и свечей
+
+-- =====================================================
+-- ИНДЕКС ЗАВЕРШЕННЫХ СДЕЛОК
+-- =====================================================
+
+-- Статистика за 24 часа и свечи выбирают завершенные сделки пары за период
+CREATE INDEX IF NOT EXISTS idx_deals_completed_pair ON deals(cryptocurrency, fiat_currency, completed_at)
+    WHERE status = 'completed';
+
+-- Статистика по всем парам выбирает завершенные сделки только по времени
+CREATE INDEX IF NOT EXISTS idx_deals_completed_at ON deals(completed_at)
+    WHERE status = 'completed';
@@ -0,0 +1,22 @@
+-- Миграция для заявок с плавающей ценой
+-- Версия: 015
+-- Описание: Тип цены заявки и наценка к опорному курсу (RATE_PROVIDER_URL / RATE_PROVIDER_FILE)
+
+-- =====================================================
+-- ПЛАВАЮЩАЯ ЦЕНА ЗАЯВКИ
+-- =====================================================
+
+-- fixed - цена указана автором, floating - курс источника ± наценка в процентах
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS price_type VARCHAR(10) NOT NULL DEFAULT 'fixed';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS price_margin DECIMAL(7,4) NOT NULL DEFAULT 0;
+
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_price_type_check;
+ALTER TABLE orders ADD CONSTRAINT orders_price_type_check CHECK (price_type IN ('fixed', 'floating'));
+
+-- Для плавающих заявок колонка price хранит последнюю посчитанную цену:
+-- ее обновляет фоновый пересчет, чтобы стакан и автосопоставление работали по актуальной цене
+CREATE INDEX IF NOT EXISTS idx_orders_floating ON orders(cryptocurrency, fiat_currency)
+    WHERE price_type = 'floating' AND status IN ('active', 'has_responses');
+
+COMMENT ON COLUMN orders.price_type IS 'Тип цены: fixed - фиксированная, floating - курс источника ± наценка';
+COMMENT ON COLUMN orders.price_margin IS 'Наценка к опорному курсу в процентах (может быть отрицательной)';
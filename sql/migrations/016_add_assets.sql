@@ -0,0 +1,28 @@
+-- Миграция для реестра активов, фиатных валют и способов оплаты
+-- Версия: 016
+-- Описание: Записи реестра, переопределяющие встроенный реестр (названия, точность, лимиты заявок)
+
+-- =====================================================
+-- РЕЕСТР АКТИВОВ
+-- =====================================================
+
+-- Встроенный реестр задан в коде; строка таблицы с тем же kind и code переопределяет
+-- встроенную запись, новая строка добавляет запись, is_active = FALSE убирает ее из реестра.
+-- Состав разделов дополнительно ограничивается SUPPORTED_CRYPTOCURRENCIES,
+-- SUPPORTED_FIAT_CURRENCIES и SUPPORTED_PAYMENT_METHODS
+CREATE TABLE IF NOT EXISTS assets (
+    code VARCHAR(32) NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('crypto', 'fiat', 'payment_method')),
+    display_name VARCHAR(100) NOT NULL,
+    decimals INTEGER NOT NULL DEFAULT 2 CHECK (decimals BETWEEN 0 AND 18),
+    min_order_amount DECIMAL(30,8) NOT NULL DEFAULT 0 CHECK (min_order_amount >= 0),
+    max_order_amount DECIMAL(30,8) NOT NULL DEFAULT 0 CHECK (max_order_amount >= 0),
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    PRIMARY KEY (kind, code)
+);
+
+COMMENT ON TABLE assets IS 'Переопределения встроенного реестра криптовалют, фиатных валют и способов оплаты';
+COMMENT ON COLUMN assets.decimals IS 'Знаков после запятой: количество для криптовалюты, цена для фиата';
+COMMENT ON COLUMN assets.min_order_amount IS 'Минимальный размер заявки: количество для криптовалюты, сумма для фиата (0 - без ограничения)';
+COMMENT ON COLUMN assets.max_order_amount IS 'Максимальный размер заявки: количество для криптовалюты, сумма для фиата (0 - без ограничения)';
@@ -2,6 +2,8 @@
 // Глобальные переменные для P2P криптобиржи
 let currentUser = null;
 let currentInternalUserId = null; // Внутренний ID пользователя в системе
+let sessionToken = null; // Сессионный токен, выданный сервером при авторизации
+let assetRegistry = null; // Реестр криптовалют, фиатных валют и способов оплаты с сервера
 let tg = window.Telegram?.WebApp;
 
 // Инициализация Telegram WebApp
@@ -38,6 +40,9 @@ function initTelegramWebApp() {
             last_name: 'Пользователь',
             username: 'testuser'
         };
+
+        // Сервер, запущенный с --demo, пускает под демонстрационным пользователем
+        demoLogin();
     }
 }
 
@@ -154,12 +159,24 @@ async function handleCreateOrder(e) {
         cryptocurrency: formData.get('cryptocurrency'),
         fiat_currency: formData.get('fiat_currency'),
         amount: parseFloat(formData.get('amount')),
-        price: parseFloat(formData.get('price')),
+        price: parseFloat(formData.get('price')) || 0,
+        price_type: formData.get('price_type') || 'fixed',
+        price_margin: parseFloat(formData.get('price_margin')) || 0,
         payment_methods: paymentMethods,
         description: formData.get('description') || '',
-        auto_match: formData.has('auto_match')
+        auto_match: formData.has('auto_match'),
+        auto_accept: readAutoAcceptRules(formData)
     };
     
+    // Фиксированной цене нужна цена, плавающая считается сервером по курсу
+    if (orderData.price_type === 'fixed' && orderData.price <= 0) {
+        showError('Укажите цену или выберите цену по курсу');
+        return;
+    }
+    if (orderData.price_type === 'fixed') {
+        orderData.price_margin = 0;
+    }
+    
     // Проверяем режим редактирования
     const editId = e.target.dataset.editId;
     const isEditMode = editId && editId !== '';
@@ -175,7 +192,7 @@ async function handleCreateOrder(e) {
                 method: 'PUT',
                 headers: {
                     'Content-Type': 'application/json',
-                    'X-Telegram-User-ID': currentUser.id.toString()
+                    'Authorization': 'Bearer ' + sessionToken
                 },
                 body: JSON.stringify(orderData)
             });
@@ -185,7 +202,7 @@ async function handleCreateOrder(e) {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json',
-                    'X-Telegram-User-ID': currentUser.id.toString()
+                    'Authorization': 'Bearer ' + sessionToken
                 },
                 body: JSON.stringify(orderData)
             });
@@ -222,6 +239,43 @@ async function handleCreateOrder(e) {
     }
 }
 
+// Пометка плавающей цены: курс источника и наценка автора
+function floatingPriceLabel(order) {
+    if (order.price_type !== 'floating') {
+        return '';
+    }
+    const margin = order.price_margin || 0;
+    return ' (курс ' + (margin >= 0 ? '+' : '') + margin + '%)';
+}
+
+// Правила автопринятия откликов из формы заявки (null - автопринятие выключено)
+function readAutoAcceptRules(formData) {
+    if (!formData.has('auto_accept_enabled')) {
+        return null;
+    }
+    return {
+        enabled: true,
+        min_rating: parseFloat(formData.get('auto_accept_min_rating')) || 0,
+        min_completed_deals: parseInt(formData.get('auto_accept_min_deals')) || 0,
+        min_chat_member_days: parseInt(formData.get('auto_accept_min_days')) || 0,
+        payment_methods: formData.getAll('auto_accept_payment_methods')
+    };
+}
+
+// Заполнение правил автопринятия в форме заявки при редактировании
+function fillAutoAcceptRules(rules) {
+    const enabled = !!(rules && rules.enabled);
+    document.querySelector('[name="auto_accept_enabled"]').checked = enabled;
+    document.querySelector('[name="auto_accept_min_rating"]').value = enabled && rules.min_rating ? rules.min_rating : '';
+    document.querySelector('[name="auto_accept_min_deals"]').value = enabled && rules.min_completed_deals ? rules.min_completed_deals : '';
+    document.querySelector('[name="auto_accept_min_days"]').value = enabled && rules.min_chat_member_days ? rules.min_chat_member_days : '';
+
+    const methods = enabled && Array.isArray(rules.payment_methods) ? rules.payment_methods : [];
+    document.querySelectorAll('[name="auto_accept_payment_methods"]').forEach(checkbox => {
+        checkbox.checked = methods.includes(checkbox.value);
+    });
+}
+
 // Сброс формы заявки в исходное состояние
 function resetOrderForm(form) {
     console.log('[DEBUG] Сброс формы заявки в исходное состояние');
@@ -320,9 +374,9 @@ async function apiRequest(url, method = 'GET', data = null) {
         }
     };
     
-    // Добавляем Telegram User ID в заголовки для авторизации
-    if (currentUser && currentUser.id) {
-        options.headers['X-Telegram-User-ID'] = currentUser.id.toString();
+    // Добавляем сессионный токен в заголовки для авторизации
+    if (sessionToken) {
+        options.headers['Authorization'] = 'Bearer ' + sessionToken;
     }
     
     // Добавляем данные для POST/PUT запросов
@@ -340,6 +394,53 @@ async function apiRequest(url, method = 'GET', data = null) {
     }
 }
 
+// Загрузка реестра криптовалют, фиатных валют и способов оплаты
+async function loadAssetRegistry() {
+    try {
+        const result = await apiRequest('/api/v1/meta/assets', 'GET');
+        if (!result.success) {
+            console.error('[ERROR] Не удалось загрузить реестр активов:', result.error);
+            return;
+        }
+        assetRegistry = result.assets;
+        renderAssetOptions();
+    } catch (error) {
+        console.error('[ERROR] Ошибка загрузки реестра активов:', error);
+    }
+}
+
+// Заполнение списков монет, валют и способов оплаты из реестра
+function renderAssetOptions() {
+    document.querySelectorAll('[data-asset-select]').forEach(select => {
+        const assets = select.dataset.assetSelect === 'crypto'
+            ? assetRegistry.cryptocurrencies
+            : assetRegistry.fiat_currencies;
+        // Пункт-заглушка ("Все монеты", "Выберите монету") остается первым
+        select.querySelectorAll('option:not([value=""])').forEach(option => option.remove());
+        assets.forEach(asset => {
+            const option = document.createElement('option');
+            option.value = asset.code;
+            option.textContent = asset.display_name + ' (' + asset.code + ')';
+            select.appendChild(option);
+        });
+    });
+
+    document.querySelectorAll('[data-asset-checkboxes]').forEach(container => {
+        const name = container.dataset.assetCheckboxes;
+        container.innerHTML = assetRegistry.payment_methods.map(method =>
+            `<label><input type="checkbox" name="${name}" value="${method.code}"> ${method.display_name}</label>`
+        ).join('');
+    });
+}
+
+// Названия способов оплаты для отображения (код, если способа нет в реестре)
+function paymentMethodLabels(methods) {
+    return (methods || []).map(code => {
+        const method = assetRegistry && assetRegistry.payment_methods.find(item => item.code === code);
+        return method ? method.display_name : code;
+    }).join(', ');
+}
+
 // Авторизация пользователя через Telegram WebApp
 async function authenticateUser() {
     if (!currentUser) {
@@ -355,10 +456,14 @@ async function authenticateUser() {
             last_name: currentUser.last_name || '',
             username: currentUser.username || '',
             photo_url: currentUser.photo_url || '',
-            auth_date: Math.floor(Date.now() / 1000),
-            hash: 'dummy_hash' // В реальном приложении тут будет настоящий hash от Telegram
+            auth_date: Math.floor(Date.now() / 1000)
         };
 
+        // Внутри Telegram передаем подписанную строку initData для проверки на сервере
+        if (tg && tg.initData) {
+            authData.init_data = tg.initData;
+        }
+
         const response = await fetch('/api/v1/auth/login', {
             method: 'POST',
             headers: {
@@ -370,16 +475,7 @@ async function authenticateUser() {
         const result = await response.json();
 
         if (result.success) {
-            console.log('[INFO] Авторизация успешна:', result.user);
-            
-            // Сохраняем внутренний ID пользователя для проверки "моих заявок"
-            currentInternalUserId = result.user.id;
-            
-            document.querySelector('.user-info').textContent = 
-                '👤 ' + result.user.first_name + ' ⭐' + result.user.rating.toFixed(1);
-            
-            // Загружаем заявки после успешной авторизации
-            loadOrders();
+            applySession(result);
         } else {
             if (result.error && result.error.includes('не являетесь членом закрытого чата')) {
                 showAccessDenied();
@@ -393,6 +489,57 @@ async function authenticateUser() {
     }
 }
 
+// Вход под демонстрационным пользователем вне Telegram.
+// Маршрут есть только на сервере в демо-режиме (--demo), иначе остается локальный демо-пользователь без сессии
+async function demoLogin() {
+    try {
+        const response = await fetch('/api/v1/auth/demo-login', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify({})
+        });
+        if (response.status === 404) {
+            console.warn('[WARN] Сервер запущен не в демо-режиме, вход без Telegram недоступен');
+            return;
+        }
+
+        const result = await response.json();
+        if (result.success) {
+            currentUser = {
+                id: result.user.telegram_id,
+                first_name: result.user.first_name,
+                last_name: result.user.last_name,
+                username: result.user.username
+            };
+            applySession(result);
+        } else {
+            showError('Ошибка демо-входа: ' + result.error);
+        }
+    } catch (error) {
+        console.error('[ERROR] Ошибка демо-входа:', error);
+        showError('Ошибка сети при авторизации');
+    }
+}
+
+// Сохраняет сессию после успешного входа и загружает заявки
+function applySession(result) {
+    console.log('[INFO] Авторизация успешна:', result.user);
+
+    // Сохраняем внутренний ID пользователя для проверки "моих заявок"
+    currentInternalUserId = result.user.id;
+
+    // Сохраняем сессионный токен для всех последующих запросов
+    sessionToken = result.token;
+
+    document.querySelector('.user-info').textContent = 
+        '👤 ' + result.user.first_name + ' ⭐' + result.user.rating.toFixed(1);
+
+    // Загружаем заявки после успешной авторизации
+    loadOrders();
+}
+
 // Показывает сообщение об отказе в доступе
 function showAccessDenied() {
     const container = document.querySelector('.container');
@@ -430,10 +577,10 @@ async function loadMyOrders() {
         // Загружаем заявки и сделки параллельно
         const [ordersResult, dealsResult] = await Promise.all([
             fetch('/api/v1/orders/my', {
-                headers: { 'X-Telegram-User-ID': currentUser.id.toString() }
+                headers: { 'Authorization': 'Bearer ' + sessionToken }
             }).then(r => r.json()),
             fetch('/api/v1/deals', {
-                headers: { 'X-Telegram-User-ID': currentUser.id.toString() }
+                headers: { 'Authorization': 'Bearer ' + sessionToken }
             }).then(r => r.json())
         ]);
         
@@ -586,6 +733,9 @@ function createOrderCard(order, category) {
                     <button onclick="viewOrderResponses(${order.id})" class="btn btn-compact btn-info" style="flex: 1;">
                         Отклики
                     </button>
+                    <button onclick="extendOrder(${order.id})" class="btn btn-compact btn-secondary" style="flex: 1;">
+                        Продлить
+                    </button>
                     <button onclick="cancelOrder(${order.id})" class="btn btn-compact btn-danger" style="flex: 1;">
                         Удалить
                     </button>
@@ -646,7 +796,7 @@ function createOrderCard(order, category) {
             ` : ''}
             
             <div style="display: flex; justify-content: space-between; font-size: 11px; color: var(--tg-theme-hint-color, #708499); margin-bottom: 8px;">
-                <span>💳 ${Array.isArray(order.payment_methods) ? order.payment_methods.join(', ') : order.payment_methods || 'Любой способ'}</span>
+                <span>💳 ${paymentMethodLabels(order.payment_methods) || 'Любой способ'}</span>
                 <span>📅 ${new Date(order.created_at).toLocaleDateString('ru')}</span>
             </div>
             
@@ -668,7 +818,7 @@ async function loadDeals() {
     try {
         const response = await fetch('/api/v1/deals', {
             headers: {
-                'X-Telegram-User-ID': currentUser.id.toString()
+                'Authorization': 'Bearer ' + sessionToken
             }
         });
         
@@ -748,19 +898,19 @@ async function loadProfile() {
         // Получаем данные пользователя и статистику параллельно
         const [userResponse, statsResponse, reviewsResponse] = await Promise.all([
             fetch('/api/v1/auth/me', {
-                headers: { 'X-Telegram-User-ID': currentUser.id.toString() }
+                headers: { 'Authorization': 'Bearer ' + sessionToken }
             }).catch(err => {
                 console.error('[DEBUG] Ошибка запроса /auth/me:', err);
                 return null;
             }),
             fetch('/api/v1/auth/stats', {
-                headers: { 'X-Telegram-User-ID': currentUser.id.toString() }
+                headers: { 'Authorization': 'Bearer ' + sessionToken }
             }).catch(err => {
                 console.error('[DEBUG] Ошибка запроса /auth/stats:', err);
                 return null;
             }),
             fetch('/api/v1/auth/reviews?limit=5', {
-                headers: { 'X-Telegram-User-ID': currentUser.id.toString() }
+                headers: { 'Authorization': 'Bearer ' + sessionToken }
             }).catch(err => {
                 console.error('[DEBUG] Ошибка запроса /auth/reviews:', err);
                 return null;
@@ -853,10 +1003,10 @@ async function updateHeaderRatingFromServer() {
         // Получаем актуальную статистику и данные пользователя
         const [userResponse, statsResponse] = await Promise.all([
             fetch('/api/v1/auth/me', {
-                headers: { 'X-Telegram-User-ID': currentUser.id.toString() }
+                headers: { 'Authorization': 'Bearer ' + sessionToken }
             }).catch(() => null),
             fetch('/api/v1/auth/stats', {
-                headers: { 'X-Telegram-User-ID': currentUser.id.toString() }
+                headers: { 'Authorization': 'Bearer ' + sessionToken }
             }).catch(() => null)
         ]);
 
@@ -886,7 +1036,7 @@ async function updateHeaderRatingFromServer() {
 async function getUserByTelegramID() {
     try {
         const response = await fetch('/api/v1/auth/me', {
-            headers: { 'X-Telegram-User-ID': currentUser.id.toString() }
+            headers: { 'Authorization': 'Bearer ' + sessionToken }
         });
         
         if (response.ok) {
@@ -1252,7 +1402,7 @@ async function cancelOrder(orderId) {
         const response = await fetch(`/api/v1/orders/${orderId}`, {
             method: 'DELETE',
             headers: {
-                'X-Telegram-User-ID': currentUser.id.toString()
+                'Authorization': 'Bearer ' + sessionToken
             }
         });
         
@@ -1270,6 +1420,35 @@ async function cancelOrder(orderId) {
     }
 }
 
+// Продление срока действия заявки
+async function extendOrder(orderId) {
+    if (!currentUser) {
+        showError('Пользователь не авторизован');
+        return;
+    }
+    
+    try {
+        const response = await fetch(`/api/v1/orders/${orderId}/extend`, {
+            method: 'POST',
+            headers: {
+                'Authorization': 'Bearer ' + sessionToken
+            }
+        });
+        
+        const result = await response.json();
+        
+        if (result.success) {
+            showSuccess('Заявка продлена до ' + new Date(result.expires_at).toLocaleString('ru-RU'));
+            loadMyOrders(); // Перезагружаем список заявок
+        } else {
+            showError('Ошибка продления заявки: ' + result.error);
+        }
+    } catch (error) {
+        console.error('[ERROR] Ошибка продления заявки:', error);
+        showError('Ошибка сети при продлении заявки');
+    }
+}
+
 // Создание отзыва
 async function createReview(dealId, toUserId, rating, comment, isAnonymous) {
     if (!currentUser) {
@@ -1282,7 +1461,7 @@ async function createReview(dealId, toUserId, rating, comment, isAnonymous) {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/json',
-                'X-Telegram-User-ID': currentUser.id.toString()
+                'Authorization': 'Bearer ' + sessionToken
             },
             body: JSON.stringify({
                 deal_id: dealId,
@@ -1361,6 +1540,7 @@ document.addEventListener('DOMContentLoaded', () => {
     initNavigation();
     initModal();
     initReviewModal();
+    loadAssetRegistry();
     
     // Обрабатываем хэш URL после полной инициализации
     setTimeout(() => {
@@ -1560,10 +1740,13 @@ function createOrderCardHTML(order) {
                 '<div>' +
                     '<span style="color: rgba(255, 255, 255, 0.6);">📊 Объем:</span><br>' +
                     '<strong style="color: #ffffff;">' + (order.amount || '?') + ' ' + (order.cryptocurrency || '?') + '</strong>' +
+                    (order.remaining_amount && order.remaining_amount < order.amount
+                        ? '<br><span style="color: rgba(255, 255, 255, 0.6); font-size: 12px;">Доступно: ' + order.remaining_amount + '</span>'
+                        : '') +
                 '</div>' +
                 '<div>' +
                     '<span style="color: rgba(255, 255, 255, 0.6);">💰 Курс:</span><br>' +
-                    '<strong style="color: #ffffff;">' + (order.price || '?') + ' ' + (order.fiat_currency || '?') + ' за 1' + (order.cryptocurrency || '?') + '</strong>' +
+                    '<strong style="color: #ffffff;">' + (order.price || '?') + ' ' + (order.fiat_currency || '?') + ' за 1' + (order.cryptocurrency || '?') + floatingPriceLabel(order) + '</strong>' +
                 '</div>' +
             '</div>' +
             '<div style="margin-top: 8px; padding-top: 8px; border-top: 1px solid rgba(255, 255, 255, 0.15); font-size: 13px;">' +
@@ -1573,7 +1756,7 @@ function createOrderCardHTML(order) {
         '</div>' +
         
         '<div style="font-size: 12px; color: rgba(255, 255, 255, 0.6); margin-bottom: 10px;">' +
-            '💳 Способы оплаты: <span style="color: #ffffff;">' + ((order.payment_methods || []).join(', ') || 'Не указано') + '</span>' +
+            '💳 Способы оплаты: <span style="color: #ffffff;">' + (paymentMethodLabels(order.payment_methods) || 'Не указано') + '</span>' +
         '</div>' +
         
         (order.description ? '<div style="font-size: 12px; margin-bottom: 10px; color: #ffffff;">' + order.description + '</div>' : '') +
@@ -1704,10 +1887,10 @@ async function openUserProfile(userId) {
         // Загружаем профиль пользователя
         const [profileResponse, reviewsResponse] = await Promise.all([
             fetch(`/api/v1/users/${userId}/profile`, {
-                headers: { 'X-Telegram-User-ID': currentUser.id.toString() }
+                headers: { 'Authorization': 'Bearer ' + sessionToken }
             }),
             fetch(`/api/v1/reviews?user_id=${userId}&limit=5`, {
-                headers: { 'X-Telegram-User-ID': currentUser.id.toString() }
+                headers: { 'Authorization': 'Bearer ' + sessionToken }
             }).catch(() => null)
         ]);
 
@@ -1889,7 +2072,7 @@ async function respondToOrder(orderId) {
     try {
         // Загружаем детали заявки
         const response = await fetch(`/api/v1/orders/${orderId}`, {
-            headers: { 'X-Telegram-User-ID': currentUser.id.toString() }
+            headers: { 'Authorization': 'Bearer ' + sessionToken }
         });
         
         const result = await response.json();
@@ -1921,9 +2104,19 @@ function displayOrderDetails(order) {
                 <span class="order-info-label">Количество:</span>
                 <span class="order-info-value">${order.amount} ${order.cryptocurrency}</span>
             </div>
+            <div class="order-info-row">
+                <span class="order-info-label">Доступно:</span>
+                <span class="order-info-value">${order.remaining_amount || order.amount} ${order.cryptocurrency}</span>
+            </div>
+            ${order.min_amount || order.max_amount ? `
+            <div class="order-info-row">
+                <span class="order-info-label">Лимиты сделки:</span>
+                <span class="order-info-value">${order.min_amount || 0} – ${order.max_amount || '∞'} ${order.fiat_currency}</span>
+            </div>
+            ` : ''}
             <div class="order-info-row">
                 <span class="order-info-label">Курс:</span>
-                <span class="order-info-value">${order.price} ${order.fiat_currency}</span>
+                <span class="order-info-value">${order.price} ${order.fiat_currency}${floatingPriceLabel(order)}</span>
             </div>
             <div class="order-info-row">
                 <span class="order-info-label">Общая сумма:</span>
@@ -1933,7 +2126,7 @@ function displayOrderDetails(order) {
             </div>
             <div class="order-info-row">
                 <span class="order-info-label">Способы оплаты:</span>
-                <span class="order-info-value">${(order.payment_methods || []).join(', ') || 'Не указано'}</span>
+                <span class="order-info-value">${paymentMethodLabels(order.payment_methods) || 'Не указано'}</span>
             </div>
             ${order.description ? `
             <div style="margin-top: 12px; padding-top: 12px; border-top: 1px solid var(--tg-theme-section-separator-color, #e1e8ed);">
@@ -1945,6 +2138,33 @@ function displayOrderDetails(order) {
             ` : ''}
         </div>
     `;
+
+    // По умолчанию предлагаем взять весь доступный остаток по цене заявки
+    const amountField = document.getElementById('respondAmount');
+    if (amountField) {
+        amountField.value = order.remaining_amount || order.amount;
+        amountField.max = order.remaining_amount || order.amount;
+    }
+    const priceField = document.getElementById('respondPrice');
+    if (priceField) {
+        priceField.value = order.price;
+        priceField.dataset.orderPrice = order.price;
+    }
+
+    // Способ оплаты выбирается из способов заявки
+    const methodField = document.getElementById('respondPaymentMethod');
+    if (methodField) {
+        methodField.innerHTML = '<option value="">Любой из способов заявки</option>' +
+            (order.payment_methods || []).map(method => `<option value="${method}">${paymentMethodLabels([method])}</option>`).join('');
+    }
+
+    // Подсказка о правилах автопринятия автора
+    const autoAcceptHint = document.getElementById('respondAutoAcceptHint');
+    if (autoAcceptHint) {
+        autoAcceptHint.textContent = order.auto_accept && order.auto_accept.enabled
+            ? 'Автор принимает подходящие отклики автоматически - сделка будет создана сразу'
+            : 'Автор рассматривает отклики вручную';
+    }
 }
 
 // Отправка отклика (обновлено для новой логики)
@@ -1952,6 +2172,14 @@ async function submitResponse() {
     const modal = document.getElementById('respondModal');
     const orderId = parseInt(modal.dataset.orderId);
     const message = document.getElementById('respondMessage').value.trim();
+    const amount = parseFloat(document.getElementById('respondAmount').value) || 0;
+    const priceField = document.getElementById('respondPrice');
+    let price = parseFloat(priceField.value) || 0;
+    if (price === parseFloat(priceField.dataset.orderPrice)) {
+        price = 0; // Цена заявки - отдельное предложение не нужно
+    }
+    const paymentMethod = document.getElementById('respondPaymentMethod').value;
+    const autoAccept = document.getElementById('respondAutoAccept').checked;
     
     if (!currentUser) {
         showAlert('❌ Требуется авторизация');
@@ -1970,17 +2198,30 @@ async function submitResponse() {
     submitBtn.textContent = 'Отправка...';
     
     try {
-        console.log('[DEBUG] Создание отклика на заявку:', { orderId, message });
+        console.log('[DEBUG] Создание отклика на заявку:', { orderId, amount, price, message });
         
-        // Создаем отклик через новый API
+        // Создаем отклик через новый API (amount = 0 - весь остаток, price = 0 - цена заявки)
         const result = await apiRequest('/api/v1/responses', 'POST', {
             order_id: orderId,
+            amount: amount,
+            price: price,
+            payment_method: paymentMethod,
+            auto_accept: autoAccept,
             message: message
         });
         
         if (result.success) {
             console.log('[INFO] Отклик создан:', result.response);
             
+            if (result.response && result.response.status === 'accepted') {
+                // Отклик подошел под правила автора - сделка уже создана
+                showAlert('✅ ' + result.message);
+                closeRespondModal();
+                goToDeals();
+                loadOrders();
+                return;
+            }
+            
             if (tg) {
                 tg.showPopup({
                     message: 'Отклик отправлен!\n\nВы откликнулись на заявку. Автор заявки рассмотрит ваш отклик и примет решение.'
@@ -2049,13 +2290,32 @@ function createRespondModal() {
                         </div>
                     </div>
                     
+                    <div class="form-group">
+                        <label class="form-label">Количество для сделки:</label>
+                        <input type="number" id="respondAmount" class="form-input" step="0.00000001" min="0">
+                    </div>
+                    
+                    <div class="form-group">
+                        <label class="form-label">Ваша цена за единицу (можно предложить свою):</label>
+                        <input type="number" id="respondPrice" class="form-input" step="0.01" min="0">
+                    </div>
+                    
                     <div class="form-group">
                         <label class="form-label">Сообщение контрагенту (необязательно):</label>
                         <textarea id="respondMessage" class="form-textarea" rows="3" maxlength="200" 
                                   placeholder="Например: Готов к сделке, жду контакта"></textarea>
                     </div>
                     
-                    <!-- Автоматическое принятие отключено в новой логике откликов -->
+                    <div class="form-group">
+                        <label class="form-label">Способ оплаты:</label>
+                        <select id="respondPaymentMethod" class="form-select"></select>
+                    </div>
+                    
+                    <div class="form-group">
+                        <label><input type="checkbox" id="respondAutoAccept" checked> Сразу начать сделку, если отклик подходит под правила автора</label>
+                        <div id="respondAutoAcceptHint" style="font-size: 12px; color: var(--tg-theme-hint-color, #708499);"></div>
+                    </div>
+                    
                     
                     <div class="modal-footer">
                         <button type="button" onclick="closeRespondModal()" class="btn btn-secondary">
@@ -2117,7 +2377,7 @@ async function editOrder(orderId) {
     try {
         // Получаем данные заявки
         const response = await fetch(`/api/v1/orders/${orderId}`, {
-            headers: { 'X-Telegram-User-ID': currentUser.id.toString() }
+            headers: { 'Authorization': 'Bearer ' + sessionToken }
         });
 
         if (!response.ok) {
@@ -2135,12 +2395,15 @@ async function editOrder(orderId) {
         document.querySelector('[name="amount"]').value = order.amount;
         document.querySelector('[name="price"]').value = order.price;
         document.querySelector('[name="description"]').value = order.description || '';
+        document.querySelector('[name="price_type"]').value = order.price_type || 'fixed';
+        document.querySelector('[name="price_margin"]').value = order.price_margin || '';
 
         // Устанавливаем способы оплаты
         const paymentMethods = Array.isArray(order.payment_methods) ? order.payment_methods : [];
         document.querySelectorAll('[name="payment_methods"]').forEach(checkbox => {
             checkbox.checked = paymentMethods.includes(checkbox.value);
         });
+        fillAutoAcceptRules(order.auto_accept);
 
         // Показываем модальное окно
         document.getElementById('createOrderModal').classList.add('show');
@@ -2169,7 +2432,7 @@ async function viewOrderResponses(orderId) {
     try {
         // Получаем сделки связанные с заявкой
         const response = await fetch(`/api/v1/deals?order_id=${orderId}`, {
-            headers: { 'X-Telegram-User-ID': currentUser.id.toString() }
+            headers: { 'Authorization': 'Bearer ' + sessionToken }
         });
 
         const result = await response.json();
@@ -2296,7 +2559,7 @@ async function viewDealDetails(dealId) {
 
     try {
         const response = await fetch(`/api/v1/deals/${dealId}`, {
-            headers: { 'X-Telegram-User-ID': currentUser.id.toString() }
+            headers: { 'Authorization': 'Bearer ' + sessionToken }
         });
 
         if (response.ok) {
@@ -2407,7 +2670,7 @@ async function confirmDeal(dealId) {
         const response = await fetch(`/api/v1/deals/${dealId}/confirm`, {
             method: 'POST',
             headers: {
-                'X-Telegram-User-ID': currentUser.id.toString(),
+                'Authorization': 'Bearer ' + sessionToken,
                 'Content-Type': 'application/json'
             }
         });
@@ -2817,7 +3080,9 @@ function createMyResponseCard(response) {
     const statusConfig = {
         waiting: { icon: '🟡', text: 'Ожидает', color: '#f59e0b' },
         accepted: { icon: '🟢', text: 'Принят', color: '#22c55e' },
-        rejected: { icon: '🔴', text: 'Отклонен', color: '#ef4444' }
+        rejected: { icon: '🔴', text: 'Отклонен', color: '#ef4444' },
+        countered: { icon: '🔵', text: 'Встречное предложение', color: '#3b82f6' },
+        withdrawn: { icon: '⚪', text: 'Отозван', color: '#6b7280' }
     };
     
     const status = statusConfig[response.status] || statusConfig.waiting;
@@ -2852,6 +3117,26 @@ function createMyResponseCard(response) {
                 <p>${response.message || 'Без сообщения'}</p>
             </div>
             
+            ${createResponseTermsBlock(response)}
+            
+            ${response.status === 'countered' ? `
+                <div style="display: flex; gap: 6px; margin-top: 12px;">
+                    <button onclick="acceptResponse(${response.id})" class="btn btn-compact btn-success" style="flex: 1;">
+                        ✅ Принять условия
+                    </button>
+                    <button onclick="counterResponse(${response.id})" class="btn btn-compact btn-secondary" style="flex: 1;">
+                        💬 Свое предложение
+                    </button>
+                </div>
+            ` : ''}
+            ${response.status === 'waiting' || response.status === 'countered' ? `
+                <div style="display: flex; gap: 6px; margin-top: 6px;">
+                    <button onclick="withdrawResponse(${response.id})" class="btn btn-compact btn-danger" style="flex: 1;">
+                        ↩️ Отозвать отклик
+                    </button>
+                </div>
+            ` : ''}
+            
             ${response.status === 'accepted' ? `
                 <div class="response-actions">
                     ${response.deal_status === 'completed' ? `
@@ -2874,7 +3159,9 @@ function createOrderResponseCard(response) {
     const statusConfig = {
         waiting: { icon: '🟡', text: 'Ожидает', color: '#f59e0b' },
         accepted: { icon: '🟢', text: 'Принят', color: '#22c55e' },
-        rejected: { icon: '🔴', text: 'Отклонен', color: '#ef4444' }
+        rejected: { icon: '🔴', text: 'Отклонен', color: '#ef4444' },
+        countered: { icon: '🔵', text: 'Встречное предложение', color: '#3b82f6' },
+        withdrawn: { icon: '⚪', text: 'Отозван', color: '#6b7280' }
     };
     
     const status = statusConfig[response.status] || statusConfig.waiting;
@@ -2903,11 +3190,24 @@ function createOrderResponseCard(response) {
                 <p>${response.message || 'Без сообщения'}</p>
             </div>
             
+            ${createResponseTermsBlock(response)}
+            
             ${response.status === 'waiting' ? `
                 <div style="display: flex; gap: 6px; margin-top: 12px;">
                     <button onclick="acceptResponse(${response.id})" class="btn btn-compact btn-success" style="flex: 1;">
                         ✅ Принять
                     </button>
+                    <button onclick="counterResponse(${response.id})" class="btn btn-compact btn-secondary" style="flex: 1;">
+                        💬 Встречное
+                    </button>
+                    <button onclick="rejectResponse(${response.id})" class="btn btn-compact btn-danger" style="flex: 1;">
+                        ❌ Отклонить
+                    </button>
+                </div>
+            ` : ''}
+            ${response.status === 'countered' ? `
+                <div style="display: flex; gap: 6px; margin-top: 12px;">
+                    <span style="flex: 2; font-size: 12px; color: var(--tg-theme-hint-color, #708499); align-self: center;">⏳ Ждем ответа на ваше предложение</span>
                     <button onclick="rejectResponse(${response.id})" class="btn btn-compact btn-danger" style="flex: 1;">
                         ❌ Отклонить
                     </button>
@@ -2917,6 +3217,89 @@ function createOrderResponseCard(response) {
     `;
 }
 
+// Блок текущих условий отклика и истории переговоров
+function createResponseTermsBlock(response) {
+    const price = response.proposed_price || response.price;
+    const amount = response.requested_amount || response.amount;
+    const offers = response.offers || [];
+    
+    return `
+        <div style="font-size: 12px; margin-top: 8px;">
+            <strong>🤝 Условия:</strong> ${amount} ${response.cryptocurrency || ''} по ${price} ${response.fiat_currency || ''}
+            = ${(amount * price).toLocaleString('ru')} ${response.fiat_currency || ''}
+        </div>
+        ${offers.length > 1 ? `
+            <details style="font-size: 12px; margin-top: 6px; color: var(--tg-theme-hint-color, #708499);">
+                <summary>📜 История предложений (${offers.length})</summary>
+                ${offers.map(offer => `
+                    <div style="margin-top: 4px;">
+                        ${new Date(offer.created_at).toLocaleString('ru-RU')} -
+                        ${offer.user_id === response.user_id ? 'откликнувшийся' : 'автор заявки'}:
+                        ${offer.amount} по ${offer.price}${offer.message ? ` - "${offer.message}"` : ''}
+                    </div>
+                `).join('')}
+            </details>
+        ` : ''}
+    `;
+}
+
+// Отзыв своего отклика
+async function withdrawResponse(responseId) {
+    console.log('[DEBUG] Отзыв отклика:', responseId);
+    
+    if (!confirm('Отозвать отклик? Автор заявки получит уведомление.')) {
+        return;
+    }
+    
+    try {
+        const result = await apiRequest(`/api/v1/responses/${responseId}/withdraw`, 'POST');
+        
+        if (result.success) {
+            showAlert('↩️ Отклик отозван');
+            await loadMyResponses();
+        } else {
+            showAlert('❌ ' + (result.message || 'Не удалось отозвать отклик'));
+        }
+    } catch (error) {
+        console.error('[ERROR] Ошибка отзыва отклика:', error);
+        showAlert('❌ Ошибка при отзыве отклика');
+    }
+}
+
+// Встречное предложение по отклику
+async function counterResponse(responseId) {
+    console.log('[DEBUG] Встречное предложение по отклику:', responseId);
+    
+    const priceInput = prompt('Ваша цена за единицу (пусто - без изменений):');
+    if (priceInput === null) {
+        return;
+    }
+    const amountInput = prompt('Количество (пусто - без изменений):');
+    if (amountInput === null) {
+        return;
+    }
+    const message = prompt('Комментарий к предложению (необязательно):') || '';
+    
+    try {
+        const result = await apiRequest(`/api/v1/responses/${responseId}/counter`, 'POST', {
+            price: parseFloat(priceInput) || 0,
+            amount: parseFloat(amountInput) || 0,
+            message: message
+        });
+        
+        if (result.success) {
+            showAlert('💬 Встречное предложение отправлено');
+            // Предложение могло быть и по моему отклику, и по отклику на мою заявку
+            await Promise.all([loadMyResponses(), loadResponsesToMyOrders()]);
+        } else {
+            showAlert('❌ ' + (result.message || 'Не удалось отправить предложение'));
+        }
+    } catch (error) {
+        console.error('[ERROR] Ошибка встречного предложения:', error);
+        showAlert('❌ Ошибка при отправке предложения');
+    }
+}
+
 // Принятие отклика
 async function acceptResponse(responseId) {
     console.log('[DEBUG] Принятие отклика:', responseId);
@@ -3082,6 +3465,8 @@ function createDealCard(deal) {
     const statusConfig = {
         in_progress: { icon: '⏳', text: 'В процессе', color: '#f59e0b' },
         waiting_payment: { icon: '💰', text: 'Ожидание оплаты', color: '#2BE47E' },
+        waiting_confirmation: { icon: '⏳', text: 'Ожидает подтверждения', color: '#f59e0b' },
+        dispute: { icon: '⚠️', text: 'Спор', color: '#ef4444' },
         completed: { icon: '✅', text: 'Завершена', color: '#22c55e' },
         cancelled: { icon: '❌', text: 'Отменена', color: '#ef4444' },
         expired: { icon: '⏰', text: 'Истекла', color: '#6b7280' }
@@ -3132,7 +3517,7 @@ function createDealCard(deal) {
                 
                 <div style="margin-top: 12px; padding-top: 8px; border-top: 1px solid var(--tg-theme-section-separator-color, #e2e8f0);">
                     <div style="color: var(--tg-theme-hint-color, #708499); margin-bottom: 4px;">💳 Способ оплаты:</div>
-                    <div style="font-weight: 500; color: var(--tg-theme-text-color, #000);">${(deal.payment_methods || []).join(', ') || 'Не указано'}</div>
+                    <div style="font-weight: 500; color: var(--tg-theme-text-color, #000);">${paymentMethodLabels(deal.payment_methods) || 'Не указано'}</div>
                 </div>
                 
                 <div style="margin-top: 8px; display: grid; grid-template-columns: 1fr 1fr; gap: 12px; font-size: 12px;">
@@ -3163,6 +3548,25 @@ function createDealCard(deal) {
                 </div>
             </div>
             
+            ${deal.status === 'cancelled' && deal.cancel_reason ? `
+                <div style="background: var(--tg-theme-secondary-bg-color, #f8fafc); border-radius: 6px; padding: 8px; margin-bottom: 12px; font-size: 12px; color: var(--tg-theme-text-color, #000);">
+                    ❌ Причина отмены: ${deal.cancel_reason}
+                </div>
+            ` : ''}
+            
+            ${(deal.status === 'in_progress' || deal.status === 'waiting_confirmation') && deal.cancel_requested_by ? `
+                <div style="background: rgba(245, 158, 11, 0.1); border: 1px solid #f59e0b; border-radius: 6px; padding: 8px; margin-bottom: 12px; font-size: 12px; color: var(--tg-theme-text-color, #000);">
+                    🤚 ${deal.cancel_requested_by === currentInternalUserId ? 'Вы запросили отмену, ожидается согласие контрагента' : 'Контрагент просит отменить сделку'}: ${deal.cancel_reason}
+                </div>
+            ` : ''}
+            
+            ${deal.status === 'dispute' ? `
+                <div style="background: rgba(239, 68, 68, 0.1); border: 1px solid #ef4444; border-radius: 6px; padding: 8px; margin-bottom: 12px; font-size: 12px; color: var(--tg-theme-text-color, #000);">
+                    ⚠️ Открыт спор: ${deal.dispute_reason || 'причина не указана'}<br>
+                    <span style="color: var(--tg-theme-hint-color, #708499);">Подтверждения заморожены до решения администратора</span>
+                </div>
+            ` : ''}
+            
             <div style="display: flex; gap: 8px;">
                 ${counterpartyTelegramUsername ? `
                     <button onclick="contactCounterparty('${counterpartyTelegramUsername}')" style="background: var(--tg-theme-button-color, #2BE47E); color: black; border: none; padding: 8px 12px; border-radius: 4px; font-size: 12px; flex: 1; font-weight: 700;">
@@ -3189,11 +3593,17 @@ function createDealCard(deal) {
                                 '</button>';
                         }
                     })()}
-                ` : `
+                ` : (deal.status === 'in_progress' || deal.status === 'waiting_confirmation') ? `
                     <button onclick="confirmPayment(${deal.id}, ${isAuthor})" style="background: ${myConfirmed ? 'var(--tg-theme-hint-color, #6c757d)' : 'var(--tg-theme-button-color, #22c55e)'}; color: var(--tg-theme-button-text-color, white); border: none; padding: 8px 12px; border-radius: 4px; font-size: 12px; flex: 1;" ${myConfirmed ? 'disabled' : ''}>
                         ${myConfirmed ? '✅ Подтверждено' : '✅ Подтвердить'}
                     </button>
-                `}
+                    <button onclick="cancelDeal(${deal.id})" style="background: var(--tg-theme-hint-color, #6c757d); color: white; border: none; padding: 8px 12px; border-radius: 4px; font-size: 12px;">
+                        ${deal.cancel_requested_by && deal.cancel_requested_by !== currentInternalUserId ? '🤝 Согласиться на отмену' : '✖️ Отменить'}
+                    </button>
+                    <button onclick="openDispute(${deal.id})" style="background: #ef4444; color: white; border: none; padding: 8px 12px; border-radius: 4px; font-size: 12px;">
+                        ⚠️ Спор
+                    </button>
+                ` : ''}
             </div>
         </div>
     `;
@@ -3201,6 +3611,80 @@ function createDealCard(deal) {
 
 // Функция таймера удалена - больше не используем таймеры в сделках
 
+// Отмена сделки (или согласие на отмену, запрошенную контрагентом)
+async function cancelDeal(dealId) {
+    if (!currentUser) {
+        showError('Пользователь не авторизован');
+        return;
+    }
+    
+    const reason = prompt('Укажите причину отмены сделки:');
+    if (!reason || !reason.trim()) {
+        return;
+    }
+    
+    try {
+        const response = await fetch(`/api/v1/deals/${dealId}/cancel`, {
+            method: 'POST',
+            headers: {
+                'Authorization': 'Bearer ' + sessionToken,
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify({ reason: reason.trim() })
+        });
+        
+        const result = await response.json();
+        
+        if (result.success) {
+            showSuccess(result.message);
+            loadMyOrders(); // Заявка могла вернуться на биржу
+            loadDeals(); // Обновляем сделки
+        } else {
+            showError('Ошибка отмены сделки: ' + result.error);
+        }
+    } catch (error) {
+        console.error('[ERROR] Ошибка отмены сделки:', error);
+        showError('Ошибка сети при отмене сделки');
+    }
+}
+
+// Открытие спора по сделке
+async function openDispute(dealId) {
+    if (!currentUser) {
+        showError('Пользователь не авторизован');
+        return;
+    }
+    
+    const reason = prompt('Опишите причину спора:');
+    if (!reason || !reason.trim()) {
+        return;
+    }
+    const evidence = prompt('Доказательства (ссылки на скриншоты, номер транзакции) - необязательно:') || '';
+    
+    try {
+        const response = await fetch(`/api/v1/deals/${dealId}/dispute`, {
+            method: 'POST',
+            headers: {
+                'Authorization': 'Bearer ' + sessionToken,
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify({ reason: reason.trim(), evidence: evidence.trim() })
+        });
+        
+        const result = await response.json();
+        
+        if (result.success) {
+            showSuccess('Спор открыт. Администратор рассмотрит его в ближайшее время');
+            loadDeals(); // Обновляем сделки
+        } else {
+            showError('Ошибка открытия спора: ' + result.error);
+        }
+    } catch (error) {
+        console.error('[ERROR] Ошибка открытия спора:', error);
+        showError('Ошибка сети при открытии спора');
+    }
+}
+
 // Связь с контрагентом в Telegram
 function contactCounterparty(username) {
     console.log('[DEBUG] Открытие чата с контрагентом:', username);